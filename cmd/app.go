@@ -1,193 +1,293 @@
 package cmd
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"linea/internal"
 )
 
-// AppCreateCommand creates a new Linea App folder structure
-func AppCreateCommand(appName string) error {
-	// Check if directory already exists
+// AppCreateCommand creates a new Linea App folder structure from a template. The values
+// of the template's placeholders come from set, then from answers read from in, or
+// their defaults when in is nil
+func AppCreateCommand(appName, templateName string, set map[string]string, in io.Reader) error {
+	template, err := internal.FindAppTemplate(templateName)
+	if err != nil {
+		return err
+	}
 	if _, err := os.Stat(appName); err == nil {
 		return fmt.Errorf("directory %s already exists", appName)
 	}
 
-	// Create directory structure
-	workflowsDir := filepath.Join(appName, ".linea", "workflows")
-	scriptsDir := filepath.Join(appName, "scripts")
-
-	if err := os.MkdirAll(workflowsDir, 0755); err != nil {
-		return fmt.Errorf("failed to create workflows directory: %w", err)
-	}
-
-	if err := os.MkdirAll(scriptsDir, 0755); err != nil {
-		return fmt.Errorf("failed to create scripts directory: %w", err)
-	}
-
-	// Create example workflow files
-	createVMWorkflow := `# Create VM Workflow
-# Usage: linea run .linea/workflows/create-vm.yml -s name="vm-name"
-
-command: echo
-args:
-  - "Creating VM: {name}"
-variables:
-  name: "default-vm"
-`
-
-	lsWorkflow := `# List Directory Workflow
-# Usage: linea run .linea/workflows/ls.yml
-
-command: ls
-args:
-  - -l
-  - -a
-`
-
-	// Write workflow files
-	if err := os.WriteFile(filepath.Join(workflowsDir, "create-vm.yml"), []byte(createVMWorkflow), 0644); err != nil {
-		return fmt.Errorf("failed to create create-vm.yml: %w", err)
-	}
-
-	if err := os.WriteFile(filepath.Join(workflowsDir, "ls.yml"), []byte(lsWorkflow), 0644); err != nil {
-		return fmt.Errorf("failed to create ls.yml: %w", err)
-	}
-
-	// Create example script
-	exampleScript := `# Linea Script Example with friendly syntax
-# No shebang required! Scripts can run directly with: lineash scripts/script.lnsh
-# Note: Use $variable syntax in lineash (not {variable} which is for YAML)
-
-# Variables
-VM_NAME="my-vm"
-VM_OS="alpine"
-
-echo "Starting VM creation..."
-
-# Friendly conditional syntax
-if $VM_OS == "alpine"
-    echo "Using Alpine Linux"
-    # Pass variables to workflows using $variable syntax
-    create-vm -s name=$VM_NAME
-else
-    echo "Using different OS"
-end
-
-# Friendly for loop syntax
-for item in workflows scripts
-    echo "Checking $item..."
-    ls
-end
-
-# While loop with arithmetic
-counter=1
-while $counter <= 3
-    echo "Iteration $counter"
-    counter=$((counter + 1))
-end
-
-echo "Script completed!"
-`
-
-	if err := os.WriteFile(filepath.Join(scriptsDir, "script.lnsh"), []byte(exampleScript), 0755); err != nil {
-		return fmt.Errorf("failed to create script.lnsh: %w", err)
-	}
-
-	// Create README
-	readme := "# " + appName + "\n\n" +
-		"This is a Linea App directory structure.\n\n" +
-		"## Directory Structure\n\n" +
-		"- `.linea/workflows/` - Workflow YAML files that can be executed as commands\n" +
-		"- `scripts/` - Lineash scripts (`.lnsh` files) that can use workflows as commands\n\n" +
-		"## Usage\n\n" +
-		"### Running Workflows\n\n" +
-		"```bash\n" +
-		"# Run a workflow directly\n" +
-		"linea run .linea/workflows/create-vm.yml -s name=\"my-vm\"\n\n" +
-		"# Or use lineash to run workflows as commands\n" +
-		"lineash scripts/script.lnsh\n" +
-		"```\n\n" +
-		"### Creating New Workflows\n\n" +
-		"1. Create a new YAML file in `.linea/workflows/`\n" +
-		"2. Define your command structure\n" +
-		"3. Use it in scripts or run directly with `linea run`\n\n" +
-		"### Writing Scripts\n\n" +
-		"Scripts in `scripts/` can:\n" +
-		"- Execute workflows as commands (if they exist in `.linea/workflows/`)\n" +
-		"- Use friendly syntax (variables, conditionals, loops)\n" +
-		"- Call system commands\n" +
-		"- No shebang required!\n\n" +
-		"**Friendly Syntax Features:**\n" +
-		"- Conditionals: `if $VAR == \"value\" ... else ... end`\n" +
-		"- For loops: `for item in list ... end`\n" +
-		"- While loops: `while condition ... end`\n" +
-		"- Arithmetic: `$((expression))`\n" +
-		"- Operators: `==`, `!=`, `<`, `>`, `<=`, `>=`\n\n" +
-		"Example:\n" +
-		"```bash\n" +
-		"# No shebang needed!\n" +
-		"VM_NAME=\"my-vm\"\n" +
-		"if $VM_NAME == \"my-vm\"\n" +
-		"    create-vm -s name=$VM_NAME\n" +
-		"end\n" +
-		"```\n"
-
-	if err := os.WriteFile(filepath.Join(appName, "README.md"), []byte(readme), 0644); err != nil {
-		return fmt.Errorf("failed to create README.md: %w", err)
-	}
-
-	fmt.Printf("✅ Created Linea App: %s\n", appName)
+	var ask func(p internal.AppTemplatePrompt, def string) string
+	if in != nil && len(template.Prompts) > 0 {
+		reader := bufio.NewReader(in)
+		fmt.Printf("Creating %s from template %s (press Enter to accept defaults)\n\n", appName, template.Name)
+		ask = func(p internal.AppTemplatePrompt, def string) string {
+			answer, _ := prompt(reader, p.Question(), def)
+			return answer
+		}
+	}
+	values, err := internal.AppTemplateValues(appName, template, set, ask)
+	if err != nil {
+		return err
+	}
+	if ask != nil {
+		fmt.Printf("\n")
+	}
+
+	files, err := internal.CreateApp(appName, template, values)
+	if err != nil {
+		return err
+	}
+
+	internal.Output.Printf(internal.StatusSuccess, "Created Linea App: %s (template %s)\n", appName, template.Name)
+	if internal.Output.Quiet {
+		return nil
+	}
 	fmt.Printf("\n")
 	fmt.Printf("Directory structure:\n")
-	fmt.Printf("  %s/\n", appName)
-	fmt.Printf("  ├─ .linea/workflows/\n")
-	fmt.Printf("  │   ├─ create-vm.yml\n")
-	fmt.Printf("  │   └─ ls.yml\n")
-	fmt.Printf("  ├─ scripts/\n")
-		fmt.Printf("  │   └─ script.lnsh\n")
-	fmt.Printf("  └─ README.md\n")
+	printAppTree(appName, files)
 	fmt.Printf("\n")
 	fmt.Printf("Next steps:\n")
 	fmt.Printf("  • Edit workflows in .linea/workflows/\n")
 	fmt.Printf("  • Create scripts in scripts/\n")
-		fmt.Printf("  • Run scripts: lineash scripts/script.lnsh\n")
+	for _, file := range files {
+		if strings.HasPrefix(file, "scripts/") && strings.HasSuffix(file, ".lnsh") {
+			fmt.Printf("  • Run scripts: lineash %s\n", file)
+			break
+		}
+	}
 	fmt.Printf("\n")
 
 	return nil
 }
 
-// AppCreateCommandMain is the entry point for the app create subcommand
+// printAppTree prints the files of a new app, grouped by directory, directories first
+func printAppTree(appName string, files []string) {
+	var dirs, rootFiles []string
+	byDir := map[string][]string{}
+	for _, file := range files {
+		dir := path.Dir(file)
+		if dir == "." {
+			rootFiles = append(rootFiles, file)
+			continue
+		}
+		if _, ok := byDir[dir]; !ok {
+			dirs = append(dirs, dir)
+		}
+		byDir[dir] = append(byDir[dir], path.Base(file))
+	}
+
+	fmt.Printf("  %s/\n", appName)
+	for i, dir := range dirs {
+		branch, indent := "├─", "│   "
+		if i == len(dirs)-1 && len(rootFiles) == 0 {
+			branch, indent = "└─", "    "
+		}
+		fmt.Printf("  %s %s/\n", branch, dir)
+		for j, name := range byDir[dir] {
+			if j == len(byDir[dir])-1 {
+				fmt.Printf("  %s└─ %s\n", indent, name)
+			} else {
+				fmt.Printf("  %s├─ %s\n", indent, name)
+			}
+		}
+	}
+	for i, name := range rootFiles {
+		if i == len(rootFiles)-1 {
+			fmt.Printf("  └─ %s\n", name)
+		} else {
+			fmt.Printf("  ├─ %s\n", name)
+		}
+	}
+}
+
+// AppTemplatesCommand lists the templates of linea app create
+func AppTemplatesCommand() error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "TEMPLATE\tSOURCE\tPROMPTS\tDESCRIPTION\n")
+	for _, template := range internal.AppTemplates() {
+		description := template.Description
+		if description == "" {
+			description = "-"
+		}
+		var names []string
+		for _, p := range template.Prompts {
+			names = append(names, p.Name)
+		}
+		prompts := strings.Join(names, ", ")
+		if prompts == "" {
+			prompts = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", template.Name, template.Source(), prompts, description)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\nAdd your own templates in %s\n", internal.AppTemplatesDir())
+	return nil
+}
+
+// AppDoctorCommand checks the layout of the Linea app in dir and prints a fix for every
+// problem. Returns the number of problems, not counting warnings
+func AppDoctorCommand(dir string) (int, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return 0, err
+	}
+	if info, err := os.Stat(abs); err != nil || !info.IsDir() {
+		return 0, fmt.Errorf("%s is not a directory", dir)
+	}
+	root := internal.AppRoot(abs)
+	diagnostics := internal.DiagnoseApp(root)
+
+	fmt.Printf("Linea app doctor: %s\n", root)
+	problems, warnings := 0, 0
+	for _, check := range internal.AppChecks {
+		printed := false
+		for _, d := range diagnostics {
+			if d.Check != check {
+				continue
+			}
+			if !printed {
+				fmt.Printf("\n%s:\n", check)
+				printed = true
+			}
+			switch {
+			case d.Problem == "":
+				fmt.Printf("  %s %s\n", internal.Output.Symbol(os.Stdout, internal.StatusSuccess), d.Subject)
+				continue
+			case d.Warning:
+				warnings++
+				fmt.Printf("  %s %s: %s\n", internal.Output.Symbol(os.Stdout, internal.StatusWarning), d.Subject, d.Problem)
+			default:
+				problems++
+				fmt.Printf("  %s %s: %s\n", internal.Output.Symbol(os.Stdout, internal.StatusError), d.Subject, d.Problem)
+			}
+			if d.Fix != "" {
+				fmt.Printf("     %s Fix: %s\n", internal.Output.Symbol(os.Stdout, internal.StatusHint), d.Fix)
+			}
+		}
+	}
+
+	fmt.Printf("\n")
+	if problems == 0 && warnings == 0 {
+		internal.Output.Printf(internal.StatusSuccess, "Everything looks good\n")
+	} else {
+		fmt.Printf("%d problem(s), %d warning(s)\n", problems, warnings)
+	}
+	return problems, nil
+}
+
+// printAppUsage prints the usage of the app subcommand with an error message
+func printAppUsage(message string) {
+	fmt.Fprintf(os.Stderr, "\n")
+	internal.Output.Eprintf(internal.StatusError, "  Error: %s\n", message)
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "  USAGE:\n")
+	fmt.Fprintf(os.Stderr, "    linea app create <app-name> [--template <name>] [-s name=value]... [-y]\n")
+	fmt.Fprintf(os.Stderr, "    linea app templates\n")
+	fmt.Fprintf(os.Stderr, "    linea app doctor [dir]\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "  EXAMPLES:\n")
+	fmt.Fprintf(os.Stderr, "    linea app create my-app\n")
+	fmt.Fprintf(os.Stderr, "    linea app create api --template node\n")
+	fmt.Fprintf(os.Stderr, "    linea app create image --template docker\n")
+	fmt.Fprintf(os.Stderr, "    linea app create billing --template service -s port=8080 -y\n")
+	fmt.Fprintf(os.Stderr, "\n")
+}
+
+// AppCreateCommandMain is the entry point for the app subcommand
 func AppCreateCommandMain(args []string) {
-	if len(args) < 2 {
-		fmt.Fprintf(os.Stderr, "\n")
-		fmt.Fprintf(os.Stderr, "  ❌ Error: no app name specified\n")
-		fmt.Fprintf(os.Stderr, "\n")
-		fmt.Fprintf(os.Stderr, "  USAGE:\n")
-		fmt.Fprintf(os.Stderr, "    linea app create <app-name>\n")
-		fmt.Fprintf(os.Stderr, "\n")
-		fmt.Fprintf(os.Stderr, "  EXAMPLES:\n")
-		fmt.Fprintf(os.Stderr, "    linea app create my-app\n")
-		fmt.Fprintf(os.Stderr, "    linea app create deployment\n")
-		fmt.Fprintf(os.Stderr, "\n")
+	if len(args) == 0 {
+		printAppUsage("no app subcommand specified")
 		os.Exit(1)
 	}
 
-	if args[0] != "create" {
-		fmt.Fprintf(os.Stderr, "\n")
-		fmt.Fprintf(os.Stderr, "  ❌ Error: unknown app subcommand '%s'\n", args[0])
-		fmt.Fprintf(os.Stderr, "\n")
-		fmt.Fprintf(os.Stderr, "  USAGE:\n")
-		fmt.Fprintf(os.Stderr, "    linea app create <app-name>\n")
-		fmt.Fprintf(os.Stderr, "\n")
+	switch args[0] {
+	case "create":
+	case "templates":
+		if err := AppTemplatesCommand(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	case "doctor":
+		if len(args) > 2 {
+			printAppUsage("doctor takes at most one directory")
+			os.Exit(1)
+		}
+		dir := "."
+		if len(args) == 2 {
+			dir = args[1]
+		}
+		problems, err := AppDoctorCommand(dir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if problems > 0 {
+			os.Exit(1)
+		}
+		return
+	default:
+		printAppUsage(fmt.Sprintf("unknown app subcommand '%s'", args[0]))
 		os.Exit(1)
 	}
 
-	appName := args[1]
+	appName, templateName := "", internal.DefaultAppTemplate
+	set := map[string]string{}
+	assumeYes := false
+	for i := 1; i < len(args); i++ {
+		switch {
+		case args[i] == "-s" || args[i] == "--set":
+			if i+1 >= len(args) || !strings.Contains(args[i+1], "=") {
+				printAppUsage(args[i] + " needs a name=value pair")
+				os.Exit(1)
+			}
+			i++
+			parts := strings.SplitN(args[i], "=", 2)
+			set[parts[0]] = parts[1]
+		case args[i] == "-y" || args[i] == "--yes":
+			assumeYes = true
+		case args[i] == "-t" || args[i] == "--template":
+			if i+1 >= len(args) {
+				printAppUsage(args[i] + " needs a template name")
+				os.Exit(1)
+			}
+			i++
+			templateName = args[i]
+		case strings.HasPrefix(args[i], "--template="):
+			templateName = strings.TrimPrefix(args[i], "--template=")
+		case strings.HasPrefix(args[i], "-"):
+			printAppUsage(fmt.Sprintf("unknown option '%s'", args[i]))
+			os.Exit(1)
+		case appName == "":
+			appName = args[i]
+		default:
+			printAppUsage(fmt.Sprintf("unexpected argument '%s'", args[i]))
+			os.Exit(1)
+		}
+	}
+	if appName == "" {
+		printAppUsage("no app name specified")
+		os.Exit(1)
+	}
 
-	if err := AppCreateCommand(appName); err != nil {
+	// Ask for the placeholders' values on a terminal, unless --yes accepts the defaults
+	var in io.Reader
+	if !assumeYes && internal.IsTerminal(os.Stdin) {
+		in = os.Stdin
+	}
+	if err := AppCreateCommand(appName, templateName, set, in); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 }
-
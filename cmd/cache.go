@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"linea/internal"
+)
+
+// CacheListCommand prints the keys of the shared cache with their size and last modification
+func CacheListCommand() error {
+	entries, err := internal.ListCacheEntries()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Cache directory: %s\n\n", internal.SharedCacheDir())
+	if len(entries) == 0 {
+		fmt.Println("The cache is empty. Steps store downloads under {cache_dir}/<key>.")
+		printStepCacheCount()
+		return nil
+	}
+
+	var total int64
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tSIZE\tFILES\tMODIFIED")
+	for _, entry := range entries {
+		total += entry.Size
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", entry.Key, formatBytes(entry.Size), entry.Files, entry.Modified.Format("2006-01-02 15:04"))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\n%d key(s), %s\n", len(entries), formatBytes(total))
+	printStepCacheCount()
+	return nil
+}
+
+// printStepCacheCount prints how many steps with cache: are cached, if any
+func printStepCacheCount() {
+	if n := internal.CountStepCache(); n > 0 {
+		fmt.Printf("%d cached step(s); linea cache clear runs them again\n", n)
+	}
+}
+
+// CacheClearCommand forgets the cached steps of the given workflows (of every workflow if
+// none are given), so that their next run runs them
+func CacheClearCommand(workflows []string) error {
+	cleared, err := internal.ClearStepCache(workflows)
+	if err != nil {
+		return err
+	}
+	internal.Output.Printf(internal.StatusSuccess, "Cleared the cache of %d step(s)\n", cleared)
+	return nil
+}
+
+// CacheCleanCommand evicts the given keys, or every key not modified within olderThan
+// (every key if olderThan is zero)
+func CacheCleanCommand(keys []string, olderThan time.Duration) error {
+	var removed []internal.CacheEntry
+	if len(keys) > 0 {
+		for _, key := range keys {
+			entry, err := internal.RemoveCacheEntry(key)
+			if err != nil {
+				return err
+			}
+			removed = append(removed, entry)
+		}
+	} else {
+		var err error
+		if removed, err = internal.CleanCache(olderThan, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	var freed int64
+	for _, entry := range removed {
+		freed += entry.Size
+		internal.Output.Printf(internal.StatusRemove, "Removed %s (%s)\n", entry.Key, formatBytes(entry.Size))
+	}
+	internal.Output.Printf(internal.StatusSuccess, "Removed %d key(s), freed %s\n", len(removed), formatBytes(freed))
+	return nil
+}
+
+// formatBytes formats a size in bytes with a binary unit, e.g. 1.5 MiB
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// CacheCommandMain is the entry point for the cache subcommand
+func CacheCommandMain(args []string) {
+	if len(args) < 1 {
+		printCacheUsage("no cache subcommand specified")
+		os.Exit(1)
+	}
+
+	var err error
+	switch args[0] {
+	case "ls", "list":
+		err = CacheListCommand()
+	case "clear":
+		var workflows []string
+		for _, arg := range args[1:] {
+			if !strings.HasPrefix(arg, "-") {
+				workflows = append(workflows, arg)
+			}
+		}
+		err = CacheClearCommand(workflows)
+	case "clean":
+		var keys []string
+		var olderThan time.Duration
+		for i := 1; i < len(args); i++ {
+			switch {
+			case args[i] == "--older-than":
+				if i+1 >= len(args) {
+					printCacheUsage("--older-than needs an age such as 30d")
+					os.Exit(1)
+				}
+				if olderThan, err = internal.ParseAge(args[i+1]); err != nil {
+					printCacheUsage(err.Error())
+					os.Exit(1)
+				}
+				i++
+			case !strings.HasPrefix(args[i], "-"):
+				keys = append(keys, args[i])
+			}
+		}
+		if len(keys) > 0 && olderThan > 0 {
+			printCacheUsage("--older-than cannot be combined with cache keys")
+			os.Exit(1)
+		}
+		err = CacheCleanCommand(keys, olderThan)
+	default:
+		printCacheUsage(fmt.Sprintf("unknown cache subcommand '%s'", args[0]))
+		os.Exit(1)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// printCacheUsage prints the usage of the cache subcommand with an error message
+func printCacheUsage(message string) {
+	fmt.Fprintf(os.Stderr, "\n")
+	internal.Output.Eprintf(internal.StatusError, "  Error: %s\n", message)
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "  USAGE:\n")
+	fmt.Fprintf(os.Stderr, "    linea cache ls                         List cache keys with their size\n")
+	fmt.Fprintf(os.Stderr, "    linea cache clean [key...]             Remove the given keys (all keys if none are given)\n")
+	fmt.Fprintf(os.Stderr, "    linea cache clean --older-than <age>   Remove keys not modified within age (e.g. 30d)\n")
+	fmt.Fprintf(os.Stderr, "    linea cache clear [workflow...]        Run the steps with cache: again (of every workflow if none are given)\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "  NOTE:\n")
+	fmt.Fprintf(os.Stderr, "    Workflows refer to the cache directory as {cache_dir}: %s\n", internal.SharedCacheDir())
+	fmt.Fprintf(os.Stderr, "\n")
+}
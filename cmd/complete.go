@@ -0,0 +1,293 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"linea/internal"
+)
+
+// completionSpec describes how the arguments of a subcommand are completed
+type completionSpec struct {
+	Flags       []string // Flags accepted by the subcommand
+	Subcommands []string // Nested subcommands (first argument only)
+	Workflows   bool     // Whether the subcommand takes a workflow name or file
+}
+
+// completionSpecs lists the visible subcommands and how their arguments are completed
+var completionSpecs = map[string]completionSpec{
+	"run":            {Flags: []string{"-v", "--verbose", "-s", "--set", "--set-json", "--force", "-y", "--yes", "--enforce-policy", "--require-signed", "--output", "--capture", "--log-file", "--idempotency-key", "--resume", "--no-cache", "--fail-fast", "--keep-going", "--summary", "--progress", "--progress-fd", "--progress-file", "--grace-period", "--chaos"}, Workflows: true},
+	"test":           {Flags: []string{"-s", "--set", "--set-json", "--resolve", "--output", "--shellquote"}, Workflows: true},
+	"help":           {Flags: []string{"--output"}, Workflows: true},
+	"init":           {Flags: []string{"-i", "--interactive", "-p", "--preset", "--list-presets", "--from-command"}},
+	"app":            {Subcommands: []string{"create", "templates", "doctor"}, Flags: []string{"-t", "--template", "-s", "--set", "-y", "--yes"}},
+	"import":         {Subcommands: []string{"gha", "make"}, Flags: []string{"--job", "--target", "-o", "--output", "-f", "--force"}},
+	"export":         {Flags: []string{"--format", "--parameterized", "-s", "--set", "--matrix", "-o", "--output", "-f", "--force"}, Workflows: true},
+	"sh":             {},
+	"validate":       {Flags: []string{"-s", "--set", "--set-json"}, Workflows: true},
+	"fmt":            {Flags: []string{"--check", "--upgrade"}},
+	"lint":           {Flags: []string{"--fix", "--disable", "--rules"}},
+	"doctor":         {},
+	"verify-install": {Flags: []string{"--keep"}},
+	"sign":           {Workflows: true},
+	"package":        {Flags: []string{"-o", "--output"}},
+	"install":        {},
+	"uninstall":      {},
+	"search":         {},
+	"publish":        {Flags: []string{"--bump", "--dry-run"}},
+	"verify":         {Workflows: true},
+	"list":           {Flags: []string{"-g", "--global", "--packages"}},
+	"global":         {Subcommands: []string{"install", "list"}, Flags: []string{"--name", "-f", "--force"}},
+	"cache":          {Subcommands: []string{"ls", "clean", "clear"}, Flags: []string{"--older-than"}},
+	"secret":         {Subcommands: []string{"set", "get", "list"}},
+	"config":         {Subcommands: []string{"get", "set", "list"}},
+	"stats":          {Flags: []string{"--days", "--json"}},
+	"schedule":       {Subcommands: []string{"start", "list", "add", "remove"}, Flags: []string{"--cron", "-s", "--set", "--log-file", "--metrics-addr"}},
+	"service":        {Subcommands: []string{"install", "start", "stop", "uninstall"}},
+	"serve":          {Flags: []string{"--addr", "--token", "--ui"}},
+	"history":        {Flags: []string{"-n", "--limit", "--all", "--workflow", "--failed", "--json"}},
+	"logs":           {Flags: []string{"--path"}},
+	"jobs":           {Flags: []string{"--json"}},
+	"stop":           {Flags: []string{"--all", "--timeout"}},
+	"rerun":          {Flags: []string{"-v", "--verbose", "-i", "--interactive", "-s", "--set", "--force", "-y", "--yes", "--enforce-policy", "--require-signed"}},
+	"completion":     {Subcommands: completionShells},
+}
+
+// globalFlags are accepted by every subcommand (see ParseGlobalFlags)
+var globalFlags = []string{"--theme", "--shell", "--quiet", "--no-color", "--strict"}
+
+// CompleteCommand returns completion candidates for the words typed after `linea`
+// The last word is the (possibly empty) word being completed
+// No candidates means the shell should fall back to file name completion
+func CompleteCommand(words []string) []string {
+	if len(words) == 0 {
+		return nil
+	}
+
+	current := words[len(words)-1]
+	if len(words) == 1 {
+		names := make([]string, 0, len(completionSpecs))
+		for name := range completionSpecs {
+			names = append(names, name)
+		}
+		return filterPrefix(names, current)
+	}
+
+	subcommand := words[0]
+	spec, ok := completionSpecs[subcommand]
+	if !ok {
+		return nil
+	}
+	args := words[1 : len(words)-1]
+	previous := words[len(words)-2]
+
+	// Variable names for -s/--set, sourced from the workflow index
+	if isSetFlag(previous) {
+		if subcommand == "rerun" {
+			return nil
+		}
+		return completeVariableNames(args, current)
+	}
+
+	if previous == "--theme" {
+		return filterPrefix(internal.ThemeNames(), current)
+	}
+	if previous == "--shell" {
+		return filterPrefix(internal.ShellNames, current)
+	}
+	if previous == "--format" && subcommand == "export" {
+		return filterPrefix(internal.ExportFormats, current)
+	}
+
+	if strings.HasPrefix(current, "-") {
+		return filterPrefix(append(append([]string{}, spec.Flags...), globalFlags...), current)
+	}
+
+	if len(args) == 0 && len(spec.Subcommands) > 0 {
+		return filterPrefix(spec.Subcommands, current)
+	}
+
+	switch subcommand {
+	case "config":
+		if len(args) == 1 && (args[0] == "get" || args[0] == "set") {
+			keys := make([]string, len(internal.ConfigKeys))
+			for i, key := range internal.ConfigKeys {
+				keys[i] = key.Name
+			}
+			return filterPrefix(keys, current)
+		}
+	case "rerun", "logs":
+		return filterPrefix(recentRunIDs(), current)
+	case "stop":
+		return filterPrefix(jobIDs(), current)
+	case "cache":
+		switch args[0] {
+		case "clean":
+			return filterPrefix(cacheKeys(), current)
+		case "clear":
+			return filterPrefix(workflowNames(), current)
+		}
+		return nil
+	case "schedule":
+		switch {
+		case len(args) == 1 && args[0] == "remove":
+			return filterPrefix(scheduleIDs(), current)
+		case args[0] == "add" && completionWorkflow(args[1:]) == nil:
+			return filterPrefix(workflowNames(), current)
+		}
+		return nil
+	}
+
+	if spec.Workflows && completionWorkflow(args) == nil {
+		return filterPrefix(workflowNames(), current)
+	}
+	return nil
+}
+
+// filterPrefix returns the sorted candidates starting with prefix
+func filterPrefix(candidates []string, prefix string) []string {
+	var matches []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, prefix) {
+			matches = append(matches, candidate)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// isSetFlag reports whether a word is one of the variable-setting flags
+func isSetFlag(word string) bool {
+	return word == "-s" || word == "--set" || word == "--set-json" || word == "--args"
+}
+
+// completeVariableNames suggests `name=` for the variables declared by the workflow in args
+func completeVariableNames(args []string, prefix string) []string {
+	workflow := completionWorkflow(args)
+	if workflow == nil {
+		return nil
+	}
+
+	var candidates []string
+	for _, name := range workflow.Variables {
+		if strings.HasPrefix(name, prefix) {
+			candidates = append(candidates, name+"=")
+		}
+	}
+	return candidates
+}
+
+// completionWorkflow finds the workflow referenced by already-typed arguments
+func completionWorkflow(args []string) *internal.WorkflowInfo {
+	for i := 0; i < len(args); i++ {
+		if isSetFlag(args[i]) {
+			i++ // Skip the variable=value pair
+			continue
+		}
+		if strings.HasPrefix(args[i], "-") {
+			continue
+		}
+		if path, err := internal.ResolveWorkflowPath(args[i]); err == nil {
+			return internal.IndexWorkflowFile(path)
+		}
+	}
+	return nil
+}
+
+// workflowNames returns the names of the workflows that can be run by name from the current directory
+func workflowNames() []string {
+	cwd, _ := os.Getwd()
+	seen := map[string]bool{}
+	var names []string
+	for _, dir := range internal.WorkflowSearchDirs(internal.FindWorkflowsDir(cwd)) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !internal.IsWorkflowFile(entry.Name()) {
+				continue
+			}
+			name := internal.WorkflowName(entry.Name())
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// recentRunIDs returns "last" and the IDs of the most recent runs, newest first
+func recentRunIDs() []string {
+	ids := []string{"last"}
+	records, err := internal.LoadRunHistory()
+	if err != nil {
+		return ids
+	}
+	for i := len(records) - 1; i >= 0 && len(ids) <= 20; i-- {
+		if records[i].ID != "" {
+			ids = append(ids, records[i].ID)
+		}
+	}
+	return ids
+}
+
+// jobIDs returns the IDs of the background jobs of the project in the current directory
+func jobIDs() []string {
+	jobs, _ := internal.LoadJobs(internal.JobsDir("."))
+	ids := make([]string, len(jobs))
+	for i, job := range jobs {
+		ids[i] = job.ID
+	}
+	return ids
+}
+
+// scheduleIDs returns the IDs of the registered schedules
+func scheduleIDs() []string {
+	entries, _ := internal.LoadSchedules()
+	ids := make([]string, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.ID
+	}
+	return ids
+}
+
+// cacheKeys returns the keys of the shared cache
+func cacheKeys() []string {
+	entries, _ := internal.ListCacheEntries()
+	keys := make([]string, len(entries))
+	for i, entry := range entries {
+		keys[i] = entry.Key
+	}
+	return keys
+}
+
+// splitCompletionLine splits a command line (up to the cursor) into the words after the program name
+// A trailing space starts a new, empty word
+func splitCompletionLine(line string) []string {
+	fields := strings.Fields(line)
+	if len(fields) > 0 {
+		fields = fields[1:]
+	}
+	for i, field := range fields {
+		fields[i] = strings.Trim(field, `"'`)
+	}
+	if line == "" || strings.HasSuffix(line, " ") || strings.HasSuffix(line, "\t") {
+		fields = append(fields, "")
+	}
+	return fields
+}
+
+// CompleteCommandMain is the entry point for the hidden __complete subcommand used by completion scripts
+// Words are passed as separate arguments, or as a whole line with --line for shells that drop empty arguments
+func CompleteCommandMain(args []string) {
+	if len(args) == 2 && args[0] == "--line" {
+		args = splitCompletionLine(args[1])
+	}
+	for _, candidate := range CompleteCommand(args) {
+		fmt.Println(candidate)
+	}
+}
@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"linea/internal"
+)
+
+// completionShells lists the shells `linea completion` can generate scripts for
+var completionShells = []string{"bash", "zsh", "fish", "powershell"}
+
+// bashCompletion completes through `linea __complete`, falling back to file names
+const bashCompletion = `# bash completion for linea
+# Load with: source <(linea completion bash)
+
+_linea() {
+    local line="${COMP_LINE:0:COMP_POINT}"
+    local -a words
+    read -ra words <<< "$line"
+    if [[ "$line" == *[[:space:]] ]]; then
+        words+=("")
+    fi
+
+    local IFS=$'\n'
+    COMPREPLY=($(linea __complete "${words[@]:1}" 2>/dev/null))
+
+    # Variable names end with '=' so the value can be typed right after
+    if [[ ${#COMPREPLY[@]} -eq 1 && "${COMPREPLY[0]}" == *= ]]; then
+        compopt -o nospace 2>/dev/null
+    fi
+}
+
+complete -o default -F _linea linea
+`
+
+// zshCompletion completes through `linea __complete`, falling back to file names
+const zshCompletion = `#compdef linea
+# zsh completion for linea
+# Load with: source <(linea completion zsh)
+# or save as _linea in a directory on $fpath
+
+_linea() {
+    local -a candidates assignments others
+    candidates=(${(f)"$(linea __complete "${(@)words[2,CURRENT]}" 2>/dev/null)"})
+
+    if (( ${#candidates} == 0 )); then
+        _files
+        return
+    fi
+
+    local candidate
+    for candidate in $candidates; do
+        if [[ $candidate == *= ]]; then
+            assignments+=($candidate)
+        else
+            others+=($candidate)
+        fi
+    done
+
+    # Variable names end with '=' so the value can be typed right after
+    (( ${#assignments} )) && compadd -S '' -- $assignments
+    (( ${#others} )) && compadd -- $others
+}
+
+if [[ "${funcstack[1]}" == "_linea" ]]; then
+    _linea "$@"
+else
+    compdef _linea linea
+fi
+`
+
+// fishCompletion completes through `linea __complete`, falling back to file names
+const fishCompletion = `# fish completion for linea
+# Load with: linea completion fish | source
+# or save as ~/.config/fish/completions/linea.fish
+
+function __linea_complete
+    set -l tokens (commandline -opc)
+    set -e tokens[1]
+    set -l candidates (linea __complete $tokens (commandline -ct) 2>/dev/null)
+    if test (count $candidates) -gt 0
+        printf '%s\n' $candidates
+    else
+        __fish_complete_path (commandline -ct)
+    end
+end
+
+complete -c linea -f -a '(__linea_complete)'
+`
+
+// powershellCompletion completes through `linea __complete --line`, which keeps empty words
+// that older PowerShell versions drop when calling native commands
+const powershellCompletion = `# PowerShell completion for linea
+# Load with: linea completion powershell | Out-String | Invoke-Expression
+# or add that line to your $PROFILE
+
+Register-ArgumentCompleter -Native -CommandName linea, linea.exe -ScriptBlock {
+    param($wordToComplete, $commandAst, $cursorPosition)
+
+    $line = $commandAst.Extent.Text
+    $length = $cursorPosition - $commandAst.Extent.StartOffset
+    if ($length -lt $line.Length) {
+        $line = $line.Substring(0, $length)
+    }
+    if ($wordToComplete -eq '' -and -not $line.EndsWith(' ')) {
+        $line += ' '
+    }
+
+    $candidates = @(& linea __complete --line $line 2>$null)
+    if ($candidates.Count -eq 0) {
+        return
+    }
+
+    $candidates | Where-Object { $_ } | ForEach-Object {
+        [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+    }
+}
+`
+
+// CompletionCommand prints the completion script for a shell
+func CompletionCommand(shell string) error {
+	var script string
+	switch shell {
+	case "bash":
+		script = bashCompletion
+	case "zsh":
+		script = zshCompletion
+	case "fish":
+		script = fishCompletion
+	case "powershell", "pwsh":
+		script = powershellCompletion
+	default:
+		return fmt.Errorf("unsupported shell '%s' (expected %s)", shell, strings.Join(completionShells, ", "))
+	}
+
+	fmt.Print(script)
+	return nil
+}
+
+// CompletionCommandMain is the entry point for the completion subcommand
+func CompletionCommandMain(args []string) {
+	if len(args) < 1 {
+		fmt.Fprintf(os.Stderr, "\n")
+		internal.Output.Eprintf(internal.StatusError, "  Error: no shell specified\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "  USAGE:\n")
+		fmt.Fprintf(os.Stderr, "    linea completion <%s>\n", strings.Join(completionShells, "|"))
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "  EXAMPLES:\n")
+		fmt.Fprintf(os.Stderr, "    source <(linea completion bash)\n")
+		fmt.Fprintf(os.Stderr, "    linea completion fish > ~/.config/fish/completions/linea.fish\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		os.Exit(1)
+	}
+
+	if err := CompletionCommand(args[0]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"linea/internal"
+)
+
+// ConfigGetCommand prints the value of a global config setting
+func ConfigGetCommand(key string) error {
+	config, err := internal.LoadUserConfig()
+	if err != nil {
+		return err
+	}
+
+	value, err := config.Get(key)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(value)
+	return nil
+}
+
+// ConfigSetCommand stores a global config setting
+// An empty value resets the setting to its default
+func ConfigSetCommand(key, value string) error {
+	err := internal.UpdateUserConfig(func(config *internal.UserConfig) error {
+		return config.Set(key, value)
+	})
+	if err != nil {
+		return err
+	}
+
+	if value == "" {
+		internal.Output.Printf(internal.StatusSuccess, "Reset %s in %s\n", key, internal.ConfigFilePath())
+	} else {
+		internal.Output.Printf(internal.StatusSuccess, "Set %s = %s in %s\n", key, value, internal.ConfigFilePath())
+	}
+	return nil
+}
+
+// ConfigListCommand prints every global config setting with its current value
+func ConfigListCommand() error {
+	config, err := internal.LoadUserConfig()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Config file: %s\n\n", internal.ConfigFilePath())
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tDESCRIPTION")
+	for _, key := range internal.ConfigKeys {
+		value, _ := config.Get(key.Name)
+		if value == "" {
+			value = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", key.Name, value, key.Description)
+	}
+	return w.Flush()
+}
+
+// ConfigCommandMain is the entry point for the config subcommand
+func ConfigCommandMain(args []string) {
+	if len(args) < 1 {
+		printConfigUsage("no config subcommand specified")
+		os.Exit(1)
+	}
+
+	var err error
+	switch args[0] {
+	case "get":
+		if len(args) < 2 {
+			printConfigUsage("no config key specified")
+			os.Exit(1)
+		}
+		err = ConfigGetCommand(args[1])
+	case "set":
+		if len(args) < 3 {
+			printConfigUsage("config set needs a key and a value")
+			os.Exit(1)
+		}
+		err = ConfigSetCommand(args[1], args[2])
+	case "list":
+		err = ConfigListCommand()
+	default:
+		printConfigUsage(fmt.Sprintf("unknown config subcommand '%s'", args[0]))
+		os.Exit(1)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// printConfigUsage prints the usage of the config subcommand with an error message
+func printConfigUsage(message string) {
+	fmt.Fprintf(os.Stderr, "\n")
+	internal.Output.Eprintf(internal.StatusError, "  Error: %s\n", message)
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "  USAGE:\n")
+	fmt.Fprintf(os.Stderr, "    linea config get <key>            Print a setting\n")
+	fmt.Fprintf(os.Stderr, "    linea config set <key> <value>    Change a setting (\"\" resets it)\n")
+	fmt.Fprintf(os.Stderr, "    linea config list                 List all settings\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "  KEYS:\n")
+	for _, key := range internal.ConfigKeys {
+		fmt.Fprintf(os.Stderr, "    %-22s %s\n", key.Name, key.Description)
+	}
+	fmt.Fprintf(os.Stderr, "\n")
+}
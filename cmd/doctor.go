@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+
+	"linea/internal"
+)
+
+// DoctorCommand checks the installation and reports how the linea executable is resolved
+// Returns an error if linea cannot be resolved
+func DoctorCommand() error {
+	fmt.Printf("Linea doctor\n")
+	fmt.Printf("\n")
+	fmt.Printf("Platform: %s/%s\n", runtime.GOOS, runtime.GOARCH)
+	fmt.Printf("\n")
+
+	fmt.Printf("linea executable (checked in order):\n")
+	selected, resolveErr := internal.FindLineaExecutable()
+	for i, candidate := range internal.LineaExecutableCandidates() {
+		switch {
+		case candidate.Found && candidate.Path == selected:
+			fmt.Printf("  %d. %s %-9s %s (selected)\n", i+1, internal.Output.Symbol(os.Stdout, internal.StatusSuccess), candidate.Source, candidate.Path)
+		case candidate.Found:
+			fmt.Printf("  %d. %s %-9s %s\n", i+1, internal.Output.Symbol(os.Stdout, internal.StatusSuccess), candidate.Source, candidate.Path)
+		case candidate.Path != "":
+			fmt.Printf("  %d. %s %-9s %s (%s)\n", i+1, internal.Output.Symbol(os.Stdout, internal.StatusError), candidate.Source, candidate.Path, candidate.Detail)
+		default:
+			fmt.Printf("  %d. %s %-9s %s\n", i+1, internal.Output.Symbol(os.Stdout, internal.StatusNone), candidate.Source, candidate.Detail)
+		}
+	}
+	fmt.Printf("\n")
+
+	paths := internal.UserPaths()
+	fmt.Printf("Directories:\n")
+	fmt.Printf("  config:           %s\n", paths.Config)
+	fmt.Printf("  data:             %s\n", paths.Data)
+	fmt.Printf("  state:            %s\n", paths.State)
+	fmt.Printf("  cache:            %s\n", paths.Cache)
+	fmt.Printf("  global workflows: %s\n", paths.GlobalWorkflows)
+	fmt.Printf("\n")
+
+	var configErr error
+	if _, err := internal.LoadUserConfig(); err != nil {
+		configErr = err
+		fmt.Printf("Config file: %s %v\n", internal.Output.Symbol(os.Stdout, internal.StatusError), err)
+	} else if _, err := os.Stat(internal.ConfigFilePath()); err == nil {
+		fmt.Printf("Config file: %s %s\n", internal.Output.Symbol(os.Stdout, internal.StatusSuccess), internal.ConfigFilePath())
+	} else {
+		fmt.Printf("Config file: %s %s (not created yet)\n", internal.Output.Symbol(os.Stdout, internal.StatusNone), internal.ConfigFilePath())
+	}
+	fmt.Printf("\n")
+
+	cwd, _ := os.Getwd()
+	if dir := internal.FindWorkflowsDir(cwd); dir != "" {
+		fmt.Printf("Workflows directory: %s %s\n", internal.Output.Symbol(os.Stdout, internal.StatusSuccess), dir)
+	} else {
+		fmt.Printf("Workflows directory: %s no .linea/workflows found from %s\n", internal.Output.Symbol(os.Stdout, internal.StatusNone), cwd)
+	}
+	fmt.Printf("\n")
+
+	if resolveErr != nil {
+		return resolveErr
+	}
+	if configErr != nil {
+		return configErr
+	}
+	internal.Output.Printf(internal.StatusSuccess, "Everything looks good\n")
+	return nil
+}
+
+// DoctorCommandMain is the entry point for the doctor subcommand
+func DoctorCommandMain(args []string) {
+	if err := DoctorCommand(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"linea/internal"
+)
+
+// ExportCommand writes a workflow exported by linea export to output ("-" for stdout)
+// and prints what the script does differently from linea run
+func ExportCommand(result *internal.ExportResult, output string, force bool) error {
+	if output == "-" {
+		_, err := os.Stdout.Write(result.Script)
+		for _, note := range result.Notes {
+			internal.Output.Eprintf(internal.StatusWarning, "%s\n", note)
+		}
+		return err
+	}
+
+	if _, err := os.Stat(output); err == nil && !force {
+		return fmt.Errorf("file %s already exists (use --force to overwrite it)", output)
+	}
+	if dir := filepath.Dir(output); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+	}
+	mode := os.FileMode(0644)
+	if result.Executable {
+		mode = 0755
+	}
+	if err := os.WriteFile(output, result.Script, mode); err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+
+	internal.Output.Printf(internal.StatusSuccess, "Created %s\n", output)
+	if len(result.Notes) > 0 {
+		fmt.Printf("\n")
+		internal.Output.Printf(internal.StatusWarning, "%d difference(s) from linea run, also listed at the top of the file:\n", len(result.Notes))
+		for _, note := range result.Notes {
+			fmt.Printf("  - %s\n", note)
+		}
+	}
+	return nil
+}
+
+// printExportUsage prints the usage of the export subcommand with an error message
+func printExportUsage(message string) {
+	fmt.Fprintf(os.Stderr, "\n")
+	internal.Output.Eprintf(internal.StatusError, "  Error: %s\n", message)
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "  USAGE:\n")
+	fmt.Fprintf(os.Stderr, "    linea export <yaml-file|workflow-name> --format bash|powershell|gha|gitlab [options]\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "  OPTIONS:\n")
+	fmt.Fprintf(os.Stderr, "    --format <format>          bash or powershell for a script, gha or gitlab for a CI pipeline\n")
+	fmt.Fprintf(os.Stderr, "    --parameterized            Make the variables parameters instead of baking them in\n")
+	fmt.Fprintf(os.Stderr, "    -s, --set <var>=<value>    Bake in a variable value (can be used multiple times)\n")
+	fmt.Fprintf(os.Stderr, "    --matrix <var>=<v1>,<v2>   Run the CI job once per value of a variable (can be used multiple times)\n")
+	fmt.Fprintf(os.Stderr, "    -o, --output <file>        Write to a file instead of stdout\n")
+	fmt.Fprintf(os.Stderr, "    -f, --force                Overwrite an existing file\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "  EXAMPLES:\n")
+	fmt.Fprintf(os.Stderr, "    linea export deploy --format bash -o deploy.sh\n")
+	fmt.Fprintf(os.Stderr, "    linea export deploy.yml --format powershell --parameterized -o deploy.ps1\n")
+	fmt.Fprintf(os.Stderr, "    linea export build.yml --format bash -s env=prod > build.sh\n")
+	fmt.Fprintf(os.Stderr, "    linea export test --format gha --matrix go=1.22,1.23 -o .github/workflows/test.yml\n")
+	fmt.Fprintf(os.Stderr, "\n")
+}
+
+// addExportMatrix adds a --matrix name=value1,value2 flag to opts
+func addExportMatrix(opts *internal.ExportOptions, flag string) {
+	parts := strings.SplitN(flag, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		printExportUsage("--matrix needs a name=value1,value2 list")
+		os.Exit(1)
+	}
+	if opts.Matrix == nil {
+		opts.Matrix = map[string][]string{}
+	}
+	for _, value := range strings.Split(parts[1], ",") {
+		opts.Matrix[parts[0]] = append(opts.Matrix[parts[0]], strings.TrimSpace(value))
+	}
+}
+
+// ExportCommandMain is the entry point for the export subcommand
+func ExportCommandMain(args []string) {
+	set, remainingArgs := ParseArgs(args)
+	opts := internal.ExportOptions{Set: set}
+	workflow, output := "", "-"
+	force := false
+	for i := 0; i < len(remainingArgs); i++ {
+		arg := remainingArgs[i]
+		switch {
+		case arg == "--format" || arg == "-o" || arg == "--output" || arg == "--matrix":
+			if i+1 >= len(remainingArgs) {
+				printExportUsage(arg + " needs a value")
+				os.Exit(1)
+			}
+			i++
+			switch arg {
+			case "--format":
+				opts.Format = remainingArgs[i]
+			case "--matrix":
+				addExportMatrix(&opts, remainingArgs[i])
+			default:
+				output = remainingArgs[i]
+			}
+		case strings.HasPrefix(arg, "--matrix="):
+			addExportMatrix(&opts, strings.TrimPrefix(arg, "--matrix="))
+		case strings.HasPrefix(arg, "--format="):
+			opts.Format = strings.TrimPrefix(arg, "--format=")
+		case strings.HasPrefix(arg, "--output="):
+			output = strings.TrimPrefix(arg, "--output=")
+		case arg == "--parameterized":
+			opts.Parameterized = true
+		case arg == "-f" || arg == "--force":
+			force = true
+		case strings.HasPrefix(arg, "-"):
+			printExportUsage(fmt.Sprintf("unknown option '%s'", arg))
+			os.Exit(1)
+		case workflow == "":
+			workflow = arg
+		default:
+			printExportUsage(fmt.Sprintf("unexpected argument '%s'", arg))
+			os.Exit(1)
+		}
+	}
+	if workflow == "" {
+		printExportUsage("no YAML file specified")
+		os.Exit(1)
+	}
+	if opts.Format == "" {
+		printExportUsage("no format specified (--format bash, powershell, gha, or gitlab)")
+		os.Exit(1)
+	}
+
+	path, err := internal.ResolveWorkflowPath(workflow)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	result, err := internal.ExportWorkflow(path, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := ExportCommand(result, output, force); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
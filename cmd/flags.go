@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"linea/internal"
+)
+
+// ParseGlobalFlags applies the flags accepted by every subcommand and returns the
+// remaining arguments. The flags may appear anywhere before a -- separator:
+//
+//	--theme <name>   Style of status messages, see internal.Themes
+//	--shell <name>   Windows shell for commands not on PATH, see internal.StepShell
+//	--quiet          Only errors and warnings among linea's own messages
+//	--no-color       No ANSI colors, like NO_COLOR
+//	--strict         Refuse unknown workflow keys, see internal.StrictParse
+//
+// --set-json <var>=<json> is rewritten to -s with the value checked and made compact, so
+// that every subcommand taking -s accepts lists and maps, see internal.StructuredValue
+func ParseGlobalFlags(args []string) ([]string, error) {
+	remaining := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			return append(remaining, args[i:]...), nil
+		case arg == "-s" || arg == "--set":
+			// The value of -s may itself look like a flag
+			remaining = append(remaining, args[i:minIndex(i+2, len(args))]...)
+			i++
+		case arg == "--set-json":
+			if i+1 >= len(args) || !strings.Contains(args[i+1], "=") {
+				return nil, fmt.Errorf("--set-json needs a value such as targets='[\"web1\",\"web2\"]'")
+			}
+			i++
+			parts := strings.SplitN(args[i], "=", 2)
+			value, err := internal.StructuredValue(parts[1])
+			if err != nil {
+				return nil, fmt.Errorf("--set-json %s: %w", parts[0], err)
+			}
+			remaining = append(remaining, "-s", parts[0]+"="+value)
+		case arg == "--quiet":
+			internal.Output.Quiet = true
+		case arg == "--no-color":
+			internal.SetColorMode(internal.ColorNever)
+		case arg == "--strict":
+			internal.StrictParse = true
+		case arg == "--shell" || strings.HasPrefix(arg, "--shell="):
+			name := strings.TrimPrefix(arg, "--shell=")
+			if arg == "--shell" {
+				if i+1 >= len(args) {
+					return nil, fmt.Errorf("--shell needs a value (%s)", strings.Join(internal.ShellNames, ", "))
+				}
+				i++
+				name = args[i]
+			}
+			if err := internal.SetShell(name); err != nil {
+				return nil, err
+			}
+		case arg == "--theme" || strings.HasPrefix(arg, "--theme="):
+			name := strings.TrimPrefix(arg, "--theme=")
+			if arg == "--theme" {
+				if i+1 >= len(args) {
+					return nil, fmt.Errorf("--theme needs a value (%s)", strings.Join(internal.ThemeNames(), ", "))
+				}
+				i++
+				name = args[i]
+			}
+			if err := internal.SetTheme(name); err != nil {
+				return nil, err
+			}
+		default:
+			remaining = append(remaining, arg)
+		}
+	}
+	return remaining, nil
+}
+
+// parseOutputFlag removes --output <format> (or --output=<format>) from args, used by
+// run, test, and help; the format is empty when the flag is absent
+func parseOutputFlag(args []string) (string, []string, error) {
+	format := ""
+	remaining := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg != "--output" && !strings.HasPrefix(arg, "--output=") {
+			remaining = append(remaining, arg)
+			continue
+		}
+		value := strings.TrimPrefix(arg, "--output=")
+		if arg == "--output" {
+			if i+1 >= len(args) {
+				return "", nil, fmt.Errorf("--output needs a value (text, json, or yaml)")
+			}
+			i++
+			value = args[i]
+		}
+		parsed, err := internal.ParseOutputFormat(value)
+		if err != nil {
+			return "", nil, err
+		}
+		format = parsed
+	}
+	return format, remaining, nil
+}
+
+// minIndex returns the smaller of two indexes
+func minIndex(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"linea/internal"
+)
+
+// FmtCommand rewrites workflow files in canonical form, upgrading them to the latest
+// format version if upgrade is set
+// With check set, files are left untouched and the number of unformatted files is returned
+func FmtCommand(targets []string, check, upgrade bool) (int, error) {
+	files, err := collectWorkflowFiles(targets)
+	if err != nil {
+		return 0, err
+	}
+	if len(files) == 0 {
+		return 0, fmt.Errorf("no workflow files (.yml/.yaml) found")
+	}
+
+	changed := 0
+	for _, file := range files {
+		if !internal.IsYAMLFile(file) {
+			internal.Output.Printf(internal.StatusSkip, "%s skipped: only YAML files are formatted\n", file)
+			continue
+		}
+		formatted, differs, err := internal.FormatWorkflowFile(file, upgrade)
+		if err != nil {
+			return changed, err
+		}
+		if !differs {
+			continue
+		}
+		changed++
+
+		if check {
+			internal.Output.Printf(internal.StatusError, "%s is not formatted\n", file)
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return changed, fmt.Errorf("cannot access %s: %w", file, err)
+		}
+		if err := os.WriteFile(file, formatted, info.Mode().Perm()); err != nil {
+			return changed, fmt.Errorf("failed to write %s: %w", file, err)
+		}
+		internal.Output.Printf(internal.StatusEdit, "Formatted %s\n", file)
+	}
+
+	if check {
+		fmt.Printf("\n%d file(s) checked, %d not formatted\n", len(files), changed)
+	} else {
+		fmt.Printf("\n%d file(s) checked, %d formatted\n", len(files), changed)
+	}
+	return changed, nil
+}
+
+// FmtCommandMain is the entry point for the fmt subcommand
+func FmtCommandMain(args []string) {
+	check := false
+	upgrade := false
+	var targets []string
+	for _, arg := range args {
+		switch {
+		case arg == "--check":
+			check = true
+		case arg == "--upgrade":
+			upgrade = true
+		case !strings.HasPrefix(arg, "-"):
+			targets = append(targets, arg)
+		}
+	}
+
+	// Default to the project's workflows
+	if len(targets) == 0 {
+		cwd, _ := os.Getwd()
+		if dir := internal.FindWorkflowsDir(cwd); dir != "" {
+			targets = append(targets, dir)
+		}
+	}
+
+	if len(targets) == 0 {
+		fmt.Fprintf(os.Stderr, "\n")
+		internal.Output.Eprintf(internal.StatusError, "  Error: no file or directory specified (and no .linea/workflows directory found)\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "  USAGE:\n")
+		fmt.Fprintf(os.Stderr, "    linea fmt [--check] [--upgrade] [file|dir]...\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "  OPTIONS:\n")
+		fmt.Fprintf(os.Stderr, "    --check      Only report unformatted files and exit with status 1 if there are any\n")
+		fmt.Fprintf(os.Stderr, "    --upgrade    Also rewrite files to the latest workflow format version (%d)\n", internal.CurrentWorkflowVersion)
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "  EXAMPLES:\n")
+		fmt.Fprintf(os.Stderr, "    linea fmt\n")
+		fmt.Fprintf(os.Stderr, "    linea fmt --check .linea/workflows\n")
+		fmt.Fprintf(os.Stderr, "    linea fmt --upgrade\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		os.Exit(1)
+	}
+
+	changed, err := FmtCommand(targets, check, upgrade)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if check && changed > 0 {
+		os.Exit(1)
+	}
+}
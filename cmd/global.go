@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"linea/internal"
+)
+
+// GlobalInstallCommand copies a workflow file into the global workflows directory
+func GlobalInstallCommand(file, name string, force bool) error {
+	dest, err := internal.InstallGlobalWorkflow(file, name, force)
+	if err != nil {
+		return err
+	}
+
+	internal.Output.Printf(internal.StatusSuccess, "Installed global workflow '%s' to %s\n", internal.WorkflowName(dest), dest)
+	fmt.Printf("   Run it from any directory with: linea run %s\n", internal.WorkflowName(dest))
+	return nil
+}
+
+// GlobalCommandMain is the entry point for the global subcommand
+func GlobalCommandMain(args []string) {
+	if len(args) < 1 {
+		printGlobalUsage("no global subcommand specified")
+		os.Exit(1)
+	}
+
+	var err error
+	switch args[0] {
+	case "install":
+		file := ""
+		name := ""
+		force := false
+		for i := 1; i < len(args); i++ {
+			switch args[i] {
+			case "-f", "--force":
+				force = true
+			case "--name":
+				if i+1 >= len(args) {
+					printGlobalUsage("--name needs a workflow name")
+					os.Exit(1)
+				}
+				name = args[i+1]
+				i++
+			default:
+				file = args[i]
+			}
+		}
+		if file == "" {
+			printGlobalUsage("no workflow file specified")
+			os.Exit(1)
+		}
+		err = GlobalInstallCommand(file, name, force)
+	case "list":
+		err = ListCommand(true)
+	default:
+		printGlobalUsage(fmt.Sprintf("unknown global subcommand '%s'", args[0]))
+		os.Exit(1)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// printGlobalUsage prints the usage of the global subcommand with an error message
+func printGlobalUsage(message string) {
+	fmt.Fprintf(os.Stderr, "\n")
+	internal.Output.Eprintf(internal.StatusError, "  Error: %s\n", message)
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "  USAGE:\n")
+	fmt.Fprintf(os.Stderr, "    linea global install [options] <yaml-file>    Copy a workflow into the global workflows directory\n")
+	fmt.Fprintf(os.Stderr, "    linea global list                            List global workflows\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "  OPTIONS:\n")
+	fmt.Fprintf(os.Stderr, "    --name <name>    Install under a different workflow name\n")
+	fmt.Fprintf(os.Stderr, "    -f, --force      Replace an existing global workflow\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "  NOTE:\n")
+	fmt.Fprintf(os.Stderr, "    Global workflows are stored in %s\n", internal.GlobalWorkflowsDir())
+	fmt.Fprintf(os.Stderr, "\n")
+}
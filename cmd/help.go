@@ -3,34 +3,65 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
+	"text/tabwriter"
 
 	"linea/internal"
 )
 
 // HelpCommand displays help information for a YAML command file (supports single or multiple commands)
-func HelpCommand(yamlFile string) error {
+// An output of json or yaml prints the description as a machine-readable report instead
+func HelpCommand(yamlFile string, output string) error {
+	yamlFile, err := internal.ResolveWorkflowPath(yamlFile)
+	if err != nil {
+		return err
+	}
+
 	configs, err := internal.ParseMultiYAML(yamlFile)
 	if err != nil {
 		return fmt.Errorf("failed to parse YAML file: %w", err)
 	}
 
+	if output == internal.OutputJSON || output == internal.OutputYAML {
+		return internal.WriteStructured(os.Stdout, output, internal.DescribeWorkflow(yamlFile, configs))
+	}
+
+	// The full commands show the inputs as <name>, or their defaults
+	var inputVars map[string]string
+	if metadata := configs[0].Metadata; metadata != nil && len(metadata.Inputs) > 0 {
+		printInputs(internal.WorkflowName(yamlFile), metadata.Inputs)
+		inputVars = make(map[string]string, len(metadata.Inputs))
+		for _, in := range metadata.Inputs {
+			inputVars[in.Name] = "<" + in.Name + ">"
+			if in.Optional() {
+				inputVars[in.Name] = *in.Default
+			}
+		}
+	}
+
 	if len(configs) == 1 {
 		config := configs[0]
-		fmt.Printf("Command: %s\n", config.Command)
+		if config.Type != "" {
+			fmt.Printf("Type: %s\n", config.Type)
+		} else {
+			fmt.Printf("Command: %s\n", config.Command)
+		}
 		if config.Subcommand != "" {
 			fmt.Printf("Subcommand: %s\n", config.Subcommand)
 		}
 		if len(config.Args) > 0 {
 			fmt.Printf("Arguments: %v\n", config.Args)
 		}
+		printForeach(config)
 		if len(config.Variables) > 0 {
 			fmt.Printf("Variables:\n")
 			for key, value := range config.Variables {
 				fmt.Printf("  %s: %s\n", key, value)
 			}
 		}
+		printSecrets(config)
 
-		cmd, err := internal.BuildCommand(config, nil)
+		cmd, err := internal.BuildCommand(foreachPlaceholders(config, inputVars), inputVars)
 		if err != nil {
 			return err
 		}
@@ -44,21 +75,27 @@ func HelpCommand(yamlFile string) error {
 		fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
 		fmt.Printf("Command %d/%d:\n", i+1, len(configs))
 		fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
-		fmt.Printf("Command: %s\n", config.Command)
+		if config.Type != "" {
+			fmt.Printf("Type: %s\n", config.Type)
+		} else {
+			fmt.Printf("Command: %s\n", config.Command)
+		}
 		if config.Subcommand != "" {
 			fmt.Printf("Subcommand: %s\n", config.Subcommand)
 		}
 		if len(config.Args) > 0 {
 			fmt.Printf("Arguments: %v\n", config.Args)
 		}
+		printForeach(config)
 		if len(config.Variables) > 0 {
 			fmt.Printf("Variables:\n")
 			for key, value := range config.Variables {
 				fmt.Printf("  %s: %s\n", key, value)
 			}
 		}
+		printSecrets(config)
 
-		cmd, err := internal.BuildCommand(config, nil)
+		cmd, err := internal.BuildCommand(foreachPlaceholders(config, inputVars), inputVars)
 		if err != nil {
 			return fmt.Errorf("error building command %d: %w", i+1, err)
 		}
@@ -71,23 +108,95 @@ func HelpCommand(yamlFile string) error {
 	return nil
 }
 
+// printInputs shows how to pass the inputs of a workflow, with their descriptions and defaults
+func printInputs(workflow string, inputs []internal.WorkflowInput) {
+	fmt.Printf("Usage: linea run %s %s\n", workflow, internal.InputUsage(inputs))
+	fmt.Printf("Inputs:\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, in := range inputs {
+		description := in.Description
+		if in.Optional() {
+			description = strings.TrimSpace(fmt.Sprintf("%s (default: %s)", description, *in.Default))
+		}
+		fmt.Fprintf(w, "  %s\t%s\n", in.Name, description)
+	}
+	w.Flush()
+	fmt.Println()
+}
+
+// printForeach shows what a foreach: step runs for
+func printForeach(config *internal.CommandConfig) {
+	if config.Foreach == nil {
+		return
+	}
+	if config.Foreach.Items != nil {
+		fmt.Printf("Foreach: %s\n", strings.Join(config.Foreach.Items, ", "))
+	} else {
+		fmt.Printf("Foreach: %s\n", config.Foreach.From)
+	}
+}
+
+// foreachPlaceholders returns a foreach: step whose full command shows its first item, or
+// <item> and <index> if the items are only known at run time
+func foreachPlaceholders(config *internal.CommandConfig, inputVars map[string]string) *internal.CommandConfig {
+	if config.Foreach == nil {
+		return config
+	}
+	step := *config
+	step.Variables = map[string]string{"item": "<item>", "index": "<index>"}
+	if items, err := internal.ForeachItems(config, inputVars); err == nil && len(items) > 0 {
+		step.Variables["item"], step.Variables["index"] = items[0], "0"
+	}
+	for k, v := range config.Variables {
+		step.Variables[k] = v
+	}
+	return &step
+}
+
+// printSecrets lists the declared secrets and their backends without revealing values
+func printSecrets(config *internal.CommandConfig) {
+	if len(config.Secrets) == 0 {
+		return
+	}
+	fmt.Printf("Secrets:\n")
+	for name, ref := range config.Secrets {
+		from := ref.From
+		if from == "" {
+			from = internal.SecretFromFile
+		}
+		fmt.Printf("  %s: %s (from %s)\n", name, internal.SecretMask, from)
+	}
+}
+
 // HelpCommandMain is the entry point for the help subcommand
 func HelpCommandMain(args []string) {
 	if len(args) < 1 {
 		fmt.Fprintf(os.Stderr, "\n")
-		fmt.Fprintf(os.Stderr, "  ❌ Error: no YAML file specified\n")
+		internal.Output.Eprintf(internal.StatusError, "  Error: no YAML file specified\n")
 		fmt.Fprintf(os.Stderr, "\n")
 		fmt.Fprintf(os.Stderr, "  USAGE:\n")
-		fmt.Fprintf(os.Stderr, "    linea help <yaml-file>\n")
+		fmt.Fprintf(os.Stderr, "    linea help [--output <text|json|yaml>] <yaml-file|workflow-name>\n")
 		fmt.Fprintf(os.Stderr, "\n")
 		fmt.Fprintf(os.Stderr, "  EXAMPLES:\n")
 		fmt.Fprintf(os.Stderr, "    linea help config.yml\n")
+		fmt.Fprintf(os.Stderr, "    linea help config.yml --output json\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		os.Exit(1)
+	}
+
+	output, remainingArgs, err := parseOutputFlag(args)
+	if err != nil || len(remainingArgs) == 0 {
+		if err == nil {
+			err = fmt.Errorf("no YAML file specified")
+		}
+		fmt.Fprintf(os.Stderr, "\n")
+		internal.Output.Eprintf(internal.StatusError, "  Error: %v\n", err)
 		fmt.Fprintf(os.Stderr, "\n")
 		os.Exit(1)
 	}
 
-	yamlFile := args[0]
-	if err := HelpCommand(yamlFile); err != nil {
+	yamlFile := remainingArgs[0]
+	if err := HelpCommand(yamlFile, output); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"linea/internal"
+)
+
+// HistoryCommand lists recorded runs, newest first
+func HistoryCommand(workflow string, failedOnly bool, limit int, asJSON bool) error {
+	records, err := internal.LoadRunHistory()
+	if err != nil {
+		return err
+	}
+	recent := internal.RecentRuns(records, workflow, failedOnly, limit)
+
+	if asJSON {
+		if recent == nil {
+			recent = []internal.RunRecord{}
+		}
+		data, err := json.MarshalIndent(recent, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode history: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if len(recent) == 0 {
+		fmt.Printf("No runs recorded in %s\n", internal.HistoryFilePath())
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RUN ID\tWORKFLOW\tSTATUS\tDURATION\tSTARTED")
+	for _, record := range recent {
+		status := "success"
+		if !record.Success {
+			status = fmt.Sprintf("failed (%d)", record.ExitCode)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			record.ID,
+			record.Workflow,
+			status,
+			formatDurationMs(record.DurationMs),
+			record.Start.Local().Format("2006-01-02 15:04:05"),
+		)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if !internal.Output.Quiet {
+		fmt.Println()
+		fmt.Println("View a run's output with: linea logs <run-id>")
+	}
+	return nil
+}
+
+// HistoryCommandMain is the entry point for the history subcommand
+func HistoryCommandMain(args []string) {
+	workflow := ""
+	failedOnly := false
+	limit := 20
+	asJSON := false
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--json":
+			asJSON = true
+		case "--failed":
+			failedOnly = true
+		case "--all":
+			limit = 0
+		case "-n", "--limit":
+			if i+1 >= len(args) {
+				printHistoryUsage("--limit needs a number of runs")
+				os.Exit(1)
+			}
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n < 1 {
+				printHistoryUsage(fmt.Sprintf("invalid number of runs '%s'", args[i+1]))
+				os.Exit(1)
+			}
+			limit = n
+			i++
+		case "--workflow":
+			if i+1 >= len(args) {
+				printHistoryUsage("--workflow needs a workflow name")
+				os.Exit(1)
+			}
+			workflow = args[i+1]
+			i++
+		default:
+			printHistoryUsage(fmt.Sprintf("unknown option '%s'", args[i]))
+			os.Exit(1)
+		}
+	}
+
+	if err := HistoryCommand(workflow, failedOnly, limit, asJSON); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// printHistoryUsage prints the usage of the history subcommand with an error message
+func printHistoryUsage(message string) {
+	fmt.Fprintf(os.Stderr, "\n")
+	internal.Output.Eprintf(internal.StatusError, "  Error: %s\n", message)
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "  USAGE:\n")
+	fmt.Fprintf(os.Stderr, "    linea history [options]\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "  OPTIONS:\n")
+	fmt.Fprintf(os.Stderr, "    -n, --limit <n>        Show the last n runs (default 20)\n")
+	fmt.Fprintf(os.Stderr, "    --all                  Show every recorded run\n")
+	fmt.Fprintf(os.Stderr, "    --workflow <name>      Only show runs of one workflow\n")
+	fmt.Fprintf(os.Stderr, "    --failed               Only show failed runs\n")
+	fmt.Fprintf(os.Stderr, "    --json                 Print the runs as JSON\n")
+	fmt.Fprintf(os.Stderr, "\n")
+}
@@ -0,0 +1,231 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"linea/internal"
+)
+
+// ImportCommand writes a workflow converted by linea import to output ("-" for stdout)
+// and prints the notes of the conversion
+func ImportCommand(result *internal.ImportResult, output string, force bool) error {
+	if output == "-" {
+		_, err := os.Stdout.Write(result.Content)
+		for _, note := range result.Notes {
+			internal.Output.Eprintf(internal.StatusWarning, "%s\n", note)
+		}
+		return err
+	}
+
+	if err := writeImport(result, output, force); err != nil {
+		return err
+	}
+	if len(result.Notes) > 0 {
+		fmt.Printf("\n")
+		internal.Output.Printf(internal.StatusWarning, "%d thing(s) to review, also listed at the top of the file:\n", len(result.Notes))
+		for _, note := range result.Notes {
+			fmt.Printf("  - %s\n", note)
+		}
+	}
+	if internal.Output.Quiet {
+		return nil
+	}
+	fmt.Printf("\n")
+	fmt.Printf("You can now:\n")
+	fmt.Printf("  • Test it: linea test %s\n", output)
+	fmt.Printf("  • Run it: linea run %s\n", output)
+	fmt.Printf("\n")
+	return nil
+}
+
+// ImportMakeCommand writes the workflows converted from the targets of a Makefile into
+// dir and prints the notes of the conversion, once each
+func ImportMakeCommand(results []*internal.ImportResult, dir string, force bool) error {
+	var notes []string
+	seen := make(map[string]bool)
+	for _, result := range results {
+		if err := writeImport(result, filepath.Join(dir, result.Name+".yml"), force); err != nil {
+			return err
+		}
+		for _, note := range result.Notes {
+			if !seen[note] {
+				seen[note] = true
+				notes = append(notes, note)
+			}
+		}
+	}
+	if len(notes) > 0 {
+		fmt.Printf("\n")
+		internal.Output.Printf(internal.StatusWarning, "%d thing(s) to review, also listed at the top of the files:\n", len(notes))
+		for _, note := range notes {
+			fmt.Printf("  - %s\n", note)
+		}
+	}
+	if internal.Output.Quiet {
+		return nil
+	}
+	fmt.Printf("\n")
+	fmt.Printf("You can now:\n")
+	fmt.Printf("  • List them: linea list\n")
+	fmt.Printf("  • Run a target and the targets it depends on: linea run %s\n", results[0].Name)
+	fmt.Printf("\n")
+	return nil
+}
+
+// writeImport writes an imported workflow to the file output
+func writeImport(result *internal.ImportResult, output string, force bool) error {
+	if _, err := os.Stat(output); err == nil && !force {
+		return fmt.Errorf("file %s already exists (use --force to overwrite it)", output)
+	}
+	if dir := filepath.Dir(output); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+	}
+	if err := os.WriteFile(output, result.Content, 0644); err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	internal.Output.Printf(internal.StatusSuccess, "Created workflow file: %s (%d step(s))\n", output, result.Steps)
+	return nil
+}
+
+// printImportUsage prints the usage of the import subcommand with an error message
+func printImportUsage(message string) {
+	fmt.Fprintf(os.Stderr, "\n")
+	internal.Output.Eprintf(internal.StatusError, "  Error: %s\n", message)
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "  USAGE:\n")
+	fmt.Fprintf(os.Stderr, "    linea import gha <workflow.yml> [--job <id>] [-o <file>] [--force]\n")
+	fmt.Fprintf(os.Stderr, "    linea import make <Makefile> [--target <name>]... [-o <dir>] [--force]\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "  OPTIONS:\n")
+	fmt.Fprintf(os.Stderr, "    --job <id>             gha: import only this job (default: every job, in needs: order)\n")
+	fmt.Fprintf(os.Stderr, "    --target <name>        make: import this target and the targets it depends on\n")
+	fmt.Fprintf(os.Stderr, "                           (can be used multiple times; default: every target)\n")
+	fmt.Fprintf(os.Stderr, "    -o, --output <path>    gha: where to write the workflow, - for stdout\n")
+	fmt.Fprintf(os.Stderr, "                           (default: <name>.yml in .linea/workflows)\n")
+	fmt.Fprintf(os.Stderr, "                           make: the directory of the workflows, one per target\n")
+	fmt.Fprintf(os.Stderr, "                           (default: .linea/workflows)\n")
+	fmt.Fprintf(os.Stderr, "    -f, --force            Overwrite existing files\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "  EXAMPLES:\n")
+	fmt.Fprintf(os.Stderr, "    linea import gha .github/workflows/ci.yml\n")
+	fmt.Fprintf(os.Stderr, "    linea import gha .github/workflows/ci.yml --job test -o test.yml\n")
+	fmt.Fprintf(os.Stderr, "    linea import make ./Makefile\n")
+	fmt.Fprintf(os.Stderr, "    linea import make ./Makefile --target release -o workflows\n")
+	fmt.Fprintf(os.Stderr, "\n")
+}
+
+// ImportCommandMain is the entry point for the import subcommand
+func ImportCommandMain(args []string) {
+	if len(args) == 0 {
+		printImportUsage("no format specified")
+		os.Exit(1)
+	}
+	format := args[0]
+	if format != "gha" && format != "make" {
+		printImportUsage(fmt.Sprintf("unknown format '%s'", format))
+		os.Exit(1)
+	}
+
+	source, output, job := "", "", ""
+	var targets []string
+	force := false
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "-o" || arg == "--output" || arg == "--job" || arg == "--target":
+			if i+1 >= len(args) {
+				printImportUsage(arg + " needs a value")
+				os.Exit(1)
+			}
+			i++
+			switch arg {
+			case "--job":
+				job = args[i]
+			case "--target":
+				targets = append(targets, args[i])
+			default:
+				output = args[i]
+			}
+		case strings.HasPrefix(arg, "--output="):
+			output = strings.TrimPrefix(arg, "--output=")
+		case strings.HasPrefix(arg, "--job="):
+			job = strings.TrimPrefix(arg, "--job=")
+		case strings.HasPrefix(arg, "--target="):
+			targets = append(targets, strings.TrimPrefix(arg, "--target="))
+		case arg == "-f" || arg == "--force":
+			force = true
+		case strings.HasPrefix(arg, "-") && arg != "-":
+			printImportUsage(fmt.Sprintf("unknown option '%s'", arg))
+			os.Exit(1)
+		case source == "":
+			source = arg
+		default:
+			printImportUsage(fmt.Sprintf("unexpected argument '%s'", arg))
+			os.Exit(1)
+		}
+	}
+	if source == "" {
+		printImportUsage("no file specified")
+		os.Exit(1)
+	}
+	if format == "make" {
+		if job != "" {
+			printImportUsage("--job is only for import gha")
+			os.Exit(1)
+		}
+		importMakefile(source, targets, output, force)
+		return
+	}
+	if len(targets) > 0 {
+		printImportUsage("--target is only for import make")
+		os.Exit(1)
+	}
+
+	result, err := internal.ImportGitHubActions(source, job)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if output == "" {
+		name := internal.WorkflowName(source)
+		if job != "" {
+			name += "-" + job
+		}
+		output = newWorkflowFile(name)
+	}
+	if err := ImportCommand(result, output, force); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// importMakefile runs linea import make, writing the workflows into output, the
+// workflows directory by default
+func importMakefile(source string, targets []string, output string, force bool) {
+	if output == "" {
+		output = filepath.Dir(newWorkflowFile("workflow"))
+	}
+	results, err := internal.ImportMakefile(source, targets)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if output == "-" {
+		if len(results) > 1 {
+			fmt.Fprintf(os.Stderr, "Error: %d targets to import; use --target to write a single one to stdout\n", len(results))
+			os.Exit(1)
+		}
+		err = ImportCommand(results[0], output, force)
+	} else {
+		err = ImportMakeCommand(results, output, force)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -1,9 +1,15 @@
 package cmd
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"strings"
+	"text/tabwriter"
+
+	"linea/internal"
 )
 
 // InitCommand creates a new workflow YAML file with template and documentation
@@ -58,7 +64,10 @@ variables:
 		return fmt.Errorf("failed to create file: %w", err)
 	}
 
-	fmt.Printf("✅ Created workflow file: %s\n", yamlFile)
+	internal.Output.Printf(internal.StatusSuccess, "Created workflow file: %s\n", yamlFile)
+	if internal.Output.Quiet {
+		return nil
+	}
 	fmt.Printf("\n")
 	fmt.Printf("You can now:\n")
 	fmt.Printf("  • Edit the file to customize your workflow\n")
@@ -69,34 +78,319 @@ variables:
 	return nil
 }
 
+// InitInteractiveCommand asks for the command, args, and variables and writes a documented workflow
+func InitInteractiveCommand(yamlFile string, in io.Reader) error {
+	if _, err := os.Stat(yamlFile); err == nil {
+		return fmt.Errorf("file %s already exists", yamlFile)
+	}
+
+	reader := bufio.NewReader(in)
+	spec := &internal.WorkflowSpec{}
+
+	fmt.Printf("Creating workflow %s (press Enter to accept defaults)\n\n", yamlFile)
+
+	for spec.Command == "" {
+		var err error
+		if spec.Command, err = prompt(reader, "Command to execute", ""); spec.Command != "" {
+			break
+		}
+		if err != nil {
+			fmt.Printf("\n")
+			return fmt.Errorf("input ended before a command was given")
+		}
+		fmt.Printf("  A command is required\n")
+	}
+	spec.Subcommand, _ = prompt(reader, "Subcommand (optional)", "")
+
+	fmt.Printf("\nArguments, one per line (use {name} for variables, empty line to finish):\n")
+	for {
+		arg, _ := prompt(reader, fmt.Sprintf("  arg %d", len(spec.Args)+1), "")
+		if arg == "" {
+			break
+		}
+		spec.Args = append(spec.Args, arg)
+	}
+
+	// Ask about every placeholder used in the args first
+	declared := make(map[string]bool)
+	placeholders := internal.ArgPlaceholders(spec.Args)
+	if len(placeholders) > 0 {
+		fmt.Printf("\nVariables used in arguments:\n")
+	}
+	for _, name := range placeholders {
+		spec.Variables = append(spec.Variables, promptVariable(reader, name))
+		declared[name] = true
+	}
+
+	fmt.Printf("\nAdditional variables (empty name to finish):\n")
+	for {
+		name, _ := prompt(reader, "  variable name", "")
+		if name == "" {
+			break
+		}
+		if declared[name] {
+			fmt.Printf("  Variable %s is already declared\n", name)
+			continue
+		}
+		spec.Variables = append(spec.Variables, promptVariable(reader, name))
+		declared[name] = true
+	}
+
+	fmt.Printf("\n")
+	spec.Description, _ = prompt(reader, "Description (optional)", "")
+
+	if err := os.WriteFile(yamlFile, []byte(internal.RenderWorkflow(spec)), 0644); err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+
+	internal.Output.Printf(internal.StatusSuccess, "\nCreated workflow file: %s\n", yamlFile)
+	fmt.Printf("\n")
+	fmt.Printf("You can now:\n")
+	fmt.Printf("  • Test it: linea test %s\n", yamlFile)
+	fmt.Printf("  • Run it: linea run %s\n", yamlFile)
+	fmt.Printf("\n")
+
+	return nil
+}
+
+// InitPresetCommand writes the workflow of a preset to yamlFile
+func InitPresetCommand(yamlFile, presetName string) error {
+	preset, err := internal.FindInitPreset(presetName)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(yamlFile); err == nil {
+		return fmt.Errorf("file %s already exists", yamlFile)
+	}
+	if dir := filepath.Dir(yamlFile); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+	}
+	if err := os.WriteFile(yamlFile, preset.Content, 0644); err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+
+	internal.Output.Printf(internal.StatusSuccess, "Created workflow file: %s (preset %s)\n", yamlFile, preset.Name)
+	if internal.Output.Quiet {
+		return nil
+	}
+	fmt.Printf("\n")
+	fmt.Printf("You can now:\n")
+	fmt.Printf("  • Edit the variables and steps to fit your project\n")
+	fmt.Printf("  • Test it: linea test %s\n", yamlFile)
+	fmt.Printf("  • Run it: linea run %s\n", yamlFile)
+	fmt.Printf("\n")
+	return nil
+}
+
+// InitFromCommandCommand writes a workflow running a command line, declaring a variable
+// for each of its placeholders. With in, it asks for the variables' defaults and
+// descriptions and for the workflow's description
+func InitFromCommandCommand(yamlFile, line string, in io.Reader) error {
+	spec, err := internal.WorkflowSpecFromCommand(line)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(yamlFile); err == nil {
+		return fmt.Errorf("file %s already exists", yamlFile)
+	}
+
+	if in != nil {
+		reader := bufio.NewReader(in)
+		fmt.Printf("Creating workflow %s (press Enter to accept defaults)\n", yamlFile)
+		if len(spec.Variables) > 0 {
+			fmt.Printf("\nVariables used in the command:\n")
+		}
+		for i, variable := range spec.Variables {
+			spec.Variables[i] = promptVariable(reader, variable.Name)
+		}
+		fmt.Printf("\n")
+		spec.Description, _ = prompt(reader, "Description (optional)", "")
+	}
+
+	if err := os.WriteFile(yamlFile, []byte(internal.RenderWorkflow(spec)), 0644); err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+
+	internal.Output.Printf(internal.StatusSuccess, "Created workflow file: %s\n", yamlFile)
+	if internal.Output.Quiet {
+		return nil
+	}
+	fmt.Printf("\n")
+	fmt.Printf("You can now:\n")
+	if len(spec.Variables) > 0 && in == nil {
+		fmt.Printf("  • Set the defaults of the variables\n")
+	}
+	fmt.Printf("  • Test it: linea test %s\n", yamlFile)
+	fmt.Printf("  • Run it: linea run %s\n", yamlFile)
+	fmt.Printf("\n")
+	return nil
+}
+
+// ListPresetsCommand lists the presets of linea init
+func ListPresetsCommand() error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "PRESET\tDESCRIPTION\n")
+	for _, preset := range internal.InitPresets() {
+		fmt.Fprintf(w, "%s\t%s\n", preset.Name, preset.Description)
+	}
+	return w.Flush()
+}
+
+// newWorkflowFile returns the file a preset or an imported workflow is written to without
+// a file name: <name>.yml in the project's workflows directory, or in the current
+// directory outside a project
+func newWorkflowFile(name string) string {
+	cwd, _ := os.Getwd()
+	if dir := internal.FindWorkflowsDir(cwd); dir != "" {
+		if rel, err := filepath.Rel(cwd, dir); err == nil {
+			dir = rel
+		}
+		return filepath.Join(dir, name+".yml")
+	}
+	return name + ".yml"
+}
+
+// promptVariable asks for the default value and description of a variable
+func promptVariable(reader *bufio.Reader, name string) internal.WorkflowVariable {
+	variable := internal.WorkflowVariable{Name: name}
+	variable.Default, _ = prompt(reader, fmt.Sprintf("  %s: default value", name), "")
+	variable.Description, _ = prompt(reader, fmt.Sprintf("  %s: description", name), "")
+	return variable
+}
+
+// prompt prints a question and reads a single line answer, returning def for an empty answer.
+// The error is io.EOF when the input ended, after which every answer is def
+func prompt(reader *bufio.Reader, question, def string) (string, error) {
+	if def != "" {
+		fmt.Printf("%s [%s]: ", question, def)
+	} else {
+		fmt.Printf("%s: ", question)
+	}
+
+	line, err := reader.ReadString('\n')
+	answer := strings.TrimSpace(line)
+	if answer == "" {
+		return def, err
+	}
+	return answer, err
+}
+
 // InitCommandMain is the entry point for the init subcommand
 func InitCommandMain(args []string) {
 	if len(args) < 1 {
 		fmt.Fprintf(os.Stderr, "\n")
-		fmt.Fprintf(os.Stderr, "  ❌ Error: no file name specified\n")
+		internal.Output.Eprintf(internal.StatusError, "  Error: no file name specified\n")
 		fmt.Fprintf(os.Stderr, "\n")
 		fmt.Fprintf(os.Stderr, "  USAGE:\n")
-		fmt.Fprintf(os.Stderr, "    linea init <file-name>\n")
+		fmt.Fprintf(os.Stderr, "    linea init [--interactive] <file-name>\n")
+		fmt.Fprintf(os.Stderr, "    linea init --preset <name> [file-name]\n")
+		fmt.Fprintf(os.Stderr, "    linea init <file-name> --from-command \"<command line>\"\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "  OPTIONS:\n")
+		fmt.Fprintf(os.Stderr, "    -i, --interactive          Ask for command, args, variables, and description\n")
+		fmt.Fprintf(os.Stderr, "    -p, --preset <name>        Start from a ready-made workflow (see --list-presets)\n")
+		fmt.Fprintf(os.Stderr, "    --list-presets             List the presets\n")
+		fmt.Fprintf(os.Stderr, "    --from-command <line>      Turn a command line into a workflow\n")
 		fmt.Fprintf(os.Stderr, "\n")
 		fmt.Fprintf(os.Stderr, "  EXAMPLES:\n")
 		fmt.Fprintf(os.Stderr, "    linea init workflow.yml\n")
 		fmt.Fprintf(os.Stderr, "    linea init my-commands.yml\n")
 		fmt.Fprintf(os.Stderr, "    linea init examples/new-workflow.yml\n")
+		fmt.Fprintf(os.Stderr, "    linea init --interactive deploy.yml\n")
+		fmt.Fprintf(os.Stderr, "    linea init --preset docker-build\n")
+		fmt.Fprintf(os.Stderr, "    linea init build.yml --from-command \"docker build -t myapp:{tag} .\"\n")
 		fmt.Fprintf(os.Stderr, "\n")
 		os.Exit(1)
 	}
 
-	yamlFile := args[0]
+	interactive := false
+	yamlFile, presetName := "", ""
+	fromCommand, hasFromCommand := "", false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "-i" || arg == "--interactive":
+			interactive = true
+		case arg == "--list-presets":
+			if err := ListPresetsCommand(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case arg == "-p" || arg == "--preset":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "Error: %s needs a preset name (see linea init --list-presets)\n", arg)
+				os.Exit(1)
+			}
+			i++
+			presetName = args[i]
+		case strings.HasPrefix(arg, "--preset="):
+			presetName = strings.TrimPrefix(arg, "--preset=")
+		case arg == "--from-command":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "Error: %s needs a command line\n", arg)
+				os.Exit(1)
+			}
+			i++
+			fromCommand, hasFromCommand = args[i], true
+		case strings.HasPrefix(arg, "--from-command="):
+			fromCommand, hasFromCommand = strings.TrimPrefix(arg, "--from-command="), true
+		case !strings.HasPrefix(arg, "-"):
+			yamlFile = arg
+		}
+	}
+
+	if presetName != "" {
+		if hasFromCommand {
+			fmt.Fprintf(os.Stderr, "Error: --preset and --from-command cannot be combined\n")
+			os.Exit(1)
+		}
+		if interactive {
+			fmt.Fprintf(os.Stderr, "Error: --preset and --interactive cannot be combined\n")
+			os.Exit(1)
+		}
+		if yamlFile == "" {
+			yamlFile = newWorkflowFile(presetName)
+		}
+		if err := InitPresetCommand(yamlFile, presetName); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if yamlFile == "" {
+		fmt.Fprintf(os.Stderr, "\n")
+		internal.Output.Eprintf(internal.StatusError, "  Error: no file name specified\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "  USAGE:\n")
+		fmt.Fprintf(os.Stderr, "    linea init [--interactive] <file-name>\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		os.Exit(1)
+	}
 
 	// Ensure file has .yml or .yaml extension
 	if !strings.HasSuffix(yamlFile, ".yml") && !strings.HasSuffix(yamlFile, ".yaml") {
 		fmt.Fprintf(os.Stderr, "\n")
-		fmt.Fprintf(os.Stderr, "  ⚠️  Warning: file should have .yml or .yaml extension\n")
+		internal.Output.Eprintf(internal.StatusWarning, "  Warning: file should have .yml or .yaml extension\n")
 		fmt.Fprintf(os.Stderr, "  Continuing anyway...\n")
 		fmt.Fprintf(os.Stderr, "\n")
 	}
 
-	if err := InitCommand(yamlFile); err != nil {
+	var err error
+	switch {
+	case hasFromCommand && interactive:
+		err = InitFromCommandCommand(yamlFile, fromCommand, os.Stdin)
+	case hasFromCommand:
+		err = InitFromCommandCommand(yamlFile, fromCommand, nil)
+	case interactive:
+		err = InitInteractiveCommand(yamlFile, os.Stdin)
+	default:
+		err = InitCommand(yamlFile)
+	}
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"linea/internal"
+)
+
+// JobsCommand lists the background jobs of the project in the current directory
+func JobsCommand(asJSON bool) error {
+	dir := internal.JobsDir(".")
+	jobs, err := internal.LoadJobs(dir)
+	if err != nil {
+		return err
+	}
+
+	if asJSON {
+		type jobStatus struct {
+			*internal.Job
+			Running bool `json:"running"`
+		}
+		statuses := make([]jobStatus, len(jobs))
+		for i, job := range jobs {
+			statuses[i] = jobStatus{Job: job, Running: job.Running()}
+		}
+		data, err := json.MarshalIndent(statuses, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode jobs: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if len(jobs) == 0 {
+		fmt.Printf("No background jobs in %s\n", dir)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "JOB\tPID\tSTATUS\tWORKFLOW\tSTARTED\tCOMMAND")
+	for _, job := range jobs {
+		status := "running"
+		if !job.Running() {
+			status = "exited"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\n",
+			job.ID,
+			job.PID,
+			status,
+			job.Workflow,
+			job.Start.Local().Format("2006-01-02 15:04:05"),
+			internal.ShellQuote(job.Command, internal.DefaultQuoteStyle()),
+		)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if !internal.Output.Quiet {
+		fmt.Println()
+		fmt.Printf("Job output is in %s/<job>.log; stop a job with: linea stop <job>\n", dir)
+	}
+	return nil
+}
+
+// StopCommand terminates background jobs of the project in the current directory, or
+// every one of them with all
+func StopCommand(ids []string, all bool, timeout time.Duration) error {
+	dir := internal.JobsDir(".")
+	var jobs []*internal.Job
+	if all {
+		var err error
+		if jobs, err = internal.LoadJobs(dir); err != nil {
+			return err
+		}
+	}
+	for _, id := range ids {
+		job, err := internal.LoadJob(dir, id)
+		if err != nil {
+			return err
+		}
+		jobs = append(jobs, job)
+	}
+
+	for _, job := range jobs {
+		running := job.Running()
+		if err := internal.StopJob(dir, job, timeout); err != nil {
+			return err
+		}
+		if running {
+			internal.Output.Printf(internal.StatusStop, "Stopped job %s (pid %d)\n", job.ID, job.PID)
+		} else {
+			internal.Output.Printf(internal.StatusRemove, "Removed job %s, which had already exited\n", job.ID)
+		}
+	}
+	return nil
+}
+
+// JobsCommandMain is the entry point for the jobs subcommand
+func JobsCommandMain(args []string) {
+	asJSON := false
+	for _, arg := range args {
+		switch arg {
+		case "--json":
+			asJSON = true
+		default:
+			fmt.Fprintf(os.Stderr, "\n")
+			internal.Output.Eprintf(internal.StatusError, "  Error: unknown option '%s'\n", arg)
+			fmt.Fprintf(os.Stderr, "\n")
+			fmt.Fprintf(os.Stderr, "  USAGE:\n")
+			fmt.Fprintf(os.Stderr, "    linea jobs [--json]\n")
+			fmt.Fprintf(os.Stderr, "\n")
+			os.Exit(1)
+		}
+	}
+
+	if err := JobsCommand(asJSON); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// StopCommandMain is the entry point for the stop subcommand
+func StopCommandMain(args []string) {
+	all := false
+	timeout := internal.DefaultStopTimeout
+	var ids []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--all":
+			all = true
+		case arg == "--timeout" && i+1 < len(args):
+			i++
+			d, err := internal.ParseAge(args[i])
+			if err != nil {
+				printStopUsage(err.Error())
+				os.Exit(1)
+			}
+			timeout = d
+		case !strings.HasPrefix(arg, "-"):
+			ids = append(ids, arg)
+		default:
+			printStopUsage(fmt.Sprintf("unknown option '%s'", arg))
+			os.Exit(1)
+		}
+	}
+
+	if len(ids) == 0 && !all {
+		printStopUsage("no job specified")
+		os.Exit(1)
+	}
+
+	if err := StopCommand(ids, all, timeout); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// printStopUsage prints the usage of the stop subcommand with an error message
+func printStopUsage(message string) {
+	fmt.Fprintf(os.Stderr, "\n")
+	internal.Output.Eprintf(internal.StatusError, "  Error: %s\n", message)
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "  USAGE:\n")
+	fmt.Fprintf(os.Stderr, "    linea stop [options] <job>...\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "  OPTIONS:\n")
+	fmt.Fprintf(os.Stderr, "    --all                  Stop every background job of the project\n")
+	fmt.Fprintf(os.Stderr, "    --timeout <duration>   Wait this long for a job to exit before killing it (default 5s)\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "  EXAMPLES:\n")
+	fmt.Fprintf(os.Stderr, "    linea jobs\n")
+	fmt.Fprintf(os.Stderr, "    linea stop dev-server\n")
+	fmt.Fprintf(os.Stderr, "\n")
+}
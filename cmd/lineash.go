@@ -34,11 +34,31 @@ func ExecuteLineashScript(scriptPath string, scriptArgs []string) error {
 	return internal.ExecuteLines(ctx, string(scriptContent))
 }
 
+// ShCommandMain is the entry point for the sh subcommand (linea sh <script.lnsh> [args...])
+// It runs lineash scripts from the main binary so single-binary installs need no lineash executable
+func ShCommandMain(args []string) {
+	if len(args) < 1 {
+		fmt.Fprintf(os.Stderr, "\n")
+		internal.Output.Eprintf(internal.StatusError, "  Error: no script file specified\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "  USAGE:\n")
+		fmt.Fprintf(os.Stderr, "    linea sh <script.lnsh> [args...]\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "  EXAMPLES:\n")
+		fmt.Fprintf(os.Stderr, "    linea sh scripts/script.lnsh\n")
+		fmt.Fprintf(os.Stderr, "    linea sh .linea/scripts/deploy.lnsh arg1 arg2\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		os.Exit(1)
+	}
+
+	LineashMain(args)
+}
+
 // LineashMain is the entry point for the lineash command
 func LineashMain(args []string) {
 	if len(args) < 1 {
 		fmt.Fprintf(os.Stderr, "\n")
-		fmt.Fprintf(os.Stderr, "  ❌ Error: no script file specified\n")
+		internal.Output.Eprintf(internal.StatusError, "  Error: no script file specified\n")
 		fmt.Fprintf(os.Stderr, "\n")
 		fmt.Fprintf(os.Stderr, "  USAGE:\n")
 		fmt.Fprintf(os.Stderr, "    lineash <script.lnsh> [args...]\n")
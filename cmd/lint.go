@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"linea/internal"
+)
+
+// LintCommand runs the lint rules on workflow files, skipping the rules in disabled
+// With fix set, fixable findings are corrected in place
+// Returns the number of findings left unfixed
+func LintCommand(targets []string, fix bool, disabled map[string]bool) (int, error) {
+	files, err := collectWorkflowFiles(targets)
+	if err != nil {
+		return 0, err
+	}
+	if len(files) == 0 {
+		return 0, fmt.Errorf("no workflow files (.yml/.yaml/.json/.toml) found")
+	}
+
+	remaining := 0
+	fixed := 0
+	for _, file := range files {
+		findings, contents, err := internal.LintWorkflowFile(file, fix)
+		if err != nil {
+			return remaining, err
+		}
+
+		var shown []internal.LintFinding
+		for _, finding := range findings {
+			if !disabled[finding.Rule.ID] {
+				shown = append(shown, finding)
+			}
+		}
+		if len(shown) == 0 {
+			internal.Output.Printf(internal.StatusSuccess, "%s\n", file)
+			continue
+		}
+
+		internal.Output.Printf(internal.StatusWarning, "%s\n", file)
+		for _, finding := range shown {
+			switch {
+			case finding.Fixed:
+				fmt.Printf("   %s (fixed)\n", finding)
+				fixed++
+			case finding.Rule.Fixable:
+				fmt.Printf("   %s (fixable with --fix)\n", finding)
+				remaining++
+			default:
+				fmt.Printf("   %s\n", finding)
+				remaining++
+			}
+		}
+
+		if contents != nil {
+			info, err := os.Stat(file)
+			if err != nil {
+				return remaining, fmt.Errorf("cannot access %s: %w", file, err)
+			}
+			if err := os.WriteFile(file, contents, info.Mode().Perm()); err != nil {
+				return remaining, fmt.Errorf("failed to write %s: %w", file, err)
+			}
+		}
+	}
+
+	if fix {
+		fmt.Printf("\n%d file(s) checked, %d finding(s) fixed, %d remaining\n", len(files), fixed, remaining)
+	} else {
+		fmt.Printf("\n%d file(s) checked, %d finding(s)\n", len(files), remaining)
+	}
+	return remaining, nil
+}
+
+// LintCommandMain is the entry point for the lint subcommand
+func LintCommandMain(args []string) {
+	fix := false
+	disabled := make(map[string]bool)
+	var targets []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--fix":
+			fix = true
+		case arg == "--rules":
+			printLintRules()
+			return
+		case arg == "--disable" && i+1 < len(args):
+			i++
+			for _, id := range strings.Split(args[i], ",") {
+				rule, ok := internal.LookupLintRule(strings.TrimSpace(id))
+				if !ok {
+					fmt.Fprintf(os.Stderr, "\n")
+					internal.Output.Eprintf(internal.StatusError, "  Error: unknown lint rule '%s' (see linea lint --rules)\n", id)
+					fmt.Fprintf(os.Stderr, "\n")
+					os.Exit(1)
+				}
+				disabled[rule.ID] = true
+			}
+		case !strings.HasPrefix(arg, "-"):
+			targets = append(targets, arg)
+		}
+	}
+
+	// Default to the project's workflows
+	if len(targets) == 0 {
+		cwd, _ := os.Getwd()
+		if dir := internal.FindWorkflowsDir(cwd); dir != "" {
+			targets = append(targets, dir)
+		}
+	}
+
+	if len(targets) == 0 {
+		fmt.Fprintf(os.Stderr, "\n")
+		internal.Output.Eprintf(internal.StatusError, "  Error: no file or directory specified (and no .linea/workflows directory found)\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "  USAGE:\n")
+		fmt.Fprintf(os.Stderr, "    linea lint [--fix] [--disable <rule,...>] [file|dir]...\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "  OPTIONS:\n")
+		fmt.Fprintf(os.Stderr, "    --fix                  Correct fixable findings in place\n")
+		fmt.Fprintf(os.Stderr, "    --disable <rule,...>   Skip rules by ID or name (e.g. L005,unused-variable)\n")
+		fmt.Fprintf(os.Stderr, "    --rules                List the lint rules\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "  EXAMPLES:\n")
+		fmt.Fprintf(os.Stderr, "    linea lint\n")
+		fmt.Fprintf(os.Stderr, "    linea lint --fix deploy.yml\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		os.Exit(1)
+	}
+
+	remaining, err := LintCommand(targets, fix, disabled)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if remaining > 0 {
+		os.Exit(1)
+	}
+}
+
+// printLintRules lists the lint rules with their IDs
+func printLintRules() {
+	for _, rule := range internal.LintRules {
+		fixable := ""
+		if rule.Fixable {
+			fixable = " (fixable)"
+		}
+		fmt.Printf("%s  %-22s %s%s\n", rule.ID, rule.Name, rule.Description, fixable)
+	}
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"linea/internal"
+)
+
+// ListCommand prints the workflows in the local .linea/workflows directory or the global directory
+func ListCommand(global bool) error {
+	var dir string
+	if global {
+		dir = internal.GlobalWorkflowsDir()
+	} else {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("failed to get current directory: %w", err)
+		}
+		dir = internal.FindWorkflowsDir(cwd)
+		if dir == "" {
+			return fmt.Errorf("could not find .linea/workflows directory (use --global for global workflows)")
+		}
+	}
+
+	workflows, err := internal.IndexWorkflows(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			fmt.Printf("No workflows found in %s\n", dir)
+			return nil
+		}
+		return fmt.Errorf("failed to read workflows directory: %w", err)
+	}
+
+	if len(workflows) == 0 {
+		fmt.Printf("No workflows found in %s\n", dir)
+		return nil
+	}
+
+	fmt.Printf("Workflows in %s:\n\n", dir)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "NAME\tDESCRIPTION\tREQUIRED VARIABLES\n")
+	for _, workflow := range workflows {
+		required := "-"
+		if len(workflow.Required) > 0 {
+			required = strings.Join(workflow.Required, ", ")
+		}
+		description := workflow.Description
+		if description == "" {
+			description = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", workflow.Name, description, required)
+	}
+	return w.Flush()
+}
+
+// ListCommandMain is the entry point for the list subcommand
+func ListCommandMain(args []string) {
+	global := false
+	packages := false
+	for _, arg := range args {
+		if arg == "-g" || arg == "--global" {
+			global = true
+		} else if arg == "--packages" {
+			packages = true
+		} else {
+			fmt.Fprintf(os.Stderr, "\n")
+			internal.Output.Eprintf(internal.StatusError, "  Error: unknown option '%s'\n", arg)
+			fmt.Fprintf(os.Stderr, "\n")
+			fmt.Fprintf(os.Stderr, "  USAGE:\n")
+			fmt.Fprintf(os.Stderr, "    linea list [--global|--packages]\n")
+			fmt.Fprintf(os.Stderr, "\n")
+			os.Exit(1)
+		}
+	}
+
+	if packages {
+		if err := ListPackagesCommand(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if err := ListCommand(global); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"linea/internal"
+)
+
+// LogsCommand prints the output of a recorded run, or only the log's path
+func LogsCommand(runID string, pathOnly bool) error {
+	record, err := internal.FindRunRecord(runID)
+	if err != nil {
+		return err
+	}
+	if pathOnly {
+		if record.LogFile == "" {
+			return fmt.Errorf("run %s has no log", record.ID)
+		}
+		fmt.Println(record.LogFile)
+		return nil
+	}
+
+	data, err := internal.ReadRunLog(record)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
+
+// LogsCommandMain is the entry point for the logs subcommand
+func LogsCommandMain(args []string) {
+	pathOnly := false
+	runID := ""
+	for _, arg := range args {
+		switch {
+		case arg == "--path":
+			pathOnly = true
+		case !strings.HasPrefix(arg, "-"):
+			runID = arg
+		}
+	}
+
+	if runID == "" {
+		fmt.Fprintf(os.Stderr, "\n")
+		internal.Output.Eprintf(internal.StatusError, "  Error: no run ID specified\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "  USAGE:\n")
+		fmt.Fprintf(os.Stderr, "    linea logs [--path] <run-id|last>\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "  OPTIONS:\n")
+		fmt.Fprintf(os.Stderr, "    --path                 Print the log file's path instead of its contents\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "  EXAMPLES:\n")
+		fmt.Fprintf(os.Stderr, "    linea history\n")
+		fmt.Fprintf(os.Stderr, "    linea logs last\n")
+		fmt.Fprintf(os.Stderr, "    linea logs 20260302T101500-3fa2\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		os.Exit(1)
+	}
+
+	if err := LogsCommand(runID, pathOnly); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,256 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"linea/internal"
+)
+
+// PackageCommand packs the package in dir into a .tar.gz
+func PackageCommand(dir, out string) error {
+	archive, meta, err := internal.BuildPackage(dir, out)
+	if err != nil {
+		return err
+	}
+	sum, err := internal.FileSHA256(archive)
+	if err != nil {
+		return err
+	}
+	internal.Output.Printf(internal.StatusSuccess, "Packaged %s %s into %s\n", meta.Name, meta.Version, archive)
+	fmt.Printf("   sha256: %s\n", sum)
+	return nil
+}
+
+// InstallCommand installs a package from a file, a URL, or the registry
+func InstallCommand(source string) error {
+	meta, previous, err := internal.InstallPackage(source)
+	if err != nil {
+		return err
+	}
+	switch previous {
+	case "":
+		internal.Output.Printf(internal.StatusSuccess, "Installed %s %s\n", meta.Name, meta.Version)
+	case meta.Version:
+		internal.Output.Printf(internal.StatusSuccess, "Reinstalled %s %s\n", meta.Name, meta.Version)
+	default:
+		internal.Output.Printf(internal.StatusSuccess, "Updated %s %s -> %s\n", meta.Name, previous, meta.Version)
+	}
+	workflows, _ := internal.IndexWorkflows(filepath.Join(meta.Dir, internal.PackageWorkflowsDir))
+	for _, workflow := range workflows {
+		fmt.Printf("   linea run %s\n", workflow.Name)
+	}
+	return nil
+}
+
+// UninstallCommand removes an installed package
+func UninstallCommand(name string) error {
+	meta, err := internal.UninstallPackage(name)
+	if err != nil {
+		return err
+	}
+	if meta.Version != "" {
+		internal.Output.Printf(internal.StatusSuccess, "Uninstalled %s %s\n", meta.Name, meta.Version)
+	} else {
+		internal.Output.Printf(internal.StatusSuccess, "Uninstalled %s\n", meta.Name)
+	}
+	return nil
+}
+
+// SearchCommand lists the packages of the registry matching term
+func SearchCommand(term string) error {
+	index, _, err := internal.FetchPackageIndex()
+	if err != nil {
+		return err
+	}
+	entries := index.Search(term)
+	if len(entries) == 0 {
+		fmt.Printf("No packages found\n")
+		return nil
+	}
+
+	installed := map[string]string{}
+	for _, meta := range internal.InstalledPackages() {
+		installed[meta.Name] = meta.Version
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "NAME\tVERSION\tINSTALLED\tDESCRIPTION\n")
+	for _, entry := range entries {
+		version := installed[entry.Name]
+		if version == "" {
+			version = "-"
+		}
+		description := entry.Description
+		if description == "" {
+			description = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", entry.Name, entry.Version, version, description)
+	}
+	return w.Flush()
+}
+
+// PublishCommand uploads the package in dir to the registry
+func PublishCommand(dir string, opts internal.PublishOptions) error {
+	result, err := internal.PublishPackage(dir, opts)
+	if err != nil {
+		return err
+	}
+	if result.Previous != "" {
+		verb := "Bumped"
+		if opts.DryRun {
+			verb = "Would bump"
+		}
+		internal.Output.Printf(internal.StatusEdit, "%s %s %s -> %s\n", verb, result.Meta.Name, result.Previous, result.Meta.Version)
+	}
+	if opts.DryRun {
+		internal.Output.Printf(internal.StatusSuccess, "%s %s is ready to publish (dry run)\n", result.Meta.Name, result.Meta.Version)
+	} else {
+		internal.Output.Printf(internal.StatusSuccess, "Published %s %s to %s\n", result.Meta.Name, result.Meta.Version, result.URL)
+	}
+	fmt.Printf("   sha256: %s\n", result.SHA256)
+	return nil
+}
+
+// ListPackagesCommand lists the installed packages and their workflows
+func ListPackagesCommand() error {
+	packages := internal.InstalledPackages()
+	if len(packages) == 0 {
+		fmt.Printf("No packages installed in %s\n", internal.PackagesDir())
+		return nil
+	}
+
+	fmt.Printf("Packages in %s:\n\n", internal.PackagesDir())
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "PACKAGE\tVERSION\tWORKFLOW\tDESCRIPTION\n")
+	for _, meta := range packages {
+		workflows, _ := internal.IndexWorkflows(filepath.Join(meta.Dir, internal.PackageWorkflowsDir))
+		for _, workflow := range workflows {
+			description := workflow.Description
+			if description == "" {
+				description = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", meta.Name, meta.Version, workflow.Name, description)
+		}
+	}
+	return w.Flush()
+}
+
+// printPackageUsage prints the usage of a package subcommand with an error message
+func printPackageUsage(message string) {
+	fmt.Fprintf(os.Stderr, "\n")
+	internal.Output.Eprintf(internal.StatusError, "  Error: %s\n", message)
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "  USAGE:\n")
+	fmt.Fprintf(os.Stderr, "    linea package [dir] [-o <file>]     Pack a package directory into a .tar.gz\n")
+	fmt.Fprintf(os.Stderr, "    linea install <file|url|name[@version]>  Install a package\n")
+	fmt.Fprintf(os.Stderr, "    linea uninstall <name>              Remove an installed package\n")
+	fmt.Fprintf(os.Stderr, "    linea search [term]                 Search the registry\n")
+	fmt.Fprintf(os.Stderr, "    linea publish [dir] [--bump <part>] [--dry-run]  Upload a package to the registry\n")
+	fmt.Fprintf(os.Stderr, "    linea list --packages               List installed packages\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "  EXAMPLES:\n")
+	fmt.Fprintf(os.Stderr, "    linea package ./docker-tools\n")
+	fmt.Fprintf(os.Stderr, "    linea install docker-tools\n")
+	fmt.Fprintf(os.Stderr, "    linea install k8s-deploy@1.2.0\n")
+	fmt.Fprintf(os.Stderr, "    linea install https://example.com/packages/k8s-deploy-1.2.0.tar.gz\n")
+	fmt.Fprintf(os.Stderr, "    linea publish --bump patch ./docker-tools\n")
+	fmt.Fprintf(os.Stderr, "\n")
+}
+
+// PackageCommandMain is the entry point for the package subcommand
+func PackageCommandMain(args []string) {
+	dir, out := ".", ""
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "-o" || args[i] == "--output":
+			if i+1 >= len(args) {
+				printPackageUsage(args[i] + " needs a file name")
+				os.Exit(1)
+			}
+			i++
+			out = args[i]
+		case strings.HasPrefix(args[i], "-"):
+			printPackageUsage(fmt.Sprintf("unknown option '%s'", args[i]))
+			os.Exit(1)
+		default:
+			dir = args[i]
+		}
+	}
+	if err := PackageCommand(dir, out); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// InstallCommandMain is the entry point for the install subcommand
+func InstallCommandMain(args []string) {
+	if len(args) != 1 {
+		printPackageUsage("install needs one package file, URL, or name")
+		os.Exit(1)
+	}
+	if err := InstallCommand(args[0]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// UninstallCommandMain is the entry point for the uninstall subcommand
+func UninstallCommandMain(args []string) {
+	if len(args) != 1 {
+		printPackageUsage("uninstall needs one package name")
+		os.Exit(1)
+	}
+	if err := UninstallCommand(args[0]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// SearchCommandMain is the entry point for the search subcommand
+func SearchCommandMain(args []string) {
+	if len(args) > 1 {
+		printPackageUsage("search takes at most one term")
+		os.Exit(1)
+	}
+	term := ""
+	if len(args) == 1 {
+		term = args[0]
+	}
+	if err := SearchCommand(term); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// PublishCommandMain is the entry point for the publish subcommand
+func PublishCommandMain(args []string) {
+	dir := "."
+	var opts internal.PublishOptions
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--bump":
+			if i+1 >= len(args) {
+				printPackageUsage("--bump needs major, minor, or patch")
+				os.Exit(1)
+			}
+			i++
+			opts.Bump = args[i]
+		case strings.HasPrefix(args[i], "--bump="):
+			opts.Bump = strings.TrimPrefix(args[i], "--bump=")
+		case args[i] == "--dry-run":
+			opts.DryRun = true
+		case strings.HasPrefix(args[i], "-"):
+			printPackageUsage(fmt.Sprintf("unknown option '%s'", args[i]))
+			os.Exit(1)
+		default:
+			dir = args[i]
+		}
+	}
+	if err := PublishCommand(dir, opts); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
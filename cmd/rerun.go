@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"linea/internal"
+)
+
+// RerunCommand replays a recorded run with its recorded variables
+// overrideVars replace recorded values; with in set, every variable is confirmed interactively first
+func RerunCommand(runID string, overrideVars map[string]string, opts RunOptions, in io.Reader) error {
+	record, err := internal.FindRunRecord(runID)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(record.Path); err != nil {
+		return fmt.Errorf("workflow file %s of run %s no longer exists", record.Path, record.ID)
+	}
+
+	vars := make(map[string]string, len(record.Variables)+len(overrideVars))
+	for name, value := range record.Variables {
+		vars[name] = value
+	}
+	for name, value := range overrideVars {
+		vars[name] = value
+	}
+
+	if in != nil {
+		vars = editRunVariables(record.Path, vars, bufio.NewReader(in))
+	}
+
+	internal.Output.Printf(internal.StatusRetry, "Re-running %s (run %s)\n", record.Workflow, record.ID)
+	if len(vars) > 0 && opts.Verbose {
+		for _, name := range sortedKeys(vars) {
+			fmt.Printf("   %s=%s\n", name, internal.MaskSecrets(vars[name]))
+		}
+	}
+	return RunCommand(record.Path, vars, opts)
+}
+
+// editRunVariables asks for the value of every recorded or required variable, defaulting to the current value
+func editRunVariables(path string, vars map[string]string, reader *bufio.Reader) map[string]string {
+	current := make(map[string]string, len(vars))
+	for name, value := range vars {
+		current[name] = value
+	}
+	if info := internal.IndexWorkflowFile(path); info != nil {
+		for _, name := range info.Required {
+			if _, ok := current[name]; !ok {
+				current[name] = ""
+			}
+		}
+	}
+
+	edited := make(map[string]string, len(current))
+	fmt.Println("Edit variables (press Enter to keep the current value):")
+	for _, name := range sortedKeys(current) {
+		if value, _ := prompt(reader, "  "+name, current[name]); value != "" {
+			edited[name] = value
+		}
+	}
+	return edited
+}
+
+// sortedKeys returns the keys of a map in sorted order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// RerunCommandMain is the entry point for the rerun subcommand
+func RerunCommandMain(args []string) {
+	overrideVars, remainingArgs := ParseArgs(args)
+
+	opts := RunOptions{Verbose: internal.CurrentUserConfig().Verbose, Tracer: envTracer()}
+	interactive := false
+	runID := ""
+	for _, arg := range remainingArgs {
+		switch {
+		case arg == "-v" || arg == "--verbose":
+			opts.Verbose = true
+		case arg == "--force":
+			opts.Force = true
+		case arg == "-y" || arg == "--yes":
+			opts.Yes = true
+		case arg == "--enforce-policy":
+			opts.EnforcePolicy = true
+		case arg == "--require-signed":
+			opts.RequireSigned = true
+		case arg == "-i" || arg == "--interactive":
+			interactive = true
+		case !strings.HasPrefix(arg, "-"):
+			runID = arg
+		}
+	}
+
+	if runID == "" {
+		fmt.Fprintf(os.Stderr, "\n")
+		internal.Output.Eprintf(internal.StatusError, "  Error: no run ID specified\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "  USAGE:\n")
+		fmt.Fprintf(os.Stderr, "    linea rerun [options] <run-id|last>\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "  OPTIONS:\n")
+		fmt.Fprintf(os.Stderr, "    -s, --set <var>=<value>     Override a recorded variable (can be used multiple times)\n")
+		fmt.Fprintf(os.Stderr, "    -i, --interactive          Edit the variables before re-running\n")
+		fmt.Fprintf(os.Stderr, "    -v, --verbose              Show the command before executing\n")
+		fmt.Fprintf(os.Stderr, "    --force                    Run steps outside their allowed_hours/allowed_days window\n")
+		fmt.Fprintf(os.Stderr, "    -y, --yes                  Answer the confirm: prompts of steps with yes\n")
+		fmt.Fprintf(os.Stderr, "    --enforce-policy           Refuse to run commands the policy files do not allow\n")
+		fmt.Fprintf(os.Stderr, "    --require-signed           Refuse to run workflow files that do not match their signature\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		os.Exit(1)
+	}
+
+	var in io.Reader
+	if interactive {
+		in = os.Stdin
+	}
+	internal.HandleSignals(internal.DefaultGracePeriod)
+	if err := RerunCommand(runID, overrideVars, opts, in); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		printFailureHints(err)
+		os.Exit(failedExitCode(err, 1))
+	}
+}
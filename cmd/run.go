@@ -2,42 +2,545 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"linea/internal"
 )
 
+// RunOptions holds the flags of `linea run`
+type RunOptions struct {
+	Verbose   bool               // Show each command before executing it
+	Force     bool               // Run steps outside their allowed_hours/allowed_days window
+	Yes       bool               // Answer the confirm: prompts of steps with yes
+	Output    string             // json or yaml prints a report of the run instead of the usual output
+	Capture   bool               // Record each step's stdout and stderr in the report
+	LogFile   string             // Write the run's output here instead of the automatic log in .linea/logs
+	KeepGoing bool               // Run every step of every file instead of stopping at the first failure
+	Summary   string             // text, json, or none; empty prints a text summary after multi-step runs
+	Progress  *internal.Progress // Receives NDJSON progress events (--progress-fd/--progress-file)
+	Display   bool               // Show the step running and each step's outcome (--progress)
+
+	EnforcePolicy  bool          // Refuse to run commands the policy files do not allow
+	RequireSigned  bool          // Refuse to run workflow files that do not match their signature
+	IdempotencyKey string        // Skip the run if a run with this key and the same inputs succeeded
+	GracePeriod    time.Duration // Time a command has to exit after Ctrl+C or SIGTERM before it is killed
+
+	Chaos *internal.ChaosOptions // Inject random faults into the steps (--chaos)
+
+	Resume  bool // Continue the last failed run from its first step that did not complete
+	NoCache bool // Run the steps with cache: even when their inputs are unchanged
+
+	Tracer *internal.Tracer // Exports each run as a trace (the OTEL_ variables, see envTracer)
+}
+
+// bindWorkflowInputs maps the arguments after a workflow to the inputs: it declares, and
+// returns the -s values with them. It reports false for a workflow without inputs, whose
+// arguments are other workflows to run after it; one that cannot be read is reported
+// when it runs
+func bindWorkflowInputs(workflow string, args []string, overrideVars map[string]string) (map[string]string, bool, error) {
+	path, err := internal.ResolveWorkflowPath(workflow)
+	if err != nil {
+		return overrideVars, false, nil
+	}
+	inputs, err := internal.WorkflowInputs(path)
+	if err != nil || len(inputs) == 0 {
+		return overrideVars, false, nil
+	}
+	vars, err := internal.BindInputs(workflow, inputs, args, overrideVars)
+	return vars, true, err
+}
+
+// structured reports whether the options ask for a --output report
+func (o RunOptions) structured() bool {
+	return o.Output == internal.OutputJSON || o.Output == internal.OutputYAML
+}
+
+// machineReadable reports whether standard output is reserved for a report or summary,
+// in which case the commands' output goes to standard error
+func (o RunOptions) machineReadable() bool {
+	return o.structured() || o.Summary == internal.SummaryJSON
+}
+
 // RunCommand executes a YAML command file (supports single or multiple commands)
-func RunCommand(yamlFile string, verbose bool, overrideVars map[string]string) error {
-	configs, err := internal.ParseMultiYAML(yamlFile)
+// Unless the summary is disabled, it runs like RunBatchCommand with a single file so that
+// every step is timed
+func RunCommand(yamlFile string, overrideVars map[string]string, opts RunOptions) error {
+	internal.AssumeYes = opts.Yes
+	internal.NoCache = opts.NoCache
+	if opts.structured() || opts.KeepGoing || opts.Summary != internal.SummaryNone || opts.Progress != nil || opts.Display || opts.Chaos != nil || opts.Tracer != nil || opts.Resume {
+		_, err := RunBatchCommand([]string{yamlFile}, overrideVars, opts)
+		return err
+	}
+
+	yamlFile, err := internal.ResolveWorkflowPath(yamlFile)
+	if err != nil {
+		return err
+	}
+	if prior, err := priorRun(yamlFile, overrideVars, opts); err != nil || prior != nil {
+		return err
+	}
+	start := time.Now()
+	record, err := recordRun(yamlFile, overrideVars, opts, start, func(stdout, stderr io.Writer) error {
+		return runWorkflow(yamlFile, overrideVars, opts, stdout, stderr)
+	})
+	report := internal.NewRunReport(yamlFile, "run", overrideVars, start)
+	report.RunID, report.IdempotencyKey = record.ID, opts.IdempotencyKey
+	report.Finish(err)
+	notifyRun(report)
+	return err
+}
+
+// RunBatchCommand runs workflow files in order and returns the number of failed steps
+// (a file that fails before its steps run counts as one) and the first error
+// By default it stops at the first failure; with KeepGoing it runs every step of every file
+// In text mode a run of several steps ends with a summary of every step (see RunOptions.Summary);
+// with --output the reports are printed instead, as a list when there are several files
+func RunBatchCommand(yamlFiles []string, overrideVars map[string]string, opts RunOptions) (int, error) {
+	internal.AssumeYes = opts.Yes
+	internal.NoCache = opts.NoCache
+	var reports []*internal.RunReport
+	var firstErr error
+	failures := 0
+	for i, file := range yamlFiles {
+		if (firstErr != nil && !opts.KeepGoing) || internal.Cancelled() != nil {
+			for _, notRun := range yamlFiles[i:] {
+				report := internal.NewRunReport(notRun, "run", overrideVars, time.Now())
+				report.Status = internal.StepNotRun
+				reports = append(reports, report)
+				opts.Progress.Emit(internal.RunEndEvent(report))
+			}
+			break
+		}
+
+		report, err := runReport(file, overrideVars, opts)
+		reports = append(reports, report)
+		if err != nil {
+			failures += report.FailedSteps()
+			if firstErr == nil {
+				firstErr = err
+			}
+			if opts.KeepGoing && !opts.structured() && len(report.Steps) == 0 {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			}
+		}
+	}
+
+	if opts.structured() {
+		var v interface{} = reports
+		if len(reports) == 1 {
+			v = reports[0]
+		}
+		if err := internal.WriteStructured(os.Stdout, opts.Output, v); err != nil && firstErr == nil {
+			return failures, err
+		}
+		return failures, firstErr
+	}
+
+	summary := internal.SummarizeRuns(reports)
+	switch opts.Summary {
+	case internal.SummaryJSON:
+		if err := internal.WriteStructured(os.Stdout, internal.OutputJSON, summary); err != nil && firstErr == nil {
+			return failures, err
+		}
+	case internal.SummaryText:
+		printRunSummary(summary)
+	case "":
+		if (len(summary.Steps) > 1 || opts.KeepGoing) && !internal.Output.Quiet {
+			printRunSummary(summary)
+		}
+	}
+	return failures, firstErr
+}
+
+// runReport runs one workflow file through the step reporter
+func runReport(file string, overrideVars map[string]string, opts RunOptions) (*internal.RunReport, error) {
+	start := time.Now()
+	yamlFile, err := internal.ResolveWorkflowPath(file)
 	if err != nil {
-		return fmt.Errorf("failed to parse YAML file: %w", err)
+		report := internal.NewRunReport(file, "run", overrideVars, start)
+		report.Finish(err)
+		opts.Progress.Emit(internal.RunEndEvent(report))
+		return report, err
 	}
 
+	prior, err := priorRun(yamlFile, overrideVars, opts)
+	if err != nil {
+		report := internal.NewRunReport(yamlFile, "run", overrideVars, start)
+		report.Finish(err)
+		opts.Progress.Emit(internal.RunEndEvent(report))
+		return report, err
+	}
+	if prior != nil {
+		report := internal.PriorRunReport(prior)
+		opts.Progress.Emit(internal.RunEndEvent(report))
+		return report, nil
+	}
+
+	// --resume continues from the checkpoint of the last failed run, with its -s values
+	var checkpoint *internal.Checkpoint
+	if opts.Resume {
+		if checkpoint, err = internal.LoadCheckpoint(yamlFile); err != nil {
+			report := internal.NewRunReport(yamlFile, "run", overrideVars, start)
+			report.Finish(err)
+			opts.Progress.Emit(internal.RunEndEvent(report))
+			return report, err
+		}
+		if checkpoint != nil {
+			overrideVars = checkpoint.ResumeVariables(overrideVars)
+		} else if !opts.structured() {
+			internal.Output.Eprintf(internal.StatusInfo, "  No failed run of %s to resume; running every step\n", internal.WorkflowName(yamlFile))
+		}
+	}
+
+	report := internal.NewRunReport(yamlFile, "run", overrideVars, start)
+	report.IdempotencyKey = opts.IdempotencyKey
+	opts.Progress.Emit(internal.RunStartEvent(report))
+	trace := opts.Tracer.StartRun()
+	record, err := recordRun(yamlFile, overrideVars, opts, start, func(stdout, stderr io.Writer) error {
+		if opts.machineReadable() {
+			stdout = stderr
+			internal.ReserveStdout()
+		}
+		steps, err := runWorkflowReport(yamlFile, overrideVars, opts, trace, checkpoint, stdout, stderr)
+		report.Steps = steps
+		return err
+	})
+	report.RunID = record.ID
+	report.Finish(err)
+	if traceErr := trace.End(report); traceErr != nil {
+		internal.Output.Eprintf(internal.StatusWarning, "  Warning: %v\n", traceErr)
+	}
+	notifyRun(report)
+	opts.Progress.Emit(internal.RunEndEvent(report))
+	return report, err
+}
+
+// envTracer returns the tracer configured by the OTEL_ variables, or nil; invalid settings
+// are reported and the runs are not traced
+func envTracer() *internal.Tracer {
+	tracer, err := internal.TracerFromEnv()
+	if err != nil {
+		internal.Output.Eprintf(internal.StatusWarning, "  Warning: tracing disabled: %v\n", err)
+	}
+	return tracer
+}
+
+// notifyRun sends the workflow's notifications: and tells the registered notifiers (see
+// internal.RegisterNotifier) about a finished run, warning about those that failed
+func notifyRun(report *internal.RunReport) {
+	errs := internal.NotifyWorkflow(report)
+	for _, err := range append(errs, internal.NotifyRun(report)...) {
+		internal.Output.Eprintf(internal.StatusWarning, "  Warning: %v\n", internal.MaskSecrets(err.Error()))
+	}
+}
+
+// priorRun returns the earlier successful run that this run duplicates, going by its
+// --idempotency-key, after telling the user that it is skipped; nil means run as usual
+func priorRun(yamlFile string, overrideVars map[string]string, opts RunOptions) (*internal.RunRecord, error) {
+	if opts.IdempotencyKey == "" {
+		return nil, nil
+	}
+	records, err := internal.LoadRunHistory()
+	if err != nil {
+		return nil, err
+	}
+	prior, err := internal.FindIdempotentRun(records, opts.IdempotencyKey, yamlFile, overrideVars)
+	if prior != nil && !opts.structured() {
+		internal.Output.Eprintf(internal.StatusSkip, "  Skipped: run %s with idempotency key '%s' already succeeded at %s\n",
+			prior.ID, prior.IdempotencyKey, prior.Start.Local().Format("2006-01-02 15:04:05"))
+	}
+	return prior, err
+}
+
+// recordRun runs fn with the output writers of a run, which also feed the run's log, and
+// records the run in the local history used by `linea stats` and `linea history`
+// History and the automatic log are best-effort and never fail the run
+func recordRun(yamlFile string, overrideVars map[string]string, opts RunOptions, start time.Time, fn func(stdout, stderr io.Writer) error) (internal.RunRecord, error) {
+	runLog, err := createRunLog(yamlFile, opts.LogFile, start)
+	if err != nil {
+		return internal.RunRecord{}, err
+	}
+	stdout, stderr := io.Writer(os.Stdout), io.Writer(os.Stderr)
+	if runLog != nil {
+		runLog.Start(yamlFile, start)
+		stdout, stderr = io.MultiWriter(os.Stdout, runLog), io.MultiWriter(os.Stderr, runLog)
+	}
+
+	records, _ := internal.LoadRunHistory()
+	changes := internal.CheckWorkflowChanges(yamlFile, records)
+	printWorkflowChanges(stderr, yamlFile, changes)
+
+	err = fn(stdout, stderr)
+
+	record := internal.NewRunRecord(yamlFile, overrideVars, start, err)
+	if changes != nil {
+		record.WorkflowHash, record.ChangelogHead = changes.Hash, changes.Head
+	}
+	if opts.IdempotencyKey != "" {
+		record.SetIdempotencyKey(opts.IdempotencyKey)
+	}
+	if runLog != nil {
+		runLog.Finish(start, err)
+		record.LogFile = runLog.Path
+	}
+	internal.AppendRunRecord(record)
+	return record, err
+}
+
+// printWorkflowChanges shows the changelog entries added to a workflow since its last run,
+// so that operators of shared automation see what changed before it runs
+func printWorkflowChanges(w io.Writer, yamlFile string, changes *internal.WorkflowChanges) {
+	if changes == nil || len(changes.Entries) == 0 || internal.Output.Silenced(internal.StatusInfo) {
+		return
+	}
+	fmt.Fprint(w, internal.Output.Sprintf(w, internal.StatusInfo, "%s changed since its last run (%s):\n",
+		internal.WorkflowName(yamlFile), changes.Last.Start.Local().Format("2006-01-02 15:04")))
+	for _, entry := range changes.Entries {
+		fmt.Fprintf(w, "   - %s\n", entry)
+	}
+}
+
+// printRunSummary prints the status, exit code, and duration of every step of a run,
+// followed by the slowest step
+func printRunSummary(summary *internal.RunSummary) {
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "WORKFLOW\tSTEP\tSTATUS\tEXIT CODE\tDURATION")
+	steps := 0
+	for _, step := range summary.Steps {
+		label := "-"
+		if step.Index > 0 {
+			steps++
+			label = strconv.Itoa(step.Index)
+			if step.Name != "" {
+				label += " " + step.Name
+			}
+		}
+		exitCode, duration := "-", "-"
+		if step.Status != internal.StepNotRun && step.Status != internal.StepSkipped && (step.Index > 0 || step.Status == internal.StepFailed) {
+			exitCode, duration = strconv.Itoa(step.ExitCode), formatDurationMs(step.DurationMs)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", step.Workflow, label, step.Status, exitCode, duration)
+	}
+	w.Flush()
+	fmt.Println()
+	if summary.Failures == 0 {
+		internal.Output.Printf(internal.StatusSuccess, "All %d step(s) succeeded in %s\n", steps, formatDurationMs(summary.DurationMs))
+	} else {
+		internal.Output.Printf(internal.StatusError, "%d failure(s) in %s\n", summary.Failures, formatDurationMs(summary.DurationMs))
+	}
+	if slowest := summary.Slowest(); slowest != nil && steps > 1 {
+		label := strconv.Itoa(slowest.Index)
+		if slowest.Name != "" {
+			label += " (" + slowest.Name + ")"
+		}
+		fmt.Printf("   Slowest: %s step %s, %s\n", slowest.Workflow, label, formatDurationMs(slowest.DurationMs))
+	}
+}
+
+// createRunLog opens the log of a run: logFile if set, otherwise a new file in the
+// automatic logs directory, pruned to internal.MaxRunLogs
+// Only a logFile that cannot be created is an error; without it the run is not logged
+func createRunLog(yamlFile, logFile string, start time.Time) (*internal.RunLog, error) {
+	if logFile != "" {
+		return internal.OpenRunLog(logFile)
+	}
+
+	dir := internal.RunLogsDir(yamlFile)
+	runLog, err := internal.CreateRunLog(dir, internal.WorkflowName(yamlFile), start)
+	if err != nil {
+		return nil, nil
+	}
+	internal.PruneRunLogs(dir, internal.MaxRunLogs)
+	return runLog, nil
+}
+
+// runWorkflow parses and executes a resolved workflow file
+func runWorkflow(yamlFile string, overrideVars map[string]string, opts RunOptions, stdout, stderr io.Writer) error {
+	configs, err := prepareWorkflow(yamlFile, overrideVars, opts)
+	if err != nil {
+		return err
+	}
+	lock, err := internal.LockWorkflow(yamlFile, configs, stderr)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	verbose := opts.Verbose
+
 	// If single command, execute normally for backward compatibility
 	if len(configs) == 1 {
+		if internal.StepStatusAfter(configs[0], false, false) == internal.StepSkipped {
+			if verbose {
+				fmt.Fprintf(stdout, "Skipped (on_failure: nothing failed)\n")
+			}
+			return nil
+		}
+		if run, err := internal.ShouldRunStep(configs[0], overrideVars); err != nil {
+			return err
+		} else if !run {
+			if verbose {
+				fmt.Fprintf(stdout, "Skipped (when: %s)\n", configs[0].When)
+			}
+			return nil
+		}
 		cmd, err := internal.BuildCommand(configs[0], overrideVars)
 		if err != nil {
 			return err
 		}
-		
-		if verbose {
-			fmt.Printf("Executing: %s\n", internal.FormatCommand(cmd))
-		}
-		
-		if err := internal.ExecuteCommand(cmd); err != nil {
-			return fmt.Errorf("command execution failed: %w", err)
+		if err := internal.CheckStepPolicy(configs[0], overrideVars); err != nil {
+			return err
 		}
-		return nil
+		return internal.RunCachedStep(configs[0], cmd, overrideVars, stdout, func() error {
+			if err := internal.ConfirmStep(configs[0], overrideVars); err != nil {
+				return err
+			}
+
+			if verbose {
+				fmt.Fprintf(stdout, "Executing: %s\n", internal.FormatCommand(cmd))
+			}
+
+			stdin, err := internal.OpenStepInput(configs[0], overrideVars)
+			if err != nil {
+				return err
+			}
+
+			if err := internal.ExecuteStepWithOutput(configs[0], cmd, stdin, stdout, stderr); err != nil {
+				if cancelled := internal.Cancelled(); cancelled != nil {
+					return cancelled
+				}
+				return fmt.Errorf("command execution failed: %w", err)
+			}
+			return internal.Cancelled()
+		})
 	}
 
 	// Multiple commands - execute sequentially
 	if verbose {
-		fmt.Printf("Found %d commands in YAML file\n", len(configs))
+		fmt.Fprintf(stdout, "Found %d commands in YAML file\n", len(configs))
+	}
+
+	// The run is checkpointed like one with a summary, replacing that of an earlier run
+	checkpoint, _ := internal.NewCheckpoint(yamlFile, overrideVars)
+	err = internal.ExecuteCheckpointedCommands(configs, overrideVars, verbose, stdout, stderr, checkpoint)
+	checkpoint.Finish(err)
+	return err
+}
+
+// runWorkflowReport executes a resolved workflow file, returning the result of every step
+// Each step is recorded in trace, if not nil, and in a checkpoint for --resume: the
+// checkpoint of the run resumed, or a new one for a workflow of several steps
+func runWorkflowReport(yamlFile string, overrideVars map[string]string, opts RunOptions, trace *internal.RunTrace, checkpoint *internal.Checkpoint, stdout, stderr io.Writer) ([]internal.StepResult, error) {
+	configs, err := prepareWorkflow(yamlFile, overrideVars, opts)
+	if err != nil {
+		return []internal.StepResult{}, err
+	}
+	lock, err := internal.LockWorkflow(yamlFile, configs, stderr)
+	if err != nil {
+		return []internal.StepResult{}, err
+	}
+	defer lock.Unlock()
+	if checkpoint != nil {
+		fmt.Fprint(stderr, internal.Output.Sprintf(stderr, internal.StatusInfo, "Resuming %s at step %d of %d (run started at %s)\n",
+			internal.WorkflowName(yamlFile), checkpoint.NextStep(), len(configs), checkpoint.Start.Local().Format("2006-01-02 15:04:05")))
+	} else if len(configs) > 1 {
+		checkpoint, _ = internal.NewCheckpoint(yamlFile, overrideVars)
+	}
+	var display *internal.StepDisplay
+	if opts.Display && !opts.machineReadable() && !internal.Output.Quiet {
+		display = internal.NewStepDisplay(stdout, os.Stdout)
+	}
+	var runner internal.Runner
+	if opts.Chaos != nil {
+		runner = internal.NewChaosRunner(internal.LocalRunner{}, *opts.Chaos)
+	}
+	steps, err := internal.RunStepsReport(configs, overrideVars, internal.ReportOptions{
+		Stdout:     stdout,
+		Stderr:     stderr,
+		Capture:    opts.Capture,
+		Verbose:    opts.Verbose,
+		KeepGoing:  opts.KeepGoing,
+		Progress:   opts.Progress,
+		Workflow:   internal.WorkflowName(yamlFile),
+		Display:    display,
+		Runner:     runner,
+		Middleware: []internal.Middleware{trace.Middleware()},
+		Checkpoint: checkpoint,
+	})
+	checkpoint.Finish(err)
+	return steps, err
+}
+
+// prepareWorkflow parses a resolved workflow file and checks that it may run now
+func prepareWorkflow(yamlFile string, overrideVars map[string]string, opts RunOptions) ([]*internal.CommandConfig, error) {
+	// A workflow file that changed since it was signed is not even parsed, nor are the
+	// files its workflow: and use: steps load
+	if opts.RequireSigned {
+		if _, err := internal.VerifyWorkflow(yamlFile); err != nil {
+			return nil, err
+		}
+		internal.RequireSignatures = true
+	}
+
+	configs, err := internal.ParseMultiYAML(yamlFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse YAML file: %w", err)
+	}
+	// A foreach: step runs once per item, so it counts as that many steps from here on
+	if configs, err = internal.ExpandForeach(configs, overrideVars); err != nil {
+		return nil, err
+	}
+
+	// Maintenance windows are checked for every step before anything runs
+	errs, err := internal.CheckTimeWindows(configs, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		if !opts.Force {
+			return nil, fmt.Errorf("%w (use --force to run anyway)", errs[0])
+		}
+		for _, err := range errs {
+			internal.Output.Eprintf(internal.StatusWarning, "%v, running anyway (--force)\n", err)
+		}
 	}
 
-	return internal.ExecuteMultipleCommands(configs, overrideVars, false, verbose)
+	// Policies are audited for every step before anything runs, and enforced again as each
+	// step runs with its final arguments
+	policies, err := internal.LoadPolicies()
+	if err != nil {
+		return nil, err
+	}
+	if len(policies) == 0 && opts.EnforcePolicy {
+		return nil, fmt.Errorf("--enforce-policy: no policy file found (%s)", strings.Join(internal.PolicyFilePaths(), ", "))
+	}
+	if len(policies) > 0 {
+		violations := internal.AuditPolicies(policies, configs, overrideVars)
+		if internal.PoliciesEnforced(policies, opts.EnforcePolicy) {
+			if len(violations) > 1 {
+				return nil, fmt.Errorf("%w (and %d more steps)", violations[0], len(violations)-1)
+			}
+			if len(violations) == 1 {
+				return nil, violations[0]
+			}
+			internal.EnforcePolicies(policies)
+		} else {
+			for _, err := range violations {
+				internal.Output.Eprintf(internal.StatusWarning, "%v (not enforced; use --enforce-policy)\n", err)
+			}
+		}
+	}
+
+	// Steps share downloads through {cache_dir}, which must exist before they write to it
+	if err := internal.EnsureSharedCacheDir(); err != nil {
+		return nil, err
+	}
+	return configs, nil
 }
 
 // ParseArgs parses -s/--set flags from command line arguments
@@ -74,23 +577,69 @@ func ParseArgs(args []string) (map[string]string, []string) {
 	return vars, remainingArgs
 }
 
+// printFailureHints prints remediation hints for a failed run beneath the raw error
+func printFailureHints(err error) {
+	rules, loadErr := internal.LoadHintRules()
+	if loadErr != nil {
+		internal.Output.Eprintf(internal.StatusWarning, "  %v\n", loadErr)
+		rules = internal.BuiltinHintRules
+	}
+
+	for _, hint := range internal.DiagnoseFailure(err, rules) {
+		internal.Output.Eprintf(internal.StatusHint, "  Hint: %s\n", hint.Message)
+	}
+}
+
+// failedExitCode returns the exit status of a failed run: 128 plus the signal number
+// (130 for Ctrl+C) if a signal cancelled it, and otherwise code
+func failedExitCode(err error, code int) int {
+	if internal.IsCancelled(err) {
+		return internal.ExitCode(err)
+	}
+	return code
+}
+
 // RunCommandMain is the entry point for the run subcommand
 func RunCommandMain(args []string) {
 	if len(args) < 1 {
 		fmt.Fprintf(os.Stderr, "\n")
-		fmt.Fprintf(os.Stderr, "  ❌ Error: no YAML file specified\n")
+		internal.Output.Eprintf(internal.StatusError, "  Error: no YAML file specified\n")
 		fmt.Fprintf(os.Stderr, "\n")
 		fmt.Fprintf(os.Stderr, "  USAGE:\n")
-		fmt.Fprintf(os.Stderr, "    linea run [options] <yaml-file>\n")
+		fmt.Fprintf(os.Stderr, "    linea run [options] <yaml-file|workflow-name>...\n")
+		fmt.Fprintf(os.Stderr, "    linea run [options] <workflow> [input...]   (a workflow with inputs:)\n")
 		fmt.Fprintf(os.Stderr, "\n")
 		fmt.Fprintf(os.Stderr, "  OPTIONS:\n")
 		fmt.Fprintf(os.Stderr, "    -v, --verbose              Show the command before executing\n")
 		fmt.Fprintf(os.Stderr, "    -s, --set <var>=<value>     Set variable values (can be used multiple times)\n")
+		fmt.Fprintf(os.Stderr, "    --set-json <var>=<json>     Set a list or map variable, e.g. targets='[\"web1\",\"web2\"]'\n")
+		fmt.Fprintf(os.Stderr, "    --force                    Run steps outside their allowed_hours/allowed_days window\n")
+		fmt.Fprintf(os.Stderr, "    -y, --yes                  Answer the confirm: prompts of steps with yes\n")
+		fmt.Fprintf(os.Stderr, "    --enforce-policy           Refuse to run commands the policy files do not allow\n")
+		fmt.Fprintf(os.Stderr, "    --require-signed           Refuse to run workflow files that do not match their signature\n")
+		fmt.Fprintf(os.Stderr, "    --output <text|json|yaml>  Print a machine-readable report of the run\n")
+		fmt.Fprintf(os.Stderr, "    --capture                  Include each step's stdout/stderr in the report\n")
+		fmt.Fprintf(os.Stderr, "    --log-file <path>          Write the run's output to path instead of .linea/logs\n")
+		fmt.Fprintf(os.Stderr, "    --idempotency-key <key>    Skip the run if one with this key and inputs succeeded\n")
+		fmt.Fprintf(os.Stderr, "    --resume                   Continue the last failed run from its first unfinished step\n")
+		fmt.Fprintf(os.Stderr, "    --no-cache                 Run the steps with cache: even if their inputs are unchanged\n")
+		fmt.Fprintf(os.Stderr, "    --fail-fast                Stop at the first failed step (default)\n")
+		fmt.Fprintf(os.Stderr, "    --keep-going               Run every step of every file; exit with the failure count\n")
+		fmt.Fprintf(os.Stderr, "    --summary <text|json|none> Per-step timing summary (default: text after multi-step runs)\n")
+		fmt.Fprintf(os.Stderr, "    --progress                 Show the step running with its elapsed time\n")
+		fmt.Fprintf(os.Stderr, "    --progress-fd <n>          Write NDJSON progress events to file descriptor n (3 or above)\n")
+		fmt.Fprintf(os.Stderr, "    --progress-file <path>     Append NDJSON progress events to a file or named pipe\n")
+		fmt.Fprintf(os.Stderr, "    --grace-period <duration>  Time a command has to exit after Ctrl+C before it is killed (default 10s)\n")
+		fmt.Fprintf(os.Stderr, "    --chaos <faults>           Inject random faults, e.g. fail=10%%,delay=25%%,timeout=5%%,seed=42\n")
 		fmt.Fprintf(os.Stderr, "\n")
 		fmt.Fprintf(os.Stderr, "  EXAMPLES:\n")
 		fmt.Fprintf(os.Stderr, "    linea run config.yml\n")
 		fmt.Fprintf(os.Stderr, "    linea run -v config.yml\n")
 		fmt.Fprintf(os.Stderr, "    linea run config.yml -s name=\"John\"\n")
+		fmt.Fprintf(os.Stderr, "    linea run config.yml --output json --capture\n")
+		fmt.Fprintf(os.Stderr, "    linea run --keep-going build.yml test.yml\n")
+		fmt.Fprintf(os.Stderr, "    linea run deploy --summary json\n")
+		fmt.Fprintf(os.Stderr, "    linea run deploy prod eu-west-1\n")
 		fmt.Fprintf(os.Stderr, "\n")
 		os.Exit(1)
 	}
@@ -98,35 +647,232 @@ func RunCommandMain(args []string) {
 	// Parse -s/--set flags first
 	overrideVars, remainingArgs := ParseArgs(args)
 	
-	verbose := false
-	yamlFile := ""
+	opts := RunOptions{Verbose: internal.CurrentUserConfig().Verbose, GracePeriod: internal.DefaultGracePeriod, Tracer: envTracer()}
+	output, remainingArgs, err := parseOutputFlag(remainingArgs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\n")
+		internal.Output.Eprintf(internal.StatusError, "  Error: %v\n", err)
+		fmt.Fprintf(os.Stderr, "\n")
+		os.Exit(1)
+	}
+	opts.Output = output
+	var yamlFiles []string
 	
 	// Parse other flags
-	for _, arg := range remainingArgs {
+	for i := 0; i < len(remainingArgs); i++ {
+		arg := remainingArgs[i]
 		if arg == "-v" || arg == "--verbose" {
-			verbose = true
+			opts.Verbose = true
+		} else if arg == "--force" {
+			opts.Force = true
+		} else if arg == "-y" || arg == "--yes" {
+			opts.Yes = true
+		} else if arg == "--enforce-policy" {
+			opts.EnforcePolicy = true
+		} else if arg == "--require-signed" {
+			opts.RequireSigned = true
+		} else if arg == "--capture" {
+			opts.Capture = true
+		} else if arg == "--resume" {
+			opts.Resume = true
+		} else if arg == "--no-cache" {
+			opts.NoCache = true
+		} else if arg == "--log-file" && i+1 < len(remainingArgs) {
+			i++
+			opts.LogFile = remainingArgs[i]
+		} else if strings.HasPrefix(arg, "--log-file=") {
+			opts.LogFile = strings.TrimPrefix(arg, "--log-file=")
+		} else if arg == "--idempotency-key" && i+1 < len(remainingArgs) {
+			i++
+			opts.IdempotencyKey = remainingArgs[i]
+		} else if strings.HasPrefix(arg, "--idempotency-key=") {
+			opts.IdempotencyKey = strings.TrimPrefix(arg, "--idempotency-key=")
+		} else if arg == "--grace-period" || strings.HasPrefix(arg, "--grace-period=") {
+			value := strings.TrimPrefix(arg, "--grace-period=")
+			if arg == "--grace-period" {
+				if i+1 >= len(remainingArgs) {
+					fmt.Fprintf(os.Stderr, "\n")
+					internal.Output.Eprintf(internal.StatusError, "  Error: --grace-period requires a duration (e.g. 30s)\n")
+					fmt.Fprintf(os.Stderr, "\n")
+					os.Exit(1)
+				}
+				i++
+				value = remainingArgs[i]
+			}
+			grace, err := internal.ParseAge(value)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "\n")
+				internal.Output.Eprintf(internal.StatusError, "  Error: --grace-period: %v\n", err)
+				fmt.Fprintf(os.Stderr, "\n")
+				os.Exit(1)
+			}
+			opts.GracePeriod = grace
+		} else if (arg == "--chaos" && i+1 < len(remainingArgs)) || strings.HasPrefix(arg, "--chaos=") {
+			value := strings.TrimPrefix(arg, "--chaos=")
+			if arg == "--chaos" {
+				i++
+				value = remainingArgs[i]
+			}
+			chaos, err := internal.ParseChaos(value)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "\n")
+				internal.Output.Eprintf(internal.StatusError, "  Error: --chaos: %v\n", err)
+				fmt.Fprintf(os.Stderr, "\n")
+				os.Exit(1)
+			}
+			opts.Chaos = &chaos
+		} else if arg == "--progress" {
+			opts.Display = true
+		} else if arg == "--keep-going" {
+			opts.KeepGoing = true
+		} else if arg == "--fail-fast" {
+			opts.KeepGoing = false
+		} else if arg == "--summary" || strings.HasPrefix(arg, "--summary=") {
+			value := strings.TrimPrefix(arg, "--summary=")
+			if arg == "--summary" {
+				if i+1 >= len(remainingArgs) {
+					fmt.Fprintf(os.Stderr, "\n")
+					internal.Output.Eprintf(internal.StatusError, "  Error: --summary requires a format (text, json, or none)\n")
+					fmt.Fprintf(os.Stderr, "\n")
+					os.Exit(1)
+				}
+				i++
+				value = remainingArgs[i]
+			}
+			summary, err := internal.ParseSummaryFormat(value)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "\n")
+				internal.Output.Eprintf(internal.StatusError, "  Error: %v\n", err)
+				fmt.Fprintf(os.Stderr, "\n")
+				os.Exit(1)
+			}
+			opts.Summary = summary
+		} else if (arg == "--progress-fd" || arg == "--progress-file") && i+1 < len(remainingArgs) {
+			i++
+			var progress *internal.Progress
+			if arg == "--progress-fd" {
+				progress, err = internal.ProgressToFD(remainingArgs[i])
+			} else {
+				progress, err = internal.OpenProgressFile(remainingArgs[i])
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "\n")
+				internal.Output.Eprintf(internal.StatusError, "  Error: %v\n", err)
+				fmt.Fprintf(os.Stderr, "\n")
+				os.Exit(1)
+			}
+			opts.Progress = progress
 		} else if !strings.HasPrefix(arg, "-") {
-			yamlFile = arg
+			yamlFiles = append(yamlFiles, arg)
 		}
 	}
 
-	if yamlFile == "" {
+	if len(yamlFiles) == 0 {
 		fmt.Fprintf(os.Stderr, "\n")
-		fmt.Fprintf(os.Stderr, "  ❌ Error: no YAML file specified\n")
+		internal.Output.Eprintf(internal.StatusError, "  Error: no YAML file specified\n")
 		fmt.Fprintf(os.Stderr, "\n")
 		fmt.Fprintf(os.Stderr, "  USAGE:\n")
-		fmt.Fprintf(os.Stderr, "    linea run [options] <yaml-file>\n")
+		fmt.Fprintf(os.Stderr, "    linea run [options] <yaml-file|workflow-name>...\n")
+		fmt.Fprintf(os.Stderr, "    linea run [options] <workflow> [input...]   (a workflow with inputs:)\n")
 		fmt.Fprintf(os.Stderr, "\n")
 		fmt.Fprintf(os.Stderr, "  OPTIONS:\n")
 		fmt.Fprintf(os.Stderr, "    -v, --verbose              Show the command before executing\n")
 		fmt.Fprintf(os.Stderr, "    -s, --set <var>=<value>     Set variable values (can be used multiple times)\n")
+		fmt.Fprintf(os.Stderr, "    --set-json <var>=<json>     Set a list or map variable, e.g. targets='[\"web1\",\"web2\"]'\n")
+		fmt.Fprintf(os.Stderr, "    --force                    Run steps outside their allowed_hours/allowed_days window\n")
+		fmt.Fprintf(os.Stderr, "    -y, --yes                  Answer the confirm: prompts of steps with yes\n")
+		fmt.Fprintf(os.Stderr, "    --enforce-policy           Refuse to run commands the policy files do not allow\n")
+		fmt.Fprintf(os.Stderr, "    --require-signed           Refuse to run workflow files that do not match their signature\n")
+		fmt.Fprintf(os.Stderr, "    --output <text|json|yaml>  Print a machine-readable report of the run\n")
+		fmt.Fprintf(os.Stderr, "    --capture                  Include each step's stdout/stderr in the report\n")
+		fmt.Fprintf(os.Stderr, "    --log-file <path>          Write the run's output to path instead of .linea/logs\n")
+		fmt.Fprintf(os.Stderr, "    --idempotency-key <key>    Skip the run if one with this key and inputs succeeded\n")
+		fmt.Fprintf(os.Stderr, "    --resume                   Continue the last failed run from its first unfinished step\n")
+		fmt.Fprintf(os.Stderr, "    --no-cache                 Run the steps with cache: even if their inputs are unchanged\n")
+		fmt.Fprintf(os.Stderr, "    --fail-fast                Stop at the first failed step (default)\n")
+		fmt.Fprintf(os.Stderr, "    --keep-going               Run every step of every file; exit with the failure count\n")
+		fmt.Fprintf(os.Stderr, "    --summary <text|json|none> Per-step timing summary (default: text after multi-step runs)\n")
+		fmt.Fprintf(os.Stderr, "    --progress                 Show the step running with its elapsed time\n")
+		fmt.Fprintf(os.Stderr, "    --progress-fd <n>          Write NDJSON progress events to file descriptor n (3 or above)\n")
+		fmt.Fprintf(os.Stderr, "    --progress-file <path>     Append NDJSON progress events to a file or named pipe\n")
+		fmt.Fprintf(os.Stderr, "    --grace-period <duration>  Time a command has to exit after Ctrl+C before it is killed (default 10s)\n")
+		fmt.Fprintf(os.Stderr, "    --chaos <faults>           Inject random faults, e.g. fail=10%%,delay=25%%,timeout=5%%,seed=42\n")
 		fmt.Fprintf(os.Stderr, "\n")
 		os.Exit(1)
 	}
 
-	if err := RunCommand(yamlFile, verbose, overrideVars); err != nil {
+	// A workflow with inputs: takes the arguments after it as their values
+	if vars, bound, err := bindWorkflowInputs(yamlFiles[0], yamlFiles[1:], overrideVars); err != nil {
+		fmt.Fprintf(os.Stderr, "\n")
+		internal.Output.Eprintf(internal.StatusError, "  Error: %v\n", err)
+		fmt.Fprintf(os.Stderr, "\n")
+		os.Exit(1)
+	} else if bound {
+		yamlFiles, overrideVars = yamlFiles[:1], vars
+	}
+
+	if opts.structured() && opts.Summary != "" && opts.Summary != internal.SummaryNone {
+		fmt.Fprintf(os.Stderr, "\n")
+		internal.Output.Eprintf(internal.StatusError, "  Error: --summary cannot be combined with --output (the report already has each step's duration)\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		os.Exit(1)
+	}
+
+	if opts.LogFile != "" && len(yamlFiles) > 1 {
+		fmt.Fprintf(os.Stderr, "\n")
+		internal.Output.Eprintf(internal.StatusError, "  Error: --log-file can only be used with a single workflow\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		os.Exit(1)
+	}
+
+	if opts.IdempotencyKey != "" && len(yamlFiles) > 1 {
+		fmt.Fprintf(os.Stderr, "\n")
+		internal.Output.Eprintf(internal.StatusError, "  Error: --idempotency-key can only be used with a single workflow\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		os.Exit(1)
+	}
+
+	if opts.Chaos != nil {
+		internal.Output.Eprintf(internal.StatusWarning, "  Chaos mode: injecting faults (%s)\n", opts.Chaos)
+	}
+
+	// The workflows named in depends_on: run first, each once
+	yamlFiles, err = internal.RunOrder(yamlFiles)
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
+
+	// Ctrl+C and SIGTERM cancel the run, which still runs its after: and on_failure: steps
+	internal.HandleSignals(opts.GracePeriod)
+
+	// Exit codes: 0 when every step succeeded; 1 when a step failed (or the run could not
+	// start); with --keep-going, the number of failures, capped at 125; 130 or 143 when
+	// Ctrl+C or SIGTERM cancelled the run
+	if len(yamlFiles) == 1 && !opts.KeepGoing {
+		if err := RunCommand(yamlFiles[0], overrideVars, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			printFailureHints(err)
+			internal.Output.Eprintf(internal.StatusRetry, "  Retry with: linea rerun last\n")
+			if path, resolveErr := internal.ResolveWorkflowPath(yamlFiles[0]); resolveErr == nil {
+				if _, statErr := os.Stat(internal.CheckpointPath(path)); statErr == nil {
+					internal.Output.Eprintf(internal.StatusRetry, "  Or continue from the failed step: linea run %s --resume\n", yamlFiles[0])
+				}
+			}
+			os.Exit(failedExitCode(err, 1))
+		}
+		return
+	}
+
+	failures, err := RunBatchCommand(yamlFiles, overrideVars, opts)
+	if err == nil {
+		return
+	}
+	if opts.KeepGoing {
+		os.Exit(failedExitCode(err, internal.FailureExitCode(failures)))
+	}
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	printFailureHints(err)
+	os.Exit(failedExitCode(err, 1))
 }
 
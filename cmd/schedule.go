@@ -0,0 +1,227 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"linea/internal"
+)
+
+// ScheduleStartCommand runs the scheduler in the foreground until interrupted
+// With logFile set, the scheduler's messages are appended to it instead of standard output
+// With metricsAddr set, Prometheus metrics of the runs are served at /metrics on that address
+func ScheduleStartCommand(logFile, metricsAddr string) error {
+	lineaPath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate the linea executable: %w", err)
+	}
+
+	out := os.Stdout
+	if logFile != "" {
+		if err := os.MkdirAll(filepath.Dir(logFile), 0755); err != nil {
+			return fmt.Errorf("failed to create log directory: %w", err)
+		}
+		out, err = os.OpenFile(logFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open log file: %w", err)
+		}
+		defer out.Close()
+		internal.Output.Out, internal.Output.Err = out, out
+	}
+
+	entries, err := internal.LoadSchedules()
+	if err != nil {
+		return err
+	}
+	internal.Output.Printf(internal.StatusInfo, "Scheduler started with %d schedule(s) from %s (Ctrl+C to stop)\n", len(entries), internal.SchedulesFilePath())
+
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		internal.Output.Printf(internal.StatusStop, "Stopping scheduler, waiting for running workflows...\n")
+		close(stop)
+	}()
+
+	logf := func(status internal.Status, format string, args ...interface{}) {
+		fmt.Fprintf(out, "[%s] %s\n", time.Now().Format("2006-01-02 15:04:05"), internal.Output.Sprintf(out, status, format, args...))
+	}
+	scheduler := &internal.Scheduler{
+		LineaPath: lineaPath,
+		Logf:      logf,
+		Shipper:   &internal.LogShipper{Dir: internal.ShipQueueDir(), Logf: logf},
+	}
+	if metricsAddr != "" {
+		scheduler.Metrics = &internal.Metrics{Queues: map[string]func() int{"ship_logs": scheduler.Shipper.QueueDepth}}
+		listener, err := net.Listen("tcp", metricsAddr)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %w", metricsAddr, err)
+		}
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", scheduler.Metrics)
+		go http.Serve(listener, mux)
+		internal.Output.Printf(internal.StatusInfo, "Serving metrics on http://%s/metrics\n", listener.Addr())
+	}
+	scheduler.Run(stop)
+	return nil
+}
+
+// ScheduleListCommand prints the registered schedules with their next run
+func ScheduleListCommand() error {
+	entries, err := internal.LoadSchedules()
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		fmt.Println("No schedules registered (add one with: linea schedule add <workflow>)")
+		return nil
+	}
+
+	now := time.Now()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tCRON\tNEXT RUN\tWORKFLOW")
+	for _, entry := range entries {
+		cron := entry.Cron
+		next := "-"
+		schedule, location, err := entry.Resolve()
+		if err != nil {
+			next = "error: " + err.Error()
+		} else if at := schedule.Next(now.In(location)); !at.IsZero() {
+			next = at.Format("2006-01-02 15:04 MST")
+		}
+		if cron == "" {
+			fileCron, _, _ := internal.WorkflowSchedule(entry.Workflow)
+			cron = fileCron + " (schedule:)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", entry.ID, cron, next, entry.Workflow)
+	}
+	return w.Flush()
+}
+
+// ScheduleAddCommand registers a workflow with the scheduler
+// cron overrides the workflow's schedule: field
+func ScheduleAddCommand(workflow, cron string, vars map[string]string) error {
+	path, err := internal.ResolveWorkflowPath(workflow)
+	if err != nil {
+		return err
+	}
+
+	entry, err := internal.AddSchedule(path, cron, vars)
+	if err != nil {
+		return err
+	}
+
+	internal.Output.Printf(internal.StatusSuccess, "Scheduled %s as '%s'\n", entry.Workflow, entry.ID)
+	if schedule, location, err := entry.Resolve(); err == nil {
+		if next := schedule.Next(time.Now().In(location)); !next.IsZero() {
+			fmt.Printf("   Next run: %s\n", next.Format("2006-01-02 15:04 MST"))
+		}
+	}
+	fmt.Printf("   Start the scheduler with: linea schedule start\n")
+	return nil
+}
+
+// ScheduleRemoveCommand unregisters a schedule
+func ScheduleRemoveCommand(id string) error {
+	entry, err := internal.RemoveSchedule(id)
+	if err != nil {
+		return err
+	}
+	internal.Output.Printf(internal.StatusSuccess, "Removed schedule '%s' (%s)\n", entry.ID, entry.Workflow)
+	return nil
+}
+
+// ScheduleCommandMain is the entry point for the schedule subcommand
+func ScheduleCommandMain(args []string) {
+	if len(args) < 1 {
+		printScheduleUsage("no schedule subcommand specified")
+		os.Exit(1)
+	}
+
+	var err error
+	switch args[0] {
+	case "start":
+		logFile, metricsAddr := "", os.Getenv(internal.MetricsAddrEnv)
+		for i := 1; i < len(args); i++ {
+			if args[i] == "--log-file" && i+1 < len(args) {
+				logFile = args[i+1]
+				i++
+			} else if args[i] == "--metrics-addr" && i+1 < len(args) {
+				metricsAddr = args[i+1]
+				i++
+			}
+		}
+		err = ScheduleStartCommand(logFile, metricsAddr)
+	case "list":
+		err = ScheduleListCommand()
+	case "add":
+		vars, remainingArgs := ParseArgs(args[1:])
+		workflow := ""
+		cron := ""
+		for i := 0; i < len(remainingArgs); i++ {
+			switch {
+			case remainingArgs[i] == "--cron":
+				if i+1 >= len(remainingArgs) {
+					printScheduleUsage("--cron needs a cron expression")
+					os.Exit(1)
+				}
+				cron = remainingArgs[i+1]
+				i++
+			case !strings.HasPrefix(remainingArgs[i], "-"):
+				workflow = remainingArgs[i]
+			}
+		}
+		if workflow == "" {
+			printScheduleUsage("no workflow specified")
+			os.Exit(1)
+		}
+		err = ScheduleAddCommand(workflow, cron, vars)
+	case "remove":
+		if len(args) < 2 {
+			printScheduleUsage("no schedule ID specified")
+			os.Exit(1)
+		}
+		err = ScheduleRemoveCommand(args[1])
+	default:
+		printScheduleUsage(fmt.Sprintf("unknown schedule subcommand '%s'", args[0]))
+		os.Exit(1)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// printScheduleUsage prints the usage of the schedule subcommand with an error message
+func printScheduleUsage(message string) {
+	fmt.Fprintf(os.Stderr, "\n")
+	internal.Output.Eprintf(internal.StatusError, "  Error: %s\n", message)
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "  USAGE:\n")
+	fmt.Fprintf(os.Stderr, "    linea schedule add [options] <workflow>    Register a workflow with the scheduler\n")
+	fmt.Fprintf(os.Stderr, "    linea schedule list                        List schedules and their next run\n")
+	fmt.Fprintf(os.Stderr, "    linea schedule remove <id>                 Unregister a schedule\n")
+	fmt.Fprintf(os.Stderr, "    linea schedule start                       Run scheduled workflows until interrupted\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "  OPTIONS:\n")
+	fmt.Fprintf(os.Stderr, "    --cron <expr>              Cron expression (defaults to the workflow's schedule: field)\n")
+	fmt.Fprintf(os.Stderr, "    -s, --set <var>=<value>     Variable passed on every run (can be used multiple times)\n")
+	fmt.Fprintf(os.Stderr, "    --log-file <path>          With start, append the scheduler's messages to path\n")
+	fmt.Fprintf(os.Stderr, "    --metrics-addr <addr>      With start, serve Prometheus metrics at /metrics (default $LINEA_METRICS_ADDR)\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "  NOTE:\n")
+	fmt.Fprintf(os.Stderr, "    Schedules are stored in %s\n", internal.SchedulesFilePath())
+	fmt.Fprintf(os.Stderr, "    Each run is logged to .linea/logs/ of the workflow's project\n")
+	fmt.Fprintf(os.Stderr, "    Keep the scheduler running across reboots with: linea service install\n")
+	fmt.Fprintf(os.Stderr, "\n")
+}
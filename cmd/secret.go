@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"linea/internal"
+)
+
+// SecretSetCommand stores a secret in the encrypted secrets file
+func SecretSetCommand(name, value string) error {
+	path := internal.SecretsFilePath()
+	err := internal.UpdateSecretStore(path, func(secrets map[string]string) error {
+		secrets[name] = value
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	internal.Output.Printf(internal.StatusSuccess, "Stored secret '%s' in %s\n", name, path)
+	return nil
+}
+
+// SecretGetCommand prints a secret from the encrypted secrets file
+func SecretGetCommand(name string) error {
+	path := internal.SecretsFilePath()
+	store, err := internal.LoadSecretStore(path)
+	if err != nil {
+		return err
+	}
+
+	value, ok := store[name]
+	if !ok {
+		return fmt.Errorf("secret %s not found in %s", name, path)
+	}
+
+	fmt.Println(value)
+	return nil
+}
+
+// SecretListCommand prints the names of all secrets in the encrypted secrets file
+func SecretListCommand() error {
+	path := internal.SecretsFilePath()
+	store, err := internal.LoadSecretStore(path)
+	if err != nil {
+		return err
+	}
+
+	if len(store) == 0 {
+		fmt.Printf("No secrets stored in %s\n", path)
+		return nil
+	}
+
+	names := make([]string, 0, len(store))
+	for name := range store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Println(name)
+	}
+	return nil
+}
+
+// SecretCommandMain is the entry point for the secret subcommand
+func SecretCommandMain(args []string) {
+	if len(args) < 1 {
+		printSecretUsage("no secret subcommand specified")
+		os.Exit(1)
+	}
+
+	var err error
+	switch args[0] {
+	case "set":
+		if len(args) < 2 {
+			printSecretUsage("no secret name specified")
+			os.Exit(1)
+		}
+		value := ""
+		if len(args) >= 3 {
+			value = args[2]
+		} else {
+			// Read the value from stdin so it does not end up in shell history
+			reader := bufio.NewReader(os.Stdin)
+			line, readErr := reader.ReadString('\n')
+			if readErr != nil && line == "" {
+				fmt.Fprintf(os.Stderr, "Error: failed to read secret value from stdin: %v\n", readErr)
+				os.Exit(1)
+			}
+			value = strings.TrimRight(line, "\r\n")
+		}
+		err = SecretSetCommand(args[1], value)
+	case "get":
+		if len(args) < 2 {
+			printSecretUsage("no secret name specified")
+			os.Exit(1)
+		}
+		err = SecretGetCommand(args[1])
+	case "list":
+		err = SecretListCommand()
+	default:
+		printSecretUsage(fmt.Sprintf("unknown secret subcommand '%s'", args[0]))
+		os.Exit(1)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// printSecretUsage prints the usage of the secret subcommand with an error message
+func printSecretUsage(message string) {
+	fmt.Fprintf(os.Stderr, "\n")
+	internal.Output.Eprintf(internal.StatusError, "  Error: %s\n", message)
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "  USAGE:\n")
+	fmt.Fprintf(os.Stderr, "    linea secret set <name> [value]    Store a secret (reads stdin if value is omitted)\n")
+	fmt.Fprintf(os.Stderr, "    linea secret get <name>            Print a stored secret\n")
+	fmt.Fprintf(os.Stderr, "    linea secret list                  List stored secret names\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "  NOTE:\n")
+	fmt.Fprintf(os.Stderr, "    Secrets are encrypted in .linea/secrets.enc using the %s passphrase\n", internal.SecretsKeyEnv)
+	fmt.Fprintf(os.Stderr, "\n")
+}
@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"linea/internal"
+)
+
+// DefaultServeAddr is where linea serve listens without --addr
+const DefaultServeAddr = "127.0.0.1:8080"
+
+// ServeCommand serves the HTTP API and the webhook triggers of the global config on addr
+// until interrupted, then waits for the runs in progress. A token, and a secret for every
+// trigger, are required to listen on anything but a loopback address. With ui, the web UI
+// is served at /
+func ServeCommand(addr, token string, ui bool) error {
+	config, err := internal.LoadUserConfig()
+	if err != nil {
+		return err
+	}
+	if err := internal.CheckTriggers(config.Triggers); err != nil {
+		return fmt.Errorf("%s: %w", internal.ConfigFilePath(), err)
+	}
+	if !isLoopbackAddr(addr) {
+		if token == "" {
+			return fmt.Errorf("refusing to serve on %s without a token (set --token or LINEA_SERVE_TOKEN)", addr)
+		}
+		for _, trigger := range config.Triggers {
+			if trigger.Secret == "" {
+				return fmt.Errorf("refusing to serve trigger '%s' on %s without a secret", trigger.Name, addr)
+			}
+		}
+	}
+	lineaPath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate the linea executable: %w", err)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current directory: %w", err)
+	}
+
+	logf := func(status internal.Status, format string, args ...interface{}) {
+		fmt.Printf("[%s] %s\n", time.Now().Format("2006-01-02 15:04:05"), internal.Output.Sprintf(os.Stdout, status, format, args...))
+	}
+	server := &internal.Server{
+		LineaPath: lineaPath,
+		Dirs:      internal.WorkflowSearchDirs(internal.FindWorkflowsDir(cwd)),
+		Token:     token,
+		Triggers:  config.Triggers,
+		UI:        ui,
+		Metrics:   &internal.Metrics{},
+		Logf:      logf,
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	httpServer := &http.Server{Handler: server.Handler(), ReadHeaderTimeout: 10 * time.Second}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		internal.Output.Printf(internal.StatusStop, "Stopping server, waiting for running workflows...\n")
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		httpServer.Shutdown(ctx)
+	}()
+
+	internal.Output.Printf(internal.StatusInfo, "Serving %d workflow(s) on http://%s (Ctrl+C to stop)\n", len(server.Workflows()), listener.Addr())
+	if ui {
+		internal.Output.Printf(internal.StatusInfo, "Web UI: http://%s/\n", listener.Addr())
+	}
+	for _, trigger := range config.Triggers {
+		internal.Output.Printf(internal.StatusInfo, "Trigger %s runs %s: POST http://%s/hooks/%s\n", trigger.Name, trigger.Workflow, listener.Addr(), trigger.Name)
+	}
+	if err := httpServer.Serve(listener); err != http.ErrServerClosed {
+		return err
+	}
+	server.Wait()
+	return nil
+}
+
+// isLoopbackAddr reports whether a listen address only accepts local connections
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// ServeCommandMain is the entry point for the serve subcommand
+func ServeCommandMain(args []string) {
+	addr := DefaultServeAddr
+	token := os.Getenv("LINEA_SERVE_TOKEN")
+	ui := false
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--addr" && i+1 < len(args):
+			i++
+			addr = args[i]
+		case args[i] == "--token" && i+1 < len(args):
+			i++
+			token = args[i]
+		case args[i] == "--ui":
+			ui = true
+		case strings.HasPrefix(args[i], "-"):
+			printServeUsage(fmt.Sprintf("unknown option '%s'", args[i]))
+			os.Exit(1)
+		default:
+			printServeUsage(fmt.Sprintf("unexpected argument '%s'", args[i]))
+			os.Exit(1)
+		}
+	}
+
+	if err := ServeCommand(addr, token, ui); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// printServeUsage prints the usage of the serve subcommand with an error message
+func printServeUsage(message string) {
+	fmt.Fprintf(os.Stderr, "\n")
+	internal.Output.Eprintf(internal.StatusError, "  Error: %s\n", message)
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "  USAGE:\n")
+	fmt.Fprintf(os.Stderr, "    linea serve [options]\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "  OPTIONS:\n")
+	fmt.Fprintf(os.Stderr, "    --addr <host:port>     Address to listen on (default %s)\n", DefaultServeAddr)
+	fmt.Fprintf(os.Stderr, "    --token <token>        Require Authorization: Bearer <token> (default $LINEA_SERVE_TOKEN)\n")
+	fmt.Fprintf(os.Stderr, "    --ui                   Serve the web UI at /\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "  EXAMPLES:\n")
+	fmt.Fprintf(os.Stderr, "    linea serve --ui\n")
+	fmt.Fprintf(os.Stderr, "    LINEA_SERVE_TOKEN=secret linea serve --addr :8080\n")
+	fmt.Fprintf(os.Stderr, "\n")
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+
+	"linea/internal"
+)
+
+// ServiceCommand installs, starts, stops, or uninstalls the scheduler service
+func ServiceCommand(action string) error {
+	service, err := internal.PlatformService()
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "install":
+		lineaPath, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("failed to locate the linea executable: %w", err)
+		}
+		if err := service.Install(lineaPath); err != nil {
+			return err
+		}
+		internal.Output.Printf(internal.StatusSuccess, "Installed and started the scheduler service (%s)\n", service.Manager)
+		if !internal.Output.Quiet {
+			printServiceDetails(service)
+		}
+	case "start":
+		if err := service.Start(); err != nil {
+			return err
+		}
+		internal.Output.Printf(internal.StatusStart, "Started the scheduler service\n")
+	case "stop":
+		if err := service.Stop(); err != nil {
+			return err
+		}
+		internal.Output.Printf(internal.StatusStop, "Stopped the scheduler service (it starts again at the next boot)\n")
+	case "uninstall":
+		if err := service.Uninstall(); err != nil {
+			return err
+		}
+		internal.Output.Printf(internal.StatusSuccess, "Uninstalled the scheduler service (schedules are kept in %s)\n", internal.SchedulesFilePath())
+	}
+	return nil
+}
+
+// printServiceDetails prints where the service definition and its log are
+func printServiceDetails(service *internal.Service) {
+	fmt.Printf("   Definition: %s\n", service.Path)
+	switch service.Manager {
+	case internal.ServiceSystemd:
+		if service.System {
+			fmt.Printf("   Logs: journalctl -u %s\n", internal.ServiceName)
+			return
+		}
+		fmt.Printf("   Logs: journalctl --user -u %s\n", internal.ServiceName)
+		name := "$USER"
+		if account, err := user.Current(); err == nil {
+			name = account.Username
+		}
+		fmt.Printf("   To keep it running while you are logged out: loginctl enable-linger %s\n", name)
+	case internal.ServiceSchtasks:
+		fmt.Printf("   Logs: %s\n", service.LogPath)
+		fmt.Printf("   The task starts when you log on to Windows\n")
+	default:
+		fmt.Printf("   Logs: %s\n", service.LogPath)
+	}
+}
+
+// ServiceCommandMain is the entry point for the service subcommand
+func ServiceCommandMain(args []string) {
+	if len(args) < 1 {
+		printServiceUsage("no service subcommand specified")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "install", "start", "stop", "uninstall":
+	default:
+		printServiceUsage(fmt.Sprintf("unknown service subcommand '%s'", args[0]))
+		os.Exit(1)
+	}
+
+	if err := ServiceCommand(args[0]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// printServiceUsage prints the usage of the service subcommand with an error message
+func printServiceUsage(message string) {
+	fmt.Fprintf(os.Stderr, "\n")
+	internal.Output.Eprintf(internal.StatusError, "  Error: %s\n", message)
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "  USAGE:\n")
+	fmt.Fprintf(os.Stderr, "    linea service install      Run the scheduler at boot, restarting it on failure, and start it\n")
+	fmt.Fprintf(os.Stderr, "    linea service start        Start the installed scheduler service\n")
+	fmt.Fprintf(os.Stderr, "    linea service stop         Stop the scheduler service until the next start or boot\n")
+	fmt.Fprintf(os.Stderr, "    linea service uninstall    Stop and remove the scheduler service\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "  NOTE:\n")
+	fmt.Fprintf(os.Stderr, "    Uses a systemd unit on Linux (a user unit, or a system unit when run as root),\n")
+	fmt.Fprintf(os.Stderr, "    a launchd agent on macOS, and a Task Scheduler task on Windows\n")
+	fmt.Fprintf(os.Stderr, "\n")
+}
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"linea/internal"
+)
+
+// SignCommand writes the signature file of every workflow file in targets
+func SignCommand(targets []string) error {
+	files, err := collectWorkflowFiles(targets)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no workflow files (.yml/.yaml/.json/.toml) found")
+	}
+
+	for _, file := range files {
+		signature, err := internal.SignWorkflow(file)
+		if err != nil {
+			return err
+		}
+		if signature.HMAC != "" {
+			internal.Output.Printf(internal.StatusEdit, "Signed %s (%s)\n", file, internal.SignatureFilePath(file))
+		} else {
+			internal.Output.Printf(internal.StatusEdit, "Checksummed %s (%s)\n", file, internal.SignatureFilePath(file))
+		}
+	}
+	if os.Getenv(internal.SigningKeyEnv) == "" {
+		internal.Output.Printf(internal.StatusHint, "%s is not set: the files are pinned by checksum but not signed\n", internal.SigningKeyEnv)
+	}
+	return nil
+}
+
+// VerifyCommand checks every workflow file in targets against its signature file
+// Returns the number of files that fail the check
+func VerifyCommand(targets []string) (int, error) {
+	files, err := collectWorkflowFiles(targets)
+	if err != nil {
+		return 0, err
+	}
+	if len(files) == 0 {
+		return 0, fmt.Errorf("no workflow files (.yml/.yaml/.json/.toml) found")
+	}
+
+	failed := 0
+	for _, file := range files {
+		signed, err := internal.VerifyWorkflow(file)
+		switch {
+		case err != nil:
+			failed++
+			internal.Output.Printf(internal.StatusError, "%v\n", err)
+		case signed:
+			internal.Output.Printf(internal.StatusSuccess, "%s: signature verified\n", file)
+		default:
+			internal.Output.Printf(internal.StatusSuccess, "%s: checksum matches\n", file)
+		}
+	}
+	if os.Getenv(internal.SigningKeyEnv) == "" {
+		internal.Output.Printf(internal.StatusHint, "%s is not set: only checksums were checked\n", internal.SigningKeyEnv)
+	}
+	fmt.Printf("\n%d file(s) checked, %d failed\n", len(files), failed)
+	return failed, nil
+}
+
+// signTargets returns the files and directories given on the command line, defaulting to
+// the project's workflows
+func signTargets(args []string) []string {
+	var targets []string
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			targets = append(targets, arg)
+		}
+	}
+	if len(targets) == 0 {
+		cwd, _ := os.Getwd()
+		if dir := internal.FindWorkflowsDir(cwd); dir != "" {
+			targets = append(targets, dir)
+		}
+	}
+	return targets
+}
+
+// SignCommandMain is the entry point for the sign subcommand
+func SignCommandMain(args []string) {
+	targets := signTargets(args)
+	if len(targets) == 0 {
+		fmt.Fprintf(os.Stderr, "\n")
+		internal.Output.Eprintf(internal.StatusError, "  Error: no file or directory specified (and no .linea/workflows directory found)\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "  USAGE:\n")
+		fmt.Fprintf(os.Stderr, "    linea sign [file|dir]...\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "  Writes <file>.sig with the file's checksum, and its HMAC when %s is set\n", internal.SigningKeyEnv)
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "  EXAMPLES:\n")
+		fmt.Fprintf(os.Stderr, "    linea sign .linea/workflows/deploy.yml\n")
+		fmt.Fprintf(os.Stderr, "    LINEA_SIGNING_KEY=... linea sign\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		os.Exit(1)
+	}
+
+	if err := SignCommand(targets); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// VerifyCommandMain is the entry point for the verify subcommand
+func VerifyCommandMain(args []string) {
+	targets := signTargets(args)
+	if len(targets) == 0 {
+		fmt.Fprintf(os.Stderr, "\n")
+		internal.Output.Eprintf(internal.StatusError, "  Error: no file or directory specified (and no .linea/workflows directory found)\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "  USAGE:\n")
+		fmt.Fprintf(os.Stderr, "    linea verify [file|dir]...\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "  Checks workflow files against their .sig files, and exits with status 1 if any\n")
+		fmt.Fprintf(os.Stderr, "  changed since it was signed\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "  EXAMPLES:\n")
+		fmt.Fprintf(os.Stderr, "    linea verify\n")
+		fmt.Fprintf(os.Stderr, "    linea verify .linea/workflows/deploy.yml\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		os.Exit(1)
+	}
+
+	failed, err := VerifyCommand(targets)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"linea/internal"
+)
+
+// StatsCommand summarizes the local run history as tables or JSON
+// days limits the summary to recent runs (0 for all history)
+func StatsCommand(days int, asJSON bool) error {
+	records, err := internal.LoadRunHistory()
+	if err != nil {
+		return err
+	}
+
+	var since time.Time
+	if days > 0 {
+		since = time.Now().UTC().AddDate(0, 0, -days)
+	}
+	stats := internal.ComputeRunStats(records, since)
+
+	if asJSON {
+		data, err := json.MarshalIndent(stats, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode stats: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if stats.Runs == 0 {
+		fmt.Printf("No runs recorded in %s\n", internal.HistoryFilePath())
+		return nil
+	}
+
+	if days > 0 {
+		fmt.Printf("Runs in the last %d days: %d\n\n", days, stats.Runs)
+	} else {
+		fmt.Printf("Runs recorded: %d\n\n", stats.Runs)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "WORKFLOW\tRUNS\tFAILURES\tFAILURE RATE\tAVG DURATION\tMAX DURATION\tLAST RUN")
+	for _, ws := range stats.Workflows {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\t%s\t%s\n",
+			ws.Workflow,
+			ws.Runs,
+			ws.Failures,
+			formatRate(ws.FailureRate),
+			formatDurationMs(ws.AvgDurationMs),
+			formatDurationMs(ws.MaxDurationMs),
+			ws.LastRun.Local().Format("2006-01-02 15:04"),
+		)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Println()
+
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "WEEK\tRUNS\tFAILURES\tFAILURE RATE")
+	for _, ps := range stats.Weekly {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\n", ps.Period, ps.Runs, ps.Failures, formatRate(ps.FailureRate))
+	}
+	return w.Flush()
+}
+
+// formatRate formats a 0..1 ratio as a percentage
+func formatRate(rate float64) string {
+	return fmt.Sprintf("%.0f%%", rate*100)
+}
+
+// formatDurationMs formats a duration in milliseconds for tables
+func formatDurationMs(ms int64) string {
+	d := time.Duration(ms) * time.Millisecond
+	if d < time.Second {
+		return d.String()
+	}
+	return d.Round(100 * time.Millisecond).String()
+}
+
+// StatsCommandMain is the entry point for the stats subcommand
+func StatsCommandMain(args []string) {
+	days := 0
+	asJSON := false
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--json":
+			asJSON = true
+		case "--days":
+			if i+1 >= len(args) {
+				printStatsUsage("--days needs a number of days")
+				os.Exit(1)
+			}
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n < 0 {
+				printStatsUsage(fmt.Sprintf("invalid number of days '%s'", args[i+1]))
+				os.Exit(1)
+			}
+			days = n
+			i++
+		default:
+			printStatsUsage(fmt.Sprintf("unknown option '%s'", args[i]))
+			os.Exit(1)
+		}
+	}
+
+	if err := StatsCommand(days, asJSON); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// printStatsUsage prints the usage of the stats subcommand with an error message
+func printStatsUsage(message string) {
+	fmt.Fprintf(os.Stderr, "\n")
+	internal.Output.Eprintf(internal.StatusError, "  Error: %s\n", message)
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "  USAGE:\n")
+	fmt.Fprintf(os.Stderr, "    linea stats [options]\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "  OPTIONS:\n")
+	fmt.Fprintf(os.Stderr, "    --days <n>    Only include runs from the last n days\n")
+	fmt.Fprintf(os.Stderr, "    --json        Print the summary as JSON\n")
+	fmt.Fprintf(os.Stderr, "\n")
+}
@@ -2,26 +2,61 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"linea/internal"
 )
 
 // TestCommand performs a dry-run of a YAML command file (supports single or multiple commands)
-func TestCommand(yamlFile string, overrideVars map[string]string) error {
+// With resolve set, it also prints how each variable placeholder was resolved
+// The commands are quoted in quote style (see internal.ShellQuote)
+// An output of json or yaml prints a report of the built commands instead
+func TestCommand(yamlFile string, overrideVars map[string]string, resolve bool, output, quote string) error {
+	yamlFile, err := internal.ResolveWorkflowPath(yamlFile)
+	if err != nil {
+		return err
+	}
+
+	if output == internal.OutputJSON || output == internal.OutputYAML {
+		report := internal.NewRunReport(yamlFile, "test", overrideVars, time.Now())
+		report.Steps, err = testWorkflowReport(yamlFile, overrideVars, resolve)
+		report.Finish(err)
+		if writeErr := internal.WriteStructured(os.Stdout, output, report); writeErr != nil && err == nil {
+			err = writeErr
+		}
+		return err
+	}
+
 	configs, err := internal.ParseMultiYAML(yamlFile)
 	if err != nil {
 		return fmt.Errorf("failed to parse YAML file: %w", err)
 	}
+	if configs, err = internal.ExpandForeach(configs, overrideVars); err != nil {
+		return err
+	}
+
+	outside, err := internal.CheckTimeWindows(configs, time.Now())
+	if err != nil {
+		return err
+	}
+	for _, err := range outside {
+		internal.Output.Printf(internal.StatusWarning, "%v (linea run needs --force)\n", err)
+	}
 
 	if len(configs) == 1 {
 		cmd, err := internal.BuildCommand(configs[0], overrideVars)
-		if err != nil {
-			return err
+		if err == nil {
+			printConfirmation(configs[0], overrideVars)
+			internal.DryRunStyle(cmd, quote)
 		}
-		internal.DryRun(cmd)
-		return nil
+		if resolve {
+			printVariableResolution(configs[0], overrideVars)
+		}
+		return err
 	}
 
 	// Multiple commands
@@ -29,10 +64,16 @@ func TestCommand(yamlFile string, overrideVars map[string]string) error {
 	for i, config := range configs {
 		fmt.Printf("[%d/%d] ", i+1, len(configs))
 		cmd, err := internal.BuildCommand(config, overrideVars)
+		if err == nil {
+			printConfirmation(config, overrideVars)
+			internal.DryRunStyle(cmd, quote)
+		}
+		if resolve {
+			printVariableResolution(config, overrideVars)
+		}
 		if err != nil {
 			return fmt.Errorf("error building command %d: %w", i+1, err)
 		}
-		internal.DryRun(cmd)
 		if i < len(configs)-1 {
 			fmt.Println()
 		}
@@ -41,50 +82,251 @@ func TestCommand(yamlFile string, overrideVars map[string]string) error {
 	return nil
 }
 
+// RunAssertions runs a workflow for real as an integration test: a failed type: assert
+// step does not stop the run, and the outcome of each assert step is printed at the end
+// An output of json or yaml prints the report of the run instead
+func RunAssertions(yamlFile string, overrideVars map[string]string, output string) error {
+	yamlFile, err := internal.ResolveWorkflowPath(yamlFile)
+	if err != nil {
+		return err
+	}
+	structured := output == internal.OutputJSON || output == internal.OutputYAML
+	stdout := io.Writer(os.Stdout)
+	if structured {
+		stdout = os.Stderr
+		internal.ReserveStdout()
+	}
+
+	report := internal.NewRunReport(yamlFile, "test", overrideVars, time.Now())
+	configs, err := prepareWorkflow(yamlFile, overrideVars, RunOptions{})
+	if err == nil {
+		report.Steps, err = internal.RunStepsReport(configs, overrideVars, internal.ReportOptions{
+			Stdout:   stdout,
+			Stderr:   os.Stderr,
+			Workflow: internal.WorkflowName(yamlFile),
+			Asserts:  true,
+		})
+	}
+	report.Finish(err)
+	if structured {
+		if writeErr := internal.WriteStructured(os.Stdout, output, report); writeErr != nil && err == nil {
+			err = writeErr
+		}
+		return err
+	}
+	if configs == nil {
+		return err
+	}
+
+	summary := internal.SummarizeAssertions(configs, report.Steps)
+	fmt.Println()
+	for _, result := range summary.Failed {
+		label := strconv.Itoa(result.Index)
+		if result.Name != "" {
+			label += " " + result.Name
+		}
+		internal.Output.Printf(internal.StatusError, "[%s] %s\n", label, result.Error)
+	}
+	total := summary.Passed + len(summary.Failed) + summary.NotRun
+	switch {
+	case total == 0:
+		internal.Output.Printf(internal.StatusWarning, "No type: assert steps in %s\n", internal.WorkflowName(yamlFile))
+	case len(summary.Failed) == 0 && summary.NotRun == 0:
+		internal.Output.Printf(internal.StatusSuccess, "All %d assertion(s) passed\n", total)
+	default:
+		internal.Output.Printf(internal.StatusError, "Assertions: %d passed, %d failed, %d not run\n", summary.Passed, len(summary.Failed), summary.NotRun)
+	}
+	if err != nil && summary.Failures == 0 && len(summary.Failed) > 0 {
+		return fmt.Errorf("%d assertion(s) failed", len(summary.Failed))
+	}
+	return err
+}
+
+// testWorkflowReport builds the steps of a resolved workflow file for --output
+func testWorkflowReport(yamlFile string, overrideVars map[string]string, resolve bool) ([]internal.StepResult, error) {
+	configs, err := internal.ParseMultiYAML(yamlFile)
+	if err != nil {
+		return []internal.StepResult{}, fmt.Errorf("failed to parse YAML file: %w", err)
+	}
+	if configs, err = internal.ExpandForeach(configs, overrideVars); err != nil {
+		return []internal.StepResult{}, err
+	}
+
+	outside, err := internal.CheckTimeWindows(configs, time.Now())
+	if err != nil {
+		return []internal.StepResult{}, err
+	}
+	for _, err := range outside {
+		internal.Output.Eprintf(internal.StatusWarning, "%v (linea run needs --force)\n", err)
+	}
+	return internal.DryRunStepsReport(configs, overrideVars, resolve)
+}
+
+// printConfirmation shows the confirm: prompt linea run would ask before a step
+func printConfirmation(config *internal.CommandConfig, overrideVars map[string]string) {
+	message, err := internal.ConfirmMessage(config, overrideVars)
+	if err != nil {
+		internal.Output.Printf(internal.StatusWarning, "%v\n", err)
+	} else if message != "" {
+		internal.Output.Printf(internal.StatusWarning, "Would ask: %s [y/N]\n", message)
+	}
+}
+
+// printVariableResolution prints the variable resolution tree of a step, with
+// unresolved placeholders highlighted
+func printVariableResolution(config *internal.CommandConfig, overrideVars map[string]string) {
+	resolutions, err := internal.ExplainVariables(config, overrideVars)
+	if err != nil {
+		fmt.Printf("Variable resolution failed: %v\n", err)
+		return
+	}
+	if len(resolutions) == 0 {
+		fmt.Println("No variables referenced")
+		return
+	}
+
+	color := internal.ColorOutput(os.Stdout)
+	fmt.Println("Variable resolution:")
+	for i, r := range resolutions {
+		branch, indent := "├─", "│  "
+		if i == len(resolutions)-1 {
+			branch, indent = "└─", "   "
+		}
+
+		if !r.Resolved() {
+			fmt.Printf("%s %s %s\n", branch, internal.Colorize(r.Reference, internal.ColorRed, color),
+				internal.Colorize("unresolved: "+r.Hint, internal.ColorRed, color))
+		} else {
+			fmt.Printf("%s %s = %q %s\n", branch, r.Reference, r.Value, internal.Colorize("("+r.Source+")", internal.ColorDim, color))
+		}
+
+		var details []string
+		for _, c := range r.Overrides {
+			details = append(details, fmt.Sprintf("overrides %s %q", c.Source, c.Value))
+		}
+		for _, c := range r.Ignored {
+			details = append(details, fmt.Sprintf("ignores %s %q (-s/--set only applies to $%s)", c.Source, c.Value, r.Name))
+		}
+		for j, detail := range details {
+			sub := "├─"
+			if j == len(details)-1 {
+				sub = "└─"
+			}
+			fmt.Printf("%s%s %s\n", indent, sub, internal.Colorize(detail, internal.ColorDim, color))
+		}
+	}
+}
+
 // TestCommandMain is the entry point for the test subcommand
 func TestCommandMain(args []string) {
 	if len(args) < 1 {
 		fmt.Fprintf(os.Stderr, "\n")
-		fmt.Fprintf(os.Stderr, "  ❌ Error: no YAML file specified\n")
+		internal.Output.Eprintf(internal.StatusError, "  Error: no YAML file specified\n")
 		fmt.Fprintf(os.Stderr, "\n")
 		fmt.Fprintf(os.Stderr, "  USAGE:\n")
-		fmt.Fprintf(os.Stderr, "    linea test [options] <yaml-file>\n")
+		fmt.Fprintf(os.Stderr, "    linea test [options] <yaml-file|workflow-name> [input...]\n")
 		fmt.Fprintf(os.Stderr, "\n")
 		fmt.Fprintf(os.Stderr, "  OPTIONS:\n")
 		fmt.Fprintf(os.Stderr, "    -s, --set <var>=<value>     Set variable values for testing\n")
+		fmt.Fprintf(os.Stderr, "    --set-json <var>=<json>     Set a list or map variable for testing\n")
+		fmt.Fprintf(os.Stderr, "    --resolve                   Show where each variable's value comes from\n")
+		fmt.Fprintf(os.Stderr, "    --output <text|json|yaml>   Print the built commands as a machine-readable report\n")
+		fmt.Fprintf(os.Stderr, "    --run-asserts               Run the workflow and report its type: assert steps\n")
+		fmt.Fprintf(os.Stderr, "    --shellquote <style>        Quote commands for posix, powershell, or cmd, or none\n")
 		fmt.Fprintf(os.Stderr, "\n")
 		fmt.Fprintf(os.Stderr, "  EXAMPLES:\n")
 		fmt.Fprintf(os.Stderr, "    linea test config.yml\n")
 		fmt.Fprintf(os.Stderr, "    linea test config.yml -s variable=\"test\"\n")
+		fmt.Fprintf(os.Stderr, "    linea test config.yml --resolve -s variable=\"test\"\n")
+		fmt.Fprintf(os.Stderr, "    linea test config.yml --output json\n")
+		fmt.Fprintf(os.Stderr, "    linea test config.yml --shellquote powershell\n")
+		fmt.Fprintf(os.Stderr, "    linea test smoke.yml --run-asserts\n")
 		fmt.Fprintf(os.Stderr, "\n")
 		os.Exit(1)
 	}
 
 	// Parse -s/--set flags
 	overrideVars, remainingArgs := ParseArgs(args)
+	output, remainingArgs, err := parseOutputFlag(remainingArgs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\n")
+		internal.Output.Eprintf(internal.StatusError, "  Error: %v\n", err)
+		fmt.Fprintf(os.Stderr, "\n")
+		os.Exit(1)
+	}
 	
 	yamlFile := ""
-	for _, arg := range remainingArgs {
-		if !strings.HasPrefix(arg, "-") {
+	var inputArgs []string
+	resolve := false
+	runAsserts := false
+	quote := internal.DefaultQuoteStyle()
+	for i := 0; i < len(remainingArgs); i++ {
+		arg := remainingArgs[i]
+		if arg == "--resolve" {
+			resolve = true
+		} else if arg == "--run-asserts" {
+			runAsserts = true
+		} else if arg == "--shellquote" || strings.HasPrefix(arg, "--shellquote=") {
+			value := strings.TrimPrefix(arg, "--shellquote=")
+			if arg == "--shellquote" {
+				if i+1 >= len(remainingArgs) {
+					fmt.Fprintf(os.Stderr, "\n")
+					internal.Output.Eprintf(internal.StatusError, "  Error: --shellquote requires a style (posix, powershell, cmd, or none)\n")
+					fmt.Fprintf(os.Stderr, "\n")
+					os.Exit(1)
+				}
+				i++
+				value = remainingArgs[i]
+			}
+			if quote, err = internal.ParseQuoteStyle(value); err != nil {
+				fmt.Fprintf(os.Stderr, "\n")
+				internal.Output.Eprintf(internal.StatusError, "  Error: %v\n", err)
+				fmt.Fprintf(os.Stderr, "\n")
+				os.Exit(1)
+			}
+		} else if !strings.HasPrefix(arg, "-") && yamlFile == "" {
 			yamlFile = arg
-			break
+		} else if !strings.HasPrefix(arg, "-") {
+			inputArgs = append(inputArgs, arg)
 		}
 	}
 
 	if yamlFile == "" {
 		fmt.Fprintf(os.Stderr, "\n")
-		fmt.Fprintf(os.Stderr, "  ❌ Error: no YAML file specified\n")
+		internal.Output.Eprintf(internal.StatusError, "  Error: no YAML file specified\n")
 		fmt.Fprintf(os.Stderr, "\n")
 		fmt.Fprintf(os.Stderr, "  USAGE:\n")
-		fmt.Fprintf(os.Stderr, "    linea test [options] <yaml-file>\n")
+		fmt.Fprintf(os.Stderr, "    linea test [options] <yaml-file|workflow-name> [input...]\n")
 		fmt.Fprintf(os.Stderr, "\n")
 		fmt.Fprintf(os.Stderr, "  OPTIONS:\n")
 		fmt.Fprintf(os.Stderr, "    -s, --set <var>=<value>     Set variable values for testing\n")
+		fmt.Fprintf(os.Stderr, "    --set-json <var>=<json>     Set a list or map variable for testing\n")
+		fmt.Fprintf(os.Stderr, "    --resolve                   Show where each variable's value comes from\n")
+		fmt.Fprintf(os.Stderr, "    --output <text|json|yaml>   Print the built commands as a machine-readable report\n")
+		fmt.Fprintf(os.Stderr, "    --run-asserts               Run the workflow and report its type: assert steps\n")
 		fmt.Fprintf(os.Stderr, "\n")
 		os.Exit(1)
 	}
 
-	if err := TestCommand(yamlFile, overrideVars); err != nil {
+	// The arguments after a workflow with inputs: are their values, as with linea run
+	if vars, bound, err := bindWorkflowInputs(yamlFile, inputArgs, overrideVars); err != nil {
+		fmt.Fprintf(os.Stderr, "\n")
+		internal.Output.Eprintf(internal.StatusError, "  Error: %v\n", err)
+		fmt.Fprintf(os.Stderr, "\n")
+		os.Exit(1)
+	} else if bound {
+		overrideVars = vars
+	}
+
+	if runAsserts {
+		if err := RunAssertions(yamlFile, overrideVars, output); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := TestCommand(yamlFile, overrideVars, resolve, output, quote); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
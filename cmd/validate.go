@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"linea/internal"
+)
+
+// collectWorkflowFiles expands files and directories into the list of workflow files to check
+func collectWorkflowFiles(targets []string) ([]string, error) {
+	var files []string
+	for _, target := range targets {
+		info, err := os.Stat(target)
+		if err != nil {
+			return nil, fmt.Errorf("cannot access %s: %w", target, err)
+		}
+		if !info.IsDir() {
+			files = append(files, target)
+			continue
+		}
+
+		err = filepath.Walk(target, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() && info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			// .json and .toml files are only workflows in a workflows directory, unlike
+			// package.json and the like
+			if !info.IsDir() && internal.IsWorkflowFile(info.Name()) && (internal.IsYAMLFile(info.Name()) || filepath.Base(filepath.Dir(path)) == "workflows") {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to walk %s: %w", target, err)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// ValidateCommand validates workflow files and directories
+// Returns the number of problems found
+func ValidateCommand(targets []string, overrideVars map[string]string) (int, error) {
+	files, err := collectWorkflowFiles(targets)
+	if err != nil {
+		return 0, err
+	}
+	if len(files) == 0 {
+		return 0, fmt.Errorf("no workflow files (.yml/.yaml/.json/.toml) found")
+	}
+
+	total := 0
+	for _, file := range files {
+		problems, err := internal.ValidateWorkflowFile(file, overrideVars)
+		if err != nil {
+			return total, err
+		}
+		if len(problems) == 0 {
+			internal.Output.Printf(internal.StatusSuccess, "%s\n", file)
+			continue
+		}
+
+		internal.Output.Printf(internal.StatusError, "%s\n", file)
+		for _, problem := range problems {
+			fmt.Printf("   %s\n", problem)
+		}
+		total += len(problems)
+	}
+
+	fmt.Printf("\n%d file(s) checked, %d problem(s) found\n", len(files), total)
+	return total, nil
+}
+
+// ValidateCommandMain is the entry point for the validate subcommand
+func ValidateCommandMain(args []string) {
+	overrideVars, remainingArgs := ParseArgs(args)
+
+	var targets []string
+	for _, arg := range remainingArgs {
+		if !strings.HasPrefix(arg, "-") {
+			targets = append(targets, arg)
+		}
+	}
+
+	if len(targets) == 0 {
+		fmt.Fprintf(os.Stderr, "\n")
+		internal.Output.Eprintf(internal.StatusError, "  Error: no file or directory specified\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "  USAGE:\n")
+		fmt.Fprintf(os.Stderr, "    linea validate [options] <file|dir>...\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "  OPTIONS:\n")
+		fmt.Fprintf(os.Stderr, "    -s, --set <var>=<value>     Treat a variable as provided on the command line\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "  EXAMPLES:\n")
+		fmt.Fprintf(os.Stderr, "    linea validate deploy.yml\n")
+		fmt.Fprintf(os.Stderr, "    linea validate .linea/workflows\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		os.Exit(1)
+	}
+
+	problems, err := ValidateCommand(targets, overrideVars)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if problems > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	"linea/internal"
+)
+
+// VerifyInstallCommand runs the post-install smoke test with the running executable
+// Returns an error if any check fails
+func VerifyInstallCommand(keep bool) error {
+	lineaPath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("cannot locate the linea executable: %w", err)
+	}
+
+	if !internal.Output.Quiet {
+		fmt.Printf("Verifying %s\n\n", lineaPath)
+	}
+	dir, checks := internal.VerifyInstall(lineaPath, keep)
+	for _, check := range checks {
+		if check.Err == nil {
+			internal.Output.Printf(internal.StatusSuccess, "%s\n", check.Name)
+			continue
+		}
+		internal.Output.Printf(internal.StatusError, "%s: %v\n", check.Name, check.Err)
+		if output := strings.TrimSpace(check.Output); output != "" {
+			for _, line := range strings.Split(output, "\n") {
+				fmt.Printf("     %s\n", line)
+			}
+		}
+	}
+	fmt.Println()
+	if keep && dir != "" {
+		fmt.Printf("Temporary app kept in %s\n\n", dir)
+	}
+
+	if len(checks) == 0 || checks[len(checks)-1].Err != nil {
+		return fmt.Errorf("verification failed on %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	internal.Output.Printf(internal.StatusSuccess, "linea works on this host\n")
+	return nil
+}
+
+// VerifyInstallCommandMain is the entry point for the verify-install subcommand
+func VerifyInstallCommandMain(args []string) {
+	keep := false
+	for _, arg := range args {
+		switch arg {
+		case "--keep":
+			keep = true
+		default:
+			fmt.Fprintf(os.Stderr, "\n")
+			internal.Output.Eprintf(internal.StatusError, "  Error: unknown option '%s'\n", arg)
+			fmt.Fprintf(os.Stderr, "\n")
+			fmt.Fprintf(os.Stderr, "  USAGE:\n")
+			fmt.Fprintf(os.Stderr, "    linea verify-install [--keep]\n")
+			fmt.Fprintf(os.Stderr, "\n")
+			fmt.Fprintf(os.Stderr, "  OPTIONS:\n")
+			fmt.Fprintf(os.Stderr, "    --keep                 Keep the temporary app for inspection\n")
+			fmt.Fprintf(os.Stderr, "\n")
+			os.Exit(1)
+		}
+	}
+
+	if err := VerifyInstallCommand(keep); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -4,3 +4,4 @@ go 1.18
 
 require gopkg.in/yaml.v3 v3.0.1
 require mvdan.cc/sh/v3 v3.12.0 
+require github.com/BurntSushi/toml v1.4.0
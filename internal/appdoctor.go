@@ -0,0 +1,335 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Checks run by `linea app doctor`, in the order they are reported
+const (
+	AppCheckWorkflows   = "Workflows"
+	AppCheckCommands    = "Commands"
+	AppCheckScripts     = "Scripts"
+	AppCheckExecutables = "Executables"
+	AppCheckConfig      = "Config"
+)
+
+// AppChecks lists the checks of `linea app doctor` in the order they are reported
+var AppChecks = []string{AppCheckWorkflows, AppCheckCommands, AppCheckScripts, AppCheckExecutables, AppCheckConfig}
+
+// AppDiagnostic is one finding of `linea app doctor`
+type AppDiagnostic struct {
+	Check   string // One of AppChecks
+	Subject string // The file, command, or setting the finding is about
+	Problem string // Empty when the check passed
+	Fix     string // What to do about the problem
+	Warning bool   // The problem may not break the app, e.g. a command that may be a Windows built-in
+}
+
+// shellBuiltins are the commands of sh that lineash scripts can use without them being
+// on PATH
+var shellBuiltins = map[string]bool{
+	".": true, ":": true, "[": true, "alias": true, "cd": true, "echo": true, "eval": true,
+	"exec": true, "exit": true, "export": true, "false": true, "kill": true, "printf": true,
+	"pwd": true, "read": true, "set": true, "shift": true, "source": true, "test": true,
+	"trap": true, "true": true, "type": true, "ulimit": true, "umask": true, "unset": true,
+	"wait": true,
+}
+
+// lineashKeywords start or close the blocks of lineash scripts
+var lineashKeywords = map[string]bool{
+	"if": true, "then": true, "else": true, "elif": true, "fi": true, "end": true,
+	"for": true, "while": true, "do": true, "done": true,
+}
+
+// AppRoot returns the Linea app containing dir: the nearest directory with a .linea
+// directory, or dir itself
+func AppRoot(dir string) string {
+	if lineaDir := FindLineaDir(dir); lineaDir != "" {
+		return filepath.Dir(lineaDir)
+	}
+	return dir
+}
+
+// DiagnoseApp checks the layout of the Linea app in dir: its workflows parse, the
+// commands they run are on PATH, its lineash scripts only call existing workflows, the
+// linea and lineash executables resolve, and the global config is readable
+func DiagnoseApp(dir string) []AppDiagnostic {
+	var diagnostics []AppDiagnostic
+	workflowsDir := filepath.Join(dir, ".linea", "workflows")
+	if info, err := os.Stat(workflowsDir); err != nil || !info.IsDir() {
+		diagnostics = append(diagnostics, AppDiagnostic{
+			Check:   AppCheckWorkflows,
+			Subject: dir,
+			Problem: "no .linea/workflows directory",
+			Fix:     "create it, or start a new app with: linea app create <name>",
+		})
+		workflowsDir = ""
+	} else {
+		diagnostics = append(diagnostics, diagnoseAppWorkflows(dir, workflowsDir)...)
+	}
+	diagnostics = append(diagnostics, diagnoseAppScripts(dir, workflowsDir)...)
+	diagnostics = append(diagnostics, diagnoseExecutables()...)
+	diagnostics = append(diagnostics, diagnoseConfig()...)
+	return diagnostics
+}
+
+// relativeTo returns path relative to dir for display, or path if it is not inside dir
+func relativeTo(dir, path string) string {
+	if rel, err := filepath.Rel(dir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+// diagnoseAppWorkflows validates the workflows of an app and checks that the commands
+// they run locally can be found
+func diagnoseAppWorkflows(dir, workflowsDir string) []AppDiagnostic {
+	var files []string
+	filepath.Walk(workflowsDir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && IsWorkflowFile(info.Name()) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	sort.Strings(files)
+	if len(files) == 0 {
+		return []AppDiagnostic{{
+			Check:   AppCheckWorkflows,
+			Subject: relativeTo(dir, workflowsDir),
+			Problem: "no workflow files (.yml/.yaml/.json/.toml)",
+			Fix:     "add one with: linea init " + filepath.Join(relativeTo(dir, workflowsDir), "<name>.yml"),
+		}}
+	}
+
+	var diagnostics []AppDiagnostic
+	usedBy := map[string][]string{}
+	for _, file := range files {
+		name := relativeTo(dir, file)
+		problems, err := ValidateWorkflowDefinition(file)
+		if err != nil {
+			problems = []ValidationProblem{{File: file, Message: err.Error()}}
+		}
+		if len(problems) == 0 {
+			diagnostics = append(diagnostics, AppDiagnostic{Check: AppCheckWorkflows, Subject: name})
+		}
+		for _, problem := range problems {
+			subject := name
+			if problem.Line > 0 {
+				subject = fmt.Sprintf("%s:%d", name, problem.Line)
+			}
+			diagnostics = append(diagnostics, AppDiagnostic{
+				Check:   AppCheckWorkflows,
+				Subject: subject,
+				Problem: problem.Message,
+				Fix:     "edit the file, then check it with: linea validate " + name,
+			})
+		}
+
+		configs, err := ParseMultiYAML(file)
+		if err != nil {
+			continue
+		}
+		for _, config := range configs {
+			if command := localCommand(config); command != "" && !containsString(usedBy[command], name) {
+				usedBy[command] = append(usedBy[command], name)
+			}
+		}
+	}
+
+	commands := make([]string, 0, len(usedBy))
+	for command := range usedBy {
+		commands = append(commands, command)
+	}
+	sort.Strings(commands)
+	for _, command := range commands {
+		diagnostic := AppDiagnostic{Check: AppCheckCommands, Subject: command}
+		if !commandExists(dir, command) {
+			diagnostic.Problem = fmt.Sprintf("not found on PATH (used by %s)", strings.Join(usedBy[command], ", "))
+			diagnostic.Fix = fmt.Sprintf("install %s, or add its directory to PATH", command)
+			if strings.ContainsAny(command, `/\`) {
+				diagnostic.Problem = fmt.Sprintf("does not exist (used by %s)", strings.Join(usedBy[command], ", "))
+				diagnostic.Fix = "fix the path of command:, relative to the app directory"
+			} else if runtime.GOOS == "windows" {
+				diagnostic.Problem = fmt.Sprintf("not found on PATH, so it runs through %s as a built-in (used by %s)", StepShell(nil), strings.Join(usedBy[command], ", "))
+				diagnostic.Warning = true
+			}
+		}
+		diagnostics = append(diagnostics, diagnostic)
+	}
+	return diagnostics
+}
+
+// localCommand returns the program a step runs on this host, or "" for built-in step
+// types and commands named by a variable
+func localCommand(config *CommandConfig) string {
+	switch {
+	case config.Type != "":
+		return ""
+	case config.Runner != nil && config.Runner.Type == RunnerSSH:
+		return "ssh"
+	case config.Runner != nil && config.Runner.Type == RunnerDocker:
+		return "docker"
+	case config.Command == "" || strings.ContainsAny(config.Command, "{$"):
+		return ""
+	}
+	return config.Command
+}
+
+// commandExists reports whether a command can be run from the app directory: on PATH,
+// or a file for commands given as a path
+func commandExists(dir, command string) bool {
+	if strings.ContainsAny(command, `/\`) {
+		path := command
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		info, err := os.Stat(path)
+		return err == nil && !info.IsDir()
+	}
+	_, err := exec.LookPath(command)
+	return err == nil
+}
+
+// diagnoseAppScripts checks that the commands of the app's lineash scripts are workflows,
+// shell built-ins, or on PATH
+func diagnoseAppScripts(dir, workflowsDir string) []AppDiagnostic {
+	var scripts []string
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() && path != dir && (strings.HasPrefix(info.Name(), ".") || info.Name() == "node_modules") {
+			return filepath.SkipDir
+		}
+		if !info.IsDir() && strings.HasSuffix(info.Name(), ".lnsh") {
+			scripts = append(scripts, path)
+		}
+		return nil
+	})
+	sort.Strings(scripts)
+
+	ctx := &LineashContext{WorkflowsDir: workflowsDir, SearchDirs: WorkflowSearchDirs(workflowsDir)}
+	var diagnostics []AppDiagnostic
+	for _, script := range scripts {
+		name := relativeTo(dir, script)
+		data, err := os.ReadFile(script)
+		if err != nil {
+			diagnostics = append(diagnostics, AppDiagnostic{Check: AppCheckScripts, Subject: name, Problem: err.Error(), Fix: "check the file's permissions"})
+			continue
+		}
+
+		found := false
+		for i, line := range strings.Split(string(data), "\n") {
+			command, workflowCall := scriptCommand(line)
+			if command == "" || ctx.IsWorkflowCommand(command) || shellBuiltins[command] {
+				continue
+			}
+			subject := fmt.Sprintf("%s:%d", name, i+1)
+			switch {
+			case workflowCall:
+				found = true
+				diagnostics = append(diagnostics, AppDiagnostic{
+					Check:   AppCheckScripts,
+					Subject: subject,
+					Problem: fmt.Sprintf("calls workflow '%s', which does not exist", command),
+					Fix:     fmt.Sprintf("create .linea/workflows/%s.yml, or fix the name (linea list shows the workflows)", command),
+				})
+			case !commandExists(filepath.Dir(script), command):
+				found = true
+				diagnostic := AppDiagnostic{
+					Check:   AppCheckScripts,
+					Subject: subject,
+					Problem: fmt.Sprintf("'%s' is not a workflow or a command on PATH", command),
+					Fix:     fmt.Sprintf("create .linea/workflows/%s.yml, or install %s", command, command),
+				}
+				if runtime.GOOS == "windows" {
+					diagnostic.Problem = fmt.Sprintf("'%s' is not a workflow or a command on PATH, so it runs through cmd as a built-in", command)
+					diagnostic.Warning = true
+				}
+				diagnostics = append(diagnostics, diagnostic)
+			}
+		}
+		if !found {
+			diagnostics = append(diagnostics, AppDiagnostic{Check: AppCheckScripts, Subject: name})
+		}
+	}
+	return diagnostics
+}
+
+// scriptCommand returns the command a lineash script line runs, and whether it passes
+// -s/--set variables like a workflow call; "" for comments, assignments, block keywords,
+// and commands named by a variable
+func scriptCommand(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", false
+	}
+	if _, _, ok := parseVariableAssignment(line); ok {
+		return "", false
+	}
+	parts := parseCommand(line)
+	if len(parts) == 0 || lineashKeywords[parts[0]] || strings.Contains(parts[0], "$") {
+		return "", false
+	}
+	for _, arg := range parts[1:] {
+		if arg == "-s" || arg == "--set" {
+			return parts[0], true
+		}
+	}
+	return parts[0], false
+}
+
+// diagnoseExecutables checks that linea and lineash resolve, as lineash scripts need
+func diagnoseExecutables() []AppDiagnostic {
+	var diagnostics []AppDiagnostic
+	linea, err := FindLineaExecutable()
+	if err != nil {
+		diagnostics = append(diagnostics, AppDiagnostic{
+			Check:   AppCheckExecutables,
+			Subject: LineaExecutableName(),
+			Problem: err.Error(),
+			Fix:     fmt.Sprintf("add linea to PATH, or set %s or linea_bin (linea config set linea_bin <path>)", LineaBinEnv),
+		})
+	} else {
+		diagnostics = append(diagnostics, AppDiagnostic{Check: AppCheckExecutables, Subject: linea})
+	}
+
+	lineash := "lineash"
+	if runtime.GOOS == "windows" {
+		lineash = "lineash.exe"
+	}
+	diagnostic := AppDiagnostic{Check: AppCheckExecutables, Subject: lineash}
+	if path, err := exec.LookPath(lineash); err == nil {
+		diagnostic.Subject = path
+	} else if linea != "" && commandExists("", filepath.Join(filepath.Dir(linea), lineash)) {
+		diagnostic.Subject = filepath.Join(filepath.Dir(linea), lineash)
+	} else {
+		diagnostic.Problem = "not found on PATH or next to linea"
+		diagnostic.Fix = "run scripts with linea sh <script>, or install lineash next to linea"
+		diagnostic.Warning = true
+	}
+	return append(diagnostics, diagnostic)
+}
+
+// diagnoseConfig checks that the global config file can be read
+func diagnoseConfig() []AppDiagnostic {
+	path := ConfigFilePath()
+	if _, err := LoadUserConfig(); err != nil {
+		return []AppDiagnostic{{
+			Check:   AppCheckConfig,
+			Subject: path,
+			Problem: err.Error(),
+			Fix:     "fix the file (linea config list shows the settings), or remove it to use the defaults",
+		}}
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		path += " (not created yet)"
+	}
+	return []AppDiagnostic{{Check: AppCheckConfig, Subject: path}}
+}
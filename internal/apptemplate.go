@@ -0,0 +1,243 @@
+package internal
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"linea/templates"
+)
+
+// AppTemplateFile is the manifest at the root of an app template; it describes the
+// template and is not copied into the app
+const AppTemplateFile = "template.yml"
+
+// DefaultAppTemplate is the template `linea app create` uses without --template
+const DefaultAppTemplate = "default"
+
+// AppTemplate is a directory tree `linea app create` copies into a new app, replacing
+// {{app_name}} and the {{placeholders}} of its prompts in file names and contents
+type AppTemplate struct {
+	Name        string
+	Description string
+	Prompts     []AppTemplatePrompt
+	Dir         string // The user template directory; empty for a built-in template
+
+	files fs.FS
+	err   error // Why the manifest could not be loaded
+}
+
+// AppTemplatePrompt is a placeholder of an app template that `linea app create` asks a
+// value for
+type AppTemplatePrompt struct {
+	Name    string `yaml:"name"`
+	Prompt  string `yaml:"prompt,omitempty"`  // The question asked; defaults to the name
+	Default string `yaml:"default,omitempty"` // May use {{app_name}} and earlier placeholders
+}
+
+// Question returns the question asked for the placeholder
+func (p AppTemplatePrompt) Question() string {
+	if p.Prompt != "" {
+		return p.Prompt
+	}
+	return p.Name
+}
+
+// appTemplateManifest is the content of a template.yml
+type appTemplateManifest struct {
+	Description string              `yaml:"description"`
+	Prompts     []AppTemplatePrompt `yaml:"prompts"`
+}
+
+// placeholderName matches the names of template placeholders
+var placeholderName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// Source describes where the template comes from, for listings
+func (t *AppTemplate) Source() string {
+	if t.Dir == "" {
+		return "built-in"
+	}
+	return t.Dir
+}
+
+// AppTemplatesDir returns the directory of user app templates, one subdirectory per
+// template; a user template replaces the built-in template of the same name
+func AppTemplatesDir() string {
+	return filepath.Join(UserPaths().Data, "templates")
+}
+
+// loadAppTemplate reads the manifest of the template in files, if it has one
+func loadAppTemplate(name, dir string, files fs.FS) *AppTemplate {
+	t := &AppTemplate{Name: name, Dir: dir, files: files}
+	data, err := fs.ReadFile(files, AppTemplateFile)
+	if err != nil {
+		return t
+	}
+	var manifest appTemplateManifest
+	if err := yaml.Unmarshal(data, &manifest); err != nil {
+		t.err = fmt.Errorf("template '%s': invalid %s: %w", name, AppTemplateFile, err)
+		return t
+	}
+	t.Description = strings.TrimSpace(manifest.Description)
+	t.Prompts = manifest.Prompts
+
+	seen := map[string]bool{"app_name": true}
+	for _, p := range t.Prompts {
+		switch {
+		case !placeholderName.MatchString(p.Name):
+			t.err = fmt.Errorf("template '%s': invalid prompt name '%s' in %s", name, p.Name, AppTemplateFile)
+		case seen[p.Name]:
+			t.err = fmt.Errorf("template '%s': prompt '%s' is declared twice or is built in", name, p.Name)
+		}
+		seen[p.Name] = true
+	}
+	return t
+}
+
+// AppTemplates returns the built-in and user app templates, sorted by name
+func AppTemplates() []*AppTemplate {
+	found := map[string]*AppTemplate{}
+	if entries, err := fs.ReadDir(templates.App, "app"); err == nil {
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+			files, err := fs.Sub(templates.App, path.Join("app", entry.Name()))
+			if err != nil {
+				continue
+			}
+			found[entry.Name()] = loadAppTemplate(entry.Name(), "", files)
+		}
+	}
+	userDir := AppTemplatesDir()
+	if entries, err := os.ReadDir(userDir); err == nil {
+		for _, entry := range entries {
+			if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			dir := filepath.Join(userDir, entry.Name())
+			found[entry.Name()] = loadAppTemplate(entry.Name(), dir, os.DirFS(dir))
+		}
+	}
+
+	list := make([]*AppTemplate, 0, len(found))
+	for _, t := range found {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// FindAppTemplate returns the app template with the given name
+func FindAppTemplate(name string) (*AppTemplate, error) {
+	var names []string
+	for _, t := range AppTemplates() {
+		if t.Name == name {
+			return t, t.err
+		}
+		names = append(names, t.Name)
+	}
+	return nil, fmt.Errorf("unknown app template '%s' (available: %s)", name, strings.Join(names, ", "))
+}
+
+// renderAppTemplate replaces the {{name}} placeholders of s with their values
+func renderAppTemplate(s string, values map[string]string) string {
+	for name, value := range values {
+		s = strings.ReplaceAll(s, "{{"+name+"}}", value)
+	}
+	return s
+}
+
+// AppTemplateValues returns the values of the placeholders of a template for the app
+// dir: {{app_name}}, then each prompt's value from set, ask, or its default. ask gets the
+// prompt and its default, and may be nil to use the defaults. Other values in set fill
+// placeholders the manifest does not declare
+func AppTemplateValues(dir string, t *AppTemplate, set map[string]string, ask func(p AppTemplatePrompt, def string) string) (map[string]string, error) {
+	values := map[string]string{"app_name": filepath.Base(filepath.Clean(dir))}
+	for name, value := range set {
+		values[name] = value
+	}
+	for _, p := range t.Prompts {
+		if _, ok := set[p.Name]; ok {
+			continue
+		}
+		value := renderAppTemplate(p.Default, values)
+		if ask != nil {
+			value = ask(p, value)
+		}
+		if value == "" {
+			return nil, fmt.Errorf("template '%s' needs a value for {{%s}} (%s); set it with -s %s=<value>", t.Name, p.Name, p.Question(), p.Name)
+		}
+		values[p.Name] = value
+	}
+	return values, nil
+}
+
+// CreateApp creates the app directory dir from a template, replacing its placeholders
+// with values (see AppTemplateValues). Returns the created files, as slash-separated
+// paths relative to dir
+func CreateApp(dir string, t *AppTemplate, values map[string]string) ([]string, error) {
+	if t.err != nil {
+		return nil, t.err
+	}
+	if _, err := os.Stat(dir); err == nil {
+		return nil, fmt.Errorf("directory %s already exists", dir)
+	}
+	if values == nil {
+		var err error
+		if values, err = AppTemplateValues(dir, t, nil, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	var created []string
+	err := fs.WalkDir(t.files, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name == "." || name == AppTemplateFile {
+			return nil
+		}
+		target := filepath.Join(dir, filepath.FromSlash(renderAppTemplate(name, values)))
+		if entry.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		data, err := fs.ReadFile(t.files, name)
+		if err != nil {
+			return err
+		}
+		mode := os.FileMode(0644)
+		info, err := entry.Info()
+		if err == nil && info.Mode()&0111 != 0 {
+			mode = 0755
+		}
+		if ext := path.Ext(name); ext == ".lnsh" || ext == ".sh" {
+			mode = 0755
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, []byte(renderAppTemplate(string(data), values)), mode); err != nil {
+			return err
+		}
+		created = append(created, renderAppTemplate(name, values))
+		return nil
+	})
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to create app from template '%s': %w", t.Name, err)
+	}
+	sort.Strings(created)
+	return created, nil
+}
@@ -0,0 +1,514 @@
+package internal
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Types of the steps that create and extract archives
+const (
+	StepTypeArchive = "archive"
+	StepTypeExtract = "extract"
+)
+
+// ArchiveSpec configures a `type: archive` step, which packs the files of src matching
+// include and not exclude into dest, or a `type: extract` step, which unpacks the archive
+// src into the directory dest. The format follows the archive's extension: .zip, .tar,
+// .tar.gz, or .tgz
+type ArchiveSpec struct {
+	Src     string   `yaml:"src,omitempty"`     // Directory to pack (default the current one), or archive to extract
+	Dest    string   `yaml:"dest,omitempty"`    // Archive to create, or directory to extract into
+	Include []string `yaml:"include,omitempty"` // Glob patterns of the files to pack (default all)
+	Exclude []string `yaml:"exclude,omitempty"` // Glob patterns of the files and directories to leave out
+}
+
+// Archive formats
+const (
+	archiveZip   = "zip"
+	archiveTar   = "tar"
+	archiveTarGz = "tar.gz"
+)
+
+// archiveFormat returns the format of an archive from its name
+func archiveFormat(name string) (string, error) {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return archiveZip, nil
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return archiveTarGz, nil
+	case strings.HasSuffix(lower, ".tar"):
+		return archiveTar, nil
+	}
+	return "", fmt.Errorf("unknown archive format of '%s' (expected .zip, .tar, .tar.gz, or .tgz)", name)
+}
+
+// CheckArchiveStep validates the archive: or extract: of a step
+func CheckArchiveStep(config *CommandConfig) error {
+	switch {
+	case config.Archive != nil && config.Type != StepTypeArchive:
+		return fmt.Errorf("archive: requires type: archive")
+	case config.Extract != nil && config.Type != StepTypeExtract:
+		return fmt.Errorf("extract: requires type: extract")
+	case config.Type == StepTypeArchive:
+		if config.Archive == nil || config.Archive.Dest == "" {
+			return fmt.Errorf("type: archive needs an archive: with dest")
+		}
+		if !strings.ContainsAny(config.Archive.Dest, "{$") {
+			if _, err := archiveFormat(config.Archive.Dest); err != nil {
+				return fmt.Errorf("archive: %v", err)
+			}
+		}
+		for _, pattern := range append(append([]string{}, config.Archive.Include...), config.Archive.Exclude...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("archive: invalid pattern '%s'", pattern)
+			}
+		}
+	case config.Type == StepTypeExtract:
+		if config.Extract == nil || config.Extract.Src == "" || config.Extract.Dest == "" {
+			return fmt.Errorf("type: extract needs an extract: with src and dest")
+		}
+		if len(config.Extract.Include) > 0 || len(config.Extract.Exclude) > 0 {
+			return fmt.Errorf("extract: include and exclude only apply to archive steps")
+		}
+		if !strings.ContainsAny(config.Extract.Src, "{$") {
+			if _, err := archiveFormat(config.Extract.Src); err != nil {
+				return fmt.Errorf("extract: %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+// archiveArgs turns the archive: of a step into the arguments of runArchive
+func archiveArgs(config *CommandConfig) ([]string, error) {
+	if err := CheckArchiveStep(config); err != nil {
+		return nil, err
+	}
+	a := config.Archive
+	src := a.Src
+	if src == "" {
+		src = "."
+	}
+	args := []string{"--src", src, "--dest", a.Dest}
+	for _, pattern := range a.Include {
+		args = append(args, "--include", pattern)
+	}
+	for _, pattern := range a.Exclude {
+		args = append(args, "--exclude", pattern)
+	}
+	return args, nil
+}
+
+// extractArgs turns the extract: of a step into the arguments of runExtract
+func extractArgs(config *CommandConfig) ([]string, error) {
+	if err := CheckArchiveStep(config); err != nil {
+		return nil, err
+	}
+	return []string{"--src", config.Extract.Src, "--dest", config.Extract.Dest}, nil
+}
+
+// listFlag is a flag that may be repeated, such as --include
+type listFlag []string
+
+func (p *listFlag) String() string     { return strings.Join(*p, ",") }
+func (p *listFlag) Set(v string) error { *p = append(*p, v); return nil }
+
+// runArchive packs the files of a directory into an archive
+func runArchive(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet(StepTypeArchive, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	src := flags.String("src", ".", "")
+	dest := flags.String("dest", "", "")
+	var include, exclude listFlag
+	flags.Var(&include, "include", "")
+	flags.Var(&exclude, "exclude", "")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("archive: %w", err)
+	}
+	format, err := archiveFormat(*dest)
+	if err != nil {
+		return fileStepError(StepTypeArchive, err)
+	}
+
+	files, err := archiveFiles(*src, *dest, include, exclude)
+	if err != nil {
+		return fileStepError(StepTypeArchive, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(*dest), 0755); err != nil {
+		return fileStepError(StepTypeArchive, err)
+	}
+	out, err := os.Create(*dest)
+	if err != nil {
+		return fileStepError(StepTypeArchive, err)
+	}
+	if format == archiveZip {
+		err = writeZip(out, *src, files)
+	} else {
+		err = writeTar(out, *src, files, format == archiveTarGz)
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(*dest)
+		return fileStepError(StepTypeArchive, err)
+	}
+	fmt.Fprintf(stdout, "archive: %s -> %s (%d file(s))\n", *src, *dest, len(files))
+	return nil
+}
+
+// archiveFiles returns the slash-separated paths, relative to src, of the files to pack:
+// those matching an include pattern, if there are any, and no exclude pattern. An
+// excluded directory is left out with everything in it, and so is the archive itself
+func archiveFiles(src, archive string, include, exclude []string) ([]string, error) {
+	archivePath, _ := filepath.Abs(archive)
+	var files []string
+	err := filepath.WalkDir(src, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, file)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if matchesAnyGlob(exclude, rel) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		if abs, _ := filepath.Abs(file); abs == archivePath {
+			return nil
+		}
+		if len(include) == 0 || matchesAnyGlob(include, rel) {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// matchesAnyGlob reports whether a slash-separated relative path matches one of the
+// patterns (see matchGlob)
+func matchesAnyGlob(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if matchGlob(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob matches a slash-separated relative path against a glob pattern, where **
+// matches any number of directories. A pattern without a slash, such as *.log, matches
+// the name of a file or directory at any depth
+func matchGlob(pattern, rel string) bool {
+	pattern = strings.TrimPrefix(filepath.ToSlash(pattern), "./")
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(rel))
+		return ok
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(rel, "/"))
+}
+
+// matchSegments matches path segments against pattern segments, ** matching any number
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
+
+// writeZip writes the files of src to a zip archive
+func writeZip(out io.Writer, src string, files []string) error {
+	zw := zip.NewWriter(out)
+	for _, rel := range files {
+		file := filepath.Join(src, filepath.FromSlash(rel))
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name, header.Method = rel, zip.Deflate
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		if err := copyFileTo(w, file); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// writeTar writes the files of src to a tar archive, compressed with gzip if gz is set
+func writeTar(out io.Writer, src string, files []string, gz bool) error {
+	var gw *gzip.Writer
+	if gz {
+		gw = gzip.NewWriter(out)
+		out = gw
+	}
+	tw := tar.NewWriter(out)
+	for _, rel := range files {
+		file := filepath.Join(src, filepath.FromSlash(rel))
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = rel
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if err := copyFileTo(tw, file); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if gw != nil {
+		return gw.Close()
+	}
+	return nil
+}
+
+// copyFileTo copies the contents of a file to w
+func copyFileTo(w io.Writer, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// runExtract unpacks an archive into a directory
+func runExtract(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet(StepTypeExtract, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	src := flags.String("src", "", "")
+	dest := flags.String("dest", "", "")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("extract: %w", err)
+	}
+	format, err := archiveFormat(*src)
+	if err != nil {
+		return fileStepError(StepTypeExtract, err)
+	}
+	var files int
+	if format == archiveZip {
+		files, err = extractZip(*src, *dest)
+	} else {
+		files, err = extractTar(*src, *dest, format == archiveTarGz)
+	}
+	if err != nil {
+		return fileStepError(StepTypeExtract, err)
+	}
+	fmt.Fprintf(stdout, "extract: %s -> %s (%d file(s))\n", *src, *dest, files)
+	return nil
+}
+
+// extractPath returns where an entry of an archive goes in dest, refusing names that
+// would escape it
+func extractPath(dest, name string) (string, error) {
+	clean := path.Clean(strings.ReplaceAll(name, `\`, "/"))
+	if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") || filepath.VolumeName(clean) != "" {
+		return "", fmt.Errorf("refusing to extract '%s' outside of %s", name, dest)
+	}
+	return filepath.Join(dest, filepath.FromSlash(clean)), nil
+}
+
+// resolvePath returns p with the symbolic links of its longest existing part resolved,
+// which is where writing to p actually goes
+func resolvePath(p string) (string, error) {
+	existing, rest := filepath.Clean(p), ""
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = parent
+	}
+	resolved, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(resolved, rest), nil
+}
+
+// checkExtractTarget refuses an entry whose target resolves outside dest through the
+// links extracted before it, such as a/b/file after the links a -> . and a/b -> ..
+// It returns the resolved target
+func checkExtractTarget(dest, target, name string) (string, error) {
+	realDest, err := resolvePath(dest)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := resolvePath(target)
+	if err != nil || !pathWithin(realDest, resolved) {
+		return "", fmt.Errorf("refusing to extract '%s' outside of %s", name, dest)
+	}
+	return resolved, nil
+}
+
+// pathWithin reports whether p is dir or inside it
+func pathWithin(dir, p string) bool {
+	rel, err := filepath.Rel(dir, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+// writeExtracted writes the contents of an extracted file, creating its parents
+func writeExtracted(target string, r io.Reader, mode fs.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm()|0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// extractZip unpacks a zip archive into dest, returning the number of files
+func extractZip(src, dest string) (int, error) {
+	zr, err := zip.OpenReader(src)
+	if err != nil {
+		return 0, err
+	}
+	defer zr.Close()
+	files := 0
+	for _, f := range zr.File {
+		target, err := extractPath(dest, f.Name)
+		if err != nil {
+			return files, err
+		}
+		if _, err := checkExtractTarget(dest, target, f.Name); err != nil {
+			return files, err
+		}
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return files, err
+			}
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return files, err
+		}
+		err = writeExtracted(target, r, f.Mode())
+		r.Close()
+		if err != nil {
+			return files, err
+		}
+		files++
+	}
+	return files, nil
+}
+
+// extractTar unpacks a tar archive, gzip-compressed if gz is set, into dest, returning
+// the number of files. Symbolic links must point inside dest, and no entry may be
+// written through them to outside of it
+func extractTar(src, dest string, gz bool) (int, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if gz {
+		gr, err := gzip.NewReader(f)
+		if err != nil {
+			return 0, err
+		}
+		defer gr.Close()
+		r = gr
+	}
+
+	tr := tar.NewReader(r)
+	files := 0
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return files, err
+		}
+		target, err := extractPath(dest, header.Name)
+		if err != nil {
+			return files, err
+		}
+		resolved, err := checkExtractTarget(dest, target, header.Name)
+		if err != nil {
+			return files, err
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, 0755)
+		case tar.TypeReg:
+			err = writeExtracted(target, tr, header.FileInfo().Mode())
+			files++
+		case tar.TypeSymlink:
+			// The link is checked from where it is created, after the links before it
+			linked := path.Join(path.Dir(strings.ReplaceAll(header.Name, `\`, "/")), header.Linkname)
+			_, err = extractPath(dest, linked)
+			if err == nil && !path.IsAbs(header.Linkname) {
+				_, err = checkExtractTarget(dest, filepath.Join(filepath.Dir(resolved), filepath.FromSlash(header.Linkname)), header.Name)
+			}
+			if err != nil || path.IsAbs(header.Linkname) {
+				return files, fmt.Errorf("refusing to extract link '%s' to '%s' outside of %s", header.Name, header.Linkname, dest)
+			}
+			if err = os.MkdirAll(filepath.Dir(target), 0755); err == nil {
+				os.Remove(target)
+				err = os.Symlink(header.Linkname, target)
+			}
+		}
+		if err != nil {
+			return files, err
+		}
+	}
+}
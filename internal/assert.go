@@ -0,0 +1,173 @@
+package internal
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// StepTypeAssert is the type of the steps that check the postconditions of the steps
+// before them, failing when one does not hold
+const StepTypeAssert = "assert"
+
+// AssertSpec is the assert: of a `type: assert` step: the checks that must all hold
+type AssertSpec struct {
+	FileExists     string `yaml:"file_exists,omitempty"`     // Path of a file or directory that must exist
+	FileMissing    string `yaml:"file_missing,omitempty"`    // Path that must not exist
+	Step           string `yaml:"step,omitempty"`            // Earlier step with capture: true whose output output_contains checks
+	OutputContains string `yaml:"output_contains,omitempty"` // Text the captured output of step must contain
+	ExitCode       *int   `yaml:"exit_code,omitempty"`       // Exit code the step before must have exited with
+	That           string `yaml:"that,omitempty"`            // Expression that must hold, like a when: condition
+	Message        string `yaml:"message,omitempty"`         // Reported instead of the failed checks
+}
+
+// CheckAssertStep validates the assert: of a step
+func CheckAssertStep(config *CommandConfig) error {
+	if config.Assert != nil && config.Type != StepTypeAssert {
+		return fmt.Errorf("assert: requires type: assert")
+	}
+	if config.Type != StepTypeAssert {
+		return nil
+	}
+	a := config.Assert
+	switch {
+	case a == nil || (a.FileExists == "" && a.FileMissing == "" && a.OutputContains == "" && a.ExitCode == nil && a.That == ""):
+		return fmt.Errorf("type: assert needs an assert: with file_exists, file_missing, output_contains, exit_code, or that")
+	case a.OutputContains != "" && a.Step == "":
+		return fmt.Errorf("assert: output_contains needs the step whose captured output it checks")
+	case a.Step != "" && a.OutputContains == "":
+		return fmt.Errorf("assert: step only applies to output_contains")
+	case a.ExitCode != nil && (*a.ExitCode < 0 || *a.ExitCode > 255):
+		return fmt.Errorf("assert: invalid exit_code %d (expected 0-255)", *a.ExitCode)
+	}
+	if a.That != "" {
+		if _, err := EvaluateExpression(a.That, nil, nil); err != nil {
+			return fmt.Errorf("assert: that: %v", err)
+		}
+	}
+	return nil
+}
+
+// assertArgs turns the assert: of a step into the arguments of runAssert
+func assertArgs(config *CommandConfig) ([]string, error) {
+	if err := CheckAssertStep(config); err != nil {
+		return nil, err
+	}
+	a := config.Assert
+	var args []string
+	for _, option := range [][2]string{{"--file-exists", a.FileExists}, {"--file-missing", a.FileMissing}, {"--output-contains", a.OutputContains}, {"--that", a.That}, {"--message", a.Message}} {
+		if option[1] != "" {
+			args = append(args, option[0], option[1])
+		}
+	}
+	if a.Step != "" {
+		args = append(args, "--step", stepInputKey(config, a.Step))
+	}
+	if a.ExitCode != nil {
+		args = append(args, "--exit-code", strconv.Itoa(*a.ExitCode))
+	}
+	return args, nil
+}
+
+// runAssert runs the checks of an assert step, printing each that holds and failing with
+// those that do not
+func runAssert(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet(StepTypeAssert, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	fileExists := flags.String("file-exists", "", "")
+	fileMissing := flags.String("file-missing", "", "")
+	step := flags.String("step", "", "")
+	outputContains := flags.String("output-contains", "", "")
+	that := flags.String("that", "", "")
+	message := flags.String("message", "", "")
+	exitCode := flags.Int("exit-code", -1, "")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("assert: %w", err)
+	}
+
+	var failed []string
+	check := func(ok bool, passed, failure string) {
+		if ok {
+			fmt.Fprintf(stdout, "assert: %s\n", passed)
+		} else {
+			failed = append(failed, failure)
+		}
+	}
+	if *fileExists != "" {
+		_, err := os.Stat(*fileExists)
+		check(err == nil, *fileExists+" exists", *fileExists+" does not exist")
+	}
+	if *fileMissing != "" {
+		_, err := os.Stat(*fileMissing)
+		check(os.IsNotExist(err), *fileMissing+" does not exist", *fileMissing+" exists")
+	}
+	if *outputContains != "" {
+		if output, ok := capturedStepOutput(*step); !ok {
+			failed = append(failed, fmt.Sprintf("step '%s' has not run or does not have capture: true", *step))
+		} else {
+			check(strings.Contains(string(output), *outputContains),
+				fmt.Sprintf("output of %s contains %q", *step, *outputContains),
+				fmt.Sprintf("output of %s does not contain %q", *step, *outputContains))
+		}
+	}
+	if *exitCode >= 0 {
+		got := lastStepExitCode()
+		check(got == *exitCode, fmt.Sprintf("exit code is %d", got), fmt.Sprintf("exit code is %d, expected %d", got, *exitCode))
+	}
+	if *that != "" {
+		holds, err := EvaluateCondition(*that)
+		if err != nil {
+			return &StepExitError{Code: 1, Err: fmt.Errorf("assert: %w", err)}
+		}
+		check(holds, *that, *that+" does not hold")
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+	if *message != "" {
+		return &StepExitError{Code: 1, Err: fmt.Errorf("assertion failed: %s", *message)}
+	}
+	return &StepExitError{Code: 1, Err: fmt.Errorf("assertion failed: %s", strings.Join(failed, "; "))}
+}
+
+// AssertionSummary counts the outcomes of the assert steps of a run, for linea test
+// --run-asserts
+type AssertionSummary struct {
+	Passed   int
+	Failed   []StepResult // The results of the assert steps that failed
+	NotRun   int          // Skipped, or not reached because a step before failed
+	Failures int          // Failed steps other than assert steps
+}
+
+// SummarizeAssertions returns the outcomes of the assert steps among the results of
+// running configs
+func SummarizeAssertions(configs []*CommandConfig, results []StepResult) AssertionSummary {
+	var summary AssertionSummary
+	for i, result := range results {
+		if i >= len(configs) {
+			break
+		}
+		assertion := configs[i].Type == StepTypeAssert
+		switch {
+		case result.Status == StepFailed && !assertion:
+			summary.Failures++
+		case !assertion:
+		case result.Status == StepSucceeded:
+			summary.Passed++
+		case result.Status == StepFailed:
+			summary.Failed = append(summary.Failed, result)
+		default:
+			summary.NotRun++
+		}
+	}
+	for i := len(results); i < len(configs); i++ {
+		if configs[i].Type == StepTypeAssert {
+			summary.NotRun++
+		}
+	}
+	return summary
+}
@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	runStartOnce sync.Once
+	runStart     time.Time
+	runUUID      string
+
+	lastExitCode int32 // Exit code of the last step run, for {exit_code}
+)
+
+// recordExitCode stores the exit code of a finished step for the steps after it
+func recordExitCode(code int) {
+	atomic.StoreInt32(&lastExitCode, int32(code))
+}
+
+// lastStepExitCode returns the exit code of the last step run
+func lastStepExitCode() int {
+	return int(atomic.LoadInt32(&lastExitCode))
+}
+
+// initRunValues fixes the timestamp and UUID once per process so every step of a run sees the same values
+func initRunValues() {
+	runStartOnce.Do(func() {
+		runStart = time.Now().UTC()
+		runUUID = newUUID()
+	})
+}
+
+// newUUID returns a random (version 4) UUID
+func newUUID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "00000000-0000-4000-8000-000000000000"
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// BuiltinVariables returns the always-available variables for a workflow
+// YAML variables and -s/--set values take precedence over these
+func BuiltinVariables(config *CommandConfig) map[string]string {
+	initRunValues()
+
+	cwd, _ := os.Getwd()
+	vars := map[string]string{
+		"os":        runtime.GOOS,
+		"arch":      runtime.GOARCH,
+		"cwd":       cwd,
+		"timestamp": runStart.Format("20060102T150405Z"),
+		"date":      runStart.Format("2006-01-02"),
+		"uuid":      runUUID,
+		"cache_dir": SharedCacheDir(),
+		"exit_code": strconv.Itoa(lastStepExitCode()),
+	}
+
+	if config != nil && config.SourceFile != "" {
+		base := filepath.Base(config.SourceFile)
+		vars["workflow_name"] = strings.TrimSuffix(base, filepath.Ext(base))
+		if dir, err := filepath.Abs(filepath.Dir(config.SourceFile)); err == nil {
+			vars["workflow_dir"] = dir
+		}
+	}
+	for k, v := range gitVariables(gitDir(config)) {
+		vars[k] = v
+	}
+
+	return vars
+}
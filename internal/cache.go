@@ -0,0 +1,120 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SharedCacheDir returns the managed cache directory exposed to workflows as {cache_dir}
+// Every top-level file or directory in it is a cache key that can be listed and evicted
+func SharedCacheDir() string {
+	return filepath.Join(UserPaths().Cache, "shared")
+}
+
+// EnsureSharedCacheDir creates the shared cache directory so steps can write to {cache_dir}
+func EnsureSharedCacheDir() error {
+	if err := os.MkdirAll(SharedCacheDir(), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	return nil
+}
+
+// CacheEntry is one key of the shared cache
+type CacheEntry struct {
+	Key      string
+	Path     string
+	Size     int64     // Total size of the files under the key
+	Files    int       // Number of files under the key
+	Modified time.Time // Most recent modification of any file under the key
+}
+
+// ListCacheEntries returns the keys of the shared cache, sorted by key
+// A missing cache directory is treated as an empty cache
+func ListCacheEntries() ([]CacheEntry, error) {
+	dir := SharedCacheDir()
+	items, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache directory %s: %w", dir, err)
+	}
+
+	entries := make([]CacheEntry, 0, len(items))
+	for _, item := range items {
+		entry, err := cacheEntry(filepath.Join(dir, item.Name()))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	return entries, nil
+}
+
+// cacheEntry measures the file or directory at path
+func cacheEntry(path string) (CacheEntry, error) {
+	entry := CacheEntry{Key: filepath.Base(path), Path: path}
+	err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(entry.Modified) {
+			entry.Modified = info.ModTime()
+		}
+		if !info.IsDir() {
+			entry.Size += info.Size()
+			entry.Files++
+		}
+		return nil
+	})
+	if err != nil {
+		return CacheEntry{}, fmt.Errorf("failed to read cache entry %s: %w", path, err)
+	}
+	return entry, nil
+}
+
+// RemoveCacheEntry evicts one key from the shared cache
+func RemoveCacheEntry(key string) (CacheEntry, error) {
+	if key == "" || key == "." || key == ".." || strings.ContainsAny(key, `/\`) {
+		return CacheEntry{}, fmt.Errorf("invalid cache key '%s'", key)
+	}
+
+	path := filepath.Join(SharedCacheDir(), key)
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		return CacheEntry{}, fmt.Errorf("cache key %s not found (see linea cache ls)", key)
+	}
+	entry, err := cacheEntry(path)
+	if err != nil {
+		return CacheEntry{}, err
+	}
+	if err := os.RemoveAll(path); err != nil {
+		return CacheEntry{}, fmt.Errorf("failed to remove cache entry %s: %w", path, err)
+	}
+	return entry, nil
+}
+
+// CleanCache evicts every key not modified within olderThan of now, or every key if olderThan is zero
+// Returns the evicted entries
+func CleanCache(olderThan time.Duration, now time.Time) ([]CacheEntry, error) {
+	entries, err := ListCacheEntries()
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []CacheEntry
+	for _, entry := range entries {
+		if olderThan > 0 && now.Sub(entry.Modified) < olderThan {
+			continue
+		}
+		if err := os.RemoveAll(entry.Path); err != nil {
+			return removed, fmt.Errorf("failed to remove cache entry %s: %w", entry.Path, err)
+		}
+		removed = append(removed, entry)
+	}
+	return removed, nil
+}
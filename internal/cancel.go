@@ -0,0 +1,208 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultGracePeriod is how long a command interrupted by Ctrl+C or SIGTERM has to exit
+// before it is killed
+const DefaultGracePeriod = 10 * time.Second
+
+// CancelledError is the error of a run cancelled by a signal: Ctrl+C, or SIGTERM from a
+// service manager or CI runner
+type CancelledError struct {
+	Signal os.Signal
+}
+
+func (e *CancelledError) Error() string {
+	return fmt.Sprintf("run cancelled (%v)", e.Signal)
+}
+
+// ExitCode returns 128 plus the signal number, like a shell: 130 for Ctrl+C, 143 for SIGTERM
+func (e *CancelledError) ExitCode() int {
+	if sig, ok := e.Signal.(syscall.Signal); ok {
+		return 128 + int(sig)
+	}
+	return 1
+}
+
+// IsCancelled reports whether err is, or wraps, a *CancelledError
+func IsCancelled(err error) bool {
+	var cancelled *CancelledError
+	return errors.As(err, &cancelled)
+}
+
+// cancellation is the signal state of a run while HandleSignals is active
+var cancellation struct {
+	sync.Mutex
+	signal      os.Signal       // First signal received; nil while the run is not cancelled
+	grace       time.Duration   // Time a signalled command gets before it is killed
+	group       *processGroup   // Command running in the foreground, nil between commands
+	interactive bool            // process is an interactive step, which gets Ctrl+C itself
+	signalled   bool            // process has been sent a signal
+	kill        *time.Timer     // Kills process at the end of the grace period
+	waiters     []chan struct{} // Closed by the next signal, see interrupted
+}
+
+// HandleSignals makes SIGINT (Ctrl+C) and SIGTERM cancel the run instead of killing linea:
+// the command running is sent the signal and killed if it has not exited after grace, the
+// remaining steps are not run except after: and on_failure: steps, and Cancelled returns
+// the error of the run. A second signal kills the command right away
+// stop restores the default behavior and forgets the cancellation
+func HandleSignals(grace time.Duration) (stop func()) {
+	cancellation.Lock()
+	cancellation.signal, cancellation.grace, cancellation.waiters = nil, grace, nil
+	cancellation.Unlock()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				cancelRun(sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(done)
+		cancellation.Lock()
+		cancellation.signal, cancellation.waiters = nil, nil
+		cancellation.Unlock()
+	}
+}
+
+// CancelOnDone makes the end of ctx cancel the run like SIGTERM does with HandleSignals,
+// for runs started from Go code. Signals are left alone. stop forgets the cancellation
+// Runs share this state, so a process runs one workflow at a time
+func CancelOnDone(ctx context.Context, grace time.Duration) (stop func()) {
+	cancellation.Lock()
+	cancellation.signal, cancellation.grace, cancellation.waiters = nil, grace, nil
+	cancellation.Unlock()
+
+	done, exited := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+			cancelRun(syscall.SIGTERM)
+		case <-done:
+		}
+	}()
+	return func() {
+		close(done)
+		<-exited
+		cancellation.Lock()
+		cancellation.signal, cancellation.waiters = nil, nil
+		cancellation.Unlock()
+	}
+}
+
+// Cancelled returns the error of a run cancelled by a signal, or nil
+func Cancelled() error {
+	cancellation.Lock()
+	defer cancellation.Unlock()
+	if cancellation.signal == nil {
+		return nil
+	}
+	return &CancelledError{Signal: cancellation.signal}
+}
+
+// cancelRun handles a signal received while HandleSignals is active
+func cancelRun(sig os.Signal) {
+	cancellation.Lock()
+	defer cancellation.Unlock()
+
+	// Ctrl+C in an interactive step is for the command, which decides whether to exit
+	if cancellation.group != nil && cancellation.interactive && sig == os.Interrupt {
+		return
+	}
+	if cancellation.signal == nil {
+		cancellation.signal = sig
+	}
+	for _, waiter := range cancellation.waiters {
+		close(waiter)
+	}
+	cancellation.waiters = nil
+
+	group := cancellation.group
+	if group == nil {
+		return
+	}
+	if cancellation.signalled {
+		group.kill()
+		return
+	}
+	cancellation.signalled = true
+	// A command in linea's process group got Ctrl+C from the terminal already
+	if group.own || sig != os.Interrupt {
+		group.signal(sig)
+	}
+	cancellation.kill = time.AfterFunc(cancellation.grace, func() { group.kill() })
+}
+
+// trackProcess makes a started command the one that signals are forwarded to, with the
+// processes it starts, until untrack is called, once it has exited. Commands started
+// after the run was cancelled, like cleanup steps, only get the signals that arrive while
+// they run
+func trackProcess(group *processGroup, interactive bool) (untrack func()) {
+	cancellation.Lock()
+	defer cancellation.Unlock()
+	cancellation.group, cancellation.interactive, cancellation.signalled = group, interactive, false
+	return func() {
+		cancellation.Lock()
+		defer cancellation.Unlock()
+		if cancellation.kill != nil {
+			cancellation.kill.Stop()
+			cancellation.kill = nil
+		}
+		cancellation.group = nil
+	}
+}
+
+// interrupted returns a channel closed when the next signal cancels the run, for steps
+// that wait without a command to forward the signal to, like type: healthcheck
+func interrupted() <-chan struct{} {
+	cancellation.Lock()
+	defer cancellation.Unlock()
+	waiter := make(chan struct{})
+	cancellation.waiters = append(cancellation.waiters, waiter)
+	return waiter
+}
+
+// StepStatusAfter decides whether a step runs, given whether an earlier step failed and
+// whether the run goes on after failures: after: steps always run, on_failure: steps
+// only after a failure or once the run is cancelled, and other steps only while the run
+// is not cancelled and nothing failed (or keepGoing is set)
+// It returns "" if the step runs, or else the status it gets instead
+func StepStatusAfter(config *CommandConfig, failed, keepGoing bool) string {
+	cancelled := Cancelled() != nil
+	switch {
+	case config.OnFailure && !failed && !cancelled:
+		return StepSkipped
+	case config.After || config.OnFailure:
+		return ""
+	case cancelled, failed && !keepGoing:
+		return StepNotRun
+	}
+	return ""
+}
+
+// CheckCleanup validates the after: and on_failure: flags of a step
+func CheckCleanup(config *CommandConfig) error {
+	if config.After && config.OnFailure {
+		return fmt.Errorf("after and on_failure cannot be combined (on_failure steps already run after a failure)")
+	}
+	return nil
+}
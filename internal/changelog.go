@@ -0,0 +1,105 @@
+package internal
+
+import (
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maxChangelogEntries is how many entries are shown when the entries added since the last
+// run cannot be told apart, e.g. after the changelog was rewritten
+const maxChangelogEntries = 3
+
+// ChangelogEntry is one entry of a workflow's changelog:, newest first
+type ChangelogEntry struct {
+	Version     string `yaml:"version,omitempty"`
+	Date        string `yaml:"date,omitempty"`
+	Description string `yaml:"description,omitempty"` // `- text` is short for `- {description: text}`
+}
+
+// UnmarshalYAML accepts a string as the description, or a mapping
+func (e *ChangelogEntry) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		e.Description = value.Value
+		return nil
+	}
+	type plain ChangelogEntry
+	return value.Decode((*plain)(e))
+}
+
+// String formats an entry as "version (date): description", leaving out what is not set
+func (e ChangelogEntry) String() string {
+	label := e.Version
+	if e.Date != "" {
+		if label != "" {
+			label += " "
+		}
+		label += "(" + e.Date + ")"
+	}
+	if label == "" {
+		return e.Description
+	}
+	if e.Description == "" {
+		return label
+	}
+	return label + ": " + e.Description
+}
+
+// WorkflowChanges describes how a workflow file differs from its last recorded run
+type WorkflowChanges struct {
+	Hash    string           // Content hash of the file, recorded with the run
+	Head    string           // Newest changelog entry, recorded with the run
+	Changed bool             // The file changed since the last recorded run
+	Last    *RunRecord       // The last recorded run of the file, nil if none
+	Entries []ChangelogEntry // Changelog entries added since the last recorded run
+}
+
+// CheckWorkflowChanges compares the workflow file at path with the last run of it in
+// records. A workflow run for the first time, or recorded without a hash, is not changed
+// Unreadable files return nil; running the workflow reports the problem
+func CheckWorkflowChanges(path string, records []RunRecord) *WorkflowChanges {
+	hash, err := WorkflowFileHash(path)
+	if err != nil {
+		return nil
+	}
+	changes := &WorkflowChanges{Hash: hash}
+
+	var changelog []ChangelogEntry
+	if configs, err := ParseMultiYAML(path); err == nil {
+		for _, config := range configs {
+			if len(config.Changelog) > 0 {
+				changelog = config.Changelog
+				break
+			}
+		}
+	}
+	if len(changelog) > 0 {
+		changes.Head = changelog[0].String()
+	}
+
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	for i := len(records) - 1; i >= 0; i-- {
+		if records[i].Path == path {
+			changes.Last = &records[i]
+			break
+		}
+	}
+	if changes.Last == nil || changes.Last.WorkflowHash == "" || changes.Last.WorkflowHash == changes.Hash {
+		return changes
+	}
+
+	changes.Changed = true
+	for i, entry := range changelog {
+		if entry.String() == changes.Last.ChangelogHead {
+			changes.Entries = changelog[:i]
+			return changes
+		}
+	}
+	if len(changelog) > maxChangelogEntries {
+		changelog = changelog[:maxChangelogEntries]
+	}
+	changes.Entries = changelog
+	return changes
+}
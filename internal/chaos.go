@@ -0,0 +1,146 @@
+package internal
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultChaosMaxDelay is the longest delay chaos mode injects unless max_delay is set
+const DefaultChaosMaxDelay = 5 * time.Second
+
+// ChaosTimeoutExitCode is the exit code of a simulated timeout, that of timeout(1)
+const ChaosTimeoutExitCode = 124
+
+// ChaosOptions are the faults chaos mode injects, each at a rate between 0 and 1 per step
+type ChaosOptions struct {
+	Fail     float64       // Fail the step with exit code 1 instead of running it
+	Delay    float64       // Wait up to MaxDelay before running the step
+	Timeout  float64       // Fail the step as timed out (exit code 124) instead of running it
+	MaxDelay time.Duration // Longest injected delay
+	Seed     int64         // Seed of the random faults; the same seed injects the same faults
+}
+
+// ParseChaos parses a --chaos value: comma-separated fail=, delay=, and timeout= rates
+// (0.1 or 10%), max_delay=<duration>, and seed=<n>; a bare rate is the fail rate
+// Without a seed, one is picked from the clock
+func ParseChaos(spec string) (ChaosOptions, error) {
+	opts := ChaosOptions{MaxDelay: DefaultChaosMaxDelay}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		key, value, found := strings.Cut(part, "=")
+		if !found {
+			key, value = "fail", part
+		}
+		var err error
+		switch key {
+		case "fail":
+			opts.Fail, err = parseChaosRate(value)
+		case "delay":
+			opts.Delay, err = parseChaosRate(value)
+		case "timeout":
+			opts.Timeout, err = parseChaosRate(value)
+		case "max_delay":
+			opts.MaxDelay, err = ParseAge(value)
+		case "seed":
+			opts.Seed, err = strconv.ParseInt(value, 10, 64)
+		default:
+			return opts, fmt.Errorf("invalid chaos option '%s' (expected fail, delay, timeout, max_delay, or seed)", key)
+		}
+		if err != nil {
+			return opts, fmt.Errorf("invalid chaos option '%s': %w", part, err)
+		}
+	}
+	if opts.Fail == 0 && opts.Delay == 0 && opts.Timeout == 0 {
+		return opts, fmt.Errorf("chaos mode needs a fail, delay, or timeout rate above 0")
+	}
+	if opts.Seed == 0 {
+		opts.Seed = time.Now().UnixNano()
+	}
+	return opts, nil
+}
+
+// parseChaosRate parses a rate between 0 and 1, or a percentage
+func parseChaosRate(value string) (float64, error) {
+	percent := strings.HasSuffix(value, "%")
+	rate, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+	if err != nil {
+		return 0, fmt.Errorf("expected a rate such as 0.1 or 10%%")
+	}
+	if percent {
+		rate /= 100
+	}
+	if rate < 0 || rate > 1 {
+		return 0, fmt.Errorf("rate must be between 0 and 1 (0%% and 100%%)")
+	}
+	return rate, nil
+}
+
+// String describes the options, e.g. "fail 10%, delay 25% up to 5s, seed 42"
+func (o ChaosOptions) String() string {
+	var parts []string
+	if o.Fail > 0 {
+		parts = append(parts, fmt.Sprintf("fail %g%%", o.Fail*100))
+	}
+	if o.Delay > 0 {
+		parts = append(parts, fmt.Sprintf("delay %g%% up to %s", o.Delay*100, o.MaxDelay))
+	}
+	if o.Timeout > 0 {
+		parts = append(parts, fmt.Sprintf("timeout %g%%", o.Timeout*100))
+	}
+	parts = append(parts, fmt.Sprintf("seed %d", o.Seed))
+	return strings.Join(parts, ", ")
+}
+
+// ChaosRunner injects random faults into the steps run by another runner, to check that
+// a workflow's on_failure: and after: steps handle them. Those cleanup steps only get
+// delays, so that they run as they would in a real incident
+type ChaosRunner struct {
+	Runner  Runner
+	Options ChaosOptions
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// NewChaosRunner wraps runner with the faults of opts
+func NewChaosRunner(runner Runner, opts ChaosOptions) *ChaosRunner {
+	return &ChaosRunner{Runner: runner, Options: opts, rand: rand.New(rand.NewSource(opts.Seed))}
+}
+
+// RunStep runs a step through the wrapped runner, unless a fault replaces it
+// Every step draws the same random numbers whatever happens, so that a seed injects the
+// same faults into the same steps
+func (c *ChaosRunner) RunStep(step *StepCommand) error {
+	c.mu.Lock()
+	timeout, fail, delay, wait := c.rand.Float64(), c.rand.Float64(), c.rand.Float64(), c.rand.Float64()
+	c.mu.Unlock()
+
+	cleanup := step.Config.After || step.Config.OnFailure
+	switch {
+	case !cleanup && timeout < c.Options.Timeout:
+		c.report(step, "simulated timeout")
+		return &StepExitError{Code: ChaosTimeoutExitCode, Err: fmt.Errorf("chaos: step timed out (simulated)")}
+	case !cleanup && fail < c.Options.Fail:
+		c.report(step, "injected failure")
+		return &StepExitError{Code: 1, Err: fmt.Errorf("chaos: injected failure")}
+	case delay < c.Options.Delay:
+		d := time.Duration(wait * float64(c.Options.MaxDelay)).Round(time.Millisecond)
+		c.report(step, fmt.Sprintf("injected delay of %s", d))
+		select {
+		case <-time.After(d):
+		case <-interrupted():
+		}
+	}
+	return c.Runner.RunStep(step)
+}
+
+// report tells the step's stderr about a fault
+func (c *ChaosRunner) report(step *StepCommand, fault string) {
+	if step.Stderr != nil {
+		fmt.Fprintf(step.Stderr, "chaos: %s\n", fault)
+	}
+}
@@ -0,0 +1,202 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"linea/internal/store"
+)
+
+// CheckpointsDirName is the directory of run checkpoints inside the jobs directory
+// (.linea/run/checkpoints)
+const CheckpointsDirName = "checkpoints"
+
+// Checkpoint is the state of a run of a multi-step workflow, saved after every step so that
+// `linea run --resume` can continue a failed or interrupted run from its first step that did
+// not complete. It is removed once a run of the workflow succeeds. The file is private, as
+// it holds the -s values and captured output of the run unmasked
+type Checkpoint struct {
+	Workflow  string            `json:"workflow"`
+	Path      string            `json:"path"`                // Workflow file
+	Hash      string            `json:"hash"`                // Content hash of the workflow file; a changed file cannot resume
+	Variables map[string]string `json:"variables,omitempty"` // -s values of the run
+	Start     time.Time         `json:"start"`               // Of the run that was checkpointed first
+	Done      []int             `json:"done"`                // Steps (1-based) that completed before the first failure
+	Outputs   map[string][]byte `json:"outputs,omitempty"`   // Captured stdout of the done steps, for stdin: {step: name}
+	ExitCode  int               `json:"exit_code"`           // Of the last step that ran, for {exit_code}
+
+	file   string
+	done   map[int]bool
+	failed bool // A step of this run failed: the steps after it are not done
+}
+
+// CheckpointPath returns the checkpoint file of a workflow file
+func CheckpointPath(path string) string {
+	return workflowStateFile(path, CheckpointsDirName, ".json")
+}
+
+// NewCheckpoint starts the checkpoint of a run of a workflow file with the -s values vars
+func NewCheckpoint(path string, vars map[string]string) (*Checkpoint, error) {
+	hash, err := WorkflowFileHash(path)
+	if err != nil {
+		return nil, err
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return &Checkpoint{
+		Workflow:  WorkflowName(path),
+		Path:      path,
+		Hash:      hash,
+		Variables: vars,
+		Start:     time.Now().UTC(),
+		Done:      []int{},
+		file:      CheckpointPath(path),
+		done:      map[int]bool{},
+	}, nil
+}
+
+// LoadCheckpoint reads the checkpoint of a workflow file, or returns nil if it has none
+// A workflow file changed since the checkpoint cannot resume from it, as its steps may
+// have changed
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	file := CheckpointPath(path)
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var checkpoint Checkpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint %s: %w", file, err)
+	}
+	hash, err := WorkflowFileHash(path)
+	if err != nil {
+		return nil, err
+	}
+	if hash != checkpoint.Hash {
+		return nil, fmt.Errorf("%s changed since the run started at %s; run it without --resume to start over",
+			WorkflowName(path), checkpoint.Start.Local().Format("2006-01-02 15:04:05"))
+	}
+	checkpoint.file = file
+	checkpoint.done = make(map[int]bool, len(checkpoint.Done))
+	for _, index := range checkpoint.Done {
+		checkpoint.done[index] = true
+	}
+	return &checkpoint, nil
+}
+
+// ResumeVariables returns the -s values of the checkpointed run, overridden by vars
+func (c *Checkpoint) ResumeVariables(vars map[string]string) map[string]string {
+	merged := make(map[string]string, len(c.Variables)+len(vars))
+	for k, v := range c.Variables {
+		merged[k] = v
+	}
+	for k, v := range vars {
+		merged[k] = v
+	}
+	c.Variables = merged
+	return merged
+}
+
+// restore gives the steps after the done ones the captured output and exit code they
+// would have seen in the checkpointed run
+func (c *Checkpoint) restore() {
+	if c == nil || len(c.Done) == 0 {
+		return
+	}
+	for key, output := range c.Outputs {
+		recordStepOutput(key, output)
+	}
+	recordExitCode(c.ExitCode)
+}
+
+// isDone reports whether a step completed in the checkpointed run and is skipped on resume
+// Cleanup steps are never done: they run again in every run
+func (c *Checkpoint) isDone(index int, config *CommandConfig) bool {
+	return c != nil && !c.failed && !config.After && !config.OnFailure && c.done[index]
+}
+
+// record saves the outcome of a step. The steps that succeed or are skipped by their
+// when: before the first failure are done
+func (c *Checkpoint) record(config *CommandConfig, result StepResult) {
+	if c == nil || config.After || config.OnFailure || result.Status == StepNotRun {
+		return
+	}
+	if result.Status == StepFailed {
+		c.failed = true
+	}
+	if c.failed {
+		c.save()
+		return
+	}
+	if !c.done[result.Index] {
+		c.done[result.Index] = true
+		c.Done = append(c.Done, result.Index)
+		sort.Ints(c.Done)
+	}
+	if config.Capture && config.Name != "" {
+		for _, key := range stepOutputKeys(config) {
+			if output, ok := capturedStepOutput(key); ok {
+				if c.Outputs == nil {
+					c.Outputs = map[string][]byte{}
+				}
+				c.Outputs[key] = output
+			}
+		}
+	}
+	if result.Status == StepSucceeded {
+		c.ExitCode = result.ExitCode
+	}
+	c.save()
+}
+
+// save writes the checkpoint file; a checkpoint that cannot be written only means that
+// the run cannot be resumed, so errors are ignored
+func (c *Checkpoint) save() {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(c.file), 0755); err != nil {
+		return
+	}
+	store.WriteFile(c.file, append(data, '\n'), 0600)
+}
+
+// Finish removes the checkpoint after a successful run; after a failure it is kept for
+// --resume
+func (c *Checkpoint) Finish(err error) {
+	if c == nil || err != nil {
+		return
+	}
+	os.Remove(c.file)
+}
+
+// NextStep returns the first step a resumed run runs: the one after the done steps
+func (c *Checkpoint) NextStep() int {
+	next := 1
+	for c != nil && c.done[next] {
+		next++
+	}
+	return next
+}
+
+// WorkflowFileHash returns the content hash of a workflow file, as recorded in the run
+// history and checkpoints
+func WorkflowFileHash(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:12]), nil
+}
@@ -0,0 +1,76 @@
+package internal
+
+import (
+	"os"
+)
+
+// ANSI color codes used in terminal output
+const (
+	ColorRed    = "31"
+	ColorGreen  = "32"
+	ColorYellow = "33"
+	ColorDim    = "2"
+)
+
+// Color modes of the color setting
+const (
+	ColorAuto   = "auto"   // Color on terminals only
+	ColorAlways = "always" // Color even when output is redirected
+	ColorNever  = "never"
+)
+
+// NoColorEnv disables color when set to any non-empty value (see https://no-color.org)
+const NoColorEnv = "NO_COLOR"
+
+// colorOverride is the color mode selected on the command line, overriding NO_COLOR and the
+// color setting
+var colorOverride string
+
+// SetColorMode selects the color mode, overriding NO_COLOR and the color setting (used for --no-color)
+func SetColorMode(mode string) {
+	colorOverride = mode
+}
+
+// CurrentColorMode returns the color mode in effect: --no-color, then NO_COLOR, then the
+// color setting of the global config, then auto
+func CurrentColorMode() string {
+	if colorOverride != "" {
+		return colorOverride
+	}
+	if os.Getenv(NoColorEnv) != "" {
+		return ColorNever
+	}
+	if mode := CurrentUserConfig().Color; mode != "" {
+		return mode
+	}
+	return ColorAuto
+}
+
+// ColorOutput reports whether output written to f should be colored, following the color
+// mode; in auto mode, only when f is a terminal
+func ColorOutput(f *os.File) bool {
+	switch CurrentColorMode() {
+	case ColorNever:
+		return false
+	case ColorAlways:
+		return true
+	}
+	return IsTerminal(f)
+}
+
+// IsTerminal reports whether f is a terminal
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// Colorize wraps s in an ANSI color code if enabled is set
+func Colorize(s, code string, enabled bool) string {
+	if !enabled || s == "" {
+		return s
+	}
+	return "\033[" + code + "m" + s + "\033[0m"
+}
@@ -0,0 +1,55 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// StepSkipped is the report status of a step whose when: condition did not hold
+const StepSkipped = "skipped"
+
+// ShouldRunStep reports whether a step runs: it has no when: condition, or the condition
+// holds once its variables are substituted like the step's arguments
+func ShouldRunStep(config *CommandConfig, overrideVars map[string]string) (bool, error) {
+	if strings.TrimSpace(config.When) == "" {
+		return true, nil
+	}
+
+	_, yamlVars, dollarVars, err := stepVariables(config, overrideVars)
+	if err != nil {
+		return false, err
+	}
+	allVars := make(map[string]string, len(dollarVars))
+	for k, v := range dollarVars {
+		allVars[k] = v
+	}
+	if err := ValidateVariablesWithSyntax([]string{config.When}, allVars, config.VariableSyntax); err != nil {
+		return false, fmt.Errorf("when: %w", err)
+	}
+
+	value, err := EvaluateExpression(config.When, yamlVars, dollarVars)
+	if err != nil {
+		return false, fmt.Errorf("when: %w", err)
+	}
+	return IsTrue(value), nil
+}
+
+// EvaluateCondition evaluates a substituted when: condition, an expression (see
+// EvaluateExpression) such as `left == right` or a single value, which holds unless it is
+// empty, false, no, or 0
+func EvaluateCondition(cond string) (bool, error) {
+	value, err := EvaluateExpression(cond, nil, nil)
+	if err != nil {
+		return false, err
+	}
+	return IsTrue(value), nil
+}
+
+// conditionOperand trims an operand of a condition and removes its quotes
+func conditionOperand(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,226 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"linea/internal/store"
+)
+
+// ConfigFileName is the name of the global configuration file inside the config directory
+const ConfigFileName = "config.yml"
+
+// UserConfig holds the settings from the global configuration file
+type UserConfig struct {
+	Verbose            bool     `yaml:"verbose,omitempty"`
+	GlobalWorkflowsDir string   `yaml:"global_workflows_dir,omitempty"`
+	Shell              string   `yaml:"shell,omitempty"`
+	Color              string   `yaml:"color,omitempty"`
+	Theme              string   `yaml:"theme,omitempty"`
+	PluginPaths        []string `yaml:"plugin_paths,omitempty"`
+	LineaBin           string   `yaml:"linea_bin,omitempty"`
+	Registry           string   `yaml:"registry,omitempty"`
+	PublishURL         string   `yaml:"publish_url,omitempty"`
+
+	// Triggers are the webhooks of linea serve; they are edited in the file, not with
+	// linea config set
+	Triggers []Trigger `yaml:"triggers,omitempty"`
+}
+
+// ConfigKey describes one setting of the global configuration file
+type ConfigKey struct {
+	Name        string
+	Description string
+}
+
+// ConfigKeys lists every supported setting, in display order
+var ConfigKeys = []ConfigKey{
+	{"verbose", "Show commands before executing them by default (true/false)"},
+	{"global_workflows_dir", "Directory holding workflows available from any project"},
+	{"shell", "Shell used for built-ins on Windows (cmd, powershell, pwsh)"},
+	{"color", "Color output (auto, always, never)"},
+	{"theme", "Style of status messages (classic, minimal, ci)"},
+	{"plugin_paths", "Extra directories searched for workflows by name (comma-separated)"},
+	{"linea_bin", "linea executable used by lineash scripts"},
+	{"registry", "URL of the package index used by linea install and linea search"},
+	{"publish_url", "Endpoint linea publish uploads packages to"},
+}
+
+// ConfigFilePath returns the path of the global configuration file
+func ConfigFilePath() string {
+	return filepath.Join(UserPaths().Config, ConfigFileName)
+}
+
+// LoadUserConfig reads the global configuration file
+// A missing file is treated as an empty configuration
+func LoadUserConfig() (*UserConfig, error) {
+	return loadUserConfig(ConfigFilePath())
+}
+
+// loadUserConfig reads a configuration file from path
+func loadUserConfig(path string) (*UserConfig, error) {
+	config := &UserConfig{}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// SaveUserConfig writes the global configuration file
+func SaveUserConfig(config *UserConfig) error {
+	path := ConfigFilePath()
+
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := store.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// UpdateUserConfig loads the global configuration, applies fn, and saves the result,
+// holding the config file's lock so concurrent updates are not lost
+func UpdateUserConfig(fn func(config *UserConfig) error) error {
+	path := ConfigFilePath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	return store.WithLock(path, func() error {
+		config, err := LoadUserConfig()
+		if err != nil {
+			return err
+		}
+		if err := fn(config); err != nil {
+			return err
+		}
+		return SaveUserConfig(config)
+	})
+}
+
+// CurrentUserConfig returns the global configuration, or an empty one if it cannot be read
+// Used where a broken config file should not stop linea; `linea config` and `linea doctor` report it
+func CurrentUserConfig() *UserConfig {
+	config, err := LoadUserConfig()
+	if err != nil {
+		return &UserConfig{}
+	}
+	return config
+}
+
+// Get returns the value of a setting formatted for display
+func (c *UserConfig) Get(key string) (string, error) {
+	switch key {
+	case "verbose":
+		return strconv.FormatBool(c.Verbose), nil
+	case "global_workflows_dir":
+		return c.GlobalWorkflowsDir, nil
+	case "shell":
+		return c.Shell, nil
+	case "color":
+		return c.Color, nil
+	case "theme":
+		return c.Theme, nil
+	case "plugin_paths":
+		return strings.Join(c.PluginPaths, ","), nil
+	case "linea_bin":
+		return c.LineaBin, nil
+	case "registry":
+		return c.Registry, nil
+	case "publish_url":
+		return c.PublishURL, nil
+	default:
+		return "", unknownConfigKey(key)
+	}
+}
+
+// Set parses and stores the value of a setting
+// An empty value resets the setting to its default
+func (c *UserConfig) Set(key, value string) error {
+	switch key {
+	case "verbose":
+		if value == "" {
+			c.Verbose = false
+			return nil
+		}
+		verbose, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid value '%s' for verbose (expected true or false)", value)
+		}
+		c.Verbose = verbose
+	case "global_workflows_dir":
+		c.GlobalWorkflowsDir = value
+	case "shell":
+		if err := checkConfigChoice(key, value, ShellNames...); err != nil {
+			return err
+		}
+		c.Shell = value
+	case "color":
+		if err := checkConfigChoice(key, value, ColorAuto, ColorAlways, ColorNever); err != nil {
+			return err
+		}
+		c.Color = value
+	case "theme":
+		if err := checkConfigChoice(key, value, ThemeNames()...); err != nil {
+			return err
+		}
+		c.Theme = value
+	case "plugin_paths":
+		c.PluginPaths = nil
+		for _, path := range strings.Split(value, ",") {
+			if path = strings.TrimSpace(path); path != "" {
+				c.PluginPaths = append(c.PluginPaths, path)
+			}
+		}
+	case "linea_bin":
+		c.LineaBin = value
+	case "registry":
+		c.Registry = value
+	case "publish_url":
+		c.PublishURL = value
+	default:
+		return unknownConfigKey(key)
+	}
+	return nil
+}
+
+// checkConfigChoice validates that value is empty or one of choices
+func checkConfigChoice(key, value string, choices ...string) error {
+	if value == "" {
+		return nil
+	}
+	for _, choice := range choices {
+		if value == choice {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid value '%s' for %s (expected %s)", value, key, strings.Join(choices, ", "))
+}
+
+// unknownConfigKey returns the error for a setting that does not exist
+func unknownConfigKey(key string) error {
+	names := make([]string, len(ConfigKeys))
+	for i, k := range ConfigKeys {
+		names[i] = k.Name
+	}
+	return fmt.Errorf("unknown config key '%s' (expected one of %s)", key, strings.Join(names, ", "))
+}
@@ -0,0 +1,71 @@
+package internal
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// AssumeYes answers the confirm: prompts of steps with yes instead of asking (linea run --yes)
+var AssumeYes bool
+
+// ErrNotConfirmed is the failure of a step whose confirm: prompt was not answered with yes
+var ErrNotConfirmed = errors.New("not confirmed")
+
+// ConfirmMessage returns the confirm: prompt of a step with its variables substituted
+// like its arguments, or "" if the step has none
+func ConfirmMessage(config *CommandConfig, overrideVars map[string]string) (string, error) {
+	if strings.TrimSpace(config.Confirm) == "" {
+		return "", nil
+	}
+	_, yamlVars, dollarVars, err := stepVariables(config, overrideVars)
+	if err != nil {
+		return "", err
+	}
+	if err := ValidateVariablesWithSyntax([]string{config.Confirm}, dollarVars, config.VariableSyntax); err != nil {
+		return "", fmt.Errorf("confirm: %w", err)
+	}
+	return MaskSecrets(SubstituteVariablesWithSeparateMaps(config.Confirm, yamlVars, dollarVars)), nil
+}
+
+// ConfirmStep asks the confirm: prompt of a step on the terminal before it runs, and
+// fails with ErrNotConfirmed unless it is answered with yes. With AssumeYes the prompt
+// is only shown; without a terminal to ask on, the step fails
+func ConfirmStep(config *CommandConfig, overrideVars map[string]string) error {
+	message, err := ConfirmMessage(config, overrideVars)
+	if err != nil || message == "" {
+		return err
+	}
+	if AssumeYes {
+		Output.Eprintf(StatusWarning, "%s yes (--yes)\n", message)
+		return nil
+	}
+	if !isTerminalFile(os.Stdin) {
+		return fmt.Errorf("confirm: %w: %s (standard input is not a terminal; use --yes to confirm)", ErrNotConfirmed, message)
+	}
+
+	// Ctrl+C while waiting for the answer declines instead of waiting for Enter
+	answer := make(chan bool, 1)
+	go func() { answer <- AskConfirmation(os.Stdin, os.Stderr, message) }()
+	select {
+	case yes := <-answer:
+		if yes {
+			return nil
+		}
+	case <-interrupted():
+		fmt.Fprintln(os.Stderr)
+	}
+	return fmt.Errorf("confirm: %w: %s", ErrNotConfirmed, message)
+}
+
+// AskConfirmation writes prompt with [y/N] to out and reports whether the line read from
+// in answers it with y or yes
+func AskConfirmation(in io.Reader, out io.Writer, prompt string) bool {
+	fmt.Fprint(out, Output.Sprintf(out, StatusWarning, "%s [y/N] ", prompt))
+	line, _ := bufio.NewReader(in).ReadString('\n')
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes"
+}
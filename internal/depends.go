@@ -0,0 +1,96 @@
+package internal
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// WorkflowDependencies returns the depends_on: of a workflow file
+// The first document with depends_on wins, like notifications:
+func WorkflowDependencies(path string) ([]string, error) {
+	configs, err := ParseMultiYAML(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, config := range configs {
+		if len(config.DependsOn) > 0 {
+			return config.DependsOn, nil
+		}
+	}
+	return nil, nil
+}
+
+// ResolveDependency returns the path of a workflow named in the depends_on: of the
+// workflow file from: a workflow next to it, then any workflow found by
+// ResolveWorkflowPath. Paths are relative to the directory of from
+func ResolveDependency(from, name string) (string, error) {
+	dir := filepath.Dir(from)
+	if strings.ContainsAny(name, `/\`) || IsWorkflowFile(name) {
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(dir, name)
+		}
+		return ResolveWorkflowPath(name)
+	}
+	if path := FindWorkflowInDirs(name, []string{dir}); path != "" {
+		return path, nil
+	}
+	return ResolveWorkflowPath(name)
+}
+
+// RunOrder returns the workflow files to run for refs: the depends_on: of each workflow,
+// recursively, before the workflow itself. A workflow needed twice runs once, at its
+// first place. References that cannot be resolved are kept, so that running them
+// reports the error
+func RunOrder(refs []string) ([]string, error) {
+	var order []string
+	done := make(map[string]bool)
+	var visit func(path string, chain []string) error
+	visit = func(path string, chain []string) error {
+		key, err := filepath.Abs(path)
+		if err != nil {
+			key = path
+		}
+		if done[key] {
+			return nil
+		}
+		for i, earlier := range chain {
+			if earlier == key {
+				var names []string
+				for _, p := range append(chain[i:], key) {
+					names = append(names, WorkflowName(p))
+				}
+				return fmt.Errorf("depends_on: dependency cycle %s", strings.Join(names, " -> "))
+			}
+		}
+
+		dependencies, err := WorkflowDependencies(path)
+		if err != nil {
+			return err
+		}
+		for _, name := range dependencies {
+			dependency, err := ResolveDependency(path, name)
+			if err != nil {
+				return fmt.Errorf("%s: depends_on: %w", path, err)
+			}
+			if err := visit(dependency, append(chain, key)); err != nil {
+				return err
+			}
+		}
+		done[key] = true
+		order = append(order, path)
+		return nil
+	}
+
+	for _, ref := range refs {
+		path, err := ResolveWorkflowPath(ref)
+		if err != nil {
+			order = append(order, ref)
+			continue
+		}
+		if err := visit(path, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
@@ -0,0 +1,115 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+)
+
+// LineaBinEnv is the environment variable that overrides the linea executable used by lineash
+const LineaBinEnv = "LINEA_BIN"
+
+// configCandidateSource names the linea_bin config setting in the candidate list
+const configCandidateSource = "config"
+
+// ExecutableCandidate is one location checked while resolving the linea executable
+type ExecutableCandidate struct {
+	Source string // Where the candidate came from (LINEA_BIN, config, same-dir, PATH)
+	Path   string // Candidate path, empty if the source is not configured
+	Found  bool   // Whether an executable exists at Path
+	Detail string // Why the candidate was rejected, if it was
+}
+
+// LineaExecutableName returns the platform-specific file name of the linea executable
+func LineaExecutableName() string {
+	if runtime.GOOS == "windows" {
+		return "linea.exe"
+	}
+	return "linea"
+}
+
+// LineaExecutableCandidates returns every location checked for the linea executable, in resolution order:
+//  1. LINEA_BIN environment variable
+//  2. linea_bin in the global config file
+//  3. Same directory as the running executable (linea itself, or lineash installed next to it)
+//  4. PATH
+func LineaExecutableCandidates() []ExecutableCandidate {
+	lineaExe := LineaExecutableName()
+	var candidates []ExecutableCandidate
+
+	// 1. LINEA_BIN environment variable
+	envCandidate := ExecutableCandidate{Source: LineaBinEnv}
+	if path := os.Getenv(LineaBinEnv); path != "" {
+		envCandidate.Path = path
+		envCandidate.Found, envCandidate.Detail = checkExecutable(path)
+	} else {
+		envCandidate.Detail = "not set"
+	}
+	candidates = append(candidates, envCandidate)
+
+	// 2. linea_bin in the global config file
+	configCandidate := ExecutableCandidate{Source: configCandidateSource}
+	if path := CurrentUserConfig().LineaBin; path != "" {
+		configCandidate.Path = path
+		configCandidate.Found, configCandidate.Detail = checkExecutable(path)
+	} else {
+		configCandidate.Detail = "linea_bin not set in " + ConfigFilePath()
+	}
+	candidates = append(candidates, configCandidate)
+
+	// 3. Same directory as the running executable
+	sameDir := ExecutableCandidate{Source: "same-dir"}
+	if execPath, err := os.Executable(); err == nil {
+		if resolved, err := filepath.EvalSymlinks(execPath); err == nil {
+			execPath = resolved
+		}
+		sameDir.Path = filepath.Join(filepath.Dir(execPath), lineaExe)
+		sameDir.Found, sameDir.Detail = checkExecutable(sameDir.Path)
+	} else {
+		sameDir.Detail = err.Error()
+	}
+	candidates = append(candidates, sameDir)
+
+	// 4. PATH
+	pathCandidate := ExecutableCandidate{Source: "PATH"}
+	if path, err := exec.LookPath(lineaExe); err == nil {
+		pathCandidate.Path = path
+		pathCandidate.Found = true
+	} else {
+		pathCandidate.Detail = fmt.Sprintf("%s not found in PATH", lineaExe)
+	}
+	candidates = append(candidates, pathCandidate)
+
+	return candidates
+}
+
+// checkExecutable reports whether path is an existing regular file
+func checkExecutable(path string) (bool, string) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, "does not exist"
+	}
+	if info.IsDir() {
+		return false, "is a directory"
+	}
+	return true, ""
+}
+
+// FindLineaExecutable resolves the linea executable used to run workflows from lineash scripts
+func FindLineaExecutable() (string, error) {
+	for _, candidate := range LineaExecutableCandidates() {
+		if candidate.Found {
+			return candidate.Path, nil
+		}
+		// An explicit override that points nowhere is an error, not a reason to guess
+		if candidate.Source == LineaBinEnv && candidate.Path != "" {
+			return "", fmt.Errorf("%s is set to %s, which %s", LineaBinEnv, candidate.Path, candidate.Detail)
+		}
+		if candidate.Source == configCandidateSource && candidate.Path != "" {
+			return "", fmt.Errorf("linea_bin in %s is set to %s, which %s", ConfigFilePath(), candidate.Path, candidate.Detail)
+		}
+	}
+	return "", fmt.Errorf("could not find %s (set %s, install it next to lineash, or add it to PATH)", LineaExecutableName(), LineaBinEnv)
+}
@@ -0,0 +1,219 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// displayRefresh is how often the live step line is redrawn
+const displayRefresh = 100 * time.Millisecond
+
+// maxDisplayLabel is the length at which step labels are cut in the step display
+const maxDisplayLabel = 60
+
+// StepDisplay shows which step of a run is running (linea run --progress): on a terminal,
+// a live line with a spinner and the step's elapsed time, kept below the commands' output;
+// otherwise a plain line when each step starts. Either way, a line with the outcome and
+// duration of the step follows it. A nil *StepDisplay shows nothing
+type StepDisplay struct {
+	out  io.Writer // Receives the plain lines, including the run's log
+	term *os.File  // Receives the live line; nil when not a terminal
+
+	mu          sync.Mutex
+	header      string
+	start       time.Time
+	frame       int
+	shown       bool // The live line is on screen
+	atLineStart bool // The commands' output so far ends with a newline
+	stop        chan struct{}
+	done        chan struct{}
+}
+
+// NewStepDisplay returns a display writing plain lines to out, and the live line to
+// terminal if it is one
+func NewStepDisplay(out io.Writer, terminal *os.File) *StepDisplay {
+	d := &StepDisplay{out: out, atLineStart: true}
+	if terminal != nil && IsTerminal(terminal) {
+		d.term = terminal
+	}
+	return d
+}
+
+// Live reports whether the display draws a live line
+func (d *StepDisplay) Live() bool {
+	return d != nil && d.term != nil
+}
+
+// StartStep shows that step index of total, described by label, is starting
+func (d *StepDisplay) StartStep(index, total int, label string) {
+	d.startStep(index, total, label, false)
+}
+
+// StartInteractiveStep is StartStep for an interactive step, which has the terminal to
+// itself: the step gets a plain line instead of the live one
+func (d *StepDisplay) StartInteractiveStep(index, total int, label string) {
+	d.startStep(index, total, label, true)
+}
+
+func (d *StepDisplay) startStep(index, total int, label string, plain bool) {
+	if d == nil {
+		return
+	}
+	header := fmt.Sprintf("[%d/%d] %s", index, total, truncateLabel(label))
+	if !d.Live() || plain {
+		d.stop = nil
+		d.header = header
+		fmt.Fprintf(d.out, "%s...\n", header)
+		return
+	}
+
+	d.mu.Lock()
+	d.header, d.start, d.frame, d.atLineStart = header, time.Now(), 0, true
+	d.draw()
+	d.mu.Unlock()
+
+	d.stop, d.done = make(chan struct{}), make(chan struct{})
+	go func(stop, done chan struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(displayRefresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				d.mu.Lock()
+				d.frame++
+				if d.atLineStart {
+					d.draw()
+				}
+				d.mu.Unlock()
+			}
+		}
+	}(d.stop, d.done)
+}
+
+// EndStep shows the outcome of the step started last
+func (d *StepDisplay) EndStep(status string, duration time.Duration) {
+	if d == nil {
+		return
+	}
+	if d.Live() && d.stop != nil {
+		close(d.stop)
+		<-d.done
+		d.mu.Lock()
+		d.clear()
+		if !d.atLineStart {
+			fmt.Fprintln(d.term)
+		}
+		d.mu.Unlock()
+	}
+
+	outcome := StatusSuccess
+	if status != StepSucceeded {
+		outcome = StatusError
+	}
+	fmt.Fprint(d.out, Output.Sprintf(d.out, outcome, "%s (%s)\n", d.header, displayDuration(duration)))
+}
+
+// SkipStep shows that step index of total, described by label, was skipped
+func (d *StepDisplay) SkipStep(index, total int, label string) {
+	if d == nil {
+		return
+	}
+	header := fmt.Sprintf("[%d/%d] %s", index, total, truncateLabel(label))
+	fmt.Fprint(d.out, Output.Sprintf(d.out, StatusSkip, "%s (skipped)\n", header))
+}
+
+// Writer returns w wrapped so that the live line is cleared before anything is written to w
+// and redrawn beneath it; w itself when the display is not live
+func (d *StepDisplay) Writer(w io.Writer) io.Writer {
+	if !d.Live() {
+		return w
+	}
+	return &displayWriter{display: d, w: w}
+}
+
+// draw redraws the live line; the caller holds d.mu
+func (d *StepDisplay) draw() {
+	elapsed := time.Since(d.start)
+	fmt.Fprintf(d.term, "\r\033[K%s %s... %s", Output.SpinnerFrame(d.frame), d.header, displayDuration(elapsed))
+	d.shown = true
+}
+
+// clear erases the live line; the caller holds d.mu
+func (d *StepDisplay) clear() {
+	if d.shown {
+		fmt.Fprint(d.term, "\r\033[K")
+		d.shown = false
+	}
+}
+
+// displayWriter writes the commands' output around the live line
+type displayWriter struct {
+	display *StepDisplay
+	w       io.Writer
+}
+
+func (dw *displayWriter) Write(p []byte) (int, error) {
+	d := dw.display
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.clear()
+	n, err := dw.w.Write(p)
+	if len(p) > 0 {
+		d.atLineStart = p[len(p)-1] == '\n'
+	}
+	return n, err
+}
+
+// truncateLabel cuts a step label to maxDisplayLabel characters
+func truncateLabel(label string) string {
+	runes := []rune(label)
+	if len(runes) <= maxDisplayLabel {
+		return label
+	}
+	return string(runes[:maxDisplayLabel-3]) + "..."
+}
+
+// displayDuration formats a step duration with a tenth of a second under a minute
+func displayDuration(d time.Duration) string {
+	if d < time.Minute {
+		return fmt.Sprintf("%.1fs", d.Seconds())
+	}
+	return d.Round(time.Second).String()
+}
+
+// Middleware returns the middleware that shows the steps of a run
+func (d *StepDisplay) Middleware() Middleware {
+	started := false
+	return Middleware{
+		BeforeStep: func(step StepInfo) error {
+			label := stepLabel(step.Config, step.Command)
+			if step.Config.Interactive {
+				d.StartInteractiveStep(step.Index, step.Steps, label)
+			} else {
+				d.StartStep(step.Index, step.Steps, label)
+			}
+			started = true
+			return nil
+		},
+		AfterStep: func(step StepInfo, result StepResult) {
+			switch {
+			case result.Status == StepSkipped:
+				d.SkipStep(step.Index, step.Steps, stepLabel(step.Config, nil))
+			case result.Status == StepNotRun:
+			case started:
+				d.EndStep(result.Status, time.Duration(result.DurationMs)*time.Millisecond)
+			default:
+				// The step failed before it could start
+				d.StartStep(step.Index, step.Steps, stepLabel(step.Config, nil))
+				d.EndStep(result.Status, 0)
+			}
+			started = false
+		},
+	}
+}
@@ -0,0 +1,64 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+)
+
+// StepEnvironment returns the environment a step's command runs with: the host
+// environment filtered by its env_passthrough: patterns, or nil (the whole host
+// environment) without them. An empty env_passthrough passes nothing
+func StepEnvironment(config *CommandConfig) []string {
+	if config.EnvPassthrough == nil {
+		return nil
+	}
+	return FilterEnvironment(os.Environ(), config.EnvPassthrough)
+}
+
+// FilterEnvironment returns the NAME=value entries of env whose name matches one of
+// patterns (glob patterns, e.g. AWS_*). Names are matched case-insensitively on Windows
+func FilterEnvironment(env []string, patterns []string) []string {
+	filtered := []string{}
+	for _, entry := range env {
+		name := entry
+		if i := strings.Index(entry, "="); i > 0 {
+			name = entry[:i]
+		}
+		if matchesEnvPattern(name, patterns) {
+			filtered = append(filtered, entry)
+		}
+	}
+	return filtered
+}
+
+// matchesEnvPattern reports whether an environment variable name matches one of patterns
+func matchesEnvPattern(name string, patterns []string) bool {
+	if runtime.GOOS == "windows" {
+		name = strings.ToUpper(name)
+	}
+	for _, pattern := range patterns {
+		if runtime.GOOS == "windows" {
+			pattern = strings.ToUpper(pattern)
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckEnvPatterns validates the glob patterns of env_passthrough:
+func CheckEnvPatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if strings.TrimSpace(pattern) == "" {
+			return fmt.Errorf("empty pattern in env_passthrough")
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern '%s' in env_passthrough: %v", pattern, err)
+		}
+	}
+	return nil
+}
@@ -1,42 +1,41 @@
 package internal
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
-	"strings"
 )
 
 // BuildCommand constructs the full command with subcommand and arguments
 func BuildCommand(config *CommandConfig, overrideVars map[string]string) ([]string, error) {
-	// Separate YAML variables from override variables
-	// {name} syntax uses ONLY YAML variables (not overridable)
-	// $name syntax uses override variables first, then YAML variables
-	
-	yamlVars := make(map[string]string)
-	if config.Variables != nil {
-		for k, v := range config.Variables {
-			yamlVars[k] = v
-		}
+	if err := CheckVariableSyntax(config); err != nil {
+		return nil, err
+	}
+	builtinVars, yamlVars, dollarVars, err := stepVariables(config, overrideVars)
+	if err != nil {
+		return nil, err
 	}
 	
-	// For $variable syntax: override vars take precedence, then YAML vars
-	dollarVars := make(map[string]string)
-	// First add YAML vars
-	for k, v := range yamlVars {
-		dollarVars[k] = v
+	// Built-in step types (type: healthcheck, ...) turn their fields into arguments
+	args, err := StepArguments(config)
+	if err != nil {
+		return nil, err
 	}
-	// Then override with -s/--set vars
-	if overrideVars != nil {
-		for k, v := range overrideVars {
-			dollarVars[k] = v
-		}
+	// A template: step is passed the variables its file uses
+	if config.Type == StepTypeTemplate {
+		args = append(args, templateVariableArgs(config, yamlVars, dollarVars)...)
 	}
 	
 	// Collect all strings that need validation (args + variable values)
-	stringsToValidate := make([]string, 0, len(config.Args))
-	stringsToValidate = append(stringsToValidate, config.Args...)
+	stringsToValidate := make([]string, 0, len(args))
+	stringsToValidate = append(stringsToValidate, args...)
+	if config.Runner != nil {
+		stringsToValidate = append(stringsToValidate, config.Runner.templates()...)
+	}
 	// Validate against both YAML vars (for {name}) and dollar vars (for $name)
 	allVars := make(map[string]string)
 	for k, v := range yamlVars {
@@ -45,108 +44,422 @@ func BuildCommand(config *CommandConfig, overrideVars map[string]string) ([]stri
 	for k, v := range dollarVars {
 		allVars[k] = v
 	}
-	for _, v := range allVars {
+	for k, v := range allVars {
+		// Built-in values (e.g. {cwd}) are not templates and need no validation
+		if builtin, ok := builtinVars[k]; ok && builtin == v {
+			continue
+		}
 		stringsToValidate = append(stringsToValidate, v)
 	}
 	
 	// Validate that all referenced variables are defined
-	if err := ValidateVariables(stringsToValidate, allVars); err != nil {
+	if err := ValidateVariablesWithSyntax(stringsToValidate, allVars, config.VariableSyntax); err != nil {
 		return nil, err
 	}
 	
+	if err := CheckRunner(config); err != nil {
+		return nil, err
+	}
+	
+	// Arguments of built-in step types are URLs and values rather than paths, so they
+	// are substituted without path normalization
+	if config.Type != "" {
+		cmd := []string{config.Type}
+		for _, arg := range args {
+			cmd = append(cmd, SubstituteVariablesWithSeparateMaps(arg, yamlVars, dollarVars))
+		}
+		return cmd, nil
+	}
+	
 	cmd := []string{config.Command}
 	
 	if config.Subcommand != "" {
 		cmd = append(cmd, config.Subcommand)
 	}
 	
+	// A runner: wraps the command in the client that runs it remotely; its paths are those
+	// of the remote host, so they are substituted without path normalization
+	if config.Runner != nil {
+		substitute := func(s string) string {
+			return SubstituteVariablesWithSeparateMaps(s, yamlVars, dollarVars)
+		}
+		for _, arg := range args {
+			cmd = append(cmd, substitute(arg))
+		}
+		dir := filepath.Dir(config.SourceFile)
+		return config.Runner.substitute(substitute).commandLine(cmd, config.Limits, config.Interactive, dir), nil
+	}
+	
 	// Apply variable substitution to arguments
 	// {name} uses yamlVars only, $name uses dollarVars
-	args := SubstituteVariablesInArgsWithSeparateMaps(config.Args, yamlVars, dollarVars)
-	cmd = append(cmd, args...)
+	cmd = append(cmd, substituteStepArgs(config, args, yamlVars, dollarVars)...)
 	
 	return cmd, nil
 }
 
-// FormatCommand returns a string representation of the command for display
+// stepVariables returns the variables of a step: the built-in ones, those for {name}
+// (built-ins, YAML variables, secrets, and providers), and those for $name, which
+// -s/--set values override
+func stepVariables(config *CommandConfig, overrideVars map[string]string) (builtinVars, yamlVars, dollarVars map[string]string, err error) {
+	// Separate YAML variables from override variables
+	// {name} syntax uses ONLY YAML variables (not overridable)
+	// $name syntax uses override variables first, then YAML variables
+	
+	// Built-in variables ({os}, {arch}, {timestamp}, ...) have the lowest precedence
+	builtinVars = BuiltinVariables(config)
+	yamlVars = make(map[string]string)
+	for k, v := range builtinVars {
+		yamlVars[k] = v
+	}
+	// The steps of a called workflow see the set: values of the call as their -s/--set
+	// values, and not those of the caller
+	if config.Call != nil {
+		if overrideVars, err = config.Call.setValues(overrideVars); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	// The steps of a group see its inputs, but not -s/--set values, which only reach
+	// them through the inputs
+	if config.Group != "" {
+		inputs, err := groupInputs(config, builtinVars, overrideVars)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		for k, v := range inputs {
+			yamlVars[k] = v
+		}
+		overrideVars = nil
+	}
+	if config.Variables != nil {
+		for k, v := range config.Variables {
+			yamlVars[k] = v
+		}
+	}
+	
+	// Secrets behave like YAML variables but are masked in all displayed output
+	if len(config.Secrets) > 0 {
+		secretVars, err := ResolveSecrets(config.Secrets)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		for k, v := range secretVars {
+			yamlVars[k] = v
+		}
+	}
+	
+	// Provider variables ({provider: exec|http|file|env}) are also YAML variables,
+	// fetched only if the step references them
+	if len(config.Providers) > 0 {
+		providerVars, err := ResolveProviderVariables(config)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		for k, v := range providerVars {
+			yamlVars[k] = v
+		}
+	}
+	
+	// For $variable syntax: override vars take precedence, then YAML vars
+	dollarVars = make(map[string]string)
+	// First add YAML vars
+	for k, v := range yamlVars {
+		dollarVars[k] = v
+	}
+	// Then override with -s/--set vars
+	if overrideVars != nil {
+		for k, v := range overrideVars {
+			dollarVars[k] = v
+		}
+	}
+	
+	// Values may be composed of other variables, e.g. image: "{registry}/{app}:{tag}"
+	if err := resolveNestedVariables(yamlVars, dollarVars, variableTemplates(config, overrideVars)); err != nil {
+		return nil, nil, nil, err
+	}
+	return builtinVars, yamlVars, dollarVars, nil
+}
+
+// FormatCommand returns a string representation of the command for display, quoted for
+// the shell of the platform (see DefaultQuoteStyle) so that it can be copied and pasted
+// Secret values are masked
 func FormatCommand(cmd []string) string {
-	return strings.Join(cmd, " ")
+	return FormatCommandStyle(cmd, DefaultQuoteStyle())
+}
+
+// FormatCommandStyle is FormatCommand with the arguments quoted in style
+func FormatCommandStyle(cmd []string, style string) string {
+	return ShellQuote(maskedCommand(cmd), style)
 }
 
 // ExecuteCommand runs the command and returns the output
 func ExecuteCommand(cmd []string) error {
+	return ExecuteCommandWithOutput(cmd, os.Stdout, os.Stderr)
+}
+
+// ExecuteCommandWithOutput runs the command with its standard output and error sent to
+// stdout and stderr instead of the terminal
+func ExecuteCommandWithOutput(cmd []string, stdout, stderr io.Writer) error {
+	return executeCommand(cmd, StepShell(nil), nil, nil, nil, stdout, stderr)
+}
+
+// executeCommand runs a command with the environment env, or the host environment if nil,
+// and the resource limits, if not nil, reading stdin, or linea's own standard input if nil
+// On Windows, commands not found on PATH run through shell (see StepShell)
+func executeCommand(cmd []string, shell string, env []string, limits *ResourceLimits, stdin io.Reader, stdout, stderr io.Writer) error {
 	if len(cmd) == 0 {
 		return fmt.Errorf("command is empty")
 	}
 
-	// On Windows, check if command exists in PATH
-	// If not, try executing through cmd.exe (for shell built-ins like echo, dir, etc.)
-	if runtime.GOOS == "windows" {
-		_, err := exec.LookPath(cmd[0])
-		if err != nil {
-			// Command not found in PATH, try shell execution
-			return executeWindowsShell(cmd)
+	line := limitedCommandLine(cmd, shell, limits)
+	execCmd := exec.Command(line[0], line[1:]...)
+	execCmd.Env = env
+	execCmd.Stdin = stdin
+	return runCapturingStderr(execCmd, limits, stdout, stderr)
+}
+
+// ExecuteStepWithOutput runs the built command of a step like ExecuteCommandWithOutput,
+// or the built-in step of its type:. An interactive: step is attached to the terminal
+// instead of stdin, stdout, and stderr, and a background: step is started as a job that
+// linea does not wait for. Exit codes in its allowed_exit_codes: count as
+// success, and the command only receives the host environment its env_passthrough: allows
+// stdin is the step's input from OpenStepInput, closed afterwards if it is a file; nil
+// passes linea's own standard input
+// The exit code is recorded either way, for {exit_code} in the steps after it, and so is
+// the output of a named step with capture:, for the stdin: of the steps after it
+func ExecuteStepWithOutput(config *CommandConfig, cmd []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	return ExecuteStepWithRunner(LocalRunner{}, config, cmd, stdin, stdout, stderr)
+}
+
+// ExecuteStepWithRunner is ExecuteStepWithOutput with the command run by runner
+func ExecuteStepWithRunner(runner Runner, config *CommandConfig, cmd []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if closer, ok := stdin.(io.Closer); ok {
+		defer closer.Close()
+	}
+	var captured *bytes.Buffer
+	if config.Capture && config.Name != "" {
+		captured = &bytes.Buffer{}
+		stdout = io.MultiWriter(captured, stdout)
+	}
+
+	err := runner.RunStep(&StepCommand{Config: config, Args: cmd, Stdin: stdin, Stdout: stdout, Stderr: stderr})
+	recordExitCode(ExitCode(err))
+	if captured != nil {
+		for _, key := range stepOutputKeys(config) {
+			recordStepOutput(key, captured.Bytes())
+		}
+	}
+	if len(config.AllowedExitCodes) == 0 {
+		return err
+	}
+
+	// Commands that could not start or were killed by a signal have no exit code to allow
+	code := 0
+	if err != nil {
+		var ok bool
+		if code, ok = errorExitCode(err); !ok {
+			return err
+		}
+	}
+	for _, allowed := range config.AllowedExitCodes {
+		if code == allowed {
+			return nil
 		}
 	}
+	if err == nil {
+		return fmt.Errorf("exit status 0 is not in allowed_exit_codes %v", config.AllowedExitCodes)
+	}
+	return err
+}
 
-	execCmd := exec.Command(cmd[0], cmd[1:]...)
-	execCmd.Stdout = os.Stdout
-	execCmd.Stderr = os.Stderr
-	execCmd.Stdin = os.Stdin
+// runCapturingStderr runs a command with the given output writers, keeping the tail of
+// its stderr so failures can be matched against the hint rules
+// A command that writes more than its limits.output is killed
+func runCapturingStderr(execCmd *exec.Cmd, limits *ResourceLimits, stdout, stderr io.Writer) error {
+	tail := &tailBuffer{max: stderrTailSize}
+	var output *outputLimit
+	if max := limits.outputBytes(); max > 0 {
+		output = &outputLimit{max: max}
+		stdout, stderr = output.writer(stdout), output.writer(stderr)
+	}
+	execCmd.Stdout = stdout
+	execCmd.Stderr = io.MultiWriter(stderr, tail)
+	if execCmd.Stdin == nil {
+		execCmd.Stdin = os.Stdin
+	}
+	own := !usesTerminal(execCmd.Stdin)
+	if own {
+		setOwnProcessGroup(execCmd)
+	}
 
-	return execCmd.Run()
+	if err := execCmd.Start(); err != nil {
+		return &CommandError{Err: err, Stderr: tail.String()}
+	}
+	group := newProcessGroup(execCmd.Process, own, limits)
+	defer group.release()
+	if output != nil {
+		output.start(group.kill)
+	}
+	untrack := trackProcess(group, false)
+	defer untrack()
+	if err := execCmd.Wait(); err != nil {
+		if output != nil && output.err() != nil {
+			err = output.err()
+		}
+		// Processes a failed command started and left running are killed with it
+		if own {
+			group.kill()
+		}
+		return &CommandError{Err: err, Stderr: tail.String()}
+	}
+	return nil
 }
 
 // ExecuteMultipleCommands executes multiple commands sequentially
 // Stops on first error unless continueOnError is true
 func ExecuteMultipleCommands(configs []*CommandConfig, overrideVars map[string]string, continueOnError bool, verbose bool) error {
+	return ExecuteMultipleCommandsWithOutput(configs, overrideVars, continueOnError, verbose, os.Stdout, os.Stderr)
+}
+
+// ExecuteMultipleCommandsWithOutput is ExecuteMultipleCommands with the commands' output,
+// and linea's own progress lines, sent to stdout and stderr instead of the terminal
+// after: and on_failure: steps still run after the first error, which is returned once
+// they have; a run cancelled by a signal returns a *CancelledError
+func ExecuteMultipleCommandsWithOutput(configs []*CommandConfig, overrideVars map[string]string, continueOnError bool, verbose bool, stdout, stderr io.Writer) error {
+	return executeSteps(configs, overrideVars, continueOnError, verbose, stdout, stderr, nil)
+}
+
+// ExecuteCheckpointedCommands is ExecuteMultipleCommandsWithOutput stopping at the first
+// error and recording each step in checkpoint, like RunStepsReport, so that a failed run
+// can be resumed with --resume
+func ExecuteCheckpointedCommands(configs []*CommandConfig, overrideVars map[string]string, verbose bool, stdout, stderr io.Writer, checkpoint *Checkpoint) error {
+	return executeSteps(configs, overrideVars, false, verbose, stdout, stderr, checkpoint)
+}
+
+// executeSteps runs the steps of ExecuteMultipleCommandsWithOutput, recording their
+// outcomes in checkpoint if it is not nil
+func executeSteps(configs []*CommandConfig, overrideVars map[string]string, continueOnError bool, verbose bool, stdout, stderr io.Writer, checkpoint *Checkpoint) error {
+	var failure error
 	for i, config := range configs {
+		result := StepResult{Index: i + 1}
+		if status := StepStatusAfter(config, failure != nil, continueOnError); status != "" {
+			result.Status = status
+			checkpoint.record(config, result)
+			if verbose && status == StepSkipped {
+				fmt.Fprintf(stdout, "\n[%d/%d] Skipped (on_failure: nothing failed)\n", i+1, len(configs))
+			}
+			continue
+		}
 		if verbose {
-			fmt.Printf("\n[%d/%d] ", i+1, len(configs))
+			fmt.Fprintf(stdout, "\n[%d/%d] ", i+1, len(configs))
 		}
 
-		cmd, err := BuildCommand(config, overrideVars)
+		run, err := ShouldRunStep(config, overrideVars)
+		if err == nil && !run {
+			result.Status = StepSkipped
+			checkpoint.record(config, result)
+			if verbose {
+				fmt.Fprintf(stdout, "Skipped (when: %s)\n", config.When)
+			}
+			continue
+		}
+		var cmd []string
+		var stdin io.Reader
+		if err == nil {
+			cmd, err = BuildCommand(config, overrideVars)
+		}
+		if err == nil {
+			err = CheckStepPolicy(config, overrideVars)
+		}
+		var cache *StepCache
+		if err == nil {
+			var cached *StepCacheRecord
+			if cache, cached, err = LookupStepCache(config, i+1, cmd, overrideVars); cached != nil {
+				cached.restore(config)
+				cached.notice(stdout, stepLabel(config, cmd))
+				result.Status, result.ExitCode = StepSkipped, cached.ExitCode
+				checkpoint.record(config, result)
+				continue
+			}
+		}
+		if err == nil {
+			err = ConfirmStep(config, overrideVars)
+		}
+		if err == nil {
+			stdin, err = OpenStepInput(config, overrideVars)
+		}
 		if err != nil {
+			result.Status = StepFailed
+			checkpoint.record(config, result)
 			if continueOnError {
-				fmt.Fprintf(os.Stderr, "Error building command %d: %v\n", i+1, err)
-				continue
+				fmt.Fprintf(stderr, "Error building command %d: %v\n", i+1, err)
 			}
-			return fmt.Errorf("error building command %d: %w", i+1, err)
+			if failure == nil {
+				failure = fmt.Errorf("error building command %d: %w", i+1, err)
+			}
+			continue
 		}
 
 		if verbose {
-			fmt.Printf("Executing: %s\n", FormatCommand(cmd))
+			fmt.Fprintf(stdout, "Executing: %s\n", FormatCommand(cmd))
 		}
 
-		if err := ExecuteCommand(cmd); err != nil {
+		if err := ExecuteStepWithOutput(config, cmd, stdin, stdout, stderr); err != nil {
+			result.Status = StepFailed
+			checkpoint.record(config, result)
 			if continueOnError {
-				fmt.Fprintf(os.Stderr, "Error executing command %d: %v\n", i+1, err)
-				continue
+				fmt.Fprintf(stderr, "Error executing command %d: %v\n", i+1, err)
 			}
-			return fmt.Errorf("command %d execution failed: %w", i+1, err)
+			if failure == nil {
+				failure = fmt.Errorf("command %d execution failed: %w", i+1, err)
+			}
+		} else {
+			cache.Save(config, lastStepExitCode())
+			result.Status, result.ExitCode = StepSucceeded, lastStepExitCode()
+			checkpoint.record(config, result)
 		}
 	}
 
-	return nil
+	if cancelled := Cancelled(); cancelled != nil {
+		return cancelled
+	}
+	if continueOnError {
+		return nil
+	}
+	return failure
 }
 
-// executeWindowsShell executes a command through cmd.exe on Windows
+// commandLine returns the program and arguments that run cmd
+// On Windows, a command not found on PATH runs through cmd.exe, or PowerShell, instead
 // This is used for shell built-ins like echo, dir, etc.
-func executeWindowsShell(cmd []string) error {
-	// Build the command string for cmd.exe /c
-	cmdStr := FormatCommand(cmd)
-	
-	execCmd := exec.Command("cmd.exe", "/c", cmdStr)
-	execCmd.Stdout = os.Stdout
-	execCmd.Stderr = os.Stderr
-	execCmd.Stdin = os.Stdin
+// Arguments are quoted for the shell, so values with spaces or quotes stay one argument
+// (quoted directly rather than via FormatCommand, which masks secrets)
+func commandLine(cmd []string, shell string) []string {
+	if runtime.GOOS == "windows" {
+		if _, err := exec.LookPath(cmd[0]); err != nil {
+			return WindowsShellCommand(cmd, shell)
+		}
+	}
+	return cmd
+}
 
-	return execCmd.Run()
+// limitedCommandLine is commandLine with the memory and CPU time limits and the niceness
+// of limits applied on Unix (see limitCommandLine)
+func limitedCommandLine(cmd []string, shell string, limits *ResourceLimits) []string {
+	line := commandLine(cmd, shell)
+	if limits != nil && runtime.GOOS != "windows" {
+		line = limitCommandLine(line, limits)
+	}
+	return line
 }
 
 // DryRun prints the command without executing it
 func DryRun(cmd []string) {
+	DryRunStyle(cmd, DefaultQuoteStyle())
+}
+
+// DryRunStyle is DryRun with the arguments quoted in style
+func DryRunStyle(cmd []string, style string) {
 	fmt.Println("Dry run - would execute:")
-	fmt.Println(FormatCommand(cmd))
+	fmt.Println(FormatCommandStyle(cmd, style))
 }
 
@@ -0,0 +1,927 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Formats of linea export
+const (
+	ExportBash          = "bash"
+	ExportPowerShell    = "powershell"
+	ExportGitHubActions = "gha"
+	ExportGitLab        = "gitlab"
+)
+
+// ExportFormats lists the formats of linea export
+var ExportFormats = []string{ExportBash, ExportPowerShell, ExportGitHubActions, ExportGitLab}
+
+// ExportOptions holds the flags of `linea export`
+type ExportOptions struct {
+	Format string
+
+	// Parameterized turns the $name variables of the workflow into parameters of the
+	// script, passed as name=value arguments (bash) or -Set @{name=value} (PowerShell),
+	// instead of baking their values in
+	Parameterized bool
+
+	// Set holds -s/--set values, baked into the script like linea run applies them
+	Set map[string]string
+
+	// Matrix holds --matrix values: the CI formats run the workflow once per combination,
+	// with the $name variables set to each value like -s would
+	Matrix map[string][]string
+}
+
+// ExportResult is a workflow exported as a script or CI pipeline
+type ExportResult struct {
+	Script     []byte
+	Notes      []string // What the script does differently from linea run
+	Executable bool     // A bash or PowerShell script, written with the executable bit set
+}
+
+// Kinds of values the script computes when it runs
+const (
+	exportParam   = "param"   // A variable passed to the script
+	exportEnv     = "env"     // An environment variable: a secret, or an env provider
+	exportBuiltin = "builtin" // A built-in variable such as {timestamp}
+	exportMatrix  = "matrix"  // A variable set by the CI matrix
+)
+
+// exportValue is a value the script computes when it runs
+type exportValue struct {
+	Kind     string
+	Name     string // Variable, environment variable, or built-in variable name
+	Default  string
+	Required bool // A parameter or environment variable without a default
+	Secret   bool // An environment variable holding a secret
+}
+
+// exportPart is a piece of a word of the script: literal text, or a value
+type exportPart struct {
+	Text  string
+	Value *exportValue
+}
+
+// exportCondition is a when: condition tested when the script runs
+type exportCondition struct {
+	Op          string // ==, !=, or empty for a single value
+	Left, Right []exportPart
+}
+
+// exportStep is a step of an exported script
+type exportStep struct {
+	Index, Total int
+	Name         string
+	Words        [][]exportPart // The command line
+	Unsupported  string         // What of the step needs linea, e.g. "type: sleep"; the step then fails
+	When         *exportCondition
+	Confirm      []exportPart
+	StdinText    []exportPart
+	StdinFile    []exportPart
+	Background   bool
+	Skipped      bool // The when: condition is false whatever the script is run with
+	After        bool
+	OnFailure    bool
+	Allowed      []int
+}
+
+// exportScript is a workflow being exported
+type exportScript struct {
+	Source      string
+	Name        string
+	Description string
+	Steps       []*exportStep
+	Notes       []string
+
+	values []exportValue
+	tokens map[exportValue]string
+	used   map[exportValue]bool
+}
+
+// exportRuntimeBuiltins are the built-in variables whose value depends on the run, so the
+// script computes them instead of baking in their value at export time
+var exportRuntimeBuiltins = []string{
+	"os", "arch", "cwd", "timestamp", "date", "uuid", "exit_code",
+	"workflow_dir", "git_branch", "git_sha", "git_dirty",
+}
+
+// exportTokenMark delimits the tokens that stand for values during substitution
+const exportTokenMark = "\x00"
+
+// token returns the text standing for a value in substituted strings
+func (s *exportScript) token(v exportValue) string {
+	if token, ok := s.tokens[v]; ok {
+		return token
+	}
+	token := exportTokenMark + strconv.Itoa(len(s.values)) + exportTokenMark
+	s.values = append(s.values, v)
+	s.tokens[v] = token
+	return token
+}
+
+// parts splits a substituted string into literal text and the values of its tokens
+func (s *exportScript) parts(text string) []exportPart {
+	var parts []exportPart
+	for i, piece := range strings.Split(text, exportTokenMark) {
+		if i%2 == 0 {
+			if piece != "" {
+				parts = append(parts, exportPart{Text: piece})
+			}
+			continue
+		}
+		n, _ := strconv.Atoi(piece)
+		v := s.values[n]
+		s.used[v] = true
+		parts = append(parts, exportPart{Value: &v})
+	}
+	return parts
+}
+
+// literal returns the text of parts without values, and whether there was no value
+func literal(parts []exportPart) (string, bool) {
+	var text strings.Builder
+	for _, p := range parts {
+		if p.Value != nil {
+			return "", false
+		}
+		text.WriteString(p.Text)
+	}
+	return text.String(), true
+}
+
+// ExportWorkflow converts the workflow file at path into a standalone bash or PowerShell
+// script, or a GitHub Actions or GitLab CI pipeline, running its steps like linea run
+// would: variables are substituted, after: and on_failure: steps, when: conditions,
+// confirm: prompts, and allowed_exit_codes are kept, and secrets and run-dependent
+// built-in variables are read when it runs
+func ExportWorkflow(path string, opts ExportOptions) (*ExportResult, error) {
+	script := opts.Format == ExportBash || opts.Format == ExportPowerShell
+	if !script && opts.Format != ExportGitHubActions && opts.Format != ExportGitLab {
+		return nil, fmt.Errorf("invalid export format '%s' (expected %s)", opts.Format, strings.Join(ExportFormats, ", "))
+	}
+	if len(opts.Matrix) > 0 && script {
+		return nil, fmt.Errorf("--matrix needs a CI format (%s or %s)", ExportGitHubActions, ExportGitLab)
+	}
+	for name, values := range opts.Matrix {
+		if len(values) == 0 {
+			return nil, fmt.Errorf("matrix variable %s has no values", name)
+		}
+	}
+	configs, err := ParseMultiYAML(path)
+	if err != nil {
+		return nil, err
+	}
+	if configs, err = ExpandForeach(configs, opts.Set); err != nil {
+		return nil, err
+	}
+
+	exported := &exportScript{Source: path, Name: WorkflowName(path), tokens: map[exportValue]string{}, used: map[exportValue]bool{}}
+	exported.Description = configs[0].Description
+	for i, config := range configs {
+		step, err := exported.exportStep(config, opts)
+		if err != nil {
+			return nil, fmt.Errorf("step %d: %w", i+1, err)
+		}
+		step.Index = i + 1
+		step.Total = len(configs)
+		exported.Steps = append(exported.Steps, step)
+	}
+
+	var text string
+	switch opts.Format {
+	case ExportBash:
+		text = renderBashScript(exported)
+	case ExportPowerShell:
+		text = renderPowerShellScript(exported)
+	case ExportGitHubActions:
+		text, err = renderGitHubActions(exported, opts.Matrix)
+	case ExportGitLab:
+		text, err = renderGitLabCI(exported, opts.Matrix)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ExportResult{Script: []byte(text), Notes: exported.Notes, Executable: script}, nil
+}
+
+// note records something the script does differently from linea run
+func (s *exportScript) note(format string, args ...interface{}) {
+	note := fmt.Sprintf(format, args...)
+	for _, existing := range s.Notes {
+		if existing == note {
+			return
+		}
+	}
+	s.Notes = append(s.Notes, note)
+}
+
+// exportStep substitutes the variables of a step, with tokens for the values the script
+// computes
+func (s *exportScript) exportStep(config *CommandConfig, opts ExportOptions) (*exportStep, error) {
+	label := config.Name
+	if label == "" {
+		label = config.Command
+	}
+	if label == "" {
+		label = config.Type
+	}
+
+	// The step is built from a copy whose variables stand for the values computed by
+	// the script: secrets, environment variables, and run-dependent built-ins
+	step := *config
+	step.Variables = make(map[string]string, len(config.Variables))
+	for name, value := range config.Variables {
+		step.Variables[name] = value
+	}
+	for _, name := range exportRuntimeBuiltins {
+		if _, declared := config.Variables[name]; !declared {
+			step.Variables[name] = s.token(exportValue{Kind: exportBuiltin, Name: name})
+		}
+	}
+	step.Secrets = nil
+	for name, ref := range config.Secrets {
+		env := firstNonEmpty(ref.Key, name)
+		if ref.From != SecretFromEnv {
+			s.note("the secret %s is read from the environment variable %s instead of the %s backend", name, env, firstNonEmpty(ref.From, SecretFromFile))
+		}
+		step.Variables[name] = s.token(exportValue{Kind: exportEnv, Name: env, Required: true, Secret: true})
+	}
+	step.Providers = make(map[string]ProviderSpec, len(config.Providers))
+	for name, spec := range config.Providers {
+		if spec.Provider == ProviderEnv && spec.Field == "" {
+			env := firstNonEmpty(spec.Name, name)
+			def, err := ExpressionDefault(spec.Default)
+			if err != nil {
+				return nil, fmt.Errorf("variable %s: default: %w", name, err)
+			}
+			if strings.HasPrefix(spec.Default, "=") && !strings.HasPrefix(spec.Default, "==") {
+				s.note("the default of the variable %s is an expression, evaluated now and baked into the script", name)
+			}
+			step.Variables[name] = s.token(exportValue{Kind: exportEnv, Name: env, Default: def, Required: spec.Default == ""})
+			continue
+		}
+		s.note("the variable %s is fetched by its %s provider now and baked into the script", name, spec.Provider)
+		step.Providers[name] = spec
+	}
+
+	overrides := make(map[string]string, len(opts.Set))
+	for name, value := range opts.Set {
+		overrides[name] = value
+	}
+	for name := range opts.Matrix {
+		overrides[name] = s.token(exportValue{Kind: exportMatrix, Name: name})
+	}
+	if opts.Parameterized && config.Group == "" && config.Call == nil {
+		for name, value := range config.Variables {
+			if _, set := overrides[name]; !set {
+				overrides[name] = s.token(exportValue{Kind: exportParam, Name: name, Default: value})
+			}
+		}
+		// Variables that are referenced but not declared must be passed to the script
+		_, yamlVars, dollarVars, err := stepVariables(&step, overrides)
+		if err != nil {
+			return nil, err
+		}
+		for _, text := range append(append([]string{config.When, config.Confirm}, config.Args...), config.Subcommand) {
+			for name := range ExtractVariableReferences(text) {
+				_, yaml := yamlVars[name]
+				_, dollar := dollarVars[name]
+				if !yaml && !dollar {
+					overrides[name] = s.token(exportValue{Kind: exportParam, Name: name, Required: true})
+				}
+			}
+		}
+	}
+
+	result := &exportStep{Name: label, Background: config.Background, After: config.After, OnFailure: config.OnFailure, Allowed: config.AllowedExitCodes}
+	_, yamlVars, dollarVars, err := stepVariables(&step, overrides)
+	if err != nil {
+		return nil, err
+	}
+	substitute := func(text string) []exportPart {
+		return s.parts(SubstituteVariablesWithSeparateMaps(text, yamlVars, dollarVars))
+	}
+
+	if strings.TrimSpace(config.When) != "" {
+		allVars := make(map[string]string, len(dollarVars))
+		for k, v := range dollarVars {
+			allVars[k] = v
+		}
+		if err := ValidateVariablesWithSyntax([]string{config.When}, allVars, config.VariableSyntax); err != nil {
+			return nil, fmt.Errorf("when: %w", err)
+		}
+		condition, err := exportWhen(SubstituteVariablesWithSeparateMaps(config.When, yamlVars, dollarVars), s)
+		if err != nil {
+			return nil, fmt.Errorf("when: %w", err)
+		}
+		if condition == nil {
+			result.Skipped = true
+			return result, nil
+		}
+		if condition.Op != "always" {
+			result.When = condition
+		}
+	}
+	if config.Confirm != "" {
+		result.Confirm = substitute(config.Confirm)
+	}
+
+	switch {
+	case config.Type != "":
+		result.Unsupported = "type: " + config.Type
+	case config.Stdin != nil && config.Stdin.Step != "":
+		result.Unsupported = "stdin: from another step"
+	}
+	if result.Unsupported != "" {
+		s.note("step %s: %s needs linea; the script fails at this step", label, result.Unsupported)
+		return result, nil
+	}
+
+	cmd, err := BuildCommand(&step, overrides)
+	if err != nil {
+		return nil, err
+	}
+	for _, arg := range cmd {
+		result.Words = append(result.Words, s.parts(arg))
+	}
+	if config.Stdin != nil {
+		if config.Stdin.Text != "" {
+			result.StdinText = substitute(config.Stdin.Text)
+		} else {
+			result.StdinFile = substitute(config.Stdin.File)
+		}
+	}
+	if config.Limits != nil && config.Runner == nil {
+		s.note("step %s: limits: are not applied by the script", label)
+	}
+	if config.Capture {
+		s.note("step %s: capture: has no effect in the script", label)
+	}
+	return result, nil
+}
+
+// exportWhen converts a substituted when: condition like EvaluateCondition reads it. A
+// condition without values is evaluated now: nil means it is false, and Op "always" that
+// it holds
+func exportWhen(cond string, s *exportScript) (*exportCondition, error) {
+	if !strings.Contains(cond, exportTokenMark) {
+		ok, err := EvaluateCondition(cond)
+		if err != nil || !ok {
+			return nil, err
+		}
+		return &exportCondition{Op: "always"}, nil
+	}
+	if !isPlainCondition(cond) {
+		return nil, fmt.Errorf("invalid condition '%s' (a script can only test ==, !=, or a single value that depends on variables)", cond)
+	}
+	for _, op := range []string{"==", "!="} {
+		sides := strings.Split(cond, op)
+		if len(sides) == 1 {
+			continue
+		}
+		if len(sides) > 2 {
+			return nil, fmt.Errorf("invalid condition '%s' (only one %s allowed)", cond, op)
+		}
+		return &exportCondition{Op: op, Left: s.parts(conditionOperand(sides[0])), Right: s.parts(conditionOperand(sides[1]))}, nil
+	}
+	return &exportCondition{Left: s.parts(conditionOperand(cond))}, nil
+}
+
+// isPlainCondition reports whether a condition is `left == right`, `left != right`, or a
+// single value, which a script can test without an expression language
+func isPlainCondition(cond string) bool {
+	tokens, err := lexExpression(cond, nil, nil)
+	if err != nil {
+		return false
+	}
+	ops := 0
+	for _, token := range tokens {
+		switch {
+		case token.kind == exprCall, isExprKeyword(token):
+			return false
+		case token.kind == exprOp:
+			if ops++; ops > 1 || (token.text != "==" && token.text != "!=") {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// usedValues returns the values of a kind the script uses, sorted by name, with the
+// values of the same name merged: a parameter is required only if it has no default
+func (s *exportScript) usedValues(kind string) []exportValue {
+	byName := make(map[string]exportValue)
+	for v := range s.used {
+		if v.Kind != kind {
+			continue
+		}
+		existing, ok := byName[v.Name]
+		if !ok || (existing.Required && !v.Required) {
+			byName[v.Name] = v
+		}
+	}
+	values := make([]exportValue, 0, len(byName))
+	for _, v := range byName {
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].Name < values[j].Name })
+	return values
+}
+
+// usesBuiltin reports whether the script uses a built-in variable
+func (s *exportScript) usesBuiltin(name string) bool {
+	return s.used[exportValue{Kind: exportBuiltin, Name: name}]
+}
+
+// bashBuiltins compute the run-dependent built-in variables in bash
+var bashBuiltins = map[string]string{
+	"os":           `$(uname -s | tr '[:upper:]' '[:lower:]')`,
+	"arch":         `$(uname -m | sed -e 's/x86_64/amd64/' -e 's/aarch64/arm64/')`,
+	"cwd":          `$PWD`,
+	"timestamp":    `$(date -u +%Y%m%dT%H%M%SZ)`,
+	"date":         `$(date -u +%Y-%m-%d)`,
+	"uuid":         `$(cat /proc/sys/kernel/random/uuid 2>/dev/null || uuidgen | tr '[:upper:]' '[:lower:]')`,
+	"workflow_dir": `$(cd "$(dirname "$0")" && pwd)`,
+	"git_branch":   `$(git symbolic-ref --short -q HEAD 2>/dev/null)`,
+	"git_sha":      `$(git rev-parse HEAD 2>/dev/null)`,
+	"git_dirty":    `$([ -n "$(git status --porcelain 2>/dev/null)" ] && echo true || echo false)`,
+}
+
+// bashDoubleQuoted escapes text for a bash double-quoted string
+func bashDoubleQuoted(text string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`")
+	return replacer.Replace(text)
+}
+
+// bashValue returns the bash expansion of a value
+func bashValue(v *exportValue) string {
+	switch {
+	case v.Kind == exportBuiltin && v.Name == "exit_code":
+		return "${linea_exit_code}"
+	case v.Kind == exportBuiltin:
+		return "${linea_" + v.Name + "}"
+	case v.Kind == exportParam:
+		// Parameters are only set by arguments, so that the environment cannot set them
+		return "${linea_var_" + v.Name + "-" + strings.ReplaceAll(bashDoubleQuoted(v.Default), "}", `\}`) + "}"
+	case v.Default != "":
+		return "${" + v.Name + "-" + strings.ReplaceAll(bashDoubleQuoted(v.Default), "}", `\}`) + "}"
+	}
+	return "${" + v.Name + "}"
+}
+
+// bashIsTrue defines is_true, which tells whether a when: value holds like EvaluateCondition
+const bashIsTrue = `is_true() {
+  case "$(printf '%s' "$1" | tr '[:upper:]' '[:lower:]')" in ''|false|0|no) return 1 ;; esac
+}
+`
+
+// bashRenderer renders steps as bash, with value expanding the values computed by the script
+type bashRenderer struct {
+	value func(v *exportValue) string
+}
+
+// word renders parts as one bash word
+func (r bashRenderer) word(parts []exportPart) string {
+	if text, ok := literal(parts); ok {
+		return QuoteArg(text, QuotePOSIX)
+	}
+	var word strings.Builder
+	word.WriteString(`"`)
+	for _, p := range parts {
+		if p.Value != nil {
+			word.WriteString(r.value(p.Value))
+		} else {
+			word.WriteString(bashDoubleQuoted(p.Text))
+		}
+	}
+	word.WriteString(`"`)
+	return word.String()
+}
+
+// condition renders a when: condition as a bash test
+func (r bashRenderer) condition(c *exportCondition) string {
+	switch c.Op {
+	case "==":
+		return fmt.Sprintf("[ %s = %s ]", r.word(c.Left), r.word(c.Right))
+	case "!=":
+		return fmt.Sprintf("[ %s != %s ]", r.word(c.Left), r.word(c.Right))
+	}
+	return "is_true " + r.word(c.Left)
+}
+
+// command renders the command line of a step, asking its confirm: question if confirm is set
+func (r bashRenderer) command(step *exportStep, confirm bool) string {
+	if step.Unsupported != "" {
+		return fmt.Sprintf("echo %s >&2; false", QuoteArg("This step needs linea ("+step.Unsupported+")", QuotePOSIX))
+	}
+	var words []string
+	for _, word := range step.Words {
+		words = append(words, r.word(word))
+	}
+	line := strings.Join(words, " ")
+	switch {
+	case step.StdinText != nil:
+		line = "printf '%s' " + r.word(step.StdinText) + " | " + line
+	case step.StdinFile != nil:
+		line += " < " + r.word(step.StdinFile)
+	}
+	if confirm && step.Confirm != nil {
+		line = "confirm " + r.word(step.Confirm) + " && " + line
+	}
+	if step.Background {
+		line = "(" + line + ") &"
+	}
+	return line
+}
+
+// renderBashScript writes an exported workflow as a bash script
+func renderBashScript(s *exportScript) string {
+	var b strings.Builder
+	w := func(format string, args ...interface{}) { fmt.Fprintf(&b, format, args...) }
+	params := s.usedValues(exportParam)
+	envs := s.usedValues(exportEnv)
+	bash := bashRenderer{value: bashValue}
+
+	w("#!/usr/bin/env bash\n")
+	w("# %s: exported from %s by linea export --format bash\n", s.Name, s.Source)
+	if s.Description != "" {
+		w("# %s\n", s.Description)
+	}
+	writeExportUsage(w, s, "./"+s.Name+".sh [name=value]...", params, envs)
+
+	if len(params) > 0 {
+		w("\n# Variables are set with name=value arguments, like linea run -s name=value\n")
+		var names []string
+		for _, p := range params {
+			names = append(names, p.Name)
+		}
+		w("for arg in \"$@\"; do\n")
+		w("  case \"${arg%%%%=*}\" in\n")
+		w("    %s) printf -v \"linea_var_${arg%%%%=*}\" '%%s' \"${arg#*=}\" ;;\n", strings.Join(names, "|"))
+		w("    *) echo \"Unknown variable: $arg (usage: $0 [name=value]...)\" >&2; exit 2 ;;\n")
+		w("  esac\n")
+		w("done\n")
+	}
+	var checks []string
+	for _, v := range params {
+		if v.Required {
+			checks = append(checks, fmt.Sprintf(": \"${linea_var_%s?%s is required (pass %s=<value>)}\"\n", v.Name, v.Name, v.Name))
+		}
+	}
+	for _, v := range envs {
+		if v.Required {
+			checks = append(checks, fmt.Sprintf(": \"${%s?%s is required (set the environment variable %s)}\"\n", v.Name, v.Name, v.Name))
+		}
+	}
+	if len(checks) > 0 {
+		w("\n")
+		for _, check := range checks {
+			w("%s", check)
+		}
+	}
+
+	w("\nlinea_status=0     # Exit code of the script: that of the first failed step\n")
+	w("linea_exit_code=0  # Exit code of the last step\n")
+	for _, name := range exportRuntimeBuiltins {
+		if name != "exit_code" && s.usesBuiltin(name) {
+			w("linea_%s=%s\n", name, bashBuiltins[name])
+		}
+	}
+
+	w("\n# step_done records the exit code of a step, failing the script unless it is allowed\n")
+	w("step_done() {\n")
+	w("  linea_exit_code=$1\n")
+	w("  shift\n")
+	w("  for allowed in \"${@:-0}\"; do\n")
+	w("    [ \"$linea_exit_code\" -eq \"$allowed\" ] && return\n")
+	w("  done\n")
+	w("  [ \"$linea_status\" -ne 0 ] || linea_status=$(( linea_exit_code == 0 ? 1 : linea_exit_code ))\n")
+	w("}\n")
+	if s.usesConfirm() {
+		w("\n# confirm asks a question and succeeds if it is answered with y or yes, or if LINEA_YES is set\n")
+		w("confirm() {\n")
+		w("  if [ -n \"${LINEA_YES:-}\" ]; then echo \"$1 yes (LINEA_YES)\" >&2; return 0; fi\n")
+		w("  printf '%%s [y/N] ' \"$1\" >&2\n")
+		w("  read -r answer\n")
+		w("  case \"$(printf '%%s' \"$answer\" | tr '[:upper:]' '[:lower:]')\" in y|yes) return 0 ;; esac\n")
+		w("  return 1\n")
+		w("}\n")
+	}
+	if s.usesTruthTest() {
+		w("\n# is_true tells whether a when: value holds: it is not empty, false, 0, or no\n")
+		w("%s", bashIsTrue)
+	}
+
+	for _, step := range s.Steps {
+		w("\n# [%d/%d] %s\n", step.Index, step.Total, step.Name)
+		if step.Skipped {
+			w("# Skipped: its when: condition is false\n")
+			continue
+		}
+		var guards []string
+		switch {
+		case step.OnFailure:
+			guards = append(guards, `[ "$linea_status" -ne 0 ]`)
+		case !step.After:
+			guards = append(guards, `[ "$linea_status" -eq 0 ]`)
+		}
+		if step.When != nil {
+			guards = append(guards, bash.condition(step.When))
+		}
+		indent := ""
+		if len(guards) > 0 {
+			w("if %s; then\n", strings.Join(guards, " && "))
+			indent = "  "
+		}
+
+		w("%s%s\n", indent, bash.command(step, true))
+
+		allowed := ""
+		for _, code := range step.Allowed {
+			allowed += " " + strconv.Itoa(code)
+		}
+		w("%sstep_done $?%s\n", indent, allowed)
+		if len(guards) > 0 {
+			w("fi\n")
+		}
+	}
+
+	w("\nexit \"$linea_status\"\n")
+	return b.String()
+}
+
+// powerShellBuiltins compute the run-dependent built-in variables in PowerShell
+var powerShellBuiltins = map[string]string{
+	"os":           `if ($IsMacOS) { 'darwin' } elseif ($IsLinux) { 'linux' } else { 'windows' }`,
+	"arch":         `switch ([System.Runtime.InteropServices.RuntimeInformation]::OSArchitecture) { 'Arm64' { 'arm64' } 'X86' { '386' } default { 'amd64' } }`,
+	"cwd":          `(Get-Location).Path`,
+	"timestamp":    `(Get-Date).ToUniversalTime().ToString("yyyyMMdd'T'HHmmss'Z'")`,
+	"date":         `(Get-Date).ToUniversalTime().ToString('yyyy-MM-dd')`,
+	"uuid":         `[guid]::NewGuid().ToString()`,
+	"workflow_dir": `$PSScriptRoot`,
+	"git_branch":   `"$(git symbolic-ref --short -q HEAD 2>$null)"`,
+	"git_sha":      `"$(git rev-parse HEAD 2>$null)"`,
+	"git_dirty":    `if (git status --porcelain 2>$null) { 'true' } else { 'false' }`,
+}
+
+// powerShellDoubleQuoted escapes text for a PowerShell double-quoted string
+func powerShellDoubleQuoted(text string) string {
+	replacer := strings.NewReplacer("`", "``", `"`, "`\"", "$", "`$")
+	return replacer.Replace(text)
+}
+
+// powerShellValue returns the PowerShell expression of a value
+func powerShellValue(v *exportValue) string {
+	switch {
+	case v.Kind == exportBuiltin:
+		return "$linea_" + v.Name
+	case v.Kind == exportEnv && v.Default != "":
+		return fmt.Sprintf("$(if (Test-Path env:%s) { $env:%s } else { %s })", v.Name, v.Name, powerShellString(v.Default))
+	case v.Kind == exportEnv:
+		return "$env:" + v.Name
+	case v.Default != "":
+		return fmt.Sprintf("$(Get-LineaVariable %s %s)", powerShellString(v.Name), powerShellString(v.Default))
+	}
+	return "$($Set[" + powerShellString(v.Name) + "])"
+}
+
+// powerShellString renders text as a single-quoted PowerShell string
+func powerShellString(text string) string {
+	return "'" + strings.ReplaceAll(text, "'", "''") + "'"
+}
+
+// powerShellWord renders parts as one PowerShell argument
+func powerShellWord(parts []exportPart) string {
+	if text, ok := literal(parts); ok {
+		return QuoteArg(text, QuotePowerShell)
+	}
+	if len(parts) == 1 {
+		return powerShellValue(parts[0].Value)
+	}
+	var word strings.Builder
+	word.WriteString(`"`)
+	for _, p := range parts {
+		if p.Value != nil {
+			value := powerShellValue(p.Value)
+			if !strings.HasPrefix(value, "$(") {
+				value = "$(" + value + ")"
+			}
+			word.WriteString(value)
+		} else {
+			word.WriteString(powerShellDoubleQuoted(p.Text))
+		}
+	}
+	word.WriteString(`"`)
+	return word.String()
+}
+
+// powerShellOperand renders parts as a string in a PowerShell expression
+func powerShellOperand(parts []exportPart) string {
+	if text, ok := literal(parts); ok {
+		return powerShellString(text)
+	}
+	return "[string]" + powerShellWord(parts)
+}
+
+// powerShellCondition renders a when: condition as a PowerShell expression
+func powerShellCondition(c *exportCondition) string {
+	switch c.Op {
+	case "==":
+		return fmt.Sprintf("(%s -ceq %s)", powerShellOperand(c.Left), powerShellOperand(c.Right))
+	case "!=":
+		return fmt.Sprintf("(%s -cne %s)", powerShellOperand(c.Left), powerShellOperand(c.Right))
+	}
+	return fmt.Sprintf("(@('', 'false', '0', 'no') -notcontains %s)", powerShellOperand(c.Left))
+}
+
+// renderPowerShellScript writes an exported workflow as a PowerShell script
+func renderPowerShellScript(s *exportScript) string {
+	var b strings.Builder
+	w := func(format string, args ...interface{}) { fmt.Fprintf(&b, format, args...) }
+	params := s.usedValues(exportParam)
+	envs := s.usedValues(exportEnv)
+
+	w("# %s: exported from %s by linea export --format powershell\n", s.Name, s.Source)
+	if s.Description != "" {
+		w("# %s\n", s.Description)
+	}
+	writeExportUsage(w, s, "./"+s.Name+".ps1 [-Set @{ name = 'value' }]", params, envs)
+	w("\nparam([hashtable]$Set = @{})\n")
+	w("$ErrorActionPreference = 'Stop'\n")
+
+	if len(params) > 0 {
+		var names []string
+		for _, p := range params {
+			names = append(names, powerShellString(p.Name))
+		}
+		w("\n# Variables are set with -Set @{ name = 'value' }, like linea run -s name=value\n")
+		w("$linea_variables = @(%s)\n", strings.Join(names, ", "))
+		w("foreach ($name in $Set.Keys) {\n")
+		w("  if ($linea_variables -notcontains $name) { throw \"Unknown variable: $name\" }\n")
+		w("}\n")
+		w("function Get-LineaVariable([string]$Name, [string]$Default) {\n")
+		w("  if ($Set.ContainsKey($Name)) { [string]$Set[$Name] } else { $Default }\n")
+		w("}\n")
+	}
+	var checks []string
+	for _, v := range append(append([]exportValue{}, params...), envs...) {
+		if !v.Required {
+			continue
+		}
+		if v.Kind == exportEnv {
+			checks = append(checks, fmt.Sprintf("if (-not (Test-Path env:%s)) { throw '%s is required (set the environment variable %s)' }\n", v.Name, v.Name, v.Name))
+		} else {
+			checks = append(checks, fmt.Sprintf("if (-not $Set.ContainsKey(%s)) { throw '%s is required (pass -Set @{ %s = ''<value>'' })' }\n", powerShellString(v.Name), v.Name, v.Name))
+		}
+	}
+	if len(checks) > 0 {
+		w("\n")
+		for _, check := range checks {
+			w("%s", check)
+		}
+	}
+
+	w("\n$linea_status = 0     # Exit code of the script: that of the first failed step\n")
+	w("$linea_exit_code = 0  # Exit code of the last step\n")
+	for _, name := range exportRuntimeBuiltins {
+		if name != "exit_code" && s.usesBuiltin(name) {
+			w("$linea_%s = %s\n", name, powerShellBuiltins[name])
+		}
+	}
+
+	w("\n# Invoke-Step runs a step and records its exit code, failing the script unless it is allowed\n")
+	w("function Invoke-Step([scriptblock]$Step, [int[]]$Allowed = @(0)) {\n")
+	w("  $global:LASTEXITCODE = 0\n")
+	w("  try { & $Step; $code = $global:LASTEXITCODE } catch { Write-Error $_ -ErrorAction Continue; $code = 1 }\n")
+	w("  $script:linea_exit_code = $code\n")
+	w("  if ($Allowed -notcontains $code -and $script:linea_status -eq 0) {\n")
+	w("    $script:linea_status = if ($code -eq 0) { 1 } else { $code }\n")
+	w("  }\n")
+	w("}\n")
+	if s.usesConfirm() {
+		w("\n# Confirm-Step asks a question and tells whether it is answered with y or yes, or LINEA_YES is set\n")
+		w("function Confirm-Step([string]$Question) {\n")
+		w("  if ($env:LINEA_YES) { Write-Host \"$Question yes (LINEA_YES)\"; return $true }\n")
+		w("  return @('y', 'yes') -contains (Read-Host \"$Question [y/N]\").Trim()\n")
+		w("}\n")
+	}
+
+	for _, step := range s.Steps {
+		w("\n# [%d/%d] %s\n", step.Index, step.Total, step.Name)
+		if step.Skipped {
+			w("# Skipped: its when: condition is false\n")
+			continue
+		}
+		var guards []string
+		switch {
+		case step.OnFailure:
+			guards = append(guards, "$linea_status -ne 0")
+		case !step.After:
+			guards = append(guards, "$linea_status -eq 0")
+		}
+		if step.When != nil {
+			guards = append(guards, powerShellCondition(step.When))
+		}
+		indent := ""
+		if len(guards) > 0 {
+			w("if (%s) {\n", strings.Join(guards, " -and "))
+			indent = "  "
+		}
+
+		var line string
+		if step.Unsupported != "" {
+			line = fmt.Sprintf("Write-Error %s", powerShellString("This step needs linea ("+step.Unsupported+")"))
+		} else {
+			var words []string
+			for _, word := range step.Words {
+				words = append(words, powerShellWord(word))
+			}
+			if step.Background {
+				line = "Start-Process -NoNewWindow -FilePath " + words[0]
+				if len(words) > 1 {
+					line += " -ArgumentList " + strings.Join(words[1:], ", ")
+				}
+			} else {
+				line = "& " + strings.Join(words, " ")
+			}
+			switch {
+			case step.StdinText != nil:
+				line = powerShellWord(step.StdinText) + " | " + line
+			case step.StdinFile != nil:
+				line = "Get-Content -Raw -LiteralPath " + powerShellWord(step.StdinFile) + " | " + line
+			}
+			if step.Confirm != nil {
+				line = "if (Confirm-Step " + powerShellWord(step.Confirm) + ") { " + line + " } else { $global:LASTEXITCODE = 1 }"
+			}
+		}
+
+		allowed := ""
+		if len(step.Allowed) > 0 {
+			var codes []string
+			for _, code := range step.Allowed {
+				codes = append(codes, strconv.Itoa(code))
+			}
+			allowed = " " + strings.Join(codes, ", ")
+		}
+		w("%sInvoke-Step { %s }%s\n", indent, line, allowed)
+		if len(guards) > 0 {
+			w("}\n")
+		}
+	}
+
+	w("\nexit $linea_status\n")
+	return b.String()
+}
+
+// writeExportUsage writes the usage comment of a script: its parameters, environment
+// variables, and notes
+func writeExportUsage(w func(string, ...interface{}), s *exportScript, usage string, params, envs []exportValue) {
+	w("#\n# Usage: %s\n", usage)
+	for _, p := range params {
+		if p.Required {
+			w("#   %s (required)\n", p.Name)
+		} else {
+			w("#   %s (default: %s)\n", p.Name, p.Default)
+		}
+	}
+	if len(envs) > 0 {
+		var names []string
+		for _, v := range envs {
+			names = append(names, v.Name)
+		}
+		w("# Environment: %s\n", strings.Join(names, ", "))
+	}
+	if s.usesConfirm() {
+		w("# Set LINEA_YES=1 to answer yes to the confirmations\n")
+	}
+	if len(s.Notes) > 0 {
+		w("#\n# Differences from linea run:\n")
+		for _, note := range s.Notes {
+			w("#   - %s\n", note)
+		}
+	}
+}
+
+// usesConfirm reports whether a step of the script asks for confirmation
+func (s *exportScript) usesConfirm() bool {
+	for _, step := range s.Steps {
+		if step.Confirm != nil && step.Unsupported == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// usesTruthTest reports whether a when: condition of the script tests a single value
+func (s *exportScript) usesTruthTest() bool {
+	for _, step := range s.Steps {
+		if step.When != nil && step.When.Op == "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,343 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ghaExportRunner is the runner of the job of an exported GitHub Actions workflow
+const ghaExportRunner = "ubuntu-latest"
+
+// ciJobInvalid matches the characters not allowed in a CI job ID
+var ciJobInvalid = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// ghaExport is a GitHub Actions workflow written by linea export --format gha
+type ghaExport struct {
+	Name string                   `yaml:"name"`
+	On   ghaExportOn              `yaml:"on"`
+	Jobs map[string]*ghaExportJob `yaml:"jobs"`
+}
+
+type ghaExportOn struct {
+	Push             struct{}          `yaml:"push"`
+	WorkflowDispatch ghaExportDispatch `yaml:"workflow_dispatch"`
+}
+
+type ghaExportDispatch struct {
+	Inputs map[string]ghaExportInput `yaml:"inputs,omitempty"`
+}
+
+type ghaExportInput struct {
+	Description string `yaml:"description"`
+	Required    bool   `yaml:"required"`
+	Default     string `yaml:"default,omitempty"`
+}
+
+type ghaExportJob struct {
+	RunsOn   string             `yaml:"runs-on"`
+	Strategy *ghaExportStrategy `yaml:"strategy,omitempty"`
+	Env      map[string]string  `yaml:"env,omitempty"`
+	Steps    []ghaExportStep    `yaml:"steps"`
+}
+
+type ghaExportStrategy struct {
+	FailFast bool                `yaml:"fail-fast"`
+	Matrix   map[string][]string `yaml:"matrix"`
+}
+
+type ghaExportStep struct {
+	Name string            `yaml:"name,omitempty"`
+	Uses string            `yaml:"uses,omitempty"`
+	If   string            `yaml:"if,omitempty"`
+	Env  map[string]string `yaml:"env,omitempty"`
+	Run  string            `yaml:"run,omitempty"`
+}
+
+// gitLabExport is a GitLab CI pipeline written by linea export --format gitlab
+type gitLabExport struct {
+	Variables map[string]gitLabExportVariable `yaml:"variables,omitempty"`
+	Jobs      map[string]*gitLabExportJob     `yaml:",inline"`
+}
+
+type gitLabExportVariable struct {
+	Value       string `yaml:"value"`
+	Description string `yaml:"description"`
+	Expand      *bool  `yaml:"expand,omitempty"`
+}
+
+type gitLabExportJob struct {
+	Parallel    *gitLabExportParallel `yaml:"parallel,omitempty"`
+	Script      []string              `yaml:"script"`
+	AfterScript []string              `yaml:"after_script,omitempty"`
+}
+
+type gitLabExportParallel struct {
+	Matrix []map[string][]string `yaml:"matrix"`
+}
+
+// renderGitHubActions writes an exported workflow as a GitHub Actions workflow with one
+// job, whose steps are the steps of the workflow
+func renderGitHubActions(s *exportScript, matrix map[string][]string) (string, error) {
+	job := &ghaExportJob{RunsOn: ghaExportRunner, Env: map[string]string{}}
+	if len(matrix) > 0 {
+		job.Strategy = &ghaExportStrategy{Matrix: matrix}
+	}
+	job.Steps = append(job.Steps, ghaExportStep{Uses: "actions/checkout@v4"})
+
+	for _, step := range s.Steps {
+		if step.Skipped {
+			continue
+		}
+		exported := ghaExportStep{Name: step.Name, Run: s.ciScript(step, "gha")}
+		switch {
+		case step.OnFailure:
+			exported.If = "failure()"
+		case step.After:
+			exported.If = "always()"
+		}
+		for _, v := range step.values() {
+			if v.Kind == exportEnv && v.Secret {
+				if exported.Env == nil {
+					exported.Env = map[string]string{}
+				}
+				exported.Env[v.Name] = "${{ secrets." + v.Name + " }}"
+			}
+		}
+		job.Steps = append(job.Steps, exported)
+	}
+
+	workflow := ghaExport{Name: s.Name, Jobs: map[string]*ghaExportJob{ciJobName(s.Name): job}}
+	for _, p := range s.usedValues(exportParam) {
+		if workflow.On.WorkflowDispatch.Inputs == nil {
+			workflow.On.WorkflowDispatch.Inputs = map[string]ghaExportInput{}
+		}
+		workflow.On.WorkflowDispatch.Inputs[p.Name] = ghaExportInput{Description: "Variable " + p.Name + " of the workflow", Required: p.Required, Default: p.Default}
+		if p.Required {
+			job.Env[p.Name] = "${{ inputs." + p.Name + " }}"
+		} else {
+			job.Env[p.Name] = "${{ inputs." + p.Name + " || '" + strings.ReplaceAll(p.Default, "'", "''") + "' }}"
+		}
+	}
+	for name := range matrix {
+		job.Env[name] = "${{ matrix." + name + " }}"
+	}
+	return renderCIExport(s, "gha", workflow)
+}
+
+// renderGitLabCI writes an exported workflow as a GitLab CI pipeline with one job: its
+// script runs the steps of the workflow, and its after_script the after: and
+// on_failure: steps
+func renderGitLabCI(s *exportScript, matrix map[string][]string) (string, error) {
+	job := &gitLabExportJob{}
+	if len(matrix) > 0 {
+		job.Parallel = &gitLabExportParallel{Matrix: []map[string][]string{matrix}}
+	}
+
+	for _, step := range s.Steps {
+		if step.Skipped {
+			continue
+		}
+		script := s.ciScript(step, "gitlab")
+		switch {
+		case step.OnFailure:
+			job.AfterScript = append(job.AfterScript, "if [ \"$CI_JOB_STATUS\" = failed ]; then\n"+indentLines(script, "  ")+"\nfi")
+		case step.After:
+			job.AfterScript = append(job.AfterScript, script)
+		default:
+			if len(job.AfterScript) > 0 {
+				s.note("step %s: runs before the after: and on_failure: steps, which run in after_script", step.Name)
+			}
+			job.Script = append(job.Script, script)
+		}
+		for _, v := range step.values() {
+			if v.Kind == exportEnv && v.Secret {
+				s.note("the secret %s must be a CI/CD variable of the project", v.Name)
+			}
+		}
+	}
+	if len(job.AfterScript) > 0 {
+		s.note("after: and on_failure: steps run in after_script, whose failures do not fail the job")
+	}
+
+	pipeline := gitLabExport{Jobs: map[string]*gitLabExportJob{ciJobName(s.Name): job}}
+	for _, p := range s.usedValues(exportParam) {
+		if pipeline.Variables == nil {
+			pipeline.Variables = map[string]gitLabExportVariable{}
+		}
+		variable := gitLabExportVariable{Value: p.Default, Description: "Variable " + p.Name + " of the workflow"}
+		if p.Required {
+			variable.Description += " (required)"
+		}
+		if strings.Contains(p.Default, "$") {
+			expand := false
+			variable.Expand = &expand
+		}
+		pipeline.Variables[p.Name] = variable
+	}
+	return renderCIExport(s, "gitlab", pipeline)
+}
+
+// renderCIExport marshals a CI pipeline, under a header naming its source and listing
+// the notes of the export
+func renderCIExport(s *exportScript, format string, pipeline interface{}) (string, error) {
+	var data bytes.Buffer
+	encoder := yaml.NewEncoder(&data)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(pipeline); err != nil {
+		return "", err
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s: exported from %s by linea export --format %s\n", s.Name, s.Source, format)
+	if s.Description != "" {
+		fmt.Fprintf(&b, "# %s\n", s.Description)
+	}
+	if len(s.Notes) > 0 {
+		fmt.Fprintf(&b, "#\n# Differences from linea run:\n")
+		for _, note := range s.Notes {
+			fmt.Fprintf(&b, "#   - %s\n", note)
+		}
+	}
+	b.WriteString("\n")
+	b.Write(data.Bytes())
+	return b.String(), nil
+}
+
+// ciScript renders a step as the bash script of a CI step. The built-in variables it
+// uses are computed first, and variables and secrets are read from the environment
+func (s *exportScript) ciScript(step *exportStep, format string) string {
+	bash := bashRenderer{value: func(v *exportValue) string {
+		switch v.Kind {
+		case exportBuiltin:
+			return "${linea_" + v.Name + "}"
+		case exportParam, exportMatrix:
+			return "${" + v.Name + "}"
+		}
+		return bashValue(v)
+	}}
+
+	var lines []string
+	values := step.values()
+	for _, v := range values {
+		if v.Kind == exportParam && v.Required {
+			lines = append(lines, fmt.Sprintf(": \"${%s:?%s is required}\"", v.Name, v.Name))
+		}
+	}
+	for _, name := range exportRuntimeBuiltins {
+		for _, v := range values {
+			if v.Kind == exportBuiltin && v.Name == name {
+				lines = append(lines, "linea_"+name+"="+s.ciBuiltin(name, format))
+				break
+			}
+		}
+	}
+	if step.Confirm != nil && step.Unsupported == "" {
+		s.note("step %s: confirm: is not asked; the step runs as with --yes", step.Name)
+	}
+
+	body := []string{bash.command(step, false)}
+	if len(step.Allowed) > 0 && !step.Background {
+		var codes []string
+		for _, code := range step.Allowed {
+			codes = append(codes, fmt.Sprint(code))
+		}
+		body = []string{
+			"code=0",
+			body[0] + " || code=$?",
+			fmt.Sprintf("case \"$code\" in %s) ;; *) exit $(( code == 0 ? 1 : code )) ;; esac", strings.Join(codes, "|")),
+		}
+	}
+	if step.When != nil {
+		if step.When.Op == "" {
+			lines = append(lines, strings.TrimSuffix(bashIsTrue, "\n"))
+		}
+		lines = append(lines, "if "+bash.condition(step.When)+"; then")
+		lines = append(lines, indentLines(strings.Join(body, "\n"), "  "))
+		lines = append(lines, "fi")
+	} else {
+		lines = append(lines, body...)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ciBuiltin returns the bash expression computing a built-in variable in a CI job, which
+// starts in the root of the repository
+func (s *exportScript) ciBuiltin(name, format string) string {
+	switch name {
+	case "exit_code":
+		s.note("{exit_code} is empty: the exit code of the failed step is not available to the next steps")
+		return "''"
+	case "workflow_dir":
+		dir := filepath.Dir(s.Source)
+		if abs, err := filepath.Abs(dir); err == nil {
+			if cwd, err := os.Getwd(); err == nil {
+				if rel, err := filepath.Rel(cwd, abs); err == nil {
+					dir = rel
+				}
+			}
+		}
+		if dir == "." {
+			return "$PWD"
+		}
+		return "$PWD/" + QuoteArg(filepath.ToSlash(dir), QuotePOSIX)
+	case "git_branch":
+		if format == "gha" {
+			return "$GITHUB_REF_NAME"
+		}
+		return "$CI_COMMIT_REF_NAME"
+	}
+	return bashBuiltins[name]
+}
+
+// values returns the values a step uses, in the order they appear
+func (step *exportStep) values() []exportValue {
+	var values []exportValue
+	seen := map[exportValue]bool{}
+	add := func(parts []exportPart) {
+		for _, p := range parts {
+			if p.Value != nil && !seen[*p.Value] {
+				seen[*p.Value] = true
+				values = append(values, *p.Value)
+			}
+		}
+	}
+	for _, word := range step.Words {
+		add(word)
+	}
+	if step.When != nil {
+		add(step.When.Left)
+		add(step.When.Right)
+	}
+	add(step.StdinText)
+	add(step.StdinFile)
+	return values
+}
+
+// ciJobName returns the ID of the CI job running a workflow
+func ciJobName(name string) string {
+	name = strings.Trim(ciJobInvalid.ReplaceAllString(name, "-"), "-")
+	if name == "" {
+		return "linea"
+	}
+	return name
+}
+
+// indentLines indents every non-empty line of text
+func indentLines(text, indent string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = indent + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
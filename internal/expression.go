@@ -0,0 +1,420 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Expressions are the language of when: conditions and expression defaults: comparisons
+// (== != < <= > >=), and/or/not (also && || !), parentheses, quoted strings, and calls of
+// ExpressionFunctions. Every value is a string; comparisons and not give "true" or "false",
+// and, like in Python, `a or b` gives a if it holds, else b. Variable placeholders are
+// replaced by their values as single literals, so a value is never read as an operator.
+// Other text is a literal too, with the words of a run such as `a b` kept together
+
+// Kinds of the tokens of an expression
+const (
+	exprWord   = iota // Literal text, or a keyword such as and
+	exprString        // Quoted string
+	exprOp            // Comparison or logical operator, or a parenthesis or comma
+	exprCall          // Name of a function called by the next tokens
+)
+
+// exprToken is a token of an expression
+type exprToken struct {
+	kind  int
+	text  string
+	space string // The whitespace before the token
+	value bool   // A word holding a variable value, which is never a keyword
+}
+
+// exprFunc implements a function of expressions, called with a checked number of arguments
+type exprFunc struct {
+	minArgs, maxArgs int
+	call             func(args []string) (string, error)
+}
+
+// ExpressionFunctions are the functions expressions can call, such as exists(dist/app.zip)
+var ExpressionFunctions = map[string]exprFunc{
+	"exists": {1, 1, func(args []string) (string, error) {
+		_, err := os.Stat(args[0])
+		return strconv.FormatBool(err == nil), nil
+	}},
+	"env": {1, 2, func(args []string) (string, error) {
+		if value, ok := os.LookupEnv(args[0]); ok || len(args) == 1 {
+			return value, nil
+		}
+		return args[1], nil
+	}},
+	"contains": {2, 2, func(args []string) (string, error) {
+		return strconv.FormatBool(strings.Contains(args[0], args[1])), nil
+	}},
+	"starts_with": {2, 2, func(args []string) (string, error) {
+		return strconv.FormatBool(strings.HasPrefix(args[0], args[1])), nil
+	}},
+	"ends_with": {2, 2, func(args []string) (string, error) {
+		return strconv.FormatBool(strings.HasSuffix(args[0], args[1])), nil
+	}},
+	"matches": {2, 2, func(args []string) (string, error) {
+		re, err := regexp.Compile(args[1])
+		if err != nil {
+			return "", fmt.Errorf("matches: %w", err)
+		}
+		return strconv.FormatBool(re.MatchString(args[0])), nil
+	}},
+	"lower": {1, 1, func(args []string) (string, error) { return strings.ToLower(args[0]), nil }},
+	"upper": {1, 1, func(args []string) (string, error) { return strings.ToUpper(args[0]), nil }},
+	"trim":  {1, 1, func(args []string) (string, error) { return strings.TrimSpace(args[0]), nil }},
+	"len":   {1, 1, func(args []string) (string, error) { return strconv.Itoa(len(args[0])), nil }},
+}
+
+// EvaluateExpression evaluates an expression, replacing its {name} placeholders with
+// braceVars and its $name and ${name} ones with dollarVars, like substituteTemplate
+func EvaluateExpression(expr string, braceVars, dollarVars map[string]string) (string, error) {
+	tokens, err := lexExpression(expr, braceVars, dollarVars)
+	if err != nil {
+		return "", fmt.Errorf("invalid expression '%s': %w", expr, err)
+	}
+	if len(tokens) == 0 {
+		return "", nil
+	}
+	p := &exprParser{tokens: tokens}
+	value, err := p.or()
+	if err == nil && p.pos < len(p.tokens) {
+		err = fmt.Errorf("unexpected '%s'", p.tokens[p.pos].text)
+	}
+	if err != nil {
+		return "", fmt.Errorf("invalid expression '%s': %w", expr, err)
+	}
+	return value, nil
+}
+
+// IsTrue reports whether the value of an expression holds: it is not empty, false, no, or 0
+func IsTrue(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "false", "0", "no":
+		return false
+	}
+	return true
+}
+
+// ExpressionDefault returns a default: value, evaluating it if it starts with = as in
+// `default: "=env('REGION', 'eu-west-1')"`; == starts a literal =
+func ExpressionDefault(value string) (string, error) {
+	if !strings.HasPrefix(value, "=") {
+		return value, nil
+	}
+	if strings.HasPrefix(value, "==") {
+		return value[1:], nil
+	}
+	return EvaluateExpression(value[1:], nil, nil)
+}
+
+// Sources of the bytes of an expression once its placeholders are replaced
+const (
+	exprFromText  = iota
+	exprFromValue // A byte of the value of a variable
+	exprFromEmpty // Stands for a variable whose value is empty, and is not part of the text
+)
+
+// lexExpression splits an expression into tokens, after replacing its placeholders
+func lexExpression(expr string, braceVars, dollarVars map[string]string) ([]exprToken, error) {
+	var src []byte
+	var from []int
+	for _, token := range tokenizeTemplate(expr) {
+		text := resolveToken(token, braceVars, dollarVars)
+		switch {
+		case token.kind == tokenText:
+			for j := 0; j < len(text); j++ {
+				from = append(from, exprFromText)
+			}
+		case text == "":
+			// An empty value is still a value, as in `{branch} == main`
+			text = "\x00"
+			from = append(from, exprFromEmpty)
+		default:
+			for j := 0; j < len(text); j++ {
+				from = append(from, exprFromValue)
+			}
+		}
+		src = append(src, text...)
+	}
+	mask := make([]bool, len(from))
+	for i := range from {
+		mask[i] = from[i] != exprFromText
+	}
+	// textOf returns the text of src[start:end] without the bytes standing for empty values
+	textOf := func(start, end int) string {
+		var b strings.Builder
+		for i := start; i < end; i++ {
+			if from[i] != exprFromEmpty {
+				b.WriteByte(src[i])
+			}
+		}
+		return b.String()
+	}
+
+	var tokens []exprToken
+	var calls []bool // Whether each open parenthesis is that of a call
+	for i := 0; i < len(src); {
+		start := i
+		for i < len(src) && !mask[i] && (src[i] == ' ' || src[i] == '\t' || src[i] == '\n' || src[i] == '\r') {
+			i++
+		}
+		space := string(src[start:i])
+		if i == len(src) {
+			break
+		}
+
+		inCall := len(calls) > 0 && calls[len(calls)-1]
+		op := ""
+		if !mask[i] {
+			op = exprOperatorAt(src, i, inCall, true)
+		}
+		switch {
+		case !mask[i] && (src[i] == '"' || src[i] == '\''):
+			end := i + 1
+			for end < len(src) && (src[end] != src[i] || mask[end]) {
+				end++
+			}
+			if end == len(src) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			tokens = append(tokens, exprToken{kind: exprString, text: textOf(i+1, end), space: space})
+			i = end + 1
+		case op != "":
+			switch op {
+			case "(":
+				calls = append(calls, space == "" && len(tokens) > 0 && tokens[len(tokens)-1].kind == exprCall)
+			case ")":
+				if len(calls) == 0 {
+					return nil, fmt.Errorf("unexpected ')'")
+				}
+				calls = calls[:len(calls)-1]
+			}
+			tokens = append(tokens, exprToken{kind: exprOp, text: op, space: space})
+			i += len(op)
+		default:
+			end, value := i, false
+			for end < len(src) && (mask[end] || (exprOperatorAt(src, end, inCall, false) == "" && !strings.ContainsRune(" \t\n\r", rune(src[end])))) {
+				value = value || mask[end]
+				end++
+			}
+			token := exprToken{kind: exprWord, text: textOf(i, end), space: space, value: value}
+			if !value && end < len(src) && src[end] == '(' {
+				if _, ok := ExpressionFunctions[token.text]; !ok {
+					return nil, fmt.Errorf("unknown function %s()", token.text)
+				}
+				token.kind = exprCall
+			}
+			tokens = append(tokens, token)
+			i = end
+		}
+	}
+	if len(calls) > 0 {
+		return nil, fmt.Errorf("missing ')'")
+	}
+	return tokens, nil
+}
+
+// exprOperatorAt returns the operator that starts at src[i], or "". A comma is one only
+// in the arguments of a call, and ! only at the start of a token, as in !exists(x)
+func exprOperatorAt(src []byte, i int, inCall, start bool) string {
+	if i+1 < len(src) {
+		switch two := string(src[i : i+2]); two {
+		case "==", "!=", "<=", ">=", "&&", "||":
+			return two
+		}
+	}
+	switch src[i] {
+	case '<', '>', '(', ')':
+		return string(src[i])
+	case '!':
+		if start {
+			return "!"
+		}
+	case ',':
+		if inCall {
+			return ","
+		}
+	}
+	return ""
+}
+
+// exprParser evaluates the tokens of an expression by recursive descent
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+}
+
+// peek reports whether the next token is the operator or keyword text
+func (p *exprParser) peek(texts ...string) bool {
+	if p.pos == len(p.tokens) {
+		return false
+	}
+	token := p.tokens[p.pos]
+	if token.kind == exprString || token.kind == exprCall || token.value {
+		return false
+	}
+	for _, text := range texts {
+		if token.text == text {
+			return true
+		}
+	}
+	return false
+}
+
+// or evaluates `a or b`, which gives a if it holds, else b
+func (p *exprParser) or() (string, error) {
+	left, err := p.and()
+	for err == nil && p.peek("or", "||") {
+		p.pos++
+		var right string
+		if right, err = p.and(); !IsTrue(left) {
+			left = right
+		}
+	}
+	return left, err
+}
+
+// and evaluates `a and b`, which gives a unless it holds, else b
+func (p *exprParser) and() (string, error) {
+	left, err := p.not()
+	for err == nil && p.peek("and", "&&") {
+		p.pos++
+		var right string
+		if right, err = p.not(); IsTrue(left) {
+			left = right
+		}
+	}
+	return left, err
+}
+
+// not evaluates `not a`
+func (p *exprParser) not() (string, error) {
+	if p.peek("not", "!") {
+		p.pos++
+		value, err := p.not()
+		return strconv.FormatBool(!IsTrue(value)), err
+	}
+	return p.comparison()
+}
+
+// comparison evaluates `a == b` and the other comparisons. == and != compare text; the
+// others compare numbers if both sides are numbers, else text
+func (p *exprParser) comparison() (string, error) {
+	left, err := p.primary()
+	if err != nil || !p.peek("==", "!=", "<", "<=", ">", ">=") {
+		return left, err
+	}
+	op := p.tokens[p.pos].text
+	p.pos++
+	right, err := p.primary()
+	if err != nil {
+		return "", err
+	}
+	if p.peek("==", "!=", "<", "<=", ">", ">=") {
+		return "", fmt.Errorf("comparisons cannot be chained (use and)")
+	}
+
+	cmp := strings.Compare(left, right)
+	l, lerr := strconv.ParseFloat(strings.TrimSpace(left), 64)
+	r, rerr := strconv.ParseFloat(strings.TrimSpace(right), 64)
+	if lerr == nil && rerr == nil && op != "==" && op != "!=" {
+		cmp = 0
+		if l < r {
+			cmp = -1
+		} else if l > r {
+			cmp = 1
+		}
+	}
+	switch op {
+	case "==":
+		return strconv.FormatBool(cmp == 0), nil
+	case "!=":
+		return strconv.FormatBool(cmp != 0), nil
+	case "<":
+		return strconv.FormatBool(cmp < 0), nil
+	case "<=":
+		return strconv.FormatBool(cmp <= 0), nil
+	case ">":
+		return strconv.FormatBool(cmp > 0), nil
+	}
+	return strconv.FormatBool(cmp >= 0), nil
+}
+
+// primary evaluates a string, a run of words, a call, or an expression in parentheses
+func (p *exprParser) primary() (string, error) {
+	if p.pos == len(p.tokens) {
+		return "", fmt.Errorf("missing value at the end")
+	}
+	token := p.tokens[p.pos]
+	p.pos++
+	switch {
+	case token.kind == exprString:
+		return token.text, nil
+	case token.kind == exprCall:
+		return p.call(token.text)
+	case token.kind == exprOp && token.text == "(":
+		value, err := p.or()
+		if err == nil && !p.peek(")") {
+			err = fmt.Errorf("missing ')'")
+		}
+		p.pos++
+		return value, err
+	case token.kind == exprOp || isExprKeyword(token):
+		return "", fmt.Errorf("unexpected '%s'", token.text)
+	}
+
+	text := token.text
+	for p.pos < len(p.tokens) && p.tokens[p.pos].kind == exprWord && !isExprKeyword(p.tokens[p.pos]) {
+		text += p.tokens[p.pos].space + p.tokens[p.pos].text
+		p.pos++
+	}
+	return text, nil
+}
+
+// call evaluates the arguments of a call to a function and calls it
+func (p *exprParser) call(name string) (string, error) {
+	p.pos++ // (
+	var args []string
+	for !p.peek(")") {
+		arg, err := p.or()
+		if err != nil {
+			return "", err
+		}
+		args = append(args, arg)
+		if !p.peek(",") {
+			break
+		}
+		p.pos++
+	}
+	if !p.peek(")") {
+		return "", fmt.Errorf("missing ')' after the arguments of %s()", name)
+	}
+	p.pos++
+
+	fn := ExpressionFunctions[name]
+	if len(args) < fn.minArgs || len(args) > fn.maxArgs {
+		if fn.minArgs == fn.maxArgs {
+			return "", fmt.Errorf("%s() takes %d argument(s), not %d", name, fn.minArgs, len(args))
+		}
+		return "", fmt.Errorf("%s() takes %d to %d arguments, not %d", name, fn.minArgs, fn.maxArgs, len(args))
+	}
+	return fn.call(args)
+}
+
+// isExprKeyword reports whether a token is the keyword and, or, or not
+func isExprKeyword(token exprToken) bool {
+	if token.kind != exprWord || token.value {
+		return false
+	}
+	switch token.text {
+	case "and", "or", "not":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,329 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Types of the steps that work on files, natively on every OS
+const (
+	StepTypeCopy     = "copy"
+	StepTypeMove     = "move"
+	StepTypeTemplate = "template"
+	StepTypeMkdir    = "mkdir"
+	StepTypeRm       = "rm"
+)
+
+// FileSpec configures a file step: the src and dest of a copy:, move:, or template:, or
+// the path of a mkdir: or rm:
+type FileSpec struct {
+	Src  string `yaml:"src,omitempty"`
+	Dest string `yaml:"dest,omitempty"`
+	Path string `yaml:"path,omitempty"`
+}
+
+// fileSpec returns the spec of a file step of type stepType, nil if it has none
+func fileSpec(config *CommandConfig, stepType string) *FileSpec {
+	switch stepType {
+	case StepTypeCopy:
+		return config.Copy
+	case StepTypeMove:
+		return config.Move
+	case StepTypeTemplate:
+		return config.Template
+	case StepTypeMkdir:
+		return config.Mkdir
+	case StepTypeRm:
+		return config.Rm
+	}
+	return nil
+}
+
+// CheckFileStep validates the spec of a file step, and that only the spec of its own
+// type is set
+func CheckFileStep(config *CommandConfig) error {
+	for _, stepType := range []string{StepTypeCopy, StepTypeMove, StepTypeTemplate, StepTypeMkdir, StepTypeRm} {
+		spec := fileSpec(config, stepType)
+		if stepType != config.Type {
+			if spec != nil {
+				return fmt.Errorf("%s: requires type: %s", stepType, stepType)
+			}
+			continue
+		}
+		if spec == nil {
+			return fmt.Errorf("type: %s needs a %s: with %s", stepType, stepType, fileSpecFields(stepType))
+		}
+		return spec.check(stepType)
+	}
+	return nil
+}
+
+// fileSpecFields names the fields of the spec of a file step
+func fileSpecFields(stepType string) string {
+	if stepType == StepTypeMkdir || stepType == StepTypeRm {
+		return "path"
+	}
+	return "src and dest"
+}
+
+// check validates the fields of the spec of a file step of type stepType
+func (f *FileSpec) check(stepType string) error {
+	if stepType == StepTypeMkdir || stepType == StepTypeRm {
+		if f.Path == "" || f.Src != "" || f.Dest != "" {
+			return fmt.Errorf("%s needs a path, and no src or dest", stepType)
+		}
+		return nil
+	}
+	if f.Src == "" || f.Dest == "" || f.Path != "" {
+		return fmt.Errorf("%s needs a src and a dest, and no path", stepType)
+	}
+	return nil
+}
+
+// fileStepArgs returns the function turning the spec of a file step into its arguments
+func fileStepArgs(stepType string) func(config *CommandConfig) ([]string, error) {
+	return func(config *CommandConfig) ([]string, error) {
+		if err := CheckFileStep(config); err != nil {
+			return nil, err
+		}
+		spec := fileSpec(config, stepType)
+		if spec.Path != "" {
+			return []string{spec.Path}, nil
+		}
+		return []string{spec.Src, spec.Dest}, nil
+	}
+}
+
+// fileStepError is the failure of a file step, with exit code 1
+func fileStepError(stepType string, err error) error {
+	return &StepExitError{Code: 1, Err: fmt.Errorf("%s: %w", stepType, err)}
+}
+
+// runCopy copies a file or a directory tree, like cp -R
+func runCopy(args []string, stdout, stderr io.Writer) error {
+	if len(args) != 2 {
+		return fmt.Errorf("copy: expected a source and a destination")
+	}
+	src, dest := args[0], targetPath(args[0], args[1])
+	files, err := copyTree(src, dest)
+	if err != nil {
+		return fileStepError(StepTypeCopy, err)
+	}
+	fmt.Fprintf(stdout, "copy: %s -> %s (%d file(s))\n", src, dest, files)
+	return nil
+}
+
+// runMove moves a file or a directory tree, copying it when it cannot be renamed, as
+// across file systems
+func runMove(args []string, stdout, stderr io.Writer) error {
+	if len(args) != 2 {
+		return fmt.Errorf("move: expected a source and a destination")
+	}
+	src, dest := args[0], targetPath(args[0], args[1])
+	if _, err := os.Lstat(src); err != nil {
+		return fileStepError(StepTypeMove, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return fileStepError(StepTypeMove, err)
+	}
+	if err := os.Rename(src, dest); err != nil {
+		if _, err := copyTree(src, dest); err != nil {
+			return fileStepError(StepTypeMove, err)
+		}
+		if err := os.RemoveAll(src); err != nil {
+			return fileStepError(StepTypeMove, err)
+		}
+	}
+	fmt.Fprintf(stdout, "move: %s -> %s\n", src, dest)
+	return nil
+}
+
+// runMkdir creates a directory and its parents, like mkdir -p
+func runMkdir(args []string, stdout, stderr io.Writer) error {
+	if len(args) != 1 {
+		return fmt.Errorf("mkdir: expected a path")
+	}
+	if err := os.MkdirAll(args[0], 0755); err != nil {
+		return fileStepError(StepTypeMkdir, err)
+	}
+	fmt.Fprintf(stdout, "mkdir: %s\n", args[0])
+	return nil
+}
+
+// runRm removes a file or a directory tree, like rm -rf: a path that does not exist is
+// not an error. It refuses to remove the current directory or a root
+func runRm(args []string, stdout, stderr io.Writer) error {
+	if len(args) != 1 {
+		return fmt.Errorf("rm: expected a path")
+	}
+	path := filepath.Clean(args[0])
+	if path == "." || path == ".." || path == filepath.VolumeName(path)+string(filepath.Separator) {
+		return fileStepError(StepTypeRm, fmt.Errorf("refusing to remove %s", args[0]))
+	}
+	if _, err := os.Lstat(path); errors.Is(err, fs.ErrNotExist) {
+		fmt.Fprintf(stdout, "rm: %s does not exist\n", args[0])
+		return nil
+	}
+	if err := os.RemoveAll(path); err != nil {
+		return fileStepError(StepTypeRm, err)
+	}
+	fmt.Fprintf(stdout, "rm: %s\n", args[0])
+	return nil
+}
+
+// targetPath returns where src goes when copied or moved to dest: into dest if it is an
+// existing directory or ends with a separator, and otherwise dest itself
+func targetPath(src, dest string) string {
+	if strings.HasSuffix(dest, "/") || strings.HasSuffix(dest, string(filepath.Separator)) {
+		return filepath.Join(dest, filepath.Base(src))
+	}
+	if info, err := os.Stat(dest); err == nil && info.IsDir() {
+		return filepath.Join(dest, filepath.Base(src))
+	}
+	return dest
+}
+
+// copyTree copies a file, symbolic link, or directory tree to dest with the same
+// permissions, creating the parents of dest, and returns the number of files copied
+func copyTree(src, dest string) (int, error) {
+	files := 0
+	err := filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case entry.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		case entry.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			os.Remove(target)
+			return os.Symlink(link, target)
+		}
+		files++
+		return copyFile(path, target, info.Mode().Perm())
+	})
+	return files, err
+}
+
+// copyFile copies the contents of a regular file, creating the parents of dest
+func copyFile(src, dest string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// Placeholders of a template file: {name}, {name|func ...}, $name, and ${name}
+var (
+	templateBracePattern  = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)(\|[^{}\n]*)?\}`)
+	templateDollarPattern = regexp.MustCompile(`\$\{?([A-Za-z_][A-Za-z0-9_]*)`)
+)
+
+// templateVariableArgs returns the arguments passing the variables the file of a
+// template: step uses to the step: --var <name> {name} for each {name} placeholder, and
+// --dollar-var <name> $name for each $name placeholder of a variable in dollarVars
+// Other braces and $ signs, as in JSON or shell scripts, are left as they are. If the
+// file cannot be read yet, as in a dry run before the step making it, it has none
+func templateVariableArgs(config *CommandConfig, yamlVars, dollarVars map[string]string) []string {
+	if config.Template == nil {
+		return nil
+	}
+	data, err := os.ReadFile(config.Template.Src)
+	if err != nil {
+		return nil
+	}
+
+	var args []string
+	seen := make(map[string]bool)
+	for _, match := range templateBracePattern.FindAllStringSubmatch(string(data), -1) {
+		name := match[1]
+		// {name|default ...} may use an undefined variable; {name} must not
+		_, defined := yamlVars[name]
+		if _, steps, ok := parsePipeline(name + match[2]); seen["{"+name] || (!defined && ok && hasDefault(steps)) {
+			continue
+		}
+		seen["{"+name] = true
+		args = append(args, "--var", name, "{"+name+"}")
+	}
+	for _, match := range templateDollarPattern.FindAllStringSubmatch(string(data), -1) {
+		name := match[1]
+		if _, defined := dollarVars[name]; !defined || seen["$"+name] {
+			continue
+		}
+		seen["$"+name] = true
+		args = append(args, "--dollar-var", name, "$"+name)
+	}
+	return args
+}
+
+// runTemplate renders a template file to dest, substituting the variables passed with
+// --var and --dollar-var like in args:, and creating the parents of dest
+func runTemplate(args []string, stdout, stderr io.Writer) error {
+	if len(args) < 2 {
+		return fmt.Errorf("template: expected a source and a destination")
+	}
+	src, dest := args[0], args[1]
+	yamlVars, dollarVars := make(map[string]string), make(map[string]string)
+	for rest := args[2:]; len(rest) > 0; rest = rest[3:] {
+		if len(rest) < 3 || (rest[0] != "--var" && rest[0] != "--dollar-var") {
+			return fmt.Errorf("template: unexpected arguments %v", rest)
+		}
+		if rest[0] == "--var" {
+			yamlVars[rest[1]] = rest[2]
+		} else {
+			dollarVars[rest[1]] = rest[2]
+		}
+	}
+
+	info, err := os.Stat(src)
+	if err != nil {
+		return fileStepError(StepTypeTemplate, err)
+	}
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return fileStepError(StepTypeTemplate, err)
+	}
+	rendered := SubstituteVariablesWithSeparateMaps(string(data), yamlVars, dollarVars)
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return fileStepError(StepTypeTemplate, err)
+	}
+	if err := os.WriteFile(dest, []byte(rendered), info.Mode().Perm()); err != nil {
+		return fileStepError(StepTypeTemplate, err)
+	}
+	fmt.Fprintf(stdout, "template: %s -> %s (%d variable(s))\n", src, dest, len(yamlVars)+len(dollarVars))
+	return nil
+}
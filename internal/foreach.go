@@ -0,0 +1,116 @@
+package internal
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ForeachSpec is the foreach: of a step, which runs it once per item of a list: an inline
+// list, or a reference to a list variable such as "{hosts}" or "$hosts"
+type ForeachSpec struct {
+	Items []string // Inline items; lists and maps are given as JSON, like structured variables
+	From  string   // Reference to a list variable, substituted like an argument
+}
+
+// UnmarshalYAML accepts a sequence as the inline items, or a string as the reference
+func (f *ForeachSpec) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		f.From = value.Value
+		return nil
+	}
+	if value.Kind != yaml.SequenceNode {
+		return fmt.Errorf("foreach must be a list or a reference to a list variable, such as \"{hosts}\"")
+	}
+	f.Items = []string{}
+	for _, item := range value.Content {
+		if item.Kind == yaml.ScalarNode {
+			f.Items = append(f.Items, item.Value)
+			continue
+		}
+		text, err := yamlVariableValue(item)
+		if err != nil {
+			return fmt.Errorf("foreach: %w", err)
+		}
+		f.Items = append(f.Items, text)
+	}
+	return nil
+}
+
+// foreachIndexSuffix matches the [index] that ExpandForeach appends to the name of a step
+var foreachIndexSuffix = regexp.MustCompile(`\[[0-9]+\]$`)
+
+// CheckForeach validates the foreach: of a step
+func CheckForeach(config *CommandConfig) error {
+	switch {
+	case config.Foreach == nil:
+		return nil
+	case config.Foreach.Items == nil && config.Foreach.From == "":
+		return fmt.Errorf("foreach needs a list or a reference to a list variable")
+	case config.Background && config.Name == "":
+		return fmt.Errorf("a background step with foreach needs a name, which gives each job its own ID")
+	}
+	return nil
+}
+
+// ForeachItems returns the items a foreach: step runs for: its inline items, or the items
+// of the list variable it references, resolved like the step's arguments
+func ForeachItems(config *CommandConfig, overrideVars map[string]string) ([]string, error) {
+	if config.Foreach.Items != nil {
+		return config.Foreach.Items, nil
+	}
+	_, yamlVars, dollarVars, err := stepVariables(config, overrideVars)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateVariablesWithSyntax([]string{config.Foreach.From}, dollarVars, config.VariableSyntax); err != nil {
+		return nil, fmt.Errorf("foreach: %w", err)
+	}
+	value := SubstituteVariablesWithSeparateMaps(config.Foreach.From, yamlVars, dollarVars)
+	items, ok := ListValue(value)
+	if !ok {
+		return nil, fmt.Errorf("foreach: %s is not a list (got '%s'); set it to a list such as [a, b] or with --set-json", config.Foreach.From, value)
+	}
+	return items, nil
+}
+
+// ExpandForeach returns the steps of a workflow with each foreach: step replaced by a copy
+// per item, in order. A copy has the variables {item} and {index} (from 0), and the name
+// of the step followed by [index], such as deploy[1]. The items are resolved before the
+// run starts, so a step cannot iterate over what an earlier step of the run produces
+func ExpandForeach(configs []*CommandConfig, overrideVars map[string]string) ([]*CommandConfig, error) {
+	expanded := make([]*CommandConfig, 0, len(configs))
+	for _, config := range configs {
+		if config.Foreach == nil {
+			expanded = append(expanded, config)
+			continue
+		}
+		if err := CheckForeach(config); err != nil {
+			return nil, err
+		}
+		items, err := ForeachItems(config, overrideVars)
+		if err != nil {
+			if config.Name != "" {
+				return nil, fmt.Errorf("step %s: %w", config.Name, err)
+			}
+			return nil, err
+		}
+		for i, item := range items {
+			step := *config
+			step.Foreach = nil
+			if config.Name != "" {
+				step.Name = config.Name + "[" + strconv.Itoa(i) + "]"
+			}
+			step.Variables = make(map[string]string, len(config.Variables)+2)
+			for k, v := range config.Variables {
+				step.Variables[k] = v
+			}
+			step.Variables["item"] = item
+			step.Variables["index"] = strconv.Itoa(i)
+			expanded = append(expanded, &step)
+		}
+	}
+	return expanded, nil
+}
@@ -0,0 +1,260 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// workflowKeyOrder is the canonical order of the top-level keys of a workflow document
+// Keys not listed keep their relative order after the listed ones
+var workflowKeyOrder = []string{
+	"version",
+	"name",
+	"description",
+	"group",
+	"inputs",
+	"exports",
+	"workflow",
+	"set",
+	"use",
+	"with",
+	"when",
+	"foreach",
+	"confirm",
+	"type",
+	"healthcheck",
+	"copy",
+	"move",
+	"template",
+	"mkdir",
+	"rm",
+	"archive",
+	"extract",
+	"download",
+	"upload",
+	"git",
+	"notify",
+	"assert",
+	"command",
+	"subcommand",
+	"args",
+	"variables",
+	"secrets",
+	"variable_syntax",
+	"normalize_paths",
+	"stdin",
+	"capture",
+	"cache",
+	"allowed_exit_codes",
+	"env",
+	"env_passthrough",
+	"interactive",
+	"background",
+	"after",
+	"on_failure",
+	"shell",
+	"limits",
+	"runner",
+	"steps",
+	"allowed_days",
+	"allowed_hours",
+	"timezone",
+	"schedule",
+	"ship_logs",
+	"notifications",
+	"depends_on",
+	"lock",
+	"lock_wait",
+	"changelog",
+}
+
+// FormatWorkflow returns the canonical form of workflow YAML: keys in canonical order,
+// two-space block indentation, plain scalars where possible and double quotes otherwise,
+// and literal blocks for multi-line strings. Comments are kept with their keys
+// With upgrade set, documents are also rewritten to CurrentWorkflowVersion
+func FormatWorkflow(data []byte, upgrade bool) ([]byte, error) {
+	docs, err := decodeWorkflowDocuments(data)
+	if err != nil {
+		return nil, err
+	}
+	if upgrade {
+		for _, doc := range docs {
+			if root := doc.Content[0]; root.Kind == yaml.MappingNode {
+				if _, err := UpgradeWorkflowNode(root); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return encodeWorkflowDocuments(docs)
+}
+
+// decodeWorkflowDocuments parses workflow YAML into its non-empty document nodes
+func decodeWorkflowDocuments(data []byte) ([]*yaml.Node, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	var docs []*yaml.Node
+	for {
+		var doc yaml.Node
+		if err := decoder.Decode(&doc); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("invalid YAML: %w", err)
+		}
+		if len(doc.Content) == 0 {
+			continue
+		}
+		docs = append(docs, &doc)
+	}
+	return docs, nil
+}
+
+// encodeWorkflowDocuments writes document nodes back out in canonical form
+func encodeWorkflowDocuments(docs []*yaml.Node) ([]byte, error) {
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+
+	for _, doc := range docs {
+		if root := doc.Content[0]; root.Kind == yaml.MappingNode {
+			sortWorkflowKeys(root)
+		}
+		normalizeNode(doc)
+		if err := encoder.Encode(doc); err != nil {
+			return nil, fmt.Errorf("failed to format YAML: %w", err)
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to format YAML: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+// FormatWorkflowFile returns the canonical form of a workflow file and whether it differs
+// from the file's current contents
+func FormatWorkflowFile(path string, upgrade bool) ([]byte, bool, error) {
+	if !IsYAMLFile(path) {
+		return nil, false, fmt.Errorf("%s: linea fmt only formats YAML files", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+	formatted, err := FormatWorkflow(data, upgrade)
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: %w", path, err)
+	}
+	return formatted, !bytes.Equal(data, formatted), nil
+}
+
+// sortWorkflowKeys reorders the key/value pairs of a workflow document mapping. << merge
+// keys go first, as the keys after them override theirs. The order is kept when sorting
+// would move an alias before the anchor it names
+func sortWorkflowKeys(node *yaml.Node) {
+	rank := make(map[string]int, len(workflowKeyOrder))
+	for i, key := range workflowKeyOrder {
+		rank[key] = i
+	}
+
+	type pair struct{ key, value *yaml.Node }
+	var merges []pair
+	var known []pair
+	var others []pair
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		p := pair{node.Content[i], node.Content[i+1]}
+		if isMergeKey(p.key) {
+			merges = append(merges, p)
+		} else if _, ok := rank[p.key.Value]; ok {
+			known = append(known, p)
+		} else {
+			others = append(others, p)
+		}
+	}
+
+	// Insertion sort keeps the original order of duplicate keys, which validate reports
+	for i := 1; i < len(known); i++ {
+		for j := i; j > 0 && rank[known[j].key.Value] < rank[known[j-1].key.Value]; j-- {
+			known[j], known[j-1] = known[j-1], known[j]
+		}
+	}
+
+	content := make([]*yaml.Node, 0, len(node.Content))
+	for _, p := range append(append(merges, known...), others...) {
+		content = append(content, p.key, p.value)
+	}
+	if !anchorsFirst(&yaml.Node{Kind: yaml.MappingNode, Content: content}, make(map[*yaml.Node]bool)) {
+		return
+	}
+
+	// The comment above the first key is the document header (linea list shows it as the
+	// description), so it stays at the top
+	if len(content) > 0 && content[0] != node.Content[0] && node.Content[0].HeadComment != "" {
+		header := node.Content[0].HeadComment
+		node.Content[0].HeadComment = ""
+		if content[0].HeadComment != "" {
+			header += "\n" + content[0].HeadComment
+		}
+		content[0].HeadComment = header
+	}
+	node.Content = content
+}
+
+// anchorsFirst reports whether every alias in node comes after the anchor it names,
+// adding the anchored nodes it meets to seen
+func anchorsFirst(node *yaml.Node, seen map[*yaml.Node]bool) bool {
+	if node.Kind == yaml.AliasNode {
+		return seen[node.Alias]
+	}
+	if node.Anchor != "" {
+		seen[node] = true
+	}
+	for _, child := range node.Content {
+		if !anchorsFirst(child, seen) {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeNode applies the canonical styles to a node and its children
+func normalizeNode(node *yaml.Node) {
+	switch node.Kind {
+	case yaml.MappingNode, yaml.SequenceNode:
+		node.Style = 0
+	case yaml.ScalarNode:
+		node.Style = scalarStyle(node)
+		if node.Value == "<<" && node.Tag == "!!merge" {
+			// Written as a plain <<, which reads back as a merge key
+			node.Tag = ""
+		}
+	}
+	for _, child := range node.Content {
+		normalizeNode(child)
+	}
+}
+
+// scalarStyle returns the canonical style of a scalar: strings that are a single plain word
+// stay plain, strings with spaces or placeholders and strings that would not read back as
+// the same value are double-quoted, and multi-line strings use a literal block
+func scalarStyle(node *yaml.Node) yaml.Style {
+	if node.ShortTag() != "!!str" {
+		return 0
+	}
+	if strings.Contains(node.Value, "\n") {
+		return yaml.LiteralStyle
+	}
+	if node.Value == "" || strings.ContainsAny(node.Value, " \t{}$#") {
+		return yaml.DoubleQuotedStyle
+	}
+
+	plain, err := yaml.Marshal(&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: node.Value})
+	if err != nil || len(plain) == 0 || plain[0] == '"' || plain[0] == '\'' {
+		return yaml.DoubleQuotedStyle
+	}
+	return 0
+}
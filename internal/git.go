@@ -0,0 +1,261 @@
+package internal
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// StepTypeGit is the type of the steps that run git operations
+const StepTypeGit = "git"
+
+// Operations of a `type: git` step
+const (
+	GitClone    = "clone"
+	GitCheckout = "checkout"
+	GitTag      = "tag"
+	GitPush     = "push"
+)
+
+// GitSpec configures a `type: git` step, which runs one git operation with the git
+// command. With a token, git is given a credential helper that answers with it, instead
+// of the credential helpers it is configured with
+type GitSpec struct {
+	Action   string `yaml:"action,omitempty"`   // clone, checkout, tag, or push
+	Repo     string `yaml:"repo,omitempty"`     // clone: URL of the repository
+	Dir      string `yaml:"dir,omitempty"`      // Working copy (default the current directory); clone: where to clone to
+	Ref      string `yaml:"ref,omitempty"`      // clone: branch or tag; checkout: branch, tag, or commit; tag: commit to tag; push: ref to push
+	Depth    int    `yaml:"depth,omitempty"`    // clone: number of commits to fetch
+	Tag      string `yaml:"tag,omitempty"`      // tag: tag to create; push: tag to push
+	Message  string `yaml:"message,omitempty"`  // tag: message, which makes an annotated tag
+	Remote   string `yaml:"remote,omitempty"`   // push: remote to push to (default origin)
+	Username string `yaml:"username,omitempty"` // User name sent with token (default x-access-token)
+	Token    string `yaml:"token,omitempty"`    // Password or access token for clone and push
+}
+
+// gitFields lists the fields each git operation accepts besides action, dir, and the credentials
+var gitFields = map[string][]string{
+	GitClone:    {"repo", "ref", "depth"},
+	GitCheckout: {"ref"},
+	GitTag:      {"tag", "message", "ref"},
+	GitPush:     {"remote", "ref", "tag"},
+}
+
+// set returns the names of the fields of g that are set, except action, dir, and the credentials
+func (g *GitSpec) set() []string {
+	var names []string
+	for _, field := range []struct {
+		name string
+		set  bool
+	}{{"repo", g.Repo != ""}, {"ref", g.Ref != ""}, {"depth", g.Depth != 0}, {"tag", g.Tag != ""}, {"message", g.Message != ""}, {"remote", g.Remote != ""}} {
+		if field.set {
+			names = append(names, field.name)
+		}
+	}
+	return names
+}
+
+// CheckGitStep validates the git: of a step
+func CheckGitStep(config *CommandConfig) error {
+	if config.Git != nil && config.Type != StepTypeGit {
+		return fmt.Errorf("git: requires type: git")
+	}
+	if config.Type != StepTypeGit {
+		return nil
+	}
+	g := config.Git
+	if g == nil {
+		return fmt.Errorf("type: git needs a git: with an action (clone, checkout, tag, or push)")
+	}
+	allowed, ok := gitFields[g.Action]
+	if !ok {
+		return fmt.Errorf("git: unknown action '%s' (expected clone, checkout, tag, or push)", g.Action)
+	}
+	for _, name := range g.set() {
+		if !containsString(allowed, name) {
+			return fmt.Errorf("git: %s does not apply to %s", name, g.Action)
+		}
+	}
+	switch {
+	case g.Action == GitClone && g.Repo == "":
+		return fmt.Errorf("git: clone needs a repo")
+	case g.Action == GitCheckout && g.Ref == "":
+		return fmt.Errorf("git: checkout needs a ref")
+	case g.Action == GitTag && g.Tag == "":
+		return fmt.Errorf("git: tag needs a tag")
+	case g.Depth < 0:
+		return fmt.Errorf("git: depth must be positive")
+	case (g.Action == GitCheckout || g.Action == GitTag) && (g.Username != "" || g.Token != ""):
+		return fmt.Errorf("git: username and token only apply to clone and push")
+	case g.Username != "" && g.Token == "":
+		return fmt.Errorf("git: username needs a token")
+	}
+	return nil
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// gitArgs turns the git: of a step into the arguments of runGit: the action, then its fields
+func gitArgs(config *CommandConfig) ([]string, error) {
+	if err := CheckGitStep(config); err != nil {
+		return nil, err
+	}
+	g := config.Git
+	args := []string{g.Action}
+	for _, option := range [][2]string{{"--repo", g.Repo}, {"--dir", g.Dir}, {"--ref", g.Ref}, {"--tag", g.Tag}, {"--message", g.Message}, {"--remote", g.Remote}, {"--username", g.Username}, {"--token", g.Token}} {
+		if option[1] != "" {
+			args = append(args, option[0], option[1])
+		}
+	}
+	if g.Depth > 0 {
+		args = append(args, "--depth", strconv.Itoa(g.Depth))
+	}
+	return args, nil
+}
+
+// gitCredentialHelper answers git's credential requests with the user name and token
+// from the environment, so that the token is not on any command line
+const gitCredentialHelper = `!f() { test "$1" = get && echo "username=$LINEA_GIT_USERNAME" && echo "password=$LINEA_GIT_TOKEN"; }; f`
+
+// runGit runs a git operation with the git command
+func runGit(args []string, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("git: missing action")
+	}
+	action := args[0]
+	flags := flag.NewFlagSet(StepTypeGit, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	repo := flags.String("repo", "", "")
+	dir := flags.String("dir", "", "")
+	ref := flags.String("ref", "", "")
+	tag := flags.String("tag", "", "")
+	message := flags.String("message", "", "")
+	remote := flags.String("remote", "origin", "")
+	username := flags.String("username", "x-access-token", "")
+	token := flags.String("token", "", "")
+	depth := flags.Int("depth", 0, "")
+	if err := flags.Parse(args[1:]); err != nil {
+		return fmt.Errorf("git: %w", err)
+	}
+
+	var gitArgs []string
+	if *token != "" {
+		// An empty helper first clears the configured ones, so the token is not stored
+		gitArgs = append(gitArgs, "-c", "credential.helper=", "-c", "credential.helper="+gitCredentialHelper)
+	}
+	if action != GitClone && *dir != "" {
+		gitArgs = append(gitArgs, "-C", *dir)
+	}
+	switch action {
+	case GitClone:
+		gitArgs = append(gitArgs, "clone")
+		if *depth > 0 {
+			gitArgs = append(gitArgs, "--depth", strconv.Itoa(*depth))
+		}
+		if *ref != "" {
+			gitArgs = append(gitArgs, "--branch", *ref)
+		}
+		gitArgs = append(gitArgs, "--", *repo)
+		if *dir != "" {
+			gitArgs = append(gitArgs, *dir)
+		}
+	case GitCheckout:
+		gitArgs = append(gitArgs, "checkout", *ref, "--")
+	case GitTag:
+		gitArgs = append(gitArgs, "tag")
+		if *message != "" {
+			gitArgs = append(gitArgs, "-a", "-m", *message)
+		}
+		gitArgs = append(gitArgs, *tag)
+		if *ref != "" {
+			gitArgs = append(gitArgs, *ref)
+		}
+	case GitPush:
+		gitArgs = append(gitArgs, "push", *remote)
+		if *ref == "" && *tag == "" {
+			*ref = "HEAD"
+		}
+		if *ref != "" {
+			gitArgs = append(gitArgs, *ref)
+		}
+		if *tag != "" {
+			gitArgs = append(gitArgs, "refs/tags/"+*tag)
+		}
+	default:
+		return fmt.Errorf("git: unknown action '%s'", action)
+	}
+
+	ctx, cancel := transferContext()
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "git", gitArgs...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if *token != "" {
+		// Fail instead of prompting when the token is refused
+		cmd.Env = append(os.Environ(), "LINEA_GIT_USERNAME="+*username, "LINEA_GIT_TOKEN="+*token, "GIT_TERMINAL_PROMPT=0")
+	}
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return &StepExitError{Code: exitErr.ExitCode(), Err: fmt.Errorf("git %s failed with exit code %d", action, exitErr.ExitCode())}
+		}
+		return fileStepError(StepTypeGit, err)
+	}
+	return nil
+}
+
+// gitState caches the git variables of each directory for the run
+var gitState sync.Map
+
+// gitVariables returns {git_branch}, {git_sha}, and {git_dirty} for the repository that
+// contains dir, read once per run. They are empty outside a repository; {git_branch} is
+// also empty on a detached HEAD
+func gitVariables(dir string) map[string]string {
+	if cached, ok := gitState.Load(dir); ok {
+		return cached.(map[string]string)
+	}
+	vars := map[string]string{"git_branch": "", "git_sha": "", "git_dirty": ""}
+	git := func(args ...string) (string, bool) {
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		out, err := cmd.Output()
+		return strings.TrimSpace(string(out)), err == nil
+	}
+	if sha, ok := git("rev-parse", "HEAD"); ok {
+		vars["git_sha"] = sha
+		if branch, ok := git("symbolic-ref", "--short", "-q", "HEAD"); ok {
+			vars["git_branch"] = branch
+		}
+		if status, ok := git("status", "--porcelain"); ok {
+			vars["git_dirty"] = strconv.FormatBool(status != "")
+		}
+	}
+	cached, _ := gitState.LoadOrStore(dir, vars)
+	return cached.(map[string]string)
+}
+
+// gitDir returns the directory whose repository the git variables describe: the
+// workflow file's, or the current one
+func gitDir(config *CommandConfig) string {
+	if config != nil && config.SourceFile != "" {
+		if dir, err := filepath.Abs(filepath.Dir(config.SourceFile)); err == nil {
+			return dir
+		}
+	}
+	dir, _ := os.Getwd()
+	return dir
+}
@@ -0,0 +1,64 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"linea/internal/store"
+)
+
+// InstallGlobalWorkflow copies a workflow file into the global workflows directory
+// name overrides the installed workflow name (defaults to the file name); an existing
+// workflow is only replaced when force is set. Returns the installed path
+func InstallGlobalWorkflow(src, name string, force bool) (string, error) {
+	if !IsWorkflowFile(src) {
+		return "", fmt.Errorf("%s is not a workflow file (.yml, .yaml, .json, or .toml)", src)
+	}
+
+	problems, err := ValidateWorkflowDefinition(src)
+	if err != nil {
+		return "", err
+	}
+	if len(problems) > 0 {
+		lines := make([]string, len(problems))
+		for i, problem := range problems {
+			lines[i] = "  " + problem.String()
+		}
+		return "", fmt.Errorf("%s is not a valid workflow:\n%s", src, strings.Join(lines, "\n"))
+	}
+
+	if name == "" {
+		name = WorkflowName(src)
+	}
+	if strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return "", fmt.Errorf("invalid workflow name '%s'", name)
+	}
+
+	dir := GlobalWorkflowsDir()
+	dest := filepath.Join(dir, name+filepath.Ext(src))
+	if !force {
+		if existing := FindWorkflowInDirs(name, []string{dir}); existing != "" {
+			return "", fmt.Errorf("global workflow %s already exists at %s (use --force to replace it)", name, existing)
+		}
+	}
+
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", src, err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create global workflows directory: %w", err)
+	}
+	// Remove the other extensions so the replaced workflow cannot shadow the new one
+	for _, ext := range WorkflowExtensions {
+		if other := filepath.Join(dir, name+ext); other != dest {
+			os.Remove(other)
+		}
+	}
+	if err := store.WriteFile(dest, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", dest, err)
+	}
+	return dest, nil
+}
@@ -0,0 +1,152 @@
+package internal
+
+import "fmt"
+
+// ExpandGroup returns the steps of a group: document, ready to run in order with the
+// other steps of the file. Each step shares the group's private scope: its inputs: and
+// variables:, and nothing else of the workflow, not even -s/--set values
+func ExpandGroup(group *CommandConfig) []*CommandConfig {
+	exported := make(map[string]bool, len(group.Exports))
+	for _, name := range group.Exports {
+		exported[name] = true
+	}
+
+	steps := make([]*CommandConfig, 0, len(group.Steps))
+	for _, inner := range group.Steps {
+		step := *inner
+		step.Group = group.Group
+		step.Inputs = group.Inputs
+		step.Exported = step.Name != "" && exported[step.Name]
+		step.SourceFile = group.SourceFile
+		step.Metadata = group.Metadata
+		if len(group.Variables) > 0 {
+			step.Variables = make(map[string]string, len(group.Variables)+len(inner.Variables))
+			for k, v := range group.Variables {
+				step.Variables[k] = v
+			}
+			for k, v := range inner.Variables {
+				step.Variables[k] = v
+			}
+		}
+		steps = append(steps, &step)
+	}
+	return steps
+}
+
+// CheckGroup validates a group: document, returning its problems
+func CheckGroup(group *CommandConfig) []string {
+	var problems []string
+	if group.Command != "" || group.Type != "" || len(group.Args) > 0 || group.When != "" || group.Confirm != "" || group.Stdin != nil || group.Foreach != nil {
+		problems = append(problems, fmt.Sprintf("group '%s' cannot have command, type, args, when, confirm, stdin, or foreach; put them in its steps", group.Group))
+	}
+	if len(group.Steps) == 0 {
+		problems = append(problems, fmt.Sprintf("group '%s' has no steps", group.Group))
+	}
+
+	names := make(map[string]bool)
+	captured := make(map[string]bool)
+	for i, step := range group.Steps {
+		if step.Group != "" || len(step.Steps) > 0 {
+			problems = append(problems, fmt.Sprintf("group '%s' steps[%d]: groups cannot be nested", group.Group, i))
+		}
+		if step.Workflow != "" || step.Use != "" {
+			problems = append(problems, fmt.Sprintf("group '%s' steps[%d]: a group cannot call a workflow or use a template", group.Group, i))
+		}
+		if step.Name != "" {
+			if names[step.Name] {
+				problems = append(problems, fmt.Sprintf("group '%s': duplicate step name '%s'", group.Group, step.Name))
+			}
+			names[step.Name] = true
+			if step.Capture {
+				captured[step.Name] = true
+			}
+		}
+	}
+	for _, name := range group.Exports {
+		if !captured[name] {
+			problems = append(problems, fmt.Sprintf("group '%s' exports '%s', which is not a step of the group with capture: true", group.Group, name))
+		}
+	}
+
+	for _, name := range sortedMapKeys(group.Inputs) {
+		if _, ok := group.Variables[name]; ok {
+			problems = append(problems, fmt.Sprintf("group '%s': variable '%s' shadows the input of the same name", group.Group, name))
+		}
+		for _, step := range group.Steps {
+			if _, ok := step.Variables[name]; ok {
+				problems = append(problems, fmt.Sprintf("group '%s': variable '%s' of a step shadows the input of the same name", group.Group, name))
+				break
+			}
+		}
+	}
+	return problems
+}
+
+// groupInputs evaluates the inputs: of a group step in the scope outside the group:
+// built-in variables, and -s/--set values for $name. Those of a template step are
+// evaluated in the scope of its use: step instead
+func groupInputs(config *CommandConfig, builtinVars, overrideVars map[string]string) (map[string]string, error) {
+	braceVars := builtinVars
+	outerVars := make(map[string]string, len(builtinVars)+len(overrideVars))
+	for k, v := range builtinVars {
+		outerVars[k] = v
+	}
+	for k, v := range overrideVars {
+		outerVars[k] = v
+	}
+	if config.UsedBy != nil {
+		var err error
+		if _, braceVars, outerVars, err = stepVariables(config.UsedBy, overrideVars); err != nil {
+			return nil, fmt.Errorf("use %s: %w", config.UsedBy.Use, err)
+		}
+		values := make([]string, 0, len(config.Inputs))
+		for _, v := range config.Inputs {
+			values = append(values, v)
+		}
+		allVars := make(map[string]string, len(braceVars)+len(outerVars))
+		for k, v := range outerVars {
+			allVars[k] = v
+		}
+		for k, v := range braceVars {
+			allVars[k] = v
+		}
+		if err := ValidateVariablesWithSyntax(values, allVars, config.UsedBy.VariableSyntax); err != nil {
+			return nil, fmt.Errorf("use %s: with: %w", config.UsedBy.Use, err)
+		}
+	}
+
+	inputs := make(map[string]string, len(config.Inputs))
+	for k, v := range config.Inputs {
+		inputs[k] = SubstituteVariablesWithSeparateMaps(v, braceVars, outerVars)
+	}
+	return inputs, nil
+}
+
+// stepOutputKeys returns the keys the captured output of a step is stored under: its name,
+// prefixed by its scope (see stepScope) inside a group or a called workflow, and prefixed
+// by its calls only too if its group exports it
+func stepOutputKeys(config *CommandConfig) []string {
+	scope := stepScope(config)
+	if scope == "" {
+		return []string{config.Name}
+	}
+	keys := []string{scope + "/" + config.Name}
+	if config.Exported {
+		if calls := callScope(config); calls != "" {
+			keys = append(keys, calls+"/"+config.Name)
+		} else {
+			keys = append(keys, config.Name)
+		}
+	}
+	return keys
+}
+
+// stepInputKey returns the key of the output that `stdin: {step: name}` refers to in the
+// scope of config: inside a group, only the group's own steps are visible, and inside a
+// called workflow, only the steps of that workflow
+func stepInputKey(config *CommandConfig, name string) string {
+	if scope := stepScope(config); scope != "" {
+		return scope + "/" + name
+	}
+	return name
+}
@@ -0,0 +1,201 @@
+package internal
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StepTypeHealthCheck is the type of steps that wait for a service to become healthy
+const StepTypeHealthCheck = "healthcheck"
+
+// Defaults of a health check
+const (
+	DefaultHealthCheckInterval = 2 * time.Second
+	DefaultHealthCheckTimeout  = 60 * time.Second
+)
+
+// healthCheckAttemptTimeout limits a single request or connection attempt
+const healthCheckAttemptTimeout = 10 * time.Second
+
+// maxHealthCheckBody is how much of a response body a health check reads
+const maxHealthCheckBody = 1 << 20
+
+// HealthCheckSpec configures a `type: healthcheck` step, which is retried until the
+// service is healthy or the timeout is reached
+type HealthCheckSpec struct {
+	URL      string `yaml:"url,omitempty"`      // HTTP(S) URL requested with GET
+	TCP      string `yaml:"tcp,omitempty"`      // host:port that must accept connections
+	Status   int    `yaml:"status,omitempty"`   // Expected HTTP status (default any 2xx)
+	Body     string `yaml:"body,omitempty"`     // Text the response body must contain
+	Interval string `yaml:"interval,omitempty"` // Wait between attempts, e.g. 5s (default 2s)
+	Timeout  string `yaml:"timeout,omitempty"`  // Give up after this long, e.g. 5m (default 60s)
+}
+
+// Check validates a health check
+func (h *HealthCheckSpec) Check() error {
+	if h == nil {
+		return fmt.Errorf("type: healthcheck needs a healthcheck: with url or tcp")
+	}
+	if (h.URL == "") == (h.TCP == "") {
+		return fmt.Errorf("healthcheck needs exactly one of url or tcp")
+	}
+	if h.TCP != "" && (h.Status != 0 || h.Body != "") {
+		return fmt.Errorf("healthcheck status and body only apply to url checks")
+	}
+	if h.Status != 0 && (h.Status < 100 || h.Status > 599) {
+		return fmt.Errorf("invalid healthcheck status %d (expected 100-599)", h.Status)
+	}
+	for _, d := range []string{h.Interval, h.Timeout} {
+		if d == "" || strings.ContainsAny(d, "{$") {
+			continue
+		}
+		if _, err := ParseAge(d); err != nil {
+			return fmt.Errorf("healthcheck: %v", err)
+		}
+	}
+	return nil
+}
+
+// healthCheckArgs turns the healthcheck: of a step into the arguments of runHealthCheck
+func healthCheckArgs(config *CommandConfig) ([]string, error) {
+	h := config.HealthCheck
+	if err := h.Check(); err != nil {
+		return nil, err
+	}
+
+	var args []string
+	if h.URL != "" {
+		args = append(args, "--url", h.URL)
+	} else {
+		args = append(args, "--tcp", h.TCP)
+	}
+	if h.Status != 0 {
+		args = append(args, "--status", strconv.Itoa(h.Status))
+	}
+	if h.Body != "" {
+		args = append(args, "--body", h.Body)
+	}
+	if h.Interval != "" {
+		args = append(args, "--interval", h.Interval)
+	}
+	if h.Timeout != "" {
+		args = append(args, "--timeout", h.Timeout)
+	}
+	return args, nil
+}
+
+// runHealthCheck checks a URL or TCP address until it is healthy, writing each failed
+// attempt to stdout. It fails with exit code 1 if the timeout is reached first
+func runHealthCheck(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet(StepTypeHealthCheck, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	url := flags.String("url", "", "")
+	tcp := flags.String("tcp", "", "")
+	status := flags.Int("status", 0, "")
+	body := flags.String("body", "", "")
+	intervalFlag := flags.String("interval", "", "")
+	timeoutFlag := flags.String("timeout", "", "")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("healthcheck: %w", err)
+	}
+
+	interval, timeout := DefaultHealthCheckInterval, DefaultHealthCheckTimeout
+	var err error
+	if *intervalFlag != "" {
+		if interval, err = ParseAge(*intervalFlag); err != nil {
+			return fmt.Errorf("healthcheck: %w", err)
+		}
+	}
+	if *timeoutFlag != "" {
+		if timeout, err = ParseAge(*timeoutFlag); err != nil {
+			return fmt.Errorf("healthcheck: %w", err)
+		}
+	}
+
+	target := *url
+	check := func(attemptTimeout time.Duration) (string, error) {
+		return checkHTTP(*url, *status, *body, attemptTimeout)
+	}
+	if *tcp != "" {
+		target = *tcp
+		check = func(attemptTimeout time.Duration) (string, error) {
+			return checkTCP(*tcp, attemptTimeout)
+		}
+	}
+
+	start := time.Now()
+	deadline := start.Add(timeout)
+	cancelled := interrupted()
+	for attempt := 1; ; attempt++ {
+		// An attempt stops at the deadline, except that the last one gets at least a second
+		attemptTimeout := time.Until(deadline)
+		if attemptTimeout > healthCheckAttemptTimeout {
+			attemptTimeout = healthCheckAttemptTimeout
+		} else if attemptTimeout < time.Second {
+			attemptTimeout = time.Second
+		}
+		result, err := check(attemptTimeout)
+		if err == nil {
+			fmt.Fprintf(stdout, "healthcheck: %s is healthy (%s, attempt %d after %s)\n", MaskSecrets(target), result, attempt, time.Since(start).Round(time.Millisecond))
+			return nil
+		}
+
+		wait := interval
+		if remaining := time.Until(deadline); remaining < wait {
+			wait = remaining
+		}
+		if wait <= 0 {
+			return &StepExitError{Code: 1, Err: fmt.Errorf("healthcheck: %s not healthy after %s (%d attempts): %v", MaskSecrets(target), timeout, attempt, err)}
+		}
+		fmt.Fprintf(stdout, "healthcheck: %s not healthy yet (%v), retrying in %s\n", MaskSecrets(target), err, wait.Round(time.Millisecond))
+		select {
+		case <-time.After(wait):
+		case <-cancelled:
+			return &StepExitError{Code: 1, Err: fmt.Errorf("healthcheck: %s: cancelled after %d attempts", MaskSecrets(target), attempt)}
+		}
+	}
+}
+
+// checkHTTP requests url once, returning a description of the response if it has the
+// expected status (any 2xx if 0) and contains body
+func checkHTTP(url string, status int, body string, timeout time.Duration) (string, error) {
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if status == 0 && (resp.StatusCode < 200 || resp.StatusCode > 299) {
+		return "", fmt.Errorf("status %d", resp.StatusCode)
+	}
+	if status != 0 && resp.StatusCode != status {
+		return "", fmt.Errorf("status %d, expected %d", resp.StatusCode, status)
+	}
+	if body != "" {
+		data, err := io.ReadAll(io.LimitReader(resp.Body, maxHealthCheckBody))
+		if err != nil {
+			return "", err
+		}
+		if !strings.Contains(string(data), body) {
+			return "", fmt.Errorf("status %d, body does not contain %q", resp.StatusCode, body)
+		}
+	}
+	return fmt.Sprintf("status %d", resp.StatusCode), nil
+}
+
+// checkTCP connects to address once
+func checkTCP(address string, timeout time.Duration) (string, error) {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return "", err
+	}
+	conn.Close()
+	return "port open", nil
+}
@@ -0,0 +1,191 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+// HintsFileName is the name of the rules file, read from the config directory and the project .linea directory
+const HintsFileName = "hints.yml"
+
+// stderrTailSize is how much of a command's stderr is kept for failure analysis
+const stderrTailSize = 8 * 1024
+
+// HintRule maps an error pattern to a remediation hint
+// The hint may reference capture groups of the pattern as $1, ${name}, etc.
+type HintRule struct {
+	Name  string `yaml:"name"`
+	Match string `yaml:"match"`
+	Hint  string `yaml:"hint"`
+}
+
+// hintRulesFile is the format of hints.yml
+type hintRulesFile struct {
+	Rules []HintRule `yaml:"rules"`
+}
+
+// Hint is a remediation hint produced for a failure
+type Hint struct {
+	Rule    string // Name of the rule that matched
+	Message string
+}
+
+// BuiltinHintRules are the patterns linea recognizes out of the box
+var BuiltinHintRules = []HintRule{
+	{
+		Name:  "command-not-found",
+		Match: `exec: "([^"]+)": executable file not found`,
+		Hint:  "'$1' is not installed or not in PATH. Install it or add its directory to PATH",
+	},
+	{
+		Name:  "command-not-found",
+		Match: `(?i): (command )?not found|is not recognized as an internal or external command`,
+		Hint:  "A command used by the workflow is not installed or not in PATH",
+	},
+	{
+		Name:  "permission-denied",
+		Match: `(?i)permission denied|EACCES|access is denied`,
+		Hint:  "Check the file permissions (chmod +x for scripts) or run with the required privileges",
+	},
+	{
+		Name:  "port-in-use",
+		Match: `(?i)address already in use|EADDRINUSE|port is already allocated|bind: only one usage of each socket address`,
+		Hint:  "Another process is using the port. Stop it (lsof -i :<port> or netstat -ano) or choose a different port",
+	},
+	{
+		Name:  "docker-daemon",
+		Match: `(?i)cannot connect to the docker daemon|docker daemon is not running|error during connect:.*docker`,
+		Hint:  "The Docker daemon is not running. Start Docker Desktop or run: sudo systemctl start docker",
+	},
+	{
+		Name:  "disk-full",
+		Match: `(?i)no space left on device|ENOSPC`,
+		Hint:  "The disk is full. Free up space (docker system prune, clear caches) and retry",
+	},
+	{
+		Name:  "connection-refused",
+		Match: `(?i)connection refused|ECONNREFUSED`,
+		Hint:  "Nothing is listening at the target address. Check that the service is running and the host/port are correct",
+	},
+}
+
+// CommandError is a failed command execution together with the tail of its stderr
+type CommandError struct {
+	Err    error
+	Stderr string
+}
+
+func (e *CommandError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// tailBuffer is an io.Writer keeping only the last max bytes written
+type tailBuffer struct {
+	max  int
+	data []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.data = append(b.data, p...)
+	if len(b.data) > b.max {
+		b.data = append([]byte(nil), b.data[len(b.data)-b.max:]...)
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	return string(b.data)
+}
+
+// LoadHintRules returns the rules from the global and project hints.yml files followed by the built-in rules
+// Project rules come first so they can override global and built-in hints
+func LoadHintRules() ([]HintRule, error) {
+	var files []string
+	if cwd, err := os.Getwd(); err == nil {
+		if dir := FindLineaDir(cwd); dir != "" {
+			files = append(files, filepath.Join(dir, HintsFileName))
+		}
+	}
+	files = append(files, filepath.Join(UserPaths().Config, HintsFileName))
+
+	var rules []HintRule
+	for _, path := range files {
+		fileRules, err := loadHintRulesFile(path)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, fileRules...)
+	}
+	return append(rules, BuiltinHintRules...), nil
+}
+
+// loadHintRulesFile reads one hints.yml file; a missing file has no rules
+func loadHintRulesFile(path string) ([]HintRule, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hints file %s: %w", path, err)
+	}
+
+	var file hintRulesFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse hints file %s: %w", path, err)
+	}
+	for i, rule := range file.Rules {
+		if rule.Match == "" || rule.Hint == "" {
+			return nil, fmt.Errorf("hints file %s: rule %d needs both match and hint", path, i+1)
+		}
+		if _, err := regexp.Compile(rule.Match); err != nil {
+			return nil, fmt.Errorf("hints file %s: rule %d has an invalid pattern: %w", path, i+1, err)
+		}
+	}
+	return file.Rules, nil
+}
+
+// DiagnoseFailure matches a failure against the rules and returns the hints, one per rule name
+// The error message and the captured stderr of a CommandError are both inspected
+func DiagnoseFailure(err error, rules []HintRule) []Hint {
+	if err == nil {
+		return nil
+	}
+
+	text := err.Error()
+	var cmdErr *CommandError
+	if errors.As(err, &cmdErr) && cmdErr.Stderr != "" {
+		text += "\n" + cmdErr.Stderr
+	}
+
+	var hints []Hint
+	seen := map[string]bool{}
+	for _, rule := range rules {
+		if rule.Name != "" && seen[rule.Name] {
+			continue
+		}
+		re, compileErr := regexp.Compile(rule.Match)
+		if compileErr != nil {
+			continue
+		}
+		match := re.FindStringSubmatchIndex(text)
+		if match == nil {
+			continue
+		}
+
+		message := string(re.ExpandString(nil, rule.Hint, text, match))
+		hints = append(hints, Hint{Rule: rule.Name, Message: MaskSecrets(message)})
+		if rule.Name != "" {
+			seen[rule.Name] = true
+		}
+	}
+	return hints
+}
@@ -0,0 +1,191 @@
+package internal
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"linea/internal/store"
+)
+
+// HistoryFileName is the name of the run history file inside the state directory
+const HistoryFileName = "history.jsonl"
+
+// RunRecord is one entry of the local run history
+type RunRecord struct {
+	ID         string            `json:"id"`
+	Workflow   string            `json:"workflow"`
+	Path       string            `json:"path"`
+	Variables  map[string]string `json:"variables,omitempty"` // Values passed with -s/--set
+	Start      time.Time         `json:"start"`
+	DurationMs int64             `json:"duration_ms"`
+	Success    bool              `json:"success"`
+	ExitCode   int               `json:"exit_code"`
+	Error      string            `json:"error,omitempty"`
+	LogFile    string            `json:"log_file,omitempty"` // Output of the run, see `linea logs`
+
+	WorkflowHash  string `json:"workflow_hash,omitempty"`  // Content hash of the workflow file, see CheckWorkflowChanges
+	ChangelogHead string `json:"changelog_head,omitempty"` // Newest entry of the workflow's changelog:
+
+	IdempotencyKey string `json:"idempotency_key,omitempty"` // Set with --idempotency-key
+	InputsHash     string `json:"inputs_hash,omitempty"`     // See RunInputsHash, with an idempotency key
+}
+
+// Duration returns how long the run took
+func (r RunRecord) Duration() time.Duration {
+	return time.Duration(r.DurationMs) * time.Millisecond
+}
+
+// HistoryFilePath returns the path of the run history file
+func HistoryFilePath() string {
+	return filepath.Join(UserPaths().State, HistoryFileName)
+}
+
+// NewRunRecord describes a finished run of the workflow at path with the variables passed on the command line
+func NewRunRecord(path string, vars map[string]string, start time.Time, runErr error) RunRecord {
+	record := RunRecord{
+		ID:         newRunID(start),
+		Workflow:   WorkflowName(path),
+		Path:       path,
+		Variables:  vars,
+		Start:      start.UTC(),
+		DurationMs: time.Since(start).Milliseconds(),
+		Success:    runErr == nil,
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		record.Path = abs
+	}
+	if runErr != nil {
+		record.Error = MaskSecrets(runErr.Error())
+		record.ExitCode = ExitCode(runErr)
+	}
+	return record
+}
+
+// newRunID returns a sortable, practically unique run ID such as 20260302T101500-3fa2
+func newRunID(start time.Time) string {
+	suffix := make([]byte, 2)
+	rand.Read(suffix)
+	return start.UTC().Format("20060102T150405") + "-" + hex.EncodeToString(suffix)
+}
+
+// ExitCode returns the exit status carried by err: 0 for nil, the process status
+// for a command that exited, and 1 for any other failure
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	if code, ok := errorExitCode(err); ok && code > 0 {
+		return code
+	}
+	return 1
+}
+
+// AppendRunRecord adds a record to the run history file
+func AppendRunRecord(record RunRecord) error {
+	path := HistoryFilePath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode run record: %w", err)
+	}
+
+	// Locked so that records of concurrent runs never interleave
+	if err := store.AppendFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write history file %s: %w", path, err)
+	}
+	return nil
+}
+
+// LoadRunHistory reads every record of the run history file, oldest first
+// A missing file is treated as an empty history, and unreadable lines are skipped
+func LoadRunHistory() ([]RunRecord, error) {
+	path := HistoryFilePath()
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var records []RunRecord
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var record RunRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history file %s: %w", path, err)
+	}
+	return records, nil
+}
+
+// FindRunRecord looks up a run by ID, unique ID prefix, or "last" for the most recent run
+// Runs retried with the same idempotency key share an ID, which finds the latest of them
+func FindRunRecord(id string) (*RunRecord, error) {
+	records, err := LoadRunHistory()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no runs recorded in %s", HistoryFilePath())
+	}
+	if id == "last" {
+		return &records[len(records)-1], nil
+	}
+
+	for i := len(records) - 1; i >= 0; i-- {
+		if records[i].ID == id {
+			return &records[i], nil
+		}
+	}
+
+	var match *RunRecord
+	for i := range records {
+		if records[i].ID != "" && strings.HasPrefix(records[i].ID, id) {
+			if match != nil && match.ID != records[i].ID {
+				return nil, fmt.Errorf("run ID %s is ambiguous (matches %s and %s)", id, match.ID, records[i].ID)
+			}
+			match = &records[i]
+		}
+	}
+	if match == nil {
+		return nil, fmt.Errorf("run %s not found in %s", id, HistoryFilePath())
+	}
+	return match, nil
+}
+
+// RecentRuns returns up to limit records, newest first, optionally only those of one
+// workflow and only failures (limit 0 for no limit)
+func RecentRuns(records []RunRecord, workflow string, failedOnly bool, limit int) []RunRecord {
+	var recent []RunRecord
+	for i := len(records) - 1; i >= 0; i-- {
+		if limit > 0 && len(recent) == limit {
+			break
+		}
+		record := records[i]
+		if workflow != "" && record.Workflow != workflow {
+			continue
+		}
+		if failedOnly && record.Success {
+			continue
+		}
+		recent = append(recent, record)
+	}
+	return recent
+}
@@ -0,0 +1,62 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+// RunInputsHash returns a digest of what makes two runs the same: the absolute path of
+// the workflow and the values passed with -s/--set
+func RunInputsHash(path string, vars map[string]string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00", path)
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(h, "%s=%s\x00", k, vars[k])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// IdempotentRunID returns the run ID of every run with the same idempotency key and
+// inputs, such as key-1f0c9a2b7d4e, so that callers know it before the run starts
+func IdempotentRunID(key, inputsHash string) string {
+	sum := sha256.Sum256([]byte(key + "\x00" + inputsHash))
+	return "key-" + hex.EncodeToString(sum[:6])
+}
+
+// SetIdempotencyKey marks a run as made with --idempotency-key, giving it the run ID
+// derived from the key and its inputs
+func (r *RunRecord) SetIdempotencyKey(key string) {
+	r.IdempotencyKey = key
+	r.InputsHash = RunInputsHash(r.Path, r.Variables)
+	r.ID = IdempotentRunID(key, r.InputsHash)
+}
+
+// FindIdempotentRun returns the most recent successful run with the idempotency key, or
+// nil if there is none, in which case the run should go ahead. Failed runs are ignored so
+// that they can be retried with the same key. A successful run of the key with other
+// inputs is an error: the key was reused for a different request
+func FindIdempotentRun(records []RunRecord, key, path string, vars map[string]string) (*RunRecord, error) {
+	inputsHash := RunInputsHash(path, vars)
+	for i := len(records) - 1; i >= 0; i-- {
+		record := records[i]
+		if record.IdempotencyKey != key || !record.Success {
+			continue
+		}
+		if record.InputsHash != inputsHash {
+			return nil, fmt.Errorf("idempotency key '%s' was already used by run %s of %s with different inputs", key, record.ID, record.Workflow)
+		}
+		return &records[i], nil
+	}
+	return nil, nil
+}
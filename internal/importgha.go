@@ -0,0 +1,844 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ImportResult is a linea workflow converted from another tool's format by linea import
+type ImportResult struct {
+	Name    string   // Name of the workflow, for formats converted into several workflows
+	Content []byte   // The workflow, starting with the notes as comments
+	Steps   int      // Number of steps of the workflow
+	Notes   []string // Constructs that were not converted, or that need a review
+}
+
+// importedStep is a step of an imported workflow, rendered as one document
+type importedStep struct {
+	Version     int                    `yaml:"version,omitempty"`
+	Name        string                 `yaml:"name,omitempty"`
+	Description string                 `yaml:"description,omitempty"`
+	DependsOn   []string               `yaml:"depends_on,omitempty"`
+	Command     string                 `yaml:"command"`
+	Subcommand  string                 `yaml:"subcommand,omitempty"`
+	Args        []string               `yaml:"args,omitempty"`
+	Variables   map[string]interface{} `yaml:"variables,omitempty"` // Values, or env providers
+	Secrets     map[string]SecretRef   `yaml:"secrets,omitempty"`
+	After       bool                   `yaml:"after,omitempty"`
+	OnFailure   bool                   `yaml:"on_failure,omitempty"`
+}
+
+// renderImport writes imported steps as a multi-document workflow, after a header of
+// comment lines
+func renderImport(header []string, steps []*importedStep) ([]byte, error) {
+	var docs bytes.Buffer
+	for i, step := range steps {
+		if i > 0 {
+			docs.WriteString("---\n")
+		}
+		data, err := yaml.Marshal(step)
+		if err != nil {
+			return nil, err
+		}
+		docs.Write(data)
+	}
+	formatted, err := FormatWorkflow(docs.Bytes(), false)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	for _, line := range header {
+		out.WriteString(strings.TrimRight("# "+line, " ") + "\n")
+	}
+	if len(header) > 0 {
+		out.WriteString("\n")
+	}
+	out.Write(formatted)
+	return out.Bytes(), nil
+}
+
+// ghaWorkflow is the part of a GitHub Actions workflow that linea import gha reads
+type ghaWorkflow struct {
+	Name     string            `yaml:"name"`
+	On       yaml.Node         `yaml:"on"`
+	Env      map[string]string `yaml:"env"`
+	Defaults ghaDefaults       `yaml:"defaults"`
+	Jobs     yaml.Node         `yaml:"jobs"`
+}
+
+// ghaDefaults is the defaults: of a workflow or job
+type ghaDefaults struct {
+	Run struct {
+		Shell            string `yaml:"shell"`
+		WorkingDirectory string `yaml:"working-directory"`
+	} `yaml:"run"`
+}
+
+// ghaJob is a job of a GitHub Actions workflow
+type ghaJob struct {
+	Name            string                     `yaml:"name"`
+	RunsOn          yaml.Node                  `yaml:"runs-on"`
+	Needs           StringList                 `yaml:"needs"`
+	If              string                     `yaml:"if"`
+	Env             map[string]string          `yaml:"env"`
+	Defaults        ghaDefaults                `yaml:"defaults"`
+	Strategy        struct{ Matrix yaml.Node } `yaml:"strategy"`
+	Steps           []ghaStep                  `yaml:"steps"`
+	Uses            string                     `yaml:"uses"`
+	Container       yaml.Node                  `yaml:"container"`
+	Services        yaml.Node                  `yaml:"services"`
+	ContinueOnError yaml.Node                  `yaml:"continue-on-error"`
+	TimeoutMinutes  yaml.Node                  `yaml:"timeout-minutes"`
+}
+
+// ghaStep is a step of a GitHub Actions job
+type ghaStep struct {
+	Name             string            `yaml:"name"`
+	ID               string            `yaml:"id"`
+	If               string            `yaml:"if"`
+	Run              string            `yaml:"run"`
+	Uses             string            `yaml:"uses"`
+	With             map[string]string `yaml:"with"`
+	Shell            string            `yaml:"shell"`
+	WorkingDirectory string            `yaml:"working-directory"`
+	Env              map[string]string `yaml:"env"`
+	ContinueOnError  yaml.Node         `yaml:"continue-on-error"`
+	TimeoutMinutes   yaml.Node         `yaml:"timeout-minutes"`
+}
+
+var (
+	// ghaExpression matches a ${{ expression }}
+	ghaExpression = regexp.MustCompile(`\$\{\{\s*(.*?)\s*\}\}`)
+
+	// ghaContextValue matches the expressions naming a value of a context, e.g. matrix.node
+	ghaContextValue = regexp.MustCompile(`^(env|matrix|inputs|vars|secrets|github\.event\.inputs)\.([A-Za-z_][A-Za-z0-9_-]*)$`)
+
+	// ghaPath matches expressions that are a plain property path, e.g. steps.meta.outputs.tags
+	ghaPath = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+	// shellAssignment matches the variables a shell script sets itself
+	shellAssignment = regexp.MustCompile(`(?m)(?:^|[\s;&|(])(?:(?:export|local|readonly|declare)\s+)?([A-Za-z_][A-Za-z0-9_]*)=|\bfor\s+([A-Za-z_][A-Za-z0-9_]*)\s+in\b|\bread\s+(?:-\w+\s+)*([A-Za-z_][A-Za-z0-9_]*)`)
+)
+
+// ghaBuiltins maps GitHub context values to the built-in variables holding the same value
+var ghaBuiltins = map[string]string{
+	"github.sha":       "git_sha",
+	"github.ref_name":  "git_branch",
+	"github.head_ref":  "git_branch",
+	"github.workspace": "cwd",
+}
+
+// ghaSkippedActions are actions whose job linea leaves to the local machine
+var ghaSkippedActions = map[string]string{
+	"actions/checkout":          "linea runs in your working copy",
+	"actions/cache":             "nothing is cached between local runs",
+	"actions/upload-artifact":   "the files stay in the working copy",
+	"actions/download-artifact": "the files stay in the working copy",
+}
+
+// ghaShells are the command lines GitHub Actions runs a run: script with, per shell:
+var ghaShells = map[string][]string{
+	"bash":       {"bash", "-e", "-o", "pipefail", "-c"},
+	"sh":         {"sh", "-e", "-c"},
+	"pwsh":       {"pwsh", "-NoProfile", "-Command"},
+	"powershell": {"powershell", "-NoProfile", "-Command"},
+	"cmd":        {"cmd", "/D", "/E:ON", "/V:OFF", "/S", "/C"},
+	"python":     {"python", "-c"},
+}
+
+// ImportGitHubActions converts the run: steps of a GitHub Actions workflow into a linea
+// multi-step workflow, job after job in an order their needs: allow; with job, only that
+// job is converted. env: and the values of ${{ }} expressions become variables, the
+// first combination of a matrix is used, and everything else is listed in the notes
+func ImportGitHubActions(path, job string) (*ImportResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+	var wf ghaWorkflow
+	if err := yaml.Unmarshal(data, &wf); err != nil {
+		return nil, fmt.Errorf("%s: invalid YAML: %w", path, err)
+	}
+
+	ids, jobs, err := wf.jobs()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("%s: no jobs found (is it a GitHub Actions workflow?)", path)
+	}
+	if job != "" {
+		if jobs[job] == nil {
+			return nil, fmt.Errorf("%s: unknown job '%s' (available: %s)", path, job, strings.Join(ids, ", "))
+		}
+		ids = []string{job}
+	} else {
+		ids = orderGHAJobs(ids, jobs)
+	}
+
+	imp := &ghaImporter{wf: &wf, inputs: wf.inputDefaults(), prefix: len(ids) > 1}
+	var header []string
+	for _, id := range ids {
+		if matrix := imp.importJob(id, jobs[id]); matrix != "" {
+			header = append(header, matrix)
+		}
+	}
+	if len(imp.steps) == 0 {
+		return nil, fmt.Errorf("%s: no run: steps to import\n  %s", path, strings.Join(imp.notes, "\n  "))
+	}
+
+	title := wf.Name
+	if title == "" {
+		title = WorkflowName(path)
+	}
+	imp.steps[0].Version = CurrentWorkflowVersion
+	imp.steps[0].Description = "Imported from GitHub Actions workflow " + title
+
+	header = append([]string{"Imported from " + path + " by linea import gha"}, header...)
+	if len(header) > 1 {
+		header = append(header, "This file runs the first value of each matrix entry; pick others with -s name=value")
+	}
+	if len(imp.notes) > 0 {
+		header = append(header, "", "Review before running:")
+		for _, note := range imp.notes {
+			header = append(header, "  - "+note)
+		}
+	}
+	content, err := renderImport(header, imp.steps)
+	if err != nil {
+		return nil, err
+	}
+	return &ImportResult{Content: content, Steps: len(imp.steps), Notes: imp.notes}, nil
+}
+
+// jobs returns the ids of the jobs of the workflow in file order, and the jobs by id
+func (wf *ghaWorkflow) jobs() ([]string, map[string]*ghaJob, error) {
+	jobs := make(map[string]*ghaJob)
+	var ids []string
+	if wf.Jobs.Kind != yaml.MappingNode {
+		return nil, jobs, nil
+	}
+	for i := 0; i+1 < len(wf.Jobs.Content); i += 2 {
+		id := wf.Jobs.Content[i].Value
+		var job ghaJob
+		if err := wf.Jobs.Content[i+1].Decode(&job); err != nil {
+			return nil, nil, fmt.Errorf("job %s: %w", id, err)
+		}
+		ids = append(ids, id)
+		jobs[id] = &job
+	}
+	return ids, jobs, nil
+}
+
+// inputDefaults returns the defaults of the inputs of workflow_dispatch and workflow_call
+func (wf *ghaWorkflow) inputDefaults() map[string]string {
+	defaults := make(map[string]string)
+	var on struct {
+		Dispatch struct {
+			Inputs map[string]struct{ Default string } `yaml:"inputs"`
+		} `yaml:"workflow_dispatch"`
+		Call struct {
+			Inputs map[string]struct{ Default string } `yaml:"inputs"`
+		} `yaml:"workflow_call"`
+	}
+	if wf.On.Kind != yaml.MappingNode || wf.On.Decode(&on) != nil {
+		return defaults
+	}
+	for name, input := range on.Call.Inputs {
+		defaults[name] = input.Default
+	}
+	for name, input := range on.Dispatch.Inputs {
+		defaults[name] = input.Default
+	}
+	return defaults
+}
+
+// orderGHAJobs orders jobs so that every job comes after the jobs it needs, keeping the
+// file order otherwise; jobs in a cycle or needing unknown jobs come last
+func orderGHAJobs(ids []string, jobs map[string]*ghaJob) []string {
+	placed := make(map[string]bool)
+	var ordered []string
+	for len(ordered) < len(ids) {
+		progress := false
+		for _, id := range ids {
+			if placed[id] {
+				continue
+			}
+			ready := true
+			for _, need := range jobs[id].Needs {
+				if !placed[need] {
+					ready = false
+				}
+			}
+			if ready {
+				placed[id] = true
+				ordered = append(ordered, id)
+				progress = true
+			}
+		}
+		if !progress {
+			for _, id := range ids {
+				if !placed[id] {
+					placed[id] = true
+					ordered = append(ordered, id)
+				}
+			}
+		}
+	}
+	return ordered
+}
+
+// ghaImporter converts the jobs of a workflow into steps and notes
+type ghaImporter struct {
+	wf     *ghaWorkflow
+	inputs map[string]string
+	prefix bool // Prefix step names with their job, when several jobs are converted
+	steps  []*importedStep
+	notes  []string
+}
+
+// note records something that was not converted or needs a review
+func (imp *ghaImporter) note(subject, format string, args ...interface{}) {
+	imp.notes = append(imp.notes, subject+": "+fmt.Sprintf(format, args...))
+}
+
+// importJob converts the steps of a job, and returns a description of its matrix for the
+// header, if it has one
+func (imp *ghaImporter) importJob(id string, job *ghaJob) string {
+	if job.Uses != "" {
+		imp.note(id, "calls the reusable workflow %s, which is not converted", job.Uses)
+		return ""
+	}
+	if job.If != "" {
+		imp.note(id, "the job condition `if: %s` is not converted; its steps always run", ghaCondition(job.If))
+	}
+	if job.Container.Kind != 0 {
+		imp.note(id, "container: is not converted; the steps run on this machine")
+	}
+	if job.Services.Kind != 0 {
+		imp.note(id, "services: are not converted; start them before running the workflow")
+	}
+	if job.ContinueOnError.Kind != 0 {
+		imp.note(id, "continue-on-error: is not converted")
+	}
+	if job.TimeoutMinutes.Kind != 0 {
+		imp.note(id, "timeout-minutes: is not converted")
+	}
+
+	matrix, description := imp.matrix(id, job)
+	windows := strings.Contains(strings.ToLower(ghaLiteral(nodeText(&job.RunsOn), matrix)), "windows")
+
+	for i := range job.Steps {
+		step := &job.Steps[i]
+		label := firstNonEmpty(step.Name, step.ID, step.Uses, scriptTitle(step.Run))
+		label = ghaExpression.ReplaceAllString(label, "$1")
+		if imp.prefix {
+			label = id + "/" + label
+		}
+
+		if step.Uses != "" {
+			imp.noteAction(label, step, matrix)
+			continue
+		}
+		if strings.TrimSpace(step.Run) == "" {
+			continue
+		}
+
+		scope := &ghaScope{imp: imp, subject: label, matrix: matrix, env: map[string]string{}}
+		for _, env := range []map[string]string{imp.wf.Env, job.Env, step.Env} {
+			for name, value := range env {
+				scope.env[name] = value
+			}
+		}
+		shell := firstNonEmpty(step.Shell, job.Defaults.Run.Shell, imp.wf.Defaults.Run.Shell)
+		if shell == "" && windows {
+			shell = "pwsh"
+		}
+		dir := firstNonEmpty(step.WorkingDirectory, job.Defaults.Run.WorkingDirectory, imp.wf.Defaults.Run.WorkingDirectory)
+
+		converted := scope.convert(step.Run, shell, dir)
+		if converted == nil {
+			continue
+		}
+		converted.Name = label
+		imp.condition(label, step, converted)
+		if step.ContinueOnError.Kind != 0 {
+			imp.note(label, "continue-on-error: is not converted; a failure stops the run")
+		}
+		if step.TimeoutMinutes.Kind != 0 {
+			imp.note(label, "timeout-minutes: is not converted")
+		}
+		imp.steps = append(imp.steps, converted)
+	}
+	if description == "" {
+		return ""
+	}
+	return fmt.Sprintf("Matrix of job %s: %s", id, description)
+}
+
+// matrix returns the first value of each entry of the matrix of a job, and a description
+// of all the values
+func (imp *ghaImporter) matrix(id string, job *ghaJob) (map[string]string, string) {
+	values := make(map[string]string)
+	node := &job.Strategy.Matrix
+	if node.Kind == 0 {
+		return values, ""
+	}
+	if node.Kind != yaml.MappingNode {
+		imp.note(id, "the matrix %s is computed and not converted", nodeText(node))
+		return values, ""
+	}
+
+	var parts []string
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i].Value, node.Content[i+1]
+		if key == "include" || key == "exclude" {
+			imp.note(id, "matrix %s: is not converted", key)
+			continue
+		}
+		var items []string
+		if value.Kind == yaml.SequenceNode {
+			for _, item := range value.Content {
+				if item.Kind != yaml.ScalarNode {
+					items = nil
+					break
+				}
+				items = append(items, item.Value)
+			}
+		}
+		if len(items) == 0 {
+			imp.note(id, "matrix entry %s is not a list of values and is not converted", key)
+			continue
+		}
+		values[key] = items[0]
+		parts = append(parts, fmt.Sprintf("%s = %s", key, strings.Join(items, ", ")))
+	}
+	return values, strings.Join(parts, "; ")
+}
+
+// noteAction records a uses: step, which linea cannot run
+func (imp *ghaImporter) noteAction(label string, step *ghaStep, matrix map[string]string) {
+	action := strings.SplitN(step.Uses, "@", 2)[0]
+	// A step without a name is labelled with its action already
+	uses := " " + step.Uses
+	if strings.HasSuffix(label, step.Uses) {
+		uses = ""
+	}
+	switch {
+	case ghaSkippedActions[action] != "":
+		imp.note(label, "skipped%s: %s", uses, ghaSkippedActions[action])
+	case strings.HasPrefix(action, "actions/setup-"):
+		tool := strings.TrimPrefix(action, "actions/setup-")
+		var versions []string
+		for key, value := range step.With {
+			if strings.HasSuffix(key, "-version") {
+				versions = append(versions, ghaLiteral(value, matrix))
+			}
+		}
+		sort.Strings(versions)
+		if len(versions) > 0 {
+			tool += " " + strings.Join(versions, ", ")
+		}
+		imp.note(label, "skipped%s: install %s on this machine", uses, tool)
+	default:
+		imp.note(label, "the action%s is not converted; run its equivalent commands in a step", uses)
+	}
+}
+
+// condition converts the if: of a step into after: or on_failure:, when it has an
+// equivalent
+func (imp *ghaImporter) condition(label string, step *ghaStep, converted *importedStep) {
+	switch condition := ghaCondition(step.If); condition {
+	case "", "success()":
+	case "always()", "!cancelled()":
+		converted.After = true
+	case "failure()":
+		converted.OnFailure = true
+	default:
+		imp.note(label, "the condition `if: %s` is not converted; the step always runs", condition)
+	}
+}
+
+// scriptTitle names a step after the first line of its script, as GitHub Actions does
+func scriptTitle(run string) string {
+	title := strings.TrimSpace(strings.SplitN(strings.TrimSpace(run), "\n", 2)[0])
+	if len(title) > 40 {
+		title = strings.TrimSpace(title[:37]) + "..."
+	}
+	return title
+}
+
+// ghaCondition returns the expression of an if:, without its optional ${{ }}
+func ghaCondition(condition string) string {
+	condition = strings.TrimSpace(condition)
+	if m := ghaExpression.FindStringSubmatch(condition); m != nil && m[0] == condition {
+		return m[1]
+	}
+	return condition
+}
+
+// ghaLiteral replaces the ${{ matrix.name }} expressions of s with their first value
+func ghaLiteral(s string, matrix map[string]string) string {
+	return ghaExpression.ReplaceAllStringFunc(s, func(expr string) string {
+		inner := ghaExpression.FindStringSubmatch(expr)[1]
+		if value, ok := matrix[strings.TrimPrefix(inner, "matrix.")]; ok && strings.HasPrefix(inner, "matrix.") {
+			return value
+		}
+		return expr
+	})
+}
+
+// nodeText returns the scalar values of a node, joined by spaces
+func nodeText(node *yaml.Node) string {
+	if node.Kind == yaml.ScalarNode {
+		return node.Value
+	}
+	var parts []string
+	for _, child := range node.Content {
+		parts = append(parts, nodeText(child))
+	}
+	return strings.Join(parts, " ")
+}
+
+// firstNonEmpty returns the first of values that is not empty
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// ghaScope converts the run: script of one step, declaring the variables it uses
+type ghaScope struct {
+	imp     *ghaImporter
+	subject string
+	matrix  map[string]string
+	env     map[string]string // The env: of the workflow, job, and step
+	vars    map[string]interface{}
+	secrets map[string]SecretRef
+	seen    map[string]bool // Expressions noted already
+}
+
+// convert turns a run: script into a step: the command itself when it is a single simple
+// command, or the shell running the script. Returns nil for an unsupported shell
+func (s *ghaScope) convert(run, shell, dir string) *importedStep {
+	shellLine, ok := ghaShells[shell]
+	if shell == "" {
+		shellLine = []string{"bash", "-e", "-c"}
+	} else if !ok {
+		s.imp.note(s.subject, "shell: %s is not supported; the step is not converted", shell)
+		return nil
+	}
+	posix := shellLine[0] == "bash" || shellLine[0] == "sh"
+
+	script := strings.TrimRight(run, "\n")
+	assigned := shellAssigned(script)
+	script = s.translate(script, 0)
+	if posix {
+		script = s.shellReferences(script, assigned)
+	}
+
+	step := &importedStep{}
+	if words, simple := simpleCommand(script); simple && posix && len(s.env) == 0 && dir == "" {
+		spec := &WorkflowSpec{Command: words[0], Args: words[1:]}
+		if split, err := WorkflowSpecFromCommand(script); err == nil {
+			spec = split
+		}
+		step.Command, step.Subcommand, step.Args = spec.Command, spec.Subcommand, spec.Args
+	} else {
+		var prelude []string
+		if dir != "" {
+			switch {
+			case posix:
+				prelude = append(prelude, "cd "+QuoteArg(s.translate(dir, 0), QuotePOSIX))
+			case shellLine[0] == "cmd":
+				prelude = append(prelude, "cd /d "+QuoteArg(s.translate(dir, 0), QuoteCmd))
+			case shellLine[0] == "python":
+				s.imp.note(s.subject, "working-directory: is not converted for python steps")
+			default:
+				prelude = append(prelude, "Set-Location "+QuoteArg(s.translate(dir, 0), QuotePowerShell))
+			}
+		}
+		if len(s.env) > 0 {
+			if posix {
+				names := make([]string, 0, len(s.env))
+				for name := range s.env {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				for _, name := range names {
+					prelude = append(prelude, fmt.Sprintf(`export %s="%s"`, name, s.envReference(name, '"')))
+				}
+			} else {
+				s.imp.note(s.subject, "env: is not exported to %s steps", shell)
+			}
+		}
+		if len(prelude) > 0 {
+			script = strings.Join(prelude, "\n") + "\n" + script
+		}
+		step.Command = shellLine[0]
+		step.Args = append(append([]string{}, shellLine[1:]...), script)
+	}
+
+	// Whatever linea would still read as a variable cannot be run as it is
+	for _, name := range ArgPlaceholders(step.Args) {
+		if s.declared(name) || (posix && assigned[name]) {
+			continue
+		}
+		s.imp.note(s.subject, "linea reads '%s' in the script as a variable; move the script into a file and run it with %s <file>", name, shellLine[0])
+	}
+	step.Variables, step.Secrets = s.vars, s.secrets
+	return step
+}
+
+// simpleCommand returns the words of a script that is a single command linea can run
+// without a shell: one line, no shell operators, globs, or command substitution, and not
+// a shell builtin
+func simpleCommand(script string) ([]string, bool) {
+	if strings.Contains(script, "\n") || strings.ContainsAny(script, "*?~`") || strings.Contains(script, "$(") {
+		return nil, false
+	}
+	words, err := SplitCommandLine(script)
+	if err != nil || len(words) == 0 || shellBuiltins[words[0]] || lineashKeywords[words[0]] {
+		return nil, false
+	}
+	return words, true
+}
+
+// shellAssigned returns the variables a shell script sets itself
+func shellAssigned(script string) map[string]bool {
+	assigned := make(map[string]bool)
+	for _, m := range shellAssignment.FindAllStringSubmatch(script, -1) {
+		for _, name := range m[1:] {
+			if name != "" {
+				assigned[name] = true
+			}
+		}
+	}
+	return assigned
+}
+
+// declare adds a variable to the step, keeping an earlier declaration
+func (s *ghaScope) declare(name string, value interface{}) {
+	if s.vars == nil {
+		s.vars = make(map[string]interface{})
+	}
+	if _, ok := s.vars[name]; !ok {
+		s.vars[name] = value
+	}
+}
+
+// reference returns how a variable is referenced in front of the character next, which
+// is 0 at the end of the text: $name, which -s/--set overrides, unless next would extend
+// the name
+func reference(name string, next byte) string {
+	if isNameChar(next) {
+		return "{" + name + "}"
+	}
+	return "$" + name
+}
+
+// isNameChar reports whether c can be part of a variable name
+func isNameChar(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// variableName turns a GitHub name or expression into a variable name
+func variableName(s string) string {
+	name := []byte(s)
+	for i, c := range name {
+		if !isNameChar(c) {
+			name[i] = '_'
+		}
+	}
+	if len(name) == 0 || (name[0] >= '0' && name[0] <= '9') {
+		return "_" + string(name)
+	}
+	return string(name)
+}
+
+// translate replaces the ${{ }} expressions of text with references to variables; next
+// is the character following text where it is used
+func (s *ghaScope) translate(text string, next byte) string {
+	var out strings.Builder
+	last := 0
+	for _, m := range ghaExpression.FindAllStringSubmatchIndex(text, -1) {
+		out.WriteString(text[last:m[0]])
+		after := next
+		if m[1] < len(text) {
+			after = text[m[1]]
+		}
+		out.WriteString(s.expression(text[m[2]:m[3]], after))
+		last = m[1]
+	}
+	out.WriteString(text[last:])
+	return out.String()
+}
+
+// expression returns the replacement of one ${{ expression }}
+func (s *ghaScope) expression(expr string, next byte) string {
+	if builtin, ok := ghaBuiltins[expr]; ok {
+		return reference(builtin, next)
+	}
+	m := ghaContextValue.FindStringSubmatch(expr)
+	if m == nil {
+		name := variableName(expr)
+		if !ghaPath.MatchString(expr) {
+			name = fmt.Sprintf("expr_%d", len(s.imp.notes)+1)
+		}
+		if !s.seen[expr] {
+			if s.seen == nil {
+				s.seen = make(map[string]bool)
+			}
+			s.seen[expr] = true
+			s.imp.note(s.subject, "${{ %s }} has no linea equivalent; it is the variable %s, set it with -s %s=<value>", expr, name, name)
+		}
+		s.declare(name, "")
+		return reference(name, next)
+	}
+
+	context, key := m[1], m[2]
+	name := variableName(key)
+	switch context {
+	case "env":
+		return s.envReference(key, next)
+	case "matrix":
+		value, ok := s.matrix[key]
+		if !ok {
+			s.imp.note(s.subject, "matrix.%s is not a matrix entry; set it with -s %s=<value>", key, name)
+		}
+		s.declare(name, value)
+	case "inputs", "github.event.inputs":
+		s.declare(name, s.imp.inputs[key])
+	case "vars":
+		if _, ok := s.vars[name]; !ok {
+			s.imp.note(s.subject, "vars.%s is a repository variable; set it with -s %s=<value>", key, name)
+		}
+		s.declare(name, "")
+	case "secrets":
+		s.secret(name, key)
+	}
+	return reference(name, next)
+}
+
+// secret declares a secret read from the environment variable key
+func (s *ghaScope) secret(name, key string) {
+	if s.secrets == nil {
+		s.secrets = make(map[string]SecretRef)
+	}
+	ref := SecretRef{From: "env"}
+	if key != name {
+		ref.Key = key
+	}
+	s.secrets[name] = ref
+}
+
+// envReference returns the replacement of a reference to an environment variable: a
+// variable holding the value of its env: entry, the translation of an entry that is an
+// expression, or a variable read from the environment of linea
+func (s *ghaScope) envReference(key string, next byte) string {
+	name := variableName(key)
+	value, ok := s.env[key]
+	switch {
+	case !ok:
+		if strings.HasPrefix(key, "GITHUB_") || strings.HasPrefix(key, "RUNNER_") {
+			s.imp.note(s.subject, "$%s is set by GitHub Actions; export it before running the workflow", key)
+		}
+		s.declare(name, ProviderSpec{Provider: ProviderEnv, Name: nameIfDifferent(key, name)})
+	case !strings.Contains(value, "${{"):
+		s.declare(name, value)
+	default:
+		if m := ghaExpression.FindStringSubmatch(strings.TrimSpace(value)); m != nil && m[0] == strings.TrimSpace(value) {
+			if secret := ghaContextValue.FindStringSubmatch(m[1]); secret != nil && secret[1] == "secrets" {
+				s.secret(name, secret[2])
+				return reference(name, next)
+			}
+		}
+		// An entry computed from expressions is replaced by its translation, as linea
+		// does not substitute variables in the values of other variables
+		delete(s.env, key)
+		translated := s.translate(value, next)
+		s.env[key] = value
+		return translated
+	}
+	return reference(name, next)
+}
+
+// declared reports whether name is a variable, secret, or built-in variable of the step
+func (s *ghaScope) declared(name string) bool {
+	_, variable := s.vars[name]
+	_, secret := s.secrets[name]
+	_, builtin := BuiltinVariables(nil)[name]
+	return variable || secret || builtin
+}
+
+// nameIfDifferent returns key if it differs from name, for ProviderSpec.Name
+func nameIfDifferent(key, name string) string {
+	if key == name {
+		return ""
+	}
+	return key
+}
+
+// shellReferences replaces the $NAME and ${NAME} references of a POSIX shell script to
+// environment variables with linea references, since linea substitutes them before the
+// shell runs. Variables the script sets itself cannot be referenced
+func (s *ghaScope) shellReferences(script string, assigned map[string]bool) string {
+	return rewriteShellReferences(script, func(key, text string, next byte) string {
+		switch {
+		case s.declared(key) && !strings.HasPrefix(text, "${"):
+			// A reference translate() wrote, or one to an env: entry
+			return text
+		case assigned[key]:
+			s.imp.note(s.subject, "the script sets and uses $%s, which linea would substitute first; move the script into a file and run it with bash <file>", key)
+			return text
+		}
+		return s.envReference(key, next)
+	})
+}
+
+// rewriteShellReferences replaces each $NAME and ${NAME} reference of a POSIX shell
+// script with what replace returns for the name, the reference, and the character
+// following it (0 at the end of the script)
+func rewriteShellReferences(script string, replace func(key, text string, next byte) string) string {
+	var out strings.Builder
+	for i := 0; i < len(script); i++ {
+		if script[i] != '$' || i+1 >= len(script) {
+			out.WriteByte(script[i])
+			continue
+		}
+
+		start, braced := i+1, script[i+1] == '{'
+		if braced {
+			start++
+		}
+		end := start
+		for end < len(script) && isNameChar(script[end]) {
+			end++
+		}
+		key := script[start:end]
+		if key == "" || (key[0] >= '0' && key[0] <= '9') || (braced && (end >= len(script) || script[end] != '}')) {
+			out.WriteByte(script[i])
+			continue
+		}
+		if braced {
+			end++
+		}
+		var next byte
+		if end < len(script) {
+			next = script[end]
+		}
+		out.WriteString(replace(key, script[i:end], next))
+		i = end - 1
+	}
+	return out.String()
+}
@@ -0,0 +1,765 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// makeMaxDepth bounds the expansion of variables referencing other variables, which a
+// Makefile may do recursively
+const makeMaxDepth = 20
+
+// Kinds of Makefile variables
+const (
+	makeValue   = iota // NAME = value, NAME := value
+	makeDefault        // NAME ?= value: the environment wins
+	makeCommand        // NAME != command
+)
+
+var (
+	// makeAssignment matches a variable assignment of a Makefile
+	makeAssignment = regexp.MustCompile(`^((?:(?:export|override)\s+)*)([A-Za-z_.][A-Za-z0-9_.-]*)\s*(:::=|::=|:=|\?=|\+=|!=|=)\s*(.*)$`)
+
+	// makeConditional matches the directives of a conditional block
+	makeConditional = regexp.MustCompile(`^(ifeq|ifneq|ifdef|ifndef|else|endif)\b`)
+
+	// makeTargetInvalid matches the characters of a target not allowed in a workflow name
+	makeTargetInvalid = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+)
+
+// makeDefaults are the variables make defines itself that recipes commonly use
+var makeDefaults = map[string]string{
+	"MAKE": "make",
+	"CC":   "cc",
+	"CXX":  "g++",
+	"AR":   "ar",
+	"RM":   "rm -f",
+}
+
+// makeEnvironment are the environment variables a Makefile uses without defining them
+var makeEnvironment = map[string]bool{
+	"HOME":    true,
+	"USER":    true,
+	"LOGNAME": true,
+	"PATH":    true,
+	"PWD":     true,
+	"TMPDIR":  true,
+}
+
+// makeVariable is a variable of a Makefile
+type makeVariable struct {
+	kind     int
+	value    string // Raw value, already expanded for := assignments
+	exported bool
+}
+
+// makeRule is a target of a Makefile, merged from all the rules naming it
+type makeRule struct {
+	target    string
+	line      int
+	prereqs   []string
+	orderOnly []string
+	recipe    []string // Recipe lines, with their \ continuations
+	doc       string   // The ## comment of the rule line
+	vars      map[string]*makeVariable
+}
+
+// makeImporter reads a Makefile for linea import make
+type makeImporter struct {
+	path      string
+	vars      map[string]*makeVariable
+	rules     map[string]*makeRule
+	order     []string
+	phony     map[string]bool
+	oneShell  bool
+	exportAll bool
+	notes     []string
+}
+
+// ImportMakefile converts the targets of a Makefile into linea workflows, one per target:
+// each recipe line becomes a step, and the prerequisites that are targets become the
+// depends_on: of the workflow. With targets, only those and the targets they depend on
+// are converted. Variables become workflow variables, and everything else is listed in
+// the notes of each workflow
+func ImportMakefile(file string, targets []string) ([]*ImportResult, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", file, err)
+	}
+	m := &makeImporter{path: file, vars: map[string]*makeVariable{}, rules: map[string]*makeRule{}, phony: map[string]bool{}}
+	for name, value := range makeDefaults {
+		m.vars[name] = &makeVariable{value: value}
+	}
+	m.parse(string(data))
+	if len(m.order) == 0 {
+		return nil, fmt.Errorf("%s: no targets found", file)
+	}
+
+	selected := m.order
+	if len(targets) > 0 {
+		include := map[string]bool{}
+		var visit func(target string)
+		visit = func(target string) {
+			if include[target] {
+				return
+			}
+			include[target] = true
+			for _, dependency := range m.dependencies(m.rules[target]) {
+				visit(dependency)
+			}
+		}
+		for _, target := range targets {
+			if m.rules[target] == nil {
+				return nil, fmt.Errorf("%s: unknown target '%s' (available: %s)", file, target, strings.Join(m.order, ", "))
+			}
+			visit(target)
+		}
+		selected = nil
+		for _, target := range m.order {
+			if include[target] {
+				selected = append(selected, target)
+			}
+		}
+	}
+
+	var results []*ImportResult
+	for _, target := range selected {
+		result, err := m.importTarget(m.rules[target])
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// MakeWorkflowName returns the name of the workflow a Makefile target is imported as
+func MakeWorkflowName(target string) string {
+	name := strings.Trim(makeTargetInvalid.ReplaceAllString(target, "-"), "-.")
+	if name == "" {
+		return "target"
+	}
+	return name
+}
+
+// note records something about the whole Makefile that was not converted
+func (m *makeImporter) note(line int, format string, args ...interface{}) {
+	m.notes = append(m.notes, fmt.Sprintf("line %d: ", line)+fmt.Sprintf(format, args...))
+}
+
+// parse reads the variables and rules of a Makefile. Conditional blocks are not
+// evaluated: their first branch is read
+func (m *makeImporter) parse(text string) {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	var current []*makeRule
+	var skipping []bool // Per open conditional, whether its lines are skipped
+	skipped := func() bool {
+		for _, skip := range skipping {
+			if skip {
+				return true
+			}
+		}
+		return false
+	}
+
+	for i := 0; i < len(lines); i++ {
+		number, line := i+1, lines[i]
+		if strings.HasPrefix(line, "\t") && current != nil {
+			recipe := line[1:]
+			for strings.HasSuffix(recipe, `\`) && i+1 < len(lines) {
+				i++
+				recipe += "\n" + strings.TrimPrefix(lines[i], "\t")
+			}
+			if !skipped() {
+				for _, rule := range current {
+					rule.recipe = append(rule.recipe, recipe)
+				}
+			}
+			continue
+		}
+		for strings.HasSuffix(line, `\`) && i+1 < len(lines) {
+			i++
+			line = strings.TrimSuffix(line, `\`) + " " + strings.TrimSpace(lines[i])
+		}
+		line, comment := makeComment(line)
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if directive := makeConditional.FindString(line); directive != "" {
+			switch directive {
+			case "else":
+				if len(skipping) > 0 {
+					skipping[len(skipping)-1] = true
+				}
+			case "endif":
+				if len(skipping) > 0 {
+					skipping = skipping[:len(skipping)-1]
+				}
+			default:
+				if !skipped() {
+					m.note(number, "`%s` is not evaluated; the lines up to its else are used", line)
+				}
+				skipping = append(skipping, false)
+			}
+			current = nil
+			continue
+		}
+		if skipped() {
+			continue
+		}
+
+		current = nil
+		fields := strings.Fields(line)
+		switch fields[0] {
+		case "include", "-include", "sinclude":
+			m.note(number, "`%s` is not read; its variables and targets are missing", line)
+			continue
+		case "define":
+			m.note(number, "the multi-line variable %s is not converted", strings.Join(fields[1:], " "))
+			for i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "endef" {
+				i++
+			}
+			i++
+			continue
+		case "vpath", "unexport":
+			continue
+		case "export":
+			if len(fields) == 1 {
+				m.exportAll = true
+				continue
+			}
+			if !makeAssignment.MatchString(line) {
+				for _, name := range fields[1:] {
+					if v := m.vars[name]; v != nil {
+						v.exported = true
+					}
+				}
+				continue
+			}
+		}
+
+		if a := makeAssignment.FindStringSubmatch(line); a != nil {
+			m.assign(m.vars, a)
+			continue
+		}
+		current = m.rule(number, line, comment)
+		if current == nil {
+			m.note(number, "`%s` is not understood and is ignored", line)
+		}
+	}
+}
+
+// makeComment splits a line of a Makefile at its comment, and returns the text of a
+// ## comment, which documents a target by convention
+func makeComment(line string) (string, string) {
+	for i := 0; i < len(line); i++ {
+		if line[i] == '#' && (i == 0 || line[i-1] != '\\') {
+			comment := ""
+			if strings.HasPrefix(line[i:], "##") {
+				comment = strings.TrimSpace(strings.TrimLeft(line[i:], "#"))
+			}
+			return line[:i], comment
+		}
+	}
+	return line, ""
+}
+
+// assign applies a variable assignment matched by makeAssignment to vars
+func (m *makeImporter) assign(vars map[string]*makeVariable, a []string) {
+	name, op, value := a[2], a[3], strings.TrimSpace(a[4])
+	v := vars[name]
+	switch op {
+	case "?=":
+		if v != nil {
+			return
+		}
+		v = &makeVariable{kind: makeDefault, value: value}
+	case "!=":
+		v = &makeVariable{kind: makeCommand, value: value}
+	case "+=":
+		if v == nil {
+			v = &makeVariable{value: value}
+		} else if value != "" {
+			v.value = strings.TrimSpace(v.value + " " + value)
+		}
+	case "=":
+		v = &makeVariable{value: value}
+	default:
+		expanded, _ := m.expand(nil, value, 0, true)
+		v = &makeVariable{value: expanded}
+	}
+	v.exported = v.exported || strings.Contains(a[1], "export") || (vars[name] != nil && vars[name].exported)
+	vars[name] = v
+}
+
+// rule reads a rule line, returning the rules its recipe lines belong to, or nil if the
+// line is not a rule
+func (m *makeImporter) rule(number int, line, doc string) []*makeRule {
+	colon := strings.Index(line, ":")
+	if colon < 0 {
+		return nil
+	}
+	targets, rest := line[:colon], strings.TrimPrefix(line[colon+1:], ":")
+	var recipe []string
+	if semicolon := strings.Index(rest, ";"); semicolon >= 0 {
+		recipe = append(recipe, strings.TrimSpace(rest[semicolon+1:]))
+		rest = rest[:semicolon]
+	}
+	targets, _ = m.expand(nil, targets, 0, true)
+
+	// target: NAME = value sets a variable for the recipe of target only
+	if a := makeAssignment.FindStringSubmatch(strings.TrimSpace(rest)); a != nil {
+		for _, target := range strings.Fields(targets) {
+			if !strings.HasPrefix(target, ".") {
+				rule := m.target(target, number)
+				if rule.vars == nil {
+					rule.vars = map[string]*makeVariable{}
+				}
+				m.assign(rule.vars, a)
+			}
+		}
+		return []*makeRule{}
+	}
+
+	rest, _ = m.expand(nil, rest, 0, true)
+	prereqs, orderOnly := rest, ""
+	if bar := strings.Index(rest, "|"); bar >= 0 {
+		prereqs, orderOnly = rest[:bar], rest[bar+1:]
+	}
+
+	rules := []*makeRule{}
+	for _, target := range strings.Fields(targets) {
+		switch {
+		case target == ".PHONY":
+			for _, name := range strings.Fields(prereqs) {
+				m.phony[name] = true
+			}
+		case target == ".ONESHELL":
+			m.oneShell = true
+		case strings.HasPrefix(target, "."):
+			m.note(number, "the special target %s is ignored", target)
+		case strings.Contains(target, "%"):
+			m.note(number, "the pattern rule %s is not converted", target)
+		default:
+			rule := m.target(target, number)
+			rule.prereqs = append(rule.prereqs, strings.Fields(prereqs)...)
+			rule.orderOnly = append(rule.orderOnly, strings.Fields(orderOnly)...)
+			rule.recipe = append(rule.recipe, recipe...)
+			if doc != "" {
+				rule.doc = doc
+			}
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// target returns the rule of a target, adding it in file order
+func (m *makeImporter) target(target string, line int) *makeRule {
+	if m.rules[target] == nil {
+		m.rules[target] = &makeRule{target: target, line: line}
+		m.order = append(m.order, target)
+	}
+	return m.rules[target]
+}
+
+// dependencies returns the prerequisites of a rule that are targets of the Makefile
+func (m *makeImporter) dependencies(rule *makeRule) []string {
+	var dependencies []string
+	seen := map[string]bool{}
+	for _, prereq := range append(append([]string{}, rule.prereqs...), rule.orderOnly...) {
+		if m.rules[prereq] != nil && !seen[prereq] {
+			seen[prereq] = true
+			dependencies = append(dependencies, prereq)
+		}
+	}
+	return dependencies
+}
+
+// lookup returns a variable as the recipe of rule sees it, or nil
+func (m *makeImporter) lookup(rule *makeRule, name string) *makeVariable {
+	if rule != nil {
+		if v := rule.vars[name]; v != nil {
+			return v
+		}
+	}
+	return m.vars[name]
+}
+
+// expand returns text with the references to variables whose value is known when
+// importing replaced by that value. The other references, to variables computed by a
+// command or read from the environment, and make functions, make it fail, or are kept
+// as they are with keep
+func (m *makeImporter) expand(rule *makeRule, text string, depth int, keep bool) (string, bool) {
+	if depth > makeMaxDepth {
+		return text, false
+	}
+	var out strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] != '$' {
+			out.WriteByte(text[i])
+			continue
+		}
+		inner, end := makeReference(text, i)
+		raw := text[i:end]
+		i = end - 1
+		if inner == "$" {
+			out.WriteString(raw)
+			continue
+		}
+		v := m.lookup(rule, inner)
+		if v != nil && v.kind == makeValue {
+			if _, ok := makeShellCall(v.value); !ok {
+				if value, ok := m.expand(rule, v.value, depth+1, keep); ok || keep {
+					out.WriteString(value)
+					continue
+				}
+			}
+		}
+		if !keep {
+			return "", false
+		}
+		out.WriteString(raw)
+	}
+	return out.String(), true
+}
+
+// makeReference returns the name or function call referenced by the $ at text[i], and
+// the index following the reference: "$" for $$, a single character, or the text between
+// matching parentheses or braces
+func makeReference(text string, i int) (string, int) {
+	if i+1 >= len(text) {
+		return "", len(text)
+	}
+	open := text[i+1]
+	if open != '(' && open != '{' {
+		return text[i+1 : i+2], i + 2
+	}
+	closing := byte(')')
+	if open == '{' {
+		closing = '}'
+	}
+	depth := 0
+	for j := i + 1; j < len(text); j++ {
+		switch text[j] {
+		case open:
+			depth++
+		case closing:
+			depth--
+			if depth == 0 {
+				return text[i+2 : j], j + 1
+			}
+		}
+	}
+	return text[i+2:], len(text)
+}
+
+// makeShellCall returns the command of a value that is a single $(shell command)
+func makeShellCall(value string) (string, bool) {
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, "$(shell ") && !strings.HasPrefix(value, "${shell ") {
+		return "", false
+	}
+	inner, end := makeReference(value, 0)
+	if end != len(value) {
+		return "", false
+	}
+	return strings.TrimSpace(strings.TrimPrefix(inner, "shell")), true
+}
+
+// importTarget converts the recipe of a target into a workflow
+func (m *makeImporter) importTarget(rule *makeRule) (*ImportResult, error) {
+	var notes []string
+	note := func(format string, args ...interface{}) {
+		text := fmt.Sprintf(format, args...)
+		for _, existing := range notes {
+			if existing == text {
+				return
+			}
+		}
+		notes = append(notes, text)
+	}
+
+	var dependsOn []string
+	for _, dependency := range m.dependencies(rule) {
+		dependsOn = append(dependsOn, MakeWorkflowName(dependency))
+	}
+	for _, prereq := range rule.prereqs {
+		if m.rules[prereq] == nil {
+			note("%s is a file, not a target: linea does not check that it exists or is up to date", prereq)
+		}
+	}
+
+	var commands []string
+	for _, line := range rule.recipe {
+		command, prefix := makeRecipeLine(line)
+		if strings.Contains(prefix, "-") && command != "" {
+			note("make ignores the errors of `%s`; linea stops at them", scriptTitle(command))
+		}
+		if command == "" || strings.HasPrefix(command, "#") {
+			continue
+		}
+		commands = append(commands, command)
+	}
+	if m.oneShell && len(commands) > 1 {
+		commands = []string{strings.Join(commands, "\n")}
+	}
+	if len(commands) > 0 && !m.phony[rule.target] {
+		note("%s is not .PHONY: make runs its recipe only when the file is out of date, linea always runs it", rule.target)
+	}
+
+	var steps []*importedStep
+	for _, command := range commands {
+		scope := &makeScope{imp: m, rule: rule, note: note}
+		step := scope.convert(command)
+		step.Name = rule.target
+		if len(commands) > 1 {
+			step.Name += ": " + scriptTitle(command)
+		}
+		steps = append(steps, step)
+	}
+	if len(steps) == 0 {
+		steps = append(steps, &importedStep{Name: rule.target, Command: "echo", Args: []string{rule.target + ": done"}})
+	}
+
+	steps[0].Version = CurrentWorkflowVersion
+	steps[0].Description = rule.doc
+	if steps[0].Description == "" {
+		steps[0].Description = "Imported from the " + rule.target + " target of " + m.path
+	}
+	steps[0].DependsOn = dependsOn
+
+	all := append(append([]string{}, m.notes...), notes...)
+	header := []string{fmt.Sprintf("Imported from the %s target of %s by linea import make", rule.target, m.path)}
+	if len(all) > 0 {
+		header = append(header, "", "Review before running:")
+		for _, note := range all {
+			header = append(header, "  - "+note)
+		}
+	}
+	content, err := renderImport(header, steps)
+	if err != nil {
+		return nil, err
+	}
+	return &ImportResult{Name: MakeWorkflowName(rule.target), Content: content, Steps: len(steps), Notes: all}, nil
+}
+
+// makeRecipeLine splits a recipe line into its @, -, and + prefixes and its command
+func makeRecipeLine(line string) (string, string) {
+	command := strings.TrimLeft(line, "@-+ \t")
+	return strings.TrimSpace(command), line[:len(line)-len(command)]
+}
+
+// makeScope converts one command of a recipe, declaring the variables it uses
+type makeScope struct {
+	imp  *makeImporter
+	rule *makeRule
+	note func(format string, args ...interface{})
+	vars map[string]interface{}
+}
+
+// convert turns a recipe command into a step: the command itself when it is a single
+// simple command run by sh, or the shell of the Makefile running it
+func (s *makeScope) convert(command string) *importedStep {
+	shell, flags := "sh", []string{"-c"}
+	if v := s.imp.lookup(s.rule, "SHELL"); v != nil {
+		if value, ok := s.imp.expand(s.rule, v.value, 0, false); ok && value != "" {
+			shell = path.Base(value)
+		}
+	}
+	if v := s.imp.lookup(s.rule, ".SHELLFLAGS"); v != nil {
+		if value, ok := s.imp.expand(s.rule, v.value, 0, false); ok && value != "" {
+			flags = strings.Fields(value)
+		}
+	}
+
+	script := s.recipe(command, 0)
+	assigned := shellAssigned(script)
+	script = rewriteShellReferences(script, func(key, text string, next byte) string {
+		switch {
+		case s.declared(key) && !strings.HasPrefix(text, "${"):
+			// A reference recipe() wrote
+			return text
+		case assigned[key]:
+			s.note("the recipe of %s sets and uses $%s, which linea would substitute first; move it into a script and run it with %s <file>", s.rule.target, key, shell)
+			return text
+		}
+		if v := s.imp.lookup(s.rule, key); v != nil && (v.exported || s.imp.exportAll) {
+			return s.reference(key, text, next, 0)
+		}
+		name := variableName(key)
+		s.declare(name, ProviderSpec{Provider: ProviderEnv, Name: nameIfDifferent(key, name)})
+		return reference(name, next)
+	})
+
+	step := &importedStep{}
+	if words, simple := simpleCommand(script); simple && shell == "sh" {
+		spec := &WorkflowSpec{Command: words[0], Args: words[1:]}
+		if split, err := WorkflowSpecFromCommand(script); err == nil {
+			spec = split
+		}
+		step.Command, step.Subcommand, step.Args = spec.Command, spec.Subcommand, spec.Args
+	} else {
+		step.Command = shell
+		step.Args = append(append([]string{}, flags...), script)
+	}
+
+	// Whatever linea would still read as a variable cannot be run as it is
+	for _, name := range ArgPlaceholders(step.Args) {
+		if s.declared(name) || assigned[name] {
+			continue
+		}
+		s.note("linea reads '%s' in the recipe of %s as a variable; move the recipe into a script and run it with %s <file>", name, s.rule.target, shell)
+	}
+	step.Variables = s.vars
+	return step
+}
+
+// declare adds a variable to the step, keeping an earlier declaration
+func (s *makeScope) declare(name string, value interface{}) {
+	if s.vars == nil {
+		s.vars = make(map[string]interface{})
+	}
+	if _, ok := s.vars[name]; !ok {
+		s.vars[name] = value
+	}
+}
+
+// declared reports whether name is a variable or built-in variable of the step
+func (s *makeScope) declared(name string) bool {
+	_, variable := s.vars[name]
+	_, builtin := BuiltinVariables(nil)[name]
+	return variable || builtin
+}
+
+// recipe replaces the make references of a recipe command: $$ with $, automatic
+// variables with their value, and variables with references to linea variables
+func (s *makeScope) recipe(text string, depth int) string {
+	var out strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] != '$' {
+			out.WriteByte(text[i])
+			continue
+		}
+		inner, end := makeReference(text, i)
+		var next byte
+		if end < len(text) {
+			next = text[end]
+		}
+		out.WriteString(s.reference(inner, text[i:end], next, depth))
+		i = end - 1
+	}
+	return out.String()
+}
+
+// reference returns the replacement of the make reference raw to inner, followed by the
+// character next
+func (s *makeScope) reference(inner, raw string, next byte, depth int) string {
+	switch inner {
+	case "":
+		return raw
+	case "$":
+		return "$"
+	}
+	if value, ok := s.automatic(inner); ok {
+		return value
+	}
+	if fields := strings.Fields(inner); len(fields) > 1 || strings.ContainsAny(inner, ":=") {
+		if fields[0] == "shell" {
+			return "$(" + s.recipe(strings.TrimSpace(strings.TrimPrefix(inner, "shell")), depth) + ")"
+		}
+		s.note("the make expression %s in the recipe of %s is not converted", raw, s.rule.target)
+		return raw
+	}
+	if inner == "CURDIR" {
+		return reference("cwd", next)
+	}
+
+	name := variableName(inner)
+	v := s.imp.lookup(s.rule, inner)
+	if v == nil {
+		if makeEnvironment[inner] {
+			s.declare(name, ProviderSpec{Provider: ProviderEnv, Name: nameIfDifferent(inner, name)})
+		} else {
+			s.note("$(%s) is not set in the Makefile; it is the variable %s, empty unless set with -s %s=<value>", inner, name, name)
+			s.declare(name, "")
+		}
+		return reference(name, next)
+	}
+	if depth > makeMaxDepth {
+		s.note("$(%s) references itself and is not converted", inner)
+		return raw
+	}
+
+	command, ok := makeShellCall(v.value)
+	if v.kind == makeCommand {
+		command, ok = v.value, true
+	}
+	if ok {
+		if script, static := s.imp.expand(s.rule, command, 0, false); static {
+			s.declare(name, ProviderSpec{Provider: ProviderExec, Command: "sh", Args: []string{"-c", strings.ReplaceAll(script, "$$", "$")}})
+			return reference(name, next)
+		}
+		return "$(" + s.recipe(command, depth+1) + ")"
+	}
+
+	value, static := s.imp.expand(s.rule, v.value, 0, false)
+	if !static {
+		// A value using the environment or a command is used in place, as linea does
+		// not substitute variables in the values of other variables
+		return s.recipe(v.value, depth+1)
+	}
+	value = strings.ReplaceAll(value, "$$", "$")
+	if v.kind == makeDefault && value != "" {
+		s.declare(name, ProviderSpec{Provider: ProviderEnv, Name: nameIfDifferent(inner, name), Default: value})
+	} else {
+		s.declare(name, value)
+	}
+	return reference(name, next)
+}
+
+// automatic returns the value of an automatic variable such as $@ for the rule
+func (s *makeScope) automatic(inner string) (string, bool) {
+	rule := s.rule
+	var first string
+	if len(rule.prereqs) > 0 {
+		first = rule.prereqs[0]
+	}
+	switch inner {
+	case "@":
+		return rule.target, true
+	case "@D":
+		return path.Dir(rule.target), true
+	case "@F":
+		return path.Base(rule.target), true
+	case "<":
+		return first, true
+	case "<D":
+		return path.Dir(first), true
+	case "<F":
+		return path.Base(first), true
+	case "^":
+		var unique []string
+		seen := map[string]bool{}
+		for _, prereq := range rule.prereqs {
+			if !seen[prereq] {
+				seen[prereq] = true
+				unique = append(unique, prereq)
+			}
+		}
+		return strings.Join(unique, " "), true
+	case "+", "?":
+		return strings.Join(rule.prereqs, " "), true
+	case "|":
+		return strings.Join(rule.orderOnly, " "), true
+	case "*":
+		s.note("$* is empty outside pattern rules")
+		return "", true
+	}
+	return "", false
+}
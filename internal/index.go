@@ -0,0 +1,268 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// WorkflowInfo describes a workflow file known to the workflow index
+type WorkflowInfo struct {
+	Name        string            // Workflow name (file name without its extension)
+	Path        string            // Path to the workflow file
+	Description string            // description field, or the leading comment of the file
+	Variables   []string          // Declared variable names, sorted
+	Required    []string          // Referenced but undeclared variables that must be passed with -s, sorted
+	Defaults    map[string]string // Values of the declared variables that are not providers, from the first step declaring them
+}
+
+// IsWorkflowFile reports whether a file name has a workflow extension
+func IsWorkflowFile(name string) bool {
+	for _, ext := range WorkflowExtensions {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// WorkflowName returns the workflow name for a workflow file path
+func WorkflowName(path string) string {
+	name := filepath.Base(path)
+	for _, ext := range WorkflowExtensions {
+		if strings.HasSuffix(name, ext) {
+			return strings.TrimSuffix(name, ext)
+		}
+	}
+	return name
+}
+
+// FindWorkflowsDir looks for a .linea/workflows directory walking up from startDir
+// Returns an empty string if none is found
+func FindWorkflowsDir(startDir string) string {
+	currentDir := startDir
+	for {
+		potentialDir := filepath.Join(currentDir, ".linea", "workflows")
+		if info, err := os.Stat(potentialDir); err == nil && info.IsDir() {
+			return potentialDir
+		}
+
+		parent := filepath.Dir(currentDir)
+		if parent == currentDir {
+			// Reached root
+			return ""
+		}
+		currentDir = parent
+	}
+}
+
+// FindLineaDir looks for a project .linea directory walking up from startDir
+// Returns an empty string if none is found
+func FindLineaDir(startDir string) string {
+	currentDir := startDir
+	for {
+		potentialDir := filepath.Join(currentDir, ".linea")
+		if info, err := os.Stat(potentialDir); err == nil && info.IsDir() {
+			return potentialDir
+		}
+
+		parent := filepath.Dir(currentDir)
+		if parent == currentDir {
+			return ""
+		}
+		currentDir = parent
+	}
+}
+
+// IndexWorkflowFile reads a single workflow file into the index format
+// Files that fail to parse are still indexed, without variables
+func IndexWorkflowFile(path string) *WorkflowInfo {
+	info := &WorkflowInfo{
+		Name: WorkflowName(path),
+		Path: path,
+	}
+
+	configs, err := ParseMultiYAML(path)
+	if err != nil {
+		info.Description = leadingComment(path)
+		return info
+	}
+
+	if metadata := configs[0].Metadata; metadata != nil {
+		info.Description = metadata.Description
+	}
+	seen := make(map[string]bool)
+	required := make(map[string]bool)
+	for _, config := range configs {
+		if info.Description == "" {
+			info.Description = config.Description
+		}
+		for _, name := range declaredVariables(config) {
+			if !seen[name] {
+				seen[name] = true
+				info.Variables = append(info.Variables, name)
+				if value, ok := config.Variables[name]; ok {
+					if info.Defaults == nil {
+						info.Defaults = make(map[string]string)
+					}
+					info.Defaults[name] = value
+				}
+			}
+		}
+		for _, name := range requiredVariables(config) {
+			required[name] = true
+		}
+	}
+	for name := range required {
+		if !seen[name] {
+			info.Required = append(info.Required, name)
+		}
+	}
+	if info.Description == "" {
+		info.Description = leadingComment(path)
+	}
+	sort.Strings(info.Variables)
+	sort.Strings(info.Required)
+
+	return info
+}
+
+// requiredVariables returns the variables a step references without declaring them
+func requiredVariables(config *CommandConfig) []string {
+	known := BuiltinVariables(config)
+	for name := range config.Secrets {
+		known[name] = ""
+	}
+	for name := range config.Providers {
+		known[name] = ""
+	}
+	if config.Foreach != nil {
+		known["item"], known["index"] = "", ""
+	}
+
+	sources := append([]string{}, config.Args...)
+	for _, v := range config.Variables {
+		sources = append(sources, v)
+	}
+
+	var names []string
+	for _, source := range sources {
+		for name := range ExtractVariableReferences(source) {
+			if _, declared := config.Variables[name]; declared {
+				continue
+			}
+			if _, ok := known[name]; !ok {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// declaredVariables returns the names under variables:, including provider variables
+func declaredVariables(config *CommandConfig) []string {
+	names := make([]string, 0, len(config.Variables)+len(config.Providers))
+	for name := range config.Variables {
+		names = append(names, name)
+	}
+	for name := range config.Providers {
+		names = append(names, name)
+	}
+	return names
+}
+
+// leadingComment returns the first comment line at the top of a file, without the # prefix
+func leadingComment(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "#") {
+			return ""
+		}
+		if comment := strings.TrimSpace(strings.TrimLeft(line, "#")); comment != "" {
+			return comment
+		}
+	}
+	return ""
+}
+
+// IndexWorkflows returns the index of all workflows in a workflows directory, sorted by name
+func IndexWorkflows(dir string) ([]*WorkflowInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var workflows []*WorkflowInfo
+	for _, entry := range entries {
+		if entry.IsDir() || !IsWorkflowFile(entry.Name()) {
+			continue
+		}
+		workflows = append(workflows, IndexWorkflowFile(filepath.Join(dir, entry.Name())))
+	}
+
+	sort.Slice(workflows, func(i, j int) bool { return workflows[i].Name < workflows[j].Name })
+	return workflows, nil
+}
+
+// ResolveWorkflowPath turns a workflow reference into a file path
+// Existing files are returned as-is; otherwise ref is treated as a workflow name and looked up in
+// .linea/workflows (walking up from the current directory) and then the global workflows directory
+func ResolveWorkflowPath(ref string) (string, error) {
+	if info, err := os.Stat(ref); err == nil && !info.IsDir() {
+		return ref, nil
+	}
+
+	// Paths with separators are explicit and are not looked up by name
+	if strings.ContainsAny(ref, `/\`) {
+		return "", fmt.Errorf("workflow file %s not found", ref)
+	}
+
+	localDir := ""
+	if cwd, err := os.Getwd(); err == nil {
+		localDir = FindWorkflowsDir(cwd)
+	}
+	searched := WorkflowSearchDirs(localDir)
+
+	name := WorkflowName(ref)
+	if path := FindWorkflowInDirs(name, searched); path != "" {
+		return path, nil
+	}
+
+	return "", fmt.Errorf("workflow %s not found (looked for %s.yml/.yaml/.json/.toml in %s)", ref, name, strings.Join(searched, ", "))
+}
+
+// WorkflowSearchDirs returns the directories searched for workflows by name, in order:
+// the project's .linea/workflows (if localDir is not empty), the global workflows
+// directory, the workflows of installed packages, and the plugin_paths from the global config
+func WorkflowSearchDirs(localDir string) []string {
+	var dirs []string
+	if localDir != "" {
+		dirs = append(dirs, localDir)
+	}
+	dirs = append(dirs, GlobalWorkflowsDir())
+	dirs = append(dirs, PackageWorkflowDirs()...)
+	return append(dirs, CurrentUserConfig().PluginPaths...)
+}
+
+// FindWorkflowInDirs returns the first name.yml, name.yaml, name.json, or name.toml found in
+// dirs, or an empty string
+func FindWorkflowInDirs(name string, dirs []string) string {
+	for _, dir := range dirs {
+		for _, ext := range WorkflowExtensions {
+			path := filepath.Join(dir, name+ext)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return path
+			}
+		}
+	}
+	return ""
+}
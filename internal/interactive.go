@@ -0,0 +1,111 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+)
+
+// interactiveOut is where interactive steps write: linea's standard output, or standard
+// error while standard output is reserved for a report (see ReserveStdout)
+var interactiveOut = os.Stdout
+
+// ReserveStdout sends the output of interactive steps to standard error, used when
+// standard output only holds a --output report or --summary json
+func ReserveStdout() {
+	interactiveOut = os.Stderr
+}
+
+// CheckInteractive validates the interactive: flag of a step against the fields that
+// need its output or input to go through linea
+func CheckInteractive(config *CommandConfig) error {
+	if !config.Interactive {
+		return nil
+	}
+	switch {
+	case config.Type != "":
+		return fmt.Errorf("interactive cannot be combined with type: %s", config.Type)
+	case config.Capture:
+		return fmt.Errorf("interactive steps cannot capture their output")
+	case config.Stdin != nil:
+		return fmt.Errorf("interactive steps read the terminal and cannot have stdin")
+	}
+	return nil
+}
+
+// executeInteractive runs a command of an interactive: step attached to the terminal, so
+// that programs like ssh, docker exec -it, and editors see a TTY on every stream
+// While it runs, Ctrl+C goes to the command alone: linea waits for it to exit
+// line is the command line to run, as built by commandLine
+func executeInteractive(line []string, env []string, limits *ResourceLimits) error {
+	if len(line) == 0 {
+		return fmt.Errorf("command is empty")
+	}
+	stdin, stdout, stderr, closeTerminal := openTerminal()
+	defer closeTerminal()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	defer signal.Stop(signals)
+
+	execCmd := exec.Command(line[0], line[1:]...)
+	execCmd.Env = env
+	execCmd.Stdin, execCmd.Stdout, execCmd.Stderr = stdin, stdout, stderr
+	if err := execCmd.Start(); err != nil {
+		return &CommandError{Err: err}
+	}
+	// It stays in linea's process group, the one that may read the terminal
+	group := newProcessGroup(execCmd.Process, false, limits)
+	defer group.release()
+	untrack := trackProcess(group, true)
+	defer untrack()
+	if err := execCmd.Wait(); err != nil {
+		return &CommandError{Err: err}
+	}
+	return nil
+}
+
+// openTerminal returns the files an interactive command is attached to: linea's own
+// streams where they are terminals, and otherwise the controlling terminal (/dev/tty, or
+// the console on Windows), so that a workflow run with its input or output redirected can
+// still open an editor. Without a terminal, as in CI, linea's own streams are used
+func openTerminal() (stdin, stdout, stderr *os.File, closeTerminal func()) {
+	stdin, stdout, stderr = os.Stdin, interactiveOut, os.Stderr
+	var opened []*os.File
+	open := func(name string, flag int) *os.File {
+		f, err := os.OpenFile(name, flag, 0)
+		if err != nil {
+			return nil
+		}
+		opened = append(opened, f)
+		return f
+	}
+
+	inName, outName := "/dev/tty", "/dev/tty"
+	if runtime.GOOS == "windows" {
+		inName, outName = "CONIN$", "CONOUT$"
+	}
+	if !IsTerminal(stdin) {
+		if f := open(inName, os.O_RDWR); f != nil {
+			stdin = f
+		}
+	}
+	if !IsTerminal(stdout) {
+		if IsTerminal(os.Stderr) {
+			stdout = os.Stderr
+		} else if f := open(outName, os.O_RDWR); f != nil {
+			stdout = f
+		}
+	}
+	if !IsTerminal(stderr) && IsTerminal(stdout) {
+		stderr = stdout
+	}
+
+	return stdin, stdout, stderr, func() {
+		for _, f := range opened {
+			f.Close()
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"linea/internal/store"
+)
+
+// JobsDirName is the directory for background jobs inside a project's .linea directory
+// (or the state directory outside a project)
+const JobsDirName = "run"
+
+// DefaultStopTimeout is how long `linea stop` waits for a job to exit before killing it
+const DefaultStopTimeout = 5 * time.Second
+
+// Job is a process started by a `background: true` step, tracked in <id>.json in the jobs
+// directory so that `linea jobs` and `linea stop` can find it after the run has ended
+type Job struct {
+	ID       string    `json:"id"`
+	Workflow string    `json:"workflow"`
+	Path     string    `json:"path"`    // Workflow file
+	Command  []string  `json:"command"` // Secret values are masked
+	PID      int       `json:"pid"`
+	Start    time.Time `json:"start"`
+	LogFile  string    `json:"log_file"` // The job's stdout and stderr
+}
+
+// JobsDir returns the directory tracking the background jobs of a project: the .linea/run
+// directory found from dir, or run/ in the state directory
+func JobsDir(dir string) string {
+	if lineaDir := FindLineaDir(dir); lineaDir != "" {
+		return filepath.Join(lineaDir, JobsDirName)
+	}
+	return filepath.Join(UserPaths().State, JobsDirName)
+}
+
+// workflowStateFile returns the file of state kept about a workflow file in the dirName
+// directory of its jobs directory. The name includes a hash of the path, so that
+// workflows with the same name in different directories have their own files
+func workflowStateFile(path, dirName, ext string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	sum := sha256.Sum256([]byte(path))
+	name := fmt.Sprintf("%s-%s%s", WorkflowName(path), hex.EncodeToString(sum[:4]), ext)
+	return filepath.Join(JobsDir(filepath.Dir(path)), dirName, name)
+}
+
+// JobID returns the ID of the job a background step starts: its name, or else the name
+// of its command, prefixed by its scope (see stepScope) and made safe for file names
+func JobID(config *CommandConfig) string {
+	name := config.Name
+	if name == "" {
+		name = filepath.Base(config.Command)
+	}
+	if scope := stepScope(config); scope != "" {
+		name = scope + "-" + name
+	}
+	id := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		}
+		return '-'
+	}, name)
+	return strings.Trim(id, "-.")
+}
+
+// CheckBackground validates the background: flag of a step against the fields that need
+// linea to wait for the command
+func CheckBackground(config *CommandConfig) error {
+	if !config.Background {
+		return nil
+	}
+	switch {
+	case config.Type != "":
+		return fmt.Errorf("background cannot be combined with type: %s", config.Type)
+	case config.Interactive:
+		return fmt.Errorf("background steps cannot be interactive")
+	case config.Capture:
+		return fmt.Errorf("background steps cannot capture their output; it is written to the job's log")
+	case len(config.AllowedExitCodes) > 0:
+		return fmt.Errorf("background steps have no exit code for allowed_exit_codes")
+	case JobID(config) == "":
+		return fmt.Errorf("background step needs a name to use as its job ID")
+	}
+	return nil
+}
+
+// startBackgroundJob starts the command of a background: step without waiting for it,
+// with its output going to a log file next to the job, and reports the job to stdout
+// Only one job with an ID runs at a time
+func startBackgroundJob(config *CommandConfig, cmd []string, shell string, env []string, limits *ResourceLimits, stdin io.Reader, stdout io.Writer) error {
+	if len(cmd) == 0 {
+		return fmt.Errorf("command is empty")
+	}
+	dir := JobsDir(filepath.Dir(config.SourceFile))
+	id := JobID(config)
+	if job, err := LoadJob(dir, id); err == nil && job.Running() {
+		return fmt.Errorf("job '%s' is already running (pid %d); stop it with: linea stop %s", id, job.PID, id)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create jobs directory: %w", err)
+	}
+
+	logPath := filepath.Join(dir, id+".log")
+	logFile, err := os.Create(logPath)
+	if err != nil {
+		return fmt.Errorf("failed to create job log: %w", err)
+	}
+	defer logFile.Close()
+
+	line := limitedCommandLine(cmd, shell, limits)
+	execCmd := exec.Command(line[0], line[1:]...)
+	execCmd.Env = env
+	execCmd.Stdin = stdin
+	execCmd.Stdout, execCmd.Stderr = logFile, logFile
+	// In a group of its own, the job is not interrupted by Ctrl+C in the terminal, and
+	// linea stop also stops the processes it starts
+	setOwnProcessGroup(execCmd)
+	if err := execCmd.Start(); err != nil {
+		return err
+	}
+	if limits != nil {
+		// On Windows, the job object holding the limits lives on with the job
+		newProcessGroup(execCmd.Process, true, limits).release()
+	}
+	// Reaped while linea runs; afterwards the job carries on without it
+	go execCmd.Wait()
+
+	job := &Job{
+		ID:       id,
+		Workflow: WorkflowName(config.SourceFile),
+		Path:     config.SourceFile,
+		Command:  maskedCommand(cmd),
+		PID:      execCmd.Process.Pid,
+		Start:    time.Now().UTC(),
+		LogFile:  logPath,
+	}
+	if abs, err := filepath.Abs(config.SourceFile); err == nil && config.SourceFile != "" {
+		job.Path = abs
+	}
+	if err := saveJob(dir, job); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Started job %s (pid %d), output in %s\n", id, job.PID, logPath)
+	return nil
+}
+
+// saveJob writes the tracking file of a job
+func saveJob(dir string, job *Job) error {
+	data, err := json.MarshalIndent(job, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode job: %w", err)
+	}
+	if err := store.WriteFile(filepath.Join(dir, job.ID+".json"), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write job file: %w", err)
+	}
+	return nil
+}
+
+// LoadJob reads the job with the given ID from dir
+func LoadJob(dir, id string) (*Job, error) {
+	data, err := os.ReadFile(filepath.Join(dir, id+".json"))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("job '%s' not found in %s", id, dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var job Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("invalid job file for '%s': %w", id, err)
+	}
+	return &job, nil
+}
+
+// LoadJobs reads every job tracked in dir, sorted by ID; a missing directory has none
+func LoadJobs(dir string) ([]*Job, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	var jobs []*Job
+	for _, match := range matches {
+		job, err := LoadJob(dir, strings.TrimSuffix(filepath.Base(match), ".json"))
+		if err != nil {
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// Running reports whether the job's process is still alive
+func (j *Job) Running() bool {
+	return processAlive(j.PID)
+}
+
+// StopJob terminates a job, killing it if it has not exited after timeout, and stops
+// tracking it; its log is kept. A job that already exited is only forgotten
+func StopJob(dir string, job *Job, timeout time.Duration) error {
+	if job.Running() {
+		if err := terminateProcess(job.PID, timeout); err != nil {
+			return fmt.Errorf("failed to stop job '%s' (pid %d): %w", job.ID, job.PID, err)
+		}
+	}
+	if err := os.Remove(filepath.Join(dir, job.ID+".json")); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// processAlive reports whether a process with the PID exists
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		// FindProcess opens the process, which fails once it has exited
+		process.Release()
+		return true
+	}
+	return process.Signal(syscall.Signal(0)) == nil
+}
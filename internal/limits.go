@@ -0,0 +1,210 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ResourceLimits caps what the command of a step may use, to protect the host from
+// runaway or untrusted commands (limits:)
+type ResourceLimits struct {
+	Memory  string `yaml:"memory,omitempty"`   // e.g. 512M; virtual memory on Unix, committed memory on Windows
+	CPUTime string `yaml:"cpu_time,omitempty"` // CPU time the command may use, e.g. 10m
+	Nice    *int   `yaml:"nice,omitempty"`     // Scheduling priority, from 0 (normal) to 19 (lowest)
+	Output  string `yaml:"output,omitempty"`   // Combined size of stdout and stderr, e.g. 10M
+}
+
+// Check validates the limits
+func (l *ResourceLimits) Check() error {
+	if l.Memory != "" {
+		if _, err := ParseSize(l.Memory); err != nil {
+			return fmt.Errorf("limits.memory: %v", err)
+		}
+	}
+	if l.CPUTime != "" {
+		if _, err := time.ParseDuration(l.CPUTime); err != nil || strings.HasPrefix(l.CPUTime, "-") {
+			return fmt.Errorf("limits.cpu_time: invalid duration '%s' (expected e.g. 30s or 10m)", l.CPUTime)
+		}
+	}
+	if l.Nice != nil && (*l.Nice < 0 || *l.Nice > 19) {
+		return fmt.Errorf("limits.nice: %d is out of range (expected 0-19)", *l.Nice)
+	}
+	if l.Output != "" {
+		if _, err := ParseSize(l.Output); err != nil {
+			return fmt.Errorf("limits.output: %v", err)
+		}
+	}
+	return nil
+}
+
+// CheckLimits validates the limits: of a step against the fields they cannot apply to
+func CheckLimits(config *CommandConfig) error {
+	if config.Limits == nil {
+		return nil
+	}
+	switch {
+	case config.Type != "":
+		return fmt.Errorf("limits cannot be combined with type: %s", config.Type)
+	case config.Limits.Output != "" && config.Interactive:
+		return fmt.Errorf("limits.output does not apply to interactive steps, whose output linea does not see")
+	case config.Limits.Output != "" && config.Background:
+		return fmt.Errorf("limits.output does not apply to background steps, whose output goes to the job's log")
+	}
+	return config.Limits.Check()
+}
+
+// memoryBytes returns the memory limit in bytes, 0 if there is none
+func (l *ResourceLimits) memoryBytes() int64 {
+	if l == nil {
+		return 0
+	}
+	n, _ := ParseSize(l.Memory)
+	return n
+}
+
+// cpuTime returns the CPU time limit, 0 if there is none
+func (l *ResourceLimits) cpuTime() time.Duration {
+	if l == nil {
+		return 0
+	}
+	d, _ := time.ParseDuration(l.CPUTime)
+	return d
+}
+
+// nice returns the niceness of the command, 0 if it runs with the normal priority
+func (l *ResourceLimits) nice() int {
+	if l == nil || l.Nice == nil {
+		return 0
+	}
+	return *l.Nice
+}
+
+// outputBytes returns the output limit in bytes, 0 if there is none
+func (l *ResourceLimits) outputBytes() int64 {
+	if l == nil {
+		return 0
+	}
+	n, _ := ParseSize(l.Output)
+	return n
+}
+
+// limitCommandLine wraps a command line on Unix in sh, which sets the memory and CPU
+// time limits with ulimit and lowers the priority with nice before running the command
+// Windows applies them to the command's job object instead (see newProcessGroup)
+func limitCommandLine(line []string, limits *ResourceLimits) []string {
+	var script []string
+	if memory := limits.memoryBytes(); memory > 0 {
+		script = append(script, fmt.Sprintf("ulimit -v %d", (memory+1023)/1024))
+	}
+	if cpu := limits.cpuTime(); cpu > 0 {
+		script = append(script, fmt.Sprintf("ulimit -t %d", int64((cpu+time.Second-1)/time.Second)))
+	}
+	run := `exec "$@"`
+	if nice := limits.nice(); nice > 0 {
+		run = fmt.Sprintf(`exec nice -n %d "$@"`, nice)
+	}
+	if len(script) == 0 && run == `exec "$@"` {
+		return line
+	}
+	script = append(script, run)
+	return append([]string{"/bin/sh", "-c", strings.Join(script, " && "), "linea"}, line...)
+}
+
+// ParseSize parses a size in bytes with an optional binary unit: K, M, G, or T, with or
+// without B (e.g. 512M, 1.5GB, 64KiB)
+func ParseSize(s string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(s))
+	value = strings.TrimSuffix(strings.TrimSuffix(value, "B"), "I")
+	multiplier := int64(1)
+	if n := len(value); n > 0 {
+		switch value[n-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		case 'T':
+			multiplier = 1 << 40
+		}
+		if multiplier > 1 {
+			value = value[:n-1]
+		}
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size '%s' (expected e.g. 512M or 2G)", s)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// outputLimit caps the combined output of a command, killing it once it has written more
+type outputLimit struct {
+	mu       sync.Mutex
+	max      int64
+	written  int64
+	exceeded bool
+	kill     func() error
+}
+
+// writer returns w counting against the limit; output past it is dropped
+func (o *outputLimit) writer(w io.Writer) io.Writer {
+	if w == nil {
+		w = io.Discard
+	}
+	return &limitedWriter{limit: o, w: w}
+}
+
+// start sets how to kill the started command, killing it right away if its output is
+// already over the limit
+func (o *outputLimit) start(kill func() error) {
+	o.mu.Lock()
+	o.kill = kill
+	exceeded := o.exceeded
+	o.mu.Unlock()
+	if exceeded {
+		kill()
+	}
+}
+
+// err returns the error of a command whose output went over the limit, or nil
+func (o *outputLimit) err() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if !o.exceeded {
+		return nil
+	}
+	return fmt.Errorf("output exceeded limits.output of %d bytes; the command was killed", o.max)
+}
+
+// limitedWriter is one of the output streams of a command with an output limit
+type limitedWriter struct {
+	limit *outputLimit
+	w     io.Writer
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	o := l.limit
+	o.mu.Lock()
+	if o.exceeded {
+		o.mu.Unlock()
+		return len(p), nil
+	}
+	if remaining := o.max - o.written; int64(len(p)) > remaining {
+		o.written, o.exceeded = o.max, true
+		kill := o.kill
+		o.mu.Unlock()
+		l.w.Write(p[:remaining])
+		if kill != nil {
+			kill()
+		}
+		return len(p), nil
+	}
+	o.written += int64(len(p))
+	o.mu.Unlock()
+	return l.w.Write(p)
+}
@@ -14,7 +14,8 @@ import (
 // LineashContext holds the execution context for lineash scripts
 type LineashContext struct {
 	Variables    map[string]string
-	WorkflowsDir string
+	WorkflowsDir string   // Project .linea/workflows directory, empty if there is none
+	SearchDirs   []string // Directories searched for workflows: project, global, plugin paths
 	ScriptDir    string
 	LineaPath    string
 	Args         []string // Positional parameters $1, $2, etc.
@@ -25,35 +26,18 @@ func NewLineashContext(scriptPath string) (*LineashContext, error) {
 	scriptDir := filepath.Dir(scriptPath)
 	
 	// Find .linea/workflows directory by walking up from script
-	var workflowsDir string
-	currentDir := scriptDir
-	
-	for {
-		potentialDir := filepath.Join(currentDir, ".linea", "workflows")
-		if info, err := os.Stat(potentialDir); err == nil && info.IsDir() {
-			workflowsDir = potentialDir
-			break
-		}
-		
-		parent := filepath.Dir(currentDir)
-		if parent == currentDir {
-			// Reached root
-			break
-		}
-		currentDir = parent
-	}
+	// Workflows not found there fall back to the global workflows directory
+	workflowsDir := FindWorkflowsDir(scriptDir)
+	searchDirs := WorkflowSearchDirs(workflowsDir)
 	
 	if workflowsDir == "" {
-		return nil, fmt.Errorf("could not find .linea/workflows directory")
-	}
-	
-	// Find linea executable - try multiple locations
-	lineaExe := "linea"
-	if runtime.GOOS == "windows" {
-		lineaExe = "linea.exe"
+		if info, err := os.Stat(GlobalWorkflowsDir()); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("could not find .linea/workflows directory or global workflows in %s", GlobalWorkflowsDir())
+		}
 	}
 	
-	lineaPath, err := findLineaExecutable(scriptPath, lineaExe)
+	// Find linea executable (LINEA_BIN, same directory, then PATH)
+	lineaPath, err := FindLineaExecutable()
 	if err != nil {
 		return nil, fmt.Errorf("linea executable not found: %w", err)
 	}
@@ -61,65 +45,42 @@ func NewLineashContext(scriptPath string) (*LineashContext, error) {
 	return &LineashContext{
 		Variables:    make(map[string]string),
 		WorkflowsDir: workflowsDir,
+		SearchDirs:   searchDirs,
 		ScriptDir:    scriptDir,
 		LineaPath:    lineaPath,
 		Args:         []string{}, // Empty by default, can be set if args are passed
 	}, nil
 }
 
-// findLineaExecutable searches for the linea executable in multiple locations
-func findLineaExecutable(scriptPath, lineaExe string) (string, error) {
-	scriptDir := filepath.Dir(scriptPath)
-	
-	// 1. Try PATH
-	if path, err := exec.LookPath(lineaExe); err == nil {
-		return path, nil
-	}
-	
-	// 2. Try relative to script directory (../../bin/linea.exe)
-	potentialPath := filepath.Join(scriptDir, "..", "..", "bin", lineaExe)
-	if absPath, err := filepath.Abs(potentialPath); err == nil {
-		if _, err := os.Stat(absPath); err == nil {
-			return absPath, nil
-		}
-	}
-	
-	// 3. Try in same directory as lineash (if lineash is in bin/)
-	// Get the executable path
-	execPath, err := os.Executable()
-	if err == nil {
-		execDir := filepath.Dir(execPath)
-		potentialPath = filepath.Join(execDir, lineaExe)
-		if _, err := os.Stat(potentialPath); err == nil {
-			return potentialPath, nil
-		}
-	}
-	
-	// 4. Try current working directory/bin/
-	if cwd, err := os.Getwd(); err == nil {
-		potentialPath = filepath.Join(cwd, "bin", lineaExe)
-		if _, err := os.Stat(potentialPath); err == nil {
-			return potentialPath, nil
-		}
+// workflowSearchDirs returns SearchDirs, or just WorkflowsDir for contexts built without search dirs
+func (ctx *LineashContext) workflowSearchDirs() []string {
+	if len(ctx.SearchDirs) == 0 && ctx.WorkflowsDir != "" {
+		return []string{ctx.WorkflowsDir}
 	}
-	
-	return "", fmt.Errorf("could not find %s in PATH or common locations", lineaExe)
+	return ctx.SearchDirs
 }
 
 // GetAvailableWorkflows returns a list of available workflow names
+// Names are collected from every search directory; missing directories are skipped
 func (ctx *LineashContext) GetAvailableWorkflows() ([]string, error) {
-	entries, err := os.ReadDir(ctx.WorkflowsDir)
-	if err != nil {
-		return nil, err
-	}
-	
 	var workflows []string
-	for _, entry := range entries {
-		if !entry.IsDir() && (strings.HasSuffix(entry.Name(), ".yml") || strings.HasSuffix(entry.Name(), ".yaml")) {
-			// Remove extension to get workflow name
-			name := strings.TrimSuffix(entry.Name(), ".yml")
-			name = strings.TrimSuffix(name, ".yaml")
-			workflows = append(workflows, name)
+	seen := make(map[string]bool)
+	
+	for _, dir := range ctx.workflowSearchDirs() {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		
+		for _, entry := range entries {
+			if !entry.IsDir() && IsWorkflowFile(entry.Name()) {
+				// Remove extension to get workflow name
+				name := WorkflowName(entry.Name())
+				if !seen[name] {
+					seen[name] = true
+					workflows = append(workflows, name)
+				}
+			}
 		}
 	}
 	
@@ -144,20 +105,17 @@ func (ctx *LineashContext) IsWorkflowCommand(cmdName string) bool {
 
 // ExecuteWorkflowCommand executes a workflow command via Linea
 func (ctx *LineashContext) ExecuteWorkflowCommand(workflowName string, args []string) error {
-	workflowFile := filepath.Join(ctx.WorkflowsDir, workflowName+".yml")
-	
-	// Check if .yaml extension exists
-	if _, err := os.Stat(workflowFile); os.IsNotExist(err) {
-		workflowFile = filepath.Join(ctx.WorkflowsDir, workflowName+".yaml")
-		if _, err := os.Stat(workflowFile); os.IsNotExist(err) {
-			return fmt.Errorf("workflow %s not found", workflowName)
-		}
+	// Project workflows shadow global ones with the same name
+	workflowFile := FindWorkflowInDirs(workflowName, ctx.workflowSearchDirs())
+	if workflowFile == "" {
+		return fmt.Errorf("workflow %s not found", workflowName)
 	}
 	
-	// Build linea command: linea run <workflow-file> [remaining args]
+	// Build linea command: linea run --summary none <workflow-file> [remaining args]
 	// The args are already parsed and have quotes stripped by parseCommand
 	// So we can pass them directly
-	lineaArgs := []string{"run", workflowFile}
+	// Workflows behave like commands in scripts, so the step summary is left out
+	lineaArgs := []string{"run", "--summary", "none", workflowFile}
 	lineaArgs = append(lineaArgs, args...)
 	
 	execCmd := exec.Command(ctx.LineaPath, lineaArgs...)
@@ -218,60 +176,8 @@ func (ctx *LineashContext) SubstituteVariables(line string) string {
 	// Handle positional parameters $1, $2, etc.
 	result = substitutePositionalParams(result, ctx)
 	
-	// Sort variables by length (longest first) to avoid partial replacements
-	type varEntry struct {
-		key   string
-		value string
-	}
-	vars := make([]varEntry, 0, len(ctx.Variables))
-	for key, value := range ctx.Variables {
-		vars = append(vars, varEntry{key, value})
-	}
-	
-	// Sort by key length descending
-	for i := 0; i < len(vars); i++ {
-		for j := i + 1; j < len(vars); j++ {
-			if len(vars[i].key) < len(vars[j].key) {
-				vars[i], vars[j] = vars[j], vars[i]
-			}
-		}
-	}
-	
-	// Replace variables
-	for _, v := range vars {
-		// Replace ${VAR} first (more specific, handles braces)
-		placeholder2 := "${" + v.key + "}"
-		result = strings.ReplaceAll(result, placeholder2, v.value)
-		
-		// Replace $VAR (but not if it's part of a longer variable name)
-		placeholder1 := "$" + v.key
-		for {
-			idx := strings.Index(result, placeholder1)
-			if idx == -1 {
-				break
-			}
-			
-			// Check if it's a valid variable reference (not part of a longer variable)
-			afterIdx := idx + len(placeholder1)
-			if afterIdx >= len(result) {
-				// End of string, valid replacement
-				result = result[:idx] + v.value + result[afterIdx:]
-			} else {
-				nextChar := result[afterIdx]
-				// Valid if next char is not alphanumeric or underscore
-				if !((nextChar >= 'a' && nextChar <= 'z') || 
-					 (nextChar >= 'A' && nextChar <= 'Z') || 
-					 (nextChar >= '0' && nextChar <= '9') || 
-					 nextChar == '_') {
-					result = result[:idx] + v.value + result[afterIdx:]
-				} else {
-					// Skip this occurrence, it's part of a longer variable
-					// Move past the $ to avoid infinite loop
-					result = result[:idx+1] + result[idx+1:]
-				}
-			}
-		}
-	}
+	// Replace $VAR and ${VAR} in a single pass; $VARS is never read as $VAR followed by S
+	result = substituteTemplate(result, nil, ctx.Variables)
 	
 	return result
 }
@@ -319,38 +225,9 @@ func substituteArithmetic(line string, ctx *LineashContext) string {
 		// Handle $variable and ${variable} syntax
 		expr = substitutePositionalParams(expr, ctx)
 		
-		// Substitute variables - need to handle variable names that might be part of the expression
-		// Sort by length to avoid partial matches
-		type varEntry struct {
-			key   string
-			value string
-		}
-		vars := make([]varEntry, 0, len(ctx.Variables))
-		for key, value := range ctx.Variables {
-			vars = append(vars, varEntry{key, value})
-		}
-		
-		// Sort by key length descending
-		for i := 0; i < len(vars); i++ {
-			for j := i + 1; j < len(vars); j++ {
-				if len(vars[i].key) < len(vars[j].key) {
-					vars[i], vars[j] = vars[j], vars[i]
-				}
-			}
-		}
-		
-		// Replace variables (without $ prefix in arithmetic expressions)
-		for _, v := range vars {
-			// Replace variable name with its value
-			// Use word boundaries to avoid partial matches
-			expr = regexp.MustCompile(`\b`+regexp.QuoteMeta(v.key)+`\b`).ReplaceAllString(expr, v.value)
-		}
-		
-		// Also handle $variable syntax in arithmetic
-		for _, v := range vars {
-			expr = strings.ReplaceAll(expr, "$"+v.key, v.value)
-			expr = strings.ReplaceAll(expr, "${"+v.key+"}", v.value)
-		}
+		// Substitute $variable, ${variable}, and bare variable names
+		expr = substituteTemplate(expr, nil, ctx.Variables)
+		expr = substituteArithmeticNames(expr, ctx.Variables)
 		
 		// Evaluate arithmetic expression
 		value := evaluateArithmetic(expr)
@@ -360,6 +237,30 @@ func substituteArithmetic(line string, ctx *LineashContext) string {
 	return result
 }
 
+// substituteArithmeticNames replaces the variable names of an arithmetic expression, as
+// in $((count + 1)), with their values
+func substituteArithmeticNames(expr string, variables map[string]string) string {
+	var b strings.Builder
+	for i := 0; i < len(expr); {
+		end := i
+		for end < len(expr) && isVariableName(expr[end:end+1]) {
+			end++
+		}
+		if end == i {
+			b.WriteByte(expr[i])
+			i++
+			continue
+		}
+		if value, ok := variables[expr[i:end]]; ok {
+			b.WriteString(value)
+		} else {
+			b.WriteString(expr[i:end])
+		}
+		i = end
+	}
+	return b.String()
+}
+
 // evaluateArithmetic evaluates a simple arithmetic expression
 func evaluateArithmetic(expr string) string {
 	expr = strings.TrimSpace(expr)
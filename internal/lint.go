@@ -0,0 +1,418 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LintRule is a best-practice check run by `linea lint`
+type LintRule struct {
+	ID          string
+	Name        string
+	Description string
+	Fixable     bool // --fix can correct findings of this rule
+}
+
+// LintRules lists the lint rules in the order they are reported
+var LintRules = []LintRule{
+	{ID: "L001", Name: "unused-variable", Description: "a declared variable or secret is never referenced", Fixable: true},
+	{ID: "L002", Name: "placeholder-typo", Description: "a {placeholder} is misspelled, padded with spaces, or not closed", Fixable: true},
+	{ID: "L003", Name: "shell-metacharacter", Description: "an argument uses shell syntax but the command is not run by a shell"},
+	{ID: "L004", Name: "path-escapes-project", Description: "a relative path points outside the project directory"},
+	{ID: "L005", Name: "missing-description", Description: "the workflow has no description: field or header comment"},
+}
+
+// LintFinding is a single lint rule violation
+type LintFinding struct {
+	File    string
+	Line    int
+	Rule    LintRule
+	Message string
+	Fixed   bool // set by LintWorkflowFile when the finding was corrected
+	fix     func()
+}
+
+// String formats the finding as file:line: ID name: message
+func (f LintFinding) String() string {
+	location := f.File
+	if f.Line > 0 {
+		location = fmt.Sprintf("%s:%d", f.File, f.Line)
+	}
+	return fmt.Sprintf("%s: %s %s: %s", location, f.Rule.ID, f.Rule.Name, f.Message)
+}
+
+// LookupLintRule returns the rule with the given ID or name
+func LookupLintRule(idOrName string) (LintRule, bool) {
+	for _, rule := range LintRules {
+		if strings.EqualFold(rule.ID, idOrName) || rule.Name == idOrName {
+			return rule, true
+		}
+	}
+	return LintRule{}, false
+}
+
+// shellCommands are the commands that interpret shell syntax in their arguments
+var shellCommands = map[string]bool{
+	"sh": true, "bash": true, "zsh": true, "dash": true, "ksh": true, "fish": true,
+	"cmd": true, "powershell": true, "pwsh": true,
+}
+
+// shellOperators are arguments that only mean something to a shell
+var shellOperators = map[string]bool{
+	"|": true, "||": true, "&&": true, "&": true, ";": true, "|&": true,
+	">": true, ">>": true, "<": true, "2>": true, "2>&1": true, "&>": true,
+}
+
+// LintWorkflowFile runs the lint rules on a workflow file
+// With fix set, fixable findings are corrected and the fixed file contents are returned in
+// the canonical `linea fmt` form; the contents are nil if nothing was fixed
+// Files that are not valid YAML are reported as an error: run `linea validate` first
+func LintWorkflowFile(path string, fix bool) ([]LintFinding, []byte, error) {
+	data, err := ReadWorkflowFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	// .json and .toml files are read converted to YAML, which --fix cannot write back
+	fix = fix && IsYAMLFile(path)
+	docs, err := decodeWorkflowDocuments(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	projectRoot := ""
+	if abs, err := filepath.Abs(path); err == nil {
+		if lineaDir := FindLineaDir(filepath.Dir(abs)); lineaDir != "" {
+			projectRoot = filepath.Dir(lineaDir)
+		}
+	}
+
+	var findings []LintFinding
+	for _, doc := range docs {
+		root := doc.Content[0]
+		if root.Kind != yaml.MappingNode {
+			continue
+		}
+		// Decode a copy: loading upgrades the node, which --fix must not write back
+		var config CommandConfig
+		if err := cloneNode(root).Decode(&config); err != nil {
+			continue // Reported by linea validate
+		}
+		findings = append(findings, lintDocument(path, root, &config, projectRoot)...)
+	}
+
+	if IndexWorkflowFile(path).Description == "" {
+		findings = append(findings, LintFinding{File: path, Line: 1, Rule: lintRule("L005"),
+			Message: "add a description: field or a header comment so linea list can describe the workflow"})
+	}
+
+	if !IsYAMLFile(path) {
+		for i := range findings {
+			findings[i].Line = 0
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Line != findings[j].Line {
+			return findings[i].Line < findings[j].Line
+		}
+		return findings[i].Rule.ID < findings[j].Rule.ID
+	})
+
+	if !fix {
+		return findings, nil, nil
+	}
+	fixed := false
+	for i := range findings {
+		if findings[i].fix != nil {
+			findings[i].fix()
+			findings[i].Fixed = true
+			fixed = true
+		}
+	}
+	if !fixed {
+		return findings, nil, nil
+	}
+	out, err := encodeWorkflowDocuments(docs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if bytes.Equal(out, data) {
+		out = nil
+	}
+	return findings, out, nil
+}
+
+// lintDocument runs the per-step rules on one workflow document
+func lintDocument(path string, root *yaml.Node, config *CommandConfig, projectRoot string) []LintFinding {
+	var findings []LintFinding
+	add := func(line int, id string, fix func(), format string, args ...interface{}) {
+		findings = append(findings, LintFinding{File: path, Line: line, Rule: lintRule(id), Message: fmt.Sprintf(format, args...), fix: fix})
+	}
+
+	known := BuiltinVariables(config)
+	for _, name := range declaredVariables(config) {
+		known[name] = ""
+	}
+	for name := range config.Secrets {
+		known[name] = ""
+	}
+
+	// Strings where variables are substituted: args and variable values
+	args := mappingNode(root, "args")
+	variables := mappingNode(root, "variables")
+	var sources []*yaml.Node
+	switch {
+	case args != nil && args.Kind == yaml.SequenceNode:
+		sources = append(sources, args.Content...)
+	case args != nil && args.Kind == yaml.ScalarNode:
+		sources = append(sources, args)
+	}
+	if variables != nil && variables.Kind == yaml.MappingNode {
+		for i := 1; i < len(variables.Content); i += 2 {
+			sources = append(sources, variables.Content[i])
+		}
+	}
+
+	referenced := make(map[string]bool)
+	for _, node := range sources {
+		if node.Kind != yaml.ScalarNode {
+			continue
+		}
+		for name := range ExtractVariableReferences(node.Value) {
+			referenced[name] = true
+		}
+		for _, typo := range placeholderTypos(node.Value, known) {
+			node, typo := node, typo
+			if typo.name != "" {
+				referenced[typo.name] = true
+			}
+			add(node.Line, "L002", func() { node.Value = strings.Replace(node.Value, typo.text, typo.replacement, 1) }, "%s", typo.message)
+		}
+	}
+
+	// L001: declared but never referenced
+	for _, key := range []string{"variables", "secrets"} {
+		declared := mappingNode(root, key)
+		if declared == nil || declared.Kind != yaml.MappingNode {
+			continue
+		}
+		for i := 0; i+1 < len(declared.Content); i += 2 {
+			name := declared.Content[i]
+			if referenced[name.Value] {
+				continue
+			}
+			kind := "variable"
+			if key == "secrets" {
+				kind = "secret"
+			}
+			key, declared, name := key, declared, name
+			fix := func() {
+				removeMappingKey(declared, name)
+				if len(declared.Content) == 0 {
+					removeMappingKey(root, mappingKeyNode(root, key))
+				}
+			}
+			add(name.Line, "L001", fix, "%s '%s' is declared but never used", kind, name.Value)
+		}
+	}
+
+	// L003: shell syntax passed straight to a program
+	if !shellCommands[commandBaseName(config.Command)] && args != nil {
+		for _, node := range sources {
+			if node.Kind != yaml.ScalarNode || !nodeIn(node, args) {
+				continue
+			}
+			if shellOperators[node.Value] || strings.Contains(node.Value, "$(") || strings.Contains(node.Value, "`") {
+				add(node.Line, "L003", nil, "'%s' is passed to %s as a literal argument; run the step with command: sh and args: [-c, ...] to use shell syntax", node.Value, config.Command)
+			}
+		}
+	}
+
+	// L004: relative paths that leave the project
+	if projectRoot != "" {
+		for _, node := range sources {
+			if node.Kind != yaml.ScalarNode {
+				continue
+			}
+			if p := escapingPath(node.Value, projectRoot); p != "" {
+				add(node.Line, "L004", nil, "'%s' points outside the project directory %s", p, projectRoot)
+			}
+		}
+	}
+
+	return findings
+}
+
+// placeholderTypo is a suspicious {placeholder} and its correction
+type placeholderTypo struct {
+	text        string // the text to replace
+	replacement string
+	name        string // the variable the placeholder was meant to reference
+	message     string
+}
+
+// placeholderTypos finds {placeholders} that look like mistakes for a known variable:
+// misspelled names, names padded with spaces, and placeholders missing their closing brace
+// Unknown names with no close match are left to linea validate
+func placeholderTypos(s string, known map[string]string) []placeholderTypo {
+	var typos []placeholderTypo
+	start := -1
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			start = i
+		case '}':
+			if start == -1 {
+				continue
+			}
+			content := s[start+1 : i]
+			start = -1
+			if _, _, ok := parsePipeline(content); ok {
+				continue
+			}
+			name := strings.TrimSpace(content)
+			if !isVariableName(name) {
+				continue
+			}
+			if _, ok := known[name]; ok {
+				if name != content {
+					typos = append(typos, placeholderTypo{text: "{" + content + "}", replacement: "{" + name + "}", name: name,
+						message: fmt.Sprintf("placeholder '{%s}' has spaces around the name; use {%s}", content, name)})
+				}
+				continue
+			}
+			if suggestion := suggestVariable(name, known); suggestion != "" {
+				typos = append(typos, placeholderTypo{text: "{" + content + "}", replacement: "{" + suggestion + "}", name: suggestion,
+					message: fmt.Sprintf("unknown placeholder '{%s}' (did you mean {%s}?)", content, suggestion)})
+			}
+		}
+	}
+
+	// A trailing {name with no closing brace is left as literal text
+	if start != -1 {
+		if name := s[start+1:]; isVariableName(name) {
+			if _, ok := known[name]; ok {
+				typos = append(typos, placeholderTypo{text: s[start:], replacement: s[start:] + "}", name: name,
+					message: fmt.Sprintf("placeholder '{%s' is missing its closing brace", name)})
+			}
+		}
+	}
+	return typos
+}
+
+// suggestVariable returns the known variable closest to a misspelled name, or ""
+// Short names only match with a single edit so that e.g. {id} does not suggest {os}
+func suggestVariable(name string, known map[string]string) string {
+	candidates := make([]string, 0, len(known))
+	for candidate := range known {
+		candidates = append(candidates, candidate)
+	}
+	sort.Strings(candidates)
+
+	limit := 2
+	if len(name) <= 3 {
+		limit = 1
+	}
+	best, bestDistance := "", limit+1
+	for _, candidate := range candidates {
+		d := editDistance(name, candidate)
+		if strings.EqualFold(name, candidate) {
+			d = 0
+		}
+		if d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best
+}
+
+// escapingPath returns the relative path in an argument if it resolves outside projectRoot
+// Paths are resolved from the project directory, where workflows are usually run
+func escapingPath(arg, projectRoot string) string {
+	value := arg
+	if strings.HasPrefix(value, "-") {
+		// --out=../dist
+		eq := strings.Index(value, "=")
+		if eq == -1 {
+			return ""
+		}
+		value = value[eq+1:]
+	}
+	if strings.ContainsAny(value, "{$") || filepath.IsAbs(value) || !IsPathLike(value) {
+		return ""
+	}
+	clean := filepath.Clean(filepath.FromSlash(strings.ReplaceAll(value, "\\", "/")))
+	if clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return value
+	}
+	return ""
+}
+
+// commandBaseName returns a command's program name without directory or .exe suffix
+func commandBaseName(command string) string {
+	base := filepath.Base(strings.ReplaceAll(command, "\\", "/"))
+	return strings.TrimSuffix(strings.ToLower(base), ".exe")
+}
+
+// lintRule returns the rule with the given ID
+func lintRule(id string) LintRule {
+	rule, _ := LookupLintRule(id)
+	return rule
+}
+
+// mappingNode returns the value node of a key in a mapping node, or nil
+func mappingNode(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// mappingKeyNode returns the key node of a key in a mapping node, or nil
+func mappingKeyNode(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i]
+		}
+	}
+	return nil
+}
+
+// removeMappingKey deletes a key and its value from a mapping node
+func removeMappingKey(node *yaml.Node, key *yaml.Node) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i] == key {
+			node.Content = append(node.Content[:i], node.Content[i+2:]...)
+			return
+		}
+	}
+}
+
+// nodeIn reports whether node is parent or one of its direct children
+func nodeIn(node, parent *yaml.Node) bool {
+	if node == parent {
+		return true
+	}
+	for _, child := range parent.Content {
+		if child == node {
+			return true
+		}
+	}
+	return false
+}
+
+// cloneNode returns a deep copy of a YAML node
+func cloneNode(node *yaml.Node) *yaml.Node {
+	copied := *node
+	copied.Content = make([]*yaml.Node, len(node.Content))
+	for i, child := range node.Content {
+		copied.Content[i] = cloneNode(child)
+	}
+	return &copied
+}
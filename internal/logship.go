@@ -0,0 +1,512 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ShipQueueDirName is the directory inside the state directory holding run logs waiting
+// to be shipped
+const ShipQueueDirName = "ship-queue"
+
+// Log shipping limits
+const (
+	ShipBatchSize   = 20      // Runs per HTTP request
+	MaxShipAttempts = 10      // Attempts before a shipment is dropped
+	shipLogLimit    = 8 << 20 // Logs larger than this are shipped truncated to their end
+	shipTimeout     = 30 * time.Second
+)
+
+// Delays between attempts: shipRetryBase after the first failure, doubling with every
+// further failure up to shipRetryMax
+const (
+	shipRetryBase = 30 * time.Second
+	shipRetryMax  = time.Hour
+)
+
+// LogShipTarget is an entry of a workflow's ship_logs: field: an HTTP endpoint receiving
+// batches of run logs, or an S3-compatible bucket receiving one object per run
+// Values are expanded with environment variables ($VAR or ${VAR}) when shipping
+type LogShipTarget struct {
+	URL       string            `yaml:"url,omitempty" json:"url,omitempty"`               // HTTP(S) endpoint, POSTed a JSON batch
+	Headers   map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`       // HTTP request headers
+	S3        string            `yaml:"s3,omitempty" json:"s3,omitempty"`                 // s3://bucket/prefix
+	Endpoint  string            `yaml:"endpoint,omitempty" json:"endpoint,omitempty"`     // S3-compatible endpoint, e.g. https://minio:9000 (defaults to AWS)
+	Region    string            `yaml:"region,omitempty" json:"region,omitempty"`         // Defaults to AWS_REGION, then us-east-1
+	AccessKey string            `yaml:"access_key,omitempty" json:"access_key,omitempty"` // Defaults to AWS_ACCESS_KEY_ID
+	SecretKey string            `yaml:"secret_key,omitempty" json:"secret_key,omitempty"` // Defaults to AWS_SECRET_ACCESS_KEY
+}
+
+// Check reports a target that names no destination, or both kinds
+func (t LogShipTarget) Check() error {
+	switch {
+	case t.URL == "" && t.S3 == "":
+		return fmt.Errorf("needs url or s3")
+	case t.URL != "" && t.S3 != "":
+		return fmt.Errorf("has both url and s3; use one entry for each")
+	case t.S3 != "" && !strings.HasPrefix(t.S3, "s3://"):
+		return fmt.Errorf("invalid s3 location '%s' (expected s3://bucket/prefix)", t.S3)
+	case t.S3 == "" && (t.Endpoint != "" || t.Region != "" || t.AccessKey != "" || t.SecretKey != ""):
+		return fmt.Errorf("endpoint, region, access_key, and secret_key only apply to s3 entries")
+	}
+	return nil
+}
+
+// describe names the destination of a target in messages, without credentials
+func (t LogShipTarget) describe() string {
+	if t.S3 != "" {
+		return t.S3
+	}
+	if u, err := url.Parse(t.URL); err == nil {
+		u.User = nil
+		u.RawQuery = ""
+		return u.String()
+	}
+	return t.URL
+}
+
+// Shipment is a run log queued for one target
+type Shipment struct {
+	ID          string        `json:"id"`
+	Workflow    string        `json:"workflow"`
+	Path        string        `json:"path"`        // Workflow file
+	ScheduleID  string        `json:"schedule_id"` // Schedule that started the run
+	Host        string        `json:"host"`
+	LogFile     string        `json:"log_file"`
+	Success     bool          `json:"success"`
+	Error       string        `json:"error,omitempty"`
+	Start       time.Time     `json:"start"`
+	DurationMs  int64         `json:"duration_ms"`
+	Target      LogShipTarget `json:"target"`
+	Attempts    int           `json:"attempts"`
+	NextAttempt time.Time     `json:"next_attempt"`
+	LastError   string        `json:"last_error,omitempty"`
+}
+
+// shippedRun is one run in the JSON body POSTed to HTTP targets
+type shippedRun struct {
+	ID         string    `json:"id"`
+	Workflow   string    `json:"workflow"`
+	Path       string    `json:"path"`
+	ScheduleID string    `json:"schedule_id"`
+	Host       string    `json:"host"`
+	Success    bool      `json:"success"`
+	Error      string    `json:"error,omitempty"`
+	Start      time.Time `json:"start"`
+	DurationMs int64     `json:"duration_ms"`
+	Log        string    `json:"log"`
+}
+
+// ShipQueueDir returns the directory of queued shipments
+func ShipQueueDir() string {
+	return filepath.Join(UserPaths().State, ShipQueueDirName)
+}
+
+// WorkflowLogTargets returns the ship_logs: targets of a workflow file
+// The first document with ship_logs wins, like schedule:
+func WorkflowLogTargets(path string) ([]LogShipTarget, error) {
+	configs, err := ParseMultiYAML(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, config := range configs {
+		if len(config.ShipLogs) > 0 {
+			return config.ShipLogs, nil
+		}
+	}
+	return nil, nil
+}
+
+// EnqueueShipments queues a run log for every target in dir
+func EnqueueShipments(dir string, run Shipment, targets []LogShipTarget) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create ship queue: %w", err)
+	}
+	if run.Host == "" {
+		run.Host, _ = os.Hostname()
+	}
+	base := run.ID
+	for i, target := range targets {
+		shipment := run
+		shipment.Target = target
+		shipment.ID = fmt.Sprintf("%s-%d", base, i+1)
+		data, err := json.MarshalIndent(shipment, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, shipment.ID+".json"), data, 0600); err != nil {
+			return fmt.Errorf("failed to queue log shipment: %w", err)
+		}
+	}
+	return nil
+}
+
+// LogShipper sends queued shipments, batching runs for the same HTTP target and retrying
+// failed attempts with exponential backoff. The queue is on disk, so shipments survive
+// restarts of the scheduler
+type LogShipper struct {
+	Dir    string
+	Client *http.Client                                            // Defaults to a client with a 30s timeout
+	Logf   func(status Status, format string, args ...interface{}) // Receives delivery failures
+}
+
+// Flush ships every queued shipment that is due at now
+// It returns the number of shipments delivered
+func (s *LogShipper) Flush(now time.Time) int {
+	shipments := s.due(now)
+	delivered := 0
+
+	// Batch HTTP shipments by destination; S3 shipments are one object each
+	batches := map[string][]*Shipment{}
+	var keys []string
+	for _, shipment := range shipments {
+		key := shipment.ID
+		if shipment.Target.URL != "" {
+			headers, _ := json.Marshal(shipment.Target.Headers)
+			key = shipment.Target.URL + "\x00" + string(headers)
+		}
+		if _, ok := batches[key]; !ok {
+			keys = append(keys, key)
+		}
+		batches[key] = append(batches[key], shipment)
+	}
+
+	for _, key := range keys {
+		batch := batches[key]
+		for len(batch) > 0 {
+			n := len(batch)
+			if n > ShipBatchSize {
+				n = ShipBatchSize
+			}
+			chunk := batch[:n]
+			batch = batch[n:]
+
+			var err error
+			if chunk[0].Target.URL != "" {
+				err = s.postBatch(chunk)
+			} else {
+				err = s.putObject(chunk[0], now)
+			}
+			for _, shipment := range chunk {
+				if err == nil {
+					os.Remove(filepath.Join(s.Dir, shipment.ID+".json"))
+					delivered++
+					continue
+				}
+				s.retryLater(shipment, err, now)
+			}
+		}
+	}
+	return delivered
+}
+
+// QueueDepth returns the number of shipments waiting in the queue, due or not
+func (s *LogShipper) QueueDepth() int {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return 0
+	}
+	depth := 0
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".json") {
+			depth++
+		}
+	}
+	return depth
+}
+
+// due returns the queued shipments whose next attempt is at or before now, oldest first
+func (s *LogShipper) due(now time.Time) []*Shipment {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return nil
+	}
+	var shipments []*Shipment
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.Dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		var shipment Shipment
+		if err := json.Unmarshal(data, &shipment); err != nil {
+			s.logf(StatusWarning, "dropping unreadable log shipment %s: %v", entry.Name(), err)
+			os.Remove(filepath.Join(s.Dir, entry.Name()))
+			continue
+		}
+		if shipment.NextAttempt.After(now) {
+			continue
+		}
+		shipments = append(shipments, &shipment)
+	}
+	sort.Slice(shipments, func(i, j int) bool { return shipments[i].Start.Before(shipments[j].Start) })
+	return shipments
+}
+
+// retryLater records a failed attempt, dropping the shipment after MaxShipAttempts
+func (s *LogShipper) retryLater(shipment *Shipment, err error, now time.Time) {
+	path := filepath.Join(s.Dir, shipment.ID+".json")
+	shipment.Attempts++
+	shipment.LastError = MaskSecrets(err.Error())
+	if shipment.Attempts >= MaxShipAttempts {
+		s.logf(StatusError, "%s: giving up shipping log to %s after %d attempts: %v", shipment.ScheduleID, shipment.Target.describe(), shipment.Attempts, shipment.LastError)
+		os.Remove(path)
+		return
+	}
+
+	delay := shipRetryBase << uint(shipment.Attempts-1)
+	if delay > shipRetryMax || delay <= 0 {
+		delay = shipRetryMax
+	}
+	shipment.NextAttempt = now.Add(delay)
+	s.logf(StatusRetry, "%s: shipping log to %s failed (attempt %d), retrying in %s: %v", shipment.ScheduleID, shipment.Target.describe(), shipment.Attempts, delay, shipment.LastError)
+	if data, err := json.MarshalIndent(shipment, "", "  "); err == nil {
+		os.WriteFile(path, data, 0600)
+	}
+}
+
+// postBatch POSTs {"runs": [...]} to the batch's HTTP target
+func (s *LogShipper) postBatch(batch []*Shipment) error {
+	runs := make([]shippedRun, 0, len(batch))
+	for _, shipment := range batch {
+		log, err := readShippedLog(shipment.LogFile)
+		if err != nil {
+			return err
+		}
+		runs = append(runs, shippedRun{
+			ID:         shipment.ID,
+			Workflow:   shipment.Workflow,
+			Path:       shipment.Path,
+			ScheduleID: shipment.ScheduleID,
+			Host:       shipment.Host,
+			Success:    shipment.Success,
+			Error:      shipment.Error,
+			Start:      shipment.Start,
+			DurationMs: shipment.DurationMs,
+			Log:        log,
+		})
+	}
+	body, err := json.Marshal(map[string]interface{}{"runs": runs})
+	if err != nil {
+		return err
+	}
+
+	target := batch[0].Target
+	req, err := http.NewRequest(http.MethodPost, os.ExpandEnv(target.URL), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for header, value := range target.Headers {
+		req.Header.Set(header, os.ExpandEnv(value))
+	}
+	return s.do(req)
+}
+
+// putObject uploads a run log to its S3 target as <prefix><workflow>/<log file name>
+func (s *LogShipper) putObject(shipment *Shipment, now time.Time) error {
+	log, err := readShippedLog(shipment.LogFile)
+	if err != nil {
+		return err
+	}
+	target := shipment.Target
+	location := strings.TrimPrefix(os.ExpandEnv(target.S3), "s3://")
+	parts := strings.SplitN(location, "/", 2)
+	bucket, prefix := parts[0], ""
+	if len(parts) == 2 {
+		prefix = parts[1]
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	key := prefix + shipment.Workflow + "/" + filepath.Base(shipment.LogFile)
+
+	credentials := S3Credentials{
+		AccessKey:    envDefault(os.ExpandEnv(target.AccessKey), "AWS_ACCESS_KEY_ID"),
+		SecretKey:    envDefault(os.ExpandEnv(target.SecretKey), "AWS_SECRET_ACCESS_KEY"),
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		Region:       envDefault(os.ExpandEnv(target.Region), "AWS_REGION"),
+	}
+	if credentials.Region == "" {
+		credentials.Region = "us-east-1"
+	}
+	if credentials.AccessKey == "" || credentials.SecretKey == "" {
+		return fmt.Errorf("no S3 credentials (set access_key/secret_key or AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY)")
+	}
+
+	objectURL := S3ObjectURL(os.ExpandEnv(target.Endpoint), credentials.Region, bucket, key)
+	req, err := http.NewRequest(http.MethodPut, objectURL, strings.NewReader(log))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	SignS3Request(req, []byte(log), credentials, now)
+	return s.do(req)
+}
+
+// do sends a request and treats any non-2xx response as a failure
+func (s *LogShipper) do(req *http.Request) error {
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: shipTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		if message := strings.TrimSpace(string(body)); message != "" {
+			return fmt.Errorf("%s %s returned %s: %s", req.Method, req.URL.Host, resp.Status, message)
+		}
+		return fmt.Errorf("%s %s returned %s", req.Method, req.URL.Host, resp.Status)
+	}
+	return nil
+}
+
+func (s *LogShipper) logf(status Status, format string, args ...interface{}) {
+	if s.Logf != nil {
+		s.Logf(status, format, args...)
+	}
+}
+
+// readShippedLog returns a run log, keeping only its end if it is larger than shipLogLimit
+func readShippedLog(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read run log: %w", err)
+	}
+	if len(data) > shipLogLimit {
+		data = append([]byte("... (truncated)\n"), data[len(data)-shipLogLimit:]...)
+	}
+	return string(data), nil
+}
+
+// envDefault returns value, or the environment variable name if value is empty
+func envDefault(value, name string) string {
+	if value != "" {
+		return value
+	}
+	return os.Getenv(name)
+}
+
+// S3Credentials sign requests to S3-compatible storage
+type S3Credentials struct {
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	Region       string
+}
+
+// S3ObjectURL returns the URL of an object: path-style on a custom endpoint (as MinIO and
+// most S3-compatible servers expect), virtual-hosted style on AWS
+func S3ObjectURL(endpoint, region, bucket, key string) string {
+	if endpoint != "" {
+		return strings.TrimSuffix(endpoint, "/") + "/" + s3EscapePath(bucket+"/"+key)
+	}
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", bucket, region, s3EscapePath(key))
+}
+
+// SignS3Request adds AWS Signature Version 4 headers to a request with body payload
+func SignS3Request(req *http.Request, payload []byte, creds S3Credentials, now time.Time) {
+	signS3Request(req, sha256Hex(payload), creds, now)
+}
+
+// signS3Request signs a request whose body has the hex-encoded SHA-256 payloadHash, so
+// that large bodies can be streamed
+func signS3Request(req *http.Request, payloadHash string, creds S3Credentials, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           amzDate,
+	}
+	if creds.SessionToken != "" {
+		headers["x-amz-security-token"] = creds.SessionToken
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalPath := req.URL.EscapedPath()
+	if canonicalPath == "" {
+		canonicalPath = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalPath,
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + creds.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretKey), date)
+	key = hmacSHA256(key, creds.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", creds.AccessKey, scope, signedHeaders, signature))
+}
+
+// s3EscapePath percent-encodes an object path as S3 signs it: everything but unreserved
+// characters and slashes
+func s3EscapePath(p string) string {
+	var b strings.Builder
+	for _, c := range []byte(path.Clean("/" + p)[1:]) {
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '-', c == '.', c == '_', c == '~', c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,209 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MetricsAddrEnv is the default address of the metrics of linea schedule start, so that a
+// scheduler installed with linea service serves them too
+const MetricsAddrEnv = "LINEA_METRICS_ADDR"
+
+// durationBuckets are the upper bounds, in seconds, of the run duration histogram
+var durationBuckets = []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600}
+
+// Metrics counts the runs of a long-running linea process, linea serve or the scheduler,
+// for Prometheus to scrape at /metrics. A nil *Metrics counts nothing
+type Metrics struct {
+	// Queues report the number of items waiting in each queue of the process, such as the
+	// run logs waiting to be shipped, as linea_queue_depth{queue="<name>"}
+	Queues map[string]func() int
+
+	mu   sync.Mutex
+	runs map[string]*workflowMetrics
+}
+
+// workflowMetrics are the metrics of one workflow
+type workflowMetrics struct {
+	succeeded, failed, skipped int
+	running                    int
+	buckets                    []int // Runs at or under each of durationBuckets
+	durationSum                float64
+	lastRun                    time.Time
+	lastSuccess                bool
+}
+
+// RunStarted counts a run of workflow as in progress
+func (m *Metrics) RunStarted(workflow string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.workflow(workflow).running++
+}
+
+// RunFinished records the outcome of a run counted by RunStarted
+func (m *Metrics) RunFinished(workflow string, duration time.Duration, success bool) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w := m.workflow(workflow)
+	if w.running > 0 {
+		w.running--
+	}
+	if success {
+		w.succeeded++
+	} else {
+		w.failed++
+	}
+	seconds := duration.Seconds()
+	for i, bound := range durationBuckets {
+		if seconds <= bound {
+			w.buckets[i]++
+		}
+	}
+	w.durationSum += seconds
+	w.lastRun, w.lastSuccess = time.Now(), success
+}
+
+// RunSkipped counts a run of workflow that was not started, such as a scheduled run
+// while the previous one is still going
+func (m *Metrics) RunSkipped(workflow string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.workflow(workflow).skipped++
+}
+
+// workflow returns the metrics of a workflow, creating them; the caller holds m.mu
+func (m *Metrics) workflow(name string) *workflowMetrics {
+	if m.runs == nil {
+		m.runs = make(map[string]*workflowMetrics)
+	}
+	w := m.runs[name]
+	if w == nil {
+		w = &workflowMetrics{buckets: make([]int, len(durationBuckets))}
+		m.runs[name] = w
+	}
+	return w
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteText(w)
+}
+
+// WriteText writes the metrics in the Prometheus text exposition format
+func (m *Metrics) WriteText(out io.Writer) {
+	if m == nil {
+		return
+	}
+	queues := make(map[string]int, len(m.Queues))
+	for name, depth := range m.Queues {
+		queues[name] = depth()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.runs))
+	for name := range m.runs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	metric := func(name, kind, help string, values func(emit func(labels string, value float64))) {
+		fmt.Fprintf(out, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+		values(func(labels string, value float64) {
+			fmt.Fprintf(out, "%s%s %s\n", name, labels, strconv.FormatFloat(value, 'g', -1, 64))
+		})
+	}
+	perWorkflow := func(value func(w *workflowMetrics) (float64, bool)) func(func(string, float64)) {
+		return func(emit func(string, float64)) {
+			for _, name := range names {
+				if v, ok := value(m.runs[name]); ok {
+					emit(metricLabels("workflow", name), v)
+				}
+			}
+		}
+	}
+
+	metric("linea_runs_total", "counter", "Finished workflow runs by status", func(emit func(string, float64)) {
+		for _, name := range names {
+			w := m.runs[name]
+			emit(metricLabels("workflow", name, "status", StepSucceeded), float64(w.succeeded))
+			emit(metricLabels("workflow", name, "status", StepFailed), float64(w.failed))
+		}
+	})
+	metric("linea_runs_skipped_total", "counter", "Runs not started because the previous run was still in progress", perWorkflow(func(w *workflowMetrics) (float64, bool) {
+		return float64(w.skipped), true
+	}))
+	metric("linea_runs_in_progress", "gauge", "Workflow runs in progress", perWorkflow(func(w *workflowMetrics) (float64, bool) {
+		return float64(w.running), true
+	}))
+	metric("linea_run_duration_seconds", "histogram", "Duration of finished workflow runs", func(emit func(string, float64)) {
+		sample := func(suffix, labels string, value float64) {
+			fmt.Fprintf(out, "linea_run_duration_seconds_%s%s %s\n", suffix, labels, strconv.FormatFloat(value, 'g', -1, 64))
+		}
+		for _, name := range names {
+			w := m.runs[name]
+			for i, bound := range durationBuckets {
+				sample("bucket", metricLabels("workflow", name, "le", strconv.FormatFloat(bound, 'g', -1, 64)), float64(w.buckets[i]))
+			}
+			count := float64(w.succeeded + w.failed)
+			sample("bucket", metricLabels("workflow", name, "le", "+Inf"), count)
+			sample("sum", metricLabels("workflow", name), w.durationSum)
+			sample("count", metricLabels("workflow", name), count)
+		}
+	})
+	metric("linea_last_run_timestamp_seconds", "gauge", "Unix time the last run of the workflow finished", perWorkflow(func(w *workflowMetrics) (float64, bool) {
+		return float64(w.lastRun.UnixNano()) / 1e9, !w.lastRun.IsZero()
+	}))
+	metric("linea_last_run_success", "gauge", "1 if the last run of the workflow succeeded, 0 if it failed", perWorkflow(func(w *workflowMetrics) (float64, bool) {
+		if w.lastSuccess {
+			return 1, !w.lastRun.IsZero()
+		}
+		return 0, !w.lastRun.IsZero()
+	}))
+	metric("linea_queue_depth", "gauge", "Items waiting in a queue of the process", func(emit func(string, float64)) {
+		for _, name := range sortedIntMapKeys(queues) {
+			emit(metricLabels("queue", name), float64(queues[name]))
+		}
+	})
+}
+
+// metricLabels formats label name and value pairs as {name="value",...}
+func metricLabels(pairs ...string) string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(pairs[i+1])
+		fmt.Fprintf(&b, `%s="%s"`, pairs[i], value)
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// sortedIntMapKeys returns the keys of a map in sorted order
+func sortedIntMapKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,129 @@
+package internal
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Output streams passed to OnOutputLine
+const (
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+)
+
+// StepInfo identifies the step a middleware is called for
+type StepInfo struct {
+	Workflow string
+	Index    int // 1-based
+	Steps    int // Number of steps in the run
+	Config   *CommandConfig
+	Command  []string // Secret values are masked; nil if the command was not built
+}
+
+// Middleware adds behavior around the steps of a run. RunStepsReport calls the
+// middleware of ReportOptions in order; the progress events of --progress-fd are one,
+// and programs add their own through pkg/linea. Any function may be nil
+type Middleware struct {
+	// BeforeStep is called once a step's command is built, before it runs. An error
+	// fails the step without running it, like a command policy
+	BeforeStep func(step StepInfo) error
+
+	// AfterStep is called after every step, including those skipped or not run
+	AfterStep func(step StepInfo, result StepResult)
+
+	// OnOutputLine is called with each line the command writes, without its line ending
+	// and with secret values masked. Calls are made one at a time, but not from the
+	// goroutine running the steps
+	OnOutputLine func(step StepInfo, stream, line string)
+}
+
+// middlewareChain is the middleware of a run, in the order they are called
+type middlewareChain []Middleware
+
+// before calls the BeforeStep of every middleware, stopping at the first error
+func (c middlewareChain) before(step StepInfo) error {
+	for _, m := range c {
+		if m.BeforeStep != nil {
+			if err := m.BeforeStep(step); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// after calls the AfterStep of every middleware
+func (c middlewareChain) after(step StepInfo, result StepResult) {
+	for _, m := range c {
+		if m.AfterStep != nil {
+			m.AfterStep(step, result)
+		}
+	}
+}
+
+// outputWriters wraps the output of a step so that each line also reaches the
+// OnOutputLine of the middleware. flush passes on a last line without a line ending once
+// the command has exited
+func (c middlewareChain) outputWriters(step StepInfo, stdout, stderr io.Writer) (io.Writer, io.Writer, func()) {
+	var handlers []func(StepInfo, string, string)
+	for _, m := range c {
+		if m.OnOutputLine != nil {
+			handlers = append(handlers, m.OnOutputLine)
+		}
+	}
+	if len(handlers) == 0 {
+		return stdout, stderr, func() {}
+	}
+
+	var mu sync.Mutex
+	emit := func(stream, line string) {
+		mu.Lock()
+		defer mu.Unlock()
+		line = MaskSecrets(strings.TrimSuffix(line, "\r"))
+		for _, handle := range handlers {
+			handle(step, stream, line)
+		}
+	}
+	out := &lineWriter{w: stdout, emit: func(line string) { emit(StreamStdout, line) }}
+	errOut := &lineWriter{w: stderr, emit: func(line string) { emit(StreamStderr, line) }}
+	return out, errOut, func() {
+		out.flush()
+		errOut.flush()
+	}
+}
+
+// lineWriter writes to w and calls emit with every complete line written
+type lineWriter struct {
+	w       io.Writer
+	emit    func(line string)
+	mu      sync.Mutex
+	partial bytes.Buffer
+}
+
+func (l *lineWriter) Write(p []byte) (int, error) {
+	n, err := l.w.Write(p)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.partial.Write(p[:n])
+	for {
+		i := bytes.IndexByte(l.partial.Bytes(), '\n')
+		if i < 0 {
+			break
+		}
+		line := string(l.partial.Next(i + 1))
+		l.emit(line[:i])
+	}
+	return n, err
+}
+
+// flush emits the rest of the output, if it does not end with a line ending
+func (l *lineWriter) flush() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.partial.Len() > 0 {
+		l.emit(l.partial.String())
+		l.partial.Reset()
+	}
+}
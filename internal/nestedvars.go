@@ -0,0 +1,194 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MaxVariableDepth is how deeply variables may be composed of other variables, as in
+// image: "{registry}/{app}:{tag}" where registry is itself "{host}:{port}"
+const MaxVariableDepth = 10
+
+// variableNode is a variable as one syntax sees it: {name} reads the YAML variables and
+// $name the YAML variables overridden by -s/--set, so the two can have different values
+type variableNode struct {
+	dollar bool
+	name   string
+}
+
+// String returns the node as written in a workflow
+func (n variableNode) String() string {
+	if n.dollar {
+		return "$" + n.name
+	}
+	return "{" + n.name + "}"
+}
+
+// resolveNestedVariables substitutes the references that variable values make to other
+// variables, in the order they depend on each other, so that a value is complete before
+// it is used whatever order the variables are declared in. Only the values of the
+// variables in templates are substituted: built-ins, secrets, and fetched values are
+// used as they are. References to undefined variables are left for ValidateVariables to
+// report; a cycle, or a chain deeper than MaxVariableDepth, is an error. The maps are
+// updated in place
+func resolveNestedVariables(yamlVars, dollarVars map[string]string, templates map[variableNode]bool) error {
+	nested := false
+	for n := range templates {
+		value, _ := variableValue(yamlVars, dollarVars, n)
+		nested = nested || strings.ContainsAny(value, "{$")
+	}
+	if !nested {
+		return nil
+	}
+
+	done := make(map[variableNode]bool)
+	var path []variableNode
+	var resolve func(n variableNode) error
+	resolve = func(n variableNode) error {
+		if done[n] || !templates[n] {
+			return nil
+		}
+		for i, seen := range path {
+			if seen == n {
+				return fmt.Errorf("variable cycle: %s", variableChain(append(path[i:], n)))
+			}
+		}
+		if len(path) >= MaxVariableDepth {
+			return fmt.Errorf("variables nested more than %d deep: %s", MaxVariableDepth, variableChain(append(path, n)))
+		}
+
+		value, _ := variableValue(yamlVars, dollarVars, n)
+		path = append(path, n)
+		for _, dep := range variableDependencies(value) {
+			if err := resolve(dep); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+
+		if strings.ContainsAny(value, "{$") {
+			value = SubstituteVariablesWithSeparateMaps(value, yamlVars, dollarVars)
+			if n.dollar {
+				dollarVars[n.name] = value
+			} else {
+				yamlVars[n.name] = value
+			}
+		}
+		done[n] = true
+		return nil
+	}
+
+	nodes := make([]variableNode, 0, len(templates))
+	for n := range templates {
+		nodes = append(nodes, n)
+	}
+	// Sorted, {name} before $name, so that the error for a cycle is always the same
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].dollar != nodes[j].dollar {
+			return !nodes[i].dollar
+		}
+		return nodes[i].name < nodes[j].name
+	})
+	for _, n := range nodes {
+		if err := resolve(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckNestedVariables reports a cycle among the variables: of a step, or a chain of
+// them deeper than MaxVariableDepth
+func CheckNestedVariables(config *CommandConfig) error {
+	yamlVars := make(map[string]string, len(config.Variables))
+	dollarVars := make(map[string]string, len(config.Variables))
+	for k, v := range config.Variables {
+		yamlVars[k], dollarVars[k] = v, v
+	}
+	return resolveNestedVariables(yamlVars, dollarVars, variableTemplates(config, nil))
+}
+
+// variableTemplates returns the variables of a step whose values may reference other
+// variables: those under variables: and the -s/--set values. Secrets and the values of
+// providers are used as they are
+func variableTemplates(config *CommandConfig, overrideVars map[string]string) map[variableNode]bool {
+	templates := make(map[variableNode]bool, 2*len(config.Variables)+len(overrideVars))
+	for k := range config.Variables {
+		if _, secret := config.Secrets[k]; !secret {
+			templates[variableNode{name: k}] = true
+			templates[variableNode{dollar: true, name: k}] = true
+		}
+	}
+	for k := range overrideVars {
+		templates[variableNode{dollar: true, name: k}] = true
+	}
+	return templates
+}
+
+// variableValue returns the value of a variable in the map of its syntax
+func variableValue(yamlVars, dollarVars map[string]string, n variableNode) (string, bool) {
+	if n.dollar {
+		v, ok := dollarVars[n.name]
+		return v, ok
+	}
+	v, ok := yamlVars[n.name]
+	return v, ok
+}
+
+// variableDependencies returns the variables a value references: {name} (and the name
+// of {name[0]} or {name|func}) as a YAML variable, and $name or ${name} as a $ variable
+func variableDependencies(s string) []variableNode {
+	var deps []variableNode
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '$' && i+1 < len(s) && s[i+1] == '{':
+			end := strings.IndexByte(s[i:], '}')
+			if end == -1 {
+				return deps
+			}
+			deps = append(deps, variableNode{dollar: true, name: referenceName(s[i+2 : i+end])})
+			i += end
+		case s[i] == '$':
+			j := i + 1
+			for j < len(s) && isVariableName(s[i+1:j+1]) {
+				j++
+			}
+			if j > i+1 {
+				deps = append(deps, variableNode{dollar: true, name: s[i+1 : j]})
+				i = j - 1
+			}
+		case s[i] == '{':
+			end := strings.IndexByte(s[i:], '}')
+			if end == -1 {
+				return deps
+			}
+			if next := strings.IndexByte(s[i+1:i+end], '{'); next != -1 {
+				// The reference starts at the innermost {
+				continue
+			}
+			if ref := s[i+1 : i+end]; ref != "" && !strings.HasPrefix(ref, "\"") {
+				deps = append(deps, variableNode{name: referenceName(ref)})
+			}
+			i += end
+		}
+	}
+	return deps
+}
+
+// referenceName returns the variable named by the inside of a {...} reference
+func referenceName(ref string) string {
+	if name, _, ok := parsePipeline(ref); ok {
+		ref = name
+	}
+	return variableReferenceName(ref)
+}
+
+// variableChain formats a chain of variables such as {image} -> {tag} -> {image}
+func variableChain(nodes []variableNode) string {
+	parts := make([]string, len(nodes))
+	for i, n := range nodes {
+		parts[i] = n.String()
+	}
+	return strings.Join(parts, " -> ")
+}
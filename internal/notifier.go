@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// NotifierTimeout bounds how long a notifier may take to deliver one notification
+const NotifierTimeout = 30 * time.Second
+
+// Notifier is told about every finished run, to report it on a channel of its own
+// (PagerDuty, Microsoft Teams, a database, ...). Programs embedding the engine register
+// their notifiers with RegisterNotifier instead of changing the built-in ones
+// The report has secret values masked; ctx is cancelled after NotifierTimeout
+type Notifier interface {
+	Notify(ctx context.Context, report *RunReport) error
+}
+
+// NotifierFunc adapts a function to the Notifier interface
+type NotifierFunc func(ctx context.Context, report *RunReport) error
+
+// Notify calls f
+func (f NotifierFunc) Notify(ctx context.Context, report *RunReport) error {
+	return f(ctx, report)
+}
+
+var (
+	notifiersMu sync.Mutex
+	notifiers   = map[string]Notifier{}
+)
+
+// RegisterNotifier adds a notifier called name, replacing any notifier with the same
+// name; a nil notifier removes it
+func RegisterNotifier(name string, n Notifier) {
+	notifiersMu.Lock()
+	defer notifiersMu.Unlock()
+	if n == nil {
+		delete(notifiers, name)
+		return
+	}
+	notifiers[name] = n
+}
+
+// NotifierNames returns the names of the registered notifiers in sorted order
+func NotifierNames() []string {
+	notifiersMu.Lock()
+	defer notifiersMu.Unlock()
+	names := make([]string, 0, len(notifiers))
+	for name := range notifiers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NotifyRun passes the report of a finished run to every registered notifier, in name
+// order, and returns the errors of those that failed. Notifiers never fail the run: a
+// panic is returned as an error like any other failure
+func NotifyRun(report *RunReport) []error {
+	var errs []error
+	for _, name := range NotifierNames() {
+		notifiersMu.Lock()
+		n := notifiers[name]
+		notifiersMu.Unlock()
+		if n == nil {
+			continue
+		}
+		if err := notify(name, n, report); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// notify calls one notifier with a timeout, recovering from a panic
+func notify(name string, n Notifier, report *RunReport) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), NotifierTimeout)
+	defer cancel()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("notifier %s: panic: %v", name, r)
+		}
+	}()
+	if err := n.Notify(ctx, report); err != nil {
+		return fmt.Errorf("notifier %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,472 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StepTypeNotify is the type of the steps that send a notification
+const StepTypeNotify = "notify"
+
+// Outcomes of a run a notification: entry is sent on
+const (
+	NotifyOnSuccess = "success"
+	NotifyOnFailure = "failure"
+)
+
+// discordMessageLimit is the longest message Discord accepts, in characters
+const discordMessageLimit = 2000
+
+// NotificationTarget is an entry of a workflow's notifications: field, or the notify: of a
+// `type: notify` step: a Slack or Discord incoming webhook, a generic webhook receiving
+// JSON, or an email sent over SMTP
+// In notifications:, values are expanded with environment variables ($VAR or ${VAR})
+// when sending, and message placeholders describe the run
+type NotificationTarget struct {
+	Slack   string            `yaml:"slack,omitempty"`   // Incoming webhook URL
+	Discord string            `yaml:"discord,omitempty"` // Webhook URL
+	Webhook string            `yaml:"webhook,omitempty"` // URL POSTed {"text": ..., "run": ...}
+	Headers map[string]string `yaml:"headers,omitempty"` // webhook: HTTP request headers
+	Email   *EmailTarget      `yaml:"email,omitempty"`
+	Message string            `yaml:"message,omitempty"` // Text of the notification
+	On      StringList        `yaml:"on,omitempty"`      // notifications: success, failure, or both (default)
+}
+
+// EmailTarget is an email notification sent through an SMTP server
+type EmailTarget struct {
+	SMTP     string     `yaml:"smtp,omitempty"` // host:port; port 465 uses TLS, others STARTTLS when offered
+	Username string     `yaml:"username,omitempty"`
+	Password string     `yaml:"password,omitempty"`
+	From     string     `yaml:"from,omitempty"`
+	To       StringList `yaml:"to,omitempty"`
+	Subject  string     `yaml:"subject,omitempty"`
+}
+
+// Check reports a target that names no destination or several, or misses fields they
+// need; step is set for the notify: of a step, which needs a message and has no on:
+func (t NotificationTarget) Check(step bool) error {
+	destinations := 0
+	for _, set := range []bool{t.Slack != "", t.Discord != "", t.Webhook != "", t.Email != nil} {
+		if set {
+			destinations++
+		}
+	}
+	switch {
+	case destinations == 0:
+		return fmt.Errorf("needs slack, discord, webhook, or email")
+	case destinations > 1:
+		return fmt.Errorf("has several of slack, discord, webhook, and email; use one entry for each")
+	case len(t.Headers) > 0 && t.Webhook == "":
+		return fmt.Errorf("headers only apply to webhook")
+	case t.Email != nil && (t.Email.SMTP == "" || t.Email.From == "" || len(t.Email.To) == 0):
+		return fmt.Errorf("email needs smtp, from, and to")
+	case t.Email != nil && t.Email.Username == "" && t.Email.Password != "":
+		return fmt.Errorf("email password needs a username")
+	case step && t.Message == "":
+		return fmt.Errorf("needs a message")
+	case step && len(t.On) > 0:
+		return fmt.Errorf("on only applies to notifications:")
+	}
+	if t.Email != nil && !strings.ContainsAny(t.Email.SMTP, "{$") {
+		if _, _, err := net.SplitHostPort(t.Email.SMTP); err != nil {
+			return fmt.Errorf("invalid smtp '%s' (expected host:port)", t.Email.SMTP)
+		}
+	}
+	for _, on := range t.On {
+		if on != NotifyOnSuccess && on != NotifyOnFailure {
+			return fmt.Errorf("invalid on '%s' (expected success or failure)", on)
+		}
+	}
+	return nil
+}
+
+// sendsOn reports whether the target is notified of a run that succeeded or failed
+func (t NotificationTarget) sendsOn(success bool) bool {
+	if len(t.On) == 0 {
+		return true
+	}
+	want := NotifyOnFailure
+	if success {
+		want = NotifyOnSuccess
+	}
+	for _, on := range t.On {
+		if on == want {
+			return true
+		}
+	}
+	return false
+}
+
+// describe names the destination of a target in messages, without the secret parts of
+// webhook URLs
+func (t NotificationTarget) describe() string {
+	host := func(rawURL string) string {
+		if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+			return " (" + u.Host + ")"
+		}
+		return ""
+	}
+	switch {
+	case t.Slack != "":
+		return "slack" + host(t.Slack)
+	case t.Discord != "":
+		return "discord" + host(t.Discord)
+	case t.Webhook != "":
+		return "webhook" + host(t.Webhook)
+	case t.Email != nil:
+		return "email to " + strings.Join(t.Email.To, ", ")
+	}
+	return "notification"
+}
+
+// expand returns the target with environment variables expanded in its values
+func (t NotificationTarget) expand() NotificationTarget {
+	t.Slack, t.Discord, t.Webhook = os.ExpandEnv(t.Slack), os.ExpandEnv(t.Discord), os.ExpandEnv(t.Webhook)
+	if len(t.Headers) > 0 {
+		headers := make(map[string]string, len(t.Headers))
+		for name, value := range t.Headers {
+			headers[name] = os.ExpandEnv(value)
+		}
+		t.Headers = headers
+	}
+	if t.Email != nil {
+		email := *t.Email
+		email.SMTP, email.Username, email.Password, email.From = os.ExpandEnv(email.SMTP), os.ExpandEnv(email.Username), os.ExpandEnv(email.Password), os.ExpandEnv(email.From)
+		email.To = make(StringList, len(t.Email.To))
+		for i, to := range t.Email.To {
+			email.To[i] = os.ExpandEnv(to)
+		}
+		t.Email = &email
+	}
+	return t
+}
+
+// send delivers a notification; run is the report included in webhook bodies, if any
+func (t NotificationTarget) send(ctx context.Context, subject, text string, run *RunReport) error {
+	switch {
+	case t.Slack != "":
+		return postJSON(ctx, t.Slack, nil, map[string]string{"text": text})
+	case t.Discord != "":
+		if runes := []rune(text); len(runes) > discordMessageLimit {
+			text = string(runes[:discordMessageLimit-1]) + "…"
+		}
+		return postJSON(ctx, t.Discord, nil, map[string]string{"content": text})
+	case t.Webhook != "":
+		return postJSON(ctx, t.Webhook, t.Headers, struct {
+			Text string     `json:"text"`
+			Run  *RunReport `json:"run,omitempty"`
+		}{text, run})
+	case t.Email != nil:
+		return sendEmail(ctx, t.Email, subject, text)
+	}
+	return fmt.Errorf("no destination")
+}
+
+// postJSON POSTs body as JSON, failing on a response other than 2xx
+func postJSON(ctx context.Context, rawURL string, headers map[string]string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rawURL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		// The error holds the URL, whose path is the secret of Slack and Discord webhooks
+		if urlErr, ok := err.(*url.Error); ok {
+			return urlErr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		if text := strings.TrimSpace(string(message)); text != "" {
+			return fmt.Errorf("returned %s: %s", resp.Status, text)
+		}
+		return fmt.Errorf("returned %s", resp.Status)
+	}
+	return nil
+}
+
+// sendEmail sends a plain text email. Port 465 connects with TLS; on other ports the
+// connection is upgraded with STARTTLS when the server offers it
+func sendEmail(ctx context.Context, email *EmailTarget, subject, text string) error {
+	host, port, err := net.SplitHostPort(email.SMTP)
+	if err != nil {
+		return fmt.Errorf("invalid smtp '%s' (expected host:port)", email.SMTP)
+	}
+	var conn net.Conn
+	if port == "465" {
+		conn, err = (&tls.Dialer{Config: &tls.Config{ServerName: host}}).DialContext(ctx, "tcp", email.SMTP)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", email.SMTP)
+	}
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if ok, _ := client.Extension("STARTTLS"); ok && port != "465" {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if email.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", email.Username, email.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(email.From); err != nil {
+		return err
+	}
+	for _, to := range email.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\n", email.From, strings.Join(email.To, ", "), mime.QEncoding.Encode("utf-8", subject), time.Now().Format(time.RFC1123Z))
+	message.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	message.WriteString(strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\n", "\r\n"))
+	message.WriteString("\r\n")
+	if _, err := w.Write(message.Bytes()); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// WorkflowNotifications returns the notifications: of a workflow file
+// The first document with notifications wins, like ship_logs:
+func WorkflowNotifications(path string) ([]NotificationTarget, error) {
+	configs, err := ParseMultiYAML(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, config := range configs {
+		if len(config.Notifications) > 0 {
+			return config.Notifications, nil
+		}
+	}
+	return nil, nil
+}
+
+// NotifyWorkflow sends the notifications: of a finished run's workflow that apply to
+// its outcome, and returns the errors of those that could not be delivered. Like
+// notifiers, they never fail the run and each has NotifierTimeout to be delivered
+func NotifyWorkflow(report *RunReport) []error {
+	targets, err := WorkflowNotifications(report.Path)
+	if err != nil || len(targets) == 0 {
+		return nil
+	}
+	vars := runNotificationVariables(report)
+	success := report.Status != StepFailed
+	var errs []error
+	for _, target := range targets {
+		if !target.sendsOn(success) {
+			continue
+		}
+		target = target.expand()
+		text := runNotificationText(vars, target.Message)
+		subject := "[linea] " + vars["workflow"] + " " + vars["outcome"]
+		if target.Email != nil && target.Email.Subject != "" {
+			subject = runNotificationText(vars, target.Email.Subject)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), NotifierTimeout)
+		err := target.send(ctx, subject, text, report)
+		cancel()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("notification %s: %s", target.describe(), MaskSecrets(err.Error())))
+		}
+	}
+	return errs
+}
+
+// runNotificationVariables returns the placeholders of notification messages for a run
+func runNotificationVariables(report *RunReport) map[string]string {
+	vars := map[string]string{
+		"workflow":  report.Workflow,
+		"path":      report.Path,
+		"status":    report.Status,
+		"outcome":   "succeeded",
+		"exit_code": strconv.Itoa(report.ExitCode),
+		"error":     report.Error,
+		"duration":  roundedDuration(report.DurationMs),
+		"run_id":    report.RunID,
+		"steps":     stepSummaryLines(report.Steps),
+	}
+	if report.Status == StepFailed {
+		vars["outcome"] = "failed"
+	}
+	vars["host"], _ = os.Hostname()
+	return vars
+}
+
+// runNotificationText renders a notification message with the variables of a run; an
+// empty message gets the default one: the outcome, then one line per step
+func runNotificationText(vars map[string]string, message string) string {
+	if message == "" {
+		message = "✅ {workflow} succeeded on {host} in {duration}"
+		if vars["status"] == StepFailed {
+			message = "❌ {workflow} failed on {host} in {duration}: {error}"
+		}
+		if vars["steps"] != "" {
+			message += "\n{steps}"
+		}
+	}
+	return SubstituteVariablesWithSeparateMaps(message, vars, nil)
+}
+
+// stepSummaryLines describes the steps of a run, one line each
+func stepSummaryLines(steps []StepResult) string {
+	lines := make([]string, 0, len(steps))
+	for _, step := range steps {
+		label := strconv.Itoa(step.Index)
+		if step.Name != "" {
+			label += " " + step.Name
+		}
+		switch step.Status {
+		case StepSucceeded:
+			lines = append(lines, fmt.Sprintf("✓ %s (%s)", label, roundedDuration(step.DurationMs)))
+		case StepFailed:
+			lines = append(lines, fmt.Sprintf("✗ %s (exit code %d, %s)", label, step.ExitCode, roundedDuration(step.DurationMs)))
+		default:
+			lines = append(lines, fmt.Sprintf("- %s (%s)", label, strings.ReplaceAll(step.Status, "_", " ")))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// roundedDuration formats a duration in milliseconds to a tenth of a second
+func roundedDuration(ms int64) string {
+	d := time.Duration(ms) * time.Millisecond
+	if d < time.Second {
+		return d.String()
+	}
+	return d.Round(100 * time.Millisecond).String()
+}
+
+// CheckNotifyStep validates the notify: of a step
+func CheckNotifyStep(config *CommandConfig) error {
+	if config.Notify != nil && config.Type != StepTypeNotify {
+		return fmt.Errorf("notify: requires type: notify")
+	}
+	if config.Type != StepTypeNotify {
+		return nil
+	}
+	if config.Notify == nil {
+		return fmt.Errorf("type: notify needs a notify: with a destination and a message")
+	}
+	if err := config.Notify.Check(true); err != nil {
+		return fmt.Errorf("notify: %v", err)
+	}
+	return nil
+}
+
+// notifyArgs turns the notify: of a step into the arguments of runNotify
+func notifyArgs(config *CommandConfig) ([]string, error) {
+	if err := CheckNotifyStep(config); err != nil {
+		return nil, err
+	}
+	n := config.Notify
+	args := []string{"--message", n.Message}
+	for _, option := range [][2]string{{"--slack", n.Slack}, {"--discord", n.Discord}, {"--webhook", n.Webhook}} {
+		if option[1] != "" {
+			args = append(args, option[0], option[1])
+		}
+	}
+	args = append(args, headerArgs(n.Headers)...)
+	if e := n.Email; e != nil {
+		for _, option := range [][2]string{{"--smtp", e.SMTP}, {"--username", e.Username}, {"--password", e.Password}, {"--from", e.From}, {"--subject", e.Subject}} {
+			if option[1] != "" {
+				args = append(args, option[0], option[1])
+			}
+		}
+		for _, to := range e.To {
+			args = append(args, "--to", to)
+		}
+	}
+	return args, nil
+}
+
+// runNotify sends the notification of a notify step
+func runNotify(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet(StepTypeNotify, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	var target NotificationTarget
+	var email EmailTarget
+	var headers, to listFlag
+	flags.StringVar(&target.Message, "message", "", "")
+	flags.StringVar(&target.Slack, "slack", "", "")
+	flags.StringVar(&target.Discord, "discord", "", "")
+	flags.StringVar(&target.Webhook, "webhook", "", "")
+	flags.Var(&headers, "header", "")
+	flags.StringVar(&email.SMTP, "smtp", "", "")
+	flags.StringVar(&email.Username, "username", "", "")
+	flags.StringVar(&email.Password, "password", "", "")
+	flags.StringVar(&email.From, "from", "", "")
+	flags.StringVar(&email.Subject, "subject", "", "")
+	flags.Var(&to, "to", "")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("notify: %w", err)
+	}
+	if len(headers) > 0 {
+		target.Headers = map[string]string{}
+		for _, header := range headers {
+			name, value, _ := strings.Cut(header, ":")
+			target.Headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+	}
+	subject := "[linea] notification"
+	if email.SMTP != "" {
+		email.To = StringList(to)
+		target.Email = &email
+		if email.Subject != "" {
+			subject = email.Subject
+		}
+	}
+
+	run, stop := transferContext()
+	defer stop()
+	ctx, cancel := context.WithTimeout(run, NotifierTimeout)
+	defer cancel()
+	if err := target.send(ctx, subject, target.Message, nil); err != nil {
+		return fileStepError(StepTypeNotify, fmt.Errorf("%s: %s", target.describe(), MaskSecrets(err.Error())))
+	}
+	fmt.Fprintf(stdout, "notify: sent to %s\n", target.describe())
+	return nil
+}
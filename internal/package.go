@@ -0,0 +1,387 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PackageFileName is the metadata file at the root of a workflow package
+const PackageFileName = "linea-package.yml"
+
+// PackageWorkflowsDir is the directory of a package holding its workflows; the package's
+// other files (scripts, templates) are installed next to it
+const PackageWorkflowsDir = "workflows"
+
+// RegistryEnv overrides the registry setting: the URL of the package index
+const RegistryEnv = "LINEA_REGISTRY"
+
+// PackageMeta is the content of a package's linea-package.yml
+type PackageMeta struct {
+	Name        string `yaml:"name" json:"name"`
+	Version     string `yaml:"version" json:"version"`
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+
+	Dir string `yaml:"-" json:"-"` // Where the package is, once installed or read
+}
+
+// PackageIndex is the document served by a registry: every version of every package
+type PackageIndex struct {
+	Packages []PackageEntry `json:"packages"`
+}
+
+// PackageEntry is one version of a package in a registry's index
+type PackageEntry struct {
+	Name        string `json:"name"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+	URL         string `json:"url"`              // Of the .tar.gz, relative to the index or absolute
+	SHA256      string `json:"sha256,omitempty"` // Checked when the package is installed
+}
+
+// PackagesDir returns the directory installed packages are unpacked into, one
+// subdirectory per package
+func PackagesDir() string {
+	return filepath.Join(UserPaths().Data, "packages")
+}
+
+// isPackageName reports whether name is a valid package name: lowercase letters, digits,
+// '-', '_', and '.', not starting with '.'
+func isPackageName(name string) bool {
+	if name == "" || name[0] == '.' {
+		return false
+	}
+	for _, c := range name {
+		if !((c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// ReadPackage reads the linea-package.yml of the package in dir and checks that it has
+// workflows
+func ReadPackage(dir string) (*PackageMeta, error) {
+	data, err := os.ReadFile(filepath.Join(dir, PackageFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%s is not a package: %s not found", dir, PackageFileName)
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", PackageFileName, err)
+	}
+	meta := &PackageMeta{Dir: dir}
+	if err := yaml.Unmarshal(data, meta); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filepath.Join(dir, PackageFileName), err)
+	}
+	if !isPackageName(meta.Name) {
+		return nil, fmt.Errorf("%s: invalid package name '%s' (use lowercase letters, digits, '-', '_', and '.')", filepath.Join(dir, PackageFileName), meta.Name)
+	}
+	if strings.TrimSpace(meta.Version) == "" {
+		return nil, fmt.Errorf("%s: package '%s' has no version", filepath.Join(dir, PackageFileName), meta.Name)
+	}
+	workflows, err := IndexWorkflows(filepath.Join(dir, PackageWorkflowsDir))
+	if err != nil || len(workflows) == 0 {
+		return nil, fmt.Errorf("package '%s' has no workflows in %s/", meta.Name, PackageWorkflowsDir)
+	}
+	return meta, nil
+}
+
+// BuildPackage packs the package in dir into a .tar.gz, out or <name>-<version>.tar.gz
+// in the current directory, after validating its workflows. Returns the archive's path
+func BuildPackage(dir, out string) (string, *PackageMeta, error) {
+	meta, err := ReadPackage(dir)
+	if err != nil {
+		return "", nil, err
+	}
+	workflows, _ := IndexWorkflows(filepath.Join(dir, PackageWorkflowsDir))
+	for _, workflow := range workflows {
+		problems, err := ValidateWorkflowDefinition(workflow.Path)
+		if err != nil {
+			return "", nil, err
+		}
+		if len(problems) > 0 {
+			lines := make([]string, len(problems))
+			for i, problem := range problems {
+				lines[i] = "  " + problem.String()
+			}
+			return "", nil, fmt.Errorf("%s is not a valid workflow:\n%s", workflow.Path, strings.Join(lines, "\n"))
+		}
+	}
+
+	if out == "" {
+		out = meta.Name + "-" + meta.Version + ".tar.gz"
+	}
+	files, err := archiveFiles(dir, out, nil, []string{".git", ".linea", "*.tar.gz"})
+	if err != nil {
+		return "", nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+		return "", nil, err
+	}
+	f, err := os.Create(out)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := writeTar(f, dir, files, true); err != nil {
+		f.Close()
+		os.Remove(out)
+		return "", nil, fmt.Errorf("failed to write %s: %w", out, err)
+	}
+	return out, meta, f.Close()
+}
+
+// RegistryURL returns the URL of the package index: LINEA_REGISTRY, or the registry
+// setting of the global configuration
+func RegistryURL() string {
+	if registry := os.Getenv(RegistryEnv); registry != "" {
+		return registry
+	}
+	return CurrentUserConfig().Registry
+}
+
+// FetchPackageIndex downloads the package index of the registry
+func FetchPackageIndex() (*PackageIndex, string, error) {
+	registry := RegistryURL()
+	if registry == "" {
+		return nil, "", fmt.Errorf("no registry configured (set one with: linea config set registry <url>)")
+	}
+	var data strings.Builder
+	if err := httpGet(registry, &data); err != nil {
+		return nil, "", fmt.Errorf("failed to fetch the package index: %w", err)
+	}
+	index := &PackageIndex{}
+	if err := json.Unmarshal([]byte(data.String()), index); err != nil {
+		return nil, "", fmt.Errorf("invalid package index at %s: %w", redactURL(registry), err)
+	}
+	return index, registry, nil
+}
+
+// Latest returns the newest version of a package in the index, or the given version
+func (index *PackageIndex) Latest(name, version string) (*PackageEntry, error) {
+	var found *PackageEntry
+	for i := range index.Packages {
+		entry := &index.Packages[i]
+		if entry.Name != name || (version != "" && entry.Version != version) {
+			continue
+		}
+		if found == nil || compareVersions(entry.Version, found.Version) > 0 {
+			found = entry
+		}
+	}
+	if found == nil && version != "" {
+		return nil, fmt.Errorf("package %s@%s not found in the registry", name, version)
+	}
+	if found == nil {
+		return nil, fmt.Errorf("package %s not found in the registry", name)
+	}
+	return found, nil
+}
+
+// Search returns the newest version of the packages whose name or description contains
+// term (all of them for an empty term), sorted by name
+func (index *PackageIndex) Search(term string) []PackageEntry {
+	term = strings.ToLower(term)
+	latest := map[string]PackageEntry{}
+	for _, entry := range index.Packages {
+		if !strings.Contains(strings.ToLower(entry.Name+" "+entry.Description), term) {
+			continue
+		}
+		if found, ok := latest[entry.Name]; !ok || compareVersions(entry.Version, found.Version) > 0 {
+			latest[entry.Name] = entry
+		}
+	}
+	entries := make([]PackageEntry, 0, len(latest))
+	for _, entry := range latest {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries
+}
+
+// compareVersions compares dotted versions numerically where both parts are numbers,
+// ignoring a leading v
+func compareVersions(a, b string) int {
+	as := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	bs := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y string
+		if i < len(as) {
+			x = as[i]
+		}
+		if i < len(bs) {
+			y = bs[i]
+		}
+		xn, xerr := strconv.Atoi(x)
+		yn, yerr := strconv.Atoi(y)
+		switch {
+		case xerr == nil && yerr == nil && xn != yn:
+			if xn < yn {
+				return -1
+			}
+			return 1
+		case (xerr != nil || yerr != nil) && x != y:
+			return strings.Compare(x, y)
+		}
+	}
+	return 0
+}
+
+// isURL reports whether source is an http or https URL
+func isURL(source string) bool {
+	return strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://")
+}
+
+// httpGet writes the body of a GET request to w
+func httpGet(rawURL string, w io.Writer) error {
+	ctx, cancel := transferContext()
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %v", redactURL(rawURL), MaskSecrets(err.Error()))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", redactURL(rawURL), resp.Status)
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// InstallPackage installs a package from a .tar.gz file, a URL, or a registry name with
+// an optional @version, replacing the installed version of the package. Returns the
+// installed package and the version it replaced, if any
+func InstallPackage(source string) (*PackageMeta, string, error) {
+	if err := os.MkdirAll(PackagesDir(), 0755); err != nil {
+		return nil, "", fmt.Errorf("failed to create packages directory: %w", err)
+	}
+	archive := source
+	if _, err := os.Stat(source); err != nil {
+		rawURL, checksum := source, ""
+		if !isURL(source) {
+			name, version := source, ""
+			if i := strings.LastIndex(source, "@"); i > 0 {
+				name, version = source[:i], source[i+1:]
+			}
+			index, registry, err := FetchPackageIndex()
+			if err != nil {
+				return nil, "", err
+			}
+			entry, err := index.Latest(name, version)
+			if err != nil {
+				return nil, "", err
+			}
+			base, err := url.Parse(registry)
+			if err != nil {
+				return nil, "", fmt.Errorf("invalid registry URL: %w", err)
+			}
+			ref, err := url.Parse(entry.URL)
+			if err != nil {
+				return nil, "", fmt.Errorf("invalid URL for package %s: %w", entry.Name, err)
+			}
+			rawURL, checksum = base.ResolveReference(ref).String(), strings.ToLower(entry.SHA256)
+		}
+
+		f, err := os.CreateTemp(PackagesDir(), ".download-*.tar.gz")
+		if err != nil {
+			return nil, "", err
+		}
+		archive = f.Name()
+		defer os.Remove(archive)
+		err = httpGet(rawURL, f)
+		f.Close()
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to download package: %w", err)
+		}
+		if checksum != "" {
+			if sum, err := FileSHA256(archive); err != nil || sum != checksum {
+				return nil, "", fmt.Errorf("checksum mismatch for %s: expected sha256 %s, got %s", redactURL(rawURL), checksum, sum)
+			}
+		}
+	}
+
+	staging, err := os.MkdirTemp(PackagesDir(), ".install-")
+	if err != nil {
+		return nil, "", err
+	}
+	defer os.RemoveAll(staging)
+	if _, err := extractTar(archive, staging, true); err != nil {
+		return nil, "", fmt.Errorf("failed to unpack %s: %w", source, err)
+	}
+	meta, err := ReadPackage(staging)
+	if err != nil {
+		return nil, "", err
+	}
+
+	dest := filepath.Join(PackagesDir(), meta.Name)
+	previous := ""
+	if installed, err := ReadPackage(dest); err == nil {
+		previous = installed.Version
+	}
+	if err := os.RemoveAll(dest); err != nil {
+		return nil, "", fmt.Errorf("failed to remove the installed version: %w", err)
+	}
+	if err := os.Rename(staging, dest); err != nil {
+		return nil, "", fmt.Errorf("failed to install package: %w", err)
+	}
+	meta.Dir = dest
+	return meta, previous, nil
+}
+
+// UninstallPackage removes an installed package
+func UninstallPackage(name string) (*PackageMeta, error) {
+	if !isPackageName(name) {
+		return nil, fmt.Errorf("invalid package name '%s'", name)
+	}
+	dir := filepath.Join(PackagesDir(), name)
+	meta, err := ReadPackage(dir)
+	if err != nil {
+		if _, statErr := os.Stat(dir); os.IsNotExist(statErr) {
+			return nil, fmt.Errorf("package %s is not installed", name)
+		}
+		meta = &PackageMeta{Name: name, Dir: dir}
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, fmt.Errorf("failed to remove %s: %w", dir, err)
+	}
+	return meta, nil
+}
+
+// InstalledPackages returns the installed packages, sorted by name
+func InstalledPackages() []*PackageMeta {
+	entries, err := os.ReadDir(PackagesDir())
+	if err != nil {
+		return nil
+	}
+	var packages []*PackageMeta
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if meta, err := ReadPackage(filepath.Join(PackagesDir(), entry.Name())); err == nil {
+			packages = append(packages, meta)
+		}
+	}
+	return packages
+}
+
+// PackageWorkflowDirs returns the workflows directories of the installed packages
+func PackageWorkflowDirs() []string {
+	var dirs []string
+	for _, meta := range InstalledPackages() {
+		dirs = append(dirs, filepath.Join(meta.Dir, PackageWorkflowsDir))
+	}
+	return dirs
+}
@@ -3,7 +3,6 @@ package internal
 import (
 	"fmt"
 	"io"
-	"os"
 	"strings"
 
 	"gopkg.in/yaml.v3"
@@ -11,19 +10,32 @@ import (
 
 // ParseYAML reads and parses a YAML file into a CommandConfig
 func ParseYAML(filePath string) (*CommandConfig, error) {
-	data, err := os.ReadFile(filePath)
+	data, err := ReadWorkflowFile(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read file %s: %w", filePath, err)
+		return nil, err
 	}
 
-	var config CommandConfig
-	if err := yaml.Unmarshal(data, &config); err != nil {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
 		return nil, fmt.Errorf("failed to parse YAML: %w", err)
 	}
+	var config CommandConfig
+	if len(doc.Content) > 0 {
+		root := resolveAliases(doc.Content[0])
+		if StrictParse {
+			if err := checkStrictDocument(root, IsYAMLFile(filePath)); err != nil {
+				return nil, fmt.Errorf("%s: %w", filePath, err)
+			}
+		}
+		if err := root.Decode(&config); err != nil {
+			return nil, fmt.Errorf("failed to parse YAML: %w", err)
+		}
+	}
 
-	if config.Command == "" {
+	if config.Command == "" && config.Type == "" && config.Group == "" {
 		return nil, fmt.Errorf("command field is required")
 	}
+	config.SourceFile = filePath
 
 	return &config, nil
 }
@@ -31,18 +43,37 @@ func ParseYAML(filePath string) (*CommandConfig, error) {
 // ParseMultiYAML reads and parses a YAML file with multiple documents (separated by ---)
 // Returns a slice of CommandConfig, one for each document
 func ParseMultiYAML(filePath string) ([]*CommandConfig, error) {
-	data, err := os.ReadFile(filePath)
+	return parseWorkflowFile(filePath, nil)
+}
+
+// parseWorkflowFile parses a workflow file called by the files in calls (absolute paths,
+// outermost first), which its workflow: steps must not call again
+func parseWorkflowFile(filePath string, calls []string) ([]*CommandConfig, error) {
+	abs := absPath(filePath)
+	for i, call := range calls {
+		if call == abs {
+			var names []string
+			for _, c := range append(calls[i:], abs) {
+				names = append(names, WorkflowName(c))
+			}
+			return nil, fmt.Errorf("workflow call cycle: %s", strings.Join(names, " -> "))
+		}
+	}
+
+	data, err := ReadWorkflowFile(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read file %s: %w", filePath, err)
+		return nil, err
 	}
 
 	// Always use decoder to handle both single and multiple documents
 	decoder := yaml.NewDecoder(strings.NewReader(string(data)))
 	var configs []*CommandConfig
+	var metadata *WorkflowMetadata
+	first := true
 
 	for {
-		var config CommandConfig
-		err := decoder.Decode(&config)
+		var doc yaml.Node
+		err := decoder.Decode(&doc)
 		if err != nil {
 			// Check if it's EOF (end of documents)
 			if err == io.EOF {
@@ -50,12 +81,65 @@ func ParseMultiYAML(filePath string) ([]*CommandConfig, error) {
 			}
 			return nil, fmt.Errorf("failed to parse YAML document: %w", err)
 		}
+		if len(doc.Content) == 0 {
+			continue
+		}
+		root := resolveAliases(doc.Content[0])
 
-		if config.Command == "" {
+		// A leading document with only version, name, description, and inputs describes
+		// the workflow, and its version applies to the documents without one
+		if first && isMetadataDocument(root) {
+			first = false
+			if metadata, err = decodeMetadata(root); err != nil {
+				return nil, fmt.Errorf("failed to parse YAML document: %w", err)
+			}
+			continue
+		}
+		first = false
+		inheritVersion(root, metadata)
+		if StrictParse {
+			if err := checkStrictDocument(root, IsYAMLFile(filePath)); err != nil {
+				return nil, fmt.Errorf("%s: %w", filePath, err)
+			}
+		}
+
+		var config CommandConfig
+		if err := root.Decode(&config); err != nil {
+			return nil, fmt.Errorf("failed to parse YAML document: %w", err)
+		}
+
+		if config.Command == "" && config.Type == "" && config.Group == "" && config.Workflow == "" && config.Use == "" {
 			// Skip empty documents
 			continue
 		}
+		config.SourceFile = filePath
+		config.Metadata = metadata
 
+		// The steps of a called workflow run in order with the other steps, in its scope
+		if config.Workflow != "" {
+			steps, err := expandWorkflowCall(&config, append(calls[:len(calls):len(calls)], abs))
+			if err != nil {
+				return nil, err
+			}
+			configs = append(configs, steps...)
+			continue
+		}
+
+		// The steps of a template run as a group of their own
+		if config.Use != "" {
+			steps, err := expandTemplateUse(&config)
+			if err != nil {
+				return nil, err
+			}
+			configs = append(configs, steps...)
+			continue
+		}
+
+		// The steps of a group run in order with the other steps, in their own scope
+		if config.Group != "" {
+			configs = append(configs, ExpandGroup(&config)...)
+			continue
+		}
 		configs = append(configs, &config)
 	}
 
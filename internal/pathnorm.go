@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Types of the type: of a variable, which control how the arguments using it are normalized
+const (
+	VariableTypePath = "path" // The argument is normalized even if it does not look like a path
+	VariableTypeRaw  = "raw"  // The argument is left as it is, as for versions such as 1.2/3
+)
+
+// NormalizesPaths reports whether the arguments of a step that look like paths are
+// normalized: its normalize_paths, else that of the metadata document, else true
+func NormalizesPaths(config *CommandConfig) bool {
+	if config.NormalizePaths != nil {
+		return *config.NormalizePaths
+	}
+	if config.Metadata != nil && config.Metadata.NormalizePaths != nil {
+		return *config.Metadata.NormalizePaths
+	}
+	return true
+}
+
+// CheckVariableTypes validates the type: of the variables of a step
+func CheckVariableTypes(config *CommandConfig) error {
+	names := make([]string, 0, len(config.VariableTypes))
+	for name := range config.VariableTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		switch t := config.VariableTypes[name]; t {
+		case VariableTypePath, VariableTypeRaw:
+		default:
+			return fmt.Errorf("variable %s: type must be %s or %s, not '%s'", name, VariableTypePath, VariableTypeRaw, t)
+		}
+	}
+	return nil
+}
+
+// substituteStepArgs substitutes the variables of the arguments of a step, normalizing
+// those that are paths: an argument that uses a raw variable is left as it is, one that
+// uses a path variable is normalized, and the others are normalized if they look like a
+// path. With normalize_paths: false, none is
+func substituteStepArgs(config *CommandConfig, args []string, yamlVars, dollarVars map[string]string) []string {
+	if len(config.VariableTypes) == 0 && NormalizesPaths(config) {
+		return SubstituteVariablesInArgsWithSeparateMaps(args, yamlVars, dollarVars)
+	}
+
+	result := make([]string, len(args))
+	for i, arg := range args {
+		result[i] = SubstituteVariablesWithSeparateMaps(arg, yamlVars, dollarVars)
+		if !NormalizesPaths(config) {
+			continue
+		}
+		switch argumentType(arg, config.VariableTypes) {
+		case VariableTypeRaw:
+		case VariableTypePath:
+			result[i] = NormalizePath(result[i])
+		default:
+			if IsPathLike(result[i]) {
+				result[i] = NormalizePath(result[i])
+			}
+		}
+	}
+	return result
+}
+
+// argumentType returns the type of the variables an argument uses: raw if any is raw,
+// else path if any is a path, else ""
+func argumentType(arg string, types map[string]string) string {
+	argType := ""
+	for _, token := range tokenizeTemplate(arg) {
+		if token.kind == tokenText {
+			continue
+		}
+		name := token.expr
+		if pipeline, _, ok := parsePipeline(token.expr); ok {
+			name = pipeline
+		}
+		switch types[variableReferenceName(name)] {
+		case VariableTypeRaw:
+			return VariableTypeRaw
+		case VariableTypePath:
+			argType = VariableTypePath
+		}
+	}
+	return argType
+}
@@ -0,0 +1,136 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// Environment variables overriding the locations used by linea
+const (
+	// LineaHomeEnv puts all config, data, state, and cache under one directory
+	LineaHomeEnv = "LINEA_HOME"
+
+	ConfigDirEnv       = "LINEA_CONFIG_DIR"
+	DataDirEnv         = "LINEA_DATA_DIR"
+	StateDirEnv        = "LINEA_STATE_DIR"
+	CacheDirEnv        = "LINEA_CACHE_DIR"
+	GlobalWorkflowsEnv = "LINEA_GLOBAL_WORKFLOWS"
+)
+
+// appName is the directory name used inside platform base directories
+const appName = "linea"
+
+// Paths holds the per-user directories used by every linea subsystem
+type Paths struct {
+	Config          string // Configuration (config.yml)
+	Data            string // User data (global workflows, templates)
+	State           string // Persistent state (run history, schedules, locks)
+	Cache           string // Disposable cache (downloads, step cache)
+	GlobalWorkflows string // Workflows available from any project
+}
+
+// UserPaths resolves the per-user directories
+//
+// Resolution for each directory:
+//  1. Its specific override (LINEA_CONFIG_DIR, LINEA_DATA_DIR, LINEA_STATE_DIR, LINEA_CACHE_DIR)
+//  2. LINEA_HOME, or an existing legacy ~/.linea directory, holding everything
+//  3. The platform convention: XDG base directories on Linux and other Unix systems,
+//     ~/Library on macOS, and %APPDATA%/%LOCALAPPDATA% on Windows
+//
+// The global workflows directory can also be set with global_workflows_dir in config.yml
+func UserPaths() Paths {
+	var p Paths
+
+	if home := lineaHome(); home != "" {
+		p = Paths{
+			Config: home,
+			Data:   home,
+			State:  filepath.Join(home, "state"),
+			Cache:  filepath.Join(home, "cache"),
+		}
+	} else {
+		p = platformPaths()
+	}
+
+	p.Config = envOr(ConfigDirEnv, p.Config)
+	p.Data = envOr(DataDirEnv, p.Data)
+	p.State = envOr(StateDirEnv, p.State)
+	p.Cache = envOr(CacheDirEnv, p.Cache)
+
+	globalWorkflows := filepath.Join(p.Data, "global-workflows")
+	if config, err := loadUserConfig(filepath.Join(p.Config, ConfigFileName)); err == nil && config.GlobalWorkflowsDir != "" {
+		globalWorkflows = config.GlobalWorkflowsDir
+	}
+	p.GlobalWorkflows = envOr(GlobalWorkflowsEnv, globalWorkflows)
+
+	return p
+}
+
+// lineaHome returns LINEA_HOME, or ~/.linea if it already exists, or an empty string
+func lineaHome() string {
+	if home := os.Getenv(LineaHomeEnv); home != "" {
+		return home
+	}
+	if userHome, err := os.UserHomeDir(); err == nil {
+		legacy := filepath.Join(userHome, ".linea")
+		if info, err := os.Stat(legacy); err == nil && info.IsDir() {
+			return legacy
+		}
+	}
+	return ""
+}
+
+// platformPaths returns the platform-conventional directories
+func platformPaths() Paths {
+	userHome, err := os.UserHomeDir()
+	if err != nil {
+		// Without a home directory fall back to a project-local layout
+		return Paths{
+			Config: ".linea",
+			Data:   ".linea",
+			State:  filepath.Join(".linea", "state"),
+			Cache:  filepath.Join(".linea", "cache"),
+		}
+	}
+
+	switch runtime.GOOS {
+	case "windows":
+		roaming := envOr("APPDATA", filepath.Join(userHome, "AppData", "Roaming"))
+		local := envOr("LOCALAPPDATA", filepath.Join(userHome, "AppData", "Local"))
+		return Paths{
+			Config: filepath.Join(roaming, appName),
+			Data:   filepath.Join(roaming, appName),
+			State:  filepath.Join(local, appName, "state"),
+			Cache:  filepath.Join(local, appName, "cache"),
+		}
+	case "darwin":
+		support := filepath.Join(userHome, "Library", "Application Support", appName)
+		return Paths{
+			Config: support,
+			Data:   support,
+			State:  filepath.Join(support, "state"),
+			Cache:  filepath.Join(userHome, "Library", "Caches", appName),
+		}
+	default:
+		return Paths{
+			Config: filepath.Join(envOr("XDG_CONFIG_HOME", filepath.Join(userHome, ".config")), appName),
+			Data:   filepath.Join(envOr("XDG_DATA_HOME", filepath.Join(userHome, ".local", "share")), appName),
+			State:  filepath.Join(envOr("XDG_STATE_HOME", filepath.Join(userHome, ".local", "state")), appName),
+			Cache:  filepath.Join(envOr("XDG_CACHE_HOME", filepath.Join(userHome, ".cache")), appName),
+		}
+	}
+}
+
+// envOr returns the value of an environment variable, or def if it is unset or empty
+func envOr(name, def string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return def
+}
+
+// GlobalWorkflowsDir returns the directory holding workflows available from any project
+func GlobalWorkflowsDir() string {
+	return UserPaths().GlobalWorkflows
+}
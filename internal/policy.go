@@ -0,0 +1,274 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PolicyFileName is the name of a policy file, in a project's .linea directory or in the
+// configuration directory
+const PolicyFileName = "policy.yml"
+
+// PolicyFileEnv overrides the location of the global policy file
+const PolicyFileEnv = "LINEA_POLICY_FILE"
+
+// EnforcePolicyEnv is set for the commands of a run that enforces its policies, so the
+// linea runs they start (linea run steps, lineash scripts) enforce them too
+const EnforcePolicyEnv = "LINEA_ENFORCE_POLICY"
+
+// Policy restricts the commands workflows may run
+type Policy struct {
+	// Enforce makes every run enforce the policy, as if --enforce-policy were given
+	Enforce bool `yaml:"enforce,omitempty"`
+	// Allow lists the commands workflows may run; when empty, any command not denied may run
+	Allow []PolicyRule `yaml:"allow,omitempty"`
+	// Deny lists the commands workflows may not run, even if allowed
+	Deny []PolicyRule `yaml:"deny,omitempty"`
+
+	Path string `yaml:"-"`
+}
+
+// PolicyRule matches commands by name and, optionally, by their arguments
+type PolicyRule struct {
+	// Command is the program's name or a glob of it (npm, python*, *), matched without its
+	// directory and .exe extension, or a path if it contains a slash
+	Command string `yaml:"command"`
+	// Args is a regular expression the arguments, joined with spaces, must contain
+	Args string `yaml:"args,omitempty"`
+	// Reason explains the rule in violations
+	Reason string `yaml:"reason,omitempty"`
+
+	args *regexp.Regexp
+}
+
+// PolicyViolation is the audit error of a command a policy does not allow
+type PolicyViolation struct {
+	Policy  string   // Path of the policy file
+	Command []string // The command as the step would run it
+	Rule    *PolicyRule
+}
+
+func (v *PolicyViolation) Error() string {
+	command := MaskSecrets(strings.Join(v.Command, " "))
+	if v.Rule == nil {
+		return fmt.Sprintf("policy violation: '%s' is not allowed by %s", command, v.Policy)
+	}
+	message := fmt.Sprintf("policy violation: '%s' is denied by %s", command, v.Policy)
+	if v.Rule.Reason != "" {
+		message += ": " + v.Rule.Reason
+	}
+	return message
+}
+
+// LoadPolicy reads a policy file, returning nil if it does not exist
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file %s: %w", path, err)
+	}
+	policy := &Policy{Path: path}
+	if err := yaml.Unmarshal(data, policy); err != nil {
+		return nil, fmt.Errorf("failed to parse policy file %s: %w", path, err)
+	}
+	for _, rules := range [][]PolicyRule{policy.Allow, policy.Deny} {
+		for i := range rules {
+			rule := &rules[i]
+			if strings.TrimSpace(rule.Command) == "" {
+				return nil, fmt.Errorf("policy file %s: every rule needs a command", path)
+			}
+			if _, err := filepath.Match(rule.Command, ""); err != nil {
+				return nil, fmt.Errorf("policy file %s: invalid command pattern '%s': %w", path, rule.Command, err)
+			}
+			if rule.Args != "" {
+				if rule.args, err = regexp.Compile(rule.Args); err != nil {
+					return nil, fmt.Errorf("policy file %s: invalid args pattern for '%s': %w", path, rule.Command, err)
+				}
+			}
+		}
+	}
+	return policy, nil
+}
+
+// PolicyFilePaths returns the policy files that apply in the current directory: the
+// global one (LINEA_POLICY_FILE, or policy.yml in the configuration directory) and the
+// project's .linea/policy.yml
+func PolicyFilePaths() []string {
+	global := os.Getenv(PolicyFileEnv)
+	if global == "" {
+		global = filepath.Join(UserPaths().Config, PolicyFileName)
+	}
+	paths := []string{global}
+	if cwd, err := os.Getwd(); err == nil {
+		if dir := FindLineaDir(cwd); dir != "" {
+			paths = append(paths, filepath.Join(dir, PolicyFileName))
+		}
+	}
+	return paths
+}
+
+// LoadPolicies reads the policy files that exist among PolicyFilePaths
+func LoadPolicies() ([]*Policy, error) {
+	var policies []*Policy
+	for _, path := range PolicyFilePaths() {
+		policy, err := LoadPolicy(path)
+		if err != nil {
+			return nil, err
+		}
+		if policy != nil {
+			policies = append(policies, policy)
+		}
+	}
+	return policies, nil
+}
+
+// Check returns a *PolicyViolation if the policy does not allow cmd
+func (p *Policy) Check(cmd []string) error {
+	return p.check(cmd, true)
+}
+
+// check matches cmd against the policy; without known arguments, only rules that do not
+// look at arguments can reject it
+func (p *Policy) check(cmd []string, argsKnown bool) error {
+	if len(cmd) == 0 {
+		return nil
+	}
+	for i := range p.Deny {
+		if rule := &p.Deny[i]; rule.matches(cmd, argsKnown) {
+			return &PolicyViolation{Policy: p.Path, Command: cmd, Rule: rule}
+		}
+	}
+	if len(p.Allow) == 0 {
+		return nil
+	}
+	for i := range p.Allow {
+		if rule := &p.Allow[i]; rule.matches(cmd, argsKnown) || (!argsKnown && rule.matchesCommand(cmd[0])) {
+			return nil
+		}
+	}
+	return &PolicyViolation{Policy: p.Path, Command: cmd}
+}
+
+// matches reports whether the rule matches cmd; a rule with args does not match unless
+// the arguments are known
+func (r *PolicyRule) matches(cmd []string, argsKnown bool) bool {
+	if !r.matchesCommand(cmd[0]) {
+		return false
+	}
+	if r.args == nil {
+		return true
+	}
+	return argsKnown && r.args.MatchString(strings.Join(cmd[1:], " "))
+}
+
+// matchesCommand reports whether the rule's command matches program
+func (r *PolicyRule) matchesCommand(program string) bool {
+	if strings.ContainsAny(r.Command, `/\`) {
+		ok, _ := filepath.Match(filepath.Clean(r.Command), filepath.Clean(program))
+		return ok
+	}
+	name := filepath.Base(program)
+	for _, ext := range []string{".exe", ".cmd", ".bat"} {
+		if strings.HasSuffix(strings.ToLower(name), ext) {
+			name = name[:len(name)-len(ext)]
+			break
+		}
+	}
+	ok, _ := filepath.Match(r.Command, name)
+	return ok
+}
+
+// PoliciesEnforced reports whether a run must enforce policies: when asked to
+// (--enforce-policy, or a parent run that does), or when a policy has enforce: true
+func PoliciesEnforced(policies []*Policy, requested bool) bool {
+	if requested || os.Getenv(EnforcePolicyEnv) != "" {
+		return true
+	}
+	for _, policy := range policies {
+		if policy.Enforce {
+			return true
+		}
+	}
+	return false
+}
+
+// EnforcedPolicies are checked by CheckStepPolicy before each step runs
+var EnforcedPolicies []*Policy
+
+// EnforcePolicies makes every step of this run, and of the linea runs it starts, check
+// policies before it runs. The function it returns stops enforcing them, for a process
+// that goes on to run other workflows
+func EnforcePolicies(policies []*Policy) func() {
+	previous := EnforcedPolicies
+	env, set := os.LookupEnv(EnforcePolicyEnv)
+	EnforcedPolicies = policies
+	os.Setenv(EnforcePolicyEnv, "1")
+	return func() {
+		EnforcedPolicies = previous
+		if set {
+			os.Setenv(EnforcePolicyEnv, env)
+		} else {
+			os.Unsetenv(EnforcePolicyEnv)
+		}
+	}
+}
+
+// PolicyCommand returns the command a step runs, as policies see it: a type: step is its
+// type's name and arguments, and a step with a runner: is the command it runs remotely
+func PolicyCommand(config *CommandConfig, overrideVars map[string]string) ([]string, error) {
+	local := *config
+	local.Runner = nil
+	return BuildCommand(&local, overrideVars)
+}
+
+// CheckStepPolicy fails a step that EnforcedPolicies do not allow
+func CheckStepPolicy(config *CommandConfig, overrideVars map[string]string) error {
+	if len(EnforcedPolicies) == 0 {
+		return nil
+	}
+	cmd, err := PolicyCommand(config, overrideVars)
+	if err != nil {
+		return err
+	}
+	for _, policy := range EnforcedPolicies {
+		if err := policy.Check(cmd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AuditPolicies checks the steps of a workflow against policies before anything runs,
+// returning one error per step they do not allow. Steps whose arguments depend on earlier
+// steps are checked by command only, and fully by CheckStepPolicy when they run
+func AuditPolicies(policies []*Policy, configs []*CommandConfig, overrideVars map[string]string) []error {
+	var violations []error
+	for i, config := range configs {
+		cmd, err := PolicyCommand(config, overrideVars)
+		argsKnown := err == nil
+		if !argsKnown {
+			program := config.Command
+			if config.Type != "" {
+				program = config.Type
+			}
+			if program == "" {
+				continue
+			}
+			cmd = []string{program}
+		}
+		for _, policy := range policies {
+			if err := policy.check(cmd, argsKnown); err != nil {
+				violations = append(violations, fmt.Errorf("%s: %w", StepLabel(i, config), err))
+				break
+			}
+		}
+	}
+	return violations
+}
@@ -0,0 +1,38 @@
+package internal
+
+import (
+	"io"
+	"os"
+)
+
+// processGroup is a started command together with the processes it starts, so that they
+// can be signalled and killed with it: on Unix, its process group if it leads one of its
+// own, and on Windows, the job object it was assigned to
+type processGroup struct {
+	process *os.Process
+	own     bool    // Unix: the command was started in a process group of its own
+	job     uintptr // Windows: handle of the command's job object, 0 if it has none
+}
+
+// usesTerminal reports whether a command may use the terminal, in which case it stays in
+// linea's process group: a command in another one is stopped when it reads the terminal,
+// as programs like sudo and ssh do to prompt even with their input redirected. That is
+// when its standard input is a terminal, or linea's own input or error output is
+func usesTerminal(stdin io.Reader) bool {
+	return isTerminalFile(stdin) || isTerminalFile(os.Stdin) || isTerminalFile(os.Stderr)
+}
+
+// isTerminalFile reports whether v is a file open on a terminal
+func isTerminalFile(v interface{}) bool {
+	f, ok := v.(*os.File)
+	if !ok || !IsTerminal(f) {
+		return false
+	}
+	// IsTerminal only checks for a character device, which the null device is too
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	null, err := os.Stat(os.DevNull)
+	return err != nil || !os.SameFile(info, null)
+}
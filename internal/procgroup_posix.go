@@ -0,0 +1,80 @@
+//go:build !windows
+
+package internal
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// setOwnProcessGroup makes a command start in a process group of its own, which the
+// processes it starts, like node started by npm, join
+func setOwnProcessGroup(execCmd *exec.Cmd) {
+	if execCmd.SysProcAttr == nil {
+		execCmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	execCmd.SysProcAttr.Setpgid = true
+}
+
+// newProcessGroup returns the group of a started command; own tells whether it was
+// started with setOwnProcessGroup. The command line already applies limits on Unix
+// (see limitCommandLine)
+func newProcessGroup(process *os.Process, own bool, limits *ResourceLimits) *processGroup {
+	return &processGroup{process: process, own: own}
+}
+
+// signal sends sig to the command, and the processes it started if it has its own group
+func (g *processGroup) signal(sig os.Signal) error {
+	if s, ok := sig.(syscall.Signal); ok && g.own {
+		return syscall.Kill(-g.process.Pid, s)
+	}
+	return g.process.Signal(sig)
+}
+
+// kill kills the command, and the processes it started if it has its own group
+func (g *processGroup) kill() error {
+	if g.own {
+		return syscall.Kill(-g.process.Pid, syscall.SIGKILL)
+	}
+	return g.process.Kill()
+}
+
+// release frees the resources of the group
+func (g *processGroup) release() {}
+
+// terminateProcess asks a process to exit (SIGTERM), and kills it after timeout, together
+// with its process group if it leads one, like background jobs do
+func terminateProcess(pid int, timeout time.Duration) error {
+	if err := signalProcessTree(pid, syscall.SIGTERM); err != nil {
+		return err
+	}
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if !processAlive(pid) {
+			// The processes it started get the rest of the timeout too
+			for processGroupAlive(pid) && time.Now().Before(deadline) {
+				time.Sleep(50 * time.Millisecond)
+			}
+			signalProcessTree(pid, syscall.SIGKILL)
+			return nil
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return signalProcessTree(pid, syscall.SIGKILL)
+}
+
+// signalProcessTree sends sig to the process group led by pid, or to the process alone
+// if it leads none, like jobs started before they had groups of their own
+func signalProcessTree(pid int, sig syscall.Signal) error {
+	if err := syscall.Kill(-pid, sig); err == nil {
+		return nil
+	}
+	return syscall.Kill(pid, sig)
+}
+
+// processGroupAlive reports whether a process group led by pid still has processes
+func processGroupAlive(pid int) bool {
+	return syscall.Kill(-pid, syscall.Signal(0)) == nil
+}
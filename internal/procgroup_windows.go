@@ -0,0 +1,144 @@
+package internal
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+var (
+	kernel32                     = syscall.NewLazyDLL("kernel32.dll")
+	procCreateJobObjectW         = kernel32.NewProc("CreateJobObjectW")
+	procAssignProcessToJobObject = kernel32.NewProc("AssignProcessToJobObject")
+	procTerminateJobObject       = kernel32.NewProc("TerminateJobObject")
+	procSetInformationJobObject  = kernel32.NewProc("SetInformationJobObject")
+)
+
+// Access rights needed to assign a process to a job object
+const (
+	processTerminate = 0x0001
+	processSetQuota  = 0x0100
+)
+
+// Job object limits (JOBOBJECT_EXTENDED_LIMIT_INFORMATION)
+const (
+	jobObjectExtendedLimitInformation = 9
+	jobObjectLimitProcessTime         = 0x0002
+	jobObjectLimitPriorityClass       = 0x0020
+	jobObjectLimitProcessMemory       = 0x0100
+	belowNormalPriorityClass          = 0x4000
+	idlePriorityClass                 = 0x0040
+)
+
+// jobObjectExtendedLimit is JOBOBJECT_EXTENDED_LIMIT_INFORMATION
+type jobObjectExtendedLimit struct {
+	PerProcessUserTimeLimit int64
+	PerJobUserTimeLimit     int64
+	LimitFlags              uint32
+	MinimumWorkingSetSize   uintptr
+	MaximumWorkingSetSize   uintptr
+	ActiveProcessLimit      uint32
+	Affinity                uintptr
+	PriorityClass           uint32
+	SchedulingClass         uint32
+	IoInfo                  [6]uint64
+	ProcessMemoryLimit      uintptr
+	JobMemoryLimit          uintptr
+	PeakProcessMemoryUsed   uintptr
+	PeakJobMemoryUsed       uintptr
+}
+
+// setOwnProcessGroup does nothing on Windows, where a command is assigned to a job object
+// once it has started (see newProcessGroup)
+func setOwnProcessGroup(execCmd *exec.Cmd) {}
+
+// newProcessGroup assigns a started command to a new job object, which the processes it
+// starts join, with the memory and CPU time limits and the priority of limits. Without
+// one, as when the command already left, only the command is killed
+func newProcessGroup(process *os.Process, own bool, limits *ResourceLimits) *processGroup {
+	g := &processGroup{process: process, own: own}
+	job, _, _ := procCreateJobObjectW.Call(0, 0)
+	if job == 0 {
+		return g
+	}
+	if limits != nil {
+		setJobLimits(job, limits)
+	}
+	handle, err := syscall.OpenProcess(processTerminate|processSetQuota, false, uint32(process.Pid))
+	if err != nil {
+		syscall.CloseHandle(syscall.Handle(job))
+		return g
+	}
+	defer syscall.CloseHandle(handle)
+	if ok, _, _ := procAssignProcessToJobObject.Call(job, uintptr(handle)); ok == 0 {
+		syscall.CloseHandle(syscall.Handle(job))
+		return g
+	}
+	g.job = job
+	return g
+}
+
+// setJobLimits applies limits to a job object; each process in it gets its own memory
+// and CPU time limit, as with ulimit on Unix. A nice value maps to a priority class
+func setJobLimits(job uintptr, limits *ResourceLimits) {
+	var info jobObjectExtendedLimit
+	if memory := limits.memoryBytes(); memory > 0 {
+		info.LimitFlags |= jobObjectLimitProcessMemory
+		info.ProcessMemoryLimit = uintptr(memory)
+	}
+	if cpu := limits.cpuTime(); cpu > 0 {
+		info.LimitFlags |= jobObjectLimitProcessTime
+		info.PerProcessUserTimeLimit = int64(cpu / 100) // In 100-nanosecond units
+	}
+	if nice := limits.nice(); nice > 0 {
+		info.LimitFlags |= jobObjectLimitPriorityClass
+		info.PriorityClass = belowNormalPriorityClass
+		if nice >= 10 {
+			info.PriorityClass = idlePriorityClass
+		}
+	}
+	if info.LimitFlags != 0 {
+		procSetInformationJobObject.Call(job, jobObjectExtendedLimitInformation, uintptr(unsafe.Pointer(&info)), unsafe.Sizeof(info))
+	}
+}
+
+// signal does nothing: Windows cannot send signals, and the console delivers Ctrl+C to
+// the command already
+func (g *processGroup) signal(sig os.Signal) error {
+	return nil
+}
+
+// kill kills the command and the processes it started
+func (g *processGroup) kill() error {
+	if g.job != 0 {
+		if ok, _, err := procTerminateJobObject.Call(g.job, 1); ok == 0 {
+			return err
+		}
+		return nil
+	}
+	return g.process.Kill()
+}
+
+// release closes the job object; processes still in it keep running
+func (g *processGroup) release() {
+	if g.job != 0 {
+		syscall.CloseHandle(syscall.Handle(g.job))
+		g.job = 0
+	}
+}
+
+// terminateProcess kills a process and the processes it started right away: Windows has
+// no way to ask a process to exit
+func terminateProcess(pid int, timeout time.Duration) error {
+	if err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(pid)).Run(); err == nil {
+		return nil
+	}
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return process.Kill()
+}
@@ -0,0 +1,138 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Progress event types, in the order a run emits them
+const (
+	EventRunStart  = "run_start"
+	EventStepStart = "step_start"
+	EventStepEnd   = "step_end"
+	EventRunEnd    = "run_end"
+)
+
+// ProgressEvent is one line of the NDJSON progress stream of `linea run --progress-fd`
+type ProgressEvent struct {
+	Event      string    `json:"event"`
+	Time       time.Time `json:"time"`
+	Workflow   string    `json:"workflow"`
+	Path       string    `json:"path,omitempty"`
+	Step       int       `json:"step,omitempty"`  // 1-based, step events only
+	Steps      int       `json:"steps,omitempty"` // Number of steps in the workflow, step events only
+	Name       string    `json:"name,omitempty"`
+	Command    []string  `json:"command,omitempty"`     // Secret values are masked
+	Status     string    `json:"status,omitempty"`      // End events only
+	ExitCode   *int      `json:"exit_code,omitempty"`   // End events only
+	DurationMs *int64    `json:"duration_ms,omitempty"` // End events only
+	Error      string    `json:"error,omitempty"`
+}
+
+// Progress writes progress events to a file descriptor or file; a nil *Progress discards them
+// Writes are safe for concurrent use. After a failed write (for example, the reader closed
+// the pipe) further events are dropped, so progress reporting never fails a run
+type Progress struct {
+	mu     sync.Mutex
+	w      io.WriteCloser
+	broken bool
+}
+
+// ProgressToFD returns a Progress writing to an open file descriptor inherited from the
+// parent process (for example, `linea run --progress-fd 3 deploy 3>events.ndjson`)
+func ProgressToFD(fd string) (*Progress, error) {
+	n, err := strconv.Atoi(fd)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("invalid file descriptor '%s'", fd)
+	}
+	if n <= 2 {
+		return nil, fmt.Errorf("file descriptor %d is linea's own stdin, stdout, or stderr; use 3 or above", n)
+	}
+	file := os.NewFile(uintptr(n), "progress-fd-"+fd)
+	if file == nil {
+		return nil, fmt.Errorf("invalid file descriptor '%s'", fd)
+	}
+	if _, err := file.Stat(); err != nil {
+		return nil, fmt.Errorf("file descriptor %d is not open", n)
+	}
+	return &Progress{w: file}, nil
+}
+
+// OpenProgressFile returns a Progress appending to the file or named pipe at path
+// Opening a named pipe blocks until a reader opens it
+func OpenProgressFile(path string) (*Progress, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open progress file: %w", err)
+	}
+	return &Progress{w: file}, nil
+}
+
+// NewProgress returns a Progress writing to w
+func NewProgress(w io.WriteCloser) *Progress {
+	return &Progress{w: w}
+}
+
+// Emit writes event as one JSON line, setting its time if unset
+func (p *Progress) Emit(event ProgressEvent) {
+	if p == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	var line bytes.Buffer
+	encoder := json.NewEncoder(&line)
+	encoder.SetEscapeHTML(false) // Commands often contain <, >, and &
+	if err := encoder.Encode(event); err != nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.broken {
+		return
+	}
+	if _, err := p.w.Write(line.Bytes()); err != nil {
+		p.broken = true
+	}
+}
+
+// Middleware returns the middleware that emits the step_start and step_end events of
+// a run's steps
+func (p *Progress) Middleware() Middleware {
+	stepEvent := func(step StepInfo) ProgressEvent {
+		return ProgressEvent{Workflow: step.Workflow, Step: step.Index, Steps: step.Steps, Name: step.Config.Name, Command: step.Command}
+	}
+	return Middleware{
+		BeforeStep: func(step StepInfo) error {
+			event := stepEvent(step)
+			event.Event = EventStepStart
+			p.Emit(event)
+			return nil
+		},
+		AfterStep: func(step StepInfo, result StepResult) {
+			event := stepEvent(step)
+			event.Event = EventStepEnd
+			event.Status = result.Status
+			event.ExitCode = &result.ExitCode
+			event.DurationMs = &result.DurationMs
+			event.Command, event.Error = result.Command, result.Error
+			p.Emit(event)
+		},
+	}
+}
+
+// Close closes the underlying file descriptor or file
+func (p *Progress) Close() error {
+	if p == nil {
+		return nil
+	}
+	return p.w.Close()
+}
@@ -0,0 +1,364 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"linea/internal/store"
+)
+
+// Variable providers built into linea
+const (
+	ProviderExec = "exec"
+	ProviderHTTP = "http"
+	ProviderFile = "file"
+	ProviderEnv  = "env"
+)
+
+// providerResponseLimit caps how much of a provider's output is read
+const providerResponseLimit = 1 << 20
+
+// providerHTTPTimeout bounds http provider requests
+const providerHTTPTimeout = 30 * time.Second
+
+// VariableProvider fetches the value of a variable declared with `provider:`
+// dir is the directory of the workflow file, for relative paths and as the working directory
+type VariableProvider interface {
+	Resolve(name string, spec ProviderSpec, dir string) (string, error)
+}
+
+// VariableProviderFunc adapts a function to the VariableProvider interface
+type VariableProviderFunc func(name string, spec ProviderSpec, dir string) (string, error)
+
+// Resolve calls f
+func (f VariableProviderFunc) Resolve(name string, spec ProviderSpec, dir string) (string, error) {
+	return f(name, spec, dir)
+}
+
+var (
+	variableProvidersMu sync.Mutex
+	variableProviders   = map[string]VariableProvider{
+		ProviderExec: VariableProviderFunc(resolveExecProvider),
+		ProviderHTTP: VariableProviderFunc(resolveHTTPProvider),
+		ProviderFile: VariableProviderFunc(resolveFileProvider),
+		ProviderEnv:  VariableProviderFunc(resolveEnvProvider),
+	}
+
+	// resolvedProviders memoizes values within a process, so every step of a run sees the same value
+	resolvedProviders = map[string]string{}
+)
+
+// RegisterVariableProvider makes a provider available as `provider: <name>`, replacing any
+// provider with the same name
+func RegisterVariableProvider(name string, p VariableProvider) {
+	variableProvidersMu.Lock()
+	variableProviders[name] = p
+	variableProvidersMu.Unlock()
+}
+
+// VariableProviderNames returns the names of the registered providers in sorted order
+func VariableProviderNames() []string {
+	variableProvidersMu.Lock()
+	defer variableProvidersMu.Unlock()
+
+	names := make([]string, 0, len(variableProviders))
+	for name := range variableProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CheckProviderSpec reports a problem with a provider declaration without resolving it
+func CheckProviderSpec(name string, spec ProviderSpec) error {
+	variableProvidersMu.Lock()
+	_, ok := variableProviders[spec.Provider]
+	variableProvidersMu.Unlock()
+	if !ok {
+		return fmt.Errorf("variable %s: unknown provider '%s' (expected %s)", name, spec.Provider, strings.Join(VariableProviderNames(), ", "))
+	}
+
+	switch {
+	case spec.Provider == ProviderExec && spec.Command == "":
+		return fmt.Errorf("variable %s: the exec provider needs a command", name)
+	case spec.Provider == ProviderHTTP && spec.URL == "":
+		return fmt.Errorf("variable %s: the http provider needs a url", name)
+	case spec.Provider == ProviderFile && spec.Path == "":
+		return fmt.Errorf("variable %s: the file provider needs a path", name)
+	}
+	if spec.Cache != "" {
+		if _, err := ParseAge(spec.Cache); err != nil {
+			return fmt.Errorf("variable %s: cache: %w", name, err)
+		}
+	}
+	if _, err := ExpressionDefault(spec.Default); err != nil {
+		return fmt.Errorf("variable %s: default: %w", name, err)
+	}
+	return nil
+}
+
+// ResolveProviderVariables fetches the provider variables a step references in its args or
+// variables; unreferenced providers are never called
+func ResolveProviderVariables(config *CommandConfig) (map[string]string, error) {
+	referenced := make(map[string]bool)
+	args, _ := StepArguments(config)
+	sources := append([]string{config.When, config.Confirm}, args...)
+	if config.Stdin != nil {
+		sources = append(sources, config.Stdin.Text, config.Stdin.File)
+	}
+	if config.Foreach != nil {
+		sources = append(sources, config.Foreach.From)
+	}
+	for _, v := range config.Variables {
+		sources = append(sources, v)
+	}
+	for _, source := range sources {
+		for name := range ExtractVariableReferences(source) {
+			referenced[name] = true
+		}
+	}
+
+	dir := "."
+	if config.SourceFile != "" {
+		if abs, err := filepath.Abs(filepath.Dir(config.SourceFile)); err == nil {
+			dir = abs
+		}
+	}
+
+	resolved := make(map[string]string)
+	for name, spec := range config.Providers {
+		if !referenced[name] {
+			continue
+		}
+		value, err := ResolveProviderVariable(name, spec, dir)
+		if err != nil {
+			return nil, err
+		}
+		resolved[name] = value
+	}
+	return resolved, nil
+}
+
+// ResolveProviderVariable fetches one provider variable, using the process memo and the
+// on-disk cache (for specs with cache:) before calling the provider
+func ResolveProviderVariable(name string, spec ProviderSpec, dir string) (string, error) {
+	if err := CheckProviderSpec(name, spec); err != nil {
+		return "", err
+	}
+
+	key := providerCacheKey(name, spec, dir)
+	variableProvidersMu.Lock()
+	value, ok := resolvedProviders[key]
+	provider := variableProviders[spec.Provider]
+	variableProvidersMu.Unlock()
+	if ok {
+		return value, nil
+	}
+
+	var maxAge time.Duration
+	if spec.Cache != "" {
+		maxAge, _ = ParseAge(spec.Cache)
+		if cached, ok := readProviderCache(key, maxAge); ok {
+			return cached, nil
+		}
+	}
+
+	value, err := provider.Resolve(name, spec, dir)
+	if err != nil {
+		return "", fmt.Errorf("variable %s: %s provider: %w", name, spec.Provider, err)
+	}
+	if spec.Field != "" {
+		if value, err = jsonField(value, spec.Field); err != nil {
+			return "", fmt.Errorf("variable %s: %w", name, err)
+		}
+	}
+
+	variableProvidersMu.Lock()
+	resolvedProviders[key] = value
+	variableProvidersMu.Unlock()
+	if maxAge > 0 {
+		writeProviderCache(key, value)
+	}
+	return value, nil
+}
+
+// providerCacheKey identifies a provider declaration for memoization and caching
+func providerCacheKey(name string, spec ProviderSpec, dir string) string {
+	data, _ := json.Marshal(struct {
+		Name string
+		Spec ProviderSpec
+		Dir  string
+	}{name, spec, dir})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// providerCacheEntry is the on-disk format of a cached provider value
+type providerCacheEntry struct {
+	Value   string    `json:"value"`
+	Fetched time.Time `json:"fetched"`
+}
+
+// providerCachePath returns the cache file of a provider declaration
+func providerCachePath(key string) string {
+	return filepath.Join(UserPaths().Cache, "providers", key+".json")
+}
+
+// readProviderCache returns a cached value fetched within maxAge
+func readProviderCache(key string, maxAge time.Duration) (string, bool) {
+	data, err := os.ReadFile(providerCachePath(key))
+	if err != nil {
+		return "", false
+	}
+	var entry providerCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || time.Since(entry.Fetched) > maxAge {
+		return "", false
+	}
+	return entry.Value, true
+}
+
+// writeProviderCache stores a value; failures only cost a refetch next time
+func writeProviderCache(key, value string) {
+	path := providerCachePath(key)
+	data, err := json.Marshal(providerCacheEntry{Value: value, Fetched: time.Now().UTC()})
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+	_ = store.WriteFile(path, data, 0600)
+}
+
+// resolveExecProvider runs a command and returns its trimmed standard output
+func resolveExecProvider(name string, spec ProviderSpec, dir string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	execCmd := exec.Command(spec.Command, spec.Args...)
+	execCmd.Dir = dir
+	execCmd.Stdout = &stdout
+	execCmd.Stderr = &stderr
+	if err := execCmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", fmt.Errorf("%s: %w: %s", spec.Command, err, message)
+		}
+		return "", fmt.Errorf("%s: %w", spec.Command, err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// resolveHTTPProvider fetches a URL with GET and returns the trimmed response body
+func resolveHTTPProvider(name string, spec ProviderSpec, dir string) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, spec.URL, nil)
+	if err != nil {
+		return "", err
+	}
+	for header, value := range spec.Headers {
+		req.Header.Set(header, value)
+	}
+
+	client := &http.Client{Timeout: providerHTTPTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, providerResponseLimit))
+	if err != nil {
+		return "", fmt.Errorf("failed to read response from %s: %w", spec.URL, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("GET %s returned %s", spec.URL, resp.Status)
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// resolveFileProvider returns the trimmed contents of a file
+func resolveFileProvider(name string, spec ProviderSpec, dir string) (string, error) {
+	path := spec.Path
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if len(data) > providerResponseLimit {
+		return "", fmt.Errorf("%s is larger than %d bytes", path, providerResponseLimit)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// resolveEnvProvider returns an environment variable, or the default if it is unset
+func resolveEnvProvider(name string, spec ProviderSpec, dir string) (string, error) {
+	key := spec.Name
+	if key == "" {
+		key = name
+	}
+	if value, ok := os.LookupEnv(key); ok {
+		return value, nil
+	}
+	if spec.Default != "" {
+		return ExpressionDefault(spec.Default)
+	}
+	return "", fmt.Errorf("environment variable %s is not set", key)
+}
+
+// jsonField extracts a dot-separated path such as images.0.id from a JSON document
+func jsonField(document, path string) (string, error) {
+	var value interface{}
+	decoder := json.NewDecoder(strings.NewReader(document))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return "", fmt.Errorf("field %s: result is not JSON: %w", path, err)
+	}
+
+	for _, part := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			next, ok := v[part]
+			if !ok {
+				return "", fmt.Errorf("field %s: key '%s' not found", path, part)
+			}
+			value = next
+		case []interface{}:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(v) {
+				return "", fmt.Errorf("field %s: invalid index '%s'", path, part)
+			}
+			value = v[i]
+		default:
+			return "", fmt.Errorf("field %s: cannot index into a %T with '%s'", path, value, part)
+		}
+	}
+	return jsonString(value)
+}
+
+// jsonString formats a decoded JSON value as a variable value: strings as they are, null
+// as empty, and objects and arrays as JSON
+func jsonString(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case nil:
+		return "", nil
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(v)
+		return string(data), err
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
@@ -0,0 +1,220 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// PublishURLEnv overrides the publish_url setting: the endpoint packages are uploaded to
+const PublishURLEnv = "LINEA_PUBLISH_URL"
+
+// RegistryTokenEnv holds the token linea publish authenticates with
+const RegistryTokenEnv = "LINEA_REGISTRY_TOKEN"
+
+// Parts of a version that linea publish --bump increments
+const (
+	BumpMajor = "major"
+	BumpMinor = "minor"
+	BumpPatch = "patch"
+)
+
+// ErrAlreadyPublished is returned when the version of a package is already in the registry
+var ErrAlreadyPublished = errors.New("already published")
+
+// PublishOptions holds the flags of `linea publish`
+type PublishOptions struct {
+	Bump   string // major, minor, or patch: increment the version in linea-package.yml first
+	DryRun bool   // Validate and pack the package without uploading it or changing its version
+}
+
+// PublishResult describes a published package
+type PublishResult struct {
+	Meta     *PackageMeta
+	Previous string // The version before --bump, if it was bumped
+	SHA256   string // Of the uploaded archive
+	URL      string // Where the archive was uploaded; empty for a dry run
+}
+
+// PublishURL returns the endpoint packages are uploaded to: LINEA_PUBLISH_URL, or the
+// publish_url setting of the global configuration
+func PublishURL() string {
+	if publishURL := os.Getenv(PublishURLEnv); publishURL != "" {
+		return publishURL
+	}
+	return CurrentUserConfig().PublishURL
+}
+
+// BumpVersion increments the major, minor, or patch part of a semantic version, dropping
+// any pre-release or build suffix and keeping a leading v
+func BumpVersion(version, part string) (string, error) {
+	prefix := ""
+	if strings.HasPrefix(version, "v") {
+		prefix, version = "v", version[1:]
+	}
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		version = version[:i]
+	}
+	parts := strings.Split(version, ".")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("version '%s' is not a semantic version (MAJOR.MINOR.PATCH)", prefix+version)
+	}
+	numbers := make([]int, 3)
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return "", fmt.Errorf("version '%s' is not a semantic version (MAJOR.MINOR.PATCH)", prefix+version)
+		}
+		numbers[i] = n
+	}
+	switch part {
+	case BumpMajor:
+		numbers = []int{numbers[0] + 1, 0, 0}
+	case BumpMinor:
+		numbers = []int{numbers[0], numbers[1] + 1, 0}
+	case BumpPatch:
+		numbers[2]++
+	default:
+		return "", fmt.Errorf("invalid bump '%s' (expected major, minor, or patch)", part)
+	}
+	return fmt.Sprintf("%s%d.%d.%d", prefix, numbers[0], numbers[1], numbers[2]), nil
+}
+
+// packageVersionLine matches the version: line of a linea-package.yml
+var packageVersionLine = regexp.MustCompile(`(?m)^(version:[ \t]*)(["']?)[^"'\s#]+(["']?)`)
+
+// setPackageVersion rewrites the version in the linea-package.yml of dir, leaving the rest
+// of the file as it is, and returns a function restoring the original file
+func setPackageVersion(dir, version string) (func(), error) {
+	path := filepath.Join(dir, PackageFileName)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if !packageVersionLine.Match(data) {
+		return nil, fmt.Errorf("cannot find the version: line in %s", path)
+	}
+	updated := packageVersionLine.ReplaceAll(data, []byte("${1}${2}"+version+"${3}"))
+	if err := os.WriteFile(path, updated, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return func() { os.WriteFile(path, data, 0644) }, nil
+}
+
+// PublishPackage validates the package in dir, bumps its version if asked to, checks that
+// the version is not in the registry yet, and uploads it to the publish endpoint. A
+// bumped version is kept only once the package is published
+func PublishPackage(dir string, opts PublishOptions) (result *PublishResult, err error) {
+	meta, err := ReadPackage(dir)
+	if err != nil {
+		return nil, err
+	}
+	endpoint := PublishURL()
+	if endpoint == "" && !opts.DryRun {
+		return nil, fmt.Errorf("no publish endpoint configured (set one with: linea config set publish_url <url>)")
+	}
+
+	result = &PublishResult{Meta: meta}
+	if opts.Bump != "" {
+		var version string
+		if version, err = BumpVersion(meta.Version, opts.Bump); err != nil {
+			return nil, err
+		}
+		var restore func()
+		if restore, err = setPackageVersion(dir, version); err != nil {
+			return nil, err
+		}
+		// err is the result of PublishPackage: the file is restored if publishing fails
+		defer func() {
+			if err != nil || opts.DryRun {
+				restore()
+			}
+		}()
+		result.Previous, meta.Version = meta.Version, version
+	}
+
+	if RegistryURL() != "" {
+		index, _, err := FetchPackageIndex()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := index.Latest(meta.Name, meta.Version); err == nil {
+			return nil, fmt.Errorf("%s %s is %w (use --bump major, minor, or patch)", meta.Name, meta.Version, ErrAlreadyPublished)
+		}
+	}
+
+	staging, err := os.MkdirTemp("", "linea-publish-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+	archive, _, err := BuildPackage(dir, filepath.Join(staging, meta.Name+"-"+meta.Version+".tar.gz"))
+	if err != nil {
+		return nil, err
+	}
+	if result.SHA256, err = FileSHA256(archive); err != nil {
+		return nil, err
+	}
+	if opts.DryRun {
+		return result, nil
+	}
+	if result.URL, err = uploadPackage(endpoint, meta, archive, result.SHA256); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// uploadPackage PUTs an archive to <endpoint>/<name>/<version>, authenticated with
+// LINEA_REGISTRY_TOKEN, and returns the URL it was uploaded to
+func uploadPackage(endpoint string, meta *PackageMeta, archive, checksum string) (string, error) {
+	target := strings.TrimSuffix(endpoint, "/") + "/" + url.PathEscape(meta.Name) + "/" + url.PathEscape(meta.Version)
+	f, err := os.Open(archive)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := transferContext()
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, f)
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = info.Size()
+	req.Header.Set("Content-Type", "application/gzip")
+	req.Header.Set("X-Linea-Package-Sha256", checksum)
+	if token := os.Getenv(RegistryTokenEnv); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload package: %s: %v", redactURL(target), MaskSecrets(err.Error()))
+	}
+	defer resp.Body.Close()
+
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	detail := resp.Status
+	if text := strings.TrimSpace(string(message)); text != "" {
+		detail += ": " + text
+	}
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return "", fmt.Errorf("the registry refused the upload (%s); check %s", detail, RegistryTokenEnv)
+	case resp.StatusCode == http.StatusConflict:
+		return "", fmt.Errorf("%s %s is %w (%s)", meta.Name, meta.Version, ErrAlreadyPublished, detail)
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return "", fmt.Errorf("failed to upload package: %s returned %s", redactURL(target), detail)
+	}
+	return redactURL(target), nil
+}
@@ -0,0 +1,177 @@
+package internal
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// Quoting styles of displayed commands (linea test --shellquote)
+const (
+	QuotePOSIX      = "posix"      // sh, bash, zsh: 'single quotes'
+	QuotePowerShell = "powershell" // PowerShell: 'single quotes', with & before a quoted program
+	QuoteCmd        = "cmd"        // cmd.exe and the Windows C runtime: "double quotes"
+	QuoteNone       = "none"       // Arguments joined with spaces, as written
+)
+
+// ParseQuoteStyle validates a --shellquote value
+func ParseQuoteStyle(style string) (string, error) {
+	switch style {
+	case QuotePOSIX, QuotePowerShell, QuoteCmd, QuoteNone:
+		return style, nil
+	}
+	return "", fmt.Errorf("invalid quoting style '%s' (expected posix, powershell, cmd, or none)", style)
+}
+
+// DefaultQuoteStyle returns the quoting style of the shell commands run in: POSIX, or on
+// Windows, cmd or PowerShell as selected by --shell or the shell setting
+func DefaultQuoteStyle() string {
+	if runtime.GOOS != "windows" {
+		return QuotePOSIX
+	}
+	return ShellQuoteStyle(StepShell(nil))
+}
+
+// ShellQuote renders cmd as a command line in style that a shell splits back into the
+// same arguments, so that it can be copied and pasted
+func ShellQuote(cmd []string, style string) string {
+	if style == QuoteNone {
+		return strings.Join(cmd, " ")
+	}
+
+	quoted := make([]string, len(cmd))
+	for i, arg := range cmd {
+		quoted[i] = QuoteArg(arg, style)
+	}
+	// PowerShell treats a quoted first token as a string, not a program to run
+	if style == QuotePowerShell && len(cmd) > 0 && quoted[0] != cmd[0] {
+		return "& " + strings.Join(quoted, " ")
+	}
+	return strings.Join(quoted, " ")
+}
+
+// QuoteArg quotes a single argument in style if it contains characters the shell would
+// interpret
+func QuoteArg(arg string, style string) string {
+	if arg != "" && !needsQuoting(arg, style) {
+		return arg
+	}
+
+	switch style {
+	case QuotePowerShell:
+		return "'" + strings.ReplaceAll(arg, "'", "''") + "'"
+	case QuoteCmd:
+		return quoteCmdArg(arg)
+	case QuoteNone:
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// needsQuoting reports whether arg has characters that are not safe to leave unquoted in
+// style; backslashes are only special in POSIX shells
+func needsQuoting(arg string, style string) bool {
+	safe := "_-+=.,/:@"
+	if style != QuotePOSIX {
+		safe += `\`
+	}
+	return strings.IndexFunc(arg, func(r rune) bool {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return false
+		}
+		return !strings.ContainsRune(safe, r)
+	}) >= 0
+}
+
+// quoteCmdArg quotes an argument the way the Windows C runtime splits command lines:
+// in double quotes, with embedded quotes and the backslashes before them escaped
+func quoteCmdArg(arg string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	backslashes := 0
+	for _, r := range arg {
+		switch r {
+		case '\\':
+			backslashes++
+			continue
+		case '"':
+			b.WriteString(strings.Repeat(`\`, 2*backslashes+1))
+		default:
+			b.WriteString(strings.Repeat(`\`, backslashes))
+		}
+		backslashes = 0
+		b.WriteRune(r)
+	}
+	b.WriteString(strings.Repeat(`\`, 2*backslashes))
+	b.WriteByte('"')
+	return b.String()
+}
+
+// SplitCommandLine splits a command line into arguments like a POSIX shell: words are
+// separated by spaces, 'single quotes' keep their content as it is, and "double quotes"
+// only treat \" and \\ as escapes. A backslash outside quotes escapes a space, a quote,
+// or a backslash and is kept before any other character, so Windows paths work
+// unquoted. Unquoted shell operators such as | and && are refused: a step runs one command
+func SplitCommandLine(line string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inWord, quoted := false, false
+	quote := byte(0)
+
+	endWord := func() error {
+		if !inWord {
+			return nil
+		}
+		word := current.String()
+		if !quoted && shellOperators[word] {
+			return fmt.Errorf("'%s' needs a shell: a step runs a single command (use sh -c '...' or one step per command)", word)
+		}
+		args = append(args, word)
+		current.Reset()
+		inWord, quoted = false, false
+		return nil
+	}
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				current.WriteByte(c)
+			}
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			} else if c == '\\' && i+1 < len(line) && (line[i+1] == '"' || line[i+1] == '\\') {
+				i++
+				current.WriteByte(line[i])
+			} else {
+				current.WriteByte(c)
+			}
+		case c == '\'' || c == '"':
+			quote = c
+			inWord, quoted = true, true
+		case c == '\\' && i+1 < len(line) && strings.IndexByte(" \t'\"\\", line[i+1]) >= 0:
+			i++
+			current.WriteByte(line[i])
+			inWord = true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if err := endWord(); err != nil {
+				return nil, err
+			}
+		default:
+			current.WriteByte(c)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote in command line", quote)
+	}
+	if err := endWord(); err != nil {
+		return nil, err
+	}
+	return args, nil
+}
@@ -0,0 +1,225 @@
+package internal
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Runners of a runner: block
+const (
+	RunnerSSH    = "ssh"    // Run the command on another host with the ssh client
+	RunnerDocker = "docker" // Run the command in a container with the Docker CLI
+)
+
+// RunnerConfig runs the command of a step somewhere else than on this machine (runner:)
+type RunnerConfig struct {
+	Type string `yaml:"type"`
+
+	// ssh: the host to connect to, and how; without a key, ssh's agent and config are used
+	Host string `yaml:"host,omitempty"`
+	User string `yaml:"user,omitempty"`
+	Port int    `yaml:"port,omitempty"`
+	Key  string `yaml:"key,omitempty"`
+	Sudo bool   `yaml:"sudo,omitempty"` // Run the command as root with sudo
+
+	// docker: the image of the container, the host:container[:options] volumes mounted
+	// in it, its working directory, and its environment variables
+	Image   string            `yaml:"image,omitempty"`
+	Volumes []string          `yaml:"volumes,omitempty"`
+	Workdir string            `yaml:"workdir,omitempty"`
+	Env     map[string]string `yaml:"env,omitempty"`
+}
+
+// Check validates the runner
+func (r *RunnerConfig) Check() error {
+	switch r.Type {
+	case RunnerSSH:
+		if r.Host == "" {
+			return fmt.Errorf("runner: ssh needs a host")
+		}
+		if r.Port < 0 || r.Port > 65535 {
+			return fmt.Errorf("runner.port: %d is out of range", r.Port)
+		}
+		if r.Image != "" || len(r.Volumes) > 0 || r.Workdir != "" || len(r.Env) > 0 {
+			return fmt.Errorf("image, volumes, workdir, and env apply to runner: docker, not ssh")
+		}
+	case RunnerDocker:
+		if r.Image == "" {
+			return fmt.Errorf("runner: docker needs an image")
+		}
+		if r.Host != "" || r.User != "" || r.Port != 0 || r.Key != "" || r.Sudo {
+			return fmt.Errorf("host, user, port, key, and sudo apply to runner: ssh, not docker")
+		}
+		for _, volume := range r.Volumes {
+			if !strings.Contains(volume, ":") {
+				return fmt.Errorf("runner.volumes: invalid volume '%s' (expected host:container)", volume)
+			}
+		}
+	case "":
+		return fmt.Errorf("runner needs a type (expected ssh or docker)")
+	default:
+		return fmt.Errorf("unknown runner type '%s' (expected ssh or docker)", r.Type)
+	}
+	return nil
+}
+
+// CheckRunner validates the runner: of a step against the fields it cannot apply to
+func CheckRunner(config *CommandConfig) error {
+	if config.Runner == nil {
+		return nil
+	}
+	switch {
+	case config.Type != "":
+		return fmt.Errorf("runner cannot be combined with type: %s", config.Type)
+	case config.Background:
+		return fmt.Errorf("background steps run on this machine and cannot have a runner")
+	case config.Runner.Type == RunnerDocker && config.Limits != nil && config.Limits.Nice != nil:
+		return fmt.Errorf("limits.nice does not apply to runner: docker")
+	}
+	return config.Runner.Check()
+}
+
+// substitute returns the runner with variables substituted in its fields
+func (r *RunnerConfig) substitute(substitute func(string) string) *RunnerConfig {
+	resolved := *r
+	resolved.Host = substitute(r.Host)
+	resolved.User = substitute(r.User)
+	resolved.Key = substitute(r.Key)
+	resolved.Image = substitute(r.Image)
+	resolved.Workdir = substitute(r.Workdir)
+	resolved.Volumes = make([]string, len(r.Volumes))
+	for i, volume := range r.Volumes {
+		resolved.Volumes[i] = substitute(volume)
+	}
+	resolved.Env = make(map[string]string, len(r.Env))
+	for name, value := range r.Env {
+		resolved.Env[name] = substitute(value)
+	}
+	return &resolved
+}
+
+// templates returns the fields of the runner that may reference variables
+func (r *RunnerConfig) templates() []string {
+	templates := []string{r.Host, r.User, r.Key, r.Image, r.Workdir}
+	templates = append(templates, r.Volumes...)
+	for _, value := range r.Env {
+		templates = append(templates, value)
+	}
+	return templates
+}
+
+// commandLine wraps the command of a step in the client that runs it remotely; dir is
+// the directory of the workflow, which relative volumes are in
+func (r *RunnerConfig) commandLine(cmd []string, limits *ResourceLimits, interactive bool, dir string) []string {
+	if r.Type == RunnerDocker {
+		return r.dockerCommandLine(cmd, limits, interactive, dir)
+	}
+	return r.sshCommandLine(cmd, limits, interactive)
+}
+
+// sshCommandLine runs cmd in the remote user's shell, quoted for a POSIX shell, with the
+// memory, CPU time, and niceness of limits applied on the remote host
+func (r *RunnerConfig) sshCommandLine(cmd []string, limits *ResourceLimits, interactive bool) []string {
+	remote := cmd
+	if limits != nil {
+		remote = limitCommandLine(remote, limits)
+	}
+	if r.Sudo {
+		sudo := []string{"sudo", "-n", "--"}
+		if interactive {
+			// With a terminal, sudo may ask for the password
+			sudo = []string{"sudo", "--"}
+		}
+		remote = append(sudo, remote...)
+	}
+
+	line := []string{"ssh"}
+	if interactive {
+		line = append(line, "-t")
+	} else {
+		// Fail rather than wait for a password or host key confirmation nobody can type
+		line = append(line, "-o", "BatchMode=yes")
+	}
+	if r.Port != 0 {
+		line = append(line, "-p", strconv.Itoa(r.Port))
+	}
+	if r.Key != "" {
+		line = append(line, "-i", r.Key)
+	}
+	if r.User != "" {
+		line = append(line, "-l", r.User)
+	}
+	return append(line, "--", r.Host, ShellQuote(remote, QuotePOSIX))
+}
+
+// dockerCommandLine runs cmd in a new container, removed when it exits, with the memory
+// and CPU time of limits as the container's limits
+func (r *RunnerConfig) dockerCommandLine(cmd []string, limits *ResourceLimits, interactive bool, dir string) []string {
+	line := []string{"docker", "run", "--rm", "-i"}
+	if interactive {
+		line = append(line, "-t")
+	}
+	for _, volume := range r.Volumes {
+		line = append(line, "-v", resolveVolume(volume, dir))
+	}
+	if r.Workdir != "" {
+		line = append(line, "-w", r.Workdir)
+	}
+	names := make([]string, 0, len(r.Env))
+	for name := range r.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		line = append(line, "-e", name+"="+r.Env[name])
+	}
+	if memory := limits.memoryBytes(); memory > 0 {
+		line = append(line, "--memory", strconv.FormatInt(memory, 10))
+	}
+	if cpu := limits.cpuTime(); cpu > 0 {
+		seconds := int64((cpu + time.Second - 1) / time.Second)
+		line = append(line, "--ulimit", fmt.Sprintf("cpu=%d:%d", seconds, seconds))
+	}
+	line = append(line, "--", r.Image)
+	return append(line, cmd...)
+}
+
+// resolveVolume makes the host path of a relative volume (./src:/src) absolute, relative
+// to dir; named volumes and absolute paths are left as they are
+func resolveVolume(volume string, dir string) string {
+	host, container, _ := strings.Cut(volume, ":")
+	if host != "." && host != ".." && !strings.HasPrefix(host, "./") && !strings.HasPrefix(host, "../") {
+		return volume
+	}
+	if abs, err := filepath.Abs(filepath.Join(dir, host)); err == nil {
+		host = abs
+	}
+	return host + ":" + container
+}
+
+// runRemote runs the client command line of a step with a runner:, as built by
+// BuildCommand. Only limits.output applies here; the other limits apply remotely or to
+// the container
+func runRemote(step *StepCommand) error {
+	config, line := step.Config, step.Args
+	if len(line) == 0 {
+		return fmt.Errorf("command is empty")
+	}
+	env := StepEnvironment(config)
+	if config.Interactive {
+		return executeInteractive(line, env, nil)
+	}
+	var limits *ResourceLimits
+	if config.Limits != nil && config.Limits.Output != "" {
+		limits = &ResourceLimits{Output: config.Limits.Output}
+	}
+	execCmd := exec.Command(line[0], line[1:]...)
+	execCmd.Env = env
+	execCmd.Stdin = step.Stdin
+	return runCapturingStderr(execCmd, limits, step.Stdout, step.Stderr)
+}
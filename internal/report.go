@@ -0,0 +1,605 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats accepted by --output
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+	OutputYAML = "yaml"
+)
+
+// Summary formats accepted by --summary
+const (
+	SummaryText = OutputText
+	SummaryJSON = OutputJSON
+	SummaryNone = "none" // Never print a summary
+)
+
+// Step statuses in reports
+const (
+	StepSucceeded = "success"
+	StepFailed    = "failed"
+	StepNotRun    = "not_run" // An earlier step failed
+	StepDryRun    = "dry_run" // linea test
+)
+
+// RunReport is the machine-readable result of `linea run` or `linea test` with --output
+type RunReport struct {
+	Workflow   string            `json:"workflow" yaml:"workflow"`
+	Path       string            `json:"path" yaml:"path"`
+	Mode       string            `json:"mode" yaml:"mode"` // run or test
+	Status     string            `json:"status" yaml:"status"`
+	ExitCode   int               `json:"exit_code" yaml:"exit_code"`
+	Error      string            `json:"error,omitempty" yaml:"error,omitempty"`
+	Variables  map[string]string `json:"variables,omitempty" yaml:"variables,omitempty"` // Values passed with -s/--set
+	Start      time.Time         `json:"start" yaml:"start"`
+	DurationMs int64             `json:"duration_ms" yaml:"duration_ms"`
+	Steps      []StepResult      `json:"steps" yaml:"steps"`
+
+	RunID          string `json:"run_id,omitempty" yaml:"run_id,omitempty"`                   // ID in the run history
+	IdempotencyKey string `json:"idempotency_key,omitempty" yaml:"idempotency_key,omitempty"` // Set with --idempotency-key
+	Duplicate      bool   `json:"duplicate,omitempty" yaml:"duplicate,omitempty"`             // Not run: the result is that of the earlier run RunID
+}
+
+// StepResult is the result of one step of a workflow
+type StepResult struct {
+	Index      int                  `json:"index" yaml:"index"` // 1-based
+	Name       string               `json:"name,omitempty" yaml:"name,omitempty"`
+	Group      string               `json:"group,omitempty" yaml:"group,omitempty"`     // The workflow calls and group: the step is in
+	Command    []string             `json:"command,omitempty" yaml:"command,omitempty"` // Secret values are masked
+	Status     string               `json:"status" yaml:"status"`
+	ExitCode   int                  `json:"exit_code" yaml:"exit_code"`
+	Error      string               `json:"error,omitempty" yaml:"error,omitempty"`
+	DurationMs int64                `json:"duration_ms" yaml:"duration_ms"`
+	Stdout     *string              `json:"stdout,omitempty" yaml:"stdout,omitempty"`       // Set with --capture
+	Stderr     *string              `json:"stderr,omitempty" yaml:"stderr,omitempty"`       // Set with --capture
+	Variables  []VariableResolution `json:"variables,omitempty" yaml:"variables,omitempty"` // linea test --resolve
+	Confirm    string               `json:"confirm,omitempty" yaml:"confirm,omitempty"`     // linea test: the confirm: prompt linea run asks
+}
+
+// WorkflowReport is the machine-readable description printed by `linea help --output`
+type WorkflowReport struct {
+	Workflow string          `json:"workflow" yaml:"workflow"`
+	Path     string          `json:"path" yaml:"path"`
+	Inputs   []WorkflowInput `json:"inputs,omitempty" yaml:"inputs,omitempty"` // Positional arguments
+	Steps    []StepReport    `json:"steps" yaml:"steps"`
+}
+
+// StepReport describes one step of a workflow
+type StepReport struct {
+	Index       int               `json:"index" yaml:"index"`
+	Name        string            `json:"name,omitempty" yaml:"name,omitempty"`
+	Description string            `json:"description,omitempty" yaml:"description,omitempty"`
+	Command     string            `json:"command" yaml:"command"`
+	Type        string            `json:"type,omitempty" yaml:"type,omitempty"` // Built-in step type, instead of a command
+	Subcommand  string            `json:"subcommand,omitempty" yaml:"subcommand,omitempty"`
+	Args        []string          `json:"args,omitempty" yaml:"args,omitempty"`
+	Variables   map[string]string `json:"variables,omitempty" yaml:"variables,omitempty"`
+	Secrets     map[string]string `json:"secrets,omitempty" yaml:"secrets,omitempty"` // Name to backend, never values
+	FullCommand []string          `json:"full_command,omitempty" yaml:"full_command,omitempty"`
+	Error       string            `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// RunSummary is the per-step timing summary printed after a run (--summary json)
+type RunSummary struct {
+	Status     string        `json:"status"`
+	Failures   int           `json:"failures"`
+	DurationMs int64         `json:"duration_ms"`
+	Steps      []SummaryStep `json:"steps"`
+}
+
+// SummaryStep is one row of a RunSummary: a step, or a workflow that failed or was not
+// run before any of its steps started (Index 0)
+type SummaryStep struct {
+	Workflow   string `json:"workflow"`
+	Index      int    `json:"index"`
+	Name       string `json:"name,omitempty"`
+	Status     string `json:"status"`
+	ExitCode   int    `json:"exit_code"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// MaxFailureExitCode caps the exit status of `linea run --keep-going`, which is the number
+// of failures; 126 and above are reserved by shells
+const MaxFailureExitCode = 125
+
+// ReportOptions controls how RunStepsReport executes steps
+type ReportOptions struct {
+	Stdout    io.Writer    // Receives the commands' output (for --output, standard error, so that
+	Stderr    io.Writer    // standard output only holds the report)
+	Capture   bool         // Also record each step's stdout and stderr in its result
+	Verbose   bool         // Write each command to Stdout before running it
+	KeepGoing bool         // Run the remaining steps after a failure
+	Asserts   bool         // Run the remaining steps after a failed type: assert step (linea test --run-asserts)
+	Progress  *Progress    // Receives a step_start and step_end event for every step
+	Workflow  string       // Workflow name of the progress events and of StepInfo
+	Display   *StepDisplay // Shows the step running and the outcome of each step (--progress)
+	Runner    Runner       // Runs the steps' commands; nil is LocalRunner
+
+	Middleware []Middleware // Called around every step, after Progress and Display
+
+	// Checkpoint records the steps that complete, for --resume; the steps it has as done
+	// are skipped
+	Checkpoint *Checkpoint
+}
+
+// middleware returns the middleware chain of a run
+func (o ReportOptions) middleware() middlewareChain {
+	var chain middlewareChain
+	if o.Progress != nil {
+		chain = append(chain, o.Progress.Middleware())
+	}
+	if o.Display != nil {
+		chain = append(chain, o.Display.Middleware())
+	}
+	return append(chain, o.Middleware...)
+}
+
+// ParseSummaryFormat validates a --summary value
+func ParseSummaryFormat(format string) (string, error) {
+	switch format {
+	case SummaryText, SummaryJSON, SummaryNone:
+		return format, nil
+	}
+	return "", fmt.Errorf("invalid summary format '%s' (expected text, json, or none)", format)
+}
+
+// ParseOutputFormat validates an --output value
+func ParseOutputFormat(format string) (string, error) {
+	switch format {
+	case OutputText, OutputJSON, OutputYAML:
+		return format, nil
+	}
+	return "", fmt.Errorf("invalid output format '%s' (expected text, json, or yaml)", format)
+}
+
+// WriteStructured writes v to w as indented JSON or YAML
+func WriteStructured(w io.Writer, format string, v interface{}) error {
+	switch format {
+	case OutputJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false) // Commands often contain <, >, and &
+		return encoder.Encode(v)
+	case OutputYAML:
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(v); err != nil {
+			return err
+		}
+		return encoder.Close()
+	}
+	return fmt.Errorf("invalid output format '%s' (expected json or yaml)", format)
+}
+
+// NewRunReport starts a report for a workflow file
+func NewRunReport(path, mode string, overrideVars map[string]string, start time.Time) *RunReport {
+	report := &RunReport{
+		Workflow: WorkflowName(path),
+		Path:     path,
+		Mode:     mode,
+		Status:   StepSucceeded,
+		Start:    start.UTC(),
+		Steps:    []StepResult{},
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		report.Path = abs
+	}
+	if len(overrideVars) > 0 {
+		report.Variables = make(map[string]string, len(overrideVars))
+		for k, v := range overrideVars {
+			report.Variables[k] = MaskSecrets(v)
+		}
+	}
+	return report
+}
+
+// Finish records the outcome of the run
+func (r *RunReport) Finish(err error) {
+	r.DurationMs = time.Since(r.Start).Milliseconds()
+	r.ExitCode = ExitCode(err)
+	if err != nil {
+		r.Status = StepFailed
+		r.Error = MaskSecrets(err.Error())
+	}
+}
+
+// PriorRunReport reports the earlier successful run that a run with the same idempotency
+// key and inputs duplicates, in place of running the workflow again
+func PriorRunReport(prior *RunRecord) *RunReport {
+	report := NewRunReport(prior.Path, "run", prior.Variables, prior.Start)
+	report.DurationMs = prior.DurationMs
+	report.RunID = prior.ID
+	report.IdempotencyKey = prior.IdempotencyKey
+	report.Duplicate = true
+	return report
+}
+
+// RunStepsReport executes steps in order like ExecuteMultipleCommands and returns a result
+// for every step. It stops at the first failure, leaving the remaining steps not_run
+// except after: and on_failure: steps, unless KeepGoing is set; the error is that of the
+// first failed step, or a *CancelledError if a signal cancelled the run
+func RunStepsReport(configs []*CommandConfig, overrideVars map[string]string, opts ReportOptions) ([]StepResult, error) {
+	results := make([]StepResult, 0, len(configs))
+	runner := opts.Runner
+	if runner == nil {
+		runner = LocalRunner{}
+	}
+	chain := opts.middleware()
+	opts.Checkpoint.restore()
+	var failure, assertFailure error
+	for i, config := range configs {
+		result := StepResult{Index: i + 1, Name: config.Name, Group: stepScope(config)}
+		info := StepInfo{Workflow: opts.Workflow, Index: i + 1, Steps: len(configs), Config: config}
+		if opts.Checkpoint.isDone(i+1, config) && Cancelled() == nil {
+			result.Status = StepSkipped
+			results = append(results, result)
+			opts.Checkpoint.record(config, result)
+			chain.after(info, result)
+			continue
+		}
+		if status := StepStatusAfter(config, failure != nil, opts.KeepGoing); status != "" {
+			result.Status = status
+			results = append(results, result)
+			opts.Checkpoint.record(config, result)
+			chain.after(info, result)
+			continue
+		}
+		if opts.Verbose && len(configs) > 1 && opts.Display == nil {
+			fmt.Fprintf(opts.Stdout, "\n[%d/%d] ", i+1, len(configs))
+		}
+
+		run, err := ShouldRunStep(config, overrideVars)
+		if err == nil && !run {
+			result.Status = StepSkipped
+			results = append(results, result)
+			opts.Checkpoint.record(config, result)
+			chain.after(info, result)
+			continue
+		}
+		var cmd []string
+		var stdin io.Reader
+		if err == nil {
+			cmd, err = BuildCommand(config, overrideVars)
+		}
+		if err == nil {
+			err = CheckStepPolicy(config, overrideVars)
+		}
+		var cache *StepCache
+		if err == nil {
+			var cached *StepCacheRecord
+			if cache, cached, err = LookupStepCache(config, i+1, cmd, overrideVars); cached != nil {
+				cached.restore(config)
+				if opts.Display == nil {
+					cached.notice(opts.Stdout, stepLabel(config, cmd))
+				}
+				result.Command, result.Status, result.ExitCode = maskedCommand(cmd), StepSkipped, cached.ExitCode
+				results = append(results, result)
+				opts.Checkpoint.record(config, result)
+				chain.after(info, result)
+				continue
+			}
+		}
+		if err == nil {
+			err = ConfirmStep(config, overrideVars)
+		}
+		if err == nil {
+			stdin, err = OpenStepInput(config, overrideVars)
+		}
+		if err == nil {
+			info.Command = maskedCommand(cmd)
+			if opts.Verbose {
+				fmt.Fprintf(opts.Stdout, "Executing: %s\n", FormatCommand(cmd))
+			}
+			if err = chain.before(info); err != nil {
+				if closer, ok := stdin.(io.Closer); ok {
+					closer.Close()
+				}
+			}
+		}
+		if err != nil {
+			result.Status, result.ExitCode, result.Error = StepFailed, ExitCode(err), MaskSecrets(err.Error())
+			results = append(results, result)
+			opts.Checkpoint.record(config, result)
+			chain.after(info, result)
+			if opts.KeepGoing {
+				fmt.Fprintf(opts.Stderr, "Error building command %d: %v\n", i+1, err)
+			}
+			if failure == nil {
+				failure = stepBuildError(len(configs), i, err)
+			}
+			continue
+		}
+		result.Command = info.Command
+
+		// A step's own capture: field stores its output like --capture does for every step
+		capture := opts.Capture || config.Capture
+		stdout, stderr := opts.Stdout, opts.Stderr
+		var stdoutBuf, stderrBuf bytes.Buffer
+		if capture {
+			stdout = io.MultiWriter(&stdoutBuf, opts.Stdout)
+			stderr = io.MultiWriter(&stderrBuf, opts.Stderr)
+		}
+		stdout, stderr = opts.Display.Writer(stdout), opts.Display.Writer(stderr)
+		stdout, stderr, flushOutput := chain.outputWriters(info, stdout, stderr)
+
+		start := time.Now()
+		err = ExecuteStepWithRunner(runner, config, cmd, stdin, stdout, stderr)
+		flushOutput()
+		elapsed := time.Since(start)
+		result.DurationMs = elapsed.Milliseconds()
+		result.ExitCode = ExitCode(err)
+		if err == nil {
+			// An allowed non-zero exit code is reported as it is
+			result.ExitCode = lastStepExitCode()
+		}
+		result.Status = StepSucceeded
+		if err == nil {
+			cache.Save(config, result.ExitCode)
+		} else {
+			result.Status, result.Error = StepFailed, MaskSecrets(err.Error())
+			if config.Capture {
+				if tail := lastLines(stderrBuf.String(), capturedErrorLines); tail != "" {
+					result.Error += ": " + MaskSecrets(tail)
+				}
+			}
+		}
+		if capture {
+			out, errOut := MaskSecrets(stdoutBuf.String()), MaskSecrets(stderrBuf.String())
+			result.Stdout, result.Stderr = &out, &errOut
+		}
+		results = append(results, result)
+		opts.Checkpoint.record(config, result)
+		chain.after(info, result)
+		if err != nil {
+			assertion := opts.Asserts && config.Type == StepTypeAssert
+			if opts.KeepGoing || assertion {
+				fmt.Fprintf(opts.Stderr, "Error executing command %d: %v\n", i+1, err)
+			}
+			switch {
+			case assertion && assertFailure == nil:
+				assertFailure = stepExecError(len(configs), i, err)
+			case !assertion && failure == nil:
+				failure = stepExecError(len(configs), i, err)
+			}
+		}
+	}
+	if cancelled := Cancelled(); cancelled != nil {
+		return results, cancelled
+	}
+	if failure == nil {
+		failure = assertFailure
+	}
+	return results, failure
+}
+
+// capturedErrorLines is how many lines of stderr a step with capture: adds to its error
+const capturedErrorLines = 5
+
+// lastLines returns the last n non-empty lines of s, joined by newlines
+func lastLines(s string, n int) string {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimRight(s, "\n"), "\n") {
+		if line = strings.TrimRight(line, "\r"); strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// stepLabel describes a step in the step display: its name, or its masked command,
+// prefixed by its scope (see stepScope)
+func stepLabel(config *CommandConfig, command []string) string {
+	if scope := stepScope(config); scope != "" {
+		inner := *config
+		inner.Group, inner.Call = "", nil
+		return scope + "/" + stepLabel(&inner, command)
+	}
+	if config.Name != "" {
+		return config.Name
+	}
+	if len(command) > 0 {
+		return FormatCommand(command)
+	}
+	if config.Type != "" {
+		return config.Type
+	}
+	return config.Command
+}
+
+// RunStartEvent returns the run_start event of a report
+func RunStartEvent(report *RunReport) ProgressEvent {
+	return ProgressEvent{Event: EventRunStart, Time: report.Start, Workflow: report.Workflow, Path: report.Path}
+}
+
+// RunEndEvent returns the run_end event of a finished report
+func RunEndEvent(report *RunReport) ProgressEvent {
+	return ProgressEvent{
+		Event:      EventRunEnd,
+		Workflow:   report.Workflow,
+		Path:       report.Path,
+		Steps:      len(report.Steps),
+		Status:     report.Status,
+		ExitCode:   &report.ExitCode,
+		DurationMs: &report.DurationMs,
+		Error:      report.Error,
+	}
+}
+
+// DryRunStepsReport builds every step without running it, like `linea test`
+// With resolve set, each result explains how its variables were resolved
+func DryRunStepsReport(configs []*CommandConfig, overrideVars map[string]string, resolve bool) ([]StepResult, error) {
+	results := make([]StepResult, 0, len(configs))
+	var failure error
+	for i, config := range configs {
+		result := StepResult{Index: i + 1, Name: config.Name, Group: stepScope(config), Status: StepDryRun}
+		cmd, err := BuildCommand(config, overrideVars)
+		if err != nil {
+			result.Status, result.ExitCode, result.Error = StepFailed, ExitCode(err), MaskSecrets(err.Error())
+			if failure == nil {
+				failure = stepBuildError(len(configs), i, err)
+			}
+		} else {
+			result.Command = maskedCommand(cmd)
+			result.Confirm, _ = ConfirmMessage(config, overrideVars)
+		}
+		if resolve {
+			if resolutions, err := ExplainVariables(config, overrideVars); err == nil {
+				result.Variables = resolutions
+			}
+		}
+		results = append(results, result)
+		if failure != nil {
+			break
+		}
+	}
+	return results, failure
+}
+
+// DescribeWorkflow returns the description of a workflow's steps printed by `linea help`
+func DescribeWorkflow(path string, configs []*CommandConfig) *WorkflowReport {
+	report := &WorkflowReport{Workflow: WorkflowName(path), Path: path, Steps: []StepReport{}}
+	if abs, err := filepath.Abs(path); err == nil {
+		report.Path = abs
+	}
+	if len(configs) > 0 && configs[0].Metadata != nil {
+		report.Inputs = configs[0].Metadata.Inputs
+	}
+	for i, config := range configs {
+		step := StepReport{
+			Index:       i + 1,
+			Name:        config.Name,
+			Description: config.Description,
+			Command:     config.Command,
+			Type:        config.Type,
+			Subcommand:  config.Subcommand,
+			Args:        config.Args,
+			Variables:   config.Variables,
+		}
+		if len(config.Secrets) > 0 {
+			step.Secrets = make(map[string]string, len(config.Secrets))
+			for name, ref := range config.Secrets {
+				from := ref.From
+				if from == "" {
+					from = SecretFromFile
+				}
+				step.Secrets[name] = from
+			}
+		}
+		if cmd, err := BuildCommand(config, nil); err != nil {
+			step.Error = MaskSecrets(err.Error())
+		} else {
+			step.FullCommand = maskedCommand(cmd)
+		}
+		report.Steps = append(report.Steps, step)
+	}
+	return report
+}
+
+// FailedSteps returns the number of failed steps in a report; a workflow that failed
+// before any step ran (for example, it could not be parsed) counts as one failure
+func (r *RunReport) FailedSteps() int {
+	failed := 0
+	for _, step := range r.Steps {
+		if step.Status == StepFailed {
+			failed++
+		}
+	}
+	if failed == 0 && r.Status == StepFailed {
+		failed = 1
+	}
+	return failed
+}
+
+// FailureExitCode returns the exit status for a number of failures, capped at MaxFailureExitCode
+func FailureExitCode(failures int) int {
+	if failures > MaxFailureExitCode {
+		return MaxFailureExitCode
+	}
+	return failures
+}
+
+// stepBuildError wraps a failure to build step index the way the text output reports it
+func stepBuildError(steps, index int, err error) error {
+	if steps == 1 {
+		return err
+	}
+	return fmt.Errorf("error building command %d: %w", index+1, err)
+}
+
+// stepExecError wraps a failure of step index the way the text output reports it
+func stepExecError(steps, index int, err error) error {
+	if steps == 1 {
+		return fmt.Errorf("command execution failed: %w", err)
+	}
+	return fmt.Errorf("command %d execution failed: %w", index+1, err)
+}
+
+// maskedCommand returns a copy of cmd with secret values masked
+func maskedCommand(cmd []string) []string {
+	masked := make([]string, len(cmd))
+	for i, part := range cmd {
+		masked[i] = MaskSecrets(part)
+	}
+	return masked
+}
+
+// SummarizeRuns returns the summary of the reports of a run, one row per step
+func SummarizeRuns(reports []*RunReport) *RunSummary {
+	summary := &RunSummary{Status: StepSucceeded, Steps: []SummaryStep{}}
+	for _, report := range reports {
+		summary.DurationMs += report.DurationMs
+		summary.Failures += report.FailedSteps()
+		if report.Status == StepFailed {
+			summary.Status = StepFailed
+		}
+		if len(report.Steps) == 0 {
+			row := SummaryStep{Workflow: report.Workflow, Status: report.Status}
+			if report.Status == StepFailed {
+				row.ExitCode, row.DurationMs = report.ExitCode, report.DurationMs
+			}
+			summary.Steps = append(summary.Steps, row)
+			continue
+		}
+		for _, step := range report.Steps {
+			summary.Steps = append(summary.Steps, SummaryStep{
+				Workflow:   report.Workflow,
+				Index:      step.Index,
+				Name:       step.Name,
+				Status:     step.Status,
+				ExitCode:   step.ExitCode,
+				DurationMs: step.DurationMs,
+			})
+		}
+	}
+	return summary
+}
+
+// Slowest returns the step that took longest to run, or nil if no step ran
+func (s *RunSummary) Slowest() *SummaryStep {
+	var slowest *SummaryStep
+	for i := range s.Steps {
+		step := &s.Steps[i]
+		if step.Index == 0 || step.Status == StepNotRun || step.Status == StepSkipped {
+			continue
+		}
+		if slowest == nil || step.DurationMs > slowest.DurationMs {
+			slowest = step
+		}
+	}
+	return slowest
+}
@@ -0,0 +1,249 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Variable sources, in the order BuildCommand layers them
+// {name} resolves from built-ins, variables:, secrets:, and providers; $name additionally
+// from -s/--set, which takes precedence
+const (
+	SourceBuiltin  = "built-in"
+	SourceInput    = "inputs:" // Of the group the step is in
+	SourceYAML     = "variables:"
+	SourceSecret   = "secrets:"
+	SourceProvider = "provider"
+	SourceSet      = "--set"
+	SourceDefault  = "|default"
+)
+
+// VariableCandidate is one source that has a value for a variable
+type VariableCandidate struct {
+	Source string `json:"source" yaml:"source"`
+	Value  string `json:"value" yaml:"value"`
+}
+
+// VariableResolution explains how a placeholder in a step's args is resolved
+type VariableResolution struct {
+	Reference string              `json:"reference" yaml:"reference"` // As written, e.g. {name}, {name|upper}, or $name
+	Name      string              `json:"name" yaml:"name"`
+	Value     string              `json:"value" yaml:"value"`                             // The value substituted; secret values are masked
+	Source    string              `json:"source" yaml:"source"`                           // The source of Value, empty if the placeholder is unresolved
+	Overrides []VariableCandidate `json:"overrides,omitempty" yaml:"overrides,omitempty"` // Lower-precedence sources that also have a value
+	Ignored   []VariableCandidate `json:"ignored,omitempty" yaml:"ignored,omitempty"`     // Sources that do not apply to this syntax (--set for {name})
+	Hint      string              `json:"hint,omitempty" yaml:"hint,omitempty"`           // How to resolve an unresolved placeholder
+}
+
+// Resolved reports whether the placeholder has a value
+func (r VariableResolution) Resolved() bool {
+	return r.Source != ""
+}
+
+// ExplainVariables returns how each placeholder in a step's args resolves, in the order the
+// placeholders first appear. It uses the same sources and precedence as BuildCommand
+func ExplainVariables(config *CommandConfig, overrideVars map[string]string) ([]VariableResolution, error) {
+	// Layers of {name} sources, lowest precedence first
+	type layer struct {
+		source string
+		values map[string]string
+	}
+	builtinVars := BuiltinVariables(config)
+	layers := []layer{{SourceBuiltin, builtinVars}}
+	if config.Call != nil {
+		var err error
+		if overrideVars, err = config.Call.setValues(overrideVars); err != nil {
+			return nil, err
+		}
+	}
+	if config.Group != "" {
+		inputs, err := groupInputs(config, builtinVars, overrideVars)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, layer{SourceInput, inputs})
+		overrideVars = nil
+	}
+	layers = append(layers, layer{SourceYAML, config.Variables})
+	if len(config.Secrets) > 0 {
+		secretVars, err := ResolveSecrets(config.Secrets)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, layer{SourceSecret, secretVars})
+	}
+	if len(config.Providers) > 0 {
+		providerVars, err := ResolveProviderVariables(config)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, layer{SourceProvider, providerVars})
+	}
+
+	yamlVars := make(map[string]string)
+	for _, l := range layers {
+		for k, v := range l.values {
+			yamlVars[k] = v
+		}
+	}
+	// The value shown for a variable composed of others is the one substituted
+	dollarVars := make(map[string]string, len(yamlVars)+len(overrideVars))
+	for k, v := range yamlVars {
+		dollarVars[k] = v
+	}
+	for k, v := range overrideVars {
+		dollarVars[k] = v
+	}
+	if err := resolveNestedVariables(yamlVars, dollarVars, variableTemplates(config, overrideVars)); err != nil {
+		return nil, err
+	}
+
+	// candidates lists the {name} sources with a value, highest precedence first
+	candidates := func(name string) []VariableCandidate {
+		var found []VariableCandidate
+		for i := len(layers) - 1; i >= 0; i-- {
+			if value, ok := layers[i].values[name]; ok {
+				source := layers[i].source
+				if source == SourceProvider {
+					source = SourceProvider + " " + config.Providers[name].Provider
+				}
+				found = append(found, VariableCandidate{Source: source, Value: MaskSecrets(value)})
+			}
+		}
+		return found
+	}
+
+	var resolutions []VariableResolution
+	for _, ref := range orderedReferences(config.Args) {
+		r := VariableResolution{Reference: ref.text, Name: ref.name}
+		found := candidates(ref.name)
+		setValue, isSet := overrideVars[ref.name]
+
+		if ref.dollar {
+			if isSet {
+				r.Source = SourceSet
+				r.Overrides = found
+			} else if len(found) > 0 {
+				r.Source = found[0].Source
+				r.Overrides = found[1:]
+			} else {
+				r.Hint = fmt.Sprintf("declare it under variables: or pass -s %s=<value>", ref.name)
+			}
+			if r.Resolved() {
+				r.Value = MaskSecrets(dollarVars[ref.name])
+			}
+			if ref.path != "" && r.Resolved() {
+				selected, _, err := lookupVariable(ref.path, dollarVars)
+				if err != nil {
+					r.Source, r.Hint = "", err.Error()
+				}
+				r.Value = MaskSecrets(selected)
+			}
+			resolutions = append(resolutions, r)
+			continue
+		}
+
+		if isSet {
+			r.Ignored = append(r.Ignored, VariableCandidate{Source: SourceSet, Value: MaskSecrets(setValue)})
+		}
+		if len(found) > 0 {
+			r.Source, r.Value = found[0].Source, MaskSecrets(yamlVars[ref.name])
+			r.Overrides = found[1:]
+		}
+		if ref.steps == nil && ref.path != "" && len(found) > 0 {
+			// {targets[0]} shows the selected item rather than the whole list
+			value, _, err := lookupVariable(ref.path, yamlVars)
+			if err != nil {
+				r.Source, r.Hint = "", err.Error()
+			}
+			r.Value = MaskSecrets(value)
+		}
+		if ref.steps != nil {
+			value, ok := evaluatePipeline(ref.path, ref.steps, yamlVars)
+			switch {
+			case ok && len(found) == 0:
+				r.Source, r.Value = SourceDefault, value
+			case ok:
+				r.Value = MaskSecrets(value)
+			default:
+				r.Source = ""
+			}
+		}
+		if !r.Resolved() && r.Hint == "" {
+			r.Hint = "declare it under variables:"
+			if isSet {
+				r.Hint += fmt.Sprintf(", or write $%s to use the -s/--set value", ref.name)
+			}
+		}
+		resolutions = append(resolutions, r)
+	}
+	return resolutions, nil
+}
+
+// placeholderRef is a variable placeholder found in args
+type placeholderRef struct {
+	text   string
+	name   string
+	path   string // {targets[0]}: the reference selecting into the variable name
+	dollar bool
+	steps  []templateStep // {name|func} pipeline steps
+}
+
+// orderedReferences returns the distinct placeholders in args in order of appearance
+func orderedReferences(args []string) []placeholderRef {
+	var refs []placeholderRef
+	seen := make(map[string]bool)
+	add := func(ref placeholderRef) {
+		if !seen[ref.text] {
+			seen[ref.text] = true
+			refs = append(refs, ref)
+		}
+	}
+
+	for _, arg := range args {
+		for i := 0; i < len(arg); i++ {
+			switch {
+			case arg[i] == '{':
+				end := strings.IndexByte(arg[i+1:], '}')
+				if end == -1 {
+					continue
+				}
+				expr := arg[i+1 : i+1+end]
+				if name, steps, ok := parsePipeline(expr); ok {
+					add(placeholderRef{text: "{" + expr + "}", name: variableReferenceName(name), path: name, steps: steps})
+				} else if isVariableName(expr) {
+					add(placeholderRef{text: "{" + expr + "}", name: expr})
+				} else if name, _, ok := splitVariablePath(expr); ok {
+					add(placeholderRef{text: "{" + expr + "}", name: name, path: expr})
+				}
+			case arg[i] == '$' && i+1 < len(arg):
+				rest := arg[i+1:]
+				if strings.HasPrefix(rest, "{") {
+					// ${name} is $name; ${targets[0]} selects into $targets
+					end := strings.IndexByte(rest, '}')
+					if end == -1 {
+						continue
+					}
+					if name, keys, ok := splitVariablePath(rest[1:end]); ok {
+						ref := placeholderRef{text: "$" + rest[:end+1], name: name, dollar: true}
+						if len(keys) > 0 {
+							ref.path = rest[1:end]
+						}
+						add(ref)
+						i += end + 1
+					}
+					continue
+				}
+				j := 0
+				for j < len(rest) && isVariableName(rest[:j+1]) {
+					j++
+				}
+				if j > 0 && !(rest[0] >= '0' && rest[0] <= '9') {
+					add(placeholderRef{text: "$" + rest[:j], name: rest[:j], dollar: true})
+					i += j
+				}
+			}
+		}
+	}
+	return refs
+}
@@ -0,0 +1,157 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"linea/internal/store"
+)
+
+// LocksDirName is the directory of run locks inside the jobs directory (.linea/run/locks)
+const LocksDirName = "locks"
+
+// lockPollInterval is how often a run waiting for a lock checks whether it was released
+var lockPollInterval = 200 * time.Millisecond
+
+// RunLock is the lock held by a run of a workflow with lock: true, so that only one run of
+// it happens at a time. The lock file names the process holding it and is removed when the
+// run ends; a lock whose process has exited is broken by the next run
+type RunLock struct {
+	Workflow string    `json:"workflow"`
+	Path     string    `json:"path"` // Workflow file
+	PID      int       `json:"pid"`
+	Start    time.Time `json:"start"`
+
+	file string
+}
+
+// WorkflowLockWait returns whether the steps of a workflow ask for a run lock, and how long
+// a run waits for it (0 fails at once). Any document with lock: true locks the workflow;
+// the first document with lock_wait: sets the wait
+func WorkflowLockWait(configs []*CommandConfig) (bool, time.Duration, error) {
+	locked, wait := false, ""
+	for _, config := range configs {
+		locked = locked || config.Lock
+		if wait == "" {
+			wait = config.LockWait
+		}
+	}
+	if !locked || wait == "" {
+		return locked, 0, nil
+	}
+	d, err := ParseAge(wait)
+	if err != nil {
+		return true, 0, fmt.Errorf("lock_wait: %w", err)
+	}
+	return true, d, nil
+}
+
+// CheckLock validates the lock_wait: of a step
+func CheckLock(config *CommandConfig) error {
+	if config.LockWait == "" {
+		return nil
+	}
+	if !config.Lock {
+		return fmt.Errorf("lock_wait needs lock: true")
+	}
+	if _, err := ParseAge(config.LockWait); err != nil {
+		return fmt.Errorf("lock_wait: %w", err)
+	}
+	return nil
+}
+
+// RunLockPath returns the lock file of a workflow file, in the locks directory of its project
+func RunLockPath(path string) string {
+	return workflowStateFile(path, LocksDirName, ".lock")
+}
+
+// LockWorkflow takes the run lock of a workflow file if its steps ask for one, returning
+// nil if they do not. While another run holds the lock, it waits up to the workflow's
+// lock_wait:, telling stderr once, and then fails with the process and start of that run
+func LockWorkflow(path string, configs []*CommandConfig, stderr io.Writer) (*RunLock, error) {
+	locked, wait, err := WorkflowLockWait(configs)
+	if err != nil || !locked {
+		return nil, err
+	}
+	file := RunLockPath(path)
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create locks directory: %w", err)
+	}
+
+	lock := &RunLock{Workflow: WorkflowName(path), Path: path, PID: os.Getpid(), Start: time.Now(), file: file}
+	deadline := time.Now().Add(wait)
+	told := false
+	for {
+		holder, err := lock.acquire()
+		if err != nil {
+			return nil, err
+		}
+		if holder == nil {
+			return lock, nil
+		}
+		running := fmt.Sprintf("pid %d, started at %s", holder.PID, holder.Start.Local().Format("2006-01-02 15:04:05"))
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("workflow '%s' is already running (%s)", lock.Workflow, running)
+		}
+		if !told {
+			fmt.Fprintf(stderr, "Waiting for the run of '%s' in progress (%s) to finish...\n", lock.Workflow, running)
+			told = true
+		}
+		if err := Cancelled(); err != nil {
+			return nil, err
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// acquire creates the lock file, unless a live process holds it, which is returned. The
+// check and the creation happen under the file's store lock, so that two runs breaking
+// the lock of a crashed one cannot both take it
+func (l *RunLock) acquire() (*RunLock, error) {
+	var holder *RunLock
+	err := store.WithLock(l.file, func() error {
+		if data, err := os.ReadFile(l.file); err == nil {
+			var current RunLock
+			if json.Unmarshal(data, &current) == nil && processAlive(current.PID) {
+				holder = &current
+				return nil
+			}
+		} else if !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read lock file: %w", err)
+		}
+		data, err := json.MarshalIndent(l, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := store.WriteFile(l.file, append(data, '\n'), 0644); err != nil {
+			return fmt.Errorf("failed to write lock file: %w", err)
+		}
+		return nil
+	})
+	return holder, err
+}
+
+// Unlock releases the lock, if it is still held by this process
+func (l *RunLock) Unlock() error {
+	if l == nil {
+		return nil
+	}
+	return store.WithLock(l.file, func() error {
+		data, err := os.ReadFile(l.file)
+		if err != nil {
+			return nil
+		}
+		var current RunLock
+		if json.Unmarshal(data, &current) == nil && current.PID != l.PID {
+			return nil
+		}
+		if err := os.Remove(l.file); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove lock file: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,156 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogsDirName is the directory for run logs inside a project's .linea directory
+// (or the state directory outside a project)
+const LogsDirName = "logs"
+
+// MaxRunLogs is how many automatic run logs are kept per logs directory; older ones are removed
+const MaxRunLogs = 100
+
+// runLogTimeFormat is the timestamp in run log file names, sortable like run IDs
+const runLogTimeFormat = "20060102T150405"
+
+// RunLogsDir returns the directory holding the logs of runs of a workflow, including
+// scheduled runs: the .linea/logs directory of its project, or logs/ in the state directory
+func RunLogsDir(workflowPath string) string {
+	if lineaDir := FindLineaDir(filepath.Dir(workflowPath)); lineaDir != "" {
+		return filepath.Join(lineaDir, LogsDirName)
+	}
+	return filepath.Join(UserPaths().State, LogsDirName)
+}
+
+// RunLog is the log file of one run; writes are masked and safe for concurrent use
+type RunLog struct {
+	Path string
+	mu   sync.Mutex
+	file *os.File
+}
+
+// CreateRunLog creates a log file in dir named <workflow>-<timestamp>.log, adding a
+// counter if a run of the same workflow started in the same second
+func CreateRunLog(dir, workflow string, start time.Time) (*RunLog, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create logs directory: %w", err)
+	}
+	base := workflow + "-" + start.UTC().Format(runLogTimeFormat)
+	for n := 1; ; n++ {
+		path := filepath.Join(dir, base+".log")
+		if n > 1 {
+			path = filepath.Join(dir, fmt.Sprintf("%s-%d.log", base, n))
+		}
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(err) && n < 100 {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to create log file: %w", err)
+		}
+		return &RunLog{Path: path, file: file}, nil
+	}
+}
+
+// OpenRunLog creates or truncates the log file at path (used for --log-file)
+func OpenRunLog(path string) (*RunLog, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create log directory: %w", err)
+		}
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create log file: %w", err)
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return &RunLog{Path: path, file: file}, nil
+}
+
+// Write writes p to the log with secret values masked
+// A secret split across two writes is not masked
+func (l *RunLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := io.WriteString(l.file, MaskSecrets(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Start writes the header of a run of the workflow at path
+func (l *RunLog) Start(path string, start time.Time) {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	fmt.Fprintf(l, "# linea run %s\n# started %s\n\n", path, start.Local().Format(time.RFC3339))
+}
+
+// Finish writes the outcome of the run and closes the log
+func (l *RunLog) Finish(start time.Time, runErr error) error {
+	duration := time.Since(start).Round(time.Millisecond)
+	if runErr != nil {
+		fmt.Fprintf(l, "\n# failed after %s (exit code %d): %v\n", duration, ExitCode(runErr), runErr)
+	} else {
+		fmt.Fprintf(l, "\n# succeeded after %s\n", duration)
+	}
+	return l.file.Close()
+}
+
+// PruneRunLogs removes all but the newest keep .log files in dir
+func PruneRunLogs(dir string, keep int) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	type logFile struct {
+		path    string
+		modTime time.Time
+	}
+	var logs []logFile
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".log") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		logs = append(logs, logFile{filepath.Join(dir, entry.Name()), info.ModTime()})
+	}
+	if len(logs) <= keep {
+		return nil
+	}
+
+	sort.Slice(logs, func(i, j int) bool { return logs[i].modTime.After(logs[j].modTime) })
+	for _, log := range logs[keep:] {
+		os.Remove(log.path)
+	}
+	return nil
+}
+
+// ReadRunLog returns the log of a recorded run
+func ReadRunLog(record *RunRecord) ([]byte, error) {
+	if record.LogFile == "" {
+		return nil, fmt.Errorf("run %s has no log (it was recorded before run logging, or its log could not be created)", record.ID)
+	}
+	data, err := os.ReadFile(record.LogFile)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("the log of run %s was removed (%s)", record.ID, record.LogFile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read log file: %w", err)
+	}
+	return data, nil
+}
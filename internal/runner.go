@@ -0,0 +1,52 @@
+package internal
+
+import "io"
+
+// StepCommand is the built command of a step, with the streams it runs with
+type StepCommand struct {
+	Config *CommandConfig
+	Args   []string  // Built command; for a type: step, the type followed by its arguments
+	Stdin  io.Reader // nil reads linea's own standard input
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Runner runs the command of every step of a run. LocalRunner runs them on this machine;
+// others can fake them in tests, or run them somewhere else. An error carrying an exit
+// code (an *exec.ExitError or *StepExitError) sets the step's exit code
+// allowed_exit_codes:, capture:, and {exit_code} are handled around the runner
+type Runner interface {
+	RunStep(step *StepCommand) error
+}
+
+// LocalRunner runs steps on this machine: a type: step as its built-in step type, a step
+// with a runner: through its client (such as ssh), a background: step as a job, an
+// interactive: step attached to the terminal, and any other step as a process with the
+// step's environment and shell
+type LocalRunner struct{}
+
+// RunStep runs a step on this machine
+func (LocalRunner) RunStep(step *StepCommand) error {
+	config, cmd := step.Config, step.Args
+	switch {
+	case config.Type != "":
+		stepType, err := LookupStepType(config.Type)
+		if err != nil {
+			return err
+		}
+		return stepType.Run(cmd[1:], step.Stdout, step.Stderr)
+	case config.Runner != nil:
+		return runRemote(step)
+	case config.Background:
+		if err := CheckBackground(config); err != nil {
+			return err
+		}
+		return startBackgroundJob(config, cmd, StepShell(config), StepEnvironment(config), config.Limits, step.Stdin, step.Stdout)
+	case config.Interactive:
+		if err := CheckInteractive(config); err != nil {
+			return err
+		}
+		return executeInteractive(limitedCommandLine(cmd, StepShell(config), config.Limits), StepEnvironment(config), config.Limits)
+	}
+	return executeCommand(cmd, StepShell(config), StepEnvironment(config), config.Limits, step.Stdin, step.Stdout, step.Stderr)
+}
@@ -0,0 +1,213 @@
+package internal
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"linea/templates"
+)
+
+// WorkflowVariable is a variable to declare in a generated workflow
+type WorkflowVariable struct {
+	Name        string
+	Default     string
+	Description string
+}
+
+// WorkflowSpec describes a workflow to generate with RenderWorkflow
+type WorkflowSpec struct {
+	Description string
+	Command     string
+	Subcommand  string
+	Args        []string
+	Variables   []WorkflowVariable
+}
+
+// InitPreset is a ready-made workflow `linea init --preset` starts from
+type InitPreset struct {
+	Name        string
+	Description string
+	Content     []byte
+}
+
+// InitPresets returns the built-in presets of `linea init`, sorted by name
+func InitPresets() []*InitPreset {
+	entries, err := fs.ReadDir(templates.Presets, "presets")
+	if err != nil {
+		return nil
+	}
+	var presets []*InitPreset
+	for _, entry := range entries {
+		if entry.IsDir() || !IsWorkflowFile(entry.Name()) {
+			continue
+		}
+		content, err := fs.ReadFile(templates.Presets, path.Join("presets", entry.Name()))
+		if err != nil {
+			continue
+		}
+		preset := &InitPreset{Name: WorkflowName(entry.Name()), Content: content}
+		var header struct {
+			Description string `yaml:"description"`
+		}
+		if yaml.Unmarshal(content, &header) == nil {
+			preset.Description = header.Description
+		}
+		presets = append(presets, preset)
+	}
+	sort.Slice(presets, func(i, j int) bool { return presets[i].Name < presets[j].Name })
+	return presets
+}
+
+// FindInitPreset returns the preset of `linea init` with the given name
+func FindInitPreset(name string) (*InitPreset, error) {
+	var names []string
+	for _, preset := range InitPresets() {
+		if preset.Name == name {
+			return preset, nil
+		}
+		names = append(names, preset.Name)
+	}
+	return nil, fmt.Errorf("unknown preset '%s' (available: %s)", name, strings.Join(names, ", "))
+}
+
+// yamlScalar renders a string as a YAML scalar, quoting it when required
+func yamlScalar(s string) string {
+	if s == "" || strings.ContainsAny(s, "{}$") {
+		// Placeholders are always quoted so {name} is not read as a YAML mapping
+		return doubleQuote(s)
+	}
+	out, err := yaml.Marshal(s)
+	if err != nil || strings.Contains(strings.TrimSpace(string(out)), "\n") {
+		return doubleQuote(s)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// doubleQuote renders a YAML double-quoted string
+func doubleQuote(s string) string {
+	s = strings.ReplaceAll(s, "\\", "\\\\")
+	s = strings.ReplaceAll(s, "\"", "\\\"")
+	s = strings.ReplaceAll(s, "\n", "\\n")
+	s = strings.ReplaceAll(s, "\t", "\\t")
+	return "\"" + s + "\""
+}
+
+// ArgPlaceholders returns the {variable} names referenced by args, sorted
+func ArgPlaceholders(args []string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, arg := range args {
+		for name := range ExtractVariableReferences(arg) {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// subcommandTools are programs whose first argument, when it is a plain word, is a
+// subcommand, as in docker build or git push
+var subcommandTools = map[string]bool{
+	"apt": true, "apt-get": true, "az": true, "brew": true, "cargo": true, "choco": true,
+	"composer": true, "dnf": true, "docker": true, "docker-compose": true, "dotnet": true,
+	"gcloud": true, "gh": true, "git": true, "go": true, "helm": true, "kubectl": true,
+	"npm": true, "pip": true, "pip3": true, "pnpm": true, "podman": true, "snap": true,
+	"systemctl": true, "terraform": true, "winget": true, "yarn": true, "yum": true,
+}
+
+// subcommandWord matches the words taken as a subcommand
+var subcommandWord = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// WorkflowSpecFromCommand builds the spec of a workflow running a command line: its
+// program, the subcommand of tools like docker and git, and its arguments, with a
+// variable declared for each {placeholder} or $variable that is not built in
+func WorkflowSpecFromCommand(line string) (*WorkflowSpec, error) {
+	words, err := SplitCommandLine(line)
+	if err != nil {
+		return nil, err
+	}
+	if len(words) == 0 {
+		return nil, fmt.Errorf("the command line is empty")
+	}
+
+	spec := &WorkflowSpec{Command: words[0], Args: words[1:]}
+	program := strings.ToLower(strings.TrimSuffix(filepath.Base(spec.Command), filepath.Ext(spec.Command)))
+	if subcommandTools[program] && len(spec.Args) > 0 && subcommandWord.MatchString(spec.Args[0]) {
+		spec.Subcommand, spec.Args = spec.Args[0], spec.Args[1:]
+	}
+
+	builtins := BuiltinVariables(nil)
+	for _, name := range ArgPlaceholders(words) {
+		if _, ok := builtins[name]; !ok {
+			spec.Variables = append(spec.Variables, WorkflowVariable{Name: name})
+		}
+	}
+	return spec, nil
+}
+
+// RenderWorkflow generates a documented workflow YAML file from a spec
+func RenderWorkflow(spec *WorkflowSpec) string {
+	var b strings.Builder
+
+	b.WriteString("# Linea Workflow Configuration\n")
+	b.WriteString("# This file defines commands that can be executed using: linea run <this-file>\n")
+	b.WriteString("version: " + strconv.Itoa(CurrentWorkflowVersion) + "\n")
+	if spec.Description != "" {
+		b.WriteString("description: " + yamlScalar(spec.Description) + "\n")
+	}
+	b.WriteString("\n")
+
+	b.WriteString("# Main command to execute\n")
+	b.WriteString("command: " + yamlScalar(spec.Command) + "\n")
+
+	if spec.Subcommand != "" {
+		b.WriteString("\n")
+		b.WriteString("# Subcommand passed right after the command\n")
+		b.WriteString("subcommand: " + yamlScalar(spec.Subcommand) + "\n")
+	}
+
+	if len(spec.Args) > 0 {
+		b.WriteString("\n")
+		b.WriteString("# Arguments to pass to the command\n")
+		b.WriteString("args:\n")
+		for _, arg := range spec.Args {
+			b.WriteString("  - " + yamlScalar(arg) + "\n")
+		}
+	}
+
+	if len(spec.Variables) > 0 {
+		b.WriteString("\n")
+		b.WriteString("# Variables for substitution\n")
+		b.WriteString("# Use {variable} or $variable syntax in args; override with -s name=value\n")
+		b.WriteString("variables:\n")
+		for _, v := range spec.Variables {
+			if v.Description != "" {
+				b.WriteString("  # " + v.Description + "\n")
+			}
+			b.WriteString("  " + v.Name + ": " + yamlScalar(v.Default) + "\n")
+		}
+	}
+
+	b.WriteString("\n")
+	b.WriteString("# Example usage:\n")
+	b.WriteString("#   linea run <this-file>\n")
+	if len(spec.Variables) > 0 {
+		example := spec.Variables[0]
+		b.WriteString("#   linea run <this-file> -s " + example.Name + "=\"value\"\n")
+	}
+	b.WriteString("#   linea test <this-file>\n")
+	b.WriteString("#   linea help <this-file>\n")
+
+	return b.String()
+}
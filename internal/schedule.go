@@ -0,0 +1,374 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"linea/internal/store"
+)
+
+// SchedulesFileName is the name of the schedule registry inside the state directory
+const SchedulesFileName = "schedules.json"
+
+// ScheduleEntry is a workflow registered with the scheduler
+type ScheduleEntry struct {
+	ID        string            `json:"id"`
+	Workflow  string            `json:"workflow"`       // Absolute path of the workflow file
+	Cron      string            `json:"cron,omitempty"` // Empty to use the workflow's schedule: field
+	Variables map[string]string `json:"variables,omitempty"`
+	Added     time.Time         `json:"added"`
+}
+
+// SchedulesFilePath returns the path of the schedule registry
+func SchedulesFilePath() string {
+	return filepath.Join(UserPaths().State, SchedulesFileName)
+}
+
+// LoadSchedules reads the schedule registry, sorted by ID
+// A missing file is treated as an empty registry
+func LoadSchedules() ([]ScheduleEntry, error) {
+	path := SchedulesFilePath()
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schedules file %s: %w", path, err)
+	}
+
+	var entries []ScheduleEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse schedules file %s: %w", path, err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	return entries, nil
+}
+
+// SaveSchedules writes the schedule registry
+func SaveSchedules(entries []ScheduleEntry) error {
+	path := SchedulesFilePath()
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode schedules: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	if err := store.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write schedules file %s: %w", path, err)
+	}
+	return nil
+}
+
+// WorkflowSchedule returns the schedule: cron expression and timezone: of a workflow file
+// The first document with a schedule wins; an empty cron means the workflow has none
+func WorkflowSchedule(path string) (string, string, error) {
+	configs, err := ParseMultiYAML(path)
+	if err != nil {
+		return "", "", err
+	}
+	for _, config := range configs {
+		if config.Schedule != "" {
+			return config.Schedule, config.Timezone, nil
+		}
+	}
+	return "", "", nil
+}
+
+// AddSchedule registers a workflow with the scheduler
+// cron overrides the workflow's schedule: field; vars are passed with -s/--set on every run
+func AddSchedule(workflowPath, cron string, vars map[string]string) (ScheduleEntry, error) {
+	path, err := filepath.Abs(workflowPath)
+	if err != nil {
+		return ScheduleEntry{}, fmt.Errorf("failed to resolve %s: %w", workflowPath, err)
+	}
+
+	fileCron, _, err := WorkflowSchedule(path)
+	if err != nil {
+		return ScheduleEntry{}, fmt.Errorf("failed to parse YAML file: %w", err)
+	}
+	effective := cron
+	if effective == "" {
+		effective = fileCron
+	}
+	if effective == "" {
+		return ScheduleEntry{}, fmt.Errorf("%s has no schedule: field (pass a cron expression with --cron)", workflowPath)
+	}
+	if _, err := ParseCron(effective); err != nil {
+		return ScheduleEntry{}, err
+	}
+
+	entry := ScheduleEntry{
+		Workflow:  path,
+		Cron:      cron,
+		Variables: vars,
+		Added:     time.Now().UTC(),
+	}
+	err = updateSchedules(func(entries []ScheduleEntry) ([]ScheduleEntry, error) {
+		// IDs are the workflow name, numbered if the workflow is scheduled more than once
+		ids := map[string]bool{}
+		for _, existing := range entries {
+			ids[existing.ID] = true
+		}
+		entry.ID = WorkflowName(path)
+		for n := 2; ids[entry.ID]; n++ {
+			entry.ID = fmt.Sprintf("%s-%d", WorkflowName(path), n)
+		}
+		return append(entries, entry), nil
+	})
+	if err != nil {
+		return ScheduleEntry{}, err
+	}
+	return entry, nil
+}
+
+// RemoveSchedule unregisters a schedule by ID
+func RemoveSchedule(id string) (ScheduleEntry, error) {
+	var removed ScheduleEntry
+	err := updateSchedules(func(entries []ScheduleEntry) ([]ScheduleEntry, error) {
+		for i, entry := range entries {
+			if entry.ID == id {
+				removed = entry
+				return append(entries[:i:i], entries[i+1:]...), nil
+			}
+		}
+		return nil, fmt.Errorf("schedule %s not found (see linea schedule list)", id)
+	})
+	return removed, err
+}
+
+// updateSchedules applies fn to the schedule registry while holding its lock, so that
+// concurrent `linea schedule add` and `remove` calls do not lose each other's changes
+func updateSchedules(fn func(entries []ScheduleEntry) ([]ScheduleEntry, error)) error {
+	path := SchedulesFilePath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	return store.WithLock(path, func() error {
+		entries, err := LoadSchedules()
+		if err != nil {
+			return err
+		}
+		updated, err := fn(entries)
+		if err != nil {
+			return err
+		}
+		return SaveSchedules(updated)
+	})
+}
+
+// Resolve returns the parsed cron schedule and location of an entry
+// Entries without their own cron expression use the workflow's current schedule: field
+func (e ScheduleEntry) Resolve() (*CronSchedule, *time.Location, error) {
+	fileCron, timezone, err := WorkflowSchedule(e.Workflow)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cron := e.Cron
+	if cron == "" {
+		cron = fileCron
+	}
+	if cron == "" {
+		return nil, nil, fmt.Errorf("%s no longer has a schedule: field", e.Workflow)
+	}
+	schedule, err := ParseCron(cron)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	location := time.Local
+	if timezone != "" {
+		if location, err = time.LoadLocation(timezone); err != nil {
+			return nil, nil, fmt.Errorf("invalid timezone '%s': %w", timezone, err)
+		}
+	}
+	return schedule, location, nil
+}
+
+// Scheduler runs registered workflows on their cron schedules
+// The registry is re-read every minute, so schedules can be added and removed while it runs
+type Scheduler struct {
+	LineaPath string                                                  // linea executable used to run workflows
+	Logf      func(status Status, format string, args ...interface{}) // Receives scheduler events
+	Shipper   *LogShipper                                             // Ships run logs of workflows with ship_logs:; nil to keep them local
+	Metrics   *Metrics                                                // Counts the runs; nil for none
+
+	mu       sync.Mutex
+	running  map[string]bool
+	shipping bool
+	wg       sync.WaitGroup
+}
+
+// Run checks the schedules at the start of every minute until stop is closed,
+// then waits for runs in progress to finish
+func (s *Scheduler) Run(stop <-chan struct{}) {
+	s.running = map[string]bool{}
+	for {
+		now := time.Now()
+		next := now.Truncate(time.Minute).Add(time.Minute)
+		timer := time.NewTimer(next.Sub(now))
+		select {
+		case <-stop:
+			timer.Stop()
+			s.wg.Wait()
+			s.flushLogs(time.Now())
+			return
+		case <-timer.C:
+		}
+		s.Tick(next)
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.flushLogs(next)
+		}()
+	}
+}
+
+// flushLogs ships the queued run logs that are due, unless a flush is already in progress
+func (s *Scheduler) flushLogs(t time.Time) {
+	if s.Shipper == nil {
+		return
+	}
+	s.mu.Lock()
+	busy := s.shipping
+	s.shipping = true
+	s.mu.Unlock()
+	if busy {
+		return
+	}
+	defer func() {
+		s.mu.Lock()
+		s.shipping = false
+		s.mu.Unlock()
+	}()
+	if n := s.Shipper.Flush(t); n > 0 {
+		s.Logf(StatusInfo, "shipped %d run log(s)", n)
+	}
+}
+
+// Tick starts every schedule due in the minute at t
+// A schedule whose previous run is still in progress is skipped
+func (s *Scheduler) Tick(t time.Time) {
+	entries, err := LoadSchedules()
+	if err != nil {
+		s.Logf(StatusWarning, "%v", err)
+		return
+	}
+
+	for _, entry := range entries {
+		schedule, location, err := entry.Resolve()
+		if err != nil {
+			s.Logf(StatusWarning, "%s: %v", entry.ID, err)
+			continue
+		}
+		if !schedule.Matches(t.In(location)) {
+			continue
+		}
+
+		s.mu.Lock()
+		busy := s.running[entry.ID]
+		if !busy {
+			s.running[entry.ID] = true
+		}
+		s.mu.Unlock()
+		if busy {
+			s.Logf(StatusSkip, "%s: previous run still in progress, skipping", entry.ID)
+			s.Metrics.RunSkipped(WorkflowName(entry.Workflow))
+			continue
+		}
+
+		s.wg.Add(1)
+		go func(entry ScheduleEntry) {
+			defer s.wg.Done()
+			defer func() {
+				s.mu.Lock()
+				delete(s.running, entry.ID)
+				s.mu.Unlock()
+			}()
+			s.runEntry(entry, t)
+		}(entry)
+	}
+}
+
+// runEntry runs one scheduled workflow through `linea run`, writing its output to a log file
+// The run writes the log itself (--log-file), so it is also recorded in the run history
+func (s *Scheduler) runEntry(entry ScheduleEntry, t time.Time) {
+	logPath := filepath.Join(RunLogsDir(entry.Workflow), fmt.Sprintf("%s-%s.log", entry.ID, t.Format(runLogTimeFormat)))
+
+	args := []string{"run", entry.Workflow, "--log-file", logPath}
+	for _, name := range sortedMapKeys(entry.Variables) {
+		args = append(args, "-s", name+"="+entry.Variables[name])
+	}
+
+	// Everything is in the log; stderr is kept for errors reported before it is created
+	stderr := &tailBuffer{max: stderrTailSize}
+	execCmd := exec.Command(s.LineaPath, args...)
+	execCmd.Dir = filepath.Dir(entry.Workflow)
+	execCmd.Stderr = stderr
+
+	s.Logf(StatusStart, "%s: started (log: %s)", entry.ID, logPath)
+	s.Metrics.RunStarted(WorkflowName(entry.Workflow))
+	start := time.Now()
+	err := execCmd.Run()
+	s.Metrics.RunFinished(WorkflowName(entry.Workflow), time.Since(start), err == nil)
+	if err != nil {
+		if _, statErr := os.Stat(logPath); os.IsNotExist(statErr) {
+			err = fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		s.Logf(StatusError, "%s: failed after %s: %v", entry.ID, time.Since(start).Round(time.Millisecond), err)
+	} else {
+		s.Logf(StatusSuccess, "%s: finished in %s", entry.ID, time.Since(start).Round(time.Millisecond))
+	}
+	s.queueLog(entry, logPath, start, err)
+}
+
+// queueLog queues the log of a run for the workflow's ship_logs: targets
+// The log is shipped at the next flush, so a slow or unreachable target never delays runs
+func (s *Scheduler) queueLog(entry ScheduleEntry, logPath string, start time.Time, runErr error) {
+	if s.Shipper == nil {
+		return
+	}
+	if _, err := os.Stat(logPath); err != nil {
+		return
+	}
+	targets, err := WorkflowLogTargets(entry.Workflow)
+	if err != nil || len(targets) == 0 {
+		return
+	}
+
+	name := strings.TrimSuffix(filepath.Base(entry.Workflow), filepath.Ext(entry.Workflow))
+	run := Shipment{
+		ID:         strings.TrimSuffix(filepath.Base(logPath), ".log"),
+		Workflow:   name,
+		Path:       entry.Workflow,
+		ScheduleID: entry.ID,
+		LogFile:    logPath,
+		Success:    runErr == nil,
+		Start:      start,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	if runErr != nil {
+		run.Error = MaskSecrets(runErr.Error())
+	}
+	if err := EnqueueShipments(s.Shipper.Dir, run, targets); err != nil {
+		s.Logf(StatusWarning, "%s: %v", entry.ID, err)
+	}
+}
+
+// sortedMapKeys returns the keys of a string map in sorted order
+func sortedMapKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
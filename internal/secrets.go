@@ -0,0 +1,331 @@
+package internal
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"linea/internal/store"
+)
+
+// SecretMask is the text displayed in place of a secret value
+const SecretMask = "******"
+
+// SecretsKeyEnv is the environment variable holding the passphrase for the encrypted secrets file
+const SecretsKeyEnv = "LINEA_SECRETS_KEY"
+
+// SecretsFileEnv overrides the location of the encrypted secrets file
+const SecretsFileEnv = "LINEA_SECRETS_FILE"
+
+// keychainService is the service name used for secrets stored in the OS keychain
+const keychainService = "linea"
+
+// Secret backends supported in the `secrets:` section
+const (
+	SecretFromEnv      = "env"
+	SecretFromKeychain = "keychain"
+	SecretFromFile     = "file"
+)
+
+var (
+	maskedSecretsMu sync.Mutex
+	maskedSecrets   = map[string]bool{}
+)
+
+// RegisterSecret marks a value as secret so it is masked in all displayed output
+func RegisterSecret(value string) {
+	if value == "" {
+		return
+	}
+	maskedSecretsMu.Lock()
+	maskedSecrets[value] = true
+	maskedSecretsMu.Unlock()
+}
+
+// MaskSecrets replaces every registered secret value in s with SecretMask
+func MaskSecrets(s string) string {
+	maskedSecretsMu.Lock()
+	defer maskedSecretsMu.Unlock()
+
+	if len(maskedSecrets) == 0 {
+		return s
+	}
+
+	// Replace longer secrets first so a secret containing another is fully masked
+	values := make([]string, 0, len(maskedSecrets))
+	for value := range maskedSecrets {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+
+	for _, value := range values {
+		s = strings.ReplaceAll(s, value, SecretMask)
+	}
+	return s
+}
+
+// ResolveSecrets looks up every secret declared in a workflow and registers the values for masking
+func ResolveSecrets(secrets map[string]SecretRef) (map[string]string, error) {
+	resolved := make(map[string]string, len(secrets))
+	for name, ref := range secrets {
+		value, err := ResolveSecret(name, ref)
+		if err != nil {
+			return nil, err
+		}
+		RegisterSecret(value)
+		resolved[name] = value
+	}
+	return resolved, nil
+}
+
+// ResolveSecret looks up a single secret from its backend
+func ResolveSecret(name string, ref SecretRef) (string, error) {
+	key := ref.Key
+	if key == "" {
+		key = name
+	}
+
+	switch ref.From {
+	case SecretFromEnv:
+		value, ok := os.LookupEnv(key)
+		if !ok {
+			return "", fmt.Errorf("secret %s: environment variable %s is not set", name, key)
+		}
+		return value, nil
+	case SecretFromKeychain:
+		value, err := keychainGet(key)
+		if err != nil {
+			return "", fmt.Errorf("secret %s: %w", name, err)
+		}
+		return value, nil
+	case SecretFromFile, "":
+		store, err := LoadSecretStore(SecretsFilePath())
+		if err != nil {
+			return "", fmt.Errorf("secret %s: %w", name, err)
+		}
+		value, ok := store[key]
+		if !ok {
+			return "", fmt.Errorf("secret %s: key %s not found in %s (use linea secret set)", name, key, SecretsFilePath())
+		}
+		return value, nil
+	default:
+		return "", fmt.Errorf("secret %s: unknown backend '%s' (expected env, keychain, or file)", name, ref.From)
+	}
+}
+
+// SecretsFilePath returns the path of the encrypted secrets file
+// It honors LINEA_SECRETS_FILE, then looks for a .linea directory walking up from the
+// current directory, and finally falls back to ./.linea/secrets.enc
+func SecretsFilePath() string {
+	if path := os.Getenv(SecretsFileEnv); path != "" {
+		return path
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return filepath.Join(".linea", "secrets.enc")
+	}
+
+	if dir := FindLineaDir(cwd); dir != "" {
+		return filepath.Join(dir, "secrets.enc")
+	}
+	return filepath.Join(cwd, ".linea", "secrets.enc")
+}
+
+// secretFile is the on-disk format of the encrypted secrets file
+type secretFile struct {
+	Version int    `json:"version"`
+	Salt    string `json:"salt"`
+	Nonce   string `json:"nonce"`
+	Data    string `json:"data"`
+}
+
+// secretKDFIterations is the PBKDF2 iteration count used to derive the file key
+const secretKDFIterations = 100000
+
+// LoadSecretStore decrypts the secrets file and returns its key/value pairs
+// A missing file is treated as an empty store
+func LoadSecretStore(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets file %s: %w", path, err)
+	}
+
+	var file secretFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse secrets file %s: %w", path, err)
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(file.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("corrupt secrets file %s: %w", path, err)
+	}
+	nonce, err := base64.StdEncoding.DecodeString(file.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("corrupt secrets file %s: %w", path, err)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(file.Data)
+	if err != nil {
+		return nil, fmt.Errorf("corrupt secrets file %s: %w", path, err)
+	}
+
+	gcm, err := secretCipher(salt)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secrets file %s (wrong %s?)", path, SecretsKeyEnv)
+	}
+
+	store := map[string]string{}
+	if err := json.Unmarshal(plaintext, &store); err != nil {
+		return nil, fmt.Errorf("corrupt secrets file %s: %w", path, err)
+	}
+	return store, nil
+}
+
+// SaveSecretStore encrypts the key/value pairs and writes them to the secrets file
+func SaveSecretStore(path string, secrets map[string]string) error {
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return fmt.Errorf("failed to encode secrets: %w", err)
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	gcm, err := secretCipher(salt)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	file := secretFile{
+		Version: 1,
+		Salt:    base64.StdEncoding.EncodeToString(salt),
+		Nonce:   base64.StdEncoding.EncodeToString(nonce),
+		Data:    base64.StdEncoding.EncodeToString(gcm.Seal(nil, nonce, plaintext, nil)),
+	}
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode secrets file: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create secrets directory: %w", err)
+	}
+	if err := store.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write secrets file %s: %w", path, err)
+	}
+	return nil
+}
+
+// UpdateSecretStore loads the secrets file, applies fn to its key/value pairs, and saves
+// the result, holding the file's lock so concurrent updates are not lost
+func UpdateSecretStore(path string, fn func(secrets map[string]string) error) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create secrets directory: %w", err)
+	}
+	return store.WithLock(path, func() error {
+		secrets, err := LoadSecretStore(path)
+		if err != nil {
+			return err
+		}
+		if err := fn(secrets); err != nil {
+			return err
+		}
+		return SaveSecretStore(path, secrets)
+	})
+}
+
+// secretCipher derives the AES-256-GCM cipher for the secrets file from LINEA_SECRETS_KEY
+func secretCipher(salt []byte) (cipher.AEAD, error) {
+	passphrase := os.Getenv(SecretsKeyEnv)
+	if passphrase == "" {
+		return nil, fmt.Errorf("%s must be set to use the encrypted secrets file", SecretsKeyEnv)
+	}
+
+	key := pbkdf2SHA256([]byte(passphrase), salt, secretKDFIterations, 32)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// pbkdf2SHA256 derives a key using PBKDF2 with HMAC-SHA256 (RFC 8018)
+func pbkdf2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	hashLen := prf.Size()
+	numBlocks := (keyLen + hashLen - 1) / hashLen
+
+	key := make([]byte, 0, numBlocks*hashLen)
+	buf := make([]byte, 4)
+	for block := 1; block <= numBlocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		buf[0] = byte(block >> 24)
+		buf[1] = byte(block >> 16)
+		buf[2] = byte(block >> 8)
+		buf[3] = byte(block)
+		prf.Write(buf)
+		u := prf.Sum(nil)
+
+		t := make([]byte, len(u))
+		copy(t, u)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
+
+// keychainGet reads a secret from the OS keychain using the platform's native tooling
+func keychainGet(key string) (string, error) {
+	var execCmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		execCmd = exec.Command("security", "find-generic-password", "-s", keychainService, "-a", key, "-w")
+	case "windows":
+		script := "[void][Windows.Security.Credentials.PasswordVault,Windows.Security.Credentials,ContentType=WindowsRuntime];" +
+			"$c = (New-Object Windows.Security.Credentials.PasswordVault).Retrieve('" + keychainService + "','" + strings.ReplaceAll(key, "'", "''") + "');" +
+			"$c.RetrievePassword(); [Console]::Write($c.Password)"
+		execCmd = exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", script)
+	default:
+		execCmd = exec.Command("secret-tool", "lookup", "service", keychainService, "account", key)
+	}
+
+	output, err := execCmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s from OS keychain: %w", key, err)
+	}
+	return strings.TrimRight(string(output), "\r\n"), nil
+}
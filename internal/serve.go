@@ -0,0 +1,693 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"linea/web"
+)
+
+// StepRunning is the status of a run of linea serve, and of its step, while it runs
+const StepRunning = "running"
+
+// serveLogSize is how much of the output of a run linea serve keeps, from its end
+const serveLogSize = 1 << 20
+
+// maxServerRuns is how many finished runs linea serve keeps; `linea history` has the rest
+const maxServerRuns = 100
+
+// subscriberBuffer is how many events a client of /events may fall behind before it is
+// dropped, so that a slow client never holds up a run
+const subscriberBuffer = 256
+
+// Server is the HTTP API of `linea serve`: it lists the workflows found like `linea run`
+// finds them by name, and runs them through `linea run`, one process per run, so that runs
+// are recorded in the history and send their notifications like any other
+type Server struct {
+	LineaPath string   // linea executable used to run workflows
+	Dirs      []string // Workflow directories, see WorkflowSearchDirs
+	Token     string   // Bearer token the API requires; empty for none
+	Triggers  []Trigger
+	UI        bool     // Serve the web UI at /
+	Metrics   *Metrics // Counts the runs, served at /metrics; nil for none
+	Logf      func(status Status, format string, args ...interface{})
+
+	mu   sync.Mutex
+	runs []*ServerRun // Oldest first
+	wg   sync.WaitGroup
+}
+
+// ServerRun is a run started through the API
+type ServerRun struct {
+	ID         string            `json:"id"`
+	Workflow   string            `json:"workflow"`
+	Path       string            `json:"path"`
+	Variables  map[string]string `json:"variables,omitempty"`
+	Status     string            `json:"status"` // running, success, or failed
+	ExitCode   *int              `json:"exit_code,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	Start      time.Time         `json:"start"`
+	DurationMs *int64            `json:"duration_ms,omitempty"`
+	Steps      []StepResult      `json:"steps"`
+	RunID      string            `json:"run_id,omitempty"` // ID in the run history
+
+	mu          sync.Mutex
+	cmd         *exec.Cmd
+	log         tailBuffer
+	partial     []byte // Output after the last line ending
+	subscribers map[chan serverEvent]bool
+	done        chan struct{}
+}
+
+// serverEvent is a server-sent event of /api/runs/{id}/events
+type serverEvent struct {
+	name string // log, step, or end
+	data string
+}
+
+// ServedWorkflow describes a workflow in /api/workflows
+type ServedWorkflow struct {
+	Name        string            `json:"name"`
+	Path        string            `json:"path"`
+	Description string            `json:"description,omitempty"`
+	Variables   []string          `json:"variables,omitempty"`
+	Required    []string          `json:"required,omitempty"`
+	Defaults    map[string]string `json:"defaults,omitempty"`
+}
+
+// Workflows returns the workflows of the server's directories; a name found in several
+// is the one a run by that name gets
+func (s *Server) Workflows() []ServedWorkflow {
+	seen := make(map[string]bool)
+	var workflows []ServedWorkflow
+	for _, dir := range s.Dirs {
+		infos, err := IndexWorkflows(dir)
+		if err != nil {
+			continue
+		}
+		for _, info := range infos {
+			if seen[info.Name] {
+				continue
+			}
+			seen[info.Name] = true
+			workflows = append(workflows, ServedWorkflow{
+				Name:        info.Name,
+				Path:        info.Path,
+				Description: info.Description,
+				Variables:   info.Variables,
+				Required:    info.Required,
+				Defaults:    info.Defaults,
+			})
+		}
+	}
+	sort.Slice(workflows, func(i, j int) bool { return workflows[i].Name < workflows[j].Name })
+	return workflows
+}
+
+// StartRun starts a run of the workflow named name with vars set like -s. Names are
+// looked up in the server's directories only, never as paths
+func (s *Server) StartRun(name string, vars map[string]string) (*ServerRun, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || name != WorkflowName(name) {
+		return nil, fmt.Errorf("invalid workflow name '%s'", name)
+	}
+	path := FindWorkflowInDirs(name, s.Dirs)
+	if path == "" {
+		return nil, fmt.Errorf("workflow %s not found", name)
+	}
+
+	start := time.Now()
+	run := &ServerRun{
+		ID:          newRunID(start),
+		Workflow:    name,
+		Path:        path,
+		Variables:   vars,
+		Status:      StepRunning,
+		Start:       start.UTC(),
+		Steps:       []StepResult{},
+		log:         tailBuffer{max: serveLogSize},
+		subscribers: make(map[chan serverEvent]bool),
+		done:        make(chan struct{}),
+	}
+
+	// The report of the run comes on stdout, the output of its commands on stderr
+	args := []string{"run", path, "--output", "json"}
+	for _, name := range sortedMapKeys(vars) {
+		args = append(args, "-s", name+"="+vars[name])
+	}
+	var report bytes.Buffer
+	run.cmd = exec.Command(s.LineaPath, args...)
+	run.cmd.Stdout = &report
+	run.cmd.Stderr = run
+
+	// Step events come through a pipe as the run goes, where processes can inherit one
+	var events *os.File
+	if runtime.GOOS != "windows" {
+		r, w, err := os.Pipe()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create progress pipe: %w", err)
+		}
+		events = r
+		run.cmd.ExtraFiles = []*os.File{w}
+		run.cmd.Args = append(run.cmd.Args, "--progress-fd", "3")
+		defer w.Close()
+	}
+	if err := run.cmd.Start(); err != nil {
+		if events != nil {
+			events.Close()
+		}
+		return nil, fmt.Errorf("failed to start linea run: %w", err)
+	}
+
+	s.mu.Lock()
+	s.runs = append(s.runs, run)
+	s.pruneRuns()
+	s.mu.Unlock()
+	s.logf(StatusStart, "%s: started %s", run.ID, name)
+	s.Metrics.RunStarted(name)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if events != nil {
+			run.readEvents(events)
+			events.Close()
+		}
+		err := run.cmd.Wait()
+		run.finish(report.Bytes(), err)
+		s.Metrics.RunFinished(name, time.Since(start), run.Status == StepSucceeded)
+		if run.Status == StepSucceeded {
+			s.logf(StatusSuccess, "%s: %s succeeded", run.ID, name)
+		} else {
+			s.logf(StatusError, "%s: %s failed (exit code %d)", run.ID, name, *run.ExitCode)
+		}
+	}()
+	return run, nil
+}
+
+// Wait waits for the runs in progress to finish
+func (s *Server) Wait() {
+	s.wg.Wait()
+}
+
+// Runs returns the runs the server keeps, newest first
+func (s *Server) Runs() []*ServerRun {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	runs := make([]*ServerRun, len(s.runs))
+	for i, run := range s.runs {
+		runs[len(runs)-1-i] = run
+	}
+	return runs
+}
+
+// Run returns the run with an ID, or nil
+func (s *Server) Run(id string) *ServerRun {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, run := range s.runs {
+		if run.ID == id {
+			return run
+		}
+	}
+	return nil
+}
+
+// pruneRuns forgets the oldest finished runs past maxServerRuns; the caller holds s.mu
+func (s *Server) pruneRuns() {
+	excess := len(s.runs) - maxServerRuns
+	kept := s.runs[:0]
+	for _, run := range s.runs {
+		if excess > 0 && !run.running() {
+			excess--
+			continue
+		}
+		kept = append(kept, run)
+	}
+	s.runs = kept
+}
+
+// logf reports a server event, if the server has a Logf
+func (s *Server) logf(status Status, format string, args ...interface{}) {
+	if s.Logf != nil {
+		s.Logf(status, format, args...)
+	}
+}
+
+// Write records output of the run and sends its complete lines to the subscribers
+func (r *ServerRun) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.log.Write(p)
+	r.partial = append(r.partial, p...)
+	for {
+		i := bytes.IndexByte(r.partial, '\n')
+		if i < 0 {
+			break
+		}
+		r.publish(serverEvent{name: "log", data: strings.TrimSuffix(string(r.partial[:i]), "\r")})
+		r.partial = r.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+// Log returns the output of the run so far, up to its last serveLogSize bytes
+func (r *ServerRun) Log() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.log.String()
+}
+
+// Cancel stops the run like Ctrl+C would: its after: and on_failure: steps still run
+func (r *ServerRun) Cancel() error {
+	if !r.running() {
+		return fmt.Errorf("run %s has finished", r.ID)
+	}
+	if runtime.GOOS == "windows" {
+		// Windows processes cannot be sent a signal
+		return r.cmd.Process.Kill()
+	}
+	return r.cmd.Process.Signal(os.Interrupt)
+}
+
+// MarshalJSON writes the run as it is now
+func (r *ServerRun) MarshalJSON() ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	type plain ServerRun
+	return json.Marshal((*plain)(r))
+}
+
+// running reports whether the run has not finished
+func (r *ServerRun) running() bool {
+	select {
+	case <-r.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// readEvents follows the progress events of the run until the run closes the pipe
+func (r *ServerRun) readEvents(events io.Reader) {
+	scanner := bufio.NewScanner(events)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		var event ProgressEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil || event.Step == 0 {
+			continue
+		}
+
+		r.mu.Lock()
+		for len(r.Steps) < event.Step {
+			r.Steps = append(r.Steps, StepResult{Index: len(r.Steps) + 1, Status: StepNotRun})
+		}
+		step := &r.Steps[event.Step-1]
+		step.Name, step.Command = event.Name, event.Command
+		step.Status = StepRunning
+		if event.Event == EventStepEnd {
+			step.Status, step.Error = event.Status, event.Error
+			if event.ExitCode != nil {
+				step.ExitCode = *event.ExitCode
+			}
+			if event.DurationMs != nil {
+				step.DurationMs = *event.DurationMs
+			}
+		}
+		r.publish(serverEvent{name: "step", data: scanner.Text()})
+		r.mu.Unlock()
+	}
+}
+
+// finish records the outcome of the run from its report and tells the subscribers
+func (r *ServerRun) finish(output []byte, err error) {
+	var report RunReport
+	reportErr := json.Unmarshal(output, &report)
+
+	r.mu.Lock()
+	if len(r.partial) > 0 {
+		r.publish(serverEvent{name: "log", data: string(r.partial)})
+		r.partial = nil
+	}
+	exitCode := ExitCode(err)
+	duration := time.Since(r.Start).Milliseconds()
+	r.ExitCode, r.DurationMs = &exitCode, &duration
+	r.Status = StepSucceeded
+	if err != nil {
+		r.Status, r.Error = StepFailed, err.Error()
+	}
+	if reportErr == nil {
+		r.Steps, r.RunID = report.Steps, report.RunID
+		if report.Error != "" {
+			r.Error = report.Error
+		}
+		if r.Steps == nil {
+			r.Steps = []StepResult{}
+		}
+	}
+	r.mu.Unlock()
+
+	close(r.done)
+	data, _ := json.Marshal(r)
+	r.mu.Lock()
+	r.publish(serverEvent{name: "end", data: string(data)})
+	for subscriber := range r.subscribers {
+		close(subscriber)
+	}
+	r.subscribers = nil
+	r.mu.Unlock()
+}
+
+// publish sends an event to the subscribers, dropping those that fell behind; the caller
+// holds r.mu
+func (r *ServerRun) publish(event serverEvent) {
+	for subscriber := range r.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+			close(subscriber)
+			delete(r.subscribers, subscriber)
+		}
+	}
+}
+
+// subscribe returns the output of the run so far and a channel of the events after it,
+// closed once the run has finished; nil if it has already
+func (r *ServerRun) subscribe() (string, chan serverEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.subscribers == nil {
+		return r.log.String(), nil
+	}
+	events := make(chan serverEvent, subscriberBuffer)
+	r.subscribers[events] = true
+	// The line being written comes as an event once it is complete
+	past := r.log.String()
+	if len(r.partial) <= len(past) {
+		past = past[:len(past)-len(r.partial)]
+	}
+	return past, events
+}
+
+// unsubscribe stops sending events to a channel of subscribe
+func (r *ServerRun) unsubscribe(events chan serverEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.subscribers[events] {
+		delete(r.subscribers, events)
+		close(events)
+	}
+}
+
+// Handler returns the HTTP handler of the API
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok\n")
+	})
+	mux.HandleFunc("/api/workflows", s.authorized(s.handleWorkflows))
+	mux.HandleFunc("/api/runs", s.authorized(s.handleRuns))
+	mux.HandleFunc("/api/runs/", s.authorized(s.handleRun))
+	mux.HandleFunc("/hooks/", s.handleHook)
+	if s.Metrics != nil {
+		mux.HandleFunc("/metrics", s.authorized(s.Metrics.ServeHTTP))
+	}
+	mux.HandleFunc("/api/history", s.authorized(s.handleHistory))
+	mux.HandleFunc("/api/history/", s.authorized(s.handleHistoryLog))
+	if s.UI {
+		// The page itself holds no data, so it is served without the token, which it asks for
+		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			if !sameOrigin(r) {
+				writeAPIError(w, http.StatusForbidden, "cross-origin requests are not allowed")
+				return
+			}
+			if r.URL.Path != "/" {
+				writeAPIError(w, http.StatusNotFound, fmt.Sprintf("unknown endpoint %s", r.URL.Path))
+				return
+			}
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Header().Set("Content-Security-Policy", "default-src 'self'; script-src 'unsafe-inline'; style-src 'unsafe-inline'")
+			w.Write(web.Index)
+		})
+	}
+	return mux
+}
+
+// authorized wraps a handler so that it requires the server's token, if it has one, and
+// refuses requests sent by the pages of other sites, which any browser on the machine
+// could otherwise send to a server without a token
+func (s *Server) authorized(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !sameOrigin(r) {
+			writeAPIError(w, http.StatusForbidden, "cross-origin requests are not allowed")
+			return
+		}
+		if s.Token != "" {
+			token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(token), []byte(s.Token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="linea"`)
+				writeAPIError(w, http.StatusUnauthorized, "missing or invalid token")
+				return
+			}
+		}
+		handler(w, r)
+	}
+}
+
+// sameOrigin reports whether a request has no Origin, as from curl and other clients, or
+// the server's own, as from its web UI
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && strings.EqualFold(u.Host, r.Host)
+}
+
+// handleWorkflows serves GET /api/workflows
+func (s *Server) handleWorkflows(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "use GET")
+		return
+	}
+	workflows := s.Workflows()
+	if workflows == nil {
+		workflows = []ServedWorkflow{}
+	}
+	writeAPIJSON(w, http.StatusOK, workflows)
+}
+
+// handleRuns serves GET /api/runs and POST /api/runs
+func (s *Server) handleRuns(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeAPIJSON(w, http.StatusOK, s.Runs())
+	case http.MethodPost:
+		// A JSON body cannot be sent from another site without a CORS preflight, which
+		// the server never allows
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+			writeAPIError(w, http.StatusUnsupportedMediaType, "use Content-Type: application/json")
+			return
+		}
+		var request struct {
+			Workflow  string            `json:"workflow"`
+			Variables map[string]string `json:"variables"`
+		}
+		decoder := json.NewDecoder(io.LimitReader(r.Body, 1<<20))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&request); err != nil {
+			writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+			return
+		}
+		run, err := s.StartRun(request.Workflow, request.Variables)
+		if err != nil {
+			status := http.StatusBadRequest
+			if strings.HasSuffix(err.Error(), "not found") {
+				status = http.StatusNotFound
+			} else if strings.HasPrefix(err.Error(), "failed") {
+				status = http.StatusInternalServerError
+			}
+			writeAPIError(w, status, err.Error())
+			return
+		}
+		w.Header().Set("Location", "/api/runs/"+run.ID)
+		writeAPIJSON(w, http.StatusAccepted, run)
+	default:
+		writeAPIError(w, http.StatusMethodNotAllowed, "use GET or POST")
+	}
+}
+
+// handleHistory serves GET /api/history: the runs of the run history, newest first, with
+// the workflow, failed, and limit (default 50) query parameters of linea history
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "use GET")
+		return
+	}
+	query := r.URL.Query()
+	limit := 50
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("invalid limit '%s'", value))
+			return
+		}
+		limit = n
+	}
+	records, err := LoadRunHistory()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	recent := RecentRuns(records, query.Get("workflow"), query.Get("failed") == "true", limit)
+	if recent == nil {
+		recent = []RunRecord{}
+	}
+	writeAPIJSON(w, http.StatusOK, recent)
+}
+
+// handleHistoryLog serves GET /api/history/{id}/log, the output of a recorded run like
+// linea logs
+func (s *Server) handleHistoryLog(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/history/")
+	if !strings.HasSuffix(id, "/log") {
+		writeAPIError(w, http.StatusNotFound, fmt.Sprintf("unknown endpoint %s", r.URL.Path))
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "use GET")
+		return
+	}
+	record, err := FindRunRecord(strings.TrimSuffix(id, "/log"))
+	if err != nil {
+		writeAPIError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	data, err := ReadRunLog(record)
+	if err != nil {
+		writeAPIError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write(data)
+}
+
+// handleRun serves /api/runs/{id}, and its /log, /events, and /cancel
+func (s *Server) handleRun(w http.ResponseWriter, r *http.Request) {
+	id, action := strings.TrimPrefix(r.URL.Path, "/api/runs/"), ""
+	if i := strings.IndexByte(id, '/'); i >= 0 {
+		id, action = id[:i], id[i+1:]
+	}
+	run := s.Run(id)
+	if run == nil {
+		writeAPIError(w, http.StatusNotFound, fmt.Sprintf("run %s not found", id))
+		return
+	}
+
+	method := http.MethodGet
+	if action == "cancel" {
+		method = http.MethodPost
+	}
+	if r.Method != method {
+		writeAPIError(w, http.StatusMethodNotAllowed, "use "+method)
+		return
+	}
+	switch action {
+	case "":
+		writeAPIJSON(w, http.StatusOK, run)
+	case "log":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, run.Log())
+	case "events":
+		streamRunEvents(w, r, run)
+	case "cancel":
+		if err := run.Cancel(); err != nil {
+			writeAPIError(w, http.StatusConflict, err.Error())
+			return
+		}
+		writeAPIJSON(w, http.StatusAccepted, run)
+	default:
+		writeAPIError(w, http.StatusNotFound, fmt.Sprintf("unknown endpoint %s", r.URL.Path))
+	}
+}
+
+// streamRunEvents sends the output of a run so far as log events, then its log and step
+// events as they come, and an end event with the finished run
+func streamRunEvents(w http.ResponseWriter, r *http.Request, run *ServerRun) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeAPIError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	past, events := run.subscribe()
+	if events != nil {
+		defer run.unsubscribe(events)
+	}
+	for _, line := range strings.SplitAfter(past, "\n") {
+		if line != "" {
+			writeServerEvent(w, serverEvent{name: "log", data: strings.TrimRight(line, "\r\n")})
+		}
+	}
+	if events == nil {
+		data, _ := json.Marshal(run)
+		writeServerEvent(w, serverEvent{name: "end", data: string(data)})
+		flusher.Flush()
+		return
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case event, open := <-events:
+			if !open {
+				return
+			}
+			writeServerEvent(w, event)
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// writeServerEvent writes one server-sent event
+func writeServerEvent(w io.Writer, event serverEvent) {
+	fmt.Fprintf(w, "event: %s\n", event.name)
+	for _, line := range strings.Split(event.data, "\n") {
+		fmt.Fprintf(w, "data: %s\n", line)
+	}
+	fmt.Fprint(w, "\n")
+}
+
+// writeAPIJSON writes v as the JSON response of the API
+func writeAPIJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(v)
+}
+
+// writeAPIError writes an error response of the API
+func writeAPIError(w http.ResponseWriter, status int, message string) {
+	writeAPIJSON(w, status, map[string]string{"error": message})
+}
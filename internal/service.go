@@ -0,0 +1,348 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"unicode/utf16"
+)
+
+// ServiceName names the scheduler service: the systemd unit, launchd label suffix,
+// and Windows scheduled task
+const ServiceName = "linea-scheduler"
+
+// launchdLabel is the label of the launchd agent on macOS
+const launchdLabel = "dev.linea.scheduler"
+
+// Service managers used by `linea service`
+const (
+	ServiceSystemd  = "systemd"
+	ServiceLaunchd  = "launchd"
+	ServiceSchtasks = "schtasks" // Windows Task Scheduler
+)
+
+// serviceEnvVars are carried from the installing shell into the service, so that it reads
+// the same schedules and runs workflows with the same PATH and tracing
+var serviceEnvVars = append([]string{
+	"PATH", LineaHomeEnv, ConfigDirEnv, DataDirEnv, StateDirEnv, CacheDirEnv, GlobalWorkflowsEnv, LineaBinEnv, MetricsAddrEnv,
+}, tracingEnvVars...)
+
+// Service is the registration of `linea schedule start` with the platform's service manager
+type Service struct {
+	Manager string // systemd, launchd, or schtasks
+	Path    string // Unit file, launchd plist, or task XML written on install
+	System  bool   // systemd system unit (linea runs as root) instead of a user unit
+	LogPath string // Scheduler log for managers without a journal (launchd, schtasks)
+}
+
+// PlatformService returns the scheduler service for this platform
+// On Linux, running as root installs a system unit and anyone else a user unit
+func PlatformService() (*Service, error) {
+	logPath := filepath.Join(UserPaths().State, ServiceName+".log")
+	switch runtime.GOOS {
+	case "windows":
+		return &Service{Manager: ServiceSchtasks, Path: filepath.Join(UserPaths().State, ServiceName+".xml"), LogPath: logPath}, nil
+	case "darwin":
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("cannot find the home directory: %w", err)
+		}
+		return &Service{Manager: ServiceLaunchd, Path: filepath.Join(home, "Library", "LaunchAgents", launchdLabel+".plist"), LogPath: logPath}, nil
+	}
+
+	if _, err := exec.LookPath("systemctl"); err != nil {
+		return nil, fmt.Errorf("systemctl not found: linea service needs systemd on %s (run `linea schedule start` under your init system instead)", runtime.GOOS)
+	}
+	if os.Geteuid() == 0 {
+		return &Service{Manager: ServiceSystemd, Path: filepath.Join("/etc/systemd/system", ServiceName+".service"), System: true}, nil
+	}
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("cannot find the home directory: %w", err)
+		}
+		configHome = filepath.Join(home, ".config")
+	}
+	return &Service{Manager: ServiceSystemd, Path: filepath.Join(configHome, "systemd", "user", ServiceName+".service")}, nil
+}
+
+// Definition returns the unit file, plist, or task XML that runs lineaPath as the scheduler
+// env holds the variables to set in the service (see ServiceEnv)
+func (s *Service) Definition(lineaPath string, env map[string]string) (string, error) {
+	switch s.Manager {
+	case ServiceSystemd:
+		return SystemdUnit(lineaPath, s.System, env), nil
+	case ServiceLaunchd:
+		return LaunchdPlist(lineaPath, s.LogPath, env), nil
+	case ServiceSchtasks:
+		account, err := user.Current()
+		if err != nil {
+			return "", fmt.Errorf("cannot find the current user: %w", err)
+		}
+		return WindowsTaskXML(lineaPath, s.LogPath, account.Username), nil
+	}
+	return "", fmt.Errorf("unknown service manager '%s'", s.Manager)
+}
+
+// ServiceEnv returns the variables of serviceEnvVars set in the current environment
+func ServiceEnv() map[string]string {
+	env := map[string]string{}
+	for _, name := range serviceEnvVars {
+		if value := os.Getenv(name); value != "" {
+			env[name] = value
+		}
+	}
+	return env
+}
+
+// SystemdUnit returns a systemd unit running the scheduler, restarted when it fails
+// Its messages go to the journal (journalctl -u linea-scheduler)
+func SystemdUnit(lineaPath string, system bool, env map[string]string) string {
+	var b strings.Builder
+	b.WriteString("[Unit]\n")
+	b.WriteString("Description=Linea workflow scheduler\n")
+	b.WriteString("Wants=network-online.target\n")
+	b.WriteString("After=network-online.target\n\n")
+	b.WriteString("[Service]\n")
+	b.WriteString("Type=simple\n")
+	fmt.Fprintf(&b, "ExecStart=%s --theme ci schedule start\n", systemdQuote(lineaPath))
+	for _, name := range sortedMapKeys(env) {
+		fmt.Fprintf(&b, "Environment=%s\n", systemdQuote(name+"="+env[name]))
+	}
+	b.WriteString("Restart=on-failure\n")
+	b.WriteString("RestartSec=10\n")
+	b.WriteString("StandardOutput=journal\n")
+	b.WriteString("StandardError=journal\n\n")
+	b.WriteString("[Install]\n")
+	if system {
+		b.WriteString("WantedBy=multi-user.target\n")
+	} else {
+		b.WriteString("WantedBy=default.target\n")
+	}
+	return b.String()
+}
+
+// systemdQuote escapes % specifiers in a unit file value and quotes it when it has spaces
+func systemdQuote(s string) string {
+	s = strings.ReplaceAll(s, "%", "%%")
+	if !strings.ContainsAny(s, " \t\"\\'") {
+		return s
+	}
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+// LaunchdPlist returns a launchd agent running the scheduler at login, restarted when it
+// exits with an error, with its messages appended to logPath
+func LaunchdPlist(lineaPath, logPath string, env map[string]string) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	b.WriteString(`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">` + "\n")
+	b.WriteString(`<plist version="1.0">` + "\n<dict>\n")
+	fmt.Fprintf(&b, "  <key>Label</key>\n  <string>%s</string>\n", xmlText(launchdLabel))
+	b.WriteString("  <key>ProgramArguments</key>\n  <array>\n")
+	for _, arg := range []string{lineaPath, "--theme", "ci", "schedule", "start"} {
+		fmt.Fprintf(&b, "    <string>%s</string>\n", xmlText(arg))
+	}
+	b.WriteString("  </array>\n")
+	if len(env) > 0 {
+		b.WriteString("  <key>EnvironmentVariables</key>\n  <dict>\n")
+		for _, name := range sortedMapKeys(env) {
+			fmt.Fprintf(&b, "    <key>%s</key>\n    <string>%s</string>\n", xmlText(name), xmlText(env[name]))
+		}
+		b.WriteString("  </dict>\n")
+	}
+	b.WriteString("  <key>RunAtLoad</key>\n  <true/>\n")
+	b.WriteString("  <key>KeepAlive</key>\n  <dict>\n    <key>SuccessfulExit</key>\n    <false/>\n  </dict>\n")
+	b.WriteString("  <key>ThrottleInterval</key>\n  <integer>10</integer>\n")
+	fmt.Fprintf(&b, "  <key>StandardOutPath</key>\n  <string>%s</string>\n", xmlText(logPath))
+	fmt.Fprintf(&b, "  <key>StandardErrorPath</key>\n  <string>%s</string>\n", xmlText(logPath))
+	b.WriteString("</dict>\n</plist>\n")
+	return b.String()
+}
+
+// WindowsTaskXML returns a Task Scheduler task running the scheduler when account logs on,
+// restarted every minute when it fails, with its messages appended to logPath
+// A task is used instead of a Windows service, which needs the service control protocol
+func WindowsTaskXML(lineaPath, logPath, account string) string {
+	arguments := fmt.Sprintf(`--theme ci schedule start --log-file "%s"`, logPath)
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-16"?>` + "\r\n")
+	b.WriteString(`<Task version="1.2" xmlns="http://schemas.microsoft.com/windows/2004/02/mit/task">` + "\r\n")
+	b.WriteString("  <RegistrationInfo>\r\n    <Description>Linea workflow scheduler</Description>\r\n  </RegistrationInfo>\r\n")
+	fmt.Fprintf(&b, "  <Triggers>\r\n    <LogonTrigger>\r\n      <Enabled>true</Enabled>\r\n      <UserId>%s</UserId>\r\n    </LogonTrigger>\r\n  </Triggers>\r\n", xmlText(account))
+	fmt.Fprintf(&b, "  <Principals>\r\n    <Principal id=\"Author\">\r\n      <UserId>%s</UserId>\r\n      <LogonType>InteractiveToken</LogonType>\r\n      <RunLevel>LeastPrivilege</RunLevel>\r\n    </Principal>\r\n  </Principals>\r\n", xmlText(account))
+	b.WriteString("  <Settings>\r\n")
+	b.WriteString("    <MultipleInstancesPolicy>IgnoreNew</MultipleInstancesPolicy>\r\n")
+	b.WriteString("    <DisallowStartIfOnBatteries>false</DisallowStartIfOnBatteries>\r\n")
+	b.WriteString("    <StopIfGoingOnBatteries>false</StopIfGoingOnBatteries>\r\n")
+	b.WriteString("    <ExecutionTimeLimit>PT0S</ExecutionTimeLimit>\r\n")
+	b.WriteString("    <RestartOnFailure>\r\n      <Interval>PT1M</Interval>\r\n      <Count>999</Count>\r\n    </RestartOnFailure>\r\n")
+	b.WriteString("    <Hidden>true</Hidden>\r\n")
+	b.WriteString("  </Settings>\r\n")
+	fmt.Fprintf(&b, "  <Actions Context=\"Author\">\r\n    <Exec>\r\n      <Command>%s</Command>\r\n      <Arguments>%s</Arguments>\r\n    </Exec>\r\n  </Actions>\r\n", xmlText(lineaPath), xmlText(arguments))
+	b.WriteString("</Task>\r\n")
+	return b.String()
+}
+
+// xmlText escapes s for use in XML text
+func xmlText(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// Install writes the service definition and registers it to start at boot (at login for
+// launchd agents and Windows tasks), starting it now
+func (s *Service) Install(lineaPath string) error {
+	definition, err := s.Definition(lineaPath, ServiceEnv())
+	if err != nil {
+		return err
+	}
+	data := []byte(definition)
+	if s.Manager == ServiceSchtasks {
+		data = utf16LE(definition) // schtasks only reads UTF-16 task files reliably
+	}
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(s.Path), err)
+	}
+	if s.LogPath != "" {
+		if err := os.MkdirAll(filepath.Dir(s.LogPath), 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", filepath.Dir(s.LogPath), err)
+		}
+	}
+	if err := os.WriteFile(s.Path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", s.Path, err)
+	}
+
+	switch s.Manager {
+	case ServiceSystemd:
+		if err := s.systemctl("daemon-reload"); err != nil {
+			return err
+		}
+		return s.systemctl("enable", "--now", ServiceName+".service")
+	case ServiceLaunchd:
+		launchctl("unload", s.Path) // Reload an existing agent
+		return launchctl("load", "-w", s.Path)
+	default:
+		if err := schtasks("/Create", "/TN", ServiceName, "/XML", s.Path, "/F"); err != nil {
+			return err
+		}
+		return schtasks("/Run", "/TN", ServiceName)
+	}
+}
+
+// Start starts the installed service
+func (s *Service) Start() error {
+	if err := s.checkInstalled(); err != nil {
+		return err
+	}
+	switch s.Manager {
+	case ServiceSystemd:
+		return s.systemctl("start", ServiceName+".service")
+	case ServiceLaunchd:
+		return launchctl("start", launchdLabel)
+	default:
+		return schtasks("/Run", "/TN", ServiceName)
+	}
+}
+
+// Stop stops the service until the next Start or reboot; scheduled runs in progress finish first
+func (s *Service) Stop() error {
+	if err := s.checkInstalled(); err != nil {
+		return err
+	}
+	switch s.Manager {
+	case ServiceSystemd:
+		return s.systemctl("stop", ServiceName+".service")
+	case ServiceLaunchd:
+		return launchctl("stop", launchdLabel)
+	default:
+		return schtasks("/End", "/TN", ServiceName)
+	}
+}
+
+// Uninstall stops the service, unregisters it, and removes its definition
+// The schedules themselves are kept
+func (s *Service) Uninstall() error {
+	if err := s.checkInstalled(); err != nil {
+		return err
+	}
+	var err error
+	switch s.Manager {
+	case ServiceSystemd:
+		err = s.systemctl("disable", "--now", ServiceName+".service")
+	case ServiceLaunchd:
+		err = launchctl("unload", "-w", s.Path)
+	default:
+		schtasks("/End", "/TN", ServiceName)
+		err = schtasks("/Delete", "/TN", ServiceName, "/F")
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(s.Path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove %s: %w", s.Path, err)
+	}
+	if s.Manager == ServiceSystemd {
+		return s.systemctl("daemon-reload")
+	}
+	return nil
+}
+
+// checkInstalled returns an error if the service definition does not exist
+func (s *Service) checkInstalled() error {
+	if _, err := os.Stat(s.Path); os.IsNotExist(err) {
+		return fmt.Errorf("the scheduler service is not installed (install it with: linea service install)")
+	}
+	return nil
+}
+
+// systemctl runs systemctl for the service's unit scope
+func (s *Service) systemctl(args ...string) error {
+	if !s.System {
+		args = append([]string{"--user"}, args...)
+	}
+	return runServiceTool("systemctl", args...)
+}
+
+// launchctl runs launchctl
+func launchctl(args ...string) error {
+	return runServiceTool("launchctl", args...)
+}
+
+// schtasks runs schtasks.exe
+func schtasks(args ...string) error {
+	return runServiceTool("schtasks", args...)
+}
+
+// runServiceTool runs a service manager command, including its output in the error
+func runServiceTool(name string, args ...string) error {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		if output := strings.TrimSpace(string(out)); output != "" {
+			return fmt.Errorf("%s %s failed: %v: %s", name, strings.Join(args, " "), err, output)
+		}
+		return fmt.Errorf("%s %s failed: %w", name, strings.Join(args, " "), err)
+	}
+	return nil
+}
+
+// utf16LE encodes s as UTF-16 little endian with a byte order mark
+func utf16LE(s string) []byte {
+	units := utf16.Encode([]rune(s))
+	out := make([]byte, 2, 2+2*len(units))
+	out[0], out[1] = 0xFF, 0xFE
+	for _, u := range units {
+		out = append(out, byte(u), byte(u>>8))
+	}
+	return out
+}
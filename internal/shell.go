@@ -0,0 +1,78 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Windows shells that run commands not found on PATH, such as built-ins like echo and dir
+const (
+	ShellCmd        = "cmd"        // cmd.exe, the default
+	ShellPowerShell = "powershell" // Windows PowerShell 5.1
+	ShellPwsh       = "pwsh"       // PowerShell 7 and later
+)
+
+// ShellNames lists the Windows shells accepted by the shell setting, shell:, and --shell
+var ShellNames = []string{ShellCmd, ShellPowerShell, ShellPwsh}
+
+// shellOverride is the shell selected with --shell
+var shellOverride string
+
+// SetShell selects the Windows shell of steps without a shell: of their own, overriding
+// the shell setting (used for --shell); an empty name goes back to the setting
+func SetShell(name string) error {
+	if name == "" {
+		shellOverride = ""
+		return nil
+	}
+	if err := CheckShell(name); err != nil {
+		return err
+	}
+	shellOverride = name
+	return nil
+}
+
+// CheckShell validates the name of a Windows shell
+func CheckShell(name string) error {
+	for _, shell := range ShellNames {
+		if name == shell {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid shell '%s' (expected %s)", name, strings.Join(ShellNames, ", "))
+}
+
+// StepShell returns the Windows shell of a step: its shell:, then --shell, then the shell
+// setting, then cmd. config may be nil for commands outside a workflow
+func StepShell(config *CommandConfig) string {
+	if config != nil && config.Shell != "" {
+		return config.Shell
+	}
+	if shellOverride != "" {
+		return shellOverride
+	}
+	if shell := CurrentUserConfig().Shell; shell != "" {
+		return shell
+	}
+	return ShellCmd
+}
+
+// ShellQuoteStyle returns the quoting style of a Windows shell
+func ShellQuoteStyle(shell string) string {
+	if shell == ShellPowerShell || shell == ShellPwsh {
+		return QuotePowerShell
+	}
+	return QuoteCmd
+}
+
+// WindowsShellCommand returns the command line that runs cmd through a Windows shell
+// cmd.exe gets the arguments separately, to be quoted like C runtime arguments; PowerShell
+// gets a script that runs the quoted command and exits with its exit code, since
+// -Command on its own reduces every failure to 1
+func WindowsShellCommand(cmd []string, shell string) []string {
+	if ShellQuoteStyle(shell) == QuoteCmd {
+		return append([]string{"cmd.exe", "/c"}, cmd...)
+	}
+	script := ShellQuote(cmd, QuotePowerShell) + "; $succeeded = $?; if ($LASTEXITCODE) { exit $LASTEXITCODE } elseif (-not $succeeded) { exit 1 }"
+	return []string{shell, "-NoProfile", "-Command", script}
+}
@@ -0,0 +1,124 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+
+	"linea/internal/store"
+)
+
+// SigningKeyEnv holds the key workflow files are signed and verified with
+const SigningKeyEnv = "LINEA_SIGNING_KEY"
+
+// SignatureExt is appended to a workflow file's name to name its signature file
+const SignatureExt = ".sig"
+
+// ErrUnsigned is returned for a workflow file without the signature verification requires
+var ErrUnsigned = errors.New("not signed")
+
+// ErrSignatureMismatch is returned for a workflow file that does not match its signature
+var ErrSignatureMismatch = errors.New("does not match its signature")
+
+// RequireSignatures makes ParseMultiYAML refuse the workflow files that workflow: steps
+// call and the template files of use: steps that do not match their signature, set by
+// --require-signed, whose own check covers the file it runs
+var RequireSignatures bool
+
+// WorkflowSignature is the content of a signature file: the checksum of the workflow file
+// and, when it was signed with LINEA_SIGNING_KEY, its HMAC
+type WorkflowSignature struct {
+	File   string `yaml:"file"`
+	SHA256 string `yaml:"sha256"`
+	HMAC   string `yaml:"hmac_sha256,omitempty"`
+}
+
+// SignatureFilePath returns the path of the signature file of a workflow file
+func SignatureFilePath(path string) string {
+	return path + SignatureExt
+}
+
+// workflowContent reads a workflow file with CRLF line endings turned into LF, so a
+// checkout that converts line endings still matches its signature
+func workflowContent(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n")), nil
+}
+
+// signWorkflowContent returns the signature of content, with an HMAC if key is not empty
+func signWorkflowContent(name string, content []byte, key string) *WorkflowSignature {
+	sum := sha256.Sum256(content)
+	signature := &WorkflowSignature{File: name, SHA256: hex.EncodeToString(sum[:])}
+	if key != "" {
+		mac := hmac.New(sha256.New, []byte(key))
+		mac.Write(content)
+		signature.HMAC = hex.EncodeToString(mac.Sum(nil))
+	}
+	return signature
+}
+
+// SignWorkflow writes the signature file of a workflow file: its checksum, and its HMAC
+// when LINEA_SIGNING_KEY is set
+func SignWorkflow(path string) (*WorkflowSignature, error) {
+	content, err := workflowContent(path)
+	if err != nil {
+		return nil, err
+	}
+	signature := signWorkflowContent(filepath.Base(path), content, os.Getenv(SigningKeyEnv))
+	data, err := yaml.Marshal(signature)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode signature: %w", err)
+	}
+	data = append([]byte("# Signature of "+signature.File+"; check it with linea verify\n"), data...)
+	if err := store.WriteFile(SignatureFilePath(path), data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", SignatureFilePath(path), err)
+	}
+	return signature, nil
+}
+
+// VerifyWorkflow checks a workflow file against its signature file and reports whether
+// its HMAC was checked too. With LINEA_SIGNING_KEY set, the file must be signed with that
+// key; without it, only its checksum can be checked
+func VerifyWorkflow(path string) (bool, error) {
+	data, err := os.ReadFile(SignatureFilePath(path))
+	if os.IsNotExist(err) {
+		return false, fmt.Errorf("%s is %w: %s not found (sign it with linea sign)", path, ErrUnsigned, SignatureFilePath(path))
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", SignatureFilePath(path), err)
+	}
+	var recorded WorkflowSignature
+	if err := yaml.Unmarshal(data, &recorded); err != nil || recorded.SHA256 == "" {
+		return false, fmt.Errorf("invalid signature file %s", SignatureFilePath(path))
+	}
+
+	content, err := workflowContent(path)
+	if err != nil {
+		return false, err
+	}
+	key := os.Getenv(SigningKeyEnv)
+	actual := signWorkflowContent(recorded.File, content, key)
+	if !hmac.Equal([]byte(actual.SHA256), []byte(recorded.SHA256)) {
+		return false, fmt.Errorf("%s %w: it changed since it was signed", path, ErrSignatureMismatch)
+	}
+	if key == "" {
+		return false, nil
+	}
+	if recorded.HMAC == "" {
+		return false, fmt.Errorf("%s is %w with %s: its signature file only has a checksum", path, ErrUnsigned, SigningKeyEnv)
+	}
+	if !hmac.Equal([]byte(actual.HMAC), []byte(recorded.HMAC)) {
+		return false, fmt.Errorf("%s %w: it was not signed with %s", path, ErrSignatureMismatch, SigningKeyEnv)
+	}
+	return true, nil
+}
@@ -0,0 +1,102 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// WorkflowStats summarizes the runs of one workflow
+type WorkflowStats struct {
+	Workflow      string    `json:"workflow"`
+	Runs          int       `json:"runs"`
+	Failures      int       `json:"failures"`
+	FailureRate   float64   `json:"failure_rate"`
+	AvgDurationMs int64     `json:"avg_duration_ms"`
+	MaxDurationMs int64     `json:"max_duration_ms"`
+	LastRun       time.Time `json:"last_run"`
+}
+
+// PeriodStats summarizes all runs started in one week
+type PeriodStats struct {
+	Period      string  `json:"period"` // ISO week, e.g. 2026-W07
+	Runs        int     `json:"runs"`
+	Failures    int     `json:"failures"`
+	FailureRate float64 `json:"failure_rate"`
+}
+
+// RunStats is the summary printed by `linea stats`
+type RunStats struct {
+	Since     *time.Time      `json:"since,omitempty"`
+	Runs      int             `json:"runs"`
+	Workflows []WorkflowStats `json:"workflows"`
+	Weekly    []PeriodStats   `json:"weekly"`
+}
+
+// ComputeRunStats summarizes the records started at or after since (all records if since is zero)
+// Workflows are ordered by run count, most-run first; weeks are ordered oldest first
+func ComputeRunStats(records []RunRecord, since time.Time) RunStats {
+	stats := RunStats{Workflows: []WorkflowStats{}, Weekly: []PeriodStats{}}
+	if !since.IsZero() {
+		stats.Since = &since
+	}
+
+	byWorkflow := map[string]*WorkflowStats{}
+	totalDuration := map[string]int64{}
+	byWeek := map[string]*PeriodStats{}
+
+	for _, record := range records {
+		if !since.IsZero() && record.Start.Before(since) {
+			continue
+		}
+		stats.Runs++
+
+		ws, ok := byWorkflow[record.Workflow]
+		if !ok {
+			ws = &WorkflowStats{Workflow: record.Workflow}
+			byWorkflow[record.Workflow] = ws
+		}
+		ws.Runs++
+		totalDuration[record.Workflow] += record.DurationMs
+		if record.DurationMs > ws.MaxDurationMs {
+			ws.MaxDurationMs = record.DurationMs
+		}
+		if record.Start.After(ws.LastRun) {
+			ws.LastRun = record.Start
+		}
+
+		year, week := record.Start.ISOWeek()
+		period := fmt.Sprintf("%04d-W%02d", year, week)
+		ps, ok := byWeek[period]
+		if !ok {
+			ps = &PeriodStats{Period: period}
+			byWeek[period] = ps
+		}
+		ps.Runs++
+
+		if !record.Success {
+			ws.Failures++
+			ps.Failures++
+		}
+	}
+
+	for name, ws := range byWorkflow {
+		ws.AvgDurationMs = totalDuration[name] / int64(ws.Runs)
+		ws.FailureRate = float64(ws.Failures) / float64(ws.Runs)
+		stats.Workflows = append(stats.Workflows, *ws)
+	}
+	sort.Slice(stats.Workflows, func(i, j int) bool {
+		if stats.Workflows[i].Runs != stats.Workflows[j].Runs {
+			return stats.Workflows[i].Runs > stats.Workflows[j].Runs
+		}
+		return stats.Workflows[i].Workflow < stats.Workflows[j].Workflow
+	})
+
+	for _, ps := range byWeek {
+		ps.FailureRate = float64(ps.Failures) / float64(ps.Runs)
+		stats.Weekly = append(stats.Weekly, *ps)
+	}
+	sort.Slice(stats.Weekly, func(i, j int) bool { return stats.Weekly[i].Period < stats.Weekly[j].Period })
+
+	return stats
+}
@@ -0,0 +1,112 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// StdinSpec is the stdin: of a step, fed to its command instead of linea's own input:
+// inline text, a file, or the captured output of an earlier step
+type StdinSpec struct {
+	Text string `yaml:"text,omitempty"` // Inline text; `stdin: text` is short for `stdin: {text: text}`
+	File string `yaml:"file,omitempty"` // Path, relative to the workflow file
+	Step string `yaml:"step,omitempty"` // Name of an earlier step with capture: true
+}
+
+// UnmarshalYAML accepts a string as inline text, or a mapping with text, file, or step
+func (s *StdinSpec) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		s.Text = value.Value
+		return nil
+	}
+	type plain StdinSpec
+	return value.Decode((*plain)(s))
+}
+
+// Check validates a stdin: spec
+func (s *StdinSpec) Check() error {
+	set := 0
+	for _, v := range []string{s.Text, s.File, s.Step} {
+		if v != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		return fmt.Errorf("stdin needs exactly one of text, file, or step")
+	}
+	return nil
+}
+
+var (
+	stepOutputsMu sync.Mutex
+	stepOutputs   = make(map[string][]byte) // Captured stdout of the named steps of the run
+)
+
+// recordStepOutput stores the captured stdout of a named step for the stdin: of later steps
+func recordStepOutput(name string, output []byte) {
+	stepOutputsMu.Lock()
+	defer stepOutputsMu.Unlock()
+	stepOutputs[name] = output
+}
+
+// capturedStepOutput returns the captured stdout of a named step, if it has run
+func capturedStepOutput(name string) ([]byte, bool) {
+	stepOutputsMu.Lock()
+	defer stepOutputsMu.Unlock()
+	output, ok := stepOutputs[name]
+	return output, ok
+}
+
+// OpenStepInput returns the input of a step's command as set by its stdin:, or nil
+// without it, in which case the command reads linea's own standard input
+// Inline text and file paths are substituted like the step's arguments
+func OpenStepInput(config *CommandConfig, overrideVars map[string]string) (io.Reader, error) {
+	if config.Stdin == nil {
+		return nil, nil
+	}
+	if err := config.Stdin.Check(); err != nil {
+		return nil, err
+	}
+
+	if config.Stdin.Step != "" {
+		output, ok := capturedStepOutput(stepInputKey(config, config.Stdin.Step))
+		if !ok {
+			return nil, fmt.Errorf("stdin: step '%s' has not run or does not have capture: true", config.Stdin.Step)
+		}
+		return bytes.NewReader(output), nil
+	}
+
+	_, yamlVars, dollarVars, err := stepVariables(config, overrideVars)
+	if err != nil {
+		return nil, err
+	}
+	allVars := make(map[string]string, len(dollarVars))
+	for k, v := range dollarVars {
+		allVars[k] = v
+	}
+	source := config.Stdin.Text + config.Stdin.File
+	if err := ValidateVariablesWithSyntax([]string{source}, allVars, config.VariableSyntax); err != nil {
+		return nil, fmt.Errorf("stdin: %w", err)
+	}
+	source = SubstituteVariablesWithSeparateMaps(source, yamlVars, dollarVars)
+
+	if config.Stdin.Text != "" {
+		return strings.NewReader(source), nil
+	}
+	path := source
+	if !filepath.IsAbs(path) && config.SourceFile != "" {
+		path = filepath.Join(filepath.Dir(config.SourceFile), path)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("stdin: %w", err)
+	}
+	return file, nil
+}
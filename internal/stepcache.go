@@ -0,0 +1,328 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"linea/internal/store"
+)
+
+// NoCache runs the steps with cache: even when their inputs are unchanged (linea run
+// --no-cache); their cache is still refreshed when they succeed
+var NoCache bool
+
+// StepCacheSpec is the cache: of a step: it is skipped while its command line, its key,
+// and the contents of the files in paths are those of the last time it succeeded
+type StepCacheSpec struct {
+	Key   string   `yaml:"key,omitempty"`   // Substituted like the step's arguments, e.g. deps-{env}; `cache: key` is short for `cache: {key: key}`
+	Paths []string `yaml:"paths,omitempty"` // Input files, relative to the workflow file; * and ** are globs and a directory stands for the files in it
+}
+
+// UnmarshalYAML accepts a string as the key, or a mapping with key and paths
+func (s *StepCacheSpec) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		s.Key = value.Value
+		return nil
+	}
+	type plain StepCacheSpec
+	return value.Decode((*plain)(s))
+}
+
+// CheckStepCache validates the cache: of a step
+func CheckStepCache(config *CommandConfig) error {
+	spec := config.Cache
+	if spec == nil {
+		return nil
+	}
+	if strings.TrimSpace(spec.Key) == "" && len(spec.Paths) == 0 {
+		return fmt.Errorf("cache needs a key or paths")
+	}
+	if config.Background {
+		return fmt.Errorf("background steps cannot be cached; linea does not wait for them to succeed")
+	}
+	if config.After || config.OnFailure {
+		return fmt.Errorf("cleanup steps cannot be cached")
+	}
+	for _, pattern := range spec.Paths {
+		if strings.TrimSpace(pattern) == "" {
+			return fmt.Errorf("cache: empty path")
+		}
+		if _, err := path.Match(filepath.ToSlash(pattern), ""); err != nil {
+			return fmt.Errorf("cache: invalid path '%s': %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// StepCacheRecord is the cache of a step, written when the step succeeds
+type StepCacheRecord struct {
+	Workflow string    `json:"workflow"`
+	Path     string    `json:"path"` // Workflow file
+	Step     string    `json:"step"`
+	Hash     string    `json:"hash"` // Of the command line, key, and input files
+	Saved    time.Time `json:"saved"`
+	ExitCode int       `json:"exit_code"`        // For {exit_code} in the steps after it
+	Output   []byte    `json:"output,omitempty"` // Captured stdout of a step with capture:, for stdin: {step: name}
+}
+
+// StepCache is the cache of one step in a run: the hash of its inputs and the file that
+// records the hash it last succeeded with
+type StepCache struct {
+	Hash string
+	file string
+}
+
+// StepCacheDir returns the directory of the step caches of every workflow
+func StepCacheDir() string {
+	return filepath.Join(UserPaths().Cache, "steps")
+}
+
+// stepCacheFile returns the cache record of step index of a workflow file. The directory
+// name includes a hash of the path, like the state files of the workflow
+func stepCacheFile(workflow string, config *CommandConfig, index int) string {
+	if abs, err := filepath.Abs(workflow); err == nil {
+		workflow = abs
+	}
+	sum := sha256.Sum256([]byte(workflow))
+	dir := fmt.Sprintf("%s-%s", WorkflowName(workflow), hex.EncodeToString(sum[:4]))
+	step := fmt.Sprintf("step-%d", index)
+	if config.Name != "" {
+		step = JobID(config)
+	}
+	return filepath.Join(StepCacheDir(), dir, step+".json")
+}
+
+// LookupStepCache hashes the inputs of step index with the command line cmd, and returns
+// its record if the step last succeeded with the same inputs. It returns a nil cache for
+// a step without cache:, and never a record with NoCache
+func LookupStepCache(config *CommandConfig, index int, cmd []string, overrideVars map[string]string) (*StepCache, *StepCacheRecord, error) {
+	if config.Cache == nil || config.SourceFile == "" {
+		return nil, nil, nil
+	}
+	hash, err := stepCacheHash(config, cmd, overrideVars)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cache: %w", err)
+	}
+	cache := &StepCache{Hash: hash, file: stepCacheFile(config.SourceFile, config, index)}
+	if NoCache {
+		return cache, nil, nil
+	}
+	data, err := os.ReadFile(cache.file)
+	if err != nil {
+		return cache, nil, nil
+	}
+	var record StepCacheRecord
+	if json.Unmarshal(data, &record) != nil || record.Hash != hash {
+		return cache, nil, nil
+	}
+	return cache, &record, nil
+}
+
+// stepCacheHash hashes the command line of a step, its cache key, and the path and
+// contents of each of its input files
+func stepCacheHash(config *CommandConfig, cmd []string, overrideVars map[string]string) (string, error) {
+	key := config.Cache.Key
+	if key != "" {
+		_, yamlVars, dollarVars, err := stepVariables(config, overrideVars)
+		if err != nil {
+			return "", err
+		}
+		if err := ValidateVariablesWithSyntax([]string{key}, dollarVars, config.VariableSyntax); err != nil {
+			return "", err
+		}
+		key = SubstituteVariablesWithSeparateMaps(key, yamlVars, dollarVars)
+	}
+
+	h := sha256.New()
+	for _, arg := range cmd {
+		fmt.Fprintf(h, "arg %q\n", arg)
+	}
+	fmt.Fprintf(h, "key %q\n", key)
+	base := filepath.Dir(config.SourceFile)
+	files, err := cacheInputFiles(base, config.Cache.Paths)
+	if err != nil {
+		return "", err
+	}
+	for _, rel := range files {
+		sum, err := FileSHA256(filepath.Join(base, filepath.FromSlash(rel)))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "file %q %s\n", rel, sum)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// cacheInputFiles returns the slash-separated paths, relative to base, of the files that
+// the patterns name, sorted. A pattern that names no file is an error, as a misspelled
+// path would otherwise never invalidate the cache
+func cacheInputFiles(base string, patterns []string) ([]string, error) {
+	seen := map[string]bool{}
+	for _, pattern := range patterns {
+		pattern = strings.TrimPrefix(filepath.ToSlash(pattern), "./")
+		root, glob := pattern, ""
+		if i := strings.IndexAny(pattern, "*?["); i >= 0 {
+			root = strings.TrimSuffix(pattern[:strings.LastIndex(pattern[:i], "/")+1], "/")
+			glob = pattern
+		}
+		start := filepath.Join(base, filepath.FromSlash(root))
+		matched := false
+		err := filepath.WalkDir(start, func(file string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(base, file)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			if entry.IsDir() {
+				// Without **, the files a glob matches are no deeper than it
+				if glob != "" && !strings.Contains(glob, "**") && rel != "." && strings.Count(rel, "/") >= strings.Count(glob, "/") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if glob == "" || matchSegments(strings.Split(glob, "/"), strings.Split(rel, "/")) {
+				seen[rel], matched = true, true
+			}
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if !matched {
+			return nil, fmt.Errorf("no file matches '%s'", pattern)
+		}
+	}
+	files := make([]string, 0, len(seen))
+	for file := range seen {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// restore gives the steps after a cached step the captured output and exit code they
+// would have seen had it run
+func (r *StepCacheRecord) restore(config *CommandConfig) {
+	if config.Capture && config.Name != "" {
+		for _, key := range stepOutputKeys(config) {
+			recordStepOutput(key, r.Output)
+		}
+	}
+	recordExitCode(r.ExitCode)
+}
+
+// notice tells w that a step was skipped because its inputs are unchanged
+func (r *StepCacheRecord) notice(w io.Writer, label string) {
+	fmt.Fprint(w, Output.Sprintf(w, StatusSkip, "Cached: %s (inputs unchanged since %s; --no-cache runs it)\n",
+		truncateLabel(label), r.Saved.Local().Format("2006-01-02 15:04:05")))
+}
+
+// RunCachedStep runs the only step of a workflow, with the command line cmd, through its
+// cache: like the steps of a multi-step run, it is skipped while its inputs are unchanged,
+// and its inputs are saved when run succeeds
+func RunCachedStep(config *CommandConfig, cmd []string, overrideVars map[string]string, stdout io.Writer, run func() error) error {
+	cache, cached, err := LookupStepCache(config, 1, cmd, overrideVars)
+	if err != nil {
+		return err
+	}
+	if cached != nil {
+		cached.restore(config)
+		cached.notice(stdout, stepLabel(config, cmd))
+		return nil
+	}
+	if err := run(); err != nil {
+		return err
+	}
+	cache.Save(config, lastStepExitCode())
+	return nil
+}
+
+// Save records that a step succeeded with the inputs of the cache; the captured output of
+// a step with capture: is kept with it. A cache that cannot be written only means that
+// the step runs again next time, so errors are ignored
+func (c *StepCache) Save(config *CommandConfig, exitCode int) {
+	if c == nil {
+		return
+	}
+	record := StepCacheRecord{
+		Workflow: WorkflowName(config.SourceFile),
+		Path:     config.SourceFile,
+		Step:     strings.TrimSuffix(filepath.Base(c.file), ".json"),
+		Hash:     c.Hash,
+		Saved:    time.Now().UTC(),
+		ExitCode: exitCode,
+	}
+	if abs, err := filepath.Abs(record.Path); err == nil {
+		record.Path = abs
+	}
+	if config.Capture && config.Name != "" {
+		record.Output, _ = capturedStepOutput(stepOutputKeys(config)[0])
+	}
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(c.file), 0755); err != nil {
+		return
+	}
+	// Private, as the captured output of the step is kept unmasked
+	store.WriteFile(c.file, append(data, '\n'), 0600)
+}
+
+// ClearStepCache removes the step caches of the named workflows, or of every workflow if
+// none are named, so that their cached steps run again. Returns the number of steps
+func ClearStepCache(workflows []string) (int, error) {
+	dir := StepCacheDir()
+	items, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read step cache directory %s: %w", dir, err)
+	}
+	names := map[string]bool{}
+	for _, name := range workflows {
+		names[WorkflowName(name)] = true
+	}
+	cleared := 0
+	for _, item := range items {
+		// Directories are named <workflow>-<hash of its path>
+		i := strings.LastIndex(item.Name(), "-")
+		if len(workflows) > 0 && (i < 0 || !names[item.Name()[:i]]) {
+			continue
+		}
+		path := filepath.Join(dir, item.Name())
+		steps, _ := os.ReadDir(path)
+		if err := os.RemoveAll(path); err != nil {
+			return cleared, fmt.Errorf("failed to remove step cache %s: %w", path, err)
+		}
+		cleared += len(steps)
+	}
+	return cleared, nil
+}
+
+// CountStepCache returns the number of cached steps of every workflow
+func CountStepCache() int {
+	dirs, _ := os.ReadDir(StepCacheDir())
+	count := 0
+	for _, dir := range dirs {
+		steps, _ := os.ReadDir(filepath.Join(StepCacheDir(), dir.Name()))
+		count += len(steps)
+	}
+	return count
+}
@@ -0,0 +1,134 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// StepTemplate is a parameterized sequence of steps that use: steps run, declared under
+// templates: in the metadata document or in a file of .linea/templates
+type StepTemplate struct {
+	Description string           `yaml:"description,omitempty"`
+	Params      []WorkflowInput  `yaml:"params,omitempty"` // Set by with:; those with a default are optional
+	Steps       []*CommandConfig `yaml:"steps"`
+	Exports     []string         `yaml:"exports,omitempty"` // Steps whose output the steps after the use: see
+}
+
+// TemplatesDir returns the .linea/templates directory of the project of a workflow file,
+// or "" if it has no .linea directory
+func TemplatesDir(workflowPath string) string {
+	dir := FindLineaDir(filepath.Dir(absPath(workflowPath)))
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "templates")
+}
+
+// findTemplate returns the template a use: step names: one under templates: in the
+// metadata document of its file, else the file of that name in .linea/templates
+func findTemplate(config *CommandConfig) (*StepTemplate, error) {
+	if config.Metadata != nil {
+		if tmpl, ok := config.Metadata.Templates[config.Use]; ok {
+			return tmpl, nil
+		}
+	}
+	if dir := TemplatesDir(config.SourceFile); dir != "" {
+		for _, ext := range WorkflowExtensions {
+			path := filepath.Join(dir, config.Use+ext)
+			if _, err := os.Stat(path); err != nil {
+				continue
+			}
+			if RequireSignatures {
+				if _, err := VerifyWorkflow(path); err != nil {
+					return nil, err
+				}
+			}
+			data, err := ReadWorkflowFile(path)
+			if err != nil {
+				return nil, err
+			}
+			var tmpl StepTemplate
+			if err := yaml.Unmarshal(data, &tmpl); err != nil {
+				return nil, fmt.Errorf("template %s: %w", path, err)
+			}
+			return &tmpl, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown template (declare it under templates: or in .linea/templates)")
+}
+
+// CheckTemplateUse validates a use: step against the template it names
+func CheckTemplateUse(config *CommandConfig, tmpl *StepTemplate) error {
+	if config.Command != "" || config.Type != "" || len(config.Args) > 0 || config.Group != "" || config.Workflow != "" || config.When != "" || config.Confirm != "" || config.Stdin != nil || config.Foreach != nil {
+		return fmt.Errorf("a use: step cannot have command, type, args, group, workflow, when, confirm, stdin, or foreach; put them in the template")
+	}
+	if len(tmpl.Steps) == 0 {
+		return fmt.Errorf("the template has no steps")
+	}
+	if err := CheckWorkflowInputs(tmpl.Params); err != nil {
+		return fmt.Errorf("template %w", err)
+	}
+	params := make(map[string]bool, len(tmpl.Params))
+	for _, param := range tmpl.Params {
+		params[param.Name] = true
+		if _, ok := config.With[param.Name]; !ok && !param.Optional() {
+			return fmt.Errorf("missing parameter '%s' under with:", param.Name)
+		}
+	}
+	for _, name := range sortedMapKeys(config.With) {
+		if !params[name] {
+			return fmt.Errorf("unknown parameter '%s'", name)
+		}
+	}
+	return nil
+}
+
+// templateGroup returns the group a use: step runs: the steps of its template, in a
+// private scope whose inputs are the parameters, named after the step or else the template
+func templateGroup(config *CommandConfig) (*CommandConfig, error) {
+	tmpl, err := findTemplate(config)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckTemplateUse(config, tmpl); err != nil {
+		return nil, err
+	}
+
+	group := &CommandConfig{
+		Group:      config.Name,
+		Inputs:     make(map[string]string, len(tmpl.Params)),
+		Steps:      tmpl.Steps,
+		Exports:    tmpl.Exports,
+		SourceFile: config.SourceFile,
+		Metadata:   config.Metadata,
+	}
+	if group.Group == "" {
+		group.Group = config.Use
+	}
+	for _, param := range tmpl.Params {
+		if value, ok := config.With[param.Name]; ok {
+			group.Inputs[param.Name] = value
+		} else if group.Inputs[param.Name], err = ExpressionDefault(*param.Default); err != nil {
+			return nil, fmt.Errorf("%s: default: %w", param.Name, err)
+		}
+	}
+	return group, nil
+}
+
+// expandTemplateUse returns the steps a use: step runs, ready to run in order with the
+// other steps of the file. They are the steps of a group whose inputs, the with: values,
+// are evaluated in the scope of the use: step
+func expandTemplateUse(config *CommandConfig) ([]*CommandConfig, error) {
+	group, err := templateGroup(config)
+	if err != nil {
+		return nil, fmt.Errorf("use %s: %w", config.Use, err)
+	}
+	steps := ExpandGroup(group)
+	for _, step := range steps {
+		step.UsedBy = config
+	}
+	return steps, nil
+}
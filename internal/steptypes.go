@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// StepType is a kind of step that linea runs itself instead of an external command,
+// selected with type:. Its fields are turned into the arguments of a built-in command,
+// [type args...], so that they get variable substitution, secret masking, and dry-run
+// display like the arguments of any other command
+type StepType struct {
+	Args func(config *CommandConfig) ([]string, error)       // The step's fields as arguments, before substitution
+	Run  func(args []string, stdout, stderr io.Writer) error // Runs the step with its substituted arguments
+}
+
+// stepTypes holds the built-in step types by name
+var stepTypes = map[string]StepType{
+	StepTypeHealthCheck: {Args: healthCheckArgs, Run: runHealthCheck},
+	StepTypeCopy:        {Args: fileStepArgs(StepTypeCopy), Run: runCopy},
+	StepTypeMove:        {Args: fileStepArgs(StepTypeMove), Run: runMove},
+	StepTypeTemplate:    {Args: fileStepArgs(StepTypeTemplate), Run: runTemplate},
+	StepTypeMkdir:       {Args: fileStepArgs(StepTypeMkdir), Run: runMkdir},
+	StepTypeRm:          {Args: fileStepArgs(StepTypeRm), Run: runRm},
+	StepTypeArchive:     {Args: archiveArgs, Run: runArchive},
+	StepTypeExtract:     {Args: extractArgs, Run: runExtract},
+	StepTypeDownload:    {Args: downloadArgs, Run: runDownload},
+	StepTypeUpload:      {Args: uploadArgs, Run: runUpload},
+	StepTypeGit:         {Args: gitArgs, Run: runGit},
+	StepTypeNotify:      {Args: notifyArgs, Run: runNotify},
+	StepTypeAssert:      {Args: assertArgs, Run: runAssert},
+}
+
+// LookupStepType returns the built-in step type called name
+func LookupStepType(name string) (StepType, error) {
+	stepType, ok := stepTypes[name]
+	if !ok {
+		return StepType{}, fmt.Errorf("unknown step type '%s' (expected one of %v)", name, StepTypeNames())
+	}
+	return stepType, nil
+}
+
+// StepArguments returns the arguments of a step before variable substitution: its args:,
+// or those made from the fields of its built-in type
+func StepArguments(config *CommandConfig) ([]string, error) {
+	if config.Type == "" {
+		return config.Args, nil
+	}
+	stepType, err := LookupStepType(config.Type)
+	if err != nil {
+		return nil, err
+	}
+	return stepType.Args(config)
+}
+
+// StepTypeNames returns the names of the built-in step types in sorted order
+func StepTypeNames() []string {
+	names := make([]string, 0, len(stepTypes))
+	for name := range stepTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// StepExitError is the failure of a built-in step, with the exit code it reports for
+// {exit_code} and allowed_exit_codes:
+type StepExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *StepExitError) Error() string { return e.Err.Error() }
+func (e *StepExitError) Unwrap() error { return e.Err }
+
+// ExitCode returns the exit code of the failed step
+func (e *StepExitError) ExitCode() int { return e.Code }
+
+// exitCoder is an error carrying an exit code: *exec.ExitError or *StepExitError
+type exitCoder interface {
+	ExitCode() int
+}
+
+// errorExitCode returns the exit code carried by err, if it has one; commands killed by
+// a signal have none
+func errorExitCode(err error) (int, bool) {
+	var coded exitCoder
+	if errors.As(err, &coded) && coded.ExitCode() >= 0 {
+		return coded.ExitCode(), true
+	}
+	return 0, false
+}
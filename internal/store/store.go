@@ -0,0 +1,148 @@
+// Package store provides the file primitives linea uses for its shared state files
+// (history, config, schedules, secrets, caches): advisory locks, so that concurrent linea
+// processes such as parallel CI jobs on one runner take turns, and atomic writes, so that
+// readers never see a half-written file
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// LockSuffix is appended to a file's path to name its lock file
+const LockSuffix = ".lock"
+
+var (
+	// LockTimeout is how long Lock waits for another process to release a lock
+	LockTimeout = 10 * time.Second
+
+	// StaleLockAge is the age after which a lock is assumed to belong to a process that
+	// crashed, and is broken. Locks are only held while a file is read and rewritten
+	StaleLockAge = 30 * time.Second
+
+	lockRetryInterval = 10 * time.Millisecond
+)
+
+// FileLock is a held advisory lock on a file
+type FileLock struct {
+	path string
+}
+
+// Lock acquires the advisory lock of path, waiting up to LockTimeout if another process
+// holds it. The lock is a path.lock file created exclusively, which works the same on every
+// platform; only linea processes that use this package respect it
+// The directory of path must exist
+func Lock(path string) (*FileLock, error) {
+	lockPath := path + LockSuffix
+	deadline := time.Now().Add(LockTimeout)
+	for {
+		file, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			file.WriteString(strconv.Itoa(os.Getpid()) + "\n")
+			file.Close()
+			return &FileLock{path: lockPath}, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("failed to create lock file %s: %w", lockPath, err)
+		}
+
+		if info, err := os.Stat(lockPath); err == nil && time.Since(info.ModTime()) > StaleLockAge {
+			// The holder crashed; whoever removes the stale lock first gets to retry
+			os.Remove(lockPath)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for lock %s (remove it if no other linea process is running)", lockPath)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// Unlock releases the lock
+func (l *FileLock) Unlock() error {
+	if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove lock file %s: %w", l.path, err)
+	}
+	return nil
+}
+
+// WithLock runs fn while holding the lock of path
+func WithLock(path string, fn func() error) error {
+	lock, err := Lock(path)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	return fn()
+}
+
+// WriteFile atomically replaces the contents of path: the data is written to a temporary
+// file in the same directory, flushed to disk, and renamed over path
+// It does not take the lock; use WithLock or Update when the new contents depend on the old
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	tmp, err := os.CreateTemp(dir, "."+base+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmpPath, perm)
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// Update rewrites path under its lock: fn receives the current contents (nil if the file
+// does not exist) and returns the new contents, which are written atomically
+// Returning an error from fn leaves the file unchanged
+func Update(path string, perm os.FileMode, fn func(data []byte) ([]byte, error)) error {
+	return WithLock(path, func() error {
+		data, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		updated, err := fn(data)
+		if err != nil {
+			return err
+		}
+		return WriteFile(path, updated, perm)
+	})
+}
+
+// AppendFile appends data to path under its lock, creating the file if needed
+// Used for line-based logs such as the run history, where each append must land whole
+func AppendFile(path string, data []byte, perm os.FileMode) error {
+	return WithLock(path, func() error {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, perm)
+		if err != nil {
+			return err
+		}
+		_, err = file.Write(data)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	})
+}
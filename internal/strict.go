@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// StrictParse makes ParseYAML and ParseMultiYAML refuse the keys the workflow schema does
+// not know and the documents without a command, set by the --strict global flag. Otherwise
+// a typo like commannd: is ignored, and the document it leaves without a command is
+// skipped. linea validate always reports both
+var StrictParse bool
+
+// checkStrictDocument returns an error naming the unknown keys of a workflow document,
+// checked after its upgrades like linea validate does, or the lack of a command. Line
+// numbers are left out unless lines is set, as those of a converted file are not the file's
+func checkStrictDocument(root *yaml.Node, lines bool) error {
+	if root.Kind != yaml.MappingNode {
+		return nil
+	}
+	s, err := loadWorkflowSchema()
+	if err != nil {
+		return err
+	}
+	if _, err := UpgradeWorkflowNode(root); err != nil {
+		return err
+	}
+
+	at := func(line int, message string) string {
+		if lines && line > 0 {
+			return fmt.Sprintf("line %d: %s", line, message)
+		}
+		return message
+	}
+
+	var unknown []string
+	unknownKeys(root, s, func(key *yaml.Node, known map[string]*jsonSchema) {
+		unknown = append(unknown, at(key.Line, fmt.Sprintf("unknown key '%s'%s", key.Value, suggestKey(key.Value, known))))
+	})
+	if len(unknown) > 0 {
+		return fmt.Errorf("strict mode: %s", strings.Join(unknown, "; "))
+	}
+
+	if !hasMappingKey(root, "command") && !hasMappingKey(root, "type") && !hasMappingKey(root, "group") && !hasMappingKey(root, "workflow") && !hasMappingKey(root, "use") {
+		return fmt.Errorf("strict mode: %s", at(root.Line, "document has no command, type, group, workflow, or use"))
+	}
+	return nil
+}
+
+// unknownKeys calls report for each mapping key under node that schema s does not allow,
+// with the keys it does
+func unknownKeys(node *yaml.Node, s *jsonSchema, report func(key *yaml.Node, known map[string]*jsonSchema)) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if prop, ok := s.Properties[key.Value]; ok {
+				unknownKeys(value, prop, report)
+			} else if s.AdditionalProperties != nil && !s.AdditionalProperties.Allowed {
+				report(key, s.Properties)
+			} else if s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil {
+				unknownKeys(value, s.AdditionalProperties.Schema, report)
+			}
+		}
+	case yaml.SequenceNode:
+		if s.Items != nil {
+			for _, item := range node.Content {
+				unknownKeys(item, s.Items, report)
+			}
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Structured variables hold a list or a map. Their value is the JSON text of the list or
+// map, e.g. ["web1","web2"], so that they pass through -s, history, and reports like any
+// other variable; {name[0]} and {name.key} select an element, and {name|join:,} joins a list
+
+// StructuredValue returns the value of a variable given as JSON (--set-json): a JSON string
+// is its text, and anything else is compact JSON
+func StructuredValue(text string) (string, error) {
+	var value interface{}
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return "", fmt.Errorf("invalid JSON: %w", err)
+	}
+	if decoder.More() {
+		return "", fmt.Errorf("invalid JSON: unexpected data after the value")
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	return compactJSON(value)
+}
+
+// ListValue returns the items of a list variable, each as a variable value; ok is false if
+// the value is not a JSON list
+func ListValue(value string) ([]string, bool) {
+	items, ok := decodeStructured(value).([]interface{})
+	if !ok {
+		return nil, false
+	}
+	values := make([]string, len(items))
+	for i, item := range items {
+		text, err := jsonString(item)
+		if err != nil {
+			return nil, false
+		}
+		values[i] = text
+	}
+	return values, true
+}
+
+// yamlVariableValue returns the value of a list or map under variables: as JSON
+func yamlVariableValue(node *yaml.Node) (string, error) {
+	var value interface{}
+	if err := node.Decode(&value); err != nil {
+		return "", err
+	}
+	return compactJSON(value)
+}
+
+// compactJSON encodes a value as JSON on one line, without escaping <, >, and &
+func compactJSON(value interface{}) (string, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// decodeStructured returns the decoded list or map of a structured value, or nil if the
+// value is not a JSON list or object
+func decodeStructured(value string) interface{} {
+	trimmed := strings.TrimSpace(value)
+	if !strings.HasPrefix(trimmed, "[") && !strings.HasPrefix(trimmed, "{") {
+		return nil
+	}
+	var decoded interface{}
+	decoder := json.NewDecoder(strings.NewReader(trimmed))
+	decoder.UseNumber()
+	if decoder.Decode(&decoded) != nil {
+		return nil
+	}
+	switch decoded.(type) {
+	case []interface{}, map[string]interface{}:
+		return decoded
+	}
+	return nil
+}
+
+// splitVariablePath splits a reference such as targets[0] or config.db.host into the
+// variable name and the keys and indexes after it; ok is false if path is not of that form
+func splitVariablePath(path string) (string, []string, bool) {
+	end := 0
+	for end < len(path) && path[end] != '[' && path[end] != '.' {
+		end++
+	}
+	name := path[:end]
+	if !isVariableName(name) {
+		return "", nil, false
+	}
+
+	var keys []string
+	for rest := path[end:]; rest != ""; {
+		switch rest[0] {
+		case '[':
+			close := strings.IndexByte(rest, ']')
+			if close == -1 {
+				return "", nil, false
+			}
+			if _, err := strconv.Atoi(rest[1:close]); err != nil {
+				return "", nil, false
+			}
+			keys = append(keys, rest[1:close])
+			rest = rest[close+1:]
+		case '.':
+			next := 1
+			for next < len(rest) && rest[next] != '[' && rest[next] != '.' {
+				next++
+			}
+			key := rest[1:next]
+			if key == "" || strings.ContainsAny(key, "{}$| ") {
+				return "", nil, false
+			}
+			keys = append(keys, key)
+			rest = rest[next:]
+		default:
+			return "", nil, false
+		}
+	}
+	return name, keys, true
+}
+
+// variableReferenceName returns the variable a {...} reference names: the variable of a
+// path such as targets[0], or the reference itself
+func variableReferenceName(ref string) string {
+	if name, _, ok := splitVariablePath(ref); ok {
+		return name
+	}
+	return ref
+}
+
+// lookupVariable returns the value a reference such as targets[0] or config.port selects
+// from the variables; defined is false if the variable is not set, and err describes an
+// index or key that the value does not have
+func lookupVariable(path string, variables map[string]string) (value string, defined bool, err error) {
+	name, keys, ok := splitVariablePath(path)
+	if !ok {
+		value, defined = variables[path]
+		return value, defined, nil
+	}
+	value, defined = variables[name]
+	if !defined || len(keys) == 0 {
+		return value, defined, nil
+	}
+
+	current := decodeStructured(value)
+	for _, key := range keys {
+		switch v := current.(type) {
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return "", true, fmt.Errorf("{%s}: index %s is out of range (the list has %d items)", path, key, len(v))
+			}
+			current = v[i]
+		case map[string]interface{}:
+			next, ok := v[key]
+			if !ok {
+				return "", true, fmt.Errorf("{%s}: no key '%s' in the map", path, key)
+			}
+			current = next
+		default:
+			return "", true, fmt.Errorf("{%s}: cannot select '%s' from a value that is not a list or a map", path, key)
+		}
+	}
+	value, err = jsonString(current)
+	return value, true, err
+}
+
+// variablePathReferences returns the references in s that select into a variable, such as
+// targets[0] in {targets[0]} or ${targets[0]}
+func variablePathReferences(s string) []string {
+	var paths []string
+	start := -1
+	for i := 0; i < len(s); i++ {
+		if s[i] == '{' {
+			start = i
+		} else if s[i] == '}' && start != -1 {
+			ref := s[start+1 : i]
+			if expr, _, ok := parsePipeline(ref); ok {
+				ref = expr
+			}
+			if _, keys, ok := splitVariablePath(ref); ok && len(keys) > 0 {
+				paths = append(paths, ref)
+			}
+			start = -1
+		}
+	}
+	return paths
+}
+
+// validateVariablePaths reports references that select an index or key the value of a
+// defined variable does not have
+func validateVariablePaths(s string, variables map[string]string) error {
+	for _, path := range variablePathReferences(s) {
+		if _, _, err := lookupVariable(path, variables); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package internal
+
+import "strings"
+
+// Kinds of the tokens of a template
+const (
+	tokenText        = iota // Literal text
+	tokenBrace              // {name}, {targets[0]}, or {name|upper}
+	tokenDollarBrace        // ${name}, ${targets[0]}, or ${name|upper}
+	tokenDollar             // $name
+)
+
+// templateToken is a run of literal text or a placeholder of a template
+type templateToken struct {
+	kind int
+	text string // As written
+	expr string // The name, path, or pipeline of a placeholder
+}
+
+// tokenizeTemplate splits a template into literal text and placeholders in a single scan.
+// Braces are a placeholder when they hold a variable name, a path such as targets[0], or
+// a pipeline such as name|upper; others, as in JSON or an awk program, are text
+func tokenizeTemplate(s string) []templateToken {
+	var tokens []templateToken
+	text := 0 // Start of the literal text not yet added
+	for i := 0; i < len(s); {
+		kind, end := placeholderAt(s, i)
+		if kind == tokenText {
+			i++
+			continue
+		}
+		if i > text {
+			tokens = append(tokens, templateToken{kind: tokenText, text: s[text:i]})
+		}
+		token := templateToken{kind: kind, text: s[i:end]}
+		switch kind {
+		case tokenBrace:
+			token.expr = s[i+1 : end-1]
+		case tokenDollarBrace:
+			token.expr = s[i+2 : end-1]
+		case tokenDollar:
+			token.expr = s[i+1 : end]
+		}
+		tokens = append(tokens, token)
+		i, text = end, end
+	}
+	if text < len(s) {
+		tokens = append(tokens, templateToken{kind: tokenText, text: s[text:]})
+	}
+	return tokens
+}
+
+// placeholderAt returns the kind and end of the placeholder that starts at s[i], or
+// tokenText if none does
+func placeholderAt(s string, i int) (int, int) {
+	switch {
+	case s[i] == '{':
+		if end := strings.IndexByte(s[i+1:], '}'); end != -1 && isPlaceholderExpr(s[i+1:i+1+end]) {
+			return tokenBrace, i + end + 2
+		}
+	case s[i] == '$' && i+1 < len(s) && s[i+1] == '{':
+		if end := strings.IndexByte(s[i+2:], '}'); end != -1 && isPlaceholderExpr(s[i+2:i+2+end]) {
+			return tokenDollarBrace, i + end + 3
+		}
+	case s[i] == '$':
+		end := i + 1
+		for end < len(s) && isVariableName(s[end:end+1]) {
+			end++
+		}
+		if end > i+1 {
+			return tokenDollar, end
+		}
+	}
+	return tokenText, 0
+}
+
+// isPlaceholderExpr reports whether the text inside braces is a variable name, a path
+// into a variable, or a pipeline
+func isPlaceholderExpr(expr string) bool {
+	if strings.Contains(expr, "{") {
+		return false
+	}
+	if _, _, ok := parsePipeline(expr); ok {
+		return true
+	}
+	_, _, ok := splitVariablePath(expr)
+	return ok
+}
+
+// substituteTemplate replaces the placeholders of a template in a single pass: {...}
+// with braceVars, and $name and ${...} with dollarVars. A nil map leaves the placeholders
+// of its syntax as they are, as do undefined variables. Substituted values are not
+// scanned again, so a value holding {name} or $name is used as it is
+func substituteTemplate(s string, braceVars, dollarVars map[string]string) string {
+	if !strings.ContainsAny(s, "{$") {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, token := range tokenizeTemplate(s) {
+		b.WriteString(resolveToken(token, braceVars, dollarVars))
+	}
+	return b.String()
+}
+
+// resolveToken returns the value of a token, or its text if it has none
+func resolveToken(token templateToken, braceVars, dollarVars map[string]string) string {
+	variables := dollarVars
+	switch token.kind {
+	case tokenText:
+		return token.text
+	case tokenBrace:
+		variables = braceVars
+	case tokenDollar:
+		if value, ok := dollarVars[token.expr]; ok {
+			return value
+		}
+		return token.text
+	}
+	if variables == nil {
+		return token.text
+	}
+
+	if name, steps, ok := parsePipeline(token.expr); ok {
+		if value, ok := evaluatePipeline(name, steps, variables); ok {
+			return value
+		}
+		return token.text
+	}
+	if value, defined, err := lookupVariable(token.expr, variables); defined && err == nil {
+		return value
+	}
+	return token.text
+}
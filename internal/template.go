@@ -0,0 +1,141 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// templateFunc transforms a variable value; set reports whether the variable had a value
+type templateFunc func(value string, set bool, arg string) (string, bool)
+
+// TemplateFunctions are the functions available in {name|func:arg} pipelines
+var TemplateFunctions = map[string]templateFunc{
+	"upper": func(value string, set bool, arg string) (string, bool) {
+		return strings.ToUpper(value), set
+	},
+	"lower": func(value string, set bool, arg string) (string, bool) {
+		return strings.ToLower(value), set
+	},
+	"trim": func(value string, set bool, arg string) (string, bool) {
+		if arg != "" {
+			return strings.Trim(value, arg), set
+		}
+		return strings.TrimSpace(value), set
+	},
+	"replace": func(value string, set bool, arg string) (string, bool) {
+		parts := strings.SplitN(arg, ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return value, set
+		}
+		return strings.ReplaceAll(value, parts[0], parts[1]), set
+	},
+	"join": func(value string, set bool, arg string) (string, bool) {
+		items, ok := ListValue(value)
+		if !ok {
+			return value, set
+		}
+		if arg == "" {
+			arg = " "
+		}
+		return strings.Join(items, arg), set
+	},
+	"default": func(value string, set bool, arg string) (string, bool) {
+		if !set || value == "" {
+			return arg, true
+		}
+		return value, set
+	},
+}
+
+// templateStep is a single function call in a pipeline
+type templateStep struct {
+	name string
+	arg  string
+}
+
+// parsePipeline splits "name|func:arg|func" into the variable name and its steps; the
+// name may select into a list or map variable, as in targets[0]|upper
+// ok is false if expr is not a pipeline (no '|' or an invalid variable name)
+func parsePipeline(expr string) (string, []templateStep, bool) {
+	if !strings.Contains(expr, "|") {
+		return "", nil, false
+	}
+
+	parts := strings.Split(expr, "|")
+	name := strings.TrimSpace(parts[0])
+	if _, _, ok := splitVariablePath(name); !ok {
+		return "", nil, false
+	}
+
+	steps := make([]templateStep, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		fn := strings.SplitN(part, ":", 2)
+		step := templateStep{name: strings.TrimSpace(fn[0])}
+		if len(fn) == 2 {
+			step.arg = fn[1]
+		}
+		steps = append(steps, step)
+	}
+	return name, steps, true
+}
+
+// isVariableName reports whether s is a valid variable name (letters, digits, underscore)
+func isVariableName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !((c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// hasDefault reports whether a pipeline provides a fallback for undefined variables
+func hasDefault(steps []templateStep) bool {
+	for _, step := range steps {
+		if step.name == "default" {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluatePipeline applies the pipeline steps to a variable
+// ok is false if a function is unknown or the variable is undefined without a default
+func evaluatePipeline(name string, steps []templateStep, variables map[string]string) (string, bool) {
+	value, set, err := lookupVariable(name, variables)
+	if err != nil {
+		return "", false
+	}
+	for _, step := range steps {
+		fn, exists := TemplateFunctions[step.name]
+		if !exists {
+			return "", false
+		}
+		value, set = fn(value, set, step.arg)
+	}
+	return value, set
+}
+
+// validateTemplatePipelines reports unknown functions used in {name|func} expressions
+func validateTemplatePipelines(s string) error {
+	start := -1
+	for i := 0; i < len(s); i++ {
+		if s[i] == '{' {
+			start = i
+		} else if s[i] == '}' && start != -1 {
+			if _, steps, ok := parsePipeline(s[start+1 : i]); ok {
+				for _, step := range steps {
+					if _, exists := TemplateFunctions[step.name]; !exists {
+						return fmt.Errorf("unknown template function '%s' in %s (available: default, join, lower, replace, trim, upper)", step.name, s[start:i+1])
+					}
+				}
+			}
+			start = -1
+		}
+	}
+	return nil
+}
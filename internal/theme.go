@@ -0,0 +1,225 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Status is the kind of a status message, which selects its symbol and color
+type Status string
+
+// Status message kinds
+const (
+	StatusSuccess Status = "success"
+	StatusError   Status = "error"
+	StatusWarning Status = "warning"
+	StatusHint    Status = "hint"
+	StatusInfo    Status = "info"
+	StatusStart   Status = "start"
+	StatusStop    Status = "stop"
+	StatusSkip    Status = "skip"
+	StatusRetry   Status = "retry"
+	StatusEdit    Status = "edit"
+	StatusRemove  Status = "remove"
+	StatusNone    Status = "none" // Nothing to report, e.g. an optional file that does not exist
+)
+
+// DefaultTheme is the theme used when neither --theme nor the theme setting selects one
+const DefaultTheme = "classic"
+
+// Theme controls how status messages look
+type Theme struct {
+	Name        string
+	Description string
+	Prefix      string            // Written before every status message
+	Symbols     map[Status]string // Written before the message, followed by a space
+	Colors      map[Status]string // ANSI color of the symbol, on terminals only
+	Spinner     []string          // Frames of the live step display (linea run --progress)
+}
+
+// Themes are the built-in themes, selected with --theme or `linea config set theme`
+var Themes = map[string]*Theme{
+	"classic": {
+		Name:        "classic",
+		Description: "emoji symbols (default)",
+		Symbols: map[Status]string{
+			StatusSuccess: "✅",
+			StatusError:   "❌",
+			StatusWarning: "⚠️ ",
+			StatusHint:    "💡",
+			StatusInfo:    "🕒",
+			StatusStart:   "▶️ ",
+			StatusStop:    "🛑",
+			StatusSkip:    "⏭️ ",
+			StatusRetry:   "↻",
+			StatusEdit:    "✏️ ",
+			StatusRemove:  "🗑️ ",
+			StatusNone:    "➖",
+		},
+		Spinner: brailleSpinner,
+	},
+	"minimal": {
+		Name:        "minimal",
+		Description: "plain symbols with color",
+		Symbols: map[Status]string{
+			StatusSuccess: "✓",
+			StatusError:   "✗",
+			StatusWarning: "!",
+			StatusHint:    "›",
+			StatusInfo:    "·",
+			StatusStart:   "›",
+			StatusStop:    "■",
+			StatusSkip:    "-",
+			StatusRetry:   "↻",
+			StatusEdit:    "~",
+			StatusRemove:  "-",
+			StatusNone:    "-",
+		},
+		Colors: map[Status]string{
+			StatusSuccess: ColorGreen,
+			StatusError:   ColorRed,
+			StatusWarning: ColorYellow,
+			StatusHint:    ColorDim,
+			StatusSkip:    ColorDim,
+			StatusNone:    ColorDim,
+		},
+		Spinner: brailleSpinner,
+	},
+	"ci": {
+		Name:        "ci",
+		Description: "ASCII labels for CI logs, no color",
+		Prefix:      "[linea] ",
+		Symbols: map[Status]string{
+			StatusSuccess: "OK",
+			StatusError:   "ERROR",
+			StatusWarning: "WARNING",
+			StatusHint:    "HINT",
+			StatusInfo:    "INFO",
+			StatusStart:   "START",
+			StatusStop:    "STOP",
+			StatusSkip:    "SKIP",
+			StatusRetry:   "RETRY",
+			StatusEdit:    "EDIT",
+			StatusRemove:  "REMOVE",
+			StatusNone:    "NONE",
+		},
+		Spinner: []string{"|", "/", "-", "\\"},
+	},
+}
+
+// brailleSpinner is the spinner of the themes that use Unicode symbols
+var brailleSpinner = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// ThemeNames returns the names of the built-in themes, sorted
+func ThemeNames() []string {
+	names := make([]string, 0, len(Themes))
+	for name := range Themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupTheme returns a built-in theme by name
+func LookupTheme(name string) (*Theme, error) {
+	theme, ok := Themes[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown theme '%s' (expected one of %s)", name, strings.Join(ThemeNames(), ", "))
+	}
+	return theme, nil
+}
+
+// Renderer writes status messages in a theme
+type Renderer struct {
+	Theme *Theme // nil selects the theme setting of the global config, or DefaultTheme
+	Out   io.Writer
+	Err   io.Writer
+	Quiet bool // Drop every message but errors and warnings (--quiet)
+}
+
+// Output is the renderer for all of linea's status messages
+var Output = &Renderer{Out: os.Stdout, Err: os.Stderr}
+
+// SetTheme selects the theme of Output, overriding the theme setting (used for --theme)
+func SetTheme(name string) error {
+	theme, err := LookupTheme(name)
+	if err != nil {
+		return err
+	}
+	Output.Theme = theme
+	return nil
+}
+
+// theme returns the renderer's theme: --theme, then the theme setting, then DefaultTheme
+func (r *Renderer) theme() *Theme {
+	if r.Theme == nil {
+		r.Theme = Themes[DefaultTheme]
+		if theme, err := LookupTheme(CurrentUserConfig().Theme); err == nil {
+			r.Theme = theme
+		}
+	}
+	return r.Theme
+}
+
+// SpinnerFrame returns frame n of the theme's spinner
+func (r *Renderer) SpinnerFrame(n int) string {
+	frames := r.theme().Spinner
+	if len(frames) == 0 {
+		return ""
+	}
+	return frames[n%len(frames)]
+}
+
+// Symbol returns the themed symbol of a status, colored if w is a terminal
+func (r *Renderer) Symbol(w io.Writer, status Status) string {
+	theme := r.theme()
+	symbol := theme.Symbols[status]
+	if code, ok := theme.Colors[status]; ok {
+		if f, isFile := w.(*os.File); isFile && ColorOutput(f) {
+			symbol = Colorize(symbol, code, true)
+		}
+	}
+	return symbol
+}
+
+// Sprintf formats a status message for w: indentation of the format is kept, followed by
+// the theme's prefix and the status symbol
+func (r *Renderer) Sprintf(w io.Writer, status Status, format string, args ...interface{}) string {
+	message := fmt.Sprintf(format, args...)
+
+	// Keep leading blank lines and indentation before the symbol
+	afterNewlines := strings.TrimLeft(message, "\n")
+	newlines := message[:len(message)-len(afterNewlines)]
+	body := strings.TrimLeft(afterNewlines, " ")
+	indent := afterNewlines[:len(afterNewlines)-len(body)]
+
+	// Don't repeat a label the symbol already spells out, as in "ERROR Error: ..."
+	theme := r.theme()
+	if label := theme.Symbols[status] + ": "; len(body) > len(label) && strings.EqualFold(body[:len(label)], label) {
+		body = body[len(label):]
+	}
+
+	return newlines + indent + theme.Prefix + r.Symbol(w, status) + " " + body
+}
+
+// Silenced reports whether a message of status is dropped in quiet mode
+func (r *Renderer) Silenced(status Status) bool {
+	return r.Quiet && status != StatusError && status != StatusWarning
+}
+
+// Printf writes a status message to standard output
+func (r *Renderer) Printf(status Status, format string, args ...interface{}) {
+	if !r.Silenced(status) {
+		fmt.Fprint(r.Out, r.Sprintf(r.Out, status, format, args...))
+	}
+}
+
+// Eprintf writes a status message to standard error
+func (r *Renderer) Eprintf(status Status, format string, args ...interface{}) {
+	if !r.Silenced(status) {
+		fmt.Fprint(r.Err, r.Sprintf(r.Err, status, format, args...))
+	}
+}
@@ -0,0 +1,360 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The standard OpenTelemetry variables read by TracerFromEnv. The TRACES_ variants take
+// precedence over the general ones
+const (
+	OtelSDKDisabledEnv    = "OTEL_SDK_DISABLED"
+	OtelTracesExporterEnv = "OTEL_TRACES_EXPORTER"
+	OtelEndpointEnv       = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	OtelTracesEndpointEnv = "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"
+	OtelHeadersEnv        = "OTEL_EXPORTER_OTLP_HEADERS"
+	OtelTracesHeadersEnv  = "OTEL_EXPORTER_OTLP_TRACES_HEADERS"
+	OtelTimeoutEnv        = "OTEL_EXPORTER_OTLP_TIMEOUT"
+	OtelTracesTimeoutEnv  = "OTEL_EXPORTER_OTLP_TRACES_TIMEOUT"
+	OtelProtocolEnv       = "OTEL_EXPORTER_OTLP_PROTOCOL"
+	OtelTracesProtocolEnv = "OTEL_EXPORTER_OTLP_TRACES_PROTOCOL"
+	OtelServiceNameEnv    = "OTEL_SERVICE_NAME"
+	OtelResourceAttrsEnv  = "OTEL_RESOURCE_ATTRIBUTES"
+	TraceParentEnv        = "TRACEPARENT" // W3C trace context of the process that started linea
+)
+
+const (
+	defaultOtelTimeout     = 10 * time.Second
+	defaultOtelServiceName = "linea"
+	otlpProtocolHTTPJSON   = "http/json"
+	otlpTracesPath         = "/v1/traces"
+	otlpSpanKindInternal   = 1
+	otlpStatusCodeError    = 2
+	traceParentVersion     = "00"
+)
+
+// tracingEnvVars are the tracing variables carried into a scheduler service. The headers
+// are left out, as they usually hold an API key and the unit files are world-readable
+var tracingEnvVars = []string{
+	OtelSDKDisabledEnv, OtelTracesExporterEnv, OtelEndpointEnv, OtelTracesEndpointEnv,
+	OtelProtocolEnv, OtelTracesProtocolEnv, OtelServiceNameEnv, OtelResourceAttrsEnv,
+}
+
+// Tracer exports workflow runs to an OpenTelemetry collector over OTLP/HTTP with JSON
+// encoding. A run is a trace whose root span is the workflow, with a child span for
+// every step. A nil *Tracer traces nothing
+type Tracer struct {
+	Endpoint string            // URL the spans are posted to, e.g. http://localhost:4318/v1/traces
+	Headers  map[string]string // Sent with every export, e.g. an API key
+	Resource map[string]string // Resource attributes, including service.name
+	Timeout  time.Duration     // Time an export may take
+	Parent   string            // W3C traceparent the runs are children of, e.g. from a CI job
+}
+
+// TracerFromEnv returns the tracer configured by the standard OTEL_ variables, or nil when
+// no OTLP endpoint is set, OTEL_TRACES_EXPORTER is none, or OTEL_SDK_DISABLED is true
+// A TRACEPARENT variable makes the runs part of the trace of the process that started linea
+func TracerFromEnv() (*Tracer, error) {
+	if disabled, _ := strconv.ParseBool(os.Getenv(OtelSDKDisabledEnv)); disabled {
+		return nil, nil
+	}
+	switch exporter := os.Getenv(OtelTracesExporterEnv); exporter {
+	case "", "otlp":
+	case "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("%s=%s is not supported (only otlp)", OtelTracesExporterEnv, exporter)
+	}
+
+	endpoint := os.Getenv(OtelTracesEndpointEnv)
+	if endpoint == "" {
+		base := os.Getenv(OtelEndpointEnv)
+		if base == "" {
+			return nil, nil
+		}
+		endpoint = strings.TrimSuffix(base, "/") + otlpTracesPath
+	}
+	if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("OTLP endpoint '%s' is not an http(s) URL", endpoint)
+	}
+	if protocol := otelEnv(OtelTracesProtocolEnv, OtelProtocolEnv); protocol != "" && protocol != otlpProtocolHTTPJSON {
+		return nil, fmt.Errorf("OTLP protocol '%s' is not supported (only %s)", protocol, otlpProtocolHTTPJSON)
+	}
+
+	tracer := &Tracer{Endpoint: endpoint, Timeout: defaultOtelTimeout, Parent: os.Getenv(TraceParentEnv)}
+	var err error
+	if tracer.Headers, err = parseOtelList(otelEnv(OtelTracesHeadersEnv, OtelHeadersEnv)); err != nil {
+		return nil, fmt.Errorf("OTLP headers: %w", err)
+	}
+	if tracer.Resource, err = parseOtelList(os.Getenv(OtelResourceAttrsEnv)); err != nil {
+		return nil, fmt.Errorf("%s: %w", OtelResourceAttrsEnv, err)
+	}
+	if name := os.Getenv(OtelServiceNameEnv); name != "" {
+		tracer.Resource["service.name"] = name
+	} else if tracer.Resource["service.name"] == "" {
+		tracer.Resource["service.name"] = defaultOtelServiceName
+	}
+	if timeout := otelEnv(OtelTracesTimeoutEnv, OtelTimeoutEnv); timeout != "" {
+		ms, err := strconv.Atoi(timeout)
+		if err != nil || ms <= 0 {
+			return nil, fmt.Errorf("OTLP timeout '%s' is not a number of milliseconds", timeout)
+		}
+		tracer.Timeout = time.Duration(ms) * time.Millisecond
+	}
+	return tracer, nil
+}
+
+// otelEnv returns the first of the variables that is set
+func otelEnv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// parseOtelList parses the key1=value1,key2=value2 lists of the OTEL_ variables, whose
+// values are URL-encoded
+func parseOtelList(list string) (map[string]string, error) {
+	values := map[string]string{}
+	for _, item := range strings.Split(list, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(item, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("'%s' is not key=value", strings.TrimSpace(item))
+		}
+		decoded, err := url.PathUnescape(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("value of '%s': %w", key, err)
+		}
+		values[key] = decoded
+	}
+	return values, nil
+}
+
+// RunTrace collects the spans of one workflow run. A nil *RunTrace collects nothing
+type RunTrace struct {
+	tracer   *Tracer
+	traceID  string
+	spanID   string // Of the root span
+	parentID string // Span of the root's parent, from the traceparent
+	sampled  bool
+
+	mu    sync.Mutex
+	spans []otlpSpan
+}
+
+// StartRun starts the trace of a run. A traceparent whose sampled flag is unset is
+// followed, like the parent-based sampling of the OpenTelemetry SDKs: the run is not exported
+func (t *Tracer) StartRun() *RunTrace {
+	if t == nil {
+		return nil
+	}
+	trace := &RunTrace{tracer: t, traceID: randomHex(16), spanID: randomHex(8), sampled: true}
+	if traceID, parentID, flags, ok := parseTraceParent(t.Parent); ok {
+		trace.traceID, trace.parentID = traceID, parentID
+		trace.sampled = flags&1 == 1
+	}
+	return trace
+}
+
+// parseTraceParent splits a W3C traceparent, 00-<trace-id>-<parent-id>-<flags>
+func parseTraceParent(value string) (traceID, parentID string, flags byte, ok bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) != 4 || parts[0] != traceParentVersion || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return "", "", 0, false
+	}
+	for _, part := range parts[1:3] {
+		if b, err := hex.DecodeString(part); err != nil || bytes.Count(b, []byte{0}) == len(b) || strings.ToLower(part) != part {
+			return "", "", 0, false
+		}
+	}
+	f, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return "", "", 0, false
+	}
+	return parts[1], parts[2], f[0], true
+}
+
+// Middleware returns the middleware that records a span for every step of the run
+func (r *RunTrace) Middleware() Middleware {
+	if r == nil {
+		return Middleware{}
+	}
+	return Middleware{
+		AfterStep: func(step StepInfo, result StepResult) {
+			end := time.Now()
+			name := result.Name
+			if name == "" {
+				name = fmt.Sprintf("step %d", result.Index)
+			}
+			span := otlpSpan{
+				TraceID:      r.traceID,
+				SpanID:       randomHex(8),
+				ParentSpanID: r.spanID,
+				Name:         name,
+				Kind:         otlpSpanKindInternal,
+				Start:        otlpTime(end.Add(-time.Duration(result.DurationMs) * time.Millisecond)),
+				End:          otlpTime(end),
+				Attributes: otlpAttributes(
+					"linea.step.index", result.Index,
+					"linea.step.name", result.Name,
+					"linea.step.group", result.Group,
+					"linea.step.command", strings.Join(result.Command, " "),
+					"linea.step.status", result.Status,
+					"linea.step.exit_code", result.ExitCode,
+					"linea.step.duration_ms", result.DurationMs,
+				),
+			}
+			if result.Status == StepFailed {
+				span.Status = &otlpStatus{Code: otlpStatusCodeError, Message: result.Error}
+			}
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.spans = append(r.spans, span)
+		},
+	}
+}
+
+// End records the root span of the run from its report and exports the trace
+func (r *RunTrace) End(report *RunReport) error {
+	if r == nil || !r.sampled {
+		return nil
+	}
+	root := otlpSpan{
+		TraceID:      r.traceID,
+		SpanID:       r.spanID,
+		ParentSpanID: r.parentID,
+		Name:         report.Workflow,
+		Kind:         otlpSpanKindInternal,
+		Start:        otlpTime(report.Start),
+		End:          otlpTime(report.Start.Add(time.Duration(report.DurationMs) * time.Millisecond)),
+		Attributes: otlpAttributes(
+			"linea.workflow", report.Workflow,
+			"linea.workflow.path", report.Path,
+			"linea.run.id", report.RunID,
+			"linea.run.status", report.Status,
+			"linea.run.exit_code", report.ExitCode,
+			"linea.run.duration_ms", report.DurationMs,
+			"linea.run.steps", len(report.Steps),
+		),
+	}
+	if report.Status != StepSucceeded {
+		root.Status = &otlpStatus{Code: otlpStatusCodeError, Message: report.Error}
+	}
+
+	r.mu.Lock()
+	spans := append([]otlpSpan{root}, r.spans...)
+	r.mu.Unlock()
+	return r.tracer.export(spans)
+}
+
+// export posts spans to the collector in an OTLP ExportTraceServiceRequest
+func (t *Tracer) export(spans []otlpSpan) error {
+	var resource []otlpAttribute
+	for _, key := range sortedMapKeys(t.Resource) {
+		resource = append(resource, otlpAttributes(key, t.Resource[key])...)
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{"attributes": resource},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]string{"name": "linea"},
+				"spans": spans,
+			}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", t.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range t.Headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := (&http.Client{Timeout: t.Timeout}).Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to export the trace: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("failed to export the trace: %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// otlpSpan is a span in the OTLP JSON encoding, where IDs are hex and times are
+// nanoseconds since the Unix epoch, as strings
+type otlpSpan struct {
+	TraceID      string          `json:"traceId"`
+	SpanID       string          `json:"spanId"`
+	ParentSpanID string          `json:"parentSpanId,omitempty"`
+	Name         string          `json:"name"`
+	Kind         int             `json:"kind"`
+	Start        string          `json:"startTimeUnixNano"`
+	End          string          `json:"endTimeUnixNano"`
+	Attributes   []otlpAttribute `json:"attributes,omitempty"`
+	Status       *otlpStatus     `json:"status,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+// otlpAttributes converts key and value pairs to OTLP attributes, leaving out empty
+// strings. Integers are strings in the JSON encoding
+func otlpAttributes(pairs ...interface{}) []otlpAttribute {
+	var attributes []otlpAttribute
+	for i := 0; i+1 < len(pairs); i += 2 {
+		key := pairs[i].(string)
+		switch value := pairs[i+1].(type) {
+		case string:
+			if value != "" {
+				attributes = append(attributes, otlpAttribute{key, map[string]interface{}{"stringValue": value}})
+			}
+		case int:
+			attributes = append(attributes, otlpAttribute{key, map[string]interface{}{"intValue": strconv.Itoa(value)}})
+		case int64:
+			attributes = append(attributes, otlpAttribute{key, map[string]interface{}{"intValue": strconv.FormatInt(value, 10)}})
+		}
+	}
+	return attributes
+}
+
+// otlpTime formats a time as nanoseconds since the Unix epoch
+func otlpTime(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// randomHex returns n random bytes, hex-encoded
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
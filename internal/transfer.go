@@ -0,0 +1,396 @@
+package internal
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Types of the steps that download and upload files
+const (
+	StepTypeDownload = "download"
+	StepTypeUpload   = "upload"
+)
+
+// DownloadSpec configures a `type: download` step, which fetches url to dest, resuming
+// an interrupted download and checking the file's SHA-256 checksum
+type DownloadSpec struct {
+	URL     string            `yaml:"url,omitempty"`
+	Dest    string            `yaml:"dest,omitempty"`
+	SHA256  string            `yaml:"sha256,omitempty"` // Expected checksum, in hex
+	Headers map[string]string `yaml:"headers,omitempty"`
+}
+
+// UploadSpec configures a `type: upload` step, which sends the file src with an HTTP PUT
+// to url, or to an S3-compatible bucket
+type UploadSpec struct {
+	Src       string            `yaml:"src,omitempty"`
+	URL       string            `yaml:"url,omitempty"`
+	Headers   map[string]string `yaml:"headers,omitempty"`
+	S3        string            `yaml:"s3,omitempty"`         // s3://bucket/key, or s3://bucket/prefix/ to keep the file name
+	Endpoint  string            `yaml:"endpoint,omitempty"`   // S3-compatible endpoint (defaults to AWS)
+	Region    string            `yaml:"region,omitempty"`     // Defaults to AWS_REGION, then us-east-1
+	AccessKey string            `yaml:"access_key,omitempty"` // Defaults to AWS_ACCESS_KEY_ID
+	SecretKey string            `yaml:"secret_key,omitempty"` // Defaults to AWS_SECRET_ACCESS_KEY
+}
+
+// CheckTransferStep validates the download: or upload: of a step
+func CheckTransferStep(config *CommandConfig) error {
+	switch {
+	case config.Download != nil && config.Type != StepTypeDownload:
+		return fmt.Errorf("download: requires type: download")
+	case config.Upload != nil && config.Type != StepTypeUpload:
+		return fmt.Errorf("upload: requires type: upload")
+	case config.Type == StepTypeDownload:
+		d := config.Download
+		if d == nil || d.URL == "" || d.Dest == "" {
+			return fmt.Errorf("type: download needs a download: with url and dest")
+		}
+		if d.SHA256 != "" && !strings.ContainsAny(d.SHA256, "{$") {
+			if _, err := hex.DecodeString(d.SHA256); err != nil || len(d.SHA256) != 2*sha256.Size {
+				return fmt.Errorf("download: invalid sha256 '%s' (expected 64 hex digits)", d.SHA256)
+			}
+		}
+	case config.Type == StepTypeUpload:
+		u := config.Upload
+		switch {
+		case u == nil || u.Src == "" || (u.URL == "") == (u.S3 == ""):
+			return fmt.Errorf("type: upload needs an upload: with src, and url or s3")
+		case u.S3 != "" && !strings.HasPrefix(u.S3, "s3://"):
+			return fmt.Errorf("upload: invalid s3 location '%s' (expected s3://bucket/key)", u.S3)
+		case u.S3 != "" && len(u.Headers) > 0:
+			return fmt.Errorf("upload: headers only apply to url uploads")
+		case u.S3 == "" && (u.Endpoint != "" || u.Region != "" || u.AccessKey != "" || u.SecretKey != ""):
+			return fmt.Errorf("upload: endpoint, region, access_key, and secret_key only apply to s3 uploads")
+		}
+	}
+	return nil
+}
+
+// headerArgs returns headers as --header "Name: value" arguments, sorted by name
+func headerArgs(headers map[string]string) []string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var args []string
+	for _, name := range names {
+		args = append(args, "--header", name+": "+headers[name])
+	}
+	return args
+}
+
+// downloadArgs turns the download: of a step into the arguments of runDownload
+func downloadArgs(config *CommandConfig) ([]string, error) {
+	if err := CheckTransferStep(config); err != nil {
+		return nil, err
+	}
+	d := config.Download
+	args := []string{"--url", d.URL, "--dest", d.Dest}
+	if d.SHA256 != "" {
+		args = append(args, "--sha256", d.SHA256)
+	}
+	return append(args, headerArgs(d.Headers)...), nil
+}
+
+// uploadArgs turns the upload: of a step into the arguments of runUpload
+func uploadArgs(config *CommandConfig) ([]string, error) {
+	if err := CheckTransferStep(config); err != nil {
+		return nil, err
+	}
+	u := config.Upload
+	args := []string{"--src", u.Src}
+	if u.URL != "" {
+		return append(append(args, "--url", u.URL), headerArgs(u.Headers)...), nil
+	}
+	args = append(args, "--s3", u.S3)
+	for _, option := range [][2]string{{"--endpoint", u.Endpoint}, {"--region", u.Region}, {"--access-key", u.AccessKey}, {"--secret-key", u.SecretKey}} {
+		if option[1] != "" {
+			args = append(args, option[0], option[1])
+		}
+	}
+	return args, nil
+}
+
+// transferContext returns a context cancelled when the run is
+func transferContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := interrupted()
+	go func() {
+		select {
+		case <-cancelled:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// setHeaders sets "Name: value" headers on a request
+func setHeaders(req *http.Request, headers []string) error {
+	for _, header := range headers {
+		name, value, ok := strings.Cut(header, ":")
+		if !ok {
+			return fmt.Errorf("invalid header '%s'", header)
+		}
+		req.Header.Set(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	return nil
+}
+
+// redactURL returns a URL without its credentials and query, for messages
+func redactURL(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		u.User = nil
+		u.RawQuery = ""
+		return u.String()
+	}
+	return MaskSecrets(rawURL)
+}
+
+// FileSHA256 returns the hex-encoded SHA-256 checksum of a file
+func FileSHA256(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// runDownload downloads a URL to a file through <dest>.part, which is resumed with a
+// range request if an earlier download was interrupted. The part is only resumed from the
+// same file: <dest>.part.if-range keeps the ETag or Last-Modified of the response it
+// came from, sent as If-Range. With --sha256, a dest that already has the checksum is
+// kept, and a download without it fails the step
+func runDownload(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet(StepTypeDownload, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	rawURL := flags.String("url", "", "")
+	dest := flags.String("dest", "", "")
+	checksum := flags.String("sha256", "", "")
+	var headers listFlag
+	flags.Var(&headers, "header", "")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("download: %w", err)
+	}
+	want := strings.ToLower(*checksum)
+	target := redactURL(*rawURL)
+
+	if want != "" {
+		if sum, err := FileSHA256(*dest); err == nil && sum == want {
+			fmt.Fprintf(stdout, "download: %s is up to date (sha256 %s)\n", *dest, want)
+			return nil
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(*dest), 0755); err != nil {
+		return fileStepError(StepTypeDownload, err)
+	}
+
+	part := *dest + ".part"
+	validator := part + ".if-range"
+	var offset int64
+	ifRange, _ := os.ReadFile(validator)
+	if info, err := os.Stat(part); err == nil && len(ifRange) > 0 {
+		offset = info.Size()
+	}
+	ctx, cancel := transferContext()
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, *rawURL, nil)
+	if err != nil {
+		return fileStepError(StepTypeDownload, err)
+	}
+	if err := setHeaders(req, headers); err != nil {
+		return fileStepError(StepTypeDownload, err)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		req.Header.Set("If-Range", string(ifRange))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fileStepError(StepTypeDownload, fmt.Errorf("%s: %v", target, MaskSecrets(err.Error())))
+	}
+	defer resp.Body.Close()
+
+	mode := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0 && contentRangeStart(resp) == offset:
+		mode = os.O_WRONLY | os.O_APPEND
+		fmt.Fprintf(stdout, "download: resuming %s at %d bytes\n", target, offset)
+	case (resp.StatusCode == http.StatusPartialContent || resp.StatusCode == http.StatusRequestedRangeNotSatisfiable) && offset > 0:
+		// Another range than the one asked for, or the part is complete already, or longer
+		// than the file now is: start over
+		resp.Body.Close()
+		os.Remove(part)
+		os.Remove(validator)
+		return runDownload(args, stdout, stderr)
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return fileStepError(StepTypeDownload, fmt.Errorf("GET %s returned %s", target, resp.Status))
+	default:
+		offset = 0
+		if value := rangeValidator(resp); value != "" {
+			os.WriteFile(validator, []byte(value), 0644)
+		} else {
+			os.Remove(validator)
+		}
+	}
+	out, err := os.OpenFile(part, mode, 0644)
+	if err != nil {
+		return fileStepError(StepTypeDownload, err)
+	}
+	written, err := io.Copy(out, resp.Body)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		// The part is kept, so that running the step again resumes the download
+		return fileStepError(StepTypeDownload, fmt.Errorf("%s: %v after %d bytes", target, err, offset+written))
+	}
+
+	sum, err := FileSHA256(part)
+	if err != nil {
+		return fileStepError(StepTypeDownload, err)
+	}
+	if want != "" && sum != want {
+		os.Remove(part)
+		os.Remove(validator)
+		return fileStepError(StepTypeDownload, fmt.Errorf("checksum mismatch for %s: expected sha256 %s, got %s", target, want, sum))
+	}
+	if err := os.Rename(part, *dest); err != nil {
+		return fileStepError(StepTypeDownload, err)
+	}
+	os.Remove(validator)
+	verified := ""
+	if want != "" {
+		verified = ", verified"
+	}
+	fmt.Fprintf(stdout, "download: %s -> %s (%d bytes, sha256 %s%s)\n", target, *dest, offset+written, sum, verified)
+	return nil
+}
+
+// contentRangeStart returns the first byte of the Content-Range of a partial response, or
+// -1 if it has none
+func contentRangeStart(resp *http.Response) int64 {
+	first, _, ok := strings.Cut(strings.TrimPrefix(resp.Header.Get("Content-Range"), "bytes "), "-")
+	start, err := strconv.ParseInt(first, 10, 64)
+	if !ok || err != nil {
+		return -1
+	}
+	return start
+}
+
+// rangeValidator returns the If-Range that resumes a download of the file of resp: its
+// ETag, unless it is weak, which If-Range does not accept, or else its Last-Modified
+func rangeValidator(resp *http.Response) string {
+	if etag := resp.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return resp.Header.Get("Last-Modified")
+}
+
+// runUpload uploads a file with an HTTP PUT, or to S3 signed with its checksum
+func runUpload(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet(StepTypeUpload, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	src := flags.String("src", "", "")
+	rawURL := flags.String("url", "", "")
+	s3 := flags.String("s3", "", "")
+	endpoint := flags.String("endpoint", "", "")
+	region := flags.String("region", "", "")
+	accessKey := flags.String("access-key", "", "")
+	secretKey := flags.String("secret-key", "", "")
+	var headers listFlag
+	flags.Var(&headers, "header", "")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("upload: %w", err)
+	}
+
+	info, err := os.Stat(*src)
+	if err != nil {
+		return fileStepError(StepTypeUpload, err)
+	}
+	if info.IsDir() {
+		return fileStepError(StepTypeUpload, fmt.Errorf("%s is a directory; pack it with an archive step first", *src))
+	}
+	sum, err := FileSHA256(*src)
+	if err != nil {
+		return fileStepError(StepTypeUpload, err)
+	}
+	file, err := os.Open(*src)
+	if err != nil {
+		return fileStepError(StepTypeUpload, err)
+	}
+	defer file.Close()
+
+	ctx, cancel := transferContext()
+	defer cancel()
+	target := redactURL(*rawURL)
+	var req *http.Request
+	if *s3 != "" {
+		bucket, key, _ := strings.Cut(strings.TrimPrefix(*s3, "s3://"), "/")
+		if key == "" || strings.HasSuffix(key, "/") {
+			key += filepath.Base(*src)
+		}
+		credentials := S3Credentials{
+			AccessKey:    envDefault(*accessKey, "AWS_ACCESS_KEY_ID"),
+			SecretKey:    envDefault(*secretKey, "AWS_SECRET_ACCESS_KEY"),
+			SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+			Region:       envDefault(*region, "AWS_REGION"),
+		}
+		if credentials.Region == "" {
+			credentials.Region = "us-east-1"
+		}
+		if credentials.AccessKey == "" || credentials.SecretKey == "" {
+			return fileStepError(StepTypeUpload, fmt.Errorf("no S3 credentials (set access_key/secret_key or AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY)"))
+		}
+		if req, err = http.NewRequestWithContext(ctx, http.MethodPut, S3ObjectURL(*endpoint, credentials.Region, bucket, key), file); err != nil {
+			return fileStepError(StepTypeUpload, err)
+		}
+		target = "s3://" + bucket + "/" + path.Clean("/" + key)[1:]
+		signS3Request(req, sum, credentials, time.Now())
+	} else {
+		if req, err = http.NewRequestWithContext(ctx, http.MethodPut, *rawURL, file); err != nil {
+			return fileStepError(StepTypeUpload, err)
+		}
+		if err := setHeaders(req, headers); err != nil {
+			return fileStepError(StepTypeUpload, err)
+		}
+	}
+	req.ContentLength = info.Size()
+	if req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fileStepError(StepTypeUpload, fmt.Errorf("%s: %v", target, MaskSecrets(err.Error())))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		if message := strings.TrimSpace(string(body)); message != "" {
+			return fileStepError(StepTypeUpload, fmt.Errorf("PUT %s returned %s: %s", target, resp.Status, message))
+		}
+		return fileStepError(StepTypeUpload, fmt.Errorf("PUT %s returned %s", target, resp.Status))
+	}
+	fmt.Fprintf(stdout, "upload: %s -> %s (%d bytes, sha256 %s)\n", *src, target, info.Size(), sum)
+	return nil
+}
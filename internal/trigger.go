@@ -0,0 +1,264 @@
+package internal
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Payload formats of a trigger
+const (
+	TriggerJSON   = "json"
+	TriggerGitHub = "github"
+)
+
+// maxTriggerPayload is the largest webhook body a trigger accepts, that of GitHub
+const maxTriggerPayload = 25 << 20
+
+// triggerNamePattern is what a trigger name, the last part of its URL, may contain
+var triggerNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// Trigger maps the webhooks POSTed to /hooks/<name> of linea serve to runs of a workflow,
+// with variables taken from the JSON payload. Triggers are the `triggers:` of the global
+// config file
+type Trigger struct {
+	Name      string            `yaml:"name"`
+	Workflow  string            `yaml:"workflow"`
+	Type      string            `yaml:"type,omitempty"`      // json (default) or github
+	Secret    string            `yaml:"secret,omitempty"`    // Expands environment variables, like $HOOK_SECRET
+	Events    []string          `yaml:"events,omitempty"`    // github: X-GitHub-Event values run, default push
+	Match     map[string]string `yaml:"match,omitempty"`     // JSONPath: value the payload must have
+	Variables map[string]string `yaml:"variables,omitempty"` // Variable: JSONPath of its value
+}
+
+// CheckTriggers returns an error for the first trigger that cannot be served
+func CheckTriggers(triggers []Trigger) error {
+	seen := make(map[string]bool)
+	for _, trigger := range triggers {
+		if !triggerNamePattern.MatchString(trigger.Name) {
+			return fmt.Errorf("trigger '%s': name must be letters, digits, '.', '_', or '-'", trigger.Name)
+		}
+		if seen[trigger.Name] {
+			return fmt.Errorf("trigger '%s' is defined more than once", trigger.Name)
+		}
+		seen[trigger.Name] = true
+		if trigger.Workflow == "" {
+			return fmt.Errorf("trigger '%s': no workflow", trigger.Name)
+		}
+		if trigger.Type != "" && trigger.Type != TriggerJSON && trigger.Type != TriggerGitHub {
+			return fmt.Errorf("trigger '%s': unknown type '%s' (expected json or github)", trigger.Name, trigger.Type)
+		}
+		// An unset variable would otherwise leave the trigger open to anyone
+		if trigger.Secret != "" && os.ExpandEnv(trigger.Secret) == "" {
+			return fmt.Errorf("trigger '%s': secret %s is empty", trigger.Name, trigger.Secret)
+		}
+		var paths []string
+		for path := range trigger.Match {
+			paths = append(paths, path)
+		}
+		for _, path := range trigger.Variables {
+			paths = append(paths, path)
+		}
+		for _, path := range paths {
+			if _, err := parseJSONPath(path); err != nil {
+				return fmt.Errorf("trigger '%s': %w", trigger.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// handleHook serves POST /hooks/<name>: it checks the webhook against its trigger and
+// starts a run of the trigger's workflow. Webhooks are authenticated by the trigger's
+// secret rather than the server's token, which senders such as GitHub cannot send, and
+// refused when they come from a browser
+func (s *Server) handleHook(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/hooks/")
+	var trigger *Trigger
+	for i := range s.Triggers {
+		if s.Triggers[i].Name == name {
+			trigger = &s.Triggers[i]
+		}
+	}
+	if trigger == nil {
+		writeAPIError(w, http.StatusNotFound, fmt.Sprintf("trigger %s not found", name))
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeAPIError(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+	// Webhook senders send no Origin; a browser does, for a page that could otherwise start
+	// runs of a trigger without a secret
+	if r.Header.Get("Origin") != "" {
+		writeAPIError(w, http.StatusForbidden, "cross-origin requests are not allowed")
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxTriggerPayload+1))
+	if err != nil || len(body) > maxTriggerPayload {
+		writeAPIError(w, http.StatusRequestEntityTooLarge, "payload too large")
+		return
+	}
+	if !trigger.authorized(r, body) {
+		writeAPIError(w, http.StatusUnauthorized, "invalid signature or secret")
+		return
+	}
+
+	if trigger.Type == TriggerGitHub {
+		event := r.Header.Get("X-GitHub-Event")
+		if event == "ping" {
+			writeAPIJSON(w, http.StatusOK, map[string]string{"status": "pong"})
+			return
+		}
+		events := trigger.Events
+		if len(events) == 0 {
+			events = []string{"push"}
+		}
+		if !containsString(events, event) {
+			writeAPIJSON(w, http.StatusOK, map[string]string{"status": "ignored", "reason": fmt.Sprintf("event %s is not one of %s", event, strings.Join(events, ", "))})
+			return
+		}
+		// GitHub sends payload=<json> when the webhook's content type is form-encoded
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+			if form, err := url.ParseQuery(string(body)); err == nil {
+				body = []byte(form.Get("payload"))
+			}
+		}
+	}
+
+	var payload interface{}
+	decoder := json.NewDecoder(strings.NewReader(string(body)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&payload); err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("payload is not JSON: %v", err))
+		return
+	}
+
+	for _, path := range sortedMapKeys(trigger.Match) {
+		value, err := evalJSONPath(payload, path)
+		if want := trigger.Match[path]; err != nil || value != want {
+			writeAPIJSON(w, http.StatusOK, map[string]string{"status": "ignored", "reason": fmt.Sprintf("%s is not %s", path, want)})
+			return
+		}
+	}
+	vars := make(map[string]string, len(trigger.Variables))
+	for _, variable := range sortedMapKeys(trigger.Variables) {
+		value, err := evalJSONPath(payload, trigger.Variables[variable])
+		if err != nil {
+			writeAPIError(w, http.StatusUnprocessableEntity, fmt.Sprintf("variable %s: %v", variable, err))
+			return
+		}
+		vars[variable] = value
+	}
+
+	run, err := s.StartRun(trigger.Workflow, vars)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.logf(StatusInfo, "%s: triggered by %s", run.ID, trigger.Name)
+	w.Header().Set("Location", "/api/runs/"+run.ID)
+	writeAPIJSON(w, http.StatusAccepted, run)
+}
+
+// authorized checks a webhook against the trigger's secret: the HMAC signature of GitHub's
+// X-Hub-Signature-256 for github triggers, Authorization: Bearer <secret> for the others
+func (t *Trigger) authorized(r *http.Request, body []byte) bool {
+	secret := os.ExpandEnv(t.Secret)
+	if secret == "" {
+		return true
+	}
+	if t.Type == TriggerGitHub {
+		signature, err := hex.DecodeString(strings.TrimPrefix(r.Header.Get("X-Hub-Signature-256"), "sha256="))
+		if err != nil {
+			return false
+		}
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		return hmac.Equal(signature, mac.Sum(nil))
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1
+}
+
+// parseJSONPath splits a JSONPath such as $.commits[0].author['name'] into its keys and
+// indexes. Only this subset of JSONPath is supported: no wildcards, slices, or filters
+func parseJSONPath(path string) ([]string, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("invalid JSONPath '%s': must start with $", path)
+	}
+	var parts []string
+	rest := path[1:]
+	for rest != "" {
+		switch {
+		case rest[0] == '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			key := rest[1 : end+1]
+			if key == "" || key == "*" {
+				return nil, fmt.Errorf("invalid JSONPath '%s': expected a key after '.'", path)
+			}
+			parts, rest = append(parts, key), rest[end+1:]
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid JSONPath '%s': missing ']'", path)
+			}
+			part := rest[1:end]
+			if len(part) >= 2 && (part[0] == '\'' || part[0] == '"') && part[len(part)-1] == part[0] {
+				part = part[1 : len(part)-1]
+			} else if _, err := strconv.Atoi(part); err != nil {
+				return nil, fmt.Errorf("invalid JSONPath '%s': [%s] is not an index or a quoted key", path, part)
+			}
+			parts, rest = append(parts, part), rest[end+1:]
+		default:
+			return nil, fmt.Errorf("invalid JSONPath '%s': unexpected '%c'", path, rest[0])
+		}
+	}
+	return parts, nil
+}
+
+// evalJSONPath returns the value at a JSONPath of a decoded JSON document, as jsonField
+// formats it. A negative index counts from the end of an array
+func evalJSONPath(document interface{}, path string) (string, error) {
+	parts, err := parseJSONPath(path)
+	if err != nil {
+		return "", err
+	}
+	value := document
+	for _, part := range parts {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			next, ok := v[part]
+			if !ok {
+				return "", fmt.Errorf("%s: key '%s' not found", path, part)
+			}
+			value = next
+		case []interface{}:
+			i, err := strconv.Atoi(part)
+			if i < 0 {
+				i += len(v)
+			}
+			if err != nil || i < 0 || i >= len(v) {
+				return "", fmt.Errorf("%s: invalid index '%s'", path, part)
+			}
+			value = v[i]
+		default:
+			return "", fmt.Errorf("%s: cannot index into a %T with '%s'", path, value, part)
+		}
+	}
+	return jsonString(value)
+}
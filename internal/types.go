@@ -1,10 +1,307 @@
 package internal
 
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
 // CommandConfig represents the structure of a YAML command file
 type CommandConfig struct {
-	Command    string            `yaml:"command"`
-	Subcommand string            `yaml:"subcommand,omitempty"`
-	Args       []string          `yaml:"args,omitempty"`
-	Variables  map[string]string `yaml:"variables,omitempty"`
+	Version     int                  `yaml:"version,omitempty"` // Format version, see CurrentWorkflowVersion
+	Name        string               `yaml:"name,omitempty"`
+	Description string               `yaml:"description,omitempty"`
+	Command     string               `yaml:"command"`
+	Type        string               `yaml:"type,omitempty"` // Built-in step run instead of command, e.g. healthcheck
+	Subcommand  string               `yaml:"subcommand,omitempty"`
+	Args        []string             `yaml:"args,omitempty"`
+	Variables   map[string]string    `yaml:"variables,omitempty"`
+	Secrets     map[string]SecretRef `yaml:"secrets,omitempty"`
+	Capture     bool                 `yaml:"capture,omitempty"` // Store the step's stdout and stderr while streaming them
+	When        string               `yaml:"when,omitempty"`    // Condition the step only runs if, e.g. "{exit_code} == 1"
+	Confirm     string               `yaml:"confirm,omitempty"` // Question answered with y before the step runs (or --yes)
+
+	// VariableSyntax selects the placeholders that must name a defined variable:
+	// VariableSyntaxBraces (the default) or VariableSyntaxBare
+	VariableSyntax string `yaml:"variable_syntax,omitempty"`
+
+	// Stdin is fed to the command: inline text, a file, or the output of an earlier step
+	Stdin *StdinSpec `yaml:"stdin,omitempty"`
+
+	// Foreach runs the step once per item of a list, with {item} and {index} set
+	Foreach *ForeachSpec `yaml:"foreach,omitempty"`
+
+	// Cache skips the step while its command line, key, and input files are unchanged
+	// since it last succeeded
+	Cache *StepCacheSpec `yaml:"cache,omitempty"`
+
+	// HealthCheck configures a `type: healthcheck` step
+	HealthCheck *HealthCheckSpec `yaml:"healthcheck,omitempty"`
+
+	// The files of a `type: copy`, move, template, mkdir, or rm step
+	Copy     *FileSpec `yaml:"copy,omitempty"`
+	Move     *FileSpec `yaml:"move,omitempty"`
+	Template *FileSpec `yaml:"template,omitempty"`
+	Mkdir    *FileSpec `yaml:"mkdir,omitempty"`
+	Rm       *FileSpec `yaml:"rm,omitempty"`
+
+	// The files of a `type: archive` or extract step
+	Archive *ArchiveSpec `yaml:"archive,omitempty"`
+	Extract *ArchiveSpec `yaml:"extract,omitempty"`
+
+	// Download configures a `type: download` step, and Upload a `type: upload` one
+	Download *DownloadSpec `yaml:"download,omitempty"`
+	Upload   *UploadSpec   `yaml:"upload,omitempty"`
+
+	// Git configures a `type: git` step
+	Git *GitSpec `yaml:"git,omitempty"`
+
+	// Notify configures a `type: notify` step
+	Notify *NotificationTarget `yaml:"notify,omitempty"`
+
+	// Assert configures a `type: assert` step
+	Assert *AssertSpec `yaml:"assert,omitempty"`
+
+	// AllowedExitCodes are the exit codes that count as success, e.g. [0, 1] for grep (default [0])
+	AllowedExitCodes []int `yaml:"allowed_exit_codes,omitempty"`
+
+	// Interactive attaches the command to the terminal, for programs like ssh -t, docker
+	// exec -it, and editors; its output is not captured or logged
+	Interactive bool `yaml:"interactive,omitempty"`
+
+	// Background starts the command as a job that keeps running while the later steps run
+	// and after the run, until `linea stop`; its output goes to a log in .linea/run
+	Background bool `yaml:"background,omitempty"`
+
+	// After marks a cleanup step that runs even when an earlier step failed or the run was
+	// cancelled; OnFailure one that runs only then
+	After     bool `yaml:"after,omitempty"`
+	OnFailure bool `yaml:"on_failure,omitempty"`
+
+	// Shell is the Windows shell that runs the command if it is not found on PATH, such as
+	// a built-in like dir: cmd, powershell, or pwsh (default --shell or the shell setting)
+	Shell string `yaml:"shell,omitempty"`
+
+	// Limits caps the memory, CPU time, priority, and output of the command
+	Limits *ResourceLimits `yaml:"limits,omitempty"`
+
+	// Runner runs the command on another host over SSH, or in a container
+	Runner *RunnerConfig `yaml:"runner,omitempty"`
+
+	// EnvPassthrough lists the host environment variables the command receives, as glob
+	// patterns (e.g. [HOME, PATH, AWS_*]); nil passes the whole environment, [] none of it
+	EnvPassthrough []string `yaml:"env_passthrough,omitempty"`
+
+	// A group: document runs its steps: in a private variable scope, seeded from inputs:
+	// (evaluated outside the group) and variables:; only the captured output of the steps
+	// in exports: is visible to the steps after the group
+	Group   string            `yaml:"group,omitempty"`
+	Inputs  map[string]string `yaml:"inputs,omitempty"`
+	Steps   []*CommandConfig  `yaml:"steps,omitempty"`
+	Exports []string          `yaml:"exports,omitempty"`
+
+	// Exported is set on the expanded steps of a group that the group exports
+	Exported bool `yaml:"-"`
+
+	// A workflow: step runs the steps of another workflow file in place, as if it was run
+	// with the set: values (evaluated in the scope of the step) as its -s/--set values
+	Workflow string            `yaml:"workflow,omitempty"`
+	Set      map[string]string `yaml:"set,omitempty"`
+
+	// Call is set on the steps of a called workflow, and names the workflow: step they run for
+	Call *WorkflowCall `yaml:"-"`
+
+	// A use: step runs the steps of a template as a group, with the with: values (evaluated
+	// in the scope of the step) as its parameters
+	Use  string            `yaml:"use,omitempty"`
+	With map[string]string `yaml:"with,omitempty"`
+
+	// UsedBy is set on the steps of a template, and is the use: step they run for
+	UsedBy *CommandConfig `yaml:"-"`
+
+	// Providers holds the variables declared as `name: {provider: ...}`, resolved at run time
+	Providers map[string]ProviderSpec `yaml:"-"`
+
+	// VariableTypes holds the type: (VariableTypePath or VariableTypeRaw) of the variables
+	// declared as `name: {value: ..., type: ...}` or with a provider
+	VariableTypes map[string]string `yaml:"-"`
+
+	// NormalizePaths false leaves arguments that look like paths as they are written; unset,
+	// the normalize_paths of the metadata document applies
+	NormalizePaths *bool `yaml:"normalize_paths,omitempty"`
+
+	// Maintenance window: the step only runs inside it unless --force is given
+	AllowedHours string     `yaml:"allowed_hours,omitempty"` // e.g. "09:00-17:00" or "22:00-02:00,12:00-13:00"
+	AllowedDays  StringList `yaml:"allowed_days,omitempty"`  // e.g. [mon-fri] or "sat,sun"
+	Timezone     string     `yaml:"timezone,omitempty"`      // IANA name, defaults to the local timezone
+
+	// Schedule is a cron expression used by `linea schedule`, evaluated in Timezone
+	Schedule string `yaml:"schedule,omitempty"` // e.g. "0 2 * * *" or "@daily"
+
+	// ShipLogs lists where `linea schedule` sends the logs of scheduled runs
+	ShipLogs []LogShipTarget `yaml:"ship_logs,omitempty"`
+
+	// Notifications are sent when a run of the workflow succeeds or fails
+	Notifications []NotificationTarget `yaml:"notifications,omitempty"`
+
+	// DependsOn names the workflows `linea run` runs before this one
+	DependsOn StringList `yaml:"depends_on,omitempty"`
+
+	// Lock allows one run of the workflow at a time; LockWait is how long a run waits for
+	// the run in progress to finish (e.g. 10m) instead of failing at once
+	Lock     bool   `yaml:"lock,omitempty"`
+	LockWait string `yaml:"lock_wait,omitempty"`
+
+	// Changelog describes changes to the workflow, newest first, shown by `linea run` when
+	// the file changed since its last run
+	Changelog []ChangelogEntry `yaml:"changelog,omitempty"`
+
+	// SourceFile is the path of the YAML file the config was loaded from (set by the parser)
+	SourceFile string `yaml:"-"`
+
+	// Metadata is the leading metadata document of the file, if it has one (set by the parser)
+	Metadata *WorkflowMetadata `yaml:"-"`
 }
 
+// SecretRef describes where the value of a secret variable is resolved from
+type SecretRef struct {
+	From string `yaml:"from,omitempty"` // env, keychain, or file (default)
+	Key  string `yaml:"key,omitempty"`  // lookup key, defaults to the secret name
+}
+
+// ProviderSpec declares a variable whose value is fetched at run time by a variable provider
+type ProviderSpec struct {
+	Provider string            `yaml:"provider"`          // exec, http, file, env, or a registered provider
+	Command  string            `yaml:"command,omitempty"` // exec: program to run
+	Args     []string          `yaml:"args,omitempty"`    // exec: arguments
+	URL      string            `yaml:"url,omitempty"`     // http: URL fetched with GET
+	Headers  map[string]string `yaml:"headers,omitempty"` // http: request headers
+	Path     string            `yaml:"path,omitempty"`    // file: path, relative to the workflow file
+	Name     string            `yaml:"name,omitempty"`    // env: variable name, defaults to the variable's name
+	Default  string            `yaml:"default,omitempty"` // env: value used if the variable is unset, an expression if it starts with =
+	Field    string            `yaml:"field,omitempty"`   // Dot path into a JSON result, e.g. images.0.id
+	Cache    string            `yaml:"cache,omitempty"`   // Reuse the value across runs for this long, e.g. 10m or 1d
+	Type     string            `yaml:"type,omitempty"`    // path or raw, see VariableTypes
+}
+
+// UnmarshalYAML decodes a workflow document, upgrading older format versions, moving
+// `variables:` entries that are provider declarations into Providers and their types into
+// VariableTypes, and storing lists and maps under variables: as JSON
+func (c *CommandConfig) UnmarshalYAML(value *yaml.Node) error {
+	type plain CommandConfig
+	// Aliases and << merge keys are resolved first, so that the upgrades and the provider
+	// declarations see the keys they bring
+	value = resolveAliases(value)
+	if _, err := UpgradeWorkflowNode(value); err != nil {
+		return err
+	}
+	node, providers, types, err := splitProviderVariables(value)
+	if err != nil {
+		return err
+	}
+	if err := node.Decode((*plain)(c)); err != nil {
+		return err
+	}
+	c.Providers = providers
+	c.VariableTypes = types
+	return nil
+}
+
+// splitProviderVariables returns a copy of a document node without the provider
+// declarations (mappings with a provider: key) under variables:, the decoded
+// declarations, and the types of the variables that have one. A mapping of value: and
+// type: is replaced by its value; other lists and maps are replaced by their JSON text
+func splitProviderVariables(doc *yaml.Node) (*yaml.Node, map[string]ProviderSpec, map[string]string, error) {
+	if doc.Kind != yaml.MappingNode {
+		return doc, nil, nil, nil
+	}
+
+	var providers map[string]ProviderSpec
+	var types map[string]string
+	setType := func(name, t string) {
+		if types == nil {
+			types = make(map[string]string)
+		}
+		types[name] = t
+	}
+	root := *doc
+	root.Content = append([]*yaml.Node{}, doc.Content...)
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		vars := root.Content[i+1]
+		if root.Content[i].Value != "variables" || vars.Kind != yaml.MappingNode {
+			continue
+		}
+
+		filtered := *vars
+		filtered.Content = nil
+		for j := 0; j+1 < len(vars.Content); j += 2 {
+			key, val := vars.Content[j], vars.Content[j+1]
+			if val.Kind == yaml.AliasNode {
+				val = val.Alias
+			}
+			if isTypedVariable(val) {
+				setType(key.Value, mappingNode(val, "type").Value)
+				val = resolveAliases(mappingNode(val, "value"))
+				if val.Kind == yaml.ScalarNode {
+					filtered.Content = append(filtered.Content, key, val)
+					continue
+				}
+			}
+			if val.Kind == yaml.SequenceNode || (val.Kind == yaml.MappingNode && !hasMappingKey(val, "provider")) {
+				text, err := yamlVariableValue(val)
+				if err != nil {
+					return nil, nil, nil, fmt.Errorf("variable %s: %w", key.Value, err)
+				}
+				value := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: text, Line: val.Line, Column: val.Column}
+				filtered.Content = append(filtered.Content, vars.Content[j], value)
+				continue
+			}
+			if val.Kind != yaml.MappingNode {
+				filtered.Content = append(filtered.Content, vars.Content[j], vars.Content[j+1])
+				continue
+			}
+
+			var spec ProviderSpec
+			if err := val.Decode(&spec); err != nil {
+				return nil, nil, nil, fmt.Errorf("variable %s: %w", key.Value, err)
+			}
+			if providers == nil {
+				providers = make(map[string]ProviderSpec)
+			}
+			providers[key.Value] = spec
+			if spec.Type != "" {
+				setType(key.Value, spec.Type)
+			}
+		}
+		root.Content[i+1] = &filtered
+	}
+	return &root, providers, types, nil
+}
+
+// isTypedVariable reports whether a variable is declared as a mapping of exactly value:
+// and type:, such as {value: "1.2/3", type: raw}
+func isTypedVariable(node *yaml.Node) bool {
+	return node.Kind == yaml.MappingNode && len(node.Content) == 4 && hasMappingKey(node, "value") && hasMappingKey(node, "type")
+}
+
+// StringList is a list of strings that can be written in YAML as a sequence or as a single scalar
+type StringList []string
+
+// UnmarshalYAML accepts both `key: value` and `key: [a, b]`
+func (l *StringList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		if value.ShortTag() == "!!null" {
+			*l = nil
+			return nil
+		}
+		*l = StringList{value.Value}
+		return nil
+	}
+
+	var items []string
+	if err := value.Decode(&items); err != nil {
+		return err
+	}
+	*l = items
+	return nil
+}
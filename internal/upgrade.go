@@ -0,0 +1,93 @@
+package internal
+
+import (
+	"fmt"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentWorkflowVersion is the latest workflow format, written as `version:` in each document
+// Documents without a version were written before versioning and are version 1
+const CurrentWorkflowVersion = 2
+
+// WorkflowUpgrade rewrites a workflow document from one format version to the next
+type WorkflowUpgrade struct {
+	From        int
+	Description string
+	apply       func(doc *yaml.Node)
+}
+
+// WorkflowUpgrades lists the documented format changes, oldest first
+var WorkflowUpgrades = []WorkflowUpgrade{
+	{
+		From:        1,
+		Description: "args written as a single string becomes a one-item list",
+		apply:       upgradeScalarArgs,
+	},
+}
+
+// WorkflowVersion returns the format version of a workflow document mapping
+func WorkflowVersion(doc *yaml.Node) (int, error) {
+	value, line := mappingValue(doc, "version")
+	if line == 0 {
+		return 1, nil
+	}
+	version, err := strconv.Atoi(value)
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("invalid version '%s' (expected 1 to %d)", value, CurrentWorkflowVersion)
+	}
+	if version > CurrentWorkflowVersion {
+		return 0, fmt.Errorf("version %d needs a newer linea (this one supports up to version %d)", version, CurrentWorkflowVersion)
+	}
+	return version, nil
+}
+
+// UpgradeWorkflowNode applies the upgrades a workflow document needs and sets its version
+// to CurrentWorkflowVersion. Returns the version the document was written in
+func UpgradeWorkflowNode(doc *yaml.Node) (int, error) {
+	if doc.Kind != yaml.MappingNode {
+		return CurrentWorkflowVersion, nil
+	}
+	version, err := WorkflowVersion(doc)
+	if err != nil {
+		return 0, err
+	}
+	if version == CurrentWorkflowVersion {
+		return version, nil
+	}
+
+	for _, upgrade := range WorkflowUpgrades {
+		if upgrade.From >= version {
+			upgrade.apply(doc)
+		}
+	}
+	setMappingValue(doc, "version", strconv.Itoa(CurrentWorkflowVersion), "!!int")
+	return version, nil
+}
+
+// upgradeScalarArgs turns `args: x` into `args: [x]`
+func upgradeScalarArgs(doc *yaml.Node) {
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		value := doc.Content[i+1]
+		if doc.Content[i].Value == "args" && value.Kind == yaml.ScalarNode && value.ShortTag() != "!!null" {
+			doc.Content[i+1] = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Line: value.Line, Column: value.Column, Content: []*yaml.Node{value}}
+		}
+	}
+}
+
+// setMappingValue sets a scalar key in a mapping node, adding it at the start if missing
+func setMappingValue(node *yaml.Node, key, value, tag string) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content[i+1] = &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value, Line: node.Content[i].Line}
+			return
+		}
+	}
+	keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key, Line: node.Line}
+	if len(node.Content) > 0 {
+		// Keep the document header comment at the top
+		keyNode.HeadComment, node.Content[0].HeadComment = node.Content[0].HeadComment, ""
+	}
+	node.Content = append([]*yaml.Node{keyNode, {Kind: yaml.ScalarNode, Tag: tag, Value: value, Line: node.Line}}, node.Content...)
+}
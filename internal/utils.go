@@ -3,8 +3,11 @@ package internal
 import (
 	"fmt"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // DetectOS returns the current operating system
@@ -26,19 +29,12 @@ func NormalizePath(path string) string {
 
 // SubstituteVariables replaces {variable} and $variable placeholders in strings with their values
 func SubstituteVariables(s string, variables map[string]string) string {
-	result := s
-	for key, value := range variables {
-		// Support {variable} syntax
-		placeholder := "{" + key + "}"
-		result = strings.ReplaceAll(result, placeholder, value)
-		
-		// Support $variable syntax
-		dollarPlaceholder := "$" + key
-		result = strings.ReplaceAll(result, dollarPlaceholder, value)
-	}
-	return result
+	return substituteTemplate(s, variables, variables)
 }
 
+// versionPattern matches numbers and versions separated by slashes, such as 1.2/3/4
+var versionPattern = regexp.MustCompile(`^v?[0-9]+(\.[0-9]+)*(/v?[0-9]+(\.[0-9]+)*)+$`)
+
 // IsPathLike checks if a string looks like a file path rather than a flag or option
 func IsPathLike(s string) bool {
 	// Exclude common Windows flags that start with / or \
@@ -48,6 +44,18 @@ func IsPathLike(s string) bool {
 		return false
 	}
 	
+	// A colon other than that of a drive letter is not part of a path: URLs
+	// (https://example.com/a/b), image references (registry/app:1.2), scp targets
+	// (host:dir/file)
+	if strings.LastIndexByte(s, ':') > 1 {
+		return false
+	}
+	
+	// Versions and ratios such as 1.2/3/4 or v2.0/4.1 are not paths either
+	if versionPattern.MatchString(s) {
+		return false
+	}
+	
 	// Check for Windows drive letters (C:, D:, etc.)
 	if len(s) >= 2 && s[1] == ':' && ((s[0] >= 'A' && s[0] <= 'Z') || (s[0] >= 'a' && s[0] <= 'z')) {
 		return true
@@ -90,60 +98,76 @@ func IsPathLike(s string) bool {
 }
 
 // ExtractVariableReferences extracts all variable references from a string
-// Returns a set of variable names (both {variable} and $variable syntax)
+// Returns a set of variable names ({variable}, ${variable}, and $variable syntax)
 func ExtractVariableReferences(s string) map[string]bool {
+	return extractVariableReferences(s, true)
+}
+
+// extractVariableReferences extracts the variable references of a string; bare $variable
+// references are only included when bare is set
+func extractVariableReferences(s string, bare bool) map[string]bool {
 	refs := make(map[string]bool)
-	
-	// Extract {variable} references
-	start := -1
-	for i, char := range s {
-		if char == '{' {
-			start = i
-		} else if char == '}' && start != -1 {
-			varName := s[start+1 : i]
-			if varName != "" {
-				refs[varName] = true
-			}
-			start = -1
-		}
-	}
-	
-	// Extract $variable references
-	// Look for $ followed by alphanumeric characters or underscore
-	for i := 0; i < len(s); i++ {
-		if s[i] == '$' && i+1 < len(s) {
-			// Check if next character is valid for variable name
-			if (s[i+1] >= 'a' && s[i+1] <= 'z') || 
-			   (s[i+1] >= 'A' && s[i+1] <= 'Z') || 
-			   s[i+1] == '_' {
-				// Extract variable name
-				j := i + 1
-				for j < len(s) && ((s[j] >= 'a' && s[j] <= 'z') || 
-					(s[j] >= 'A' && s[j] <= 'Z') || 
-					(s[j] >= '0' && s[j] <= '9') || 
-					s[j] == '_') {
-					j++
-				}
-				varName := s[i+1 : j]
-				if varName != "" {
-					refs[varName] = true
+	for _, token := range tokenizeTemplate(s) {
+		switch token.kind {
+		case tokenBrace, tokenDollarBrace:
+			if name, steps, ok := parsePipeline(token.expr); ok {
+				// {name|func} references name; with a default it is optional
+				if !hasDefault(steps) {
+					refs[variableReferenceName(name)] = true
 				}
-				i = j - 1
+			} else {
+				// {targets[0]} references targets
+				refs[variableReferenceName(token.expr)] = true
+			}
+		case tokenDollar:
+			// $1 is a positional parameter, as in an awk program, rather than a variable
+			if bare && (token.expr[0] < '0' || token.expr[0] > '9') {
+				refs[token.expr] = true
 			}
 		}
 	}
-	
 	return refs
 }
 
-// ValidateVariables checks if all referenced variables are defined
+// Variable syntaxes of the variable_syntax field
+const (
+	VariableSyntaxBraces = "braces" // {name} and ${name} must be defined
+	VariableSyntaxBare   = "bare"   // $name must be defined too
+)
+
+// CheckVariableSyntax validates the variable_syntax of a step
+func CheckVariableSyntax(config *CommandConfig) error {
+	switch config.VariableSyntax {
+	case "", VariableSyntaxBraces, VariableSyntaxBare:
+		return nil
+	}
+	return fmt.Errorf("variable_syntax must be %s or %s, not '%s'", VariableSyntaxBraces, VariableSyntaxBare, config.VariableSyntax)
+}
+
+// ValidateVariables checks if all referenced variables are defined, with the default
+// variable syntax
 // Returns an error listing missing variables if any
 func ValidateVariables(args []string, variables map[string]string) error {
+	return ValidateVariablesWithSyntax(args, variables, "")
+}
+
+// ValidateVariablesWithSyntax checks if all the variables referenced in a variable syntax
+// are defined. With VariableSyntaxBraces (or "") only {name} and ${name} must be defined: a
+// bare $name that is not a variable is left as it is, as $1 in an awk program or $_ in a
+// perl one. With VariableSyntaxBare, $name must be defined too
+func ValidateVariablesWithSyntax(args []string, variables map[string]string, syntax string) error {
 	allRefs := make(map[string]bool)
 	
 	// Extract all variable references from all arguments
 	for _, arg := range args {
-		refs := ExtractVariableReferences(arg)
+		if err := validateTemplatePipelines(arg); err != nil {
+			return err
+		}
+		if err := validateVariablePaths(arg, variables); err != nil {
+			return err
+		}
+
+		refs := extractVariableReferences(arg, syntax == VariableSyntaxBare)
 		for ref := range refs {
 			allRefs[ref] = true
 		}
@@ -192,52 +216,9 @@ func SubstituteVariablesInArgsWithSeparateMaps(args []string, yamlVars map[strin
 }
 
 // SubstituteVariablesWithSeparateMaps substitutes variables with separate maps
-// {name} uses yamlVars only (not overridable), $name uses dollarVars (overridable)
+// {name} uses yamlVars only (not overridable), $name and ${name} use dollarVars (overridable)
 func SubstituteVariablesWithSeparateMaps(s string, yamlVars map[string]string, dollarVars map[string]string) string {
-	result := s
-	
-	// First substitute {variable} using ONLY YAML variables (not overridable)
-	for key, value := range yamlVars {
-		placeholder := "{" + key + "}"
-		result = strings.ReplaceAll(result, placeholder, value)
-	}
-	
-	// Then substitute $variable using dollarVars (overridable, includes -s/--set)
-	for key, value := range dollarVars {
-		// Replace ${VAR} first (more specific)
-		placeholder2 := "${" + key + "}"
-		result = strings.ReplaceAll(result, placeholder2, value)
-		
-		// Replace $VAR (but not if it's part of a longer variable name)
-		placeholder1 := "$" + key
-		for {
-			idx := strings.Index(result, placeholder1)
-			if idx == -1 {
-				break
-			}
-			
-			// Check if it's a valid variable reference (not part of a longer variable)
-			afterIdx := idx + len(placeholder1)
-			if afterIdx >= len(result) {
-				// End of string, valid replacement
-				result = result[:idx] + value + result[afterIdx:]
-			} else {
-				nextChar := result[afterIdx]
-				// Valid if next char is not alphanumeric or underscore
-				if !((nextChar >= 'a' && nextChar <= 'z') || 
-					 (nextChar >= 'A' && nextChar <= 'Z') || 
-					 (nextChar >= '0' && nextChar <= '9') || 
-					 nextChar == '_') {
-					result = result[:idx] + value + result[afterIdx:]
-				} else {
-					// Skip this occurrence, it's part of a longer variable
-					result = result[:idx+1] + result[idx+1:]
-				}
-			}
-		}
-	}
-	
-	return result
+	return substituteTemplate(s, yamlVars, dollarVars)
 }
 
 // GetHelpFlag returns the appropriate help flag for the current OS
@@ -248,3 +229,18 @@ func GetHelpFlag() string {
 	return "--help"
 }
 
+// ParseAge parses a positive duration such as 30d, 12h, or 90m
+func ParseAge(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil || days <= 0 {
+			return 0, fmt.Errorf("invalid age '%s' (expected e.g. 30d, 12h, or 90m)", s)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid age '%s' (expected e.g. 30d, 12h, or 90m)", s)
+	}
+	return d, nil
+}
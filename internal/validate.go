@@ -0,0 +1,722 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"linea/schema"
+)
+
+// ValidationProblem is a single issue found while validating a workflow file
+type ValidationProblem struct {
+	File    string
+	Line    int
+	Message string
+}
+
+// String formats the problem as file:line: message
+func (p ValidationProblem) String() string {
+	if p.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", p.File, p.Line, p.Message)
+	}
+	return fmt.Sprintf("%s: %s", p.File, p.Message)
+}
+
+// jsonSchema is the subset of JSON Schema used by the published workflow schema
+type jsonSchema struct {
+	Type                 schemaTypes            `json:"type"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	AdditionalProperties *additionalProperties  `json:"additionalProperties"`
+	Required             []string               `json:"required"`
+	Items                *jsonSchema            `json:"items"`
+	Enum                 []interface{}          `json:"enum"`
+	AnyOf                []*jsonSchema          `json:"anyOf"`
+}
+
+// schemaTypes holds a JSON Schema "type", which may be a string or a list of strings
+type schemaTypes []string
+
+// UnmarshalJSON accepts both "string" and ["string", "number"]
+func (t *schemaTypes) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = schemaTypes{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*t = list
+	return nil
+}
+
+// additionalProperties is either a boolean or a schema for extra keys
+type additionalProperties struct {
+	Allowed bool
+	Schema  *jsonSchema
+}
+
+// UnmarshalJSON accepts both true/false and a schema object
+func (a *additionalProperties) UnmarshalJSON(data []byte) error {
+	var allowed bool
+	if err := json.Unmarshal(data, &allowed); err == nil {
+		a.Allowed = allowed
+		return nil
+	}
+	a.Allowed = true
+	a.Schema = &jsonSchema{}
+	return json.Unmarshal(data, a.Schema)
+}
+
+// loadWorkflowSchema parses the embedded workflow JSON Schema
+func loadWorkflowSchema() (*jsonSchema, error) {
+	var s jsonSchema
+	if err := json.Unmarshal(schema.Workflow, &s); err != nil {
+		return nil, fmt.Errorf("invalid embedded workflow schema: %w", err)
+	}
+	return &s, nil
+}
+
+// nodeType returns the JSON type of a YAML node
+func nodeType(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "object"
+	case yaml.SequenceNode:
+		return "array"
+	case yaml.AliasNode:
+		return nodeType(node.Alias)
+	}
+	switch node.ShortTag() {
+	case "!!int":
+		return "integer"
+	case "!!float":
+		return "number"
+	case "!!bool":
+		return "boolean"
+	case "!!null":
+		return "null"
+	}
+	return "string"
+}
+
+// typeMatches reports whether a node type satisfies one of the allowed schema types
+func typeMatches(actual string, allowed schemaTypes) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, t := range allowed {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// validateNode checks a YAML node against a schema, appending problems
+func validateNode(node *yaml.Node, s *jsonSchema, path string, problem func(line int, format string, args ...interface{})) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+
+	actual := nodeType(node)
+	if len(s.AnyOf) > 0 {
+		// The first alternative of the node's type with the required keys present is checked
+		var types []string
+		for _, alternative := range s.AnyOf {
+			if typeMatches(actual, alternative.Type) && hasRequiredKeys(node, alternative.Required) {
+				validateNode(node, alternative, path, problem)
+				return
+			}
+			types = append(types, alternative.Type...)
+		}
+		problem(node.Line, "%s must be %s, got %s", path, strings.Join(types, " or "), actual)
+		return
+	}
+	if !typeMatches(actual, s.Type) {
+		problem(node.Line, "%s must be %s, got %s", path, strings.Join(s.Type, " or "), actual)
+		return
+	}
+
+	if len(s.Enum) > 0 && node.Kind == yaml.ScalarNode {
+		allowed := make([]string, 0, len(s.Enum))
+		found := false
+		for _, e := range s.Enum {
+			value := fmt.Sprint(e)
+			allowed = append(allowed, value)
+			if value == node.Value {
+				found = true
+			}
+		}
+		if !found {
+			problem(node.Line, "%s must be one of %s, got '%s'", path, strings.Join(allowed, ", "), node.Value)
+		}
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		present := make(map[string]bool)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			keyNode, valueNode := node.Content[i], node.Content[i+1]
+			key := keyNode.Value
+			present[key] = true
+
+			childPath := key
+			if path != "" {
+				childPath = path + "." + key
+			}
+
+			if prop, ok := s.Properties[key]; ok {
+				validateNode(valueNode, prop, childPath, problem)
+			} else if s.AdditionalProperties != nil && !s.AdditionalProperties.Allowed {
+				problem(keyNode.Line, "unknown key '%s'%s", key, suggestKey(key, s.Properties))
+			} else if s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil {
+				validateNode(valueNode, s.AdditionalProperties.Schema, childPath, problem)
+			}
+		}
+		for _, required := range s.Required {
+			if !present[required] {
+				problem(node.Line, "missing required key '%s'", required)
+			}
+		}
+	case yaml.SequenceNode:
+		if s.Items != nil {
+			for i, item := range node.Content {
+				validateNode(item, s.Items, fmt.Sprintf("%s[%d]", path, i), problem)
+			}
+		}
+	}
+}
+
+// hasRequiredKeys reports whether a mapping node has all the keys; other nodes have none
+func hasRequiredKeys(node *yaml.Node, keys []string) bool {
+	for _, key := range keys {
+		if node.Kind != yaml.MappingNode || !hasMappingKey(node, key) {
+			return false
+		}
+	}
+	return true
+}
+
+// suggestKey returns a "did you mean" hint for a misspelled key
+func suggestKey(key string, properties map[string]*jsonSchema) string {
+	best, bestDistance := "", 3
+	for candidate := range properties {
+		if d := editDistance(key, candidate); d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (did you mean '%s'?)", best)
+}
+
+// editDistance returns the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = curr[j-1] + 1
+			if prev[j]+1 < curr[j] {
+				curr[j] = prev[j] + 1
+			}
+			if prev[j-1]+cost < curr[j] {
+				curr[j] = prev[j-1] + cost
+			}
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// ValidateWorkflowFile checks a workflow file against the schema and for semantic problems:
+// missing command, undefined variable references, and duplicate step names
+// overrideVars are treated as provided with -s/--set
+func ValidateWorkflowFile(filePath string, overrideVars map[string]string) ([]ValidationProblem, error) {
+	return validateWorkflowFile(filePath, overrideVars, true)
+}
+
+// ValidateWorkflowDefinition is ValidateWorkflowFile for a workflow that is not about to run:
+// $name references are accepted since they can still be passed with -s/--set
+func ValidateWorkflowDefinition(filePath string) ([]ValidationProblem, error) {
+	return validateWorkflowFile(filePath, nil, false)
+}
+
+// validateWorkflowFile implements ValidateWorkflowFile; requireSetVars reports $name references
+// that are neither declared nor in overrideVars
+func validateWorkflowFile(filePath string, overrideVars map[string]string, requireSetVars bool) ([]ValidationProblem, error) {
+	s, err := loadWorkflowSchema()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ReadWorkflowFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []ValidationProblem
+	problem := func(line int, format string, args ...interface{}) {
+		if !IsYAMLFile(filePath) {
+			line = 0 // The lines of a converted .json or .toml file are not the file's
+		}
+		problems = append(problems, ValidationProblem{File: filePath, Line: line, Message: fmt.Sprintf(format, args...)})
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	stepNames := make(map[string]int)
+	capturedSteps := make(map[string]bool) // Named steps with capture:, for stdin: {step: ...}
+	documents := 0
+	var metadata *WorkflowMetadata // The leading metadata document, if any
+
+	// checkStep reports the problems of a step, defined at line, that the schema cannot find
+	checkStep := func(config *CommandConfig, line int) {
+		if config.Type != "" {
+			if _, err := LookupStepType(config.Type); err != nil {
+				problem(line, "%v", err)
+			} else if config.Command != "" {
+				problem(line, "command cannot be combined with type: %s", config.Type)
+			}
+			if config.Type == StepTypeHealthCheck {
+				if err := config.HealthCheck.Check(); err != nil {
+					problem(line, "%v", err)
+				}
+			}
+		} else if strings.TrimSpace(config.Command) == "" {
+			problem(line, "command must not be empty")
+		}
+		if config.HealthCheck != nil && config.Type != StepTypeHealthCheck {
+			problem(line, "healthcheck: requires type: healthcheck")
+		}
+		if err := CheckFileStep(config); err != nil {
+			problem(line, "%v", err)
+		}
+		if err := CheckArchiveStep(config); err != nil {
+			problem(line, "%v", err)
+		}
+		if err := CheckTransferStep(config); err != nil {
+			problem(line, "%v", err)
+		}
+		if err := CheckGitStep(config); err != nil {
+			problem(line, "%v", err)
+		}
+		if err := CheckNotifyStep(config); err != nil {
+			problem(line, "%v", err)
+		}
+		if err := CheckAssertStep(config); err != nil {
+			problem(line, "%v", err)
+		} else if config.Assert != nil && config.Assert.Step != "" && !capturedSteps[stepInputKey(config, foreachIndexSuffix.ReplaceAllString(config.Assert.Step, "[]"))] {
+			problem(line, "assert: step '%s' must be an earlier step with capture: true", config.Assert.Step)
+		}
+
+		if _, err := ParseTimeWindow(config); err != nil {
+			problem(line, "%v", err)
+		}
+
+		for _, name := range sortedProviderNames(config.Providers) {
+			if err := CheckProviderSpec(name, config.Providers[name]); err != nil {
+				problem(line, "%v", err)
+			}
+		}
+
+		if config.Schedule != "" {
+			if _, err := ParseCron(config.Schedule); err != nil {
+				problem(line, "%v", err)
+			}
+			if config.Timezone != "" && !HasTimeWindow(config) {
+				if _, err := time.LoadLocation(config.Timezone); err != nil {
+					problem(line, "invalid timezone '%s': %v", config.Timezone, err)
+				}
+			}
+		}
+
+		for _, code := range config.AllowedExitCodes {
+			if code < 0 || code > 255 {
+				problem(line, "invalid exit code %d in allowed_exit_codes (expected 0-255)", code)
+			}
+		}
+
+		if config.When != "" {
+			if _, err := EvaluateExpression(config.When, nil, nil); err != nil {
+				problem(line, "when: %v", err)
+			}
+		}
+		if config.Stdin != nil {
+			if err := config.Stdin.Check(); err != nil {
+				problem(line, "%v", err)
+			} else if config.Stdin.Step != "" && !capturedSteps[stepInputKey(config, foreachIndexSuffix.ReplaceAllString(config.Stdin.Step, "[]"))] {
+				problem(line, "stdin: step '%s' must be an earlier step with capture: true", config.Stdin.Step)
+			}
+		}
+		if config.Capture && config.Name != "" {
+			// The copies of a foreach: step are named name[0], name[1], ...
+			for _, key := range stepOutputKeys(config) {
+				if config.Foreach != nil {
+					key += "[]"
+				}
+				capturedSteps[key] = true
+			}
+		}
+
+		if err := CheckEnvPatterns(config.EnvPassthrough); err != nil {
+			problem(line, "%v", err)
+		}
+		if err := CheckInteractive(config); err != nil {
+			problem(line, "%v", err)
+		}
+		if err := CheckBackground(config); err != nil {
+			problem(line, "%v", err)
+		}
+		if err := CheckCleanup(config); err != nil {
+			problem(line, "%v", err)
+		}
+		if err := CheckLimits(config); err != nil {
+			problem(line, "%v", err)
+		}
+		if err := CheckRunner(config); err != nil {
+			problem(line, "%v", err)
+		}
+		if err := CheckLock(config); err != nil {
+			problem(line, "%v", err)
+		}
+		if err := CheckStepCache(config); err != nil {
+			problem(line, "%v", err)
+		}
+		if err := CheckForeach(config); err != nil {
+			problem(line, "%v", err)
+		}
+		if err := CheckNestedVariables(config); err != nil {
+			problem(line, "%v", err)
+		}
+		if err := CheckVariableSyntax(config); err != nil {
+			problem(line, "%v", err)
+		}
+		if err := CheckVariableTypes(config); err != nil {
+			problem(line, "%v", err)
+		}
+
+		for i, target := range config.ShipLogs {
+			if err := target.Check(); err != nil {
+				problem(line, "ship_logs[%d]: %v", i, err)
+			}
+		}
+		for i, target := range config.Notifications {
+			if err := target.Check(false); err != nil {
+				problem(line, "notifications[%d]: %v", i, err)
+			}
+		}
+		for _, name := range config.DependsOn {
+			if _, err := ResolveDependency(filePath, name); err != nil {
+				problem(line, "depends_on: %v", err)
+			}
+		}
+
+		for _, message := range undefinedReferences(config, overrideVars, requireSetVars) {
+			problem(line, "%s", message)
+		}
+	}
+
+	for {
+		var doc yaml.Node
+		if err := decoder.Decode(&doc); err != nil {
+			if err == io.EOF {
+				break
+			}
+			problem(0, "invalid YAML: %v", err)
+			break
+		}
+		if len(doc.Content) == 0 {
+			continue
+		}
+		root := resolveAliases(doc.Content[0])
+		if root.Kind == yaml.ScalarNode && root.ShortTag() == "!!null" {
+			// Empty document
+			continue
+		}
+		if documents == 0 && metadata == nil && isMetadataDocument(root) {
+			if metadata, err = decodeMetadata(root); err != nil {
+				_, line := mappingValue(root, "version")
+				problem(line, "%v", err)
+				metadata = &WorkflowMetadata{}
+			} else if err := CheckWorkflowInputs(metadata.Inputs); err != nil {
+				problem(root.Line, "%v", err)
+			}
+			continue
+		}
+		inheritVersion(root, metadata)
+		documents++
+
+		// Older format versions are checked as they load: after their upgrades
+		if _, err := UpgradeWorkflowNode(root); err != nil {
+			_, line := mappingValue(root, "version")
+			problem(line, "%v", err)
+			continue
+		}
+
+		if name, line := mappingValue(root, "name"); name != "" {
+			if first, exists := stepNames[name]; exists {
+				problem(line, "duplicate step name '%s' (first defined at line %d)", name, first)
+			} else {
+				stepNames[name] = line
+			}
+		}
+
+		before := len(problems)
+		validateNode(root, s, "", problem)
+		// The schema cannot require command only for steps without a built-in type:
+		// nor validate the steps of a group: against the schema of a step
+		if root.Kind == yaml.MappingNode && !hasMappingKey(root, "command") && !hasMappingKey(root, "type") && !hasMappingKey(root, "group") && !hasMappingKey(root, "workflow") && !hasMappingKey(root, "use") {
+			problem(root.Line, "missing required key 'command'")
+		}
+		validateGroupSteps(root, s, problem)
+		if len(problems) > before {
+			// Structural problems make the semantic checks unreliable
+			continue
+		}
+
+		var config CommandConfig
+		if err := root.Decode(&config); err != nil {
+			problem(root.Line, "invalid workflow: %v", err)
+			continue
+		}
+		config.SourceFile = filePath
+		config.Metadata = metadata
+
+		if config.Workflow != "" || len(config.Set) > 0 {
+			// The called workflow is validated on its own; the outputs of its steps are
+			// visible to the steps after the call
+			if err := CheckWorkflowCall(&config); err != nil {
+				problem(root.Line, "%v", err)
+			} else if called, err := expandWorkflowCall(&config, []string{absPath(filePath)}); err != nil {
+				problem(root.Line, "%v", err)
+			} else {
+				for _, step := range called {
+					if !step.Capture || step.Name == "" {
+						continue
+					}
+					for _, key := range stepOutputKeys(step) {
+						if step.Foreach != nil {
+							key += "[]"
+						}
+						capturedSteps[key] = true
+					}
+				}
+			}
+			for _, message := range undefinedReferences(&config, overrideVars, requireSetVars) {
+				problem(root.Line, "%s", message)
+			}
+			continue
+		}
+
+		if len(config.With) > 0 && config.Use == "" {
+			problem(root.Line, "with requires use:")
+		}
+		if config.Use != "" {
+			// The steps of the template are checked as the steps of a group
+			group, err := templateGroup(&config)
+			if err != nil {
+				problem(root.Line, "use %s: %v", config.Use, err)
+				continue
+			}
+			for _, message := range CheckGroup(group) {
+				problem(root.Line, "use %s: %s", config.Use, message)
+			}
+			for _, step := range ExpandGroup(group) {
+				step.UsedBy = &config
+				checkStep(step, root.Line)
+			}
+			for _, message := range undefinedReferences(&config, overrideVars, requireSetVars) {
+				problem(root.Line, "%s", message)
+			}
+			continue
+		}
+
+		steps, lines := []*CommandConfig{&config}, []int{root.Line}
+		if config.Group == "" && (len(config.Steps) > 0 || len(config.Inputs) > 0 || len(config.Exports) > 0) {
+			problem(root.Line, "steps, inputs, and exports require group:")
+		}
+		if config.Group != "" {
+			for _, message := range CheckGroup(&config) {
+				problem(root.Line, "%s", message)
+			}
+			steps, lines = ExpandGroup(&config), groupStepLines(root)
+		}
+		for i, step := range steps {
+			checkStep(step, lines[i])
+		}
+	}
+
+	if documents == 0 && len(problems) == 0 {
+		problem(0, "no workflow documents found")
+	}
+
+	return problems, nil
+}
+
+// sortedProviderNames returns the names of provider variables in sorted order, for stable output
+func sortedProviderNames(providers map[string]ProviderSpec) []string {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// mappingValue returns the scalar value and line of a key in a mapping node
+func mappingValue(node *yaml.Node, key string) (string, int) {
+	if node.Kind != yaml.MappingNode {
+		return "", 0
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key && node.Content[i+1].Kind == yaml.ScalarNode {
+			return node.Content[i+1].Value, node.Content[i+1].Line
+		}
+	}
+	return "", 0
+}
+
+// validateGroupSteps checks the steps of a group: document against the schema of a step
+func validateGroupSteps(root *yaml.Node, s *jsonSchema, problem func(line int, format string, args ...interface{})) {
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != "steps" || root.Content[i+1].Kind != yaml.SequenceNode {
+			continue
+		}
+		for j, step := range root.Content[i+1].Content {
+			path := fmt.Sprintf("steps[%d]", j)
+			validateNode(step, s, path, problem)
+			if step.Kind == yaml.MappingNode && !hasMappingKey(step, "command") && !hasMappingKey(step, "type") {
+				problem(step.Line, "%s: missing required key 'command'", path)
+			}
+		}
+	}
+}
+
+// groupStepLines returns the line of each step of a group: document
+func groupStepLines(root *yaml.Node) []int {
+	var lines []int
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == "steps" && root.Content[i+1].Kind == yaml.SequenceNode {
+			for _, step := range root.Content[i+1].Content {
+				lines = append(lines, step.Line)
+			}
+		}
+	}
+	return lines
+}
+
+// hasMappingKey reports whether a mapping node has key
+func hasMappingKey(node *yaml.Node, key string) bool {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return true
+		}
+	}
+	return false
+}
+
+// undefinedReferences lists variable references that can never be resolved for a step
+// Undeclared $name references are only reported when requireSetVars is set
+func undefinedReferences(config *CommandConfig, overrideVars map[string]string, requireSetVars bool) []string {
+	// {name} only resolves from YAML variables, secrets, and built-ins
+	braceVars := BuiltinVariables(config)
+	if config.Group != "" {
+		// The steps of a group see its inputs instead of -s/--set values
+		for k := range config.Inputs {
+			braceVars[k] = ""
+		}
+		overrideVars = nil
+	} else if config.Metadata != nil && len(config.Metadata.Inputs) > 0 {
+		// The inputs of the workflow are set like -s/--set values
+		withInputs := make(map[string]string, len(overrideVars)+len(config.Metadata.Inputs))
+		for k, v := range overrideVars {
+			withInputs[k] = v
+		}
+		for _, in := range config.Metadata.Inputs {
+			withInputs[in.Name] = ""
+		}
+		overrideVars = withInputs
+	}
+	for k, v := range config.Variables {
+		braceVars[k] = v
+	}
+	for k := range config.Secrets {
+		braceVars[k] = ""
+	}
+	for k := range config.Providers {
+		braceVars[k] = ""
+	}
+	if config.Foreach != nil {
+		braceVars["item"], braceVars["index"] = "", ""
+	}
+
+	args, _ := StepArguments(config)
+	sources := append([]string{config.When, config.Confirm}, args...)
+	if config.Stdin != nil {
+		sources = append(sources, config.Stdin.Text, config.Stdin.File)
+	}
+	if config.Cache != nil {
+		sources = append(sources, config.Cache.Key)
+	}
+	if config.Foreach != nil {
+		sources = append(sources, config.Foreach.From)
+	}
+	for _, v := range config.Variables {
+		sources = append(sources, v)
+	}
+	for _, v := range config.Set {
+		sources = append(sources, v)
+	}
+	for _, v := range config.With {
+		sources = append(sources, v)
+	}
+
+	missing := make(map[string]bool)
+	for _, source := range sources {
+		if err := validateTemplatePipelines(source); err != nil {
+			missing[err.Error()] = true
+		}
+		if err := validateVariablePaths(source, config.Variables); err != nil {
+			missing[err.Error()] = true
+		}
+		for name := range extractVariableReferences(source, config.VariableSyntax == VariableSyntaxBare) {
+			_, inBrace := braceVars[name]
+			_, inOverride := overrideVars[name]
+			usesBrace := false
+			for _, next := range []string{"}", "|", "[", "."} {
+				// ${name} is a $ reference
+				usesBrace = usesBrace || strings.Count(source, "{"+name+next) > strings.Count(source, "${"+name+next)
+			}
+			if usesBrace && !inBrace {
+				missing[fmt.Sprintf("undefined variable {%s} (declare it under variables:)", name)] = true
+			} else if !usesBrace && !inBrace && !inOverride && requireSetVars {
+				missing[fmt.Sprintf("undefined variable $%s (declare it under variables: or pass -s %s=...)", name, name)] = true
+			}
+		}
+	}
+
+	messages := make([]string, 0, len(missing))
+	for message := range missing {
+		messages = append(messages, message)
+	}
+	sort.Strings(messages)
+	return messages
+}
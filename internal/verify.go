@@ -0,0 +1,153 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// VerifyCheck is the outcome of one step of `linea verify-install`
+type VerifyCheck struct {
+	Name   string
+	Err    error
+	Output string // Combined output of the command, shown when the check fails
+}
+
+// VerifyWorkflowName is the workflow of the temporary app created by VerifyInstall
+const VerifyWorkflowName = "verify"
+
+// WriteVerifyApp creates a minimal Linea App in dir: a workflow that echoes $token
+// and a lineash script that calls the workflow as a command and echoes its argument
+func WriteVerifyApp(dir string) error {
+	workflowsDir := filepath.Join(dir, ".linea", "workflows")
+	scriptsDir := filepath.Join(dir, "scripts")
+	for _, d := range []string{workflowsDir, scriptsDir} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", d, err)
+		}
+	}
+
+	workflow := "name: verify\n" +
+		"description: linea verify-install smoke test\n" +
+		"command: echo\n" +
+		"args:\n" +
+		"  - \"workflow $token\"\n" +
+		"variables:\n" +
+		"  token: unset\n"
+	if err := os.WriteFile(filepath.Join(workflowsDir, VerifyWorkflowName+".yml"), []byte(workflow), 0644); err != nil {
+		return fmt.Errorf("failed to write workflow: %w", err)
+	}
+
+	script := "# linea verify-install smoke test\n" +
+		"TOKEN=$1\n" +
+		"if $TOKEN != \"\"\n" +
+		"    echo \"script $TOKEN\"\n" +
+		"end\n" +
+		VerifyWorkflowName + " -s token=$TOKEN\n"
+	if err := os.WriteFile(filepath.Join(scriptsDir, "verify.lnsh"), []byte(script), 0755); err != nil {
+		return fmt.Errorf("failed to write script: %w", err)
+	}
+	return nil
+}
+
+// VerifyInstall creates a temporary app and runs it end-to-end with the linea executable
+// at lineaPath: building the workflow, running it, and running a lineash script that
+// calls it. The runs use their own LINEA_HOME, so the user's history and config are
+// untouched. The temporary directory is removed unless keep is set
+// Returns the temporary directory and the checks, stopping at the first failure
+func VerifyInstall(lineaPath string, keep bool) (string, []VerifyCheck) {
+	var checks []VerifyCheck
+	dir, err := os.MkdirTemp("", "linea-verify-")
+	if err != nil {
+		return "", append(checks, VerifyCheck{Name: "create temporary app", Err: err})
+	}
+	if !keep {
+		defer os.RemoveAll(dir)
+	}
+
+	if err := WriteVerifyApp(dir); err != nil {
+		return dir, append(checks, VerifyCheck{Name: "create temporary app", Err: err})
+	}
+	checks = append(checks, VerifyCheck{Name: "create temporary app"})
+
+	token := verifyToken()
+	workflow := filepath.Join(dir, ".linea", "workflows", VerifyWorkflowName+".yml")
+	steps := []struct {
+		name   string
+		args   []string
+		expect []string
+	}{
+		{"dry-run workflow (linea test)", []string{"test", workflow, "-s", "token=" + token}, []string{"echo workflow " + token}},
+		{"run workflow (linea run)", []string{"run", workflow, "-s", "token=" + token}, []string{"workflow " + token}},
+		{"run lineash script (linea sh)", []string{"sh", filepath.Join(dir, "scripts", "verify.lnsh"), token}, []string{"script " + token, "workflow " + token}},
+	}
+	env := verifyEnv(dir, lineaPath)
+	for _, step := range steps {
+		check := VerifyCheck{Name: step.name}
+		check.Output, check.Err = runVerifyStep(lineaPath, dir, env, step.args, step.expect)
+		checks = append(checks, check)
+		if check.Err != nil {
+			break
+		}
+	}
+	return dir, checks
+}
+
+// runVerifyStep runs linea with args in dir and checks that its output has every line of expect
+func runVerifyStep(lineaPath, dir string, env, args, expect []string) (string, error) {
+	var out bytes.Buffer
+	execCmd := exec.Command(lineaPath, args...)
+	execCmd.Dir = dir
+	execCmd.Env = env
+	execCmd.Stdout = &out
+	execCmd.Stderr = &out
+	if err := execCmd.Run(); err != nil {
+		return out.String(), fmt.Errorf("linea %s failed: %w", args[0], err)
+	}
+
+	lines := strings.Split(strings.ReplaceAll(out.String(), "\r\n", "\n"), "\n")
+	for _, want := range expect {
+		found := false
+		for _, line := range lines {
+			if strings.TrimSpace(line) == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return out.String(), fmt.Errorf("expected output line %q", want)
+		}
+	}
+	return out.String(), nil
+}
+
+// verifyEnv returns the environment of the verification runs: everything linea stores goes
+// under dir, and lineash calls back into the executable being verified
+func verifyEnv(dir, lineaPath string) []string {
+	overridden := map[string]bool{
+		LineaHomeEnv: true, ConfigDirEnv: true, DataDirEnv: true, StateDirEnv: true,
+		CacheDirEnv: true, GlobalWorkflowsEnv: true, LineaBinEnv: true,
+	}
+	var env []string
+	for _, kv := range os.Environ() {
+		if name := strings.SplitN(kv, "=", 2)[0]; !overridden[name] {
+			env = append(env, kv)
+		}
+	}
+	return append(env,
+		LineaHomeEnv+"="+filepath.Join(dir, "home"),
+		LineaBinEnv+"="+lineaPath,
+	)
+}
+
+// verifyToken returns a random value the verification runs must echo back
+func verifyToken() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
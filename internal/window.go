@@ -0,0 +1,210 @@
+package internal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// weekdayNames maps accepted day names to weekdays
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// hourRange is a daily window in minutes since midnight; end is exclusive and may be
+// smaller than start for windows crossing midnight
+type hourRange struct {
+	start, end int
+}
+
+func (r hourRange) contains(minute int) bool {
+	if r.start <= r.end {
+		return minute >= r.start && minute < r.end
+	}
+	return minute >= r.start || minute < r.end
+}
+
+// TimeWindow is the parsed allowed_hours/allowed_days/timezone guard of a step
+type TimeWindow struct {
+	hours    []hourRange
+	days     map[time.Weekday]bool
+	location *time.Location
+}
+
+// HasTimeWindow reports whether a step declares a maintenance window
+func HasTimeWindow(config *CommandConfig) bool {
+	return config.AllowedHours != "" || len(config.AllowedDays) > 0
+}
+
+// ParseTimeWindow parses the maintenance window of a step
+// Returns nil if the step has no window
+func ParseTimeWindow(config *CommandConfig) (*TimeWindow, error) {
+	if !HasTimeWindow(config) {
+		return nil, nil
+	}
+
+	window := &TimeWindow{location: time.Local}
+	if config.Timezone != "" {
+		location, err := time.LoadLocation(config.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone '%s': %w", config.Timezone, err)
+		}
+		window.location = location
+	}
+
+	if config.AllowedHours != "" {
+		for _, part := range strings.Split(config.AllowedHours, ",") {
+			r, err := parseHourRange(strings.TrimSpace(part))
+			if err != nil {
+				return nil, fmt.Errorf("invalid allowed_hours '%s': %w", config.AllowedHours, err)
+			}
+			window.hours = append(window.hours, r)
+		}
+	}
+
+	if len(config.AllowedDays) > 0 {
+		window.days = make(map[time.Weekday]bool)
+		for _, entry := range config.AllowedDays {
+			for _, part := range strings.Split(entry, ",") {
+				if err := addDayRange(window.days, strings.TrimSpace(part)); err != nil {
+					return nil, fmt.Errorf("invalid allowed_days: %w", err)
+				}
+			}
+		}
+	}
+
+	return window, nil
+}
+
+// parseHourRange parses "HH:MM-HH:MM" (or "H-H")
+func parseHourRange(s string) (hourRange, error) {
+	parts := strings.SplitN(s, "-", 2)
+	if len(parts) != 2 {
+		return hourRange{}, fmt.Errorf("expected a range like 09:00-17:00")
+	}
+	start, err := parseClock(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return hourRange{}, err
+	}
+	end, err := parseClock(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return hourRange{}, err
+	}
+	if start == end {
+		return hourRange{}, fmt.Errorf("range %s is empty", s)
+	}
+	return hourRange{start: start, end: end}, nil
+}
+
+// parseClock parses "HH:MM" or "H" into minutes since midnight; 24:00 is the end of the day
+func parseClock(s string) (int, error) {
+	hourText, minuteText := s, "0"
+	if i := strings.Index(s, ":"); i >= 0 {
+		hourText, minuteText = s[:i], s[i+1:]
+	}
+	hour, err := strconv.Atoi(hourText)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time '%s'", s)
+	}
+	minute, err := strconv.Atoi(minuteText)
+	if err != nil || minute < 0 || minute > 59 || hour < 0 || hour > 24 || (hour == 24 && minute != 0) {
+		return 0, fmt.Errorf("invalid time '%s'", s)
+	}
+	return hour*60 + minute, nil
+}
+
+// addDayRange adds a day name ("mon") or range ("mon-fri", "fri-mon") to days
+func addDayRange(days map[time.Weekday]bool, s string) error {
+	parts := strings.SplitN(strings.ToLower(s), "-", 2)
+	first, ok := weekdayNames[strings.TrimSpace(parts[0])]
+	if !ok {
+		return fmt.Errorf("unknown day '%s' (expected mon, tue, wed, thu, fri, sat, or sun)", s)
+	}
+	if len(parts) == 1 {
+		days[first] = true
+		return nil
+	}
+
+	last, ok := weekdayNames[strings.TrimSpace(parts[1])]
+	if !ok {
+		return fmt.Errorf("unknown day '%s' (expected mon, tue, wed, thu, fri, sat, or sun)", s)
+	}
+	for day := first; ; day = (day + 1) % 7 {
+		days[day] = true
+		if day == last {
+			break
+		}
+	}
+	return nil
+}
+
+// Allows reports whether the window includes the instant t
+func (w *TimeWindow) Allows(t time.Time) bool {
+	local := t.In(w.location)
+	if w.days != nil && !w.days[local.Weekday()] {
+		return false
+	}
+	if len(w.hours) == 0 {
+		return true
+	}
+	minute := local.Hour()*60 + local.Minute()
+	for _, r := range w.hours {
+		if r.contains(minute) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckTimeWindow returns an error if the step may not run at the instant now
+func CheckTimeWindow(config *CommandConfig, now time.Time) error {
+	window, err := ParseTimeWindow(config)
+	if err != nil || window == nil {
+		return err
+	}
+	if window.Allows(now) {
+		return nil
+	}
+
+	var allowed []string
+	if len(config.AllowedDays) > 0 {
+		allowed = append(allowed, strings.Join(config.AllowedDays, ","))
+	}
+	if config.AllowedHours != "" {
+		allowed = append(allowed, config.AllowedHours)
+	}
+	local := now.In(window.location)
+	return fmt.Errorf("outside the allowed window %s %s (now %s %s)",
+		strings.Join(allowed, " "), window.location, strings.ToLower(local.Format("Mon")), local.Format("15:04"))
+}
+
+// CheckTimeWindows checks the maintenance window of every step, so a workflow is blocked
+// before any of its steps run. Returns one error per step outside its window, or an
+// error if a window is invalid (which --force does not override)
+func CheckTimeWindows(configs []*CommandConfig, now time.Time) ([]error, error) {
+	var outside []error
+	for i, config := range configs {
+		if _, err := ParseTimeWindow(config); err != nil {
+			return nil, fmt.Errorf("%s: %w", StepLabel(i, config), err)
+		}
+		if err := CheckTimeWindow(config, now); err != nil {
+			outside = append(outside, fmt.Errorf("%s: %w", StepLabel(i, config), err))
+		}
+	}
+	return outside, nil
+}
+
+// StepLabel names a step in messages: its name, or its position in the file
+func StepLabel(index int, config *CommandConfig) string {
+	if config.Name != "" {
+		return fmt.Sprintf("step '%s'", config.Name)
+	}
+	return fmt.Sprintf("command %d", index+1)
+}
@@ -0,0 +1,131 @@
+package internal
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// WorkflowCall is the workflow: step that a step of a called workflow runs for
+type WorkflowCall struct {
+	Name   string         // The name of the call, which scopes the steps it runs, e.g. build
+	Caller *CommandConfig // The workflow: step, in whose scope set: is evaluated
+}
+
+// CheckWorkflowCall validates a workflow: step
+func CheckWorkflowCall(config *CommandConfig) error {
+	if config.Workflow == "" {
+		if len(config.Set) > 0 {
+			return fmt.Errorf("set requires workflow:")
+		}
+		return nil
+	}
+	if config.Command != "" || config.Type != "" || len(config.Args) > 0 || config.Group != "" || config.When != "" || config.Confirm != "" || config.Stdin != nil || config.Foreach != nil || config.Use != "" {
+		return fmt.Errorf("a workflow: step cannot have command, type, args, group, when, confirm, stdin, foreach, or use; put them in the called workflow")
+	}
+	return nil
+}
+
+// expandWorkflowCall returns the steps of the workflow a workflow: step calls, ready to
+// run in order with the other steps of the file. They run as if the called workflow was
+// run with its set: values as -s/--set values, and are named after the call, as in
+// build/test. calls holds the absolute paths of the files being parsed, to report a
+// workflow that calls itself
+func expandWorkflowCall(config *CommandConfig, calls []string) ([]*CommandConfig, error) {
+	if err := CheckWorkflowCall(config); err != nil {
+		return nil, err
+	}
+	path, err := ResolveDependency(config.SourceFile, config.Workflow)
+	if err != nil {
+		return nil, fmt.Errorf("workflow: %w", err)
+	}
+	if RequireSignatures {
+		if _, err := VerifyWorkflow(path); err != nil {
+			return nil, fmt.Errorf("workflow %s: %w", config.Workflow, err)
+		}
+	}
+	steps, err := parseWorkflowFile(path, calls)
+	if err != nil {
+		return nil, fmt.Errorf("workflow %s: %w", config.Workflow, err)
+	}
+
+	call := &WorkflowCall{Name: config.Name, Caller: config}
+	if call.Name == "" {
+		call.Name = WorkflowName(path)
+	}
+	for _, step := range steps {
+		if step.Call == nil {
+			step.Call = call
+		} else {
+			// A step of a workflow that the called one calls in turn: its outermost call
+			// is made by this one
+			outer := step.Call
+			for outer.Caller.Call != nil && outer.Caller.Call != call {
+				outer = outer.Caller.Call
+			}
+			outer.Caller.Call = call
+		}
+
+		// The settings of the called workflow as a whole belong to the calling one
+		step.DependsOn, step.Lock, step.LockWait, step.Schedule = nil, false, "", ""
+		step.Notifications, step.ShipLogs, step.Changelog = nil, nil, nil
+	}
+	return steps, nil
+}
+
+// setValues evaluates the set: of a call in the scope of the calling step, where
+// overrideVars are the -s/--set values, returning the -s/--set values of the called steps
+func (c *WorkflowCall) setValues(overrideVars map[string]string) (map[string]string, error) {
+	_, yamlVars, dollarVars, err := stepVariables(c.Caller, overrideVars)
+	if err != nil {
+		return nil, fmt.Errorf("workflow %s: %w", c.Name, err)
+	}
+	values := make([]string, 0, len(c.Caller.Set))
+	for _, v := range c.Caller.Set {
+		values = append(values, v)
+	}
+	allVars := make(map[string]string, len(dollarVars))
+	for k, v := range dollarVars {
+		allVars[k] = v
+	}
+	for k, v := range yamlVars {
+		allVars[k] = v
+	}
+	if err := ValidateVariablesWithSyntax(values, allVars, c.Caller.VariableSyntax); err != nil {
+		return nil, fmt.Errorf("workflow %s: set: %w", c.Name, err)
+	}
+
+	set := make(map[string]string, len(c.Caller.Set))
+	for k, v := range c.Caller.Set {
+		set[k] = SubstituteVariablesWithSeparateMaps(v, yamlVars, dollarVars)
+	}
+	return set, nil
+}
+
+// callScope returns the names of the workflow calls a step runs in, outermost first, as
+// in deploy/build, or "" for a step of the workflow being run
+func callScope(config *CommandConfig) string {
+	var names []string
+	for call := config.Call; call != nil; call = call.Caller.Call {
+		names = append([]string{call.Name}, names...)
+	}
+	return strings.Join(names, "/")
+}
+
+// stepScope returns the scope a step runs in, which prefixes its name: the workflow
+// calls and the group it is in, as in deploy/build/tests, or "" at the top level
+func stepScope(config *CommandConfig) string {
+	scope := callScope(config)
+	if config.Group != "" && scope != "" {
+		return scope + "/" + config.Group
+	}
+	return scope + config.Group
+}
+
+// absPath returns the absolute form of a path, or the path if it has none
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
@@ -0,0 +1,107 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// WorkflowExtensions are the extensions of workflow files, in the order a workflow name
+// is looked up
+var WorkflowExtensions = []string{".yml", ".yaml", ".json", ".toml"}
+
+// IsYAMLFile reports whether a file name has a YAML extension. Workflow files with other
+// extensions are converted to YAML when they are read
+func IsYAMLFile(name string) bool {
+	return strings.HasSuffix(name, ".yml") || strings.HasSuffix(name, ".yaml")
+}
+
+// ReadWorkflowFile reads a workflow file as YAML documents. .json and .toml files hold the
+// same keys: one step as an object or table, or several as the entries of a steps array
+// (or, in JSON, a top-level array), and are converted into one document per step
+func ReadWorkflowFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+
+	var document interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&document); err != nil {
+			return nil, fmt.Errorf("%s: invalid JSON: %w", path, err)
+		}
+		document = jsonNumbers(document)
+	case ".toml":
+		var table map[string]interface{}
+		if _, err := toml.Decode(string(data), &table); err != nil {
+			return nil, fmt.Errorf("%s: invalid TOML: %w", path, err)
+		}
+		document = table
+	default:
+		return data, nil
+	}
+
+	steps, ok := document.([]interface{})
+	if table, isTable := document.(map[string]interface{}); isTable {
+		steps, ok = table["steps"].([]interface{})
+		if tables, isTables := table["steps"].([]map[string]interface{}); isTables {
+			// A TOML array of tables, [[steps]]
+			steps, ok = make([]interface{}, len(tables)), true
+			for i, step := range tables {
+				steps[i] = step
+			}
+		}
+		if !ok || len(table) > 1 {
+			// A single step, or a group whose steps: are its own
+			steps = []interface{}{table}
+		}
+	} else if !ok {
+		return nil, fmt.Errorf("%s: a workflow must be an object or an array of objects", path)
+	}
+
+	var out bytes.Buffer
+	for i, step := range steps {
+		if i > 0 {
+			out.WriteString("---\n")
+		}
+		doc, err := yaml.Marshal(step)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		out.Write(doc)
+	}
+	return out.Bytes(), nil
+}
+
+// jsonNumbers replaces the numbers of a decoded JSON value with integers where they are
+// whole, so they decode into integer fields like YAML numbers do
+func jsonNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	case []interface{}:
+		for i := range v {
+			v[i] = jsonNumbers(v[i])
+		}
+	case map[string]interface{}:
+		for key := range v {
+			v[key] = jsonNumbers(v[key])
+		}
+	}
+	return value
+}
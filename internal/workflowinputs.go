@@ -0,0 +1,125 @@
+package internal
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// inputNamePattern is the form of the name of a workflow input, which is a variable name
+var inputNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// WorkflowInput is a positional argument of a workflow, declared in the inputs: of its
+// metadata document: `linea run deploy prod eu-west-1` sets the variables of the inputs
+// in order, as -s would
+type WorkflowInput struct {
+	Name        string  `yaml:"name" json:"name"`
+	Description string  `yaml:"description,omitempty" json:"description,omitempty"`
+	Default     *string `yaml:"default,omitempty" json:"default,omitempty"` // An input with a default is optional
+}
+
+// UnmarshalYAML accepts a string as the name of a required input, or a mapping
+func (in *WorkflowInput) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		in.Name = value.Value
+		return nil
+	}
+	type plain WorkflowInput
+	return value.Decode((*plain)(in))
+}
+
+// Optional reports whether the input has a default and can be left out
+func (in WorkflowInput) Optional() bool {
+	return in.Default != nil
+}
+
+// CheckWorkflowInputs validates the inputs: of a workflow: names are unique variable
+// names, and optional inputs come after the required ones, as arguments are mapped in order
+func CheckWorkflowInputs(inputs []WorkflowInput) error {
+	seen := map[string]bool{}
+	optional := ""
+	for _, in := range inputs {
+		if !inputNamePattern.MatchString(in.Name) {
+			return fmt.Errorf("inputs: invalid name '%s' (expected a variable name)", in.Name)
+		}
+		if seen[in.Name] {
+			return fmt.Errorf("inputs: '%s' is declared twice", in.Name)
+		}
+		seen[in.Name] = true
+		if in.Optional() {
+			optional = in.Name
+			if _, err := ExpressionDefault(*in.Default); err != nil {
+				return fmt.Errorf("inputs: '%s': default: %w", in.Name, err)
+			}
+		} else if optional != "" {
+			return fmt.Errorf("inputs: required input '%s' cannot follow the optional input '%s'", in.Name, optional)
+		}
+	}
+	return nil
+}
+
+// WorkflowInputs returns the inputs declared by a workflow file, or nil if it has none
+func WorkflowInputs(path string) ([]WorkflowInput, error) {
+	configs, err := ParseMultiYAML(path)
+	if err != nil {
+		return nil, err
+	}
+	if metadata := configs[0].Metadata; metadata != nil {
+		return metadata.Inputs, nil
+	}
+	return nil, nil
+}
+
+// InputUsage returns the arguments of a workflow as shown in its usage, e.g.
+// `<env> <region> [tier]`
+func InputUsage(inputs []WorkflowInput) string {
+	parts := make([]string, len(inputs))
+	for i, in := range inputs {
+		if in.Optional() {
+			parts[i] = "[" + in.Name + "]"
+		} else {
+			parts[i] = "<" + in.Name + ">"
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// BindInputs maps the positional arguments of a run of a workflow to its inputs, in order,
+// and returns the -s values with them added. An input left out takes its default; a
+// required one can also be given with -s. Too many arguments, a missing input, or an
+// input given both ways is an error showing the workflow's usage
+func BindInputs(workflow string, inputs []WorkflowInput, args []string, overrideVars map[string]string) (map[string]string, error) {
+	usage := strings.TrimSpace("linea run " + workflow + " " + InputUsage(inputs))
+	if len(args) > len(inputs) {
+		if len(inputs) == 0 {
+			return nil, fmt.Errorf("%s takes no arguments, got %d (usage: %s)", workflow, len(args), usage)
+		}
+		return nil, fmt.Errorf("%s takes at most %d argument(s), got %d (usage: %s)", workflow, len(inputs), len(args), usage)
+	}
+
+	vars := make(map[string]string, len(overrideVars)+len(inputs))
+	for k, v := range overrideVars {
+		vars[k] = v
+	}
+	for i, in := range inputs {
+		_, set := overrideVars[in.Name]
+		switch {
+		case i < len(args) && set:
+			return nil, fmt.Errorf("%s is given both as argument %d and with -s", in.Name, i+1)
+		case i < len(args):
+			vars[in.Name] = args[i]
+		case set:
+		case in.Optional():
+			value, err := ExpressionDefault(*in.Default)
+			if err != nil {
+				return nil, fmt.Errorf("%s: default: %w", in.Name, err)
+			}
+			vars[in.Name] = value
+		default:
+			return nil, fmt.Errorf("missing argument <%s> (usage: %s)", in.Name, usage)
+		}
+	}
+	return vars, nil
+}
@@ -0,0 +1,150 @@
+package internal
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maxAliasDepth bounds the expansion of aliases nested in the nodes they name
+const maxAliasDepth = 64
+
+// metadataKeys are the keys of a leading metadata document, which describes a multi-step
+// workflow without being a step itself
+var metadataKeys = map[string]bool{"version": true, "name": true, "description": true, "inputs": true, "normalize_paths": true, "templates": true}
+
+// WorkflowMetadata is the leading metadata document of a workflow file
+type WorkflowMetadata struct {
+	Version     int             `yaml:"version"`
+	Name        string          `yaml:"name"`
+	Description string          `yaml:"description"`
+	Inputs      []WorkflowInput `yaml:"inputs"` // Positional arguments of the workflow
+
+	// NormalizePaths is the normalize_paths of the steps that do not set their own
+	NormalizePaths *bool `yaml:"normalize_paths"`
+
+	// Templates are the step templates that the use: steps of the file can run, by name
+	Templates map[string]*StepTemplate `yaml:"templates"`
+}
+
+// isMetadataDocument reports whether a document holds only version, name, description,
+// inputs, normalize_paths, and templates
+func isMetadataDocument(root *yaml.Node) bool {
+	if root.Kind != yaml.MappingNode || len(root.Content) == 0 {
+		return false
+	}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if !metadataKeys[root.Content[i].Value] {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeMetadata decodes a leading metadata document, checking its version
+func decodeMetadata(root *yaml.Node) (*WorkflowMetadata, error) {
+	if _, err := WorkflowVersion(root); err != nil {
+		return nil, err
+	}
+	var metadata WorkflowMetadata
+	if err := root.Decode(&metadata); err != nil {
+		return nil, fmt.Errorf("invalid metadata document: %w", err)
+	}
+	return &metadata, nil
+}
+
+// inheritVersion sets the version of a document that has none to that of the metadata
+// document, so that `version:` is written once per file
+func inheritVersion(root *yaml.Node, metadata *WorkflowMetadata) {
+	if metadata == nil || metadata.Version == 0 || root.Kind != yaml.MappingNode || hasMappingKey(root, "version") {
+		return
+	}
+	setMappingValue(root, "version", fmt.Sprint(metadata.Version), "!!int")
+}
+
+// resolveAliases returns a copy of node with its aliases replaced by copies of the nodes
+// they name and its << merge keys applied: a mapping gets the keys of the mappings merged
+// into it that it does not set itself, the first merged mapping winning over the next
+// ones. Returns node itself when it has neither. The copies keep their line numbers
+func resolveAliases(node *yaml.Node) *yaml.Node {
+	if !hasAliases(node, 0) {
+		return node
+	}
+	return resolveNode(node, 0)
+}
+
+// hasAliases reports whether node has an alias or a merge key
+func hasAliases(node *yaml.Node, depth int) bool {
+	if node.Kind == yaml.AliasNode || depth > maxAliasDepth {
+		return true
+	}
+	for i, child := range node.Content {
+		if node.Kind == yaml.MappingNode && i%2 == 0 && isMergeKey(child) {
+			return true
+		}
+		if hasAliases(child, depth+1) {
+			return true
+		}
+	}
+	return false
+}
+
+// isMergeKey reports whether a mapping key is the << merge key
+func isMergeKey(key *yaml.Node) bool {
+	return key.Kind == yaml.ScalarNode && key.Value == "<<" && (key.Tag == "!!merge" || key.Tag == "")
+}
+
+// resolveNode implements resolveAliases
+func resolveNode(node *yaml.Node, depth int) *yaml.Node {
+	if depth > maxAliasDepth {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Line: node.Line, Column: node.Column}
+	}
+	if node.Kind == yaml.AliasNode {
+		return resolveNode(node.Alias, depth+1)
+	}
+
+	copied := *node
+	copied.Anchor = ""
+	copied.Content = nil
+	if node.Kind != yaml.MappingNode {
+		for _, child := range node.Content {
+			copied.Content = append(copied.Content, resolveNode(child, depth+1))
+		}
+		return &copied
+	}
+
+	own := make(map[string]bool)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if !isMergeKey(node.Content[i]) {
+			own[node.Content[i].Value] = true
+		}
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if !isMergeKey(key) {
+			copied.Content = append(copied.Content, resolveNode(key, depth+1), resolveNode(value, depth+1))
+			continue
+		}
+
+		// The merged mappings go where the << key was
+		merged := resolveNode(value, depth+1)
+		sources := []*yaml.Node{merged}
+		if merged.Kind == yaml.SequenceNode {
+			sources = merged.Content
+		}
+		for _, source := range sources {
+			if source.Kind != yaml.MappingNode {
+				// Left for the decoder to report
+				copied.Content = append(copied.Content, key, value)
+				continue
+			}
+			for j := 0; j+1 < len(source.Content); j += 2 {
+				if !own[source.Content[j].Value] {
+					own[source.Content[j].Value] = true
+					copied.Content = append(copied.Content, source.Content[j], source.Content[j+1])
+				}
+			}
+		}
+	}
+	return &copied
+}
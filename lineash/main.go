@@ -5,6 +5,7 @@ import (
 	"os"
 )
 
+// lineash is a thin alias for `linea sh`
 func main() {
 	cmd.LineashMain(os.Args[1:])
 }
@@ -5,16 +5,22 @@ import (
 	"os"
 
 	"linea/cmd"
+	"linea/internal"
 )
 
 func main() {
-	if len(os.Args) < 2 {
+	globalArgs, err := cmd.ParseGlobalFlags(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(globalArgs) < 1 {
 		printUsage()
 		os.Exit(1)
 	}
 
-	subcommand := os.Args[1]
-	args := os.Args[2:]
+	subcommand := globalArgs[0]
+	args := globalArgs[1:]
 
 	switch subcommand {
 	case "run":
@@ -27,9 +33,71 @@ func main() {
 		cmd.InitCommandMain(args)
 	case "app":
 		cmd.AppCreateCommandMain(args)
+	case "import":
+		cmd.ImportCommandMain(args)
+	case "export":
+		cmd.ExportCommandMain(args)
+	case "sh":
+		cmd.ShCommandMain(args)
+	case "validate":
+		cmd.ValidateCommandMain(args)
+	case "fmt":
+		cmd.FmtCommandMain(args)
+	case "lint":
+		cmd.LintCommandMain(args)
+	case "doctor":
+		cmd.DoctorCommandMain(args)
+	case "verify-install":
+		cmd.VerifyInstallCommandMain(args)
+	case "package":
+		cmd.PackageCommandMain(args)
+	case "install":
+		cmd.InstallCommandMain(args)
+	case "uninstall":
+		cmd.UninstallCommandMain(args)
+	case "search":
+		cmd.SearchCommandMain(args)
+	case "publish":
+		cmd.PublishCommandMain(args)
+	case "sign":
+		cmd.SignCommandMain(args)
+	case "verify":
+		cmd.VerifyCommandMain(args)
+	case "list":
+		cmd.ListCommandMain(args)
+	case "global":
+		cmd.GlobalCommandMain(args)
+	case "secret":
+		cmd.SecretCommandMain(args)
+	case "cache":
+		cmd.CacheCommandMain(args)
+	case "config":
+		cmd.ConfigCommandMain(args)
+	case "stats":
+		cmd.StatsCommandMain(args)
+	case "rerun":
+		cmd.RerunCommandMain(args)
+	case "history":
+		cmd.HistoryCommandMain(args)
+	case "logs":
+		cmd.LogsCommandMain(args)
+	case "jobs":
+		cmd.JobsCommandMain(args)
+	case "stop":
+		cmd.StopCommandMain(args)
+	case "schedule":
+		cmd.ScheduleCommandMain(args)
+	case "service":
+		cmd.ServiceCommandMain(args)
+	case "serve":
+		cmd.ServeCommandMain(args)
+	case "completion":
+		cmd.CompletionCommandMain(args)
+	case "__complete":
+		cmd.CompleteCommandMain(args)
 	default:
 		fmt.Fprintf(os.Stderr, "\n")
-		fmt.Fprintf(os.Stderr, "  ❌ Error: unknown subcommand '%s'\n", subcommand)
+		internal.Output.Eprintf(internal.StatusError, "  Error: unknown subcommand '%s'\n", subcommand)
 		fmt.Fprintf(os.Stderr, "\n")
 		printUsage()
 		os.Exit(1)
@@ -50,16 +118,30 @@ func printUsage() {
 	fmt.Fprintf(os.Stderr, "           Options:\n")
 	fmt.Fprintf(os.Stderr, "             -v, --verbose              Show the command before executing\n")
 			fmt.Fprintf(os.Stderr, "             -s, --set <var>=<value>     Set variable values (can be used multiple times)\n")
+	fmt.Fprintf(os.Stderr, "             --set-json <var>=<json>    Set a list or map variable\n")
+	fmt.Fprintf(os.Stderr, "             --force                    Run steps outside their allowed window\n")
+	fmt.Fprintf(os.Stderr, "             --log-file <path>          Write the run's output to path instead of .linea/logs\n")
+	fmt.Fprintf(os.Stderr, "             --keep-going               Run every step of every file; exit with the failure count\n")
+	fmt.Fprintf(os.Stderr, "             --summary <text|json|none> Per-step timing summary (default: text after multi-step runs)\n")
+	fmt.Fprintf(os.Stderr, "             --progress                 Show the step running with its elapsed time\n")
+	fmt.Fprintf(os.Stderr, "             --progress-fd <n>          Write NDJSON progress events to file descriptor n\n")
+	fmt.Fprintf(os.Stderr, "             --progress-file <path>     Append NDJSON progress events to a file or named pipe\n")
+	fmt.Fprintf(os.Stderr, "             --output <text|json|yaml>  Print a machine-readable report of the run\n")
+	fmt.Fprintf(os.Stderr, "             --capture                  Include each step's stdout/stderr in the report\n")
 	fmt.Fprintf(os.Stderr, "\n")
 	fmt.Fprintf(os.Stderr, "           Examples:\n")
 	fmt.Fprintf(os.Stderr, "             linea run config.yml\n")
 	fmt.Fprintf(os.Stderr, "             linea run -v config.yml\n")
+	fmt.Fprintf(os.Stderr, "             linea run --keep-going build.yml test.yml\n")
 			fmt.Fprintf(os.Stderr, "             linea run config.yml -s name=\"John\" -s age=30\n")
 	fmt.Fprintf(os.Stderr, "\n")
 	fmt.Fprintf(os.Stderr, "    test   Dry-run the command (print without executing)\n")
 	fmt.Fprintf(os.Stderr, "           \n")
 	fmt.Fprintf(os.Stderr, "           Options:\n")
 			fmt.Fprintf(os.Stderr, "             -s, --set <var>=<value>     Set variable values for testing\n")
+	fmt.Fprintf(os.Stderr, "             --resolve                  Show where each variable's value comes from\n")
+	fmt.Fprintf(os.Stderr, "             --output <text|json|yaml>  Print the built commands as a machine-readable report\n")
+	fmt.Fprintf(os.Stderr, "             --run-asserts              Run the workflow and report its type: assert steps\n")
 	fmt.Fprintf(os.Stderr, "\n")
 	fmt.Fprintf(os.Stderr, "           Examples:\n")
 	fmt.Fprintf(os.Stderr, "             linea test config.yml\n")
@@ -67,22 +149,253 @@ func printUsage() {
 	fmt.Fprintf(os.Stderr, "\n")
 	fmt.Fprintf(os.Stderr, "    help   Display information about the command defined in YAML\n")
 	fmt.Fprintf(os.Stderr, "           \n")
+	fmt.Fprintf(os.Stderr, "           Options:\n")
+	fmt.Fprintf(os.Stderr, "             --output <text|json|yaml>  Print the description as a machine-readable report\n")
+	fmt.Fprintf(os.Stderr, "\n")
 	fmt.Fprintf(os.Stderr, "           Examples:\n")
 	fmt.Fprintf(os.Stderr, "             linea help config.yml\n")
 	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "    validate  Check workflow files against the workflow schema\n")
+	fmt.Fprintf(os.Stderr, "           \n")
+	fmt.Fprintf(os.Stderr, "           Examples:\n")
+	fmt.Fprintf(os.Stderr, "             linea validate deploy.yml\n")
+	fmt.Fprintf(os.Stderr, "             linea validate .linea/workflows\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "    fmt    Rewrite workflow files in canonical form (key order, indentation, quoting)\n")
+	fmt.Fprintf(os.Stderr, "           \n")
+	fmt.Fprintf(os.Stderr, "           Options:\n")
+	fmt.Fprintf(os.Stderr, "             --check                    Report unformatted files and exit 1 (for CI)\n")
+	fmt.Fprintf(os.Stderr, "             --upgrade                  Rewrite files to the latest workflow format version\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "    lint   Check workflow files for best-practice problems (unused variables, typos, ...)\n")
+	fmt.Fprintf(os.Stderr, "           \n")
+	fmt.Fprintf(os.Stderr, "           Options:\n")
+	fmt.Fprintf(os.Stderr, "             --fix                      Correct fixable findings in place\n")
+	fmt.Fprintf(os.Stderr, "             --disable <rule,...>       Skip rules by ID or name\n")
+	fmt.Fprintf(os.Stderr, "             --rules                    List the lint rules\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "    list   List workflows in .linea/workflows with descriptions and required variables\n")
+	fmt.Fprintf(os.Stderr, "           \n")
+	fmt.Fprintf(os.Stderr, "           Options:\n")
+	fmt.Fprintf(os.Stderr, "             -g, --global               List global workflows instead\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "    global Manage workflows available from any directory\n")
+	fmt.Fprintf(os.Stderr, "           \n")
+	fmt.Fprintf(os.Stderr, "           Subcommands:\n")
+	fmt.Fprintf(os.Stderr, "             install <yaml-file>  Copy a workflow into the global workflows directory\n")
+	fmt.Fprintf(os.Stderr, "             list                 List global workflows\n")
+	fmt.Fprintf(os.Stderr, "           \n")
+	fmt.Fprintf(os.Stderr, "           Examples:\n")
+	fmt.Fprintf(os.Stderr, "             linea global install cleanup.yml\n")
+	fmt.Fprintf(os.Stderr, "\n")
 	fmt.Fprintf(os.Stderr, "    init   Initialize a new workflow YAML file with template\n")
 	fmt.Fprintf(os.Stderr, "           \n")
+	fmt.Fprintf(os.Stderr, "           Options:\n")
+	fmt.Fprintf(os.Stderr, "             -i, --interactive          Ask for command, args, variables, and description\n")
+	fmt.Fprintf(os.Stderr, "             -p, --preset <name>        Start from a ready-made workflow, e.g. docker-build\n")
+	fmt.Fprintf(os.Stderr, "             --list-presets             List the presets\n")
+	fmt.Fprintf(os.Stderr, "\n")
 	fmt.Fprintf(os.Stderr, "           Examples:\n")
 	fmt.Fprintf(os.Stderr, "             linea init workflow.yml\n")
 	fmt.Fprintf(os.Stderr, "             linea init my-commands.yml\n")
+	fmt.Fprintf(os.Stderr, "             linea init --interactive deploy.yml\n")
+	fmt.Fprintf(os.Stderr, "             linea init --preset docker-build\n")
+	fmt.Fprintf(os.Stderr, "             linea init build.yml --from-command \"docker build -t myapp:{tag} .\"\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "    import Convert a workflow of another tool into a linea workflow\n")
+	fmt.Fprintf(os.Stderr, "           \n")
+	fmt.Fprintf(os.Stderr, "           Formats:\n")
+	fmt.Fprintf(os.Stderr, "             gha <workflow.yml>         The run: steps of a GitHub Actions workflow\n")
+	fmt.Fprintf(os.Stderr, "             make <Makefile>            One workflow per target of a Makefile\n")
+	fmt.Fprintf(os.Stderr, "           \n")
+	fmt.Fprintf(os.Stderr, "           Options:\n")
+	fmt.Fprintf(os.Stderr, "             --job <id>                 gha: import only this job\n")
+	fmt.Fprintf(os.Stderr, "             --target <name>            make: import this target and its dependencies\n")
+	fmt.Fprintf(os.Stderr, "             -o, --output <path>        Where to write the workflow (a directory for make), - for stdout\n")
+	fmt.Fprintf(os.Stderr, "             -f, --force                Overwrite existing files\n")
+	fmt.Fprintf(os.Stderr, "           \n")
+	fmt.Fprintf(os.Stderr, "           Examples:\n")
+	fmt.Fprintf(os.Stderr, "             linea import gha .github/workflows/ci.yml\n")
+	fmt.Fprintf(os.Stderr, "             linea import make ./Makefile\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "    export Write a workflow as a bash or PowerShell script that runs without linea,\n")
+	fmt.Fprintf(os.Stderr, "           or as a GitHub Actions or GitLab CI pipeline\n")
+	fmt.Fprintf(os.Stderr, "           \n")
+	fmt.Fprintf(os.Stderr, "           Options:\n")
+	fmt.Fprintf(os.Stderr, "             --format <format>          bash, powershell, gha, or gitlab\n")
+	fmt.Fprintf(os.Stderr, "             --parameterized            Make the variables parameters instead of baking them in\n")
+	fmt.Fprintf(os.Stderr, "             -s, --set <var>=<value>    Bake in a variable value\n")
+	fmt.Fprintf(os.Stderr, "             --matrix <var>=<v1>,<v2>   Run the CI job once per value of a variable\n")
+	fmt.Fprintf(os.Stderr, "             -o, --output <file>        Write to a file instead of stdout\n")
+	fmt.Fprintf(os.Stderr, "           \n")
+	fmt.Fprintf(os.Stderr, "           Examples:\n")
+	fmt.Fprintf(os.Stderr, "             linea export deploy --format bash -o deploy.sh\n")
+	fmt.Fprintf(os.Stderr, "             linea export deploy --format powershell --parameterized -o deploy.ps1\n")
+	fmt.Fprintf(os.Stderr, "             linea export test --format gha --matrix go=1.22,1.23 -o .github/workflows/test.yml\n")
 	fmt.Fprintf(os.Stderr, "\n")
 	fmt.Fprintf(os.Stderr, "    app    Manage Linea Apps\n")
 	fmt.Fprintf(os.Stderr, "           \n")
 	fmt.Fprintf(os.Stderr, "           Subcommands:\n")
 	fmt.Fprintf(os.Stderr, "             create <app-name>    Create a new Linea App structure\n")
+	fmt.Fprintf(os.Stderr, "             templates            List the templates of app create\n")
+	fmt.Fprintf(os.Stderr, "             doctor [dir]         Check an app's workflows, scripts, and setup\n")
+	fmt.Fprintf(os.Stderr, "           \n")
+	fmt.Fprintf(os.Stderr, "           Options:\n")
+	fmt.Fprintf(os.Stderr, "             -t, --template <name>      Template of the app: default, node, docker, ansible-like,\n")
+	fmt.Fprintf(os.Stderr, "                                        or one of your own (default: default)\n")
+	fmt.Fprintf(os.Stderr, "             -s, --set <name=value>     Value of a placeholder of the template\n")
+	fmt.Fprintf(os.Stderr, "             -y, --yes                  Use the defaults of placeholders instead of asking\n")
 	fmt.Fprintf(os.Stderr, "           \n")
 	fmt.Fprintf(os.Stderr, "           Examples:\n")
 	fmt.Fprintf(os.Stderr, "             linea app create my-app\n")
+	fmt.Fprintf(os.Stderr, "             linea app create api --template node\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "    sh     Run a lineash script (.lnsh) with workflows as commands\n")
+	fmt.Fprintf(os.Stderr, "           \n")
+	fmt.Fprintf(os.Stderr, "           Examples:\n")
+	fmt.Fprintf(os.Stderr, "             linea sh scripts/script.lnsh\n")
+	fmt.Fprintf(os.Stderr, "             linea sh scripts/deploy.lnsh arg1 arg2\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "    rerun  Re-run a recorded run with its variables\n")
+	fmt.Fprintf(os.Stderr, "           \n")
+	fmt.Fprintf(os.Stderr, "           Options:\n")
+	fmt.Fprintf(os.Stderr, "             -s, --set <var>=<value>     Override a recorded variable\n")
+	fmt.Fprintf(os.Stderr, "             -i, --interactive          Edit the variables before re-running\n")
+	fmt.Fprintf(os.Stderr, "           \n")
+	fmt.Fprintf(os.Stderr, "           Examples:\n")
+	fmt.Fprintf(os.Stderr, "             linea rerun last\n")
+	fmt.Fprintf(os.Stderr, "             linea rerun 20260302T101500-3fa2 -s env=staging\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "    history  List past runs with their status and duration\n")
+	fmt.Fprintf(os.Stderr, "           \n")
+	fmt.Fprintf(os.Stderr, "           Options:\n")
+	fmt.Fprintf(os.Stderr, "             -n, --limit <n>            Show the last n runs (default 20, --all for every run)\n")
+	fmt.Fprintf(os.Stderr, "             --workflow <name>          Only show runs of one workflow\n")
+	fmt.Fprintf(os.Stderr, "             --failed                   Only show failed runs\n")
+	fmt.Fprintf(os.Stderr, "             --json                     Print the runs as JSON\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "    logs   Show the output of a recorded run\n")
+	fmt.Fprintf(os.Stderr, "           \n")
+	fmt.Fprintf(os.Stderr, "           Options:\n")
+	fmt.Fprintf(os.Stderr, "             --path                     Print the log file's path instead\n")
+	fmt.Fprintf(os.Stderr, "           \n")
+	fmt.Fprintf(os.Stderr, "           Examples:\n")
+	fmt.Fprintf(os.Stderr, "             linea logs last\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "    jobs   List the background jobs started by workflows (background: true)\n")
+	fmt.Fprintf(os.Stderr, "           \n")
+	fmt.Fprintf(os.Stderr, "           Options:\n")
+	fmt.Fprintf(os.Stderr, "             --json                     Print the jobs as JSON\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "    stop   Stop background jobs\n")
+	fmt.Fprintf(os.Stderr, "           \n")
+	fmt.Fprintf(os.Stderr, "           Options:\n")
+	fmt.Fprintf(os.Stderr, "             --all                      Stop every background job of the project\n")
+	fmt.Fprintf(os.Stderr, "             --timeout <duration>       Wait before killing a job (default 5s)\n")
+	fmt.Fprintf(os.Stderr, "           \n")
+	fmt.Fprintf(os.Stderr, "           Examples:\n")
+	fmt.Fprintf(os.Stderr, "             linea stop dev-server\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "    schedule  Run workflows on cron schedules\n")
+	fmt.Fprintf(os.Stderr, "           \n")
+	fmt.Fprintf(os.Stderr, "           Subcommands:\n")
+	fmt.Fprintf(os.Stderr, "             add <workflow>       Register a workflow (--cron overrides its schedule: field)\n")
+	fmt.Fprintf(os.Stderr, "             list                 List schedules and their next run\n")
+	fmt.Fprintf(os.Stderr, "             remove <id>          Unregister a schedule\n")
+	fmt.Fprintf(os.Stderr, "             start                Run scheduled workflows until interrupted\n")
+	fmt.Fprintf(os.Stderr, "           \n")
+	fmt.Fprintf(os.Stderr, "           Examples:\n")
+	fmt.Fprintf(os.Stderr, "             linea schedule add backup --cron \"0 2 * * *\"\n")
+	fmt.Fprintf(os.Stderr, "             linea schedule start\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "    service  Run the scheduler as a systemd unit, launchd agent, or Windows task\n")
+	fmt.Fprintf(os.Stderr, "           \n")
+	fmt.Fprintf(os.Stderr, "           Subcommands:\n")
+	fmt.Fprintf(os.Stderr, "             install              Start the scheduler at boot, restarting it on failure\n")
+	fmt.Fprintf(os.Stderr, "             start                Start the installed service\n")
+	fmt.Fprintf(os.Stderr, "             stop                 Stop the service\n")
+	fmt.Fprintf(os.Stderr, "             uninstall            Stop and remove the service\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "    serve  Serve an HTTP API to list, run, and follow workflows\n")
+	fmt.Fprintf(os.Stderr, "           \n")
+	fmt.Fprintf(os.Stderr, "           Options:\n")
+	fmt.Fprintf(os.Stderr, "             --addr <host:port>         Address to listen on (default 127.0.0.1:8080)\n")
+	fmt.Fprintf(os.Stderr, "             --token <token>            Require a bearer token (default $LINEA_SERVE_TOKEN)\n")
+	fmt.Fprintf(os.Stderr, "             --ui                       Serve the web UI at /\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "    stats  Summarize local run history (most-run workflows, durations, failure rates)\n")
+	fmt.Fprintf(os.Stderr, "           \n")
+	fmt.Fprintf(os.Stderr, "           Options:\n")
+	fmt.Fprintf(os.Stderr, "             --days <n>                 Only include runs from the last n days\n")
+	fmt.Fprintf(os.Stderr, "             --json                     Print the summary as JSON\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "    doctor Check the installation and linea executable discovery\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "    verify-install  Smoke-test the binary: run a workflow and a lineash script in a temporary app\n")
+	fmt.Fprintf(os.Stderr, "           \n")
+	fmt.Fprintf(os.Stderr, "           Options:\n")
+	fmt.Fprintf(os.Stderr, "             --keep                     Keep the temporary app for inspection\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "    package  Pack a directory of workflows and linea-package.yml into a .tar.gz\n")
+	fmt.Fprintf(os.Stderr, "    install  Install a package from a file, a URL, or the registry (name[@version])\n")
+	fmt.Fprintf(os.Stderr, "    uninstall  Remove an installed package\n")
+	fmt.Fprintf(os.Stderr, "    search   Search the registry for packages\n")
+	fmt.Fprintf(os.Stderr, "    publish  Upload a package to the registry (--bump major|minor|patch, --dry-run)\n")
+	fmt.Fprintf(os.Stderr, "           \n")
+	fmt.Fprintf(os.Stderr, "           Examples:\n")
+	fmt.Fprintf(os.Stderr, "             linea package ./docker-tools\n")
+	fmt.Fprintf(os.Stderr, "             linea install k8s-deploy@1.2.0\n")
+	fmt.Fprintf(os.Stderr, "             linea publish --bump patch ./docker-tools\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "    sign   Pin workflow files to their reviewed version with a checksum or signature\n")
+	fmt.Fprintf(os.Stderr, "           \n")
+	fmt.Fprintf(os.Stderr, "           Examples:\n")
+	fmt.Fprintf(os.Stderr, "             LINEA_SIGNING_KEY=... linea sign .linea/workflows/deploy.yml\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "    verify Check workflow files against their signatures (linea sign)\n")
+	fmt.Fprintf(os.Stderr, "           \n")
+	fmt.Fprintf(os.Stderr, "           Examples:\n")
+	fmt.Fprintf(os.Stderr, "             linea verify .linea/workflows\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "    secret Manage secrets in the encrypted .linea/secrets.enc file\n")
+	fmt.Fprintf(os.Stderr, "           \n")
+	fmt.Fprintf(os.Stderr, "           Subcommands:\n")
+	fmt.Fprintf(os.Stderr, "             set <name> [value]   Store a secret (reads stdin if value is omitted)\n")
+	fmt.Fprintf(os.Stderr, "             get <name>           Print a stored secret\n")
+	fmt.Fprintf(os.Stderr, "             list                 List stored secret names\n")
+	fmt.Fprintf(os.Stderr, "           \n")
+	fmt.Fprintf(os.Stderr, "           Examples:\n")
+	fmt.Fprintf(os.Stderr, "             linea secret set db_password\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "    cache  Manage the shared download cache exposed to workflows as {cache_dir}\n")
+	fmt.Fprintf(os.Stderr, "           \n")
+	fmt.Fprintf(os.Stderr, "           Subcommands:\n")
+	fmt.Fprintf(os.Stderr, "             ls                   List cache keys with their size\n")
+	fmt.Fprintf(os.Stderr, "             clean [key...]       Remove keys (--older-than 30d for old keys only)\n")
+	fmt.Fprintf(os.Stderr, "             clear [workflow...]  Run the steps with cache: again\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "    config Manage the global config file\n")
+	fmt.Fprintf(os.Stderr, "           \n")
+	fmt.Fprintf(os.Stderr, "           Subcommands:\n")
+	fmt.Fprintf(os.Stderr, "             get <key>            Print a setting\n")
+	fmt.Fprintf(os.Stderr, "             set <key> <value>    Change a setting\n")
+	fmt.Fprintf(os.Stderr, "             list                 List all settings\n")
+	fmt.Fprintf(os.Stderr, "           \n")
+	fmt.Fprintf(os.Stderr, "           Examples:\n")
+	fmt.Fprintf(os.Stderr, "             linea config set verbose true\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "    completion  Print a shell completion script (bash, zsh, fish, powershell)\n")
+	fmt.Fprintf(os.Stderr, "           \n")
+	fmt.Fprintf(os.Stderr, "           Examples:\n")
+	fmt.Fprintf(os.Stderr, "             source <(linea completion bash)\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "  GLOBAL OPTIONS:\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "    --theme <name>   Style of status messages: classic (default), minimal, or ci\n")
+	fmt.Fprintf(os.Stderr, "    --shell <name>   Windows shell for built-ins: cmd (default), powershell, or pwsh\n")
+	fmt.Fprintf(os.Stderr, "    --quiet          Only show command output, warnings, and errors\n")
+	fmt.Fprintf(os.Stderr, "    --no-color       Disable colored output (also set by NO_COLOR)\n")
+	fmt.Fprintf(os.Stderr, "    --strict         Refuse unknown keys and steps without a command\n")
 	fmt.Fprintf(os.Stderr, "\n")
 	fmt.Fprintf(os.Stderr, "  For more information, visit: https://github.com/marcuwynu23/linea\n")
 	fmt.Fprintf(os.Stderr, "\n")
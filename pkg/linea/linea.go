@@ -0,0 +1,389 @@
+// Package linea loads and runs linea workflows from Go programs, with the engine of the
+// linea command: the same parsing, variable resolution, step types, policies, and
+// failure handling as linea run
+//
+//	wf, err := linea.Load(".linea/workflows/deploy.yml")
+//	if err != nil {
+//		return err
+//	}
+//	result, err := wf.Run(ctx, linea.Options{
+//		Vars:   map[string]string{"env": "staging"},
+//		Stdout: os.Stdout,
+//		Stderr: os.Stderr,
+//		Middleware: []linea.Middleware{{
+//			AfterStep: func(e linea.StepEvent) { log.Printf("%s: %s", e.Name, e.Status) },
+//		}},
+//	})
+//
+// The API of this package is kept stable; the engine behind it lives in linea/internal
+// and may change between releases. Runs share the state of the process, such as its
+// cancellation and step outputs, so a program runs one workflow at a time: Run waits for
+// a run in progress in another goroutine to finish
+package linea
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"linea/internal"
+)
+
+// running is held by the run in progress, as the engine runs one workflow at a time
+var running sync.Mutex
+
+// Step statuses, as reported by StepResult and StepEvent
+const (
+	StatusSuccess = internal.StepSucceeded
+	StatusFailed  = internal.StepFailed
+	StatusSkipped = internal.StepSkipped // when: was false, or an on_failure: step after no failure
+	StatusNotRun  = internal.StepNotRun  // An earlier step failed
+)
+
+// Output streams, as passed to Middleware.OnOutputLine
+const (
+	Stdout = internal.StreamStdout
+	Stderr = internal.StreamStderr
+)
+
+// Workflow is a parsed workflow file
+type Workflow struct {
+	Path        string
+	Name        string
+	Description string // The metadata document's description, or the file's leading comment
+	Steps       []Step
+
+	configs []*internal.CommandConfig
+}
+
+// Step is a step of a workflow as written, before variable substitution
+type Step struct {
+	Name        string
+	Description string
+	Group       string // The group: the step is in
+	Command     string // Empty for a type: step
+	Type        string // Built-in step type, such as healthcheck
+	Args        []string
+	Variables   map[string]string
+}
+
+// Problem is an issue linea validate reports
+type Problem struct {
+	Line    int // 0 when unknown, as in .json and .toml files
+	Message string
+}
+
+// Resolved is the command of a step after variable substitution
+type Resolved struct {
+	Index     int // 1-based
+	Name      string
+	Command   []string // Secret values are masked
+	Variables []Variable
+	Error     string // Why the command could not be built
+}
+
+// Variable is how a placeholder of a step was resolved
+type Variable struct {
+	Reference string // As written, e.g. {name} or $name
+	Name      string
+	Value     string // Secret values are masked
+	Source    string // Where Value comes from; empty if the placeholder is unresolved
+}
+
+// Load parses a workflow file. A path that is not a file is looked up as a workflow name
+// like linea run does: in .linea/workflows, then the global workflows directory
+func Load(path string) (*Workflow, error) {
+	resolved, err := internal.ResolveWorkflowPath(path)
+	if err != nil {
+		return nil, err
+	}
+	configs, err := internal.ParseMultiYAML(resolved)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Workflow{
+		Path:        resolved,
+		Name:        internal.WorkflowName(resolved),
+		Description: internal.IndexWorkflowFile(resolved).Description,
+		configs:     configs,
+	}
+	for _, config := range configs {
+		w.Steps = append(w.Steps, Step{
+			Name:        config.Name,
+			Description: config.Description,
+			Group:       config.Group,
+			Command:     config.Command,
+			Type:        config.Type,
+			Args:        append([]string(nil), config.Args...),
+			Variables:   copyVars(config.Variables),
+		})
+	}
+	return w, nil
+}
+
+// Validate returns the problems linea validate reports for the workflow, with vars
+// treated as set like -s name=value
+func (w *Workflow) Validate(vars map[string]string) ([]Problem, error) {
+	problems, err := internal.ValidateWorkflowFile(w.Path, vars)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Problem, len(problems))
+	for i, problem := range problems {
+		result[i] = Problem{Line: problem.Line, Message: problem.Message}
+	}
+	return result, nil
+}
+
+// Resolve returns the command of every step with vars set, without running anything,
+// like linea test --resolve. It stops at the first step that cannot be built, returning
+// the steps up to it and its error
+func (w *Workflow) Resolve(vars map[string]string) ([]Resolved, error) {
+	configs, err := internal.ExpandForeach(w.configs, vars)
+	if err != nil {
+		return nil, err
+	}
+	results, err := internal.DryRunStepsReport(configs, vars, true)
+	resolved := make([]Resolved, len(results))
+	for i, result := range results {
+		resolved[i] = Resolved{Index: result.Index, Name: result.Name, Command: result.Command, Error: result.Error}
+		for _, v := range result.Variables {
+			resolved[i].Variables = append(resolved[i].Variables, Variable{Reference: v.Reference, Name: v.Name, Value: v.Value, Source: v.Source})
+		}
+	}
+	return resolved, err
+}
+
+// Options configure a run
+type Options struct {
+	Vars      map[string]string // Like -s name=value
+	Stdout    io.Writer         // Receives the commands' output; nil discards it
+	Stderr    io.Writer
+	Capture   bool // Also record each step's output in its StepResult
+	KeepGoing bool // Run the remaining steps after a failure, like --keep-going
+
+	// Middleware is called around every step, in order
+	Middleware []Middleware
+
+	// GracePeriod is how long the running command has to exit once ctx is done before it
+	// is killed; 0 is 10 seconds, like linea run
+	GracePeriod time.Duration
+}
+
+// Middleware adds behavior around the steps of a run, like the engine's own progress
+// events. Any function may be nil. BeforeStep and AfterStep are called from the goroutine
+// that called Run
+type Middleware struct {
+	// BeforeStep is called before a step's command runs. An error fails the step
+	// without running it, and the run goes on as after any failed step
+	BeforeStep func(step StepEvent) error
+
+	// AfterStep is called after every step, including those skipped or not run, with
+	// its outcome
+	AfterStep func(step StepEvent)
+
+	// OnOutputLine is called with each line a command writes to stream, Stdout or Stderr,
+	// without its line ending and with secret values masked. Calls are made one at a
+	// time, from the goroutines that copy the command's output
+	OnOutputLine func(step StepEvent, stream, line string)
+}
+
+// StepEvent describes a step for Middleware
+type StepEvent struct {
+	Index    int // 1-based
+	Steps    int // Number of steps in the workflow
+	Name     string
+	Group    string
+	Command  []string // Secret values are masked; empty if it was not built
+	Status   string   // AfterStep only
+	ExitCode int      // AfterStep only
+	Duration time.Duration
+	Error    string
+}
+
+// Result is the outcome of a run
+type Result struct {
+	Status   string // StatusSuccess or StatusFailed
+	ExitCode int    // The exit code linea run would exit with
+	Duration time.Duration
+	Steps    []StepResult
+}
+
+// StepResult is the outcome of one step
+type StepResult struct {
+	Index    int // 1-based
+	Name     string
+	Group    string
+	Status   string
+	Command  []string // Secret values are masked; empty if it was not built
+	ExitCode int
+	Error    string
+	Duration time.Duration
+	Stdout   string // With Options.Capture or the step's capture: field
+	Stderr   string
+}
+
+// Run runs the workflow's steps in order like linea run: after a failure only after: and
+// on_failure: steps run, unless KeepGoing is set. Maintenance windows and enforced command
+// policies are checked first, and a workflow with lock: true waits for or fails on a run
+// of it in progress, as set by its lock_wait:. A run of another workflow in progress in the
+// same program is waited for first. When ctx is done, the running command is sent SIGTERM (and
+// killed after the grace period) and the remaining steps are not run. The error is that
+// of the first failed step, or wraps ctx.Err() for a cancelled run; the Result is
+// returned either way once steps have run
+func (w *Workflow) Run(ctx context.Context, opts Options) (*Result, error) {
+	running.Lock()
+	defer running.Unlock()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	configs, err := internal.ExpandForeach(w.configs, opts.Vars)
+	if err != nil {
+		return nil, err
+	}
+	enforced, err := w.check(configs, opts.Vars)
+	if err != nil {
+		return nil, err
+	}
+	if enforced != nil {
+		defer internal.EnforcePolicies(enforced)()
+	}
+	if err := internal.EnsureSharedCacheDir(); err != nil {
+		return nil, err
+	}
+
+	grace := opts.GracePeriod
+	if grace == 0 {
+		grace = internal.DefaultGracePeriod
+	}
+	stop := internal.CancelOnDone(ctx, grace)
+	defer stop()
+
+	stdout, stderr := opts.Stdout, opts.Stderr
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	if stderr == nil {
+		stderr = io.Discard
+	}
+	lock, err := internal.LockWorkflow(w.Path, configs, stderr)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Unlock()
+	start := time.Now()
+	results, err := internal.RunStepsReport(configs, opts.Vars, internal.ReportOptions{
+		Stdout:    stdout,
+		Stderr:    stderr,
+		Capture:   opts.Capture,
+		KeepGoing: opts.KeepGoing,
+		Workflow:  w.Name,
+
+		Middleware: engineMiddleware(opts.Middleware),
+	})
+	if internal.IsCancelled(err) && ctx.Err() != nil {
+		err = fmt.Errorf("%s: run cancelled: %w", w.Name, ctx.Err())
+	}
+
+	result := &Result{Status: StatusSuccess, ExitCode: internal.ExitCode(err), Duration: time.Since(start)}
+	if err != nil {
+		result.Status = StatusFailed
+	}
+	for _, step := range results {
+		s := StepResult{
+			Index:    step.Index,
+			Name:     step.Name,
+			Group:    step.Group,
+			Status:   step.Status,
+			Command:  step.Command,
+			ExitCode: step.ExitCode,
+			Error:    step.Error,
+			Duration: time.Duration(step.DurationMs) * time.Millisecond,
+		}
+		if step.Stdout != nil {
+			s.Stdout, s.Stderr = *step.Stdout, *step.Stderr
+		}
+		result.Steps = append(result.Steps, s)
+	}
+	return result, err
+}
+
+// check refuses a run of configs, the steps of the workflow with their foreach: expanded,
+// that linea run would refuse before any step: outside a maintenance window, or with a
+// step an enforced policy does not allow. It returns the policies the run enforces
+func (w *Workflow) check(configs []*internal.CommandConfig, vars map[string]string) ([]*internal.Policy, error) {
+	errs, err := internal.CheckTimeWindows(configs, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+
+	policies, err := internal.LoadPolicies()
+	if err != nil {
+		return nil, err
+	}
+	if len(policies) == 0 || !internal.PoliciesEnforced(policies, false) {
+		return nil, nil
+	}
+	if violations := internal.AuditPolicies(policies, configs, vars); len(violations) > 0 {
+		return nil, violations[0]
+	}
+	return policies, nil
+}
+
+// engineMiddleware turns middleware into that of the engine
+func engineMiddleware(middleware []Middleware) []internal.Middleware {
+	chain := make([]internal.Middleware, 0, len(middleware))
+	for _, m := range middleware {
+		m := m
+		var engine internal.Middleware
+		if m.BeforeStep != nil {
+			engine.BeforeStep = func(step internal.StepInfo) error {
+				return m.BeforeStep(stepEvent(step))
+			}
+		}
+		if m.AfterStep != nil {
+			engine.AfterStep = func(step internal.StepInfo, result internal.StepResult) {
+				e := stepEvent(step)
+				e.Command, e.Status, e.ExitCode, e.Error = result.Command, result.Status, result.ExitCode, result.Error
+				e.Duration = time.Duration(result.DurationMs) * time.Millisecond
+				m.AfterStep(e)
+			}
+		}
+		if m.OnOutputLine != nil {
+			engine.OnOutputLine = func(step internal.StepInfo, stream, line string) {
+				m.OnOutputLine(stepEvent(step), stream, line)
+			}
+		}
+		chain = append(chain, engine)
+	}
+	return chain
+}
+
+// stepEvent describes a step of the engine
+func stepEvent(step internal.StepInfo) StepEvent {
+	return StepEvent{
+		Index:   step.Index,
+		Steps:   step.Steps,
+		Name:    step.Config.Name,
+		Group:   step.Config.Group,
+		Command: step.Command,
+	}
+}
+
+// copyVars returns a copy of a variables map, nil for an empty one
+func copyVars(vars map[string]string) map[string]string {
+	if len(vars) == 0 {
+		return nil
+	}
+	copied := make(map[string]string, len(vars))
+	for k, v := range vars {
+		copied[k] = v
+	}
+	return copied
+}
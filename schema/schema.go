@@ -0,0 +1,9 @@
+// Package schema publishes the JSON Schema of Linea workflow files
+package schema
+
+import _ "embed"
+
+// Workflow is the JSON Schema (draft-07) describing a single workflow document
+//
+//go:embed workflow.schema.json
+var Workflow []byte
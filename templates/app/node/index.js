@@ -0,0 +1,7 @@
+const http = require("http");
+
+const port = process.argv[2] || process.env.PORT || 3000;
+
+http
+  .createServer((req, res) => res.end("Hello from {{app_name}}\n"))
+  .listen(port, () => console.log(`{{app_name}} listening on port ${port}`));
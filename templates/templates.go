@@ -0,0 +1,15 @@
+// Package templates holds the project templates built into linea
+package templates
+
+import "embed"
+
+// App holds the built-in templates of `linea app create --template`, one directory per
+// template under app/, each with a template.yml manifest
+//
+//go:embed all:app
+var App embed.FS
+
+// Presets holds the workflows of `linea init --preset`, as presets/<name>.yml
+//
+//go:embed presets
+var Presets embed.FS
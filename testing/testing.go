@@ -0,0 +1,276 @@
+// Package testing runs linea workflows in Go tests with their commands faked, so that
+// teams maintaining many workflows can check what each one would run: the commands after
+// variable substitution, how steps react to failures, and what captured output reaches
+// the steps after it
+//
+//	func TestDeploy(t *testing.T) {
+//		wf := lineatest.Load(t, "../.linea/workflows/deploy.yml").Set("env", "staging")
+//		wf.Respond("kubectl rollout status", lineatest.Response{ExitCode: 1})
+//		result := wf.Run(t)
+//		result.AssertExitCode(t, 1)
+//		result.AssertCommand(t, "apply", "kubectl apply -f k8s/staging")
+//		result.AssertStatus(t, "rollback", "success")
+//	}
+//
+// The package is usually imported as lineatest, next to the standard testing package
+package testing
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"linea/internal"
+)
+
+// TB is the part of *testing.T and *testing.B that the helpers use
+type TB interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+	Fatalf(format string, args ...interface{})
+}
+
+// Response is what a faked command writes and exits with
+type Response struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+// Workflow is a workflow file loaded for a test. Its steps never run for real: each
+// command gets the Response of the longest command it starts with (see Respond), or
+// succeeds without output
+type Workflow struct {
+	Path      string
+	KeepGoing bool // Run every step after a failure, like linea run --keep-going
+
+	vars      map[string]string
+	responses map[string]Response
+	chaos     *internal.ChaosOptions
+	configs   []*internal.CommandConfig
+}
+
+// Load parses the workflow file at path, failing the test if it cannot be parsed
+func Load(t TB, path string) *Workflow {
+	t.Helper()
+	configs, err := internal.ParseMultiYAML(path)
+	if err != nil {
+		t.Fatalf("failed to load workflow %s: %v", path, err)
+	}
+	return &Workflow{Path: path, vars: map[string]string{}, responses: map[string]Response{}, configs: configs}
+}
+
+// Set gives a variable a value, like -s name=value
+func (w *Workflow) Set(name, value string) *Workflow {
+	w.vars[name] = value
+	return w
+}
+
+// Respond fakes the commands that start with command, such as "git push" or
+// "healthcheck" for type: healthcheck steps. Commands are compared as linea prints them
+// with POSIX quoting, e.g. `echo 'hello world'`
+func (w *Workflow) Respond(command string, response Response) *Workflow {
+	w.responses[command] = response
+	return w
+}
+
+// Chaos injects random faults into the faked steps, like linea run --chaos, e.g.
+// "fail=20%,seed=1"; set a seed so the test fails the same steps every time
+func (w *Workflow) Chaos(t TB, spec string) *Workflow {
+	t.Helper()
+	opts, err := internal.ParseChaos(spec)
+	if err != nil {
+		t.Fatalf("invalid chaos options: %v", err)
+	}
+	w.chaos = &opts
+	return w
+}
+
+// Commands returns the command of every step after variable substitution, without
+// running anything, like linea test; a step that cannot be built fails the test
+func (w *Workflow) Commands(t TB) []string {
+	t.Helper()
+	w.validate(t)
+	results, err := internal.DryRunStepsReport(w.configs, w.vars, false)
+	if err != nil {
+		t.Fatalf("workflow %s: %v", w.Path, err)
+	}
+	commands := make([]string, len(results))
+	for i, result := range results {
+		commands[i] = internal.ShellQuote(result.Command, internal.QuotePOSIX)
+	}
+	return commands
+}
+
+// Run runs the workflow with its commands faked, failing the test if the workflow is not
+// valid. Steps run as with linea run: after a failure only after: and on_failure: steps
+// run, unless KeepGoing is set
+func (w *Workflow) Run(t TB) *Result {
+	t.Helper()
+	w.validate(t)
+	var runner internal.Runner = fakeRunner{responses: w.responses}
+	if w.chaos != nil {
+		runner = internal.NewChaosRunner(runner, *w.chaos)
+	}
+	results, err := internal.RunStepsReport(w.configs, w.vars, internal.ReportOptions{
+		Stdout:    io.Discard,
+		Stderr:    io.Discard,
+		Capture:   true,
+		KeepGoing: w.KeepGoing,
+		Runner:    runner,
+	})
+
+	result := &Result{Err: err, ExitCode: internal.ExitCode(err)}
+	for _, step := range results {
+		s := Step{
+			Index:    step.Index,
+			Name:     step.Name,
+			Group:    step.Group,
+			Status:   step.Status,
+			ExitCode: step.ExitCode,
+			Error:    step.Error,
+		}
+		if step.Command != nil {
+			s.Command = internal.ShellQuote(step.Command, internal.QuotePOSIX)
+		}
+		if step.Stdout != nil {
+			s.Stdout, s.Stderr = *step.Stdout, *step.Stderr
+		}
+		result.Steps = append(result.Steps, s)
+	}
+	return result
+}
+
+// validate fails the test if the workflow has problems linea validate would report
+func (w *Workflow) validate(t TB) {
+	t.Helper()
+	problems, err := internal.ValidateWorkflowFile(w.Path, w.vars)
+	if err != nil {
+		t.Fatalf("failed to validate workflow %s: %v", w.Path, err)
+	}
+	if len(problems) > 0 {
+		lines := make([]string, len(problems))
+		for i, problem := range problems {
+			lines[i] = fmt.Sprintf("  line %d: %s", problem.Line, problem.Message)
+		}
+		t.Fatalf("workflow %s is not valid:\n%s", w.Path, strings.Join(lines, "\n"))
+	}
+}
+
+// fakeRunner answers every command with the response of the longest command it starts with
+type fakeRunner struct {
+	responses map[string]Response
+}
+
+// RunStep writes the response to the command of a step
+func (f fakeRunner) RunStep(step *internal.StepCommand) error {
+	command := internal.ShellQuote(step.Args, internal.QuotePOSIX)
+	var response Response
+	matched := -1
+	for prefix, r := range f.responses {
+		if (command == prefix || strings.HasPrefix(command, prefix+" ")) && len(prefix) > matched {
+			response, matched = r, len(prefix)
+		}
+	}
+	if step.Stdout != nil {
+		io.WriteString(step.Stdout, response.Stdout)
+	}
+	if step.Stderr != nil {
+		io.WriteString(step.Stderr, response.Stderr)
+	}
+	if response.ExitCode != 0 {
+		return &internal.StepExitError{Code: response.ExitCode, Err: fmt.Errorf("exit status %d", response.ExitCode)}
+	}
+	return nil
+}
+
+// Step is the outcome of one step of a faked run
+type Step struct {
+	Index    int // 1-based
+	Name     string
+	Group    string
+	Status   string // success, failed, skipped, or not_run
+	Command  string // With POSIX quoting and secret values masked; empty if it was not built
+	ExitCode int
+	Error    string
+	Stdout   string
+	Stderr   string
+}
+
+// Result is the outcome of a faked run
+type Result struct {
+	Steps    []Step
+	Err      error // The first failure, as linea run reports it
+	ExitCode int   // Exit code of the run: that of the first failed step, or 0
+}
+
+// Step returns the step called name (group/name for a step of a group), or the step at
+// a 1-based index such as "2", failing the test if there is none
+func (r *Result) Step(t TB, name string) Step {
+	t.Helper()
+	for _, step := range r.Steps {
+		if step.Name == name || (step.Group != "" && step.Group+"/"+step.Name == name) {
+			return step
+		}
+	}
+	if index, err := strconv.Atoi(name); err == nil && index >= 1 && index <= len(r.Steps) {
+		return r.Steps[index-1]
+	}
+	t.Fatalf("no step %q in the run", name)
+	return Step{}
+}
+
+// Commands returns the commands of the steps that ran, in order
+func (r *Result) Commands() []string {
+	var commands []string
+	for _, step := range r.Steps {
+		if step.Status == internal.StepSucceeded || step.Status == internal.StepFailed {
+			if step.Command != "" {
+				commands = append(commands, step.Command)
+			}
+		}
+	}
+	return commands
+}
+
+// AssertSuccess fails the test unless every step that ran succeeded
+func (r *Result) AssertSuccess(t TB) {
+	t.Helper()
+	if r.Err != nil {
+		t.Errorf("expected the run to succeed, got: %v", r.Err)
+	}
+}
+
+// AssertExitCode fails the test unless the run exited with code
+func (r *Result) AssertExitCode(t TB, code int) {
+	t.Helper()
+	if r.ExitCode != code {
+		t.Errorf("expected exit code %d, got %d (%v)", code, r.ExitCode, r.Err)
+	}
+}
+
+// AssertStatus fails the test unless the step has the status: success, failed,
+// skipped, or not_run
+func (r *Result) AssertStatus(t TB, name, status string) {
+	t.Helper()
+	if step := r.Step(t, name); step.Status != status {
+		t.Errorf("expected step %q to be %s, got %s", name, status, step.Status)
+	}
+}
+
+// AssertCommand fails the test unless the step's command is want
+func (r *Result) AssertCommand(t TB, name, want string) {
+	t.Helper()
+	if step := r.Step(t, name); step.Command != want {
+		t.Errorf("expected step %q to run %q, got %q", name, want, step.Command)
+	}
+}
+
+// AssertStdout fails the test unless the step's output contains want
+func (r *Result) AssertStdout(t TB, name, want string) {
+	t.Helper()
+	if step := r.Step(t, name); !strings.Contains(step.Stdout, want) {
+		t.Errorf("expected the output of step %q to contain %q, got %q", name, want, step.Stdout)
+	}
+}
@@ -0,0 +1,104 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+const anchorsWorkflow = `name: release
+description: Builds and tests a release
+version: 2
+---
+name: build
+command: go
+subcommand: build
+args: &flags ["-v", "$tags"]
+variables: &vars
+  tags: netgo
+---
+<<: &test
+  command: go
+  subcommand: test
+  variables: *vars
+name: test
+args: *flags
+---
+<<: *test
+name: race
+args: ["-race"]
+variables:
+  tags: race
+`
+
+func TestParseAnchorsAndMergeKeys(t *testing.T) {
+	configs := parseWorkflowFile(t, "release.yml", anchorsWorkflow)
+	if len(configs) != 3 {
+		t.Fatalf("Expected 3 steps (the metadata document is not one), got %d", len(configs))
+	}
+
+	test := configs[1]
+	if test.Command != "go" || test.Subcommand != "test" || strings.Join(test.Args, " ") != "-v $tags" || test.Variables["tags"] != "netgo" {
+		t.Errorf("Expected the test step to get its keys from the anchors, got %+v", test)
+	}
+	race := configs[2]
+	if race.Subcommand != "test" || strings.Join(race.Args, " ") != "-race" || race.Variables["tags"] != "race" {
+		t.Errorf("Expected the race step's own keys to win over the merged ones, got %+v", race)
+	}
+
+	for _, config := range configs {
+		if config.Version != 2 {
+			t.Errorf("Expected step %s to inherit version 2 from the metadata document, got %d", config.Name, config.Version)
+		}
+		if config.Metadata == nil || config.Metadata.Name != "release" {
+			t.Errorf("Expected step %s to carry the metadata, got %+v", config.Name, config.Metadata)
+		}
+	}
+}
+
+func TestValidateAnchorsAndMetadata(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "release.yml")
+	os.WriteFile(path, []byte(anchorsWorkflow), 0644)
+
+	problems, err := internal.ValidateWorkflowFile(path, nil)
+	if err != nil || len(problems) != 0 {
+		t.Errorf("Expected no problems, got %v %v", problems, err)
+	}
+	if info := internal.IndexWorkflowFile(path); info.Description != "Builds and tests a release" {
+		t.Errorf("Expected the description of the metadata document, got %q", info.Description)
+	}
+
+	// Only a leading document is metadata
+	os.WriteFile(path, []byte("version: 2\ncommand: echo\n---\nname: late\n"), 0644)
+	if problems, _ := internal.ValidateWorkflowFile(path, nil); len(problems) == 0 {
+		t.Errorf("Expected a problem for a step without a command after the first document")
+	}
+	os.WriteFile(path, []byte("version: 99\n---\ncommand: echo\n"), 0644)
+	if _, err := internal.ParseMultiYAML(path); err == nil {
+		t.Errorf("Expected an error for an unsupported metadata version")
+	}
+}
+
+func TestFormatKeepsMergeKeys(t *testing.T) {
+	formatted, err := internal.FormatWorkflow([]byte(anchorsWorkflow), false)
+	if err != nil {
+		t.Fatalf("FormatWorkflow failed: %v", err)
+	}
+	out := string(formatted)
+	if strings.Contains(out, "!!merge") {
+		t.Errorf("Expected a plain <<, got:\n%s", out)
+	}
+	if !strings.Contains(out, "---\n<<: &test\n") || !strings.Contains(out, "---\n<<: *test\nname: race\n") {
+		t.Errorf("Expected << first in its mapping, got:\n%s", out)
+	}
+
+	path := filepath.Join(t.TempDir(), "release.yml")
+	os.WriteFile(path, formatted, 0644)
+	configs, err := internal.ParseMultiYAML(path)
+	if err != nil || len(configs) != 3 || configs[2].Subcommand != "test" {
+		t.Errorf("Expected the formatted workflow to parse the same, got %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+// findDiagnostic returns the diagnostic of check whose subject starts with subject
+func findDiagnostic(diagnostics []internal.AppDiagnostic, check, subject string) *internal.AppDiagnostic {
+	for i, d := range diagnostics {
+		if d.Check == check && strings.HasPrefix(filepath.ToSlash(d.Subject), subject) {
+			return &diagnostics[i]
+		}
+	}
+	return nil
+}
+
+func TestDiagnoseApp(t *testing.T) {
+	t.Setenv("LINEA_HOME", t.TempDir())
+	exe, _ := os.Executable()
+	t.Setenv("LINEA_BIN", exe)
+
+	dir := t.TempDir()
+	workflows := filepath.Join(dir, ".linea", "workflows")
+	os.MkdirAll(workflows, 0755)
+	os.MkdirAll(filepath.Join(dir, "scripts"), 0755)
+	os.WriteFile(filepath.Join(workflows, "build.yml"), []byte("command: "+filepath.ToSlash(exe)+"\n"), 0644)
+	os.WriteFile(filepath.Join(workflows, "deploy.yml"), []byte("command: linea-no-such-command\n---\ncommand: \"{tool}\"\n"), 0644)
+	os.WriteFile(filepath.Join(workflows, "broken.yml"), []byte("command: echo\nargs: [\"{oops}\"]\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "scripts", "ci.lnsh"), []byte("# CI\nTAG=\"1.0\"\nbuild -s tag=$TAG\nif $TAG == \"1.0\"\n    release -s tag=$TAG\nend\necho done\n"), 0755)
+
+	if root := internal.AppRoot(filepath.Join(dir, "scripts")); root != dir {
+		t.Errorf("Expected the app root to be %s, got %s", dir, root)
+	}
+	diagnostics := internal.DiagnoseApp(dir)
+
+	if d := findDiagnostic(diagnostics, internal.AppCheckWorkflows, ".linea/workflows/build.yml"); d == nil || d.Problem != "" {
+		t.Errorf("Expected build.yml to pass, got %+v", d)
+	}
+	if d := findDiagnostic(diagnostics, internal.AppCheckWorkflows, ".linea/workflows/broken.yml:"); d == nil || !strings.Contains(d.Problem, "{oops}") || !strings.Contains(d.Fix, "linea validate") {
+		t.Errorf("Expected broken.yml's undefined variable with a fix, got %+v", d)
+	}
+	if d := findDiagnostic(diagnostics, internal.AppCheckCommands, "linea-no-such-command"); d == nil || !strings.Contains(d.Problem, "deploy.yml") || d.Fix == "" {
+		t.Errorf("Expected the missing command to be reported with its workflow, got %+v", d)
+	}
+	if d := findDiagnostic(diagnostics, internal.AppCheckCommands, "{tool}"); d != nil {
+		t.Errorf("Expected a command named by a variable to be skipped, got %+v", d)
+	}
+	if d := findDiagnostic(diagnostics, internal.AppCheckScripts, "scripts/ci.lnsh:5"); d == nil || !strings.Contains(d.Problem, "'release'") {
+		t.Errorf("Expected the call of a missing workflow to be reported, got %+v", d)
+	}
+	for _, d := range diagnostics {
+		if d.Check == internal.AppCheckScripts && (strings.HasSuffix(d.Subject, ":3") || strings.HasSuffix(d.Subject, ":7")) {
+			t.Errorf("Expected existing workflows and built-ins to pass, got %+v", d)
+		}
+	}
+	if d := findDiagnostic(diagnostics, internal.AppCheckExecutables, filepath.ToSlash(exe)); d == nil || d.Problem != "" {
+		t.Errorf("Expected linea to resolve to LINEA_BIN, got %+v", d)
+	}
+	if d := findDiagnostic(diagnostics, internal.AppCheckConfig, ""); d == nil || d.Problem != "" {
+		t.Errorf("Expected a missing config file to pass, got %+v", d)
+	}
+}
+
+func TestDiagnoseAppWithoutWorkflowsAndBadConfig(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("LINEA_HOME", home)
+	os.WriteFile(filepath.Join(home, "config.yml"), []byte("shell: [\n"), 0644)
+
+	diagnostics := internal.DiagnoseApp(t.TempDir())
+	if d := findDiagnostic(diagnostics, internal.AppCheckWorkflows, ""); d == nil || !strings.Contains(d.Problem, ".linea/workflows") || !strings.Contains(d.Fix, "linea app create") {
+		t.Errorf("Expected the missing workflows directory to be reported, got %+v", d)
+	}
+	if d := findDiagnostic(diagnostics, internal.AppCheckConfig, ""); d == nil || d.Problem == "" || d.Fix == "" {
+		t.Errorf("Expected the unreadable config file to be reported, got %+v", d)
+	}
+}
@@ -0,0 +1,181 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+func TestBuiltinAppTemplatesCreateValidApps(t *testing.T) {
+	t.Setenv("LINEA_HOME", t.TempDir())
+
+	names := map[string]bool{}
+	for _, template := range internal.AppTemplates() {
+		names[template.Name] = true
+	}
+	for _, name := range []string{"default", "node", "docker", "ansible-like"} {
+		if !names[name] {
+			t.Fatalf("Expected a built-in %s template, got %v", name, names)
+		}
+	}
+
+	for name := range names {
+		template, err := internal.FindAppTemplate(name)
+		if err != nil {
+			t.Fatalf("FindAppTemplate(%s) failed: %v", name, err)
+		}
+		if template.Description == "" || template.Source() != "built-in" {
+			t.Errorf("Expected %s to be a described built-in template, got %+v", name, template)
+		}
+
+		dir := filepath.Join(t.TempDir(), "my-app")
+		files, err := internal.CreateApp(dir, template, nil)
+		if err != nil {
+			t.Fatalf("CreateApp(%s) failed: %v", name, err)
+		}
+		var workflows, scripts int
+		for _, file := range files {
+			if file == internal.AppTemplateFile {
+				t.Errorf("%s: the manifest should not be copied", name)
+			}
+			data, _ := os.ReadFile(filepath.Join(dir, filepath.FromSlash(file)))
+			if strings.Contains(string(data), "{{app_name}}") {
+				t.Errorf("%s: %s still has the {{app_name}} placeholder", name, file)
+			}
+			switch {
+			case strings.HasPrefix(file, ".linea/workflows/"):
+				workflows++
+				problems, err := internal.ValidateWorkflowDefinition(filepath.Join(dir, filepath.FromSlash(file)))
+				if err != nil || len(problems) > 0 {
+					t.Errorf("%s: %s is not valid: %v %v", name, file, problems, err)
+				}
+			case strings.HasSuffix(file, ".lnsh"):
+				scripts++
+				info, _ := os.Stat(filepath.Join(dir, filepath.FromSlash(file)))
+				if runtime.GOOS != "windows" && info.Mode()&0100 == 0 {
+					t.Errorf("%s: expected %s to be executable", name, file)
+				}
+			}
+		}
+		if workflows == 0 || scripts == 0 {
+			t.Errorf("%s: expected workflows and a script, got %v", name, files)
+		}
+		readme, _ := os.ReadFile(filepath.Join(dir, "README.md"))
+		if !strings.HasPrefix(string(readme), "# my-app\n") {
+			t.Errorf("%s: expected the README to be titled with the app name, got %q", name, readme)
+		}
+	}
+}
+
+func TestUserAppTemplateOverridesBuiltin(t *testing.T) {
+	t.Setenv("LINEA_HOME", t.TempDir())
+	template := filepath.Join(internal.AppTemplatesDir(), "node")
+	os.MkdirAll(filepath.Join(template, "{{app_name}}"), 0755)
+	os.WriteFile(filepath.Join(template, "template.yml"), []byte("description: Our Node.js setup\n"), 0644)
+	os.WriteFile(filepath.Join(template, "{{app_name}}", "main.txt"), []byte("app={{app_name}} other={{other}}\n"), 0644)
+
+	found, err := internal.FindAppTemplate("node")
+	if err != nil || found.Dir != template || found.Description != "Our Node.js setup" {
+		t.Fatalf("Expected the user template to replace the built-in one, got %+v, %v", found, err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "api")
+	files, err := internal.CreateApp(dir, found, nil)
+	if err != nil {
+		t.Fatalf("CreateApp failed: %v", err)
+	}
+	if len(files) != 1 || files[0] != "api/main.txt" {
+		t.Fatalf("Expected only api/main.txt, got %v", files)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "api", "main.txt"))
+	if string(data) != "app=api other={{other}}\n" {
+		t.Errorf("Expected only {{app_name}} to be replaced, got %q", data)
+	}
+}
+
+func TestCreateAppErrors(t *testing.T) {
+	t.Setenv("LINEA_HOME", t.TempDir())
+	if _, err := internal.FindAppTemplate("nope"); err == nil || !strings.Contains(err.Error(), "default") {
+		t.Errorf("Expected an unknown template error listing the templates, got %v", err)
+	}
+
+	template, _ := internal.FindAppTemplate(internal.DefaultAppTemplate)
+	dir := t.TempDir()
+	if _, err := internal.CreateApp(dir, template, nil); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("Expected an existing directory to be refused, got %v", err)
+	}
+}
+
+// writeAppTemplate creates a user app template with a manifest and one file
+func writeAppTemplate(t *testing.T, name, manifest, file string) {
+	t.Helper()
+	dir := filepath.Join(internal.AppTemplatesDir(), name)
+	os.MkdirAll(dir, 0755)
+	os.WriteFile(filepath.Join(dir, "template.yml"), []byte(manifest), 0644)
+	os.WriteFile(filepath.Join(dir, "service.yml"), []byte(file), 0644)
+}
+
+func TestAppTemplatePrompts(t *testing.T) {
+	t.Setenv("LINEA_HOME", t.TempDir())
+	writeAppTemplate(t, "service", `description: Our service layout
+prompts:
+  - name: port
+    prompt: Port the service listens on
+    default: "8080"
+  - name: image
+    default: registry.example.com/{{app_name}}
+  - name: owner
+    prompt: Team owning the service
+`, "name: {{app_name}}\nport: {{port}}\nimage: {{image}}\nowner: {{owner}}\n")
+
+	template, err := internal.FindAppTemplate("service")
+	if err != nil || len(template.Prompts) != 3 || template.Prompts[1].Question() != "image" {
+		t.Fatalf("Expected the manifest's prompts, got %+v, %v", template, err)
+	}
+
+	if _, err := internal.AppTemplateValues("billing", template, nil, nil); err == nil || !strings.Contains(err.Error(), "-s owner=") {
+		t.Errorf("Expected a prompt without a default to need a value, got %v", err)
+	}
+
+	var asked []string
+	ask := func(p internal.AppTemplatePrompt, def string) string {
+		asked = append(asked, p.Question()+"="+def)
+		if p.Name == "owner" {
+			return "payments"
+		}
+		return def
+	}
+	values, err := internal.AppTemplateValues("billing", template, map[string]string{"port": "9090"}, ask)
+	if err != nil {
+		t.Fatalf("AppTemplateValues failed: %v", err)
+	}
+	if len(asked) != 2 || asked[0] != "image=registry.example.com/billing" || asked[1] != "Team owning the service=" {
+		t.Errorf("Expected only the unset prompts to be asked, with rendered defaults, got %v", asked)
+	}
+
+	dir := filepath.Join(t.TempDir(), "billing")
+	if _, err := internal.CreateApp(dir, template, values); err != nil {
+		t.Fatalf("CreateApp failed: %v", err)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "service.yml"))
+	expected := "name: billing\nport: 9090\nimage: registry.example.com/billing\nowner: payments\n"
+	if string(data) != expected {
+		t.Errorf("Expected %q, got %q", expected, data)
+	}
+}
+
+func TestInvalidAppTemplateManifest(t *testing.T) {
+	t.Setenv("LINEA_HOME", t.TempDir())
+	writeAppTemplate(t, "broken", "prompts:\n  - name: app_name\n", "x\n")
+	if _, err := internal.FindAppTemplate("broken"); err == nil || !strings.Contains(err.Error(), "app_name") {
+		t.Errorf("Expected a prompt for the built-in placeholder to be refused, got %v", err)
+	}
+	writeAppTemplate(t, "bad-name", "prompts:\n  - name: \"my port\"\n", "x\n")
+	if _, err := internal.FindAppTemplate("bad-name"); err == nil || !strings.Contains(err.Error(), "invalid prompt name") {
+		t.Errorf("Expected an invalid prompt name to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package tests
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+// listFiles returns the slash-separated paths of the files under dir
+func listFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	var files []string
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			rel, _ := filepath.Rel(dir, path)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files
+}
+
+func TestArchiveAndExtractSteps(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "app")
+	for _, name := range []string{"bin/app", "lib/util.js", "lib/util.js.map", "node_modules/dep/index.js", "docs/guide/intro.md", "README.md"} {
+		os.MkdirAll(filepath.Join(src, filepath.Dir(name)), 0755)
+		os.WriteFile(filepath.Join(src, name), []byte(name), 0755)
+	}
+
+	for _, ext := range []string{"zip", "tar.gz", "tar"} {
+		t.Run(ext, func(t *testing.T) {
+			dest := filepath.Join(dir, "dist", "app-{version}."+ext)
+			config := &internal.CommandConfig{
+				Type:      internal.StepTypeArchive,
+				Archive:   &internal.ArchiveSpec{Src: src, Dest: dest, Include: []string{"bin/*", "lib/**", "docs/**/*.md", "README.md"}, Exclude: []string{"*.map", "node_modules"}},
+				Variables: map[string]string{"version": "1.2.0"},
+			}
+			if output, err := runFileStep(t, config, nil); err != nil {
+				t.Fatalf("Archive failed: %v\n%s", err, output)
+			}
+
+			out := filepath.Join(dir, "out-"+ext)
+			archive := filepath.Join(dir, "dist", "app-1.2.0."+ext)
+			config = &internal.CommandConfig{Type: internal.StepTypeExtract, Extract: &internal.ArchiveSpec{Src: archive, Dest: out}}
+			if output, err := runFileStep(t, config, nil); err != nil {
+				t.Fatalf("Extract failed: %v\n%s", err, output)
+			}
+			want := "README.md bin/app docs/guide/intro.md lib/util.js"
+			if got := strings.Join(listFiles(t, out), " "); got != want {
+				t.Errorf("Expected %s, got %s", want, got)
+			}
+			if info, err := os.Stat(filepath.Join(out, "bin", "app")); err != nil || (runtime.GOOS != "windows" && info.Mode().Perm() != 0755) {
+				t.Errorf("Expected bin/app to keep its permissions, got %v, %v", info, err)
+			}
+		})
+	}
+}
+
+func TestExtractRefusesPathsOutsideDest(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "evil.tar.gz")
+	f, _ := os.Create(archive)
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+	tw.WriteHeader(&tar.Header{Name: "../escaped.txt", Mode: 0644, Size: 1, Typeflag: tar.TypeReg})
+	tw.Write([]byte("x"))
+	tw.Close()
+	gw.Close()
+	f.Close()
+
+	config := &internal.CommandConfig{Type: internal.StepTypeExtract, Extract: &internal.ArchiveSpec{Src: archive, Dest: filepath.Join(dir, "out")}}
+	if _, err := runFileStep(t, config, nil); internal.ExitCode(err) != 1 || !strings.Contains(err.Error(), "refusing") {
+		t.Errorf("Expected the entry to be refused, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "escaped.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing to be written outside of dest")
+	}
+}
+
+func TestExtractRefusesChainedLinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symbolic links needs privileges on Windows")
+	}
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "chained.tar")
+	f, _ := os.Create(archive)
+	tw := tar.NewWriter(f)
+	tw.WriteHeader(&tar.Header{Name: "a", Linkname: ".", Typeflag: tar.TypeSymlink})
+	tw.WriteHeader(&tar.Header{Name: "a/b", Linkname: "..", Typeflag: tar.TypeSymlink})
+	tw.WriteHeader(&tar.Header{Name: "a/b/evil.txt", Mode: 0644, Size: 1, Typeflag: tar.TypeReg})
+	tw.Write([]byte("x"))
+	tw.Close()
+	f.Close()
+
+	out := filepath.Join(dir, "out")
+	config := &internal.CommandConfig{Type: internal.StepTypeExtract, Extract: &internal.ArchiveSpec{Src: archive, Dest: out}}
+	if _, err := runFileStep(t, config, nil); internal.ExitCode(err) != 1 || !strings.Contains(err.Error(), "refusing") {
+		t.Errorf("Expected the chained link to be refused, got %v", err)
+	}
+	for _, path := range []string{filepath.Join(dir, "evil.txt"), filepath.Join(out, "evil.txt")} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s not to be written", path)
+		}
+	}
+}
+
+func TestCheckArchiveStep(t *testing.T) {
+	for _, config := range []*internal.CommandConfig{
+		{Type: internal.StepTypeArchive},
+		{Type: internal.StepTypeArchive, Archive: &internal.ArchiveSpec{Dest: "app.rar"}},
+		{Type: internal.StepTypeArchive, Archive: &internal.ArchiveSpec{Dest: "app.zip", Include: []string{"[a"}}},
+		{Type: internal.StepTypeExtract, Extract: &internal.ArchiveSpec{Src: "app.zip"}},
+		{Type: internal.StepTypeExtract, Extract: &internal.ArchiveSpec{Src: "app.zip", Dest: "out", Exclude: []string{"*.map"}}},
+		{Command: "echo", Archive: &internal.ArchiveSpec{Dest: "app.zip"}},
+	} {
+		if err := internal.CheckArchiveStep(config); err == nil {
+			t.Errorf("Expected %+v to be rejected", config)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package tests
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+const assertWorkflow = `name: build
+command: echo
+args: ["version 1.2"]
+capture: true
+---
+type: assert
+assert:
+  exit_code: 0
+  file_exists: "{dir}/app.zip"
+  step: build
+  output_contains: "1.2"
+---
+type: assert
+assert:
+  file_missing: "{dir}/app.zip"
+  that: "{v} == 2"
+variables:
+  v: "1"
+---
+type: assert
+assert:
+  file_exists: "{dir}"
+`
+
+func TestAssertSteps(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "app.zip"), []byte("zip"), 0644)
+	path := writeWorkflow(t, dir, "smoke.yml", strings.ReplaceAll(assertWorkflow, "{dir}", filepath.ToSlash(dir)))
+	configs, err := internal.ParseMultiYAML(path)
+	if err != nil {
+		t.Fatalf("ParseMultiYAML failed: %v", err)
+	}
+
+	// A failed assert stops a run like any other step
+	results, err := internal.RunStepsReport(configs, nil, internal.ReportOptions{Stdout: io.Discard, Stderr: io.Discard})
+	if err == nil || len(results) != 4 || results[3].Status != internal.StepNotRun {
+		t.Fatalf("Expected the run to stop at the failed assert, got %+v %v", results, err)
+	}
+
+	results, err = internal.RunStepsReport(configs, nil, internal.ReportOptions{Stdout: io.Discard, Stderr: io.Discard, Asserts: true})
+	if err == nil || !strings.Contains(err.Error(), "exists; 1 == 2 does not hold") {
+		t.Errorf("Expected the failed checks in the error, got %v", err)
+	}
+	summary := internal.SummarizeAssertions(configs, results)
+	if summary.Passed != 2 || len(summary.Failed) != 1 || summary.Failed[0].Index != 3 || summary.NotRun != 0 || summary.Failures != 0 {
+		t.Errorf("Unexpected summary: %+v", summary)
+	}
+}
+
+func TestAssertStepProblems(t *testing.T) {
+	dir := t.TempDir()
+	for _, tc := range []struct{ content, want string }{
+		{"command: echo\nassert:\n  file_exists: x\n", "assert: requires type: assert"},
+		{"type: assert\n", "type: assert needs an assert:"},
+		{"type: assert\nassert:\n  output_contains: x\n", "output_contains needs the step"},
+		{"type: assert\nassert:\n  step: nope\n  output_contains: x\n", "assert: step 'nope' must be an earlier step with capture: true"},
+		{"type: assert\nassert:\n  exit_code: 300\n", "invalid exit_code 300"},
+		{"type: assert\nassert:\n  that: \"a == b == c\"\n", "comparisons cannot be chained"},
+	} {
+		path := writeWorkflow(t, dir, "assert.yml", tc.content)
+		problems, err := internal.ValidateWorkflowFile(path, nil)
+		if err != nil || len(problems) != 1 || !strings.Contains(problems[0].Message, tc.want) {
+			t.Errorf("%q: expected a problem with %q, got %v %v", tc.content, tc.want, problems, err)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package tests
+
+import (
+	"runtime"
+	"testing"
+
+	"linea/internal"
+)
+
+func TestBuiltinVariables(t *testing.T) {
+	config := &internal.CommandConfig{
+		Command:    "echo",
+		Args:       []string{"app-{os}-{arch}.zip", "{workflow_name}"},
+		SourceFile: "examples/release.yml",
+	}
+
+	cmd, err := internal.BuildCommand(config, nil)
+	if err != nil {
+		t.Fatalf("BuildCommand failed: %v", err)
+	}
+
+	expected := "app-" + runtime.GOOS + "-" + runtime.GOARCH + ".zip"
+	if cmd[1] != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, cmd[1])
+	}
+	if cmd[2] != "release" {
+		t.Errorf("Expected workflow_name 'release', got '%s'", cmd[2])
+	}
+}
+
+func TestBuiltinVariablesStableWithinRun(t *testing.T) {
+	first := internal.BuiltinVariables(nil)
+	second := internal.BuiltinVariables(nil)
+
+	if first["timestamp"] == "" || first["timestamp"] != second["timestamp"] {
+		t.Errorf("Expected stable timestamp, got '%s' and '%s'", first["timestamp"], second["timestamp"])
+	}
+	if len(first["uuid"]) != 36 || first["uuid"] != second["uuid"] {
+		t.Errorf("Expected stable UUID, got '%s' and '%s'", first["uuid"], second["uuid"])
+	}
+}
+
+func TestBuiltinVariablesOverriddenByYAML(t *testing.T) {
+	config := &internal.CommandConfig{
+		Command:   "echo",
+		Args:      []string{"{os}"},
+		Variables: map[string]string{"os": "custom"},
+	}
+
+	cmd, err := internal.BuildCommand(config, nil)
+	if err != nil {
+		t.Fatalf("BuildCommand failed: %v", err)
+	}
+	if cmd[1] != "custom" {
+		t.Errorf("Expected YAML variable to override built-in, got '%s'", cmd[1])
+	}
+}
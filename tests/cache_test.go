@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"linea/internal"
+)
+
+func TestCacheDirBuiltinVariable(t *testing.T) {
+	cache := t.TempDir()
+	t.Setenv("LINEA_CACHE_DIR", cache)
+
+	config := &internal.CommandConfig{Command: "curl", Args: []string{"-o", "{cache_dir}/node.tar.gz"}}
+	cmd, err := internal.BuildCommand(config, nil)
+	if err != nil {
+		t.Fatalf("BuildCommand failed: %v", err)
+	}
+	if expected := filepath.Join(cache, "shared") + "/node.tar.gz"; cmd[2] != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, cmd[2])
+	}
+}
+
+func TestListAndCleanCache(t *testing.T) {
+	t.Setenv("LINEA_CACHE_DIR", t.TempDir())
+
+	if entries, err := internal.ListCacheEntries(); err != nil || len(entries) != 0 {
+		t.Fatalf("Expected an empty cache before first use, got %v (%v)", entries, err)
+	}
+	if err := internal.EnsureSharedCacheDir(); err != nil {
+		t.Fatalf("EnsureSharedCacheDir failed: %v", err)
+	}
+
+	dir := internal.SharedCacheDir()
+	writeWorkflow(t, filepath.Join(dir, "go-1.22"), "go.tar.gz", "12345")
+	writeWorkflow(t, filepath.Join(dir, "go-1.22", "bin"), "go", "123")
+	writeWorkflow(t, dir, "node.tar.gz", "1234567890")
+
+	old := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "node.tar.gz"), old, old); err != nil {
+		t.Fatalf("Chtimes failed: %v", err)
+	}
+
+	entries, err := internal.ListCacheEntries()
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("Expected 2 cache keys, got %d (%v)", len(entries), err)
+	}
+	if entries[0].Key != "go-1.22" || entries[0].Size != 8 || entries[0].Files != 2 {
+		t.Errorf("Unexpected entry %+v", entries[0])
+	}
+
+	removed, err := internal.CleanCache(24*time.Hour, time.Now())
+	if err != nil || len(removed) != 1 || removed[0].Key != "node.tar.gz" {
+		t.Fatalf("Expected only the stale key to be evicted, got %+v (%v)", removed, err)
+	}
+
+	if _, err := internal.RemoveCacheEntry("../outside"); err == nil {
+		t.Error("Expected error for a key outside the cache")
+	}
+	if _, err := internal.RemoveCacheEntry("missing"); err == nil {
+		t.Error("Expected error for a missing key")
+	}
+	if entry, err := internal.RemoveCacheEntry("go-1.22"); err != nil || entry.Size != 8 {
+		t.Errorf("RemoveCacheEntry failed: %+v (%v)", entry, err)
+	}
+	if entries, _ := internal.ListCacheEntries(); len(entries) != 0 {
+		t.Errorf("Expected an empty cache, got %+v", entries)
+	}
+}
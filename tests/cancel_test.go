@@ -0,0 +1,172 @@
+package tests
+
+import (
+	"bytes"
+	"os"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"linea/internal"
+)
+
+// sendSignal sends sig to the test process after delay
+func sendSignal(t *testing.T, sig os.Signal, delay time.Duration) {
+	t.Helper()
+	process, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatalf("FindProcess failed: %v", err)
+	}
+	time.AfterFunc(delay, func() { process.Signal(sig) })
+}
+
+func TestCleanupStepsRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	configs := []*internal.CommandConfig{
+		{Command: "sh", Args: []string{"-c", "exit 2"}},
+		{Command: "echo", Args: []string{"not reached"}},
+		{Command: "echo", Args: []string{"cleanup"}, After: true},
+		{Command: "echo", Args: []string{"rollback"}, OnFailure: true},
+	}
+	var out bytes.Buffer
+	results, err := internal.RunStepsReport(configs, nil, internal.ReportOptions{Stdout: &out, Stderr: &out})
+	if internal.ExitCode(err) != 2 {
+		t.Errorf("Expected the first failure's exit code 2, got %v", err)
+	}
+	statuses := []string{internal.StepFailed, internal.StepNotRun, internal.StepSucceeded, internal.StepSucceeded}
+	for i, status := range statuses {
+		if results[i].Status != status {
+			t.Errorf("Expected step %d to be %s, got %s", i+1, status, results[i].Status)
+		}
+	}
+	if out.String() != "cleanup\nrollback\n" {
+		t.Errorf("Expected the cleanup steps' output, got %q", out.String())
+	}
+
+	// Without a failure, on_failure steps are skipped and after steps run as usual
+	out.Reset()
+	configs[0].Args = []string{"-c", "exit 0"}
+	results, err = internal.RunStepsReport(configs, nil, internal.ReportOptions{Stdout: &out, Stderr: &out})
+	if err != nil {
+		t.Fatalf("RunStepsReport failed: %v", err)
+	}
+	if results[3].Status != internal.StepSkipped || out.String() != "not reached\ncleanup\n" {
+		t.Errorf("Expected on_failure to be skipped, got %s and %q", results[3].Status, out.String())
+	}
+}
+
+func TestCleanupStepsWithoutReport(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	configs := []*internal.CommandConfig{
+		{Command: "sh", Args: []string{"-c", "exit 2"}},
+		{Command: "echo", Args: []string{"not reached"}},
+		{Command: "echo", Args: []string{"rollback"}, OnFailure: true},
+	}
+	var out bytes.Buffer
+	err := internal.ExecuteMultipleCommandsWithOutput(configs, nil, false, false, &out, &out)
+	if err == nil || !strings.Contains(err.Error(), "command 1") {
+		t.Errorf("Expected the failure of command 1, got %v", err)
+	}
+	if out.String() != "rollback\n" {
+		t.Errorf("Expected only the on_failure step to run, got %q", out.String())
+	}
+}
+
+func TestCancelledRunForwardsSignal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("signals cannot be sent on Windows")
+	}
+	stop := internal.HandleSignals(time.Minute)
+	defer stop()
+
+	configs := []*internal.CommandConfig{
+		{Command: "sleep", Args: []string{"30"}},
+		{Command: "echo", Args: []string{"not reached"}},
+		{Command: "echo", Args: []string{"cleanup"}, After: true},
+		{Command: "echo", Args: []string{"rollback"}, OnFailure: true},
+	}
+	sendSignal(t, syscall.SIGTERM, 200*time.Millisecond)
+	var out bytes.Buffer
+	start := time.Now()
+	results, err := internal.RunStepsReport(configs, nil, internal.ReportOptions{Stdout: &out, Stderr: &out})
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("Expected SIGTERM to stop the command, took %s", elapsed)
+	}
+	if !internal.IsCancelled(err) || internal.ExitCode(err) != 143 {
+		t.Fatalf("Expected a cancelled run with exit code 143, got %v", err)
+	}
+	statuses := []string{internal.StepFailed, internal.StepNotRun, internal.StepSucceeded, internal.StepSucceeded}
+	for i, status := range statuses {
+		if results[i].Status != status {
+			t.Errorf("Expected step %d to be %s, got %s", i+1, status, results[i].Status)
+		}
+	}
+	if out.String() != "cleanup\nrollback\n" {
+		t.Errorf("Expected the cleanup steps to run, got %q", out.String())
+	}
+}
+
+func TestCancelledRunKillsAfterGracePeriod(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("signals cannot be sent on Windows")
+	}
+	stop := internal.HandleSignals(300 * time.Millisecond)
+	defer stop()
+
+	// The command ignores SIGTERM, so it has to be killed
+	config := &internal.CommandConfig{Command: "sh", Args: []string{"-c", "trap '' TERM; exec sleep 30"}}
+	sendSignal(t, syscall.SIGTERM, 200*time.Millisecond)
+	var out bytes.Buffer
+	start := time.Now()
+	_, err := internal.RunStepsReport([]*internal.CommandConfig{config}, nil, internal.ReportOptions{Stdout: &out, Stderr: &out})
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("Expected the command to be killed after the grace period, took %s", elapsed)
+	}
+	if !internal.IsCancelled(err) {
+		t.Errorf("Expected a cancelled run, got %v", err)
+	}
+}
+
+func TestHealthCheckCancelled(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("signals cannot be sent on Windows")
+	}
+	stop := internal.HandleSignals(time.Minute)
+	defer stop()
+
+	config := &internal.CommandConfig{
+		Type:        "healthcheck",
+		HealthCheck: &internal.HealthCheckSpec{TCP: "127.0.0.1:1", Interval: "10s", Timeout: "1m"},
+	}
+	cmd, err := internal.BuildCommand(config, nil)
+	if err != nil {
+		t.Fatalf("BuildCommand failed: %v", err)
+	}
+	sendSignal(t, os.Interrupt, 300*time.Millisecond)
+	start := time.Now()
+	var out bytes.Buffer
+	err = internal.ExecuteStepWithOutput(config, cmd, nil, &out, &out)
+	if err == nil || !strings.Contains(err.Error(), "cancelled") {
+		t.Errorf("Expected the healthcheck to be cancelled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the healthcheck to stop waiting, took %s", elapsed)
+	}
+}
+
+func TestCleanupValidation(t *testing.T) {
+	path := writeWorkflow(t, t.TempDir(), "cleanup.yml", "command: echo\nafter: true\non_failure: true\n")
+	problems, err := internal.ValidateWorkflowFile(path, nil)
+	if err != nil {
+		t.Fatalf("ValidateWorkflowFile failed: %v", err)
+	}
+	if len(problems) != 1 || !strings.Contains(problems[0].Message, "cannot be combined") {
+		t.Errorf("Expected after with on_failure to be rejected, got %v", problems)
+	}
+}
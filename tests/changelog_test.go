@@ -0,0 +1,77 @@
+package tests
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"linea/internal"
+)
+
+const changelogWorkflow = `name: deploy
+command: echo
+args: [deploy]
+changelog:
+  - version: "2.0"
+    date: 2026-03-02
+    description: Blue/green deploys
+  - First version
+`
+
+func TestChangelogEntryString(t *testing.T) {
+	cases := map[string]internal.ChangelogEntry{
+		"2.0 (2026-03-02): Blue/green deploys": {Version: "2.0", Date: "2026-03-02", Description: "Blue/green deploys"},
+		"2.0: Blue/green deploys":              {Version: "2.0", Description: "Blue/green deploys"},
+		"(2026-03-02)":                         {Date: "2026-03-02"},
+		"First version":                        {Description: "First version"},
+	}
+	for want, entry := range cases {
+		if got := entry.String(); got != want {
+			t.Errorf("Expected %q, got %q", want, got)
+		}
+	}
+}
+
+func TestCheckWorkflowChanges(t *testing.T) {
+	dir := t.TempDir()
+	path := writeWorkflow(t, dir, "deploy.yml", "name: deploy\ncommand: echo\nargs: [deploy]\nchangelog:\n  - First version\n")
+
+	changes := internal.CheckWorkflowChanges(path, nil)
+	if changes == nil || changes.Changed || changes.Head != "First version" {
+		t.Fatalf("Expected a first run to be unchanged, got %+v", changes)
+	}
+
+	record := internal.NewRunRecord(path, nil, time.Now(), nil)
+	record.WorkflowHash, record.ChangelogHead = changes.Hash, changes.Head
+	records := []internal.RunRecord{record}
+	if changes := internal.CheckWorkflowChanges(path, records); changes.Changed || len(changes.Entries) != 0 {
+		t.Errorf("Expected an unchanged file, got %+v", changes)
+	}
+
+	if err := os.WriteFile(path, []byte(changelogWorkflow), 0644); err != nil {
+		t.Fatal(err)
+	}
+	changes = internal.CheckWorkflowChanges(path, records)
+	if !changes.Changed || len(changes.Entries) != 1 || changes.Entries[0].Version != "2.0" {
+		t.Errorf("Expected the entry added since the last run, got %+v", changes)
+	}
+
+	// A rewritten changelog shows its newest entries
+	records[0].ChangelogHead = "Gone"
+	if changes := internal.CheckWorkflowChanges(path, records); len(changes.Entries) != 2 {
+		t.Errorf("Expected the newest entries, got %+v", changes.Entries)
+	}
+}
+
+func TestChangelogValidation(t *testing.T) {
+	dir := t.TempDir()
+	path := writeWorkflow(t, dir, "deploy.yml", changelogWorkflow)
+	if problems, err := internal.ValidateWorkflowFile(path, nil); err != nil || len(problems) != 0 {
+		t.Errorf("Expected a valid changelog, got %v, %v", problems, err)
+	}
+
+	path = writeWorkflow(t, dir, "bad.yml", "command: echo\nchangelog:\n  - note: unknown key\n")
+	if problems, _ := internal.ValidateWorkflowFile(path, nil); len(problems) == 0 {
+		t.Error("Expected a problem for an unknown changelog key")
+	}
+}
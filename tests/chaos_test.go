@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"linea/internal"
+	lineatest "linea/testing"
+)
+
+func TestParseChaos(t *testing.T) {
+	opts, err := internal.ParseChaos("fail=10%, delay=0.25,timeout=5%,max_delay=1s,seed=42")
+	if err != nil {
+		t.Fatalf("ParseChaos failed: %v", err)
+	}
+	want := internal.ChaosOptions{Fail: 0.1, Delay: 0.25, Timeout: 0.05, MaxDelay: time.Second, Seed: 42}
+	if opts != want {
+		t.Errorf("Expected %+v, got %+v", want, opts)
+	}
+	if opts.String() != "fail 10%, delay 25% up to 1s, timeout 5%, seed 42" {
+		t.Errorf("Unexpected description: %s", opts)
+	}
+
+	if opts, err := internal.ParseChaos("0.5"); err != nil || opts.Fail != 0.5 || opts.Seed == 0 {
+		t.Errorf("Expected a bare rate to be the fail rate with a random seed, got %+v, %v", opts, err)
+	}
+	for _, spec := range []string{"fail=2", "delay=-1%", "explode=10%", "seed=x", "max_delay=1s", "fail=abc"} {
+		if _, err := internal.ParseChaos(spec); err == nil {
+			t.Errorf("Expected %q to be rejected", spec)
+		}
+	}
+}
+
+func TestChaosRunnerFaults(t *testing.T) {
+	configs := []*internal.CommandConfig{
+		{Command: "echo", Args: []string{"build"}},
+		{Command: "echo", Args: []string{"rollback"}, OnFailure: true},
+		{Command: "echo", Args: []string{"cleanup"}, After: true},
+	}
+	run := func(opts internal.ChaosOptions) ([]internal.StepResult, string, error) {
+		var out bytes.Buffer
+		results, err := internal.RunStepsReport(configs, nil, internal.ReportOptions{
+			Stdout: &out,
+			Stderr: &out,
+			Runner: internal.NewChaosRunner(internal.LocalRunner{}, opts),
+		})
+		return results, out.String(), err
+	}
+
+	// Cleanup steps are never failed by chaos mode, so they handle the injected failure
+	results, output, err := run(internal.ChaosOptions{Fail: 1, Seed: 1})
+	if internal.ExitCode(err) != 1 || results[0].Status != internal.StepFailed {
+		t.Fatalf("Expected an injected failure, got %v", err)
+	}
+	if output != "chaos: injected failure\nrollback\ncleanup\n" {
+		t.Errorf("Expected the cleanup steps to run after the failure, got %q", output)
+	}
+
+	results, _, err = run(internal.ChaosOptions{Timeout: 1, Seed: 1})
+	if internal.ExitCode(err) != internal.ChaosTimeoutExitCode || !strings.Contains(results[0].Error, "timed out") {
+		t.Errorf("Expected a simulated timeout, got %v", err)
+	}
+
+	_, output, err = run(internal.ChaosOptions{Delay: 1, MaxDelay: 10 * time.Millisecond, Seed: 1})
+	if err != nil {
+		t.Errorf("Expected delays not to fail the run, got %v", err)
+	}
+	if !strings.Contains(output, "chaos: injected delay of") || !strings.Contains(output, "build") {
+		t.Errorf("Expected the delayed steps to run, got %q", output)
+	}
+}
+
+func TestChaosSeedIsReproducible(t *testing.T) {
+	path := writeWorkflow(t, t.TempDir(), "steps.yml", strings.Repeat("command: echo\nargs: [\"step\"]\n---\n", 20))
+	statuses := func() string {
+		wf := lineatest.Load(t, path).Chaos(t, "fail=50%,seed=7")
+		wf.KeepGoing = true
+		var s []string
+		for _, step := range wf.Run(t).Steps {
+			s = append(s, step.Status)
+		}
+		return strings.Join(s, ",")
+	}
+	first := statuses()
+	if !strings.Contains(first, internal.StepFailed) || !strings.Contains(first, internal.StepSucceeded) {
+		t.Errorf("Expected some of the steps to fail, got %s", first)
+	}
+	if second := statuses(); second != first {
+		t.Errorf("Expected the same seed to fail the same steps:\n%s\n%s", first, second)
+	}
+}
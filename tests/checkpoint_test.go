@@ -0,0 +1,144 @@
+package tests
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+// checkpointedWorkflow writes a workflow of four steps in a project: gen captures its
+// output, create counts its runs, use fails until the file ok exists, and cleanup is an
+// after: step. It returns the workflow file and its steps
+func checkpointedWorkflow(t *testing.T) (string, []*internal.CommandConfig) {
+	t.Helper()
+	path := projectWorkflow(t, "provision.yml", "command: echo\n")
+	dir := filepath.Dir(path)
+	return path, []*internal.CommandConfig{
+		{Name: "gen", Command: "echo", Args: []string{"token-$env"}, Capture: true, SourceFile: path},
+		{Name: "create", Command: "sh", Args: []string{"-c", "echo run >> " + filepath.Join(dir, "created")}, SourceFile: path},
+		{Name: "use", Command: "sh", Args: []string{"-c", "test -e " + filepath.Join(dir, "ok") + " && cat > " + filepath.Join(dir, "used")}, Stdin: &internal.StdinSpec{Step: "gen"}, SourceFile: path},
+		{Name: "cleanup", Command: "echo", Args: []string{"cleanup"}, After: true, SourceFile: path},
+	}
+}
+
+func TestCheckpointResume(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	path, configs := checkpointedWorkflow(t)
+	dir := filepath.Dir(path)
+	vars := map[string]string{"env": "prod"}
+
+	checkpoint, err := internal.NewCheckpoint(path, vars)
+	if err != nil {
+		t.Fatalf("NewCheckpoint failed: %v", err)
+	}
+	_, err = internal.RunStepsReport(configs, vars, internal.ReportOptions{Stdout: io.Discard, Stderr: io.Discard, Checkpoint: checkpoint})
+	checkpoint.Finish(err)
+	if err == nil {
+		t.Fatalf("Expected the use step to fail")
+	}
+	if !strings.HasPrefix(internal.CheckpointPath(path), filepath.Join(dir, ".linea", "run", "checkpoints")) {
+		t.Errorf("Expected the checkpoint in .linea/run/checkpoints, got %s", internal.CheckpointPath(path))
+	}
+	if info, err := os.Stat(internal.CheckpointPath(path)); err != nil || (runtime.GOOS != "windows" && info.Mode().Perm() != 0600) {
+		t.Fatalf("Expected a private checkpoint after the failure: %v", err)
+	}
+
+	resumed, err := internal.LoadCheckpoint(path)
+	if err != nil || resumed == nil {
+		t.Fatalf("LoadCheckpoint failed: %v", err)
+	}
+	if len(resumed.Done) != 2 || resumed.NextStep() != 3 || string(resumed.Outputs["gen"]) != "token-prod\n" {
+		t.Errorf("Expected steps 1 and 2 done with the output of gen, got %+v", resumed)
+	}
+	vars = resumed.ResumeVariables(map[string]string{"region": "eu"})
+	if vars["env"] != "prod" || vars["region"] != "eu" {
+		t.Errorf("Expected the variables of the run with the new ones, got %v", vars)
+	}
+
+	// The output of gen comes from the checkpoint, not from running gen again
+	resumed.Outputs["gen"] = []byte("restored\n")
+	os.WriteFile(filepath.Join(dir, "ok"), nil, 0644)
+	results, err := internal.RunStepsReport(configs, vars, internal.ReportOptions{Stdout: io.Discard, Stderr: io.Discard, Checkpoint: resumed})
+	resumed.Finish(err)
+	if err != nil {
+		t.Fatalf("Expected the resumed run to succeed, got %v", err)
+	}
+	var statuses []string
+	for _, result := range results {
+		statuses = append(statuses, result.Status)
+	}
+	if strings.Join(statuses, ",") != "skipped,skipped,success,success" {
+		t.Errorf("Expected the done steps to be skipped, got %v", statuses)
+	}
+	if created, _ := os.ReadFile(filepath.Join(dir, "created")); string(created) != "run\n" {
+		t.Errorf("Expected create to run once, got %q", created)
+	}
+	if used, _ := os.ReadFile(filepath.Join(dir, "used")); string(used) != "restored\n" {
+		t.Errorf("Expected use to read the checkpointed output, got %q", used)
+	}
+	if _, err := os.Stat(internal.CheckpointPath(path)); !os.IsNotExist(err) {
+		t.Errorf("Expected the checkpoint to be removed after the run succeeded, got %v", err)
+	}
+	if checkpoint, err := internal.LoadCheckpoint(path); checkpoint != nil || err != nil {
+		t.Errorf("Expected no checkpoint to resume, got %+v %v", checkpoint, err)
+	}
+}
+
+func TestCheckpointKeepGoingAndChanges(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	path, configs := checkpointedWorkflow(t)
+	configs[1].Args = []string{"-c", "exit 1"}
+	checkpoint, _ := internal.NewCheckpoint(path, nil)
+	_, err := internal.RunStepsReport(configs, map[string]string{"env": "x"}, internal.ReportOptions{Stdout: io.Discard, Stderr: io.Discard, KeepGoing: true, Checkpoint: checkpoint})
+	checkpoint.Finish(err)
+
+	// Steps after the first failure ran with --keep-going, but are not done
+	resumed, err := internal.LoadCheckpoint(path)
+	if err != nil || len(resumed.Done) != 1 || resumed.NextStep() != 2 {
+		t.Fatalf("Expected only the step before the failure done, got %+v %v", resumed, err)
+	}
+
+	os.WriteFile(path, []byte("command: echo\nargs: [changed]\n"), 0644)
+	if _, err := internal.LoadCheckpoint(path); err == nil || !strings.Contains(err.Error(), "provision changed since the run started at") {
+		t.Errorf("Expected a changed workflow not to resume, got %v", err)
+	}
+}
+
+func TestCheckpointWithoutSummary(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	path, configs := checkpointedWorkflow(t)
+	dir := filepath.Dir(path)
+	checkpoint, _ := internal.NewCheckpoint(path, nil)
+	_, err := internal.RunStepsReport(configs, map[string]string{"env": "prod"}, internal.ReportOptions{Stdout: io.Discard, Stderr: io.Discard, Checkpoint: checkpoint})
+	checkpoint.Finish(err)
+
+	// A run without the step reporter (--summary none) that succeeds removes the checkpoint
+	os.WriteFile(filepath.Join(dir, "ok"), nil, 0644)
+	checkpoint, _ = internal.NewCheckpoint(path, nil)
+	err = internal.ExecuteCheckpointedCommands(configs, map[string]string{"env": "dev"}, false, io.Discard, io.Discard, checkpoint)
+	checkpoint.Finish(err)
+	if _, statErr := os.Stat(internal.CheckpointPath(path)); err != nil || !os.IsNotExist(statErr) {
+		t.Fatalf("Expected the successful run to remove the checkpoint, got %v %v", err, statErr)
+	}
+
+	// and one that fails leaves its own
+	os.Remove(filepath.Join(dir, "ok"))
+	checkpoint, _ = internal.NewCheckpoint(path, map[string]string{"env": "test"})
+	err = internal.ExecuteCheckpointedCommands(configs, map[string]string{"env": "test"}, false, io.Discard, io.Discard, checkpoint)
+	checkpoint.Finish(err)
+	resumed, loadErr := internal.LoadCheckpoint(path)
+	if err == nil || loadErr != nil || resumed == nil || resumed.NextStep() != 3 || string(resumed.Outputs["gen"]) != "token-test\n" {
+		t.Errorf("Expected the checkpoint of the failed run, got %+v %v %v", resumed, err, loadErr)
+	}
+}
@@ -0,0 +1,80 @@
+package tests
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"linea/cmd"
+	"linea/internal"
+)
+
+func TestCompleteCommand(t *testing.T) {
+	t.Setenv("LINEA_HOME", t.TempDir())
+	dir := t.TempDir()
+	projectDir := filepath.Join(dir, ".linea", "workflows")
+	writeWorkflow(t, projectDir, "deploy.yml", "command: echo\nargs: [\"{region}\", \"{replicas}\"]\nvariables:\n  region: eu\n  replicas: \"2\"\n")
+	writeWorkflow(t, projectDir, "build.yml", "command: echo\n")
+	chdir(t, dir)
+
+	for _, tc := range []struct {
+		words []string
+		want  []string
+	}{
+		{[]string{"ser"}, []string{"serve", "service"}},
+		{[]string{"run", ""}, []string{"build", "deploy"}},
+		{[]string{"run", "d"}, []string{"deploy"}},
+		{[]string{"run", "deploy", "-s", ""}, []string{"region=", "replicas="}},
+		{[]string{"run", "deploy", "--set", "reg"}, []string{"region="}},
+		{[]string{"run", "nope", "-s", ""}, nil},
+		{[]string{"run", "deploy", ""}, nil},
+		{[]string{"run", "--re"}, []string{"--require-signed", "--resume"}},
+		{[]string{"test", "--st"}, []string{"--strict"}},
+		{[]string{"run", "--theme", ""}, internal.ThemeNames()},
+		{[]string{"export", "--format", "g"}, []string{"gha", "gitlab"}},
+		{[]string{"cache", ""}, []string{"clean", "clear", "ls"}},
+		{[]string{"completion", "f"}, []string{"fish"}},
+		{[]string{"unknown", ""}, nil},
+	} {
+		if got := cmd.CompleteCommand(tc.words); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q: expected %q, got %q", tc.words, tc.want, got)
+		}
+	}
+
+	// Shells that drop empty words pass the whole line
+	if stdout, stderr, code := runCLI(t, dir, "__complete", "--line", "linea run deploy -s "); code != 0 || stdout != "region=\nreplicas=\n" {
+		t.Errorf("Expected the variable names of deploy, got %d %q %q", code, stdout, stderr)
+	}
+}
+
+func TestCompletionScripts(t *testing.T) {
+	dir := t.TempDir()
+	for _, shell := range []string{"bash", "zsh", "fish", "powershell"} {
+		script, stderr, code := runCLI(t, dir, "completion", shell)
+		if code != 0 || !strings.Contains(script, "linea __complete") {
+			t.Errorf("%s: expected a script completing through linea __complete, got %d %q", shell, code, stderr)
+		}
+		if shell != "bash" {
+			continue
+		}
+		bash, err := exec.LookPath("bash")
+		if err != nil {
+			continue
+		}
+		path := filepath.Join(dir, "linea.bash")
+		os.WriteFile(path, []byte(script), 0644)
+		if out, err := exec.Command(bash, "-n", path).CombinedOutput(); err != nil {
+			t.Errorf("Expected bash to accept the bash script, got %v: %s", err, out)
+		}
+	}
+
+	if _, stderr, code := runCLI(t, dir, "completion", "tcsh"); code != 1 || !strings.Contains(stderr, "unsupported shell 'tcsh'") {
+		t.Errorf("Expected an unsupported shell to fail, got %d %q", code, stderr)
+	}
+	if _, stderr, code := runCLI(t, dir, "completion"); code != 1 || !strings.Contains(stderr, "no shell specified") {
+		t.Errorf("Expected the usage of linea completion, got %d %q", code, stderr)
+	}
+}
@@ -0,0 +1,53 @@
+package tests
+
+import (
+	"testing"
+
+	"linea/internal"
+)
+
+func TestEvaluateCondition(t *testing.T) {
+	tests := []struct {
+		cond string
+		want bool
+	}{
+		{"1 == 1", true},
+		{"1 == 0", false},
+		{"1 != 0", true},
+		{`prod == "prod"`, true},
+		{"'a b' == a b", true},
+		{"yes", true},
+		{"", false},
+		{"false", false},
+		{"0", false},
+		{" No ", false},
+	}
+	for _, tt := range tests {
+		got, err := internal.EvaluateCondition(tt.cond)
+		if err != nil {
+			t.Errorf("EvaluateCondition(%q) failed: %v", tt.cond, err)
+		} else if got != tt.want {
+			t.Errorf("EvaluateCondition(%q) = %v, want %v", tt.cond, got, tt.want)
+		}
+	}
+
+	if _, err := internal.EvaluateCondition("a == b == c"); err == nil {
+		t.Error("Expected an error for a condition with two operators")
+	}
+}
+
+func TestShouldRunStep(t *testing.T) {
+	config := &internal.CommandConfig{Command: "echo", When: "$env == prod", Variables: map[string]string{"env": "dev"}}
+
+	if run, err := internal.ShouldRunStep(config, nil); err != nil || run {
+		t.Errorf("Expected the step not to run for env=dev, got %v, %v", run, err)
+	}
+	if run, err := internal.ShouldRunStep(config, map[string]string{"env": "prod"}); err != nil || !run {
+		t.Errorf("Expected -s env=prod to run the step, got %v, %v", run, err)
+	}
+
+	config.When = "{missing} == 1"
+	if _, err := internal.ShouldRunStep(config, nil); err == nil {
+		t.Error("Expected an error for an undefined variable in when:")
+	}
+}
@@ -0,0 +1,94 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"linea/internal"
+)
+
+func TestUserConfigRoundTrip(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("LINEA_HOME", home)
+	t.Setenv("LINEA_CONFIG_DIR", "")
+
+	config, err := internal.LoadUserConfig()
+	if err != nil {
+		t.Fatalf("LoadUserConfig failed on missing file: %v", err)
+	}
+
+	for key, value := range map[string]string{
+		"verbose":      "true",
+		"shell":        "pwsh",
+		"plugin_paths": "/opt/a, /opt/b",
+	} {
+		if err := config.Set(key, value); err != nil {
+			t.Fatalf("Set(%s) failed: %v", key, err)
+		}
+	}
+	if err := internal.SaveUserConfig(config); err != nil {
+		t.Fatalf("SaveUserConfig failed: %v", err)
+	}
+	if internal.ConfigFilePath() != filepath.Join(home, "config.yml") {
+		t.Errorf("Unexpected config file path %s", internal.ConfigFilePath())
+	}
+
+	loaded, err := internal.LoadUserConfig()
+	if err != nil {
+		t.Fatalf("LoadUserConfig failed: %v", err)
+	}
+	if !loaded.Verbose || loaded.Shell != "pwsh" {
+		t.Errorf("Unexpected config after round trip: %+v", loaded)
+	}
+	if paths, _ := loaded.Get("plugin_paths"); paths != "/opt/a,/opt/b" {
+		t.Errorf("Expected plugin paths '/opt/a,/opt/b', got '%s'", paths)
+	}
+}
+
+func TestUserConfigSetValidation(t *testing.T) {
+	config := &internal.UserConfig{}
+
+	if err := config.Set("color", "purple"); err == nil {
+		t.Error("Expected error for invalid color value")
+	}
+	if err := config.Set("verbose", "maybe"); err == nil {
+		t.Error("Expected error for invalid verbose value")
+	}
+	if err := config.Set("nope", "x"); err == nil {
+		t.Error("Expected error for unknown key")
+	}
+	if _, err := config.Get("nope"); err == nil {
+		t.Error("Expected error for unknown key")
+	}
+}
+
+func TestUserConfigSettingsApply(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("LINEA_HOME", home)
+	t.Setenv("LINEA_CONFIG_DIR", "")
+	t.Setenv("LINEA_GLOBAL_WORKFLOWS", "")
+	t.Setenv("LINEA_BIN", "")
+
+	bin := filepath.Join(home, "linea-custom")
+	if err := os.WriteFile(bin, []byte(""), 0755); err != nil {
+		t.Fatalf("Failed to write fake executable: %v", err)
+	}
+
+	config := &internal.UserConfig{GlobalWorkflowsDir: "/srv/shared-workflows", LineaBin: bin}
+	if err := internal.SaveUserConfig(config); err != nil {
+		t.Fatalf("SaveUserConfig failed: %v", err)
+	}
+
+	if dir := internal.GlobalWorkflowsDir(); dir != "/srv/shared-workflows" {
+		t.Errorf("Expected global_workflows_dir from config, got %s", dir)
+	}
+	if path, err := internal.FindLineaExecutable(); err != nil || path != bin {
+		t.Errorf("Expected linea_bin from config, got %s (%v)", path, err)
+	}
+
+	t.Setenv("LINEA_GLOBAL_WORKFLOWS", "/env/workflows")
+	if dir := internal.GlobalWorkflowsDir(); dir != "/env/workflows" {
+		t.Errorf("Expected LINEA_GLOBAL_WORKFLOWS to win over config, got %s", dir)
+	}
+}
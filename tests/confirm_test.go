@@ -0,0 +1,69 @@
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+func TestAskConfirmation(t *testing.T) {
+	for answer, want := range map[string]bool{"y\n": true, "YES\n": true, " yes \r\n": true, "\n": false, "n\n": false, "sure\n": false, "": false} {
+		var out bytes.Buffer
+		if got := internal.AskConfirmation(strings.NewReader(answer), &out, "Drop the database?"); got != want {
+			t.Errorf("Expected %q to answer %v, got %v", answer, want, got)
+		}
+		if !strings.Contains(out.String(), "Drop the database? [y/N]") {
+			t.Errorf("Unexpected prompt: %q", out.String())
+		}
+	}
+}
+
+func TestConfirmStep(t *testing.T) {
+	config := &internal.CommandConfig{
+		Command:   "echo",
+		Confirm:   "About to drop the {env} database ($db), continue?",
+		Variables: map[string]string{"env": "prod", "db": "orders"},
+	}
+	message, err := internal.ConfirmMessage(config, map[string]string{"db": "users"})
+	if err != nil || message != "About to drop the prod database (users), continue?" {
+		t.Errorf("Expected the substituted prompt, got %q, %v", message, err)
+	}
+
+	// Tests do not run on a terminal, so the step cannot be confirmed without --yes
+	if err := internal.ConfirmStep(config, nil); !errors.Is(err, internal.ErrNotConfirmed) || !strings.Contains(err.Error(), "--yes") {
+		t.Errorf("Expected the step to need --yes, got %v", err)
+	}
+	internal.AssumeYes = true
+	defer func() { internal.AssumeYes = false }()
+	if err := internal.ConfirmStep(config, nil); err != nil {
+		t.Errorf("Expected --yes to confirm the step, got %v", err)
+	}
+}
+
+func TestRunStepsReportStopsAtUnconfirmedStep(t *testing.T) {
+	configs := []*internal.CommandConfig{
+		{Command: "echo", Args: []string{"backup"}},
+		{Command: "echo", Args: []string{"drop"}, Confirm: "Drop the database?"},
+		{Command: "echo", Args: []string{"restore"}},
+	}
+	results, err := internal.RunStepsReport(configs, nil, internal.ReportOptions{Stdout: io.Discard, Stderr: io.Discard})
+	if !errors.Is(err, internal.ErrNotConfirmed) {
+		t.Fatalf("Expected the run to fail at the confirmation, got %v", err)
+	}
+	var statuses []string
+	for _, result := range results {
+		statuses = append(statuses, result.Status)
+	}
+	if strings.Join(statuses, ",") != "success,failed,not_run" || results[1].ExitCode != 1 {
+		t.Errorf("Expected only the first step to run, got %v", results)
+	}
+
+	dryRun, err := internal.DryRunStepsReport(configs, nil, false)
+	if err != nil || dryRun[1].Confirm != "Drop the database?" || dryRun[0].Confirm != "" {
+		t.Errorf("Expected the dry run to show the confirmation, got %+v, %v", dryRun, err)
+	}
+}
@@ -0,0 +1,69 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+// writeDependencies writes one workflow per name into dir, each depending on the
+// workflows listed for it
+func writeDependencies(t *testing.T, dir string, workflows map[string]string) {
+	t.Helper()
+	for name, dependsOn := range workflows {
+		content := "version: 2\nname: " + name + "\ncommand: echo\nargs: [" + name + "]\n"
+		if dependsOn != "" {
+			content += "depends_on: [" + dependsOn + "]\n"
+		}
+		if err := os.WriteFile(filepath.Join(dir, name+".yml"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRunOrder(t *testing.T) {
+	dir := t.TempDir()
+	writeDependencies(t, dir, map[string]string{
+		"release": "build, test",
+		"test":    "build",
+		"build":   "",
+	})
+
+	order, err := internal.RunOrder([]string{filepath.Join(dir, "release.yml"), filepath.Join(dir, "test.yml")})
+	if err != nil {
+		t.Fatalf("RunOrder failed: %v", err)
+	}
+	var names []string
+	for _, path := range order {
+		names = append(names, internal.WorkflowName(path))
+	}
+	if got := strings.Join(names, ","); got != "build,test,release" {
+		t.Errorf("Expected build,test,release (each once), got %s", got)
+	}
+}
+
+func TestRunOrderCycle(t *testing.T) {
+	dir := t.TempDir()
+	writeDependencies(t, dir, map[string]string{"a": "b", "b": "a"})
+
+	_, err := internal.RunOrder([]string{filepath.Join(dir, "a.yml")})
+	if err == nil || !strings.Contains(err.Error(), "dependency cycle a -> b -> a") {
+		t.Errorf("Expected a dependency cycle error, got %v", err)
+	}
+}
+
+func TestValidateMissingDependency(t *testing.T) {
+	dir := t.TempDir()
+	writeDependencies(t, dir, map[string]string{"deploy": "nowhere-to-be-found"})
+
+	problems, err := internal.ValidateWorkflowDefinition(filepath.Join(dir, "deploy.yml"))
+	if err != nil {
+		t.Fatalf("ValidateWorkflowDefinition failed: %v", err)
+	}
+	if len(problems) != 1 || !strings.Contains(problems[0].Message, "depends_on:") {
+		t.Errorf("Expected a depends_on problem, got %+v", problems)
+	}
+}
@@ -0,0 +1,38 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"linea/internal"
+)
+
+func TestFindLineaExecutableFromEnv(t *testing.T) {
+	bin := filepath.Join(t.TempDir(), internal.LineaExecutableName())
+	if err := os.WriteFile(bin, []byte(""), 0755); err != nil {
+		t.Fatalf("Failed to create fake executable: %v", err)
+	}
+	t.Setenv("LINEA_BIN", bin)
+
+	path, err := internal.FindLineaExecutable()
+	if err != nil {
+		t.Fatalf("FindLineaExecutable failed: %v", err)
+	}
+	if path != bin {
+		t.Errorf("Expected LINEA_BIN path %s, got %s", bin, path)
+	}
+
+	candidates := internal.LineaExecutableCandidates()
+	if len(candidates) != 4 || candidates[0].Source != "LINEA_BIN" || candidates[1].Source != "config" || candidates[3].Source != "PATH" {
+		t.Errorf("Unexpected resolution order: %+v", candidates)
+	}
+}
+
+func TestFindLineaExecutableInvalidEnv(t *testing.T) {
+	t.Setenv("LINEA_BIN", filepath.Join(t.TempDir(), "missing"))
+
+	if _, err := internal.FindLineaExecutable(); err == nil {
+		t.Error("Expected error when LINEA_BIN points to a missing file")
+	}
+}
@@ -0,0 +1,47 @@
+package tests
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+func TestStepDisplayPlain(t *testing.T) {
+	var out bytes.Buffer
+	display := internal.NewStepDisplay(&out, nil)
+	if display.Live() {
+		t.Fatal("Expected a display without a terminal not to be live")
+	}
+	if w := display.Writer(&out); w != &out {
+		t.Error("Expected the writer to be passed through when not live")
+	}
+
+	configs := []*internal.CommandConfig{
+		{Name: "greet", Command: "echo", Args: []string{"hi"}},
+		{Command: "echo", Args: []string{"${missing}"}},
+	}
+	internal.RunStepsReport(configs, nil, internal.ReportOptions{Stdout: &out, Stderr: &out, Display: display, KeepGoing: true})
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) < 4 || lines[0] != "[1/2] greet..." || lines[1] != "hi" {
+		t.Fatalf("Expected a line before each step, got:\n%s", out.String())
+	}
+	if !strings.Contains(lines[2], "[1/2] greet (") || !strings.Contains(lines[3], "[2/2] echo...") {
+		t.Errorf("Expected the outcome of each step, got:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "[2/2] echo (0.0s)") {
+		t.Errorf("Expected the step that failed to build to be shown, got:\n%s", out.String())
+	}
+}
+
+func TestStepDisplayNil(t *testing.T) {
+	var display *internal.StepDisplay
+	display.StartStep(1, 1, "noop")
+	display.EndStep(internal.StepSucceeded, 0)
+	var out bytes.Buffer
+	if display.Writer(&out) != &out || display.Live() {
+		t.Error("Expected a nil display to do nothing")
+	}
+}
@@ -0,0 +1,98 @@
+package tests
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+func TestFilterEnvironment(t *testing.T) {
+	env := []string{"HOME=/home/me", "PATH=/bin", "AWS_REGION=eu-west-1", "AWS_PROFILE=prod", "GITHUB_TOKEN=secret", "EMPTY="}
+
+	got := internal.FilterEnvironment(env, []string{"PATH", "AWS_*", "EMPTY"})
+	want := []string{"PATH=/bin", "AWS_REGION=eu-west-1", "AWS_PROFILE=prod", "EMPTY="}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	if got := internal.FilterEnvironment(env, nil); len(got) != 0 {
+		t.Errorf("Expected no variables without patterns, got %v", got)
+	}
+}
+
+func TestStepEnvironment(t *testing.T) {
+	if env := internal.StepEnvironment(&internal.CommandConfig{Command: "env"}); env != nil {
+		t.Errorf("Expected the host environment without env_passthrough, got %v", env)
+	}
+
+	t.Setenv("LINEA_TEST_PASSTHROUGH", "yes")
+	env := internal.StepEnvironment(&internal.CommandConfig{Command: "env", EnvPassthrough: []string{"LINEA_TEST_*"}})
+	if len(env) != 1 || env[0] != "LINEA_TEST_PASSTHROUGH=yes" {
+		t.Errorf("Expected only LINEA_TEST_PASSTHROUGH, got %v", env)
+	}
+}
+
+func TestEnvPassthroughParsing(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "clean.yml")
+	content := "command: env\nenv_passthrough: []\n---\ncommand: env\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	configs, err := internal.ParseMultiYAML(path)
+	if err != nil {
+		t.Fatalf("ParseMultiYAML failed: %v", err)
+	}
+	if configs[0].EnvPassthrough == nil || len(configs[0].EnvPassthrough) != 0 {
+		t.Errorf("Expected env_passthrough: [] to pass no variables, got %#v", configs[0].EnvPassthrough)
+	}
+	if configs[1].EnvPassthrough != nil {
+		t.Errorf("Expected no env_passthrough on the second document, got %#v", configs[1].EnvPassthrough)
+	}
+}
+
+func TestExecuteStepEnvPassthrough(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses env")
+	}
+	t.Setenv("LINEA_TEST_KEEP", "kept")
+	t.Setenv("LINEA_TEST_DROP", "dropped")
+	config := &internal.CommandConfig{
+		Command:        "env",
+		EnvPassthrough: []string{"LINEA_TEST_KEEP"},
+	}
+
+	cmd, err := internal.BuildCommand(config, nil)
+	if err != nil {
+		t.Fatalf("BuildCommand failed: %v", err)
+	}
+	var out bytes.Buffer
+	if err := internal.ExecuteStepWithOutput(config, cmd, nil, &out, &out); err != nil {
+		t.Fatalf("ExecuteStepWithOutput failed: %v", err)
+	}
+	if out.String() != "LINEA_TEST_KEEP=kept\n" {
+		t.Errorf("Expected only LINEA_TEST_KEEP to reach the command, got %q", out.String())
+	}
+}
+
+func TestValidateEnvPassthrough(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bad.yml")
+	if err := os.WriteFile(path, []byte("command: env\nenv_passthrough: [\"AWS_[\"]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	problems, err := internal.ValidateWorkflowDefinition(path)
+	if err != nil {
+		t.Fatalf("ValidateWorkflowDefinition failed: %v", err)
+	}
+	if len(problems) != 1 || !strings.Contains(problems[0].Message, "env_passthrough") {
+		t.Errorf("Expected one env_passthrough problem, got %v", problems)
+	}
+}
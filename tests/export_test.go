@@ -0,0 +1,279 @@
+package tests
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"linea/internal"
+)
+
+const exportWorkflow = `version: 2
+name: Build
+command: echo
+args: ["building {app} for $env", "{timestamp}"]
+variables:
+  app: myapp
+  env: dev
+---
+name: Deploy
+command: sh
+args: ["-c", "echo deploying $app with $1; exit 3", "x", "$token"]
+allowed_exit_codes: [0, 3]
+when: "$env != skip"
+variables:
+  app: myapp
+  env: dev
+secrets:
+  token:
+    from: env
+    key: EXPORT_TEST_TOKEN
+---
+name: Fail
+command: "false"
+---
+name: Notify
+command: echo
+args: ["failed with {exit_code}"]
+on_failure: true
+---
+name: Cleanup
+command: echo
+args: ["cleanup"]
+after: true
+---
+name: Off
+command: echo
+args: ["never"]
+when: "false"
+`
+
+// exportScript exports a workflow with opts and returns the script
+func exportScript(t *testing.T, workflow string, opts internal.ExportOptions) *internal.ExportResult {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "deploy.yml")
+	os.WriteFile(path, []byte(workflow), 0644)
+	result, err := internal.ExportWorkflow(path, opts)
+	if err != nil {
+		t.Fatalf("ExportWorkflow failed: %v", err)
+	}
+	return result
+}
+
+func TestExportBash(t *testing.T) {
+	result := exportScript(t, exportWorkflow, internal.ExportOptions{Format: internal.ExportBash, Set: map[string]string{"env": "prod"}})
+	script := string(result.Script)
+
+	for _, want := range []string{
+		"#!/usr/bin/env bash\n",
+		`echo 'building myapp for prod' "${linea_timestamp}"`,
+		`: "${EXPORT_TEST_TOKEN?EXPORT_TEST_TOKEN is required`,
+		`sh -c 'echo deploying myapp with $1; exit 3' x "${EXPORT_TEST_TOKEN}"`,
+		"step_done $? 0 3\n",
+		"if [ \"$linea_status\" -ne 0 ]; then\n  echo \"failed with ${linea_exit_code}\"",
+		"# [5/6] Cleanup\necho cleanup\n",
+		"# [6/6] Off\n# Skipped: its when: condition is false\n",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("Script does not contain %q:\n%s", want, script)
+		}
+	}
+	if strings.Contains(script, "never") {
+		t.Errorf("Step with a false when: condition was exported:\n%s", script)
+	}
+	if strings.Contains(script, "linea_var_") {
+		t.Errorf("Baked script has parameters:\n%s", script)
+	}
+}
+
+func TestExportBashRuns(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("bash scripts are not run on Windows")
+	}
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not found")
+	}
+	result := exportScript(t, exportWorkflow, internal.ExportOptions{Format: internal.ExportBash, Parameterized: true})
+	path := filepath.Join(t.TempDir(), "deploy.sh")
+	os.WriteFile(path, result.Script, 0755)
+
+	cmd := exec.Command(bash, path, "app=my app")
+	cmd.Env = append(os.Environ(), "EXPORT_TEST_TOKEN=abc")
+	out, err := cmd.CombinedOutput()
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok || exitErr.ExitCode() != 1 {
+		t.Fatalf("Expected the script to exit with 1 (from the Fail step), got %v:\n%s", err, out)
+	}
+	want := "deploying my app with abc\nfailed with 1\ncleanup\n"
+	if !strings.HasSuffix(string(out), want) {
+		t.Errorf("Expected output ending with %q, got:\n%s", want, out)
+	}
+
+	// Parameters are checked, and the when: condition is tested by the script
+	out, err = exec.Command(bash, path, "bogus=1").CombinedOutput()
+	if err == nil || !strings.Contains(string(out), "Unknown variable: bogus=1") {
+		t.Errorf("Expected an unknown variable to be refused, got %v:\n%s", err, out)
+	}
+	cmd = exec.Command(bash, path, "env=skip")
+	cmd.Env = append(os.Environ(), "EXPORT_TEST_TOKEN=abc")
+	out, _ = cmd.CombinedOutput()
+	if strings.Contains(string(out), "deploying") {
+		t.Errorf("Expected the Deploy step to be skipped with env=skip:\n%s", out)
+	}
+}
+
+func TestExportParameterized(t *testing.T) {
+	result := exportScript(t, `version: 2
+name: Tag
+command: docker
+args: ["tag", "$image", "$registry/$image:$tag"]
+variables:
+  image: myapp
+  tag: latest
+`, internal.ExportOptions{Format: internal.ExportPowerShell, Parameterized: true, Set: map[string]string{"tag": "v1"}})
+	script := string(result.Script)
+
+	for _, want := range []string{
+		"param([hashtable]$Set = @{})\n",
+		"$linea_variables = @('image', 'registry')\n",
+		"if (-not $Set.ContainsKey('registry')) { throw",
+		`Invoke-Step { & docker tag $(Get-LineaVariable 'image' 'myapp') "$($Set['registry'])/$(Get-LineaVariable 'image' 'myapp'):v1" }`,
+		"#   image (default: myapp)\n#   registry (required)\n",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("Script does not contain %q:\n%s", want, script)
+		}
+	}
+}
+
+func TestExportNotes(t *testing.T) {
+	result := exportScript(t, `version: 2
+name: Wait
+type: sleep
+args: ["1"]
+---
+name: Call
+command: curl
+args: ["-H", "Authorization: $token", "https://example.com"]
+secrets:
+  token:
+    key: api_token
+`, internal.ExportOptions{Format: internal.ExportBash})
+
+	notes := strings.Join(result.Notes, "\n")
+	for _, want := range []string{
+		"step Wait: type: sleep needs linea",
+		"the secret token is read from the environment variable api_token instead of the file backend",
+	} {
+		if !strings.Contains(notes, want) {
+			t.Errorf("Notes do not contain %q:\n%s", want, notes)
+		}
+	}
+	if !strings.Contains(string(result.Script), "echo 'This step needs linea (type: sleep)' >&2; false") {
+		t.Errorf("Expected the type: step to fail the script:\n%s", result.Script)
+	}
+
+	path := filepath.Join(t.TempDir(), "wait.yml")
+	os.WriteFile(path, []byte("version: 2\ncommand: echo\n"), 0644)
+	if _, err := internal.ExportWorkflow(path, internal.ExportOptions{Format: "fish"}); err == nil {
+		t.Errorf("Expected an error for an unknown format")
+	}
+}
+
+func TestExportGitHubActions(t *testing.T) {
+	result := exportScript(t, exportWorkflow, internal.ExportOptions{Format: internal.ExportGitHubActions, Parameterized: true, Matrix: map[string][]string{"env": {"dev", "prod"}}})
+	var workflow struct {
+		On struct {
+			WorkflowDispatch struct {
+				Inputs map[string]map[string]interface{}
+			} `yaml:"workflow_dispatch"`
+		} `yaml:"on"`
+		Jobs map[string]struct {
+			Strategy struct{ Matrix map[string][]string }
+			Env      map[string]string
+			Steps    []struct {
+				Name, Uses, If, Run string
+				Env                 map[string]string
+			}
+		}
+	}
+	if err := yaml.Unmarshal(result.Script, &workflow); err != nil {
+		t.Fatalf("Invalid YAML: %v\n%s", err, result.Script)
+	}
+	job, ok := workflow.Jobs["deploy"]
+	if !ok {
+		t.Fatalf("Expected a deploy job:\n%s", result.Script)
+	}
+
+	if got := workflow.On.WorkflowDispatch.Inputs["app"]["default"]; got != "myapp" {
+		t.Errorf("Expected an app input defaulting to myapp, got %v", got)
+	}
+	if _, ok := workflow.On.WorkflowDispatch.Inputs["env"]; ok {
+		t.Errorf("Matrix variables should not be inputs")
+	}
+	if got := strings.Join(job.Strategy.Matrix["env"], ","); got != "dev,prod" {
+		t.Errorf("Expected the env matrix dev,prod, got %s", got)
+	}
+	if job.Env["app"] != "${{ inputs.app || 'myapp' }}" || job.Env["env"] != "${{ matrix.env }}" {
+		t.Errorf("Unexpected job env %v", job.Env)
+	}
+
+	// The checkout, then every step but the one whose when: condition is false
+	if len(job.Steps) != 6 || job.Steps[0].Uses != "actions/checkout@v4" {
+		t.Fatalf("Expected the checkout and 5 steps, got:\n%s", result.Script)
+	}
+	deploy := job.Steps[2]
+	if deploy.Env["EXPORT_TEST_TOKEN"] != "${{ secrets.EXPORT_TEST_TOKEN }}" {
+		t.Errorf("Expected the secret in the step env, got %v", deploy.Env)
+	}
+	for _, want := range []string{`if [ "${env}" != skip ]; then`, `|| code=$?`, `case "$code" in 0|3)`} {
+		if !strings.Contains(deploy.Run, want) {
+			t.Errorf("Deploy script does not contain %q:\n%s", want, deploy.Run)
+		}
+	}
+	if job.Steps[4].If != "failure()" || job.Steps[5].If != "always()" {
+		t.Errorf("Expected failure() and always() for the on_failure and after steps, got %q and %q", job.Steps[4].If, job.Steps[5].If)
+	}
+	if !strings.Contains(job.Steps[1].Run, "linea_timestamp=$(date -u") {
+		t.Errorf("Expected the Build step to compute {timestamp}:\n%s", job.Steps[1].Run)
+	}
+}
+
+func TestExportGitLab(t *testing.T) {
+	result := exportScript(t, exportWorkflow, internal.ExportOptions{Format: internal.ExportGitLab, Parameterized: true})
+	var pipeline struct {
+		Variables map[string]struct{ Value, Description string }
+		Deploy    struct {
+			Script      []string
+			AfterScript []string `yaml:"after_script"`
+		}
+	}
+	if err := yaml.Unmarshal(result.Script, &pipeline); err != nil {
+		t.Fatalf("Invalid YAML: %v\n%s", err, result.Script)
+	}
+
+	if pipeline.Variables["env"].Value != "dev" || pipeline.Variables["app"].Value != "myapp" {
+		t.Errorf("Expected the app and env variables with their defaults, got %v", pipeline.Variables)
+	}
+	if len(pipeline.Deploy.Script) != 3 || pipeline.Deploy.Script[2] != "false" {
+		t.Fatalf("Expected 3 script entries, got %q", pipeline.Deploy.Script)
+	}
+	if len(pipeline.Deploy.AfterScript) != 2 || !strings.HasPrefix(pipeline.Deploy.AfterScript[0], `if [ "$CI_JOB_STATUS" = failed ]; then`) {
+		t.Errorf("Expected the on_failure step guarded by CI_JOB_STATUS in after_script, got %q", pipeline.Deploy.AfterScript)
+	}
+	if !strings.Contains(strings.Join(result.Notes, "\n"), "the secret EXPORT_TEST_TOKEN must be a CI/CD variable") {
+		t.Errorf("Expected a note about the secret, got %q", result.Notes)
+	}
+
+	path := filepath.Join(t.TempDir(), "deploy.yml")
+	os.WriteFile(path, []byte(exportWorkflow), 0644)
+	if _, err := internal.ExportWorkflow(path, internal.ExportOptions{Format: internal.ExportBash, Matrix: map[string][]string{"env": {"a"}}}); err == nil {
+		t.Errorf("Expected --matrix to be refused for a script")
+	}
+}
@@ -0,0 +1,105 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+func TestEvaluateExpression(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.zip")
+	os.WriteFile(file, []byte("zip"), 0644)
+	t.Setenv("LINEA_EXPR_TEST", "set")
+
+	vars := map[string]string{"env": "prod", "msg": "a and b", "count": "10", "empty": "", "file": file}
+	tests := []struct{ expr, want string }{
+		{"{env} == prod and {count} > 9", "true"},
+		{"{count} < 9 or {env} != prod", "false"},
+		{"not ({env} == dev || {env} == prod)", "false"},
+		{"{msg} == 'a and b'", "true"},
+		{"{empty} == ''", "true"},
+		{"{empty} or fallback", "fallback"},
+		{"{env} and {count}", "10"},
+		{"exists({file}) && !exists({file}.bak)", "true"},
+		{"env(LINEA_EXPR_TEST) == set", "true"},
+		{"env(LINEA_EXPR_UNSET, 'none')", "none"},
+		{"contains({msg}, 'and') and starts_with({env}, pr) and ends_with({env}, od)", "true"},
+		{"matches({count}, '^[0-9]+$')", "true"},
+		{"upper(trim(' x ')) == X and len({msg}) == 7", "true"},
+		{"'10' < '9'", "false"},
+		{"ab < b", "true"},
+		{"hello world", "hello world"},
+		{"--flag=1 == --flag=1", "true"},
+	}
+	for _, tt := range tests {
+		got, err := internal.EvaluateExpression(tt.expr, vars, vars)
+		if err != nil {
+			t.Errorf("EvaluateExpression(%q) failed: %v", tt.expr, err)
+		} else if got != tt.want {
+			t.Errorf("EvaluateExpression(%q) = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+
+	for _, expr := range []string{"a == b == c", "nope(1)", "len(a, b)", "(a == b", "a == b)", "'a", "a and", "matches(a, '(')"} {
+		if _, err := internal.EvaluateExpression(expr, nil, nil); err == nil {
+			t.Errorf("Expected an error for %q", expr)
+		}
+	}
+}
+
+func TestExpressionDefault(t *testing.T) {
+	t.Setenv("LINEA_EXPR_REGION", "eu-west-1")
+	for _, tt := range []struct{ value, want string }{
+		{"plain", "plain"},
+		{"=env(LINEA_EXPR_REGION)", "eu-west-1"},
+		{"=env(LINEA_EXPR_UNSET) or us-east-1", "us-east-1"},
+		{"==x", "=x"},
+	} {
+		if got, err := internal.ExpressionDefault(tt.value); err != nil || got != tt.want {
+			t.Errorf("ExpressionDefault(%q) = %q, %v, want %q", tt.value, got, err, tt.want)
+		}
+	}
+
+	path := writeWorkflow(t, t.TempDir(), "region.yml", `inputs:
+  - name: region
+    default: "=env('LINEA_EXPR_REGION', 'us-east-1')"
+---
+command: echo
+args: ["$region", "{zone}"]
+variables:
+  zone:
+    provider: env
+    name: LINEA_EXPR_UNSET
+    default: "=env('LINEA_EXPR_REGION') + a"
+`)
+	inputs, err := internal.WorkflowInputs(path)
+	if err != nil {
+		t.Fatalf("WorkflowInputs failed: %v", err)
+	}
+	vars, err := internal.BindInputs("region", inputs, nil, nil)
+	if err != nil || vars["region"] != "eu-west-1" {
+		t.Errorf("Expected the default expression to be evaluated, got %v %v", vars, err)
+	}
+
+	problems, _ := internal.ValidateWorkflowFile(path, vars)
+	if len(problems) != 1 || !strings.Contains(problems[0].Message, "variable zone: default: invalid expression") {
+		t.Errorf("Expected the invalid default to be reported, got %v", problems)
+	}
+}
+
+func TestWhenExpressionProblems(t *testing.T) {
+	path := writeWorkflow(t, t.TempDir(), "when.yml", "when: \"starts(x)\"\ncommand: echo\n")
+	problems, err := internal.ValidateWorkflowFile(path, nil)
+	if err != nil || len(problems) != 1 || !strings.Contains(problems[0].Message, "unknown function starts()") {
+		t.Errorf("Expected the unknown function to be reported, got %v %v", problems, err)
+	}
+
+	config := &internal.CommandConfig{Command: "echo", When: "{v} == 'x or y'", Variables: map[string]string{"v": "x or y"}}
+	if run, err := internal.ShouldRunStep(config, nil); err != nil || !run {
+		t.Errorf("Expected the value to be compared as a whole, got %v, %v", run, err)
+	}
+}
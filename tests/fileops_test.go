@@ -0,0 +1,133 @@
+package tests
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+// runFileStep builds and runs a step, returning its output
+func runFileStep(t *testing.T, config *internal.CommandConfig, overrides map[string]string) (string, error) {
+	t.Helper()
+	cmd, err := internal.BuildCommand(config, overrides)
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	err = internal.ExecuteStepWithOutput(config, cmd, nil, &out, &out)
+	return out.String(), err
+}
+
+func TestCopyMoveMkdirRmSteps(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	os.MkdirAll(filepath.Join(src, "sub"), 0755)
+	os.WriteFile(filepath.Join(src, "a.txt"), []byte("a"), 0644)
+	os.WriteFile(filepath.Join(src, "sub", "run.sh"), []byte("#!/bin/sh"), 0755)
+	out := filepath.Join(dir, "out")
+
+	steps := []*internal.CommandConfig{
+		{Type: internal.StepTypeMkdir, Mkdir: &internal.FileSpec{Path: filepath.Join(out, "deep")}},
+		{Type: internal.StepTypeCopy, Copy: &internal.FileSpec{Src: src, Dest: out}},
+		{Type: internal.StepTypeMove, Move: &internal.FileSpec{Src: filepath.Join(out, "src", "a.txt"), Dest: filepath.Join(out, "deep", "moved.txt")}},
+		{Type: internal.StepTypeRm, Rm: &internal.FileSpec{Path: filepath.Join(out, "src", "sub")}},
+		{Type: internal.StepTypeRm, Rm: &internal.FileSpec{Path: filepath.Join(out, "missing")}},
+	}
+	for i, config := range steps {
+		if output, err := runFileStep(t, config, nil); err != nil {
+			t.Fatalf("Step %d failed: %v\n%s", i+1, err, output)
+		}
+	}
+
+	// The copy went into the existing directory out
+	if data, err := os.ReadFile(filepath.Join(out, "deep", "moved.txt")); err != nil || string(data) != "a" {
+		t.Errorf("Expected the copied file to be moved, got %q, %v", data, err)
+	}
+	for _, path := range []string{filepath.Join(out, "src", "a.txt"), filepath.Join(out, "src", "sub")} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be gone, got %v", path, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(src, "sub", "run.sh")); err != nil {
+		t.Errorf("Expected the source of the copy to be left alone, got %v", err)
+	}
+}
+
+func TestCopyKeepsPermissions(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "run.sh")
+	os.WriteFile(script, []byte("#!/bin/sh"), 0755)
+	dest := filepath.Join(dir, "bin", "run")
+	config := &internal.CommandConfig{Type: internal.StepTypeCopy, Copy: &internal.FileSpec{Src: script, Dest: dest}}
+	if output, err := runFileStep(t, config, nil); err != nil {
+		t.Fatalf("Copy failed: %v\n%s", err, output)
+	}
+	info, err := os.Stat(dest)
+	if err != nil {
+		t.Fatalf("Expected the file to be copied: %v", err)
+	}
+	if want, _ := os.Stat(script); info.Mode() != want.Mode() {
+		t.Errorf("Expected mode %v, got %v", want.Mode(), info.Mode())
+	}
+}
+
+func TestRmStepRefusesCurrentDirectory(t *testing.T) {
+	config := &internal.CommandConfig{Type: internal.StepTypeRm, Rm: &internal.FileSpec{Path: "./"}}
+	if _, err := runFileStep(t, config, nil); internal.ExitCode(err) != 1 || !strings.Contains(err.Error(), "refusing") {
+		t.Errorf("Expected rm of the current directory to be refused, got %v", err)
+	}
+}
+
+func TestTemplateStep(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "app.json.tmpl")
+	os.WriteFile(src, []byte(`{"port": {port}, "env": "{env|upper}", "host": "$host", "home": "$HOME", "tag": "{tag|default:latest}"}`), 0600)
+	dest := filepath.Join(dir, "conf", "app.json")
+	config := &internal.CommandConfig{
+		Type:      internal.StepTypeTemplate,
+		Template:  &internal.FileSpec{Src: src, Dest: dest},
+		Variables: map[string]string{"port": "8080", "env": "prod", "host": "localhost"},
+	}
+
+	cmd, err := internal.BuildCommand(config, map[string]string{"host": "example.com"})
+	if err != nil {
+		t.Fatalf("BuildCommand failed: %v", err)
+	}
+	want := "template " + src + " " + dest + " --var port 8080 --var env prod --dollar-var host example.com"
+	if strings.Join(cmd, " ") != want {
+		t.Errorf("Expected %s, got %s", want, strings.Join(cmd, " "))
+	}
+	if output, err := runFileStep(t, config, map[string]string{"host": "example.com"}); err != nil {
+		t.Fatalf("Template failed: %v\n%s", err, output)
+	}
+	data, _ := os.ReadFile(dest)
+	if string(data) != `{"port": 8080, "env": "PROD", "host": "example.com", "home": "$HOME", "tag": "latest"}` {
+		t.Errorf("Unexpected rendering: %s", data)
+	}
+
+	os.WriteFile(src, []byte("listen {prot};"), 0600)
+	if _, err := runFileStep(t, config, nil); err == nil || !strings.Contains(err.Error(), "undefined variables: prot") {
+		t.Errorf("Expected an undefined placeholder to fail the step, got %v", err)
+	}
+}
+
+func TestCheckFileStep(t *testing.T) {
+	for _, config := range []*internal.CommandConfig{
+		{Type: internal.StepTypeCopy},
+		{Type: internal.StepTypeCopy, Copy: &internal.FileSpec{Src: "a"}},
+		{Type: internal.StepTypeMkdir, Mkdir: &internal.FileSpec{Src: "a", Dest: "b"}},
+		{Type: internal.StepTypeRm, Copy: &internal.FileSpec{Src: "a", Dest: "b"}, Rm: &internal.FileSpec{Path: "a"}},
+		{Command: "echo", Template: &internal.FileSpec{Src: "a", Dest: "b"}},
+	} {
+		if err := internal.CheckFileStep(config); err == nil {
+			t.Errorf("Expected %+v to be rejected", config)
+		}
+	}
+	if err := internal.CheckFileStep(&internal.CommandConfig{Type: internal.StepTypeMove, Move: &internal.FileSpec{Src: "a", Dest: "b"}}); err != nil {
+		t.Errorf("Expected a valid move step, got %v", err)
+	}
+}
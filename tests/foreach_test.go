@@ -0,0 +1,99 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+const foreachWorkflow = `name: deploy
+foreach: "$hosts"
+command: echo
+args: ["{index}", "{item}"]
+variables:
+  hosts: [web1, web2]
+---
+name: check
+foreach:
+  - {host: a, port: 80}
+  - {host: b, port: 81}
+command: echo
+args: ["{item.host}:{item.port}"]
+capture: true
+---
+name: last
+command: cat
+stdin:
+  step: check[1]
+`
+
+func TestExpandForeach(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deploy.yml")
+	os.WriteFile(path, []byte(foreachWorkflow), 0644)
+	if problems, err := internal.ValidateWorkflowFile(path, nil); err != nil || len(problems) != 0 {
+		t.Errorf("Expected no problems, got %v %v", problems, err)
+	}
+
+	configs, err := internal.ParseMultiYAML(path)
+	if err != nil {
+		t.Fatalf("ParseMultiYAML failed: %v", err)
+	}
+	steps, err := internal.ExpandForeach(configs, nil)
+	if err != nil {
+		t.Fatalf("ExpandForeach failed: %v", err)
+	}
+	var names, commands []string
+	for _, step := range steps {
+		names = append(names, step.Name)
+		cmd, err := internal.BuildCommand(step, nil)
+		if err != nil {
+			t.Fatalf("BuildCommand %s failed: %v", step.Name, err)
+		}
+		commands = append(commands, strings.Join(cmd, " "))
+	}
+	if strings.Join(names, ",") != "deploy[0],deploy[1],check[0],check[1],last" {
+		t.Errorf("Unexpected steps %v", names)
+	}
+	if commands[0] != "echo 0 web1" || commands[1] != "echo 1 web2" || commands[3] != "echo b:81" {
+		t.Errorf("Unexpected commands %v", commands)
+	}
+	if configs[0].Variables["item"] != "" || configs[0].Foreach == nil {
+		t.Errorf("Expected the parsed step to be left unchanged")
+	}
+
+	// $hosts takes the -s/--set-json value
+	steps, err = internal.ExpandForeach(configs, map[string]string{"hosts": `["x"]`})
+	if err != nil || len(steps) != 4 || steps[0].Variables["item"] != "x" {
+		t.Errorf("Expected one deploy step for x, got %d steps %v", len(steps), err)
+	}
+	if steps, err := internal.ExpandForeach(configs, map[string]string{"hosts": "[]"}); err != nil || len(steps) != 3 {
+		t.Errorf("Expected an empty list to run the step zero times, got %d steps %v", len(steps), err)
+	}
+	if _, err := internal.ExpandForeach(configs, map[string]string{"hosts": "web1"}); err == nil || !strings.Contains(err.Error(), "step deploy: foreach: $hosts is not a list (got 'web1')") {
+		t.Errorf("Expected a value that is not a list to be an error, got %v", err)
+	}
+}
+
+func TestCheckForeach(t *testing.T) {
+	for _, tc := range []struct {
+		config internal.CommandConfig
+		want   string
+	}{
+		{internal.CommandConfig{Foreach: &internal.ForeachSpec{}}, "foreach needs a list or a reference to a list variable"},
+		{internal.CommandConfig{Command: "sleep", Background: true, Foreach: &internal.ForeachSpec{Items: []string{"1"}}}, "a background step with foreach needs a name"},
+	} {
+		if err := internal.CheckForeach(&tc.config); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Expected an error containing %q, got %v", tc.want, err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "deploy.yml")
+	os.WriteFile(path, []byte(strings.Replace(foreachWorkflow, `"$hosts"`, `"{servers}"`, 1)), 0644)
+	problems, _ := internal.ValidateWorkflowFile(path, nil)
+	if len(problems) != 1 || !strings.Contains(problems[0].Message, "undefined variable {servers}") {
+		t.Errorf("Expected linea validate to report the reference of foreach, got %v", problems)
+	}
+}
@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"linea/internal"
+)
+
+func TestFormatWorkflow(t *testing.T) {
+	input := `# Deploy the app
+args: ['{name}', plain, 'true', -s, "a: b"]
+command:    echo
+variables: {name: x, multi: "a\nb", n: 5}
+name: deploy
+---
+command: ls
+`
+	expected := `# Deploy the app
+name: deploy
+command: echo
+args:
+  - "{name}"
+  - plain
+  - "true"
+  - -s
+  - "a: b"
+variables:
+  name: x
+  multi: |-
+    a
+    b
+  n: 5
+---
+command: ls
+`
+
+	formatted, err := internal.FormatWorkflow([]byte(input), false)
+	if err != nil {
+		t.Fatalf("FormatWorkflow failed: %v", err)
+	}
+	if string(formatted) != expected {
+		t.Errorf("Unexpected formatting:\n%s\nexpected:\n%s", formatted, expected)
+	}
+
+	again, err := internal.FormatWorkflow(formatted, false)
+	if err != nil || string(again) != string(formatted) {
+		t.Errorf("Expected formatting to be idempotent, got:\n%s", again)
+	}
+
+	if _, err := internal.FormatWorkflow([]byte("command: [echo\n"), false); err == nil {
+		t.Error("Expected error for invalid YAML")
+	}
+}
+
+func TestFormatWorkflowFilePreservesMeaning(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "examples", "*.yml"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("No example workflows found (%v)", err)
+	}
+
+	for _, file := range files {
+		formatted, _, err := internal.FormatWorkflowFile(file, false)
+		if err != nil {
+			t.Fatalf("FormatWorkflowFile(%s) failed: %v", file, err)
+		}
+		path := writeWorkflow(t, t.TempDir(), filepath.Base(file), string(formatted))
+
+		before, err := internal.ParseMultiYAML(file)
+		if err != nil {
+			continue // Examples without commands are templates
+		}
+		after, err := internal.ParseMultiYAML(path)
+		if err != nil {
+			t.Fatalf("Formatted %s no longer parses: %v", file, err)
+		}
+		for i := range before {
+			before[i].SourceFile, after[i].SourceFile = "", ""
+		}
+		if !reflect.DeepEqual(before, after) {
+			t.Errorf("Formatting changed the meaning of %s:\n%s", file, formatted)
+		}
+	}
+}
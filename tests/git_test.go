@@ -0,0 +1,149 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+// git runs a git command in dir and returns its trimmed output
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=linea", "-c", "user.email=linea@example.com"}, args...)...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v failed: %v\n%s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// newGitOrigin creates a bare repository with one commit on main
+func newGitOrigin(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	origin := filepath.Join(dir, "origin.git")
+	work := filepath.Join(dir, "seed")
+	git(t, dir, "init", "--bare", "-b", "main", origin)
+	git(t, dir, "init", "-b", "main", work)
+	os.WriteFile(filepath.Join(work, "README.md"), []byte("app"), 0644)
+	git(t, work, "add", "README.md")
+	git(t, work, "commit", "-m", "Initial commit")
+	git(t, work, "push", origin, "main")
+	return origin
+}
+
+func TestGitSteps(t *testing.T) {
+	origin := newGitOrigin(t)
+	dir := filepath.Join(t.TempDir(), "app")
+	t.Setenv("GIT_AUTHOR_NAME", "linea")
+	t.Setenv("GIT_AUTHOR_EMAIL", "linea@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "linea")
+	t.Setenv("GIT_COMMITTER_EMAIL", "linea@example.com")
+
+	steps := []*internal.CommandConfig{
+		{Type: internal.StepTypeGit, Git: &internal.GitSpec{Action: internal.GitClone, Repo: origin, Dir: dir, Ref: "main", Depth: 1}},
+		{Type: internal.StepTypeGit, Git: &internal.GitSpec{Action: internal.GitTag, Dir: dir, Tag: "v{version}", Message: "Release {version}"}, Variables: map[string]string{"version": "1.0.0"}},
+		{Type: internal.StepTypeGit, Git: &internal.GitSpec{Action: internal.GitPush, Dir: dir, Tag: "v1.0.0"}},
+		{Type: internal.StepTypeGit, Git: &internal.GitSpec{Action: internal.GitCheckout, Dir: dir, Ref: "v1.0.0"}},
+	}
+	for i, config := range steps {
+		if output, err := runFileStep(t, config, nil); err != nil {
+			t.Fatalf("Step %d failed: %v\n%s", i+1, err, output)
+		}
+	}
+	if tag := git(t, origin, "for-each-ref", "--format=%(objecttype) %(refname)", "refs/tags"); tag != "tag refs/tags/v1.0.0" {
+		t.Errorf("Expected the annotated tag to be pushed, got %q", tag)
+	}
+	if head := git(t, dir, "describe", "--tags"); head != "v1.0.0" {
+		t.Errorf("Expected the tag to be checked out, got %q", head)
+	}
+
+	config := &internal.CommandConfig{Type: internal.StepTypeGit, Git: &internal.GitSpec{Action: internal.GitCheckout, Dir: dir, Ref: "missing"}}
+	if _, err := runFileStep(t, config, nil); internal.ExitCode(err) == 0 {
+		t.Errorf("Expected git's failure to fail the step, got %v", err)
+	}
+}
+
+func TestGitStepToken(t *testing.T) {
+	origin := newGitOrigin(t)
+	var users []string
+	backend := &cgi.Handler{
+		Path: filepath.Join(git(t, ".", "--exec-path"), "git-http-backend"),
+		Env:  []string{"GIT_PROJECT_ROOT=" + filepath.Dir(origin), "GIT_HTTP_EXPORT_ALL=1"},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if !ok || password != "s3cret" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="git"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		users = append(users, user)
+		backend.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	dir := filepath.Join(t.TempDir(), "app")
+	config := &internal.CommandConfig{
+		Type:    internal.StepTypeGit,
+		Git:     &internal.GitSpec{Action: internal.GitClone, Repo: server.URL + "/origin.git", Dir: dir, Token: "{token}"},
+		Secrets: map[string]internal.SecretRef{"token": {From: "env", Key: "LINEA_TEST_GIT_TOKEN"}},
+	}
+	t.Setenv("LINEA_TEST_GIT_TOKEN", "s3cret")
+	if output, err := runFileStep(t, config, nil); err != nil {
+		t.Fatalf("Clone failed: %v\n%s", err, output)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "README.md")); err != nil || len(users) == 0 || users[0] != "x-access-token" {
+		t.Errorf("Expected a clone authenticated with the token, got %v and users %v", err, users)
+	}
+
+	t.Setenv("LINEA_TEST_GIT_TOKEN", "wrong")
+	config.Git.Dir = filepath.Join(t.TempDir(), "app")
+	if _, err := runFileStep(t, config, nil); internal.ExitCode(err) == 0 {
+		t.Errorf("Expected a refused token to fail the step instead of prompting, got %v", err)
+	}
+}
+
+func TestGitVariables(t *testing.T) {
+	origin := newGitOrigin(t)
+	dir := filepath.Join(t.TempDir(), "app")
+	git(t, ".", "clone", origin, dir)
+	os.WriteFile(filepath.Join(dir, "linea.yaml"), []byte("command: echo"), 0644)
+
+	vars := internal.BuiltinVariables(&internal.CommandConfig{SourceFile: filepath.Join(dir, "linea.yaml")})
+	if vars["git_branch"] != "main" || vars["git_sha"] != git(t, dir, "rev-parse", "HEAD") || vars["git_dirty"] != "true" {
+		t.Errorf("Unexpected git variables: branch %q, sha %q, dirty %q", vars["git_branch"], vars["git_sha"], vars["git_dirty"])
+	}
+
+	outside := t.TempDir()
+	vars = internal.BuiltinVariables(&internal.CommandConfig{SourceFile: filepath.Join(outside, "linea.yaml")})
+	if value, ok := vars["git_sha"]; !ok || value != "" {
+		t.Errorf("Expected an empty git_sha outside a repository, got %q", value)
+	}
+}
+
+func TestCheckGitStep(t *testing.T) {
+	for _, config := range []*internal.CommandConfig{
+		{Type: internal.StepTypeGit},
+		{Type: internal.StepTypeGit, Git: &internal.GitSpec{Action: "pull"}},
+		{Type: internal.StepTypeGit, Git: &internal.GitSpec{Action: internal.GitClone}},
+		{Type: internal.StepTypeGit, Git: &internal.GitSpec{Action: internal.GitCheckout, Ref: "main", Depth: 1}},
+		{Type: internal.StepTypeGit, Git: &internal.GitSpec{Action: internal.GitTag, Tag: "v1", Token: "x"}},
+		{Type: internal.StepTypeGit, Git: &internal.GitSpec{Action: internal.GitPush, Username: "ci"}},
+		{Command: "git", Git: &internal.GitSpec{Action: internal.GitPush}},
+	} {
+		if err := internal.CheckGitStep(config); err == nil {
+			t.Errorf("Expected %+v to be rejected", config)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+func TestInstallGlobalWorkflow(t *testing.T) {
+	global := t.TempDir()
+	t.Setenv("LINEA_GLOBAL_WORKFLOWS", global)
+
+	src := writeWorkflow(t, t.TempDir(), "cleanup.yml", "command: docker\nargs: [system, prune]\n")
+
+	dest, err := internal.InstallGlobalWorkflow(src, "", false)
+	if err != nil {
+		t.Fatalf("InstallGlobalWorkflow failed: %v", err)
+	}
+	if dest != filepath.Join(global, "cleanup.yml") {
+		t.Errorf("Unexpected install path %s", dest)
+	}
+
+	if _, err := internal.InstallGlobalWorkflow(src, "", false); err == nil || !strings.Contains(err.Error(), "--force") {
+		t.Errorf("Expected error suggesting --force for existing workflow, got %v", err)
+	}
+	if _, err := internal.InstallGlobalWorkflow(src, "", true); err != nil {
+		t.Errorf("Expected --force to replace the workflow, got %v", err)
+	}
+
+	if dest, err := internal.InstallGlobalWorkflow(src, "prune", false); err != nil || internal.WorkflowName(dest) != "prune" {
+		t.Errorf("Expected install under a new name, got %s (%v)", dest, err)
+	}
+
+	invalid := writeWorkflow(t, t.TempDir(), "broken.yml", "args: [x]\n")
+	if _, err := internal.InstallGlobalWorkflow(invalid, "", false); err == nil {
+		t.Error("Expected error installing an invalid workflow")
+	}
+}
+
+func TestLineashGlobalWorkflowFallback(t *testing.T) {
+	global := t.TempDir()
+	t.Setenv("LINEA_GLOBAL_WORKFLOWS", global)
+	writeWorkflow(t, global, "cleanup.yml", "command: echo\n")
+	writeWorkflow(t, global, "build.yml", "command: echo\n")
+
+	bin := filepath.Join(t.TempDir(), internal.LineaExecutableName())
+	if err := os.WriteFile(bin, []byte(""), 0755); err != nil {
+		t.Fatalf("Failed to create fake executable: %v", err)
+	}
+	t.Setenv("LINEA_BIN", bin)
+
+	project := t.TempDir()
+	writeWorkflow(t, filepath.Join(project, ".linea", "workflows"), "build.yml", "command: make\n")
+
+	ctx, err := internal.NewLineashContext(filepath.Join(project, "script.lnsh"))
+	if err != nil {
+		t.Fatalf("NewLineashContext failed: %v", err)
+	}
+
+	workflows, err := ctx.GetAvailableWorkflows()
+	if err != nil {
+		t.Fatalf("GetAvailableWorkflows failed: %v", err)
+	}
+	sort.Strings(workflows)
+	if strings.Join(workflows, ",") != "build,cleanup" {
+		t.Errorf("Expected local and global workflows without duplicates, got %v", workflows)
+	}
+	if !ctx.IsWorkflowCommand("cleanup") {
+		t.Error("Expected global workflow to be a lineash command")
+	}
+
+	// Scripts outside any project can still use global workflows
+	if _, err := internal.NewLineashContext(filepath.Join(t.TempDir(), "script.lnsh")); err != nil {
+		t.Errorf("Expected global workflows to be enough for a lineash context, got %v", err)
+	}
+}
+
+func TestInstallGlobalWorkflowWithCommandLineVariables(t *testing.T) {
+	t.Setenv("LINEA_GLOBAL_WORKFLOWS", t.TempDir())
+
+	// $name is provided with -s/--set at run time, so the workflow is still installable
+	src := writeWorkflow(t, t.TempDir(), "greet.yml", "command: echo\nargs: [\"Hello $name\"]\n")
+	if _, err := internal.InstallGlobalWorkflow(src, "", false); err != nil {
+		t.Errorf("Expected workflow using -s variables to install, got %v", err)
+	}
+
+	undefined := writeWorkflow(t, t.TempDir(), "broken.yml", "command: echo\nargs: [\"{missing}\"]\n")
+	if _, err := internal.InstallGlobalWorkflow(undefined, "", false); err == nil {
+		t.Error("Expected error for undefined {missing} reference")
+	}
+}
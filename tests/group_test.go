@@ -0,0 +1,153 @@
+package tests
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+const groupWorkflow = `command: echo
+args: ["before $tag"]
+variables:
+  tag: outer
+---
+group: build
+inputs:
+  tag: "$tag"
+variables:
+  image: app
+steps:
+  - name: manifest
+    command: echo
+    args: ["{image}:{tag}"]
+    capture: true
+  - name: private
+    command: echo
+    args: ["private"]
+    capture: true
+  - command: cat
+    stdin:
+      step: private
+exports: [manifest]
+---
+command: cat
+stdin:
+  step: manifest
+`
+
+func TestGroupScope(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses cat")
+	}
+	path := writeWorkflow(t, t.TempDir(), "group.yml", groupWorkflow)
+	configs, err := internal.ParseMultiYAML(path)
+	if err != nil {
+		t.Fatalf("ParseMultiYAML failed: %v", err)
+	}
+	if len(configs) != 5 {
+		t.Fatalf("Expected 5 steps, got %d", len(configs))
+	}
+	if configs[1].Group != "build" || configs[1].Name != "manifest" || !configs[1].Exported || configs[2].Exported {
+		t.Errorf("Unexpected group steps: %+v, %+v", configs[1], configs[2])
+	}
+
+	var out bytes.Buffer
+	if err := internal.ExecuteMultipleCommandsWithOutput(configs, map[string]string{"tag": "1.2"}, false, false, &out, &out); err != nil {
+		t.Fatalf("ExecuteMultipleCommandsWithOutput failed: %v", err)
+	}
+	want := "before 1.2\napp:1.2\nprivate\nprivate\napp:1.2\n"
+	if out.String() != want {
+		t.Errorf("Expected %q, got %q", want, out.String())
+	}
+}
+
+func TestGroupIsolation(t *testing.T) {
+	step := &internal.CommandConfig{Command: "echo", Args: []string{"${tag}"}, Group: "build"}
+	if _, err := internal.BuildCommand(step, map[string]string{"tag": "1.2"}); err == nil {
+		t.Error("Expected -s values not to reach a group step without inputs")
+	}
+
+	step.Inputs = map[string]string{"tag": "$tag"}
+	cmd, err := internal.BuildCommand(step, map[string]string{"tag": "1.2"})
+	if err != nil || cmd[1] != "1.2" {
+		t.Errorf("Expected the input to pass the -s value, got %v, %v", cmd, err)
+	}
+
+	outside := &internal.CommandConfig{Command: "cat", Stdin: &internal.StdinSpec{Step: "secret-step"}}
+	inside := &internal.CommandConfig{Name: "secret-step", Group: "g", Command: "echo", Args: []string{"x"}, Capture: true}
+	cmd, _ = internal.BuildCommand(inside, nil)
+	var out bytes.Buffer
+	if err := internal.ExecuteStepWithOutput(inside, cmd, nil, &out, &out); err != nil {
+		t.Fatalf("ExecuteStepWithOutput failed: %v", err)
+	}
+	if _, err := internal.OpenStepInput(outside, nil); err == nil {
+		t.Error("Expected the output of an unexported group step to be private")
+	}
+}
+
+func TestValidateGroup(t *testing.T) {
+	path := writeWorkflow(t, t.TempDir(), "group.yml", groupWorkflow)
+	problems, err := internal.ValidateWorkflowDefinition(path)
+	if err != nil || len(problems) != 0 {
+		t.Fatalf("Expected no problems, got %v, %v", problems, err)
+	}
+
+	path = writeWorkflow(t, t.TempDir(), "bad.yml", `group: build
+inputs:
+  tag: "1"
+steps:
+  - name: a
+    command: echo
+    variables:
+      tag: "2"
+  - args: [missing-command]
+exports: [a]
+---
+command: cat
+stdin:
+  step: a
+`)
+	problems, err = internal.ValidateWorkflowDefinition(path)
+	if err != nil {
+		t.Fatalf("ValidateWorkflowDefinition failed: %v", err)
+	}
+	var messages []string
+	for _, p := range problems {
+		messages = append(messages, p.String())
+	}
+	text := strings.Join(messages, "\n")
+	for _, want := range []string{":9: steps[1]: missing required key 'command'"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected problem %q, got:\n%s", want, text)
+		}
+	}
+
+	path = writeWorkflow(t, t.TempDir(), "bad2.yml", `group: build
+inputs:
+  tag: "1"
+steps:
+  - name: a
+    command: echo
+    variables:
+      tag: "2"
+exports: [a]
+---
+command: cat
+stdin:
+  step: a
+`)
+	problems, _ = internal.ValidateWorkflowDefinition(path)
+	messages = nil
+	for _, p := range problems {
+		messages = append(messages, p.Message)
+	}
+	text = strings.Join(messages, "\n")
+	for _, want := range []string{"shadows the input", "exports 'a', which is not a step of the group with capture: true", "stdin: step 'a' must be an earlier step"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected problem %q, got:\n%s", want, text)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package tests
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"linea/internal"
+)
+
+func TestHealthCheckStepRetries(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer server.Close()
+
+	config := &internal.CommandConfig{
+		Type:        internal.StepTypeHealthCheck,
+		HealthCheck: &internal.HealthCheckSpec{URL: "{base}/health", Body: `"ok"`, Interval: "10ms", Timeout: "5s"},
+		Variables:   map[string]string{"base": server.URL},
+	}
+	cmd, err := internal.BuildCommand(config, nil)
+	if err != nil {
+		t.Fatalf("BuildCommand failed: %v", err)
+	}
+	want := []string{"healthcheck", "--url", server.URL + "/health", "--body", `"ok"`, "--interval", "10ms", "--timeout", "5s"}
+	if strings.Join(cmd, " ") != strings.Join(want, " ") {
+		t.Errorf("Expected %v, got %v", want, cmd)
+	}
+
+	var out bytes.Buffer
+	if err := internal.ExecuteStepWithOutput(config, cmd, nil, &out, &out); err != nil {
+		t.Fatalf("Expected the health check to succeed, got %v", err)
+	}
+	if atomic.LoadInt32(&requests) != 3 {
+		t.Errorf("Expected 3 requests, got %d", requests)
+	}
+	if strings.Count(out.String(), "not healthy yet (status 503)") != 2 || !strings.Contains(out.String(), "is healthy (status 200, attempt 3") {
+		t.Errorf("Unexpected output: %q", out.String())
+	}
+}
+
+func TestHealthCheckStepTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	configs := []*internal.CommandConfig{
+		{
+			Type:             internal.StepTypeHealthCheck,
+			HealthCheck:      &internal.HealthCheckSpec{URL: server.URL, Status: 204, Interval: "10ms", Timeout: "50ms"},
+			AllowedExitCodes: []int{0, 1},
+		},
+		{Command: "echo", Args: []string{"unhealthy"}, When: "{exit_code} == 1"},
+	}
+
+	var out bytes.Buffer
+	results, err := internal.RunStepsReport(configs, nil, internal.ReportOptions{Stdout: &out, Stderr: &out})
+	if err != nil {
+		t.Fatalf("Expected exit code 1 to be allowed, got %v", err)
+	}
+	if results[0].ExitCode != 1 || results[1].Status != internal.StepSucceeded {
+		t.Errorf("Expected the unhealthy result to reach the next step, got %+v", results)
+	}
+	if !strings.Contains(out.String(), "status 200, expected 204") || !strings.HasSuffix(out.String(), "unhealthy\n") {
+		t.Errorf("Unexpected output: %q", out.String())
+	}
+}
+
+func TestHealthCheckStepTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	config := &internal.CommandConfig{
+		Type:        internal.StepTypeHealthCheck,
+		HealthCheck: &internal.HealthCheckSpec{TCP: listener.Addr().String()},
+	}
+	cmd, err := internal.BuildCommand(config, nil)
+	if err != nil {
+		t.Fatalf("BuildCommand failed: %v", err)
+	}
+	var out bytes.Buffer
+	if err := internal.ExecuteStepWithOutput(config, cmd, nil, &out, &out); err != nil {
+		t.Fatalf("Expected the port to be open, got %v", err)
+	}
+}
+
+func TestHealthCheckSpecCheck(t *testing.T) {
+	invalid := []*internal.HealthCheckSpec{
+		nil,
+		{},
+		{URL: "http://localhost", TCP: "localhost:80"},
+		{TCP: "localhost:80", Status: 200},
+		{URL: "http://localhost", Status: 42},
+		{URL: "http://localhost", Timeout: "soon"},
+	}
+	for _, spec := range invalid {
+		if err := spec.Check(); err == nil {
+			t.Errorf("Expected %+v to be invalid", spec)
+		}
+	}
+
+	if err := (&internal.HealthCheckSpec{URL: "http://localhost", Timeout: "{wait}"}).Check(); err != nil {
+		t.Errorf("Expected a variable timeout to be checked at run time, got %v", err)
+	}
+}
+
+func TestParseHealthCheckStep(t *testing.T) {
+	path := writeWorkflow(t, t.TempDir(), "health.yml", "command: ./deploy.sh\n---\nname: wait\ntype: healthcheck\nhealthcheck:\n  tcp: localhost:5432\n")
+
+	configs, err := internal.ParseMultiYAML(path)
+	if err != nil {
+		t.Fatalf("ParseMultiYAML failed: %v", err)
+	}
+	if len(configs) != 2 || configs[1].Type != internal.StepTypeHealthCheck || configs[1].HealthCheck.TCP != "localhost:5432" {
+		t.Errorf("Expected the healthcheck step to be parsed, got %d steps", len(configs))
+	}
+
+	problems, err := internal.ValidateWorkflowDefinition(path)
+	if err != nil || len(problems) != 0 {
+		t.Errorf("Expected no problems, got %v, %v", problems, err)
+	}
+}
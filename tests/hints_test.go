@@ -0,0 +1,66 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+func TestDiagnoseFailureBuiltinRules(t *testing.T) {
+	err := internal.ExecuteCommand([]string{"linea-definitely-missing-tool"})
+	if err == nil {
+		t.Fatal("Expected error for missing command")
+	}
+
+	hints := internal.DiagnoseFailure(err, internal.BuiltinHintRules)
+	if len(hints) != 1 || hints[0].Rule != "command-not-found" {
+		t.Fatalf("Expected a single command-not-found hint, got %+v", hints)
+	}
+	if !strings.Contains(hints[0].Message, "linea-definitely-missing-tool") {
+		t.Errorf("Expected hint to name the missing command, got '%s'", hints[0].Message)
+	}
+
+	cmdErr := &internal.CommandError{Err: errors.New("exit status 1"), Stderr: "Cannot connect to the Docker daemon at unix:///var/run/docker.sock"}
+	hints = internal.DiagnoseFailure(cmdErr, internal.BuiltinHintRules)
+	if len(hints) != 1 || hints[0].Rule != "docker-daemon" {
+		t.Errorf("Expected docker-daemon hint from stderr, got %+v", hints)
+	}
+
+	if hints := internal.DiagnoseFailure(errors.New("something odd"), internal.BuiltinHintRules); len(hints) != 0 {
+		t.Errorf("Expected no hints, got %+v", hints)
+	}
+}
+
+func TestLoadHintRulesFromProject(t *testing.T) {
+	t.Setenv("LINEA_HOME", t.TempDir())
+	t.Setenv("LINEA_CONFIG_DIR", "")
+
+	project := t.TempDir()
+	lineaDir := filepath.Join(project, ".linea")
+	if err := os.MkdirAll(lineaDir, 0755); err != nil {
+		t.Fatalf("Failed to create .linea: %v", err)
+	}
+	rules := `rules:
+  - name: port-in-use
+    match: 'listen tcp :(\d+): bind'
+    hint: "Port $1 is taken; run: npm run stop"
+`
+	if err := os.WriteFile(filepath.Join(lineaDir, "hints.yml"), []byte(rules), 0644); err != nil {
+		t.Fatalf("Failed to write hints.yml: %v", err)
+	}
+	chdir(t, project)
+
+	loaded, err := internal.LoadHintRules()
+	if err != nil {
+		t.Fatalf("LoadHintRules failed: %v", err)
+	}
+
+	hints := internal.DiagnoseFailure(errors.New("listen tcp :8080: bind: address already in use"), loaded)
+	if len(hints) != 1 || hints[0].Message != "Port 8080 is taken; run: npm run stop" {
+		t.Errorf("Expected project rule to override the built-in hint, got %+v", hints)
+	}
+}
@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"linea/internal"
+)
+
+func TestIdempotentRunID(t *testing.T) {
+	a := internal.NewRunRecord("deploy.yml", map[string]string{"version": "1.0", "env": "prod"}, time.Now(), nil)
+	a.SetIdempotencyKey("delivery-1")
+	b := internal.NewRunRecord("deploy.yml", map[string]string{"env": "prod", "version": "1.0"}, time.Now().Add(time.Hour), nil)
+	b.SetIdempotencyKey("delivery-1")
+	if a.ID != b.ID || !strings.HasPrefix(a.ID, "key-") {
+		t.Errorf("Expected the same key-... ID for the same key and inputs, got %s and %s", a.ID, b.ID)
+	}
+
+	c := internal.NewRunRecord("deploy.yml", map[string]string{"env": "dev", "version": "1.0"}, time.Now(), nil)
+	c.SetIdempotencyKey("delivery-1")
+	if c.ID == a.ID {
+		t.Errorf("Expected different inputs to give a different ID, got %s for both", a.ID)
+	}
+}
+
+func TestFindIdempotentRun(t *testing.T) {
+	vars := map[string]string{"version": "1.0"}
+	failed := internal.NewRunRecord("deploy.yml", vars, time.Now(), errors.New("boom"))
+	failed.SetIdempotencyKey("delivery-1")
+
+	records := []internal.RunRecord{failed}
+	prior, err := internal.FindIdempotentRun(records, "delivery-1", "deploy.yml", vars)
+	if err != nil || prior != nil {
+		t.Fatalf("Expected a failed run to be retried, got %v, %v", prior, err)
+	}
+
+	succeeded := internal.NewRunRecord("deploy.yml", vars, time.Now(), nil)
+	succeeded.SetIdempotencyKey("delivery-1")
+	records = append(records, succeeded)
+	prior, err = internal.FindIdempotentRun(records, "delivery-1", "deploy.yml", vars)
+	if err != nil || prior == nil || !prior.Success {
+		t.Fatalf("Expected the successful run, got %v, %v", prior, err)
+	}
+	if prior, _ := internal.FindIdempotentRun(records, "delivery-2", "deploy.yml", vars); prior != nil {
+		t.Errorf("Expected no prior run for another key, got %s", prior.ID)
+	}
+
+	if _, err := internal.FindIdempotentRun(records, "delivery-1", "deploy.yml", map[string]string{"version": "2.0"}); err == nil {
+		t.Error("Expected an error for a key reused with other inputs")
+	}
+}
+
+func TestFindRunRecordSharedID(t *testing.T) {
+	t.Setenv("LINEA_HOME", t.TempDir())
+	t.Setenv("LINEA_STATE_DIR", "")
+
+	first := internal.NewRunRecord("deploy.yml", nil, time.Now(), errors.New("boom"))
+	first.SetIdempotencyKey("delivery-1")
+	retry := internal.NewRunRecord("deploy.yml", nil, time.Now(), nil)
+	retry.SetIdempotencyKey("delivery-1")
+	for _, record := range []internal.RunRecord{first, retry} {
+		if err := internal.AppendRunRecord(record); err != nil {
+			t.Fatalf("AppendRunRecord failed: %v", err)
+		}
+	}
+
+	for _, id := range []string{retry.ID, retry.ID[:6]} {
+		record, err := internal.FindRunRecord(id)
+		if err != nil || !record.Success {
+			t.Errorf("Expected %s to find the latest run, got %v, %v", id, record, err)
+		}
+	}
+}
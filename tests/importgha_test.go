@@ -0,0 +1,148 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+// importGHA converts a GitHub Actions workflow and parses the result
+func importGHA(t *testing.T, workflow, job string) (*internal.ImportResult, []*internal.CommandConfig) {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ci.yml")
+	os.WriteFile(path, []byte(workflow), 0644)
+	result, err := internal.ImportGitHubActions(path, job)
+	if err != nil {
+		t.Fatalf("ImportGitHubActions failed: %v", err)
+	}
+	out := filepath.Join(dir, "imported.yml")
+	os.WriteFile(out, result.Content, 0644)
+	configs, err := internal.ParseMultiYAML(out)
+	if err != nil {
+		t.Fatalf("Failed to parse the imported workflow: %v\n%s", err, result.Content)
+	}
+	return result, configs
+}
+
+func TestImportGitHubActionsSteps(t *testing.T) {
+	result, configs := importGHA(t, `name: CI
+on:
+  workflow_dispatch:
+    inputs:
+      target:
+        default: staging
+jobs:
+  deploy:
+    needs: test
+    runs-on: ubuntu-latest
+    steps:
+      - name: Deploy
+        run: ./deploy.sh ${{ inputs.target }} ${{ github.sha }}
+  test:
+    runs-on: ubuntu-latest
+    strategy:
+      matrix:
+        go: ["1.22", "1.23"]
+    steps:
+      - uses: actions/checkout@v4
+      - run: go test ./...
+      - name: Vet
+        env:
+          GOFLAGS: -mod=mod
+          TOKEN: ${{ secrets.API_TOKEN }}
+        run: |
+          echo "go ${{ matrix.go }}"
+          go vet ./...
+      - name: Report
+        if: failure()
+        run: echo failed
+`, "")
+
+	if len(configs) != 4 || result.Steps != 4 {
+		t.Fatalf("Expected 4 steps, got %d", len(configs))
+	}
+	var names []string
+	for _, c := range configs {
+		names = append(names, c.Name)
+	}
+	if strings.Join(names, ",") != "test/go test ./...,test/Vet,test/Report,deploy/Deploy" {
+		t.Errorf("Expected the test job before the deploy job that needs it, got %v", names)
+	}
+
+	// A simple command runs without a shell
+	if c := configs[0]; c.Command != "go" || c.Subcommand != "test" || strings.Join(c.Args, " ") != "./..." {
+		t.Errorf("Expected go test ./..., got %+v", c)
+	}
+	if configs[0].Description == "" || configs[0].Version != internal.CurrentWorkflowVersion {
+		t.Errorf("Expected the first step to carry the version and description, got %+v", configs[0])
+	}
+
+	vet := configs[1]
+	script := vet.Args[len(vet.Args)-1]
+	if vet.Command != "bash" || !strings.Contains(script, `export GOFLAGS="$GOFLAGS"`) || !strings.Contains(script, `echo "go $go"`) {
+		t.Errorf("Expected a bash script exporting env and using the matrix variable, got %q", script)
+	}
+	if vet.Variables["go"] != "1.22" || vet.Variables["GOFLAGS"] != "-mod=mod" {
+		t.Errorf("Expected the first matrix value and the env value, got %v", vet.Variables)
+	}
+	if ref := vet.Secrets["TOKEN"]; ref.From != "env" || ref.Key != "API_TOKEN" {
+		t.Errorf("Expected TOKEN to be read from $API_TOKEN, got %+v", vet.Secrets)
+	}
+	if !configs[2].OnFailure {
+		t.Errorf("Expected if: failure() to become on_failure: true")
+	}
+	if deploy := configs[3]; strings.Join(deploy.Args, " ") != "$target $git_sha" || deploy.Variables["target"] != "staging" {
+		t.Errorf("Expected the input default and $git_sha, got %v %v", deploy.Args, deploy.Variables)
+	}
+
+	if len(result.Notes) != 1 || !strings.Contains(result.Notes[0], "skipped") {
+		t.Errorf("Expected a note about the skipped checkout, got %v", result.Notes)
+	}
+	if !strings.HasPrefix(string(result.Content), "# Imported from ") || !strings.Contains(string(result.Content), "# Matrix of job test: go = 1.22, 1.23") {
+		t.Errorf("Expected a header describing the import and the matrix, got:\n%s", result.Content)
+	}
+}
+
+func TestImportGitHubActionsShellVariables(t *testing.T) {
+	result, configs := importGHA(t, `jobs:
+  build:
+    runs-on: ubuntu-latest
+    env:
+      OUT: dist
+    steps:
+      - run: |
+          mkdir -p ${OUT}/bin
+          cp app $HOME/bin
+      - run: for f in *.txt; do echo $f; done
+      - run: echo "${{ steps.meta.outputs.tags }}"
+`, "build")
+
+	first := configs[0]
+	script := first.Args[len(first.Args)-1]
+	if !strings.Contains(script, "mkdir -p $OUT/bin") || first.Providers["HOME"].Provider != internal.ProviderEnv {
+		t.Errorf("Expected ${OUT} as $OUT and $HOME read from the environment, got %q %v", script, first.Providers)
+	}
+
+	var notes = strings.Join(result.Notes, "\n")
+	if !strings.Contains(notes, "sets and uses $f") {
+		t.Errorf("Expected a note about the shell variable $f, got %v", result.Notes)
+	}
+	if !strings.Contains(notes, "steps.meta.outputs.tags") || configs[2].Variables["steps_meta_outputs_tags"] != "" {
+		t.Errorf("Expected an unsupported expression to become a variable with a note, got %v", result.Notes)
+	}
+}
+
+func TestImportGitHubActionsErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ci.yml")
+	os.WriteFile(path, []byte("jobs:\n  lint:\n    steps:\n      - uses: actions/checkout@v4\n"), 0644)
+	if _, err := internal.ImportGitHubActions(path, "build"); err == nil || !strings.Contains(err.Error(), "available: lint") {
+		t.Errorf("Expected an unknown job error listing the jobs, got %v", err)
+	}
+	if _, err := internal.ImportGitHubActions(path, ""); err == nil || !strings.Contains(err.Error(), "no run: steps") {
+		t.Errorf("Expected a workflow without run: steps to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+const importMakefile = `APP := myapp
+VERSION ?= 1.0
+SHA != git rev-parse --short HEAD
+OUT = bin/$(APP)
+
+.PHONY: all build test release
+
+all: build test ## Build and test
+
+build:
+	@mkdir -p bin
+	go build -o $(OUT) -ldflags "-X main.version=$(VERSION)" .
+
+test: build
+	-go vet ./...
+	echo $$HOME $(SHA)
+
+release: VERSION = 2.0
+release: all
+	./release.sh $(APP) $(VERSION) $(REGISTRY)
+
+%.o: %.c
+	$(CC) -c $<
+`
+
+// importMake converts a Makefile and parses the workflows it is converted into, by name
+func importMake(t *testing.T, makefile string, targets ...string) (map[string]*internal.ImportResult, map[string][]*internal.CommandConfig) {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "Makefile")
+	os.WriteFile(path, []byte(makefile), 0644)
+	results, err := internal.ImportMakefile(path, targets)
+	if err != nil {
+		t.Fatalf("ImportMakefile failed: %v", err)
+	}
+	byName := map[string]*internal.ImportResult{}
+	configs := map[string][]*internal.CommandConfig{}
+	for _, result := range results {
+		out := filepath.Join(dir, result.Name+".yml")
+		os.WriteFile(out, result.Content, 0644)
+		parsed, err := internal.ParseMultiYAML(out)
+		if err != nil {
+			t.Fatalf("Failed to parse the workflow of %s: %v\n%s", result.Name, err, result.Content)
+		}
+		byName[result.Name], configs[result.Name] = result, parsed
+	}
+	return byName, configs
+}
+
+func TestImportMakefileTargets(t *testing.T) {
+	results, configs := importMake(t, importMakefile)
+	if len(results) != 4 {
+		t.Fatalf("Expected 4 workflows, got %d", len(results))
+	}
+
+	all := configs["all"]
+	if len(all) != 1 || strings.Join(all[0].DependsOn, ",") != "build,test" || all[0].Description != "Build and test" {
+		t.Errorf("Expected all to depend on build and test, with its ## comment as description, got %+v", all[0])
+	}
+
+	build := configs["build"]
+	if len(build) != 2 {
+		t.Fatalf("Expected 2 steps for build, got %d", len(build))
+	}
+	if build[0].Command != "mkdir" || strings.Join(build[0].Args, " ") != "-p bin" {
+		t.Errorf("Expected mkdir -p bin, got %s %v", build[0].Command, build[0].Args)
+	}
+	if build[1].Command != "go" || build[1].Subcommand != "build" || strings.Join(build[1].Args, " ") != "-o $OUT -ldflags -X main.version=$VERSION ." {
+		t.Errorf("Unexpected go build step: %s %s %v", build[1].Command, build[1].Subcommand, build[1].Args)
+	}
+	if build[1].Variables["OUT"] != "bin/myapp" {
+		t.Errorf("Expected OUT to be expanded to bin/myapp, got %q", build[1].Variables["OUT"])
+	}
+	if spec := build[1].Providers["VERSION"]; spec.Provider != internal.ProviderEnv || spec.Default != "1.0" {
+		t.Errorf("Expected VERSION ?= 1.0 to be read from the environment, got %+v", spec)
+	}
+
+	test := configs["test"]
+	if strings.Join(test[0].DependsOn, ",") != "build" {
+		t.Errorf("Expected test to depend on build, got %v", test[0].DependsOn)
+	}
+	if got := strings.Join(test[1].Args, " "); test[1].Command != "sh" || got != "-c echo $HOME $SHA" {
+		t.Errorf("Expected sh -c with $$ and $(SHA) converted, got %s %s", test[1].Command, got)
+	}
+	if test[1].Providers["SHA"].Provider != internal.ProviderExec || test[1].Providers["HOME"].Provider != internal.ProviderEnv {
+		t.Errorf("Expected SHA from the exec provider and HOME from the environment, got %+v", test[1].Providers)
+	}
+
+	release := configs["release"]
+	if release[0].Variables["VERSION"] != "2.0" || release[0].Variables["REGISTRY"] != "" {
+		t.Errorf("Expected the target-specific VERSION and an empty REGISTRY, got %v", release[0].Variables)
+	}
+
+	notes := strings.Join(results["test"].Notes, "\n")
+	for _, want := range []string{
+		"the pattern rule %.o is not converted",
+		"make ignores the errors of `go vet ./...`",
+	} {
+		if !strings.Contains(notes, want) {
+			t.Errorf("Notes do not contain %q:\n%s", want, notes)
+		}
+	}
+	if !strings.Contains(strings.Join(results["release"].Notes, "\n"), "$(REGISTRY) is not set in the Makefile") {
+		t.Errorf("Expected a note about REGISTRY, got %q", results["release"].Notes)
+	}
+}
+
+func TestImportMakefileSelectedTargets(t *testing.T) {
+	results, _ := importMake(t, importMakefile, "test")
+	if len(results) != 2 || results["test"] == nil || results["build"] == nil {
+		t.Errorf("Expected test and the build target it depends on, got %d workflows", len(results))
+	}
+
+	path := filepath.Join(t.TempDir(), "Makefile")
+	os.WriteFile(path, []byte(importMakefile), 0644)
+	if _, err := internal.ImportMakefile(path, []string{"deploy"}); err == nil || !strings.Contains(err.Error(), "unknown target 'deploy'") {
+		t.Errorf("Expected an unknown target error, got %v", err)
+	}
+}
+
+func TestImportMakefileOneShell(t *testing.T) {
+	_, configs := importMake(t, `.ONESHELL:
+SHELL = /bin/bash
+.SHELLFLAGS = -ec
+deploy:
+	cd deploy
+	./run.sh
+`)
+	deploy := configs["deploy"]
+	if len(deploy) != 1 || deploy[0].Command != "bash" || strings.Join(deploy[0].Args, "|") != "-ec|cd deploy\n./run.sh" {
+		t.Errorf("Expected the recipe run as one bash -ec script, got %+v", deploy)
+	}
+}
@@ -0,0 +1,148 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"linea/internal"
+)
+
+func writeWorkflow(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	return path
+}
+
+// projectWorkflow writes a workflow file in a new project, whose .linea directory holds
+// the locks and checkpoints of its runs, and returns its path
+func projectWorkflow(t *testing.T, name, content string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".linea"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	return writeWorkflow(t, dir, name, content)
+}
+
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	previous, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+	t.Cleanup(func() { os.Chdir(previous) })
+}
+
+func TestIndexWorkflows(t *testing.T) {
+	root := t.TempDir()
+	workflowsDir := filepath.Join(root, ".linea", "workflows")
+	writeWorkflow(t, workflowsDir, "deploy.yml", `command: echo
+args:
+  - "$env {region}"
+variables:
+  region: "eu"
+  env: "dev"
+`)
+	writeWorkflow(t, workflowsDir, "build.yaml", "# Build the project\ncommand: make\nargs:\n  - \"$target\"\n")
+	writeWorkflow(t, workflowsDir, "notes.txt", "not a workflow\n")
+
+	nested := filepath.Join(root, "scripts", "nested")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if found := internal.FindWorkflowsDir(nested); found != workflowsDir {
+		t.Fatalf("Expected workflows dir %s, got %s", workflowsDir, found)
+	}
+
+	workflows, err := internal.IndexWorkflows(workflowsDir)
+	if err != nil {
+		t.Fatalf("IndexWorkflows failed: %v", err)
+	}
+	if len(workflows) != 2 {
+		t.Fatalf("Expected 2 workflows, got %d", len(workflows))
+	}
+	if workflows[0].Name != "build" || workflows[1].Name != "deploy" {
+		t.Errorf("Expected [build deploy], got [%s %s]", workflows[0].Name, workflows[1].Name)
+	}
+
+	chdir(t, nested)
+	path, err := internal.ResolveWorkflowPath("deploy")
+	if err != nil {
+		t.Fatalf("Expected to find workflow 'deploy' by name: %v", err)
+	}
+	deploy := internal.IndexWorkflowFile(path)
+	if len(deploy.Variables) != 2 || deploy.Variables[0] != "env" || deploy.Variables[1] != "region" {
+		t.Errorf("Expected variables [env region], got %v", deploy.Variables)
+	}
+}
+
+func TestIndexWorkflowDescriptionAndRequired(t *testing.T) {
+	dir := t.TempDir()
+	path := writeWorkflow(t, dir, "release.yml", `# Leading comment
+description: Publish a release
+command: echo
+args:
+  - "{version} $channel {os}"
+variables:
+  version: "1.0"
+`)
+
+	info := internal.IndexWorkflowFile(path)
+	if info.Description != "Publish a release" {
+		t.Errorf("Expected description field to win, got '%s'", info.Description)
+	}
+	if len(info.Required) != 1 || info.Required[0] != "channel" {
+		t.Errorf("Expected required [channel], got %v", info.Required)
+	}
+
+	commented := writeWorkflow(t, dir, "build.yml", "# Build the project\ncommand: make\n")
+	if info := internal.IndexWorkflowFile(commented); info.Description != "Build the project" {
+		t.Errorf("Expected leading comment as description, got '%s'", info.Description)
+	}
+}
+
+func TestResolveWorkflowPathGlobalFallback(t *testing.T) {
+	global := t.TempDir()
+	t.Setenv("LINEA_GLOBAL_WORKFLOWS", global)
+	globalPath := writeWorkflow(t, global, "cleanup.yaml", "command: echo\n")
+
+	project := t.TempDir()
+	localPath := writeWorkflow(t, filepath.Join(project, ".linea", "workflows"), "cleanup.yml", "command: ls\n")
+	explicit := writeWorkflow(t, project, "explicit.yml", "command: pwd\n")
+
+	chdir(t, project)
+
+	if path, err := internal.ResolveWorkflowPath("cleanup"); err != nil || !sameFile(path, localPath) {
+		t.Errorf("Expected local workflow %s to win, got %s (%v)", localPath, path, err)
+	}
+	if path, err := internal.ResolveWorkflowPath(explicit); err != nil || path != explicit {
+		t.Errorf("Expected explicit path to be kept, got %s (%v)", path, err)
+	}
+
+	if err := os.Remove(localPath); err != nil {
+		t.Fatalf("Failed to remove local workflow: %v", err)
+	}
+	if path, err := internal.ResolveWorkflowPath("cleanup"); err != nil || path != globalPath {
+		t.Errorf("Expected global workflow %s, got %s (%v)", globalPath, path, err)
+	}
+
+	if _, err := internal.ResolveWorkflowPath("missing"); err == nil {
+		t.Error("Expected error for unknown workflow name")
+	}
+}
+
+func sameFile(a, b string) bool {
+	infoA, errA := os.Stat(a)
+	infoB, errB := os.Stat(b)
+	return errA == nil && errB == nil && os.SameFile(infoA, infoB)
+}
@@ -0,0 +1,52 @@
+package tests
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+func TestInteractiveStepExitCode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	config := &internal.CommandConfig{Command: "sh", Args: []string{"-c", "exit 3"}, Interactive: true}
+	cmd, err := internal.BuildCommand(config, nil)
+	if err != nil {
+		t.Fatalf("BuildCommand failed: %v", err)
+	}
+	err = internal.ExecuteStepWithOutput(config, cmd, nil, nil, nil)
+	if internal.ExitCode(err) != 3 {
+		t.Errorf("Expected exit code 3, got %v", err)
+	}
+
+	config.AllowedExitCodes = []int{3}
+	if err := internal.ExecuteStepWithOutput(config, cmd, nil, nil, nil); err != nil {
+		t.Errorf("Expected allowed_exit_codes to apply to interactive steps, got %v", err)
+	}
+}
+
+func TestInteractiveValidation(t *testing.T) {
+	dir := t.TempDir()
+	cases := map[string]string{
+		"capture": "name: shell\ncommand: ssh\nargs: [\"-t\", \"host\"]\ninteractive: true\ncapture: true\n",
+		"stdin":   "command: vim\ninteractive: true\nstdin: hello\n",
+	}
+	for name, content := range cases {
+		path := writeWorkflow(t, dir, name+".yml", content)
+		problems, err := internal.ValidateWorkflowFile(path, nil)
+		if err != nil {
+			t.Fatalf("ValidateWorkflowFile failed: %v", err)
+		}
+		if len(problems) != 1 || !strings.Contains(problems[0].Message, "interactive") {
+			t.Errorf("%s: expected an interactive problem, got %v", name, problems)
+		}
+	}
+
+	path := writeWorkflow(t, dir, "ok.yml", "command: vim\nargs: [notes.md]\ninteractive: true\n")
+	if problems, _ := internal.ValidateWorkflowFile(path, nil); len(problems) != 0 {
+		t.Errorf("Expected an interactive step to be valid, got %v", problems)
+	}
+}
@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"linea/internal"
+)
+
+func TestJobID(t *testing.T) {
+	cases := map[string]*internal.CommandConfig{
+		"dev-server":    {Name: "Dev Server", Command: "npm"},
+		"npm":           {Command: "/usr/bin/npm"},
+		"web-api_v2":    {Name: "api_v2", Group: "web", Command: "go"},
+		"watch-css.min": {Name: "Watch CSS.min", Command: "npx"},
+	}
+	for want, config := range cases {
+		if got := internal.JobID(config); got != want {
+			t.Errorf("Expected job ID %s, got %s", want, got)
+		}
+	}
+}
+
+func TestBackgroundJob(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".linea"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	config := &internal.CommandConfig{
+		Name:       "server",
+		Command:    "sh",
+		Args:       []string{"-c", "echo started; sleep 30"},
+		Background: true,
+		SourceFile: filepath.Join(dir, "dev.yml"),
+	}
+	cmd, err := internal.BuildCommand(config, nil)
+	if err != nil {
+		t.Fatalf("BuildCommand failed: %v", err)
+	}
+
+	var stdout strings.Builder
+	start := time.Now()
+	if err := internal.ExecuteStepWithOutput(config, cmd, nil, &stdout, &stdout); err != nil {
+		t.Fatalf("Expected the job to start, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second || !strings.Contains(stdout.String(), "Started job server") {
+		t.Errorf("Expected the step to return once the job started, got %q", stdout.String())
+	}
+
+	jobsDir := internal.JobsDir(dir)
+	job, err := internal.LoadJob(jobsDir, "server")
+	if err != nil {
+		t.Fatalf("LoadJob failed: %v", err)
+	}
+	if !job.Running() || job.Workflow != "dev" {
+		t.Errorf("Expected a running job of workflow dev, got %+v", job)
+	}
+
+	if err := internal.ExecuteStepWithOutput(config, cmd, nil, &stdout, &stdout); err == nil || !strings.Contains(err.Error(), "already running") {
+		t.Errorf("Expected a second start to fail, got %v", err)
+	}
+
+	// The job writes to its log on its own
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if log, _ := os.ReadFile(job.LogFile); len(log) > 0 {
+			break
+		}
+	}
+	if err := internal.StopJob(jobsDir, job, time.Second); err != nil {
+		t.Fatalf("StopJob failed: %v", err)
+	}
+	if job.Running() {
+		t.Error("Expected the job to be stopped")
+	}
+	if jobs, _ := internal.LoadJobs(jobsDir); len(jobs) != 0 {
+		t.Errorf("Expected no tracked jobs after stop, got %d", len(jobs))
+	}
+	if log, _ := os.ReadFile(job.LogFile); !strings.Contains(string(log), "started") {
+		t.Errorf("Expected the job's output in its log, got %q", log)
+	}
+}
+
+func TestBackgroundValidation(t *testing.T) {
+	dir := t.TempDir()
+	path := writeWorkflow(t, dir, "bg.yml", "name: server\ncommand: npm\nargs: [start]\nbackground: true\ncapture: true\n")
+	problems, err := internal.ValidateWorkflowFile(path, nil)
+	if err != nil {
+		t.Fatalf("ValidateWorkflowFile failed: %v", err)
+	}
+	if len(problems) != 1 || !strings.Contains(problems[0].Message, "background") {
+		t.Errorf("Expected a background problem, got %v", problems)
+	}
+}
@@ -0,0 +1,108 @@
+package tests
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"linea/internal"
+)
+
+func TestParseSize(t *testing.T) {
+	for value, want := range map[string]int64{"100": 100, "64K": 64 << 10, "64KiB": 64 << 10, "512M": 512 << 20, "1.5GB": 3 << 29, "2g": 2 << 30} {
+		if got, err := internal.ParseSize(value); err != nil || got != want {
+			t.Errorf("ParseSize(%q) = %d, %v; expected %d", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"", "M", "-1G", "12X", "0"} {
+		if _, err := internal.ParseSize(value); err == nil {
+			t.Errorf("Expected ParseSize(%q) to fail", value)
+		}
+	}
+}
+
+func TestCheckLimits(t *testing.T) {
+	nice := func(n int) *int { return &n }
+	for _, config := range []*internal.CommandConfig{
+		{Command: "make", Limits: &internal.ResourceLimits{Memory: "lots"}},
+		{Command: "make", Limits: &internal.ResourceLimits{CPUTime: "-1s"}},
+		{Command: "make", Limits: &internal.ResourceLimits{Nice: nice(20)}},
+		{Command: "vim", Interactive: true, Limits: &internal.ResourceLimits{Output: "1M"}},
+		{Command: "server", Background: true, Limits: &internal.ResourceLimits{Output: "1M"}},
+		{Type: "copy", Limits: &internal.ResourceLimits{Memory: "1G"}},
+	} {
+		if err := internal.CheckLimits(config); err == nil {
+			t.Errorf("Expected limits %+v of %s to be rejected", *config.Limits, config.Command+config.Type)
+		}
+	}
+	config := &internal.CommandConfig{Command: "make", Limits: &internal.ResourceLimits{Memory: "2G", CPUTime: "10m", Nice: nice(10), Output: "10M"}}
+	if err := internal.CheckLimits(config); err != nil {
+		t.Errorf("Expected valid limits, got %v", err)
+	}
+}
+
+func TestOutputLimitKillsCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("runs yes")
+	}
+	config := &internal.CommandConfig{Command: "yes", Limits: &internal.ResourceLimits{Output: "1K"}}
+	var out bytes.Buffer
+	done := make(chan error, 1)
+	go func() {
+		_, err := internal.RunStepsReport([]*internal.CommandConfig{config}, nil, internal.ReportOptions{Stdout: &out, Stderr: &out})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "limits.output") {
+			t.Errorf("Expected the output limit to fail the step, got %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Expected the command to be killed past its output limit")
+	}
+	if out.Len() != 1024 {
+		t.Errorf("Expected 1024 bytes of output, got %d", out.Len())
+	}
+}
+
+func TestLimitsApplyToCommand(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("reads the limits with ulimit and nice")
+	}
+	nice := 5
+	config := &internal.CommandConfig{
+		Command: "sh",
+		Args:    []string{"-c", "ulimit -v; ulimit -t; nice"},
+		Limits:  &internal.ResourceLimits{Memory: "512M", CPUTime: "90s", Nice: &nice},
+	}
+	var out bytes.Buffer
+	if _, err := internal.RunStepsReport([]*internal.CommandConfig{config}, nil, internal.ReportOptions{Stdout: &out, Stderr: &out}); err != nil {
+		t.Fatalf("Run failed: %v\n%s", err, out.String())
+	}
+	if out.String() != "524288\n90\n5\n" {
+		t.Errorf("Expected 512M of memory, 90s of CPU time, and niceness 5, got %q", out.String())
+	}
+}
+
+func TestCPUTimeLimitStopsBusyCommand(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("relies on ulimit -t")
+	}
+	config := &internal.CommandConfig{Command: "sh", Args: []string{"-c", "while :; do :; done"}, Limits: &internal.ResourceLimits{CPUTime: "1s"}}
+	var out bytes.Buffer
+	done := make(chan error, 1)
+	go func() {
+		_, err := internal.RunStepsReport([]*internal.CommandConfig{config}, nil, internal.ReportOptions{Stdout: &out, Stderr: &out})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Errorf("Expected the busy command to fail once out of CPU time")
+		}
+	case <-time.After(20 * time.Second):
+		t.Fatalf("Expected the busy command to be stopped after 1s of CPU time")
+	}
+}
@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	lineatest "linea/testing"
+)
+
+const deployWorkflow = `name: build
+command: docker
+args: ["build", "-t", "app:${tag}", "."]
+---
+name: version
+command: git
+args: ["describe", "--tags"]
+capture: true
+---
+name: push
+command: docker
+args: ["push", "app:$tag"]
+stdin:
+  step: version
+---
+name: rollback
+command: kubectl
+args: ["rollout", "undo", "deployment/app"]
+on_failure: true
+---
+name: notify
+command: echo
+args: ["deployed $tag"]
+after: true
+`
+
+// fakeTB records the failures of a helper instead of failing the test
+type fakeTB struct {
+	errors []string
+	fatal  bool
+}
+
+func (f *fakeTB) Helper() {}
+
+func (f *fakeTB) Errorf(format string, args ...interface{}) {
+	f.errors = append(f.errors, format)
+}
+
+func (f *fakeTB) Fatalf(format string, args ...interface{}) {
+	f.errors = append(f.errors, format)
+	f.fatal = true
+}
+
+func TestLineatestRun(t *testing.T) {
+	path := writeWorkflow(t, t.TempDir(), "deploy.yml", deployWorkflow)
+	wf := lineatest.Load(t, path).Set("tag", "1.2")
+	wf.Respond("git describe", lineatest.Response{Stdout: "v1.2\n"})
+
+	result := wf.Run(t)
+	result.AssertSuccess(t)
+	result.AssertExitCode(t, 0)
+	result.AssertCommand(t, "build", "docker build -t app:1.2 .")
+	result.AssertStdout(t, "version", "v1.2")
+	result.AssertStatus(t, "rollback", "skipped")
+	result.AssertStatus(t, "5", "success")
+	if got := strings.Join(result.Commands(), "; "); got != "docker build -t app:1.2 .; git describe --tags; docker push app:1.2; echo 'deployed 1.2'" {
+		t.Errorf("Unexpected commands: %s", got)
+	}
+}
+
+func TestLineatestFailure(t *testing.T) {
+	path := writeWorkflow(t, t.TempDir(), "deploy.yml", deployWorkflow)
+	wf := lineatest.Load(t, path).Set("tag", "1.2")
+	wf.Respond("docker", lineatest.Response{Stderr: "daemon not running\n", ExitCode: 1})
+	wf.Respond("docker build", lineatest.Response{})
+
+	result := wf.Run(t)
+	result.AssertExitCode(t, 1)
+	result.AssertStatus(t, "build", "success")
+	result.AssertStatus(t, "push", "failed")
+	result.AssertStatus(t, "rollback", "success")
+	result.AssertStatus(t, "notify", "success")
+	if step := result.Step(t, "push"); step.Stderr != "daemon not running\n" {
+		t.Errorf("Expected the faked stderr, got %q", step.Stderr)
+	}
+
+	// The assertions report mismatches through the TB they are given
+	tb := &fakeTB{}
+	result.AssertSuccess(tb)
+	result.AssertCommand(tb, "push", "docker push app:latest")
+	result.Step(tb, "missing")
+	if len(tb.errors) != 3 || !tb.fatal {
+		t.Errorf("Expected 3 failures, the last one fatal, got %v", tb.errors)
+	}
+}
+
+func TestLineatestCommands(t *testing.T) {
+	path := writeWorkflow(t, t.TempDir(), "deploy.yml", deployWorkflow)
+	commands := lineatest.Load(t, path).Set("tag", "2.0").Commands(t)
+	if len(commands) != 5 || commands[2] != "docker push app:2.0" {
+		t.Errorf("Unexpected commands: %v", commands)
+	}
+
+	// A variable the workflow needs but the test did not set fails validation
+	tb := &fakeTB{}
+	lineatest.Load(t, path).Commands(tb)
+	if !tb.fatal {
+		t.Errorf("Expected an unset variable to fail the test")
+	}
+}
@@ -0,0 +1,110 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+func lintRuleIDs(findings []internal.LintFinding) string {
+	ids := make([]string, len(findings))
+	for i, finding := range findings {
+		ids[i] = finding.Rule.ID
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestLintWorkflowFile(t *testing.T) {
+	project := t.TempDir()
+	workflows := filepath.Join(project, ".linea", "workflows")
+	if err := os.MkdirAll(workflows, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := writeWorkflow(t, workflows, "build.yml", `command: docker
+args:
+  - build
+  - "{nmae}"
+  - "&&"
+  - ../../outside
+variables:
+  name: app
+  unused: x
+`)
+
+	findings, fixed, err := internal.LintWorkflowFile(path, false)
+	if err != nil {
+		t.Fatalf("LintWorkflowFile failed: %v", err)
+	}
+	if fixed != nil {
+		t.Error("Expected no fixed contents without --fix")
+	}
+	if got := lintRuleIDs(findings); got != "L005,L002,L003,L004,L001" {
+		t.Fatalf("Unexpected findings %s:\n%v", got, findings)
+	}
+	if !strings.Contains(findings[1].Message, "did you mean {name}?") || findings[1].Line != 4 {
+		t.Errorf("Expected a typo suggestion on line 4, got %s", findings[1])
+	}
+	if !strings.Contains(findings[4].String(), "build.yml:9: L001 unused-variable: variable 'unused'") {
+		t.Errorf("Unexpected unused variable finding: %s", findings[4])
+	}
+}
+
+func TestLintWorkflowFileFix(t *testing.T) {
+	path := writeWorkflow(t, t.TempDir(), "greet.yml", `# Greet someone
+command: echo
+args: ["{ who }", "{greeting"]
+variables:
+  who: world
+  greeting: hello
+  unused: x
+secrets:
+  token: {}
+`)
+
+	findings, fixed, err := internal.LintWorkflowFile(path, true)
+	if err != nil {
+		t.Fatalf("LintWorkflowFile failed: %v", err)
+	}
+	for _, finding := range findings {
+		if !finding.Fixed {
+			t.Errorf("Expected every finding to be fixed, got %s", finding)
+		}
+	}
+	expected := `# Greet someone
+command: echo
+args:
+  - "{who}"
+  - "{greeting}"
+variables:
+  who: world
+  greeting: hello
+`
+	if string(fixed) != expected {
+		t.Errorf("Unexpected fixed contents:\n%s\nexpected:\n%s", fixed, expected)
+	}
+}
+
+func TestLintShellAndPaths(t *testing.T) {
+	// Shell commands may use shell syntax, and paths are only checked inside a project
+	path := writeWorkflow(t, t.TempDir(), "shell.yml", `description: Count files
+command: /bin/bash
+args: [-c, "ls ../.. | wc -l", "|", ../../x]
+`)
+	findings, _, err := internal.LintWorkflowFile(path, false)
+	if err != nil {
+		t.Fatalf("LintWorkflowFile failed: %v", err)
+	}
+	if len(findings) != 0 {
+		t.Errorf("Expected no findings, got %v", findings)
+	}
+}
+
+func TestLintInvalidYAML(t *testing.T) {
+	path := writeWorkflow(t, t.TempDir(), "broken.yml", "command: [echo\n")
+	if _, _, err := internal.LintWorkflowFile(path, false); err == nil {
+		t.Error("Expected error for invalid YAML")
+	}
+}
@@ -0,0 +1,232 @@
+package tests
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"linea/internal"
+)
+
+func queueRuns(t *testing.T, dir string, n int, target internal.LogShipTarget) {
+	t.Helper()
+	start := time.Date(2026, 3, 2, 2, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		logFile := filepath.Join(dir, "logs", "backup-"+string(rune('a'+i))+".log")
+		os.MkdirAll(filepath.Dir(logFile), 0755)
+		if err := os.WriteFile(logFile, []byte("run log\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		run := internal.Shipment{
+			ID:         "backup-" + string(rune('a'+i)),
+			Workflow:   "backup",
+			ScheduleID: "backup",
+			LogFile:    logFile,
+			Success:    true,
+			Start:      start.Add(time.Duration(i) * time.Minute),
+		}
+		if err := internal.EnqueueShipments(filepath.Join(dir, "queue"), run, []internal.LogShipTarget{target}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func queuedCount(t *testing.T, dir string) int {
+	t.Helper()
+	entries, _ := os.ReadDir(filepath.Join(dir, "queue"))
+	return len(entries)
+}
+
+func TestLogShipperBatchesHTTP(t *testing.T) {
+	var mu sync.Mutex
+	var batches []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("Authorization header = %q", r.Header.Get("Authorization"))
+		}
+		var body struct {
+			Runs []struct {
+				Workflow string `json:"workflow"`
+				Log      string `json:"log"`
+			} `json:"runs"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("invalid batch: %v", err)
+		}
+		if len(body.Runs) > 0 && (body.Runs[0].Workflow != "backup" || body.Runs[0].Log != "run log\n") {
+			t.Errorf("unexpected run %+v", body.Runs[0])
+		}
+		mu.Lock()
+		batches = append(batches, len(body.Runs))
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	t.Setenv("SHIP_TOKEN", "token")
+	dir := t.TempDir()
+	queueRuns(t, dir, internal.ShipBatchSize+5, internal.LogShipTarget{
+		URL:     server.URL,
+		Headers: map[string]string{"Authorization": "Bearer $SHIP_TOKEN"},
+	})
+
+	shipper := &internal.LogShipper{Dir: filepath.Join(dir, "queue")}
+	if n := shipper.Flush(time.Now()); n != internal.ShipBatchSize+5 {
+		t.Errorf("Flush delivered %d, expected %d", n, internal.ShipBatchSize+5)
+	}
+	if len(batches) != 2 || batches[0] != internal.ShipBatchSize || batches[1] != 5 {
+		t.Errorf("batches = %v, expected [%d 5]", batches, internal.ShipBatchSize)
+	}
+	if n := queuedCount(t, dir); n != 0 {
+		t.Errorf("%d shipments left in the queue", n)
+	}
+}
+
+func TestLogShipperRetriesWithBackoff(t *testing.T) {
+	fail := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	queueRuns(t, dir, 1, internal.LogShipTarget{URL: server.URL})
+	var messages []string
+	shipper := &internal.LogShipper{
+		Dir: filepath.Join(dir, "queue"),
+		Logf: func(status internal.Status, format string, args ...interface{}) {
+			messages = append(messages, format)
+		},
+	}
+
+	now := time.Now()
+	if n := shipper.Flush(now); n != 0 {
+		t.Fatalf("Flush delivered %d through a failing endpoint", n)
+	}
+	if len(messages) != 1 || queuedCount(t, dir) != 1 {
+		t.Fatalf("expected one retry message and the shipment kept, got %v", messages)
+	}
+
+	// Not due again until the backoff has passed
+	fail = false
+	if n := shipper.Flush(now.Add(10 * time.Second)); n != 0 {
+		t.Errorf("shipment retried before its backoff")
+	}
+	if n := shipper.Flush(now.Add(time.Minute)); n != 1 {
+		t.Errorf("shipment not retried after its backoff")
+	}
+}
+
+func TestLogShipperGivesUp(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	queueRuns(t, dir, 1, internal.LogShipTarget{URL: server.URL})
+	var last internal.Status
+	shipper := &internal.LogShipper{
+		Dir:  filepath.Join(dir, "queue"),
+		Logf: func(status internal.Status, format string, args ...interface{}) { last = status },
+	}
+
+	now := time.Now()
+	for i := 0; i < internal.MaxShipAttempts; i++ {
+		shipper.Flush(now)
+		now = now.Add(2 * time.Hour)
+	}
+	if n := queuedCount(t, dir); n != 0 {
+		t.Errorf("shipment still queued after %d attempts", internal.MaxShipAttempts)
+	}
+	if last != internal.StatusError {
+		t.Errorf("last message status = %q, expected an error", last)
+	}
+}
+
+func TestLogShipperS3(t *testing.T) {
+	var path, auth, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, auth = r.URL.Path, r.Header.Get("Authorization")
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		if r.Method != http.MethodPut || r.Header.Get("X-Amz-Content-Sha256") == "" || r.Header.Get("X-Amz-Date") == "" {
+			t.Errorf("unexpected request %s with headers %v", r.Method, r.Header)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	queueRuns(t, dir, 1, internal.LogShipTarget{
+		S3:        "s3://linea-logs/hosts/web1",
+		Endpoint:  server.URL,
+		Region:    "eu-west-1",
+		AccessKey: "AKIDEXAMPLE",
+		SecretKey: "secret",
+	})
+	shipper := &internal.LogShipper{Dir: filepath.Join(dir, "queue")}
+	if n := shipper.Flush(time.Now()); n != 1 {
+		t.Fatalf("Flush delivered %d, expected 1", n)
+	}
+
+	if path != "/linea-logs/hosts/web1/backup/backup-a.log" {
+		t.Errorf("object path = %q", path)
+	}
+	if body != "run log\n" {
+		t.Errorf("object body = %q", body)
+	}
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") || !strings.Contains(auth, "/eu-west-1/s3/aws4_request") ||
+		!strings.Contains(auth, "SignedHeaders=host;x-amz-content-sha256;x-amz-date") {
+		t.Errorf("Authorization = %q", auth)
+	}
+}
+
+func TestSignS3RequestIsDeterministic(t *testing.T) {
+	creds := internal.S3Credentials{AccessKey: "AKID", SecretKey: "secret", Region: "us-east-1"}
+	when := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	sign := func(key string) string {
+		req, _ := http.NewRequest(http.MethodPut, internal.S3ObjectURL("", "us-east-1", "bucket", key), strings.NewReader("x"))
+		internal.SignS3Request(req, []byte("x"), creds, when)
+		return req.Header.Get("Authorization")
+	}
+	if sign("a/b.log") != sign("a/b.log") {
+		t.Errorf("signature differs for the same request")
+	}
+	if sign("a/b.log") == sign("a/c.log") {
+		t.Errorf("signature does not cover the object key")
+	}
+	if got := internal.S3ObjectURL("", "us-east-1", "bucket", "run logs/a+b.log"); got != "https://bucket.s3.us-east-1.amazonaws.com/run%20logs/a%2Bb.log" {
+		t.Errorf("S3ObjectURL = %q", got)
+	}
+}
+
+func TestValidateShipLogs(t *testing.T) {
+	path := writeWorkflow(t, t.TempDir(), "ship.yml", `command: echo
+ship_logs:
+  - url: https://logs.example.com/linea
+  - region: eu-west-1
+  - s3: linea-logs
+`)
+	problems, err := internal.ValidateWorkflowFile(path, nil)
+	if err != nil {
+		t.Fatalf("ValidateWorkflowFile failed: %v", err)
+	}
+	joined := problemMessages(problems)
+	if !strings.Contains(joined, "ship_logs[1]: needs url or s3") {
+		t.Errorf("missing destination not reported:\n%s", joined)
+	}
+	if !strings.Contains(joined, "ship_logs[2]: invalid s3 location 'linea-logs'") {
+		t.Errorf("invalid s3 location not reported:\n%s", joined)
+	}
+	if strings.Contains(joined, "ship_logs[0]") {
+		t.Errorf("valid target reported:\n%s", joined)
+	}
+}
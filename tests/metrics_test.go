@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"linea/internal"
+)
+
+func TestMetricsText(t *testing.T) {
+	depth := 3
+	metrics := &internal.Metrics{Queues: map[string]func() int{"ship_logs": func() int { return depth }}}
+	metrics.RunStarted("backup")
+	metrics.RunFinished("backup", 2*time.Second, true)
+	metrics.RunStarted("backup")
+	metrics.RunFinished("backup", 90*time.Second, false)
+	metrics.RunStarted(`odd"name`)
+	metrics.RunSkipped(`odd"name`)
+
+	var out bytes.Buffer
+	metrics.WriteText(&out)
+	text := out.String()
+	for _, want := range []string{
+		"# TYPE linea_runs_total counter\n",
+		`linea_runs_total{workflow="backup",status="success"} 1`,
+		`linea_runs_total{workflow="backup",status="failed"} 1`,
+		`linea_runs_in_progress{workflow="odd\"name"} 1`,
+		`linea_runs_skipped_total{workflow="odd\"name"} 1`,
+		`linea_run_duration_seconds_bucket{workflow="backup",le="1"} 0`,
+		`linea_run_duration_seconds_bucket{workflow="backup",le="5"} 1`,
+		`linea_run_duration_seconds_bucket{workflow="backup",le="120"} 2`,
+		`linea_run_duration_seconds_bucket{workflow="backup",le="+Inf"} 2`,
+		`linea_run_duration_seconds_sum{workflow="backup"} 92`,
+		`linea_run_duration_seconds_count{workflow="backup"} 2`,
+		`linea_last_run_success{workflow="backup"} 0`,
+		`linea_queue_depth{queue="ship_logs"} 3`,
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Metrics do not contain %q:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, `linea_last_run_success{workflow="odd\"name"}`) {
+		t.Errorf("Expected no last run for a workflow without finished runs:\n%s", text)
+	}
+
+	// A nil *Metrics counts nothing
+	var none *internal.Metrics
+	none.RunStarted("backup")
+	none.RunFinished("backup", time.Second, true)
+}
+
+func TestServeMetrics(t *testing.T) {
+	server, httpServer := newTestServer(t, "secret")
+	if resp := apiRequest(t, "GET", httpServer.URL+"/metrics", "secret", "", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected no /metrics without Metrics set, got %d", resp.StatusCode)
+	}
+
+	server.Metrics = &internal.Metrics{}
+	handler := server.Handler()
+	if _, err := server.StartRun("build", map[string]string{"env": "bad"}); err != nil {
+		t.Fatalf("StartRun failed: %v", err)
+	}
+	server.Wait()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected /metrics to require the token, got %d", rec.Code)
+	}
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if !strings.Contains(rec.Body.String(), `linea_runs_total{workflow="build",status="failed"} 1`) {
+		t.Errorf("Expected the failed run in the metrics, got %d:\n%s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestLogShipperQueueDepth(t *testing.T) {
+	dir := t.TempDir()
+	shipper := &internal.LogShipper{Dir: dir}
+	if depth := shipper.QueueDepth(); depth != 0 {
+		t.Errorf("Expected an empty queue, got %d", depth)
+	}
+	for _, name := range []string{"a.json", "b.json", "notes.txt"} {
+		os.WriteFile(filepath.Join(dir, name), []byte("{}"), 0644)
+	}
+	if depth := shipper.QueueDepth(); depth != 2 {
+		t.Errorf("Expected 2 queued shipments, got %d", depth)
+	}
+	if depth := (&internal.LogShipper{Dir: filepath.Join(dir, "missing")}).QueueDepth(); depth != 0 {
+		t.Errorf("Expected a missing queue to be empty, got %d", depth)
+	}
+}
@@ -0,0 +1,90 @@
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+func TestMiddlewareChain(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	configs := []*internal.CommandConfig{
+		{Name: "first", Command: "sh", Args: []string{"-c", "echo one; echo two >&2; printf three"}},
+		{Name: "refused", Command: "echo", Args: []string{"never"}},
+		{Name: "cleanup", Command: "echo", Args: []string{"cleanup"}, After: true},
+	}
+
+	var calls []string
+	var lines []string
+	var progress bytes.Buffer
+	results, err := internal.RunStepsReport(configs, nil, internal.ReportOptions{
+		Stdout:   io.Discard,
+		Stderr:   io.Discard,
+		Progress: internal.NewProgress(nopCloser{&progress}),
+		Workflow: "deploy",
+		Middleware: []internal.Middleware{
+			{
+				BeforeStep: func(step internal.StepInfo) error {
+					calls = append(calls, "a:before:"+step.Config.Name)
+					if step.Config.Name == "refused" {
+						return errors.New("not today")
+					}
+					return nil
+				},
+				AfterStep: func(step internal.StepInfo, result internal.StepResult) {
+					calls = append(calls, "a:after:"+step.Config.Name+"="+result.Status)
+				},
+			},
+			{
+				BeforeStep: func(step internal.StepInfo) error {
+					calls = append(calls, "b:before:"+step.Config.Name)
+					return nil
+				},
+				OnOutputLine: func(step internal.StepInfo, stream, line string) {
+					if step.Config.Name == "first" {
+						lines = append(lines, stream+":"+line)
+					}
+				},
+			},
+		},
+	})
+
+	if err == nil || !strings.Contains(err.Error(), "not today") {
+		t.Errorf("Expected the refused step to fail the run, got %v", err)
+	}
+	if results[1].Status != internal.StepFailed || results[1].Command != nil {
+		t.Errorf("Expected the refused step to fail without running, got %+v", results[1])
+	}
+	want := "a:before:first,b:before:first,a:after:first=success,a:before:refused,a:after:refused=failed,a:before:cleanup,b:before:cleanup,a:after:cleanup=success"
+	if got := strings.Join(calls, ","); got != want {
+		t.Errorf("Unexpected calls:\n got %s\nwant %s", got, want)
+	}
+
+	// Output from two streams may interleave, but each stream keeps its order
+	joined := strings.Join(lines, ",")
+	for _, line := range []string{"stdout:one", "stderr:two", "stdout:three"} {
+		if !strings.Contains(joined, line) {
+			t.Errorf("Expected the line %s, got %s", line, joined)
+		}
+	}
+	if strings.Index(joined, "stdout:one") > strings.Index(joined, "stdout:three") {
+		t.Errorf("Expected stdout lines in order, got %s", joined)
+	}
+
+	// Progress events come from the first middleware of the chain
+	if got := strings.Count(progress.String(), `"event":"step_start"`); got != 3 {
+		t.Errorf("Expected 3 step_start events, got %d:\n%s", got, progress.String())
+	}
+}
+
+// nopCloser adds a Close method to a writer
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,77 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+func TestNestedVariables(t *testing.T) {
+	config := &internal.CommandConfig{
+		Command: "echo",
+		Args:    []string{"{image}", "$image", "{registry|upper}"},
+		Variables: map[string]string{
+			"image":    "{registry}/{app}:{tag}",
+			"registry": "{host}:{port}",
+			"host":     "reg.local",
+			"port":     "5000",
+			"app":      "web",
+			"tag":      "$version",
+			"version":  "1.0",
+		},
+	}
+	// Map order varies between runs, so resolve several times
+	for i := 0; i < 20; i++ {
+		cmd, err := internal.BuildCommand(config, nil)
+		if err != nil || strings.Join(cmd, " ") != "echo reg.local:5000/web:1.0 reg.local:5000/web:1.0 REG.LOCAL:5000" {
+			t.Fatalf("Unexpected command %v %v", cmd, err)
+		}
+	}
+
+	// $version takes the -s value, also inside {image}; {tag} itself cannot be overridden
+	cmd, err := internal.BuildCommand(config, map[string]string{"version": "2.0", "tag": "other"})
+	if err != nil || cmd[1] != "reg.local:5000/web:2.0" || cmd[2] != "reg.local:5000/web:2.0" {
+		t.Errorf("Expected the -s value in the nested variables, got %v %v", cmd, err)
+	}
+
+	// A nested reference to an undefined variable is reported like any other
+	config.Variables["port"] = "{missing}"
+	if _, err := internal.BuildCommand(config, nil); err == nil || !strings.Contains(err.Error(), "undefined variables: missing") {
+		t.Errorf("Expected the undefined variable to be reported, got %v", err)
+	}
+}
+
+func TestNestedVariableErrors(t *testing.T) {
+	cycle := &internal.CommandConfig{Command: "echo", Args: []string{"{a}"}, Variables: map[string]string{"a": "x-{b}", "b": "y-$c", "c": "{a}"}}
+	if _, err := internal.BuildCommand(cycle, nil); err == nil || err.Error() != "variable cycle: {a} -> {b} -> $c -> {a}" {
+		t.Errorf("Expected a cycle, got %v", err)
+	}
+	self := &internal.CommandConfig{Command: "echo", Args: []string{"$path"}, Variables: map[string]string{"path": "$path:/opt/bin"}}
+	if err := internal.CheckNestedVariables(self); err == nil || !strings.Contains(err.Error(), "variable cycle: $path -> $path") {
+		t.Errorf("Expected a variable referencing itself to be a cycle, got %v", err)
+	}
+	// The -s value of $path is not a reference to the variable
+	if cmd, err := internal.BuildCommand(self, map[string]string{"path": "/usr/bin"}); err != nil || cmd[1] != "/usr/bin" {
+		t.Errorf("Expected the -s value, got %v %v", cmd, err)
+	}
+
+	deep := &internal.CommandConfig{Command: "echo", Args: []string{"{v0}"}, Variables: map[string]string{}}
+	for i := 0; i <= internal.MaxVariableDepth; i++ {
+		deep.Variables[fmt.Sprintf("v%d", i)] = fmt.Sprintf("{v%d}", i+1)
+	}
+	deep.Variables[fmt.Sprintf("v%d", internal.MaxVariableDepth+1)] = "end"
+	if _, err := internal.BuildCommand(deep, nil); err == nil || !strings.Contains(err.Error(), "variables nested more than 10 deep: {v0} -> {v1}") {
+		t.Errorf("Expected the depth limit, got %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "cycle.yml")
+	os.WriteFile(path, []byte("command: echo\nargs: [\"{a}\"]\nvariables:\n  a: \"{b}\"\n  b: \"{a}\"\n"), 0644)
+	problems, _ := internal.ValidateWorkflowFile(path, nil)
+	if len(problems) != 1 || problems[0].Message != "variable cycle: {a} -> {b} -> {a}" {
+		t.Errorf("Expected linea validate to report the cycle, got %v", problems)
+	}
+}
@@ -0,0 +1,55 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"linea/internal"
+)
+
+func TestNotifyRun(t *testing.T) {
+	var got []string
+	internal.RegisterNotifier("b-record", internal.NotifierFunc(func(ctx context.Context, report *internal.RunReport) error {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("Expected a deadline on the notifier's context")
+		}
+		got = append(got, "b:"+report.Workflow+":"+report.Status)
+		return nil
+	}))
+	internal.RegisterNotifier("a-fail", internal.NotifierFunc(func(ctx context.Context, report *internal.RunReport) error {
+		got = append(got, "a")
+		return errors.New("channel down")
+	}))
+	internal.RegisterNotifier("c-panic", internal.NotifierFunc(func(ctx context.Context, report *internal.RunReport) error {
+		panic("boom")
+	}))
+	defer func() {
+		for _, name := range []string{"a-fail", "b-record", "c-panic"} {
+			internal.RegisterNotifier(name, nil)
+		}
+	}()
+
+	report := internal.NewRunReport("deploy.yml", "run", nil, time.Now())
+	report.Finish(errors.New("exit status 2"))
+	errs := internal.NotifyRun(report)
+
+	if strings.Join(got, ",") != "a,b:deploy:failed" {
+		t.Errorf("Expected every notifier to run in name order, got %v", got)
+	}
+	if len(errs) != 2 || !strings.Contains(errs[0].Error(), "a-fail: channel down") || !strings.Contains(errs[1].Error(), "c-panic: panic: boom") {
+		t.Errorf("Expected the failures of a-fail and c-panic, got %v", errs)
+	}
+}
+
+func TestRegisterNotifierRemove(t *testing.T) {
+	internal.RegisterNotifier("temporary", internal.NotifierFunc(func(ctx context.Context, report *internal.RunReport) error { return nil }))
+	internal.RegisterNotifier("temporary", nil)
+	for _, name := range internal.NotifierNames() {
+		if name == "temporary" {
+			t.Error("Expected a nil notifier to remove the registration")
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package tests
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"linea/internal"
+)
+
+// fakeSMTP accepts one email without authentication and returns the channel its data
+// is sent to
+func fakeSMTP(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+		reply("220 localhost ESMTP")
+		var data strings.Builder
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch command := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+				reply("250 localhost")
+			case command == "DATA":
+				reply("354 go ahead")
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					data.WriteString(line)
+				}
+				received <- data.String()
+				reply("250 queued")
+			case command == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				data.WriteString(strings.TrimSpace(line) + "\r\n")
+				reply("250 ok")
+			}
+		}
+	}()
+	return listener.Addr().String(), received
+}
+
+func TestNotifyWorkflow(t *testing.T) {
+	requests := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests[r.URL.Path] = string(body)
+		if r.URL.Path == "/webhook" && r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("LINEA_TEST_HOOKS", server.URL)
+	t.Setenv("LINEA_TEST_TOKEN", "token")
+	path := filepath.Join(t.TempDir(), "deploy.yml")
+	os.WriteFile(path, []byte(`command: ./deploy.sh
+notifications:
+  - slack: $LINEA_TEST_HOOKS/slack
+  - discord: $LINEA_TEST_HOOKS/discord
+    on: success
+  - webhook: ${LINEA_TEST_HOOKS}/webhook
+    headers:
+      Authorization: "Bearer ${LINEA_TEST_TOKEN}"
+    message: "{workflow|upper} {status} ({exit_code})"
+    on: [failure]
+`), 0644)
+
+	report := internal.NewRunReport(path, "run", nil, time.Now())
+	report.Steps = []internal.StepResult{
+		{Index: 1, Name: "build", Status: internal.StepSucceeded, DurationMs: 1200},
+		{Index: 2, Name: "test", Status: internal.StepFailed, ExitCode: 3, DurationMs: 300},
+		{Index: 3, Status: internal.StepNotRun},
+	}
+	report.Finish(&internal.StepExitError{Code: 3, Err: errors.New("tests failed")})
+	if errs := internal.NotifyWorkflow(report); len(errs) != 0 {
+		t.Fatalf("Expected the notifications to be sent, got %v", errs)
+	}
+
+	var slack map[string]string
+	json.Unmarshal([]byte(requests["/slack"]), &slack)
+	for _, want := range []string{"❌ deploy failed on ", ": tests failed", "✓ 1 build (1.2s)", "✗ 2 test (exit code 3, 300ms)", "- 3 (not run)"} {
+		if !strings.Contains(slack["text"], want) {
+			t.Errorf("Expected the Slack message to contain %q, got %q", want, slack["text"])
+		}
+	}
+	if _, ok := requests["/discord"]; ok {
+		t.Errorf("Expected no Discord message for a failed run")
+	}
+	var webhook struct {
+		Text string             `json:"text"`
+		Run  internal.RunReport `json:"run"`
+	}
+	json.Unmarshal([]byte(requests["/webhook"]), &webhook)
+	if webhook.Text != "DEPLOY failed (3)" || len(webhook.Run.Steps) != 3 {
+		t.Errorf("Expected the templated message with the run, got %q", requests["/webhook"])
+	}
+
+	// Delivery failures are returned without the webhook's URL
+	t.Setenv("LINEA_TEST_TOKEN", "wrong")
+	errs := internal.NotifyWorkflow(report)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "401") || strings.Contains(errs[0].Error(), "/webhook") {
+		t.Errorf("Expected the webhook to fail, got %v", errs)
+	}
+}
+
+func TestNotifyStepEmail(t *testing.T) {
+	addr, received := fakeSMTP(t)
+	config := &internal.CommandConfig{
+		Type: internal.StepTypeNotify,
+		Notify: &internal.NotificationTarget{
+			Email:   &internal.EmailTarget{SMTP: addr, From: "linea@example.com", To: internal.StringList{"ops@example.com"}, Subject: "Deployed {version}"},
+			Message: "Version {version} is live",
+		},
+		Variables: map[string]string{"version": "1.4.0"},
+	}
+	output, err := runFileStep(t, config, nil)
+	if err != nil {
+		t.Fatalf("Notify failed: %v\n%s", err, output)
+	}
+	select {
+	case data := <-received:
+		for _, want := range []string{"MAIL FROM:<linea@example.com>", "RCPT TO:<ops@example.com>", "Subject: Deployed 1.4.0", "Version 1.4.0 is live"} {
+			if !strings.Contains(data, want) {
+				t.Errorf("Expected the email to contain %q, got %q", want, data)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected an email")
+	}
+	if !strings.Contains(output, "email to ops@example.com") {
+		t.Errorf("Unexpected output: %s", output)
+	}
+}
+
+func TestCheckNotifyStep(t *testing.T) {
+	for _, config := range []*internal.CommandConfig{
+		{Type: internal.StepTypeNotify},
+		{Type: internal.StepTypeNotify, Notify: &internal.NotificationTarget{Slack: "https://hooks.slack.com/x"}},
+		{Type: internal.StepTypeNotify, Notify: &internal.NotificationTarget{Message: "hi"}},
+		{Type: internal.StepTypeNotify, Notify: &internal.NotificationTarget{Slack: "https://a", Discord: "https://b", Message: "hi"}},
+		{Type: internal.StepTypeNotify, Notify: &internal.NotificationTarget{Slack: "https://a", Message: "hi", On: internal.StringList{"failure"}}},
+		{Type: internal.StepTypeNotify, Notify: &internal.NotificationTarget{Email: &internal.EmailTarget{SMTP: "smtp.example.com", From: "a@b", To: internal.StringList{"c@d"}}, Message: "hi"}},
+		{Command: "echo", Notify: &internal.NotificationTarget{Slack: "https://a", Message: "hi"}},
+	} {
+		if err := internal.CheckNotifyStep(config); err == nil {
+			t.Errorf("Expected %+v to be rejected", config)
+		}
+	}
+	if err := (internal.NotificationTarget{Webhook: "https://a", On: internal.StringList{"always"}}).Check(false); err == nil {
+		t.Errorf("Expected an invalid on: to be rejected")
+	}
+}
@@ -0,0 +1,130 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+// writePackage creates a package directory with one workflow and returns it
+func writePackage(t *testing.T, name, version string) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), name)
+	os.MkdirAll(filepath.Join(dir, "workflows"), 0755)
+	os.WriteFile(filepath.Join(dir, "linea-package.yml"), []byte("name: "+name+"\nversion: "+version+"\ndescription: Docker helpers\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "workflows", "docker-prune.yml"), []byte("description: Remove unused images\ncommand: echo\nargs: [\""+version+"\"]\n"), 0644)
+	return dir
+}
+
+// buildPackage packs a package directory into dir and returns the archive
+func buildPackage(t *testing.T, src, dir string) string {
+	t.Helper()
+	archive, _, err := internal.BuildPackage(src, filepath.Join(dir, filepath.Base(src)+".tar.gz"))
+	if err != nil {
+		t.Fatalf("BuildPackage failed: %v", err)
+	}
+	return archive
+}
+
+func TestInstallPackageFromFile(t *testing.T) {
+	t.Setenv("LINEA_HOME", t.TempDir())
+	archive := buildPackage(t, writePackage(t, "docker-tools", "1.0.0"), t.TempDir())
+
+	meta, previous, err := internal.InstallPackage(archive)
+	if err != nil || meta.Name != "docker-tools" || previous != "" {
+		t.Fatalf("Expected the package to be installed, got %+v, %q, %v", meta, previous, err)
+	}
+	path, err := internal.ResolveWorkflowPath("docker-prune")
+	if err != nil || !strings.HasPrefix(path, internal.PackagesDir()) {
+		t.Errorf("Expected the package's workflow to resolve by name, got %s, %v", path, err)
+	}
+
+	archive = buildPackage(t, writePackage(t, "docker-tools", "1.1.0"), t.TempDir())
+	if _, previous, err = internal.InstallPackage(archive); err != nil || previous != "1.0.0" {
+		t.Errorf("Expected 1.0.0 to be replaced, got %q, %v", previous, err)
+	}
+	if packages := internal.InstalledPackages(); len(packages) != 1 || packages[0].Version != "1.1.0" {
+		t.Errorf("Expected only 1.1.0 to be installed, got %v", packages)
+	}
+
+	if _, err := internal.UninstallPackage("docker-tools"); err != nil {
+		t.Fatalf("UninstallPackage failed: %v", err)
+	}
+	if _, err := internal.ResolveWorkflowPath("docker-prune"); err == nil {
+		t.Errorf("Expected the workflow to be gone")
+	}
+	if _, err := internal.UninstallPackage("docker-tools"); err == nil {
+		t.Errorf("Expected an error for a package that is not installed")
+	}
+}
+
+func TestInstallPackageFromRegistry(t *testing.T) {
+	t.Setenv("LINEA_HOME", t.TempDir())
+	files := t.TempDir()
+	old := buildPackage(t, writePackage(t, "docker-tools", "1.9.0"), filepath.Join(files, "old"))
+	latest := buildPackage(t, writePackage(t, "docker-tools", "1.10.0"), files)
+	oldSum, _ := internal.FileSHA256(old)
+	latestSum, _ := internal.FileSHA256(latest)
+
+	index := internal.PackageIndex{Packages: []internal.PackageEntry{
+		{Name: "docker-tools", Version: "1.9.0", URL: "old/docker-tools.tar.gz", SHA256: oldSum},
+		{Name: "docker-tools", Version: "1.10.0", Description: "Docker helpers", URL: "docker-tools.tar.gz", SHA256: latestSum},
+		{Name: "k8s-deploy", Version: "0.1.0", Description: "Kubernetes deploys", URL: "k8s-deploy.tar.gz", SHA256: strings.Repeat("0", 64)},
+	}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/registry/index.json" {
+			json.NewEncoder(w).Encode(index)
+			return
+		}
+		http.ServeFile(w, r, filepath.Join(files, strings.TrimPrefix(r.URL.Path, "/registry/")))
+	}))
+	defer server.Close()
+	t.Setenv(internal.RegistryEnv, server.URL+"/registry/index.json")
+
+	meta, _, err := internal.InstallPackage("docker-tools")
+	if err != nil || meta.Version != "1.10.0" {
+		t.Fatalf("Expected the newest version, got %+v, %v", meta, err)
+	}
+	if meta, _, err = internal.InstallPackage("docker-tools@1.9.0"); err != nil || meta.Version != "1.9.0" {
+		t.Errorf("Expected the pinned version, got %+v, %v", meta, err)
+	}
+	if _, _, err := internal.InstallPackage("docker-tools@2.0.0"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected an unknown version to be rejected, got %v", err)
+	}
+
+	// The archive of k8s-deploy does not match its checksum
+	os.Rename(latest, filepath.Join(files, "k8s-deploy.tar.gz"))
+	if _, _, err := internal.InstallPackage("k8s-deploy"); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("Expected a checksum mismatch, got %v", err)
+	}
+
+	fetched, _, err := internal.FetchPackageIndex()
+	if err != nil {
+		t.Fatalf("FetchPackageIndex failed: %v", err)
+	}
+	if found := fetched.Search("KUBERNETES"); len(found) != 1 || found[0].Name != "k8s-deploy" {
+		t.Errorf("Expected to find k8s-deploy, got %v", found)
+	}
+	if found := fetched.Search(""); len(found) != 2 || found[0].Version != "1.10.0" {
+		t.Errorf("Expected the newest version of each package, got %v", found)
+	}
+}
+
+func TestBuildPackageRejectsInvalidPackages(t *testing.T) {
+	dir := writePackage(t, "Docker Tools", "1.0.0")
+	if _, _, err := internal.BuildPackage(dir, filepath.Join(t.TempDir(), "out.tar.gz")); err == nil {
+		t.Errorf("Expected an invalid package name to be rejected")
+	}
+
+	dir = writePackage(t, "docker-tools", "1.0.0")
+	os.WriteFile(filepath.Join(dir, "workflows", "broken.yml"), []byte("args: [x]\n"), 0644)
+	if _, _, err := internal.BuildPackage(dir, filepath.Join(t.TempDir(), "out.tar.gz")); err == nil || !strings.Contains(err.Error(), "broken.yml") {
+		t.Errorf("Expected an invalid workflow to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+func TestIsPathLikeExclusions(t *testing.T) {
+	for _, s := range []string{"https://example.com/a/b", "registry.example.com/team/app:1.2", "deploy@host:dir/file", "1.2/3/4", "v2.0/4.1"} {
+		if internal.IsPathLike(s) {
+			t.Errorf("Expected %q not to look like a path", s)
+		}
+	}
+	for _, s := range []string{"C:/Users/Test/file.txt", "build/1.2/out", "./1.2/3"} {
+		if !internal.IsPathLike(s) {
+			t.Errorf("Expected %q to look like a path", s)
+		}
+	}
+}
+
+const pathTypesWorkflow = `command: echo
+args: ["{range}", "{out}", "{out}/{range}", "{out|upper}", "scripts\\build.sh"]
+variables:
+  range:
+    value: 'x\y\z'
+    type: raw
+  out:
+    value: 'build\out'
+    type: path
+  token:
+    provider: env
+    default: a
+    type: raw
+`
+
+func TestVariableTypes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "build.yml")
+	os.WriteFile(path, []byte(pathTypesWorkflow), 0644)
+	configs, err := internal.ParseMultiYAML(path)
+	if err != nil {
+		t.Fatalf("ParseMultiYAML failed: %v", err)
+	}
+	config := configs[0]
+	if config.Variables["range"] != `x\y\z` || config.VariableTypes["out"] != "path" || config.VariableTypes["token"] != "raw" {
+		t.Errorf("Unexpected variables %v and types %v", config.Variables, config.VariableTypes)
+	}
+
+	cmd, err := internal.BuildCommand(config, nil)
+	if err != nil {
+		t.Fatalf("BuildCommand failed: %v", err)
+	}
+	want := []string{"echo", `x\y\z`, internal.NormalizePath(`build\out`), `build\out/x\y\z`, internal.NormalizePath(`BUILD\OUT`), internal.NormalizePath(`scripts\build.sh`)}
+	if strings.Join(cmd, " ") != strings.Join(want, " ") {
+		t.Errorf("Expected %v, got %v", want, cmd)
+	}
+
+	if problems, err := internal.ValidateWorkflowFile(path, nil); err != nil || len(problems) != 0 {
+		t.Errorf("Expected no problems, got %v %v", problems, err)
+	}
+	os.WriteFile(path, []byte(strings.Replace(pathTypesWorkflow, "type: path", "type: dir", 1)), 0644)
+	problems, _ := internal.ValidateWorkflowFile(path, nil)
+	if len(problems) != 1 || !strings.Contains(problems[0].Message, "variable out: type must be path or raw, not 'dir'") {
+		t.Errorf("Expected the unknown type to be reported, got %v", problems)
+	}
+}
+
+func TestNormalizePathsOption(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "release.yml")
+	os.WriteFile(path, []byte(`name: release
+normalize_paths: false
+---
+command: echo
+args: ["notes\\draft.md"]
+---
+command: echo
+args: ["notes\\draft.md"]
+normalize_paths: true
+`), 0644)
+	configs, err := internal.ParseMultiYAML(path)
+	if err != nil || len(configs) != 2 {
+		t.Fatalf("ParseMultiYAML failed: %v %d", err, len(configs))
+	}
+	if internal.NormalizesPaths(configs[0]) || !internal.NormalizesPaths(configs[1]) {
+		t.Errorf("Expected the metadata document to turn normalization off unless a step turns it on")
+	}
+	if cmd, err := internal.BuildCommand(configs[0], nil); err != nil || cmd[1] != `notes\draft.md` {
+		t.Errorf("Expected the argument as written, got %v %v", cmd, err)
+	}
+	if cmd, err := internal.BuildCommand(configs[1], nil); err != nil || cmd[1] != internal.NormalizePath(`notes\draft.md`) {
+		t.Errorf("Expected the argument normalized, got %v %v", cmd, err)
+	}
+}
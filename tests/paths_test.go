@@ -0,0 +1,60 @@
+package tests
+
+import (
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"linea/internal"
+)
+
+func TestUserPathsLineaHome(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("LINEA_HOME", home)
+	t.Setenv("LINEA_CACHE_DIR", "")
+	t.Setenv("LINEA_GLOBAL_WORKFLOWS", "")
+
+	p := internal.UserPaths()
+	if p.Config != home || p.Data != home {
+		t.Errorf("Expected config and data in %s, got %+v", home, p)
+	}
+	if p.GlobalWorkflows != filepath.Join(home, "global-workflows") {
+		t.Errorf("Unexpected global workflows dir %s", p.GlobalWorkflows)
+	}
+	if p.Cache != filepath.Join(home, "cache") {
+		t.Errorf("Unexpected cache dir %s", p.Cache)
+	}
+}
+
+func TestUserPathsOverrides(t *testing.T) {
+	t.Setenv("LINEA_HOME", t.TempDir())
+	t.Setenv("LINEA_CACHE_DIR", "/tmp/linea-cache")
+	t.Setenv("LINEA_GLOBAL_WORKFLOWS", "/srv/workflows")
+
+	p := internal.UserPaths()
+	if p.Cache != "/tmp/linea-cache" {
+		t.Errorf("Expected LINEA_CACHE_DIR override, got %s", p.Cache)
+	}
+	if internal.GlobalWorkflowsDir() != "/srv/workflows" {
+		t.Errorf("Expected LINEA_GLOBAL_WORKFLOWS override, got %s", internal.GlobalWorkflowsDir())
+	}
+}
+
+func TestUserPathsXDG(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		t.Skip("XDG layout only applies to Linux and other Unix systems")
+	}
+
+	t.Setenv("LINEA_HOME", "")
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", "/xdg/config")
+	t.Setenv("XDG_STATE_HOME", "/xdg/state")
+
+	p := internal.UserPaths()
+	if p.Config != filepath.Join("/xdg/config", "linea") {
+		t.Errorf("Expected XDG config dir, got %s", p.Config)
+	}
+	if p.State != filepath.Join("/xdg/state", "linea") {
+		t.Errorf("Expected XDG state dir, got %s", p.State)
+	}
+}
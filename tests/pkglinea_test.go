@@ -0,0 +1,218 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"linea/internal"
+	"linea/pkg/linea"
+)
+
+// loadLibraryWorkflow writes content to a workflow file and loads it with pkg/linea
+func loadLibraryWorkflow(t *testing.T, content string) *linea.Workflow {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "deploy.yml")
+	os.WriteFile(path, []byte(content), 0644)
+	wf, err := linea.Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	return wf
+}
+
+func TestLibraryLoadAndResolve(t *testing.T) {
+	wf := loadLibraryWorkflow(t, `name: deploy
+description: Deploys the app
+version: 2
+---
+name: greet
+command: echo
+args: ["hello", "${who}", "{app}"]
+variables:
+  app: myapp
+---
+name: dirs
+type: mkdir
+mkdir:
+  path: "$who/bin"
+`)
+	if wf.Name != "deploy" || wf.Description != "Deploys the app" || len(wf.Steps) != 2 {
+		t.Fatalf("Unexpected workflow: %+v", wf)
+	}
+	if wf.Steps[0].Command != "echo" || wf.Steps[0].Variables["app"] != "myapp" || wf.Steps[1].Type != "mkdir" {
+		t.Errorf("Unexpected steps: %+v", wf.Steps)
+	}
+
+	if problems, err := wf.Validate(nil); err != nil || len(problems) == 0 {
+		t.Errorf("Expected a problem for the unset $who, got %v %v", problems, err)
+	}
+	if problems, err := wf.Validate(map[string]string{"who": "world"}); err != nil || len(problems) != 0 {
+		t.Errorf("Expected no problems with who set, got %v %v", problems, err)
+	}
+
+	resolved, err := wf.Resolve(map[string]string{"who": "world"})
+	if err != nil || len(resolved) != 2 {
+		t.Fatalf("Resolve failed: %v %+v", err, resolved)
+	}
+	if got := strings.Join(resolved[0].Command, " "); !strings.HasSuffix(got, "hello world myapp") {
+		t.Errorf("Expected the substituted command, got %q", got)
+	}
+	sources := map[string]string{}
+	for _, v := range resolved[0].Variables {
+		sources[v.Name] = v.Source
+	}
+	if sources["who"] == "" || sources["app"] == "" || sources["who"] == sources["app"] {
+		t.Errorf("Expected who and app to come from different sources, got %v", sources)
+	}
+}
+
+func TestLibraryRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	wf := loadLibraryWorkflow(t, `version: 2
+name: first
+command: echo
+args: ["one"]
+---
+name: fail
+command: sh
+args: ["-c", "echo oops >&2; exit 4"]
+---
+name: skipped
+command: echo
+args: ["two"]
+---
+name: cleanup
+command: echo
+args: ["cleanup"]
+after: true
+`)
+
+	var stdout bytes.Buffer
+	var started, ended, lines []string
+	result, err := wf.Run(context.Background(), linea.Options{
+		Stdout:  &stdout,
+		Capture: true,
+		Middleware: []linea.Middleware{{
+			BeforeStep: func(e linea.StepEvent) error {
+				started = append(started, e.Name)
+				return nil
+			},
+			AfterStep: func(e linea.StepEvent) { ended = append(ended, e.Name+"="+e.Status) },
+		}, {
+			OnOutputLine: func(e linea.StepEvent, stream, line string) {
+				lines = append(lines, e.Name+":"+stream+":"+line)
+			},
+		}},
+	})
+	if err == nil || result == nil {
+		t.Fatalf("Expected the fail step to fail the run, got %v", err)
+	}
+	if result.Status != linea.StatusFailed || result.ExitCode != 4 {
+		t.Errorf("Expected a failed run with exit code 4, got %s and %d", result.Status, result.ExitCode)
+	}
+	if stdout.String() != "one\ncleanup\n" {
+		t.Errorf("Unexpected output %q", stdout.String())
+	}
+	if got := strings.Join(started, ","); got != "first,fail,cleanup" {
+		t.Errorf("Unexpected BeforeStep calls %s", got)
+	}
+	if got := strings.Join(ended, ","); got != "first=success,fail=failed,skipped=not_run,cleanup=success" {
+		t.Errorf("Unexpected AfterStep calls %s", got)
+	}
+	if got := strings.Join(lines, ","); got != "first:stdout:one,fail:stderr:oops,cleanup:stdout:cleanup" {
+		t.Errorf("Unexpected OnOutputLine calls %s", got)
+	}
+	if result.Steps[1].Stderr != "oops\n" || result.Steps[1].ExitCode != 4 {
+		t.Errorf("Expected the captured stderr and exit code of the fail step, got %+v", result.Steps[1])
+	}
+}
+
+func TestLibraryRunCancelled(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sleep")
+	}
+	wf := loadLibraryWorkflow(t, `version: 2
+name: wait
+command: sleep
+args: ["10"]
+---
+name: next
+command: echo
+args: ["next"]
+`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	result, err := wf.Run(ctx, linea.Options{GracePeriod: time.Second})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the run to end with the context, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the sleep to be stopped, took %v", elapsed)
+	}
+	if len(result.Steps) != 2 || result.Steps[1].Status != linea.StatusNotRun {
+		t.Errorf("Expected the next step not to run, got %+v", result.Steps)
+	}
+
+	if _, err := wf.Run(ctx, linea.Options{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a done context to refuse the run, got %v", err)
+	}
+}
+
+func TestLibraryRunsOneAtATime(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	log := filepath.Join(t.TempDir(), "log")
+	wf := loadLibraryWorkflow(t, `command: sh
+args: ["-c", "echo start >> `+filepath.ToSlash(log)+`; sleep 0.2; echo end >> `+filepath.ToSlash(log)+`"]
+`)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := wf.Run(context.Background(), linea.Options{}); err != nil {
+				t.Errorf("Run failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if data, _ := os.ReadFile(log); string(data) != "start\nend\nstart\nend\n" {
+		t.Errorf("Expected the runs one after the other, got %q", data)
+	}
+}
+
+func TestLibraryRunStopsEnforcingPolicies(t *testing.T) {
+	policy := filepath.Join(t.TempDir(), "policy.yml")
+	t.Setenv(internal.PolicyFileEnv, policy)
+	t.Setenv(internal.EnforcePolicyEnv, "")
+	os.Unsetenv(internal.EnforcePolicyEnv)
+	wf := loadLibraryWorkflow(t, "command: echo\nargs: [\"hi\"]\n")
+
+	os.WriteFile(policy, []byte("enforce: true\ndeny:\n  - command: rm\n"), 0644)
+	if _, err := wf.Run(context.Background(), linea.Options{}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if _, set := os.LookupEnv(internal.EnforcePolicyEnv); set || internal.EnforcedPolicies != nil {
+		t.Errorf("Expected the run to stop enforcing its policies when it returns")
+	}
+
+	// A policy that is not enforced only applies to a later run if it asks for it
+	os.WriteFile(policy, []byte("deny:\n  - command: echo\n"), 0644)
+	if _, err := wf.Run(context.Background(), linea.Options{}); err != nil {
+		t.Errorf("Expected a policy without enforce: true not to be enforced, got %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+package tests
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+// writePolicy writes a policy file and loads it
+func writePolicy(t *testing.T, content string) *internal.Policy {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "policy.yml")
+	os.WriteFile(path, []byte(content), 0644)
+	policy, err := internal.LoadPolicy(path)
+	if err != nil {
+		t.Fatalf("LoadPolicy failed: %v", err)
+	}
+	return policy
+}
+
+func TestPolicyCheck(t *testing.T) {
+	policy := writePolicy(t, `allow:
+  - command: git
+  - command: npm
+    args: "^(ci|test|run build)$"
+  - command: python*
+  - command: download
+  - command: /opt/tools/*
+deny:
+  - command: git
+    args: "push.*--force"
+    reason: force pushes need review
+  - command: "*"
+    args: "--no-verify"
+`)
+	for cmd, allowed := range map[string]bool{
+		"git status":                     true,
+		"/usr/bin/git pull":              true,
+		"git.exe fetch":                  true,
+		"git push --force origin main":   false,
+		"git commit --no-verify":         false,
+		"npm ci":                         true,
+		"npm run build":                  true,
+		"npm install left-pad":           false,
+		"python3 build.py":               true,
+		"download https://example.com/x": true,
+		"/opt/tools/lint --fix":          true,
+		"/usr/local/bin/lint --fix":      false,
+		"curl -fsSL https://get.sh":      false,
+	} {
+		err := policy.Check(strings.Fields(cmd))
+		var violation *internal.PolicyViolation
+		if allowed && err != nil {
+			t.Errorf("Expected '%s' to be allowed, got %v", cmd, err)
+		}
+		if !allowed && !errors.As(err, &violation) {
+			t.Errorf("Expected '%s' to violate the policy, got %v", cmd, err)
+		}
+	}
+	if err := policy.Check([]string{"git", "push", "--force"}); err == nil || !strings.Contains(err.Error(), "denied by "+policy.Path+": force pushes need review") {
+		t.Errorf("Expected the deny rule's reason, got %v", err)
+	}
+
+	for _, content := range []string{"allow:\n  - args: x\n", "deny:\n  - command: rm\n    args: \"(\"\n", "allow:\n  - command: \"[\"\n"} {
+		path := filepath.Join(t.TempDir(), "policy.yml")
+		os.WriteFile(path, []byte(content), 0644)
+		if _, err := internal.LoadPolicy(path); err == nil {
+			t.Errorf("Expected %q to be rejected", content)
+		}
+	}
+	if policy, err := internal.LoadPolicy(filepath.Join(t.TempDir(), "missing.yml")); policy != nil || err != nil {
+		t.Errorf("Expected a missing policy file to be ignored, got %v, %v", policy, err)
+	}
+}
+
+func TestAuditPolicies(t *testing.T) {
+	policy := writePolicy(t, `allow:
+  - command: echo
+  - command: make
+    args: "^test$"
+`)
+	configs := []*internal.CommandConfig{
+		{Command: "echo", Args: []string{"{message}"}, Variables: map[string]string{"message": "hi"}},
+		{Command: "make", Args: []string{"{target}"}, Variables: map[string]string{"target": "test"}},
+		{Command: "make", Args: []string{"{target}"}, Variables: map[string]string{"target": "deploy"}},
+		{Command: "rm", Args: []string{"-rf", "build"}},
+		{Command: "make", Args: []string{"{version}"}},
+		{Command: "curl", Args: []string{"{version}"}},
+		{Command: "rsync", Args: []string{"dist/", "host:/srv"}, Runner: &internal.RunnerConfig{Type: "ssh", Host: "deploy.example.com"}},
+	}
+	var audited []string
+	for _, err := range internal.AuditPolicies([]*internal.Policy{policy}, configs, nil) {
+		audited = append(audited, strings.SplitN(err.Error(), ":", 2)[0])
+	}
+	// Steps whose arguments cannot be built yet are checked by command, and fully when they run
+	if strings.Join(audited, ",") != "command 3,command 4,command 6,command 7" {
+		t.Errorf("Unexpected violations: %v", audited)
+	}
+}
+
+func TestRunStepsReportEnforcesPolicy(t *testing.T) {
+	internal.EnforcedPolicies = []*internal.Policy{writePolicy(t, "deny:\n  - command: echo\n    args: drop\n")}
+	defer func() { internal.EnforcedPolicies = nil }()
+
+	configs := []*internal.CommandConfig{
+		{Command: "echo", Args: []string{"backup"}},
+		{Command: "echo", Args: []string{"{action}"}, Variables: map[string]string{"action": "drop"}},
+		{Command: "echo", Args: []string{"restore"}},
+	}
+	results, err := internal.RunStepsReport(configs, nil, internal.ReportOptions{Stdout: io.Discard, Stderr: io.Discard})
+	var violation *internal.PolicyViolation
+	if !errors.As(err, &violation) || strings.Join(violation.Command, " ") != "echo drop" {
+		t.Fatalf("Expected the run to stop at the denied step, got %v", err)
+	}
+	if results[0].Status != internal.StepSucceeded || results[1].Status != internal.StepFailed || results[2].Status != internal.StepNotRun {
+		t.Errorf("Expected only the first step to run, got %v", results)
+	}
+}
@@ -0,0 +1,134 @@
+package tests
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"linea/internal"
+)
+
+// processRunning reports whether a process is alive, reading /proc so that a zombie
+// nobody has reaped yet counts as exited
+func processRunning(pid int) bool {
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return false
+	}
+	fields := strings.Fields(string(data[bytes.LastIndexByte(data, ')')+1:]))
+	return len(fields) > 0 && fields[0] != "Z"
+}
+
+// waitExited waits up to 5 seconds for a process to exit
+func waitExited(pid int) bool {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if !processRunning(pid) {
+			return true
+		}
+	}
+	return false
+}
+
+// readPID waits for a command to write its child's PID to path
+func readPID(t *testing.T, path string) int {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		data, err := os.ReadFile(path)
+		if pid, convErr := strconv.Atoi(strings.TrimSpace(string(data))); err == nil && convErr == nil {
+			return pid
+		}
+	}
+	t.Fatalf("no PID written to %s", path)
+	return 0
+}
+
+func TestCancelledRunStopsChildProcesses(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("reads /proc")
+	}
+	stop := internal.HandleSignals(time.Minute)
+	defer stop()
+
+	// sleep keeps the command's output open, so the step only ends once it is gone too
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	config := &internal.CommandConfig{Command: "sh", Args: []string{"-c", "sleep 30 & echo $! > " + pidFile + "; wait"}}
+	var out bytes.Buffer
+	done := make(chan error, 1)
+	go func() {
+		_, err := internal.RunStepsReport([]*internal.CommandConfig{config}, nil, internal.ReportOptions{Stdout: &out, Stderr: &out})
+		done <- err
+	}()
+	pid := readPID(t, pidFile)
+	sendSignal(t, syscall.SIGTERM, 0)
+
+	select {
+	case err := <-done:
+		if !internal.IsCancelled(err) {
+			t.Errorf("Expected a cancelled run, got %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Expected the run to end once its processes got SIGTERM")
+	}
+	if !waitExited(pid) {
+		t.Errorf("Expected the command's child %d to get SIGTERM too", pid)
+	}
+}
+
+func TestFailedStepStopsChildProcesses(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("reads /proc")
+	}
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	config := &internal.CommandConfig{Command: "sh", Args: []string{"-c", "sleep 30 >/dev/null 2>&1 & echo $! > " + pidFile + "; exit 3"}}
+	var out bytes.Buffer
+	_, err := internal.RunStepsReport([]*internal.CommandConfig{config}, nil, internal.ReportOptions{Stdout: &out, Stderr: &out})
+	if internal.ExitCode(err) != 3 {
+		t.Fatalf("Expected exit code 3, got %v", err)
+	}
+	if pid := readPID(t, pidFile); !waitExited(pid) {
+		t.Errorf("Expected the failed command's child %d to be killed", pid)
+	}
+}
+
+func TestStopJobStopsChildProcesses(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("reads /proc")
+	}
+	dir := t.TempDir()
+	t.Setenv("LINEA_HOME", t.TempDir())
+	t.Setenv("LINEA_STATE_DIR", "")
+	os.MkdirAll(filepath.Join(dir, ".linea"), 0755)
+	pidFile := filepath.Join(dir, "pid")
+	path := writeWorkflow(t, dir, "server.yml", "name: server\ncommand: sh\nargs: [\"-c\", \"sleep 30 & echo $! > "+pidFile+"; wait\"]\nbackground: true\n")
+	configs, err := internal.ParseMultiYAML(path)
+	if err != nil {
+		t.Fatalf("ParseMultiYAML failed: %v", err)
+	}
+	cmd, err := internal.BuildCommand(configs[0], nil)
+	if err != nil {
+		t.Fatalf("BuildCommand failed: %v", err)
+	}
+	var out bytes.Buffer
+	if err := internal.ExecuteStepWithOutput(configs[0], cmd, nil, &out, &out); err != nil {
+		t.Fatalf("Starting the job failed: %v", err)
+	}
+	child := readPID(t, pidFile)
+
+	jobsDir := internal.JobsDir(dir)
+	job, err := internal.LoadJob(jobsDir, "server")
+	if err != nil {
+		t.Fatalf("LoadJob failed: %v", err)
+	}
+	if err := internal.StopJob(jobsDir, job, 5*time.Second); err != nil {
+		t.Fatalf("StopJob failed: %v", err)
+	}
+	if !waitExited(job.PID) || !waitExited(child) {
+		t.Errorf("Expected the job %d and its child %d to be stopped", job.PID, child)
+	}
+}
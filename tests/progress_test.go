@@ -0,0 +1,72 @@
+package tests
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+func TestRunStepsReportProgress(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	progress, err := internal.OpenProgressFile(path)
+	if err != nil {
+		t.Fatalf("OpenProgressFile failed: %v", err)
+	}
+	configs := []*internal.CommandConfig{
+		{Name: "greet", Command: "echo", Args: []string{"a > b"}},
+		{Command: "echo", Args: []string{"${missing}"}},
+		{Command: "echo", Args: []string{"never"}},
+	}
+	internal.RunStepsReport(configs, nil, internal.ReportOptions{Stdout: os.Stderr, Stderr: os.Stderr, Progress: progress, Workflow: "deploy"})
+	progress.Close()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var events []map[string]interface{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("Invalid event line %q: %v", scanner.Text(), err)
+		}
+		events = append(events, event)
+	}
+
+	var kinds []string
+	for _, event := range events {
+		kinds = append(kinds, event["event"].(string))
+	}
+	if got := strings.Join(kinds, ","); got != "step_start,step_end,step_end,step_end" {
+		t.Fatalf("Unexpected events: %s", got)
+	}
+	if events[0]["workflow"] != "deploy" || events[0]["name"] != "greet" || events[0]["steps"] != float64(3) {
+		t.Errorf("Unexpected step_start event: %v", events[0])
+	}
+	if _, ok := events[0]["exit_code"]; ok {
+		t.Errorf("Expected no exit code on a start event: %v", events[0])
+	}
+	if events[1]["status"] != "success" || events[1]["exit_code"] != float64(0) {
+		t.Errorf("Unexpected step_end event: %v", events[1])
+	}
+	if events[2]["status"] != "failed" || events[2]["error"] == nil || events[3]["status"] != "not_run" {
+		t.Errorf("Unexpected failure events: %v, %v", events[2], events[3])
+	}
+}
+
+func TestProgressToFD(t *testing.T) {
+	for _, fd := range []string{"abc", "-1", "1"} {
+		if _, err := internal.ProgressToFD(fd); err == nil {
+			t.Errorf("Expected file descriptor %s to be rejected", fd)
+		}
+	}
+	var progress *internal.Progress
+	progress.Emit(internal.ProgressEvent{Event: internal.EventRunStart}) // A nil Progress discards events
+}
@@ -0,0 +1,168 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+func TestProviderVariablesParsed(t *testing.T) {
+	path := writeWorkflow(t, t.TempDir(), "deploy.yml", `command: echo
+args: ["{region}", "{ami}"]
+variables:
+  region: eu-west-1
+  ami:
+    provider: http
+    url: https://example.com/ami
+    field: images.0.id
+    cache: 10m
+`)
+
+	configs, err := internal.ParseMultiYAML(path)
+	if err != nil {
+		t.Fatalf("ParseMultiYAML failed: %v", err)
+	}
+	config := configs[0]
+	if config.Variables["region"] != "eu-west-1" || len(config.Variables) != 1 {
+		t.Errorf("Expected only plain variables in Variables, got %v", config.Variables)
+	}
+	spec, ok := config.Providers["ami"]
+	if !ok || spec.Provider != "http" || spec.URL != "https://example.com/ami" || spec.Field != "images.0.id" || spec.Cache != "10m" {
+		t.Errorf("Unexpected provider spec %+v", spec)
+	}
+
+	problems, err := internal.ValidateWorkflowFile(path, nil)
+	if err != nil || len(problems) != 0 {
+		t.Errorf("Expected no problems, got %v (%v)", problemMessages(problems), err)
+	}
+	if info := internal.IndexWorkflowFile(path); strings.Join(info.Variables, ",") != "ami,region" || len(info.Required) != 0 {
+		t.Errorf("Expected provider variables to be declared, got %v (required %v)", info.Variables, info.Required)
+	}
+}
+
+func TestProviderVariablesResolved(t *testing.T) {
+	t.Setenv("LINEA_CACHE_DIR", t.TempDir())
+	t.Setenv("LINEA_TEST_PROVIDER", "from-env")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"images": [{"id": "ami-0abc", "size": 8}]}`)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	writeWorkflow(t, dir, "version.txt", "1.4.2\n")
+	path := writeWorkflow(t, dir, "deploy.yml", `command: deploy
+args: ["{ami}", "{version}", "{size}", "{home}", "{greeting}"]
+variables:
+  ami:
+    provider: http
+    url: `+server.URL+`
+    headers:
+      Authorization: Bearer token
+    field: images.0.id
+  size:
+    provider: http
+    url: `+server.URL+`
+    headers:
+      Authorization: Bearer token
+    field: images.0.size
+  version:
+    provider: file
+    path: version.txt
+  home:
+    provider: env
+    name: LINEA_TEST_PROVIDER
+  greeting:
+    provider: exec
+    command: echo
+    args: [hello]
+  unused:
+    provider: exec
+    command: linea-provider-that-does-not-exist
+`)
+
+	configs, err := internal.ParseMultiYAML(path)
+	if err != nil {
+		t.Fatalf("ParseMultiYAML failed: %v", err)
+	}
+	cmd, err := internal.BuildCommand(configs[0], nil)
+	if err != nil {
+		t.Fatalf("BuildCommand failed: %v", err)
+	}
+	if got := strings.Join(cmd, " "); got != "deploy ami-0abc 1.4.2 8 from-env hello" {
+		t.Errorf("Unexpected command '%s'", got)
+	}
+}
+
+func TestProviderVariableErrors(t *testing.T) {
+	if err := internal.CheckProviderSpec("x", internal.ProviderSpec{Provider: "vault"}); err == nil || !strings.Contains(err.Error(), "unknown provider") {
+		t.Errorf("Expected unknown provider error, got %v", err)
+	}
+	if err := internal.CheckProviderSpec("x", internal.ProviderSpec{Provider: "http"}); err == nil {
+		t.Error("Expected error for http provider without url")
+	}
+	if err := internal.CheckProviderSpec("x", internal.ProviderSpec{Provider: "file", Path: "a", Cache: "soon"}); err == nil {
+		t.Error("Expected error for invalid cache age")
+	}
+
+	if _, err := internal.ResolveProviderVariable("token", internal.ProviderSpec{Provider: "env", Name: "LINEA_TEST_UNSET_VARIABLE"}, "."); err == nil {
+		t.Error("Expected error for unset environment variable")
+	}
+	value, err := internal.ResolveProviderVariable("token", internal.ProviderSpec{Provider: "env", Name: "LINEA_TEST_UNSET_VARIABLE", Default: "fallback"}, ".")
+	if err != nil || value != "fallback" {
+		t.Errorf("Expected default value, got '%s' (%v)", value, err)
+	}
+	if _, err := internal.ResolveProviderVariable("v", internal.ProviderSpec{Provider: "exec", Command: "echo", Args: []string{"not json"}, Field: "id"}, "."); err == nil {
+		t.Error("Expected error extracting a field from non-JSON output")
+	}
+}
+
+func TestRegisterVariableProvider(t *testing.T) {
+	calls := 0
+	internal.RegisterVariableProvider("test-counter", internal.VariableProviderFunc(func(name string, spec internal.ProviderSpec, dir string) (string, error) {
+		calls++
+		return fmt.Sprintf("%s-%d", name, calls), nil
+	}))
+
+	spec := internal.ProviderSpec{Provider: "test-counter"}
+	first, err := internal.ResolveProviderVariable("build", spec, "/project")
+	if err != nil || first != "build-1" {
+		t.Fatalf("Expected build-1, got '%s' (%v)", first, err)
+	}
+	// Values are resolved once per run
+	if second, _ := internal.ResolveProviderVariable("build", spec, "/project"); second != first || calls != 1 {
+		t.Errorf("Expected the memoized value, got '%s' after %d calls", second, calls)
+	}
+}
+
+func TestProviderVariableCache(t *testing.T) {
+	cache := t.TempDir()
+	t.Setenv("LINEA_CACHE_DIR", cache)
+
+	internal.RegisterVariableProvider("test-cached", internal.VariableProviderFunc(func(name string, spec internal.ProviderSpec, dir string) (string, error) {
+		return "value", nil
+	}))
+
+	if _, err := internal.ResolveProviderVariable("v", internal.ProviderSpec{Provider: "test-cached"}, "/a"); err != nil {
+		t.Fatalf("ResolveProviderVariable failed: %v", err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(cache, "providers", "*.json")); len(files) != 0 {
+		t.Errorf("Expected no cache file without cache:, got %v", files)
+	}
+
+	if _, err := internal.ResolveProviderVariable("v", internal.ProviderSpec{Provider: "test-cached", Cache: "1h"}, "/a"); err != nil {
+		t.Fatalf("ResolveProviderVariable failed: %v", err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(cache, "providers", "*.json")); len(files) != 1 {
+		t.Errorf("Expected one cache file with cache:, got %v", files)
+	}
+}
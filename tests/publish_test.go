@@ -0,0 +1,94 @@
+package tests
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+func TestBumpVersion(t *testing.T) {
+	for _, c := range []struct{ version, part, want string }{
+		{"1.2.3", internal.BumpPatch, "1.2.4"},
+		{"1.2.3", internal.BumpMinor, "1.3.0"},
+		{"1.2.3", internal.BumpMajor, "2.0.0"},
+		{"v0.9.9", internal.BumpMinor, "v0.10.0"},
+		{"1.0.0-rc.1", internal.BumpPatch, "1.0.1"},
+	} {
+		if got, err := internal.BumpVersion(c.version, c.part); err != nil || got != c.want {
+			t.Errorf("Expected %s %s to be %s, got %s, %v", c.part, c.version, c.want, got, err)
+		}
+	}
+	for _, c := range [][2]string{{"1.2", internal.BumpPatch}, {"1.x.0", internal.BumpPatch}, {"1.2.3", "build"}} {
+		if _, err := internal.BumpVersion(c[0], c[1]); err == nil {
+			t.Errorf("Expected %s %s to be rejected", c[1], c[0])
+		}
+	}
+}
+
+func TestPublishPackage(t *testing.T) {
+	t.Setenv("LINEA_HOME", t.TempDir())
+	uploads := map[string][]byte{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/index.json":
+			json.NewEncoder(w).Encode(internal.PackageIndex{Packages: []internal.PackageEntry{{Name: "docker-tools", Version: "1.0.0", URL: "x.tar.gz"}}})
+		case r.Method == http.MethodPut && r.Header.Get("Authorization") != "Bearer s3cret":
+			http.Error(w, "bad token", http.StatusUnauthorized)
+		case r.Method == http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			sum := sha256.Sum256(body)
+			if r.Header.Get("X-Linea-Package-Sha256") != hex.EncodeToString(sum[:]) {
+				http.Error(w, "checksum", http.StatusBadRequest)
+				return
+			}
+			uploads[r.URL.Path] = body
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer server.Close()
+	t.Setenv(internal.RegistryEnv, server.URL+"/index.json")
+	t.Setenv(internal.PublishURLEnv, server.URL+"/upload/")
+	t.Setenv(internal.RegistryTokenEnv, "s3cret")
+
+	dir := writePackage(t, "docker-tools", "1.0.0")
+	metadata := filepath.Join(dir, "linea-package.yml")
+	if _, err := internal.PublishPackage(dir, internal.PublishOptions{}); !errors.Is(err, internal.ErrAlreadyPublished) {
+		t.Errorf("Expected the published version to be refused, got %v", err)
+	}
+
+	// A dry run and a refused upload leave the version as it was
+	if result, err := internal.PublishPackage(dir, internal.PublishOptions{Bump: internal.BumpMinor, DryRun: true}); err != nil || result.Meta.Version != "1.1.0" || result.URL != "" {
+		t.Errorf("Expected a dry run of 1.1.0, got %+v, %v", result, err)
+	}
+	t.Setenv(internal.RegistryTokenEnv, "wrong")
+	if _, err := internal.PublishPackage(dir, internal.PublishOptions{Bump: internal.BumpPatch}); err == nil || !strings.Contains(err.Error(), internal.RegistryTokenEnv) {
+		t.Errorf("Expected the token to be refused, got %v", err)
+	}
+	if data, _ := os.ReadFile(metadata); !strings.Contains(string(data), "version: 1.0.0\n") {
+		t.Errorf("Expected the version to be restored, got %s", data)
+	}
+
+	t.Setenv(internal.RegistryTokenEnv, "s3cret")
+	result, err := internal.PublishPackage(dir, internal.PublishOptions{Bump: internal.BumpPatch})
+	if err != nil || result.Previous != "1.0.0" || result.Meta.Version != "1.0.1" {
+		t.Fatalf("Expected 1.0.1 to be published, got %+v, %v", result, err)
+	}
+	if data, _ := os.ReadFile(metadata); !strings.Contains(string(data), "version: 1.0.1\ndescription: Docker helpers\n") {
+		t.Errorf("Expected the bumped version to be kept, got %s", data)
+	}
+	archive := filepath.Join(t.TempDir(), "published.tar.gz")
+	os.WriteFile(archive, uploads["/upload/docker-tools/1.0.1"], 0644)
+	if meta, _, err := internal.InstallPackage(archive); err != nil || meta.Version != "1.0.1" {
+		t.Errorf("Expected the uploaded archive to install, got %+v, %v", meta, err)
+	}
+}
@@ -0,0 +1,91 @@
+package tests
+
+import (
+	"os/exec"
+	"runtime"
+	"testing"
+
+	"linea/internal"
+)
+
+func TestShellQuote(t *testing.T) {
+	cmd := []string{"git", "commit", "-m", "fix: it's done", "--author", "", "a/b.txt", `C:\path`}
+	cases := map[string]string{
+		internal.QuotePOSIX:      `git commit -m 'fix: it'\''s done' --author '' a/b.txt 'C:\path'`,
+		internal.QuotePowerShell: `git commit -m 'fix: it''s done' --author '' a/b.txt C:\path`,
+		internal.QuoteCmd:        `git commit -m "fix: it's done" --author "" a/b.txt C:\path`,
+		internal.QuoteNone:       `git commit -m fix: it's done --author  a/b.txt C:\path`,
+	}
+	for style, want := range cases {
+		if got := internal.ShellQuote(cmd, style); got != want {
+			t.Errorf("%s: expected %s, got %s", style, want, got)
+		}
+	}
+}
+
+func TestShellQuoteProgram(t *testing.T) {
+	cmd := []string{`C:\Program Files\tool.exe`, "--flag"}
+	if got := internal.ShellQuote(cmd, internal.QuotePowerShell); got != `& 'C:\Program Files\tool.exe' --flag` {
+		t.Errorf("Expected a quoted PowerShell program to be invoked with &, got %s", got)
+	}
+	if got := internal.ShellQuote(cmd, internal.QuoteCmd); got != `"C:\Program Files\tool.exe" --flag` {
+		t.Errorf("Expected cmd quoting, got %s", got)
+	}
+}
+
+func TestQuoteArgCmdBackslashes(t *testing.T) {
+	cases := map[string]string{
+		`say "hi"`:     `"say \"hi\""`,
+		`dir with\`:    `"dir with\\"`,
+		`a\"b c`:       `"a\\\"b c"`,
+		`\\server\x y`: `"\\server\x y"`,
+	}
+	for arg, want := range cases {
+		if got := internal.QuoteArg(arg, internal.QuoteCmd); got != want {
+			t.Errorf("QuoteArg(%q): expected %s, got %s", arg, want, got)
+		}
+	}
+}
+
+func TestParseQuoteStyle(t *testing.T) {
+	for _, style := range []string{"posix", "powershell", "cmd", "none"} {
+		if _, err := internal.ParseQuoteStyle(style); err != nil {
+			t.Errorf("Expected %s to be valid, got %v", style, err)
+		}
+	}
+	if _, err := internal.ParseQuoteStyle("bash"); err == nil {
+		t.Error("Expected an error for an unknown style")
+	}
+}
+
+func TestFormatCommandQuotesAndMasks(t *testing.T) {
+	internal.RegisterSecret("hunter2-quote")
+	got := internal.FormatCommandStyle([]string{"curl", "-H", "Authorization: hunter2-quote"}, internal.QuotePOSIX)
+	if got != "curl -H 'Authorization: "+internal.SecretMask+"'" {
+		t.Errorf("Expected a quoted, masked command, got %s", got)
+	}
+}
+
+func TestShellQuoteRoundTrip(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not found")
+	}
+
+	args := []string{"it's", "", "a b", `"q"`, "$HOME", "`x`", `back\slash`, "*", "new\nline"}
+	line := internal.ShellQuote(append([]string{"printf", "[%s]"}, args...), internal.QuotePOSIX)
+	out, err := exec.Command(sh, "-c", line).Output()
+	if err != nil {
+		t.Fatalf("sh -c %s: %v", line, err)
+	}
+	want := ""
+	for _, arg := range args {
+		want += "[" + arg + "]"
+	}
+	if string(out) != want {
+		t.Errorf("Expected %q, got %q", want, out)
+	}
+}
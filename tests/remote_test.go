@@ -0,0 +1,115 @@
+package tests
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+func TestBuildCommandWithSSHRunner(t *testing.T) {
+	nice := 5
+	config := &internal.CommandConfig{
+		Command:   "systemctl",
+		Args:      []string{"restart", "$service", "it's/here"},
+		Variables: map[string]string{"env": "prod", "service": "api"},
+		Runner:    &internal.RunnerConfig{Type: internal.RunnerSSH, Host: "{env}.example.com", User: "deploy", Port: 2222, Key: "~/.ssh/deploy", Sudo: true},
+		Limits:    &internal.ResourceLimits{Nice: &nice},
+	}
+	cmd, err := internal.BuildCommand(config, map[string]string{"service": "worker"})
+	if err != nil {
+		t.Fatalf("BuildCommand failed: %v", err)
+	}
+	want := []string{"ssh", "-o", "BatchMode=yes", "-p", "2222", "-i", "~/.ssh/deploy", "-l", "deploy", "--", "prod.example.com",
+		`sudo -n -- /bin/sh -c 'exec nice -n 5 "$@"' linea systemctl restart worker 'it'\''s/here'`}
+	if strings.Join(cmd, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected\n%q\ngot\n%q", want, cmd)
+	}
+
+	config = &internal.CommandConfig{Command: "htop", Interactive: true, Runner: &internal.RunnerConfig{Type: internal.RunnerSSH, Host: "web1", Sudo: true}}
+	cmd, err = internal.BuildCommand(config, nil)
+	if err != nil {
+		t.Fatalf("BuildCommand failed: %v", err)
+	}
+	if got := strings.Join(cmd, " "); got != "ssh -t -- web1 sudo -- htop" {
+		t.Errorf("Expected a terminal and sudo allowed to ask for the password, got %s", got)
+	}
+}
+
+func TestBuildCommandWithDockerRunner(t *testing.T) {
+	dir := t.TempDir()
+	config := &internal.CommandConfig{
+		Command:    "npm",
+		Args:       []string{"test", "src/app.test.js"},
+		Variables:  map[string]string{"node": "20"},
+		SourceFile: filepath.Join(dir, "test.yml"),
+		Runner: &internal.RunnerConfig{
+			Type:    internal.RunnerDocker,
+			Image:   "node:{node}",
+			Volumes: []string{"./:/app", "cache:/root/.npm", "/etc/ssl:/etc/ssl:ro"},
+			Workdir: "/app",
+			Env:     map[string]string{"NODE_ENV": "test", "CI": "true"},
+		},
+		Limits: &internal.ResourceLimits{Memory: "1G", CPUTime: "90s"},
+	}
+	cmd, err := internal.BuildCommand(config, nil)
+	if err != nil {
+		t.Fatalf("BuildCommand failed: %v", err)
+	}
+	want := []string{"docker", "run", "--rm", "-i", "-v", dir + ":/app", "-v", "cache:/root/.npm", "-v", "/etc/ssl:/etc/ssl:ro",
+		"-w", "/app", "-e", "CI=true", "-e", "NODE_ENV=test", "--memory", "1073741824", "--ulimit", "cpu=90:90", "--", "node:20",
+		"npm", "test", "src/app.test.js"}
+	if strings.Join(cmd, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected\n%q\ngot\n%q", want, cmd)
+	}
+}
+
+func TestCheckRunner(t *testing.T) {
+	for _, config := range []*internal.CommandConfig{
+		{Command: "make", Runner: &internal.RunnerConfig{Host: "web1"}},
+		{Command: "make", Runner: &internal.RunnerConfig{Type: "telnet", Host: "web1"}},
+		{Command: "make", Runner: &internal.RunnerConfig{Type: internal.RunnerSSH}},
+		{Command: "make", Runner: &internal.RunnerConfig{Type: internal.RunnerSSH, Host: "web1", Port: 70000}},
+		{Command: "server", Background: true, Runner: &internal.RunnerConfig{Type: internal.RunnerSSH, Host: "web1"}},
+		{Type: "copy", Runner: &internal.RunnerConfig{Type: internal.RunnerSSH, Host: "web1"}},
+		{Command: "make", Runner: &internal.RunnerConfig{Type: internal.RunnerDocker}},
+		{Command: "make", Runner: &internal.RunnerConfig{Type: internal.RunnerDocker, Image: "golang", Host: "web1"}},
+		{Command: "make", Runner: &internal.RunnerConfig{Type: internal.RunnerSSH, Host: "web1", Image: "golang"}},
+		{Command: "make", Runner: &internal.RunnerConfig{Type: internal.RunnerDocker, Image: "golang", Volumes: []string{"src"}}},
+	} {
+		if err := internal.CheckRunner(config); err == nil {
+			t.Errorf("Expected runner %+v to be rejected", *config.Runner)
+		}
+	}
+}
+
+func TestSSHRunnerStreamsOutput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fakes ssh with a shell script")
+	}
+	// The fake ssh runs the remote command with the local shell
+	bin := t.TempDir()
+	script := "#!/bin/sh\nfor last; do :; done\nexec sh -c \"$last\"\n"
+	if err := os.WriteFile(filepath.Join(bin, "ssh"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	config := &internal.CommandConfig{
+		Command: "sh",
+		Args:    []string{"-c", "echo 'on the host'; echo oops >&2; exit 3"},
+		Runner:  &internal.RunnerConfig{Type: internal.RunnerSSH, Host: "web1"},
+	}
+	var stdout, stderr bytes.Buffer
+	results, err := internal.RunStepsReport([]*internal.CommandConfig{config}, nil, internal.ReportOptions{Stdout: &stdout, Stderr: &stderr})
+	if internal.ExitCode(err) != 3 || results[0].ExitCode != 3 {
+		t.Errorf("Expected the remote exit code 3, got %v", err)
+	}
+	if stdout.String() != "on the host\n" || stderr.String() != "oops\n" {
+		t.Errorf("Expected the remote output, got %q and %q", stdout.String(), stderr.String())
+	}
+}
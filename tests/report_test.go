@@ -0,0 +1,284 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+	"linea/internal"
+)
+
+func TestRunStepsReport(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	configs := []*internal.CommandConfig{
+		{Name: "greet", Command: "echo", Args: []string{"hello", "{who}"}, Variables: map[string]string{"who": "world"}},
+		{Command: "sh", Args: []string{"-c", "echo oops >&2; exit 3"}},
+		{Command: "echo", Args: []string{"never"}},
+	}
+
+	var log bytes.Buffer
+	results, err := internal.RunStepsReport(configs, nil, internal.ReportOptions{Stdout: &log, Stderr: &log, Capture: true})
+	if err == nil || internal.ExitCode(err) != 3 {
+		t.Fatalf("Expected step 2 to fail with exit code 3, got %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Expected a result for every step, got %d", len(results))
+	}
+
+	first := results[0]
+	if first.Status != internal.StepSucceeded || first.Name != "greet" || strings.Join(first.Command, " ") != "echo hello world" {
+		t.Errorf("Unexpected first step: %+v", first)
+	}
+	if first.Stdout == nil || *first.Stdout != "hello world\n" {
+		t.Errorf("Expected captured stdout, got %v", first.Stdout)
+	}
+
+	second := results[1]
+	if second.Status != internal.StepFailed || second.ExitCode != 3 || second.Stderr == nil || *second.Stderr != "oops\n" {
+		t.Errorf("Unexpected second step: %+v", second)
+	}
+	if results[2].Status != internal.StepNotRun {
+		t.Errorf("Expected the third step not to run, got %s", results[2].Status)
+	}
+
+	// Captured output is still streamed to the log
+	if log.String() != "hello world\noops\n" {
+		t.Errorf("Expected the commands' output in the log, got %q", log.String())
+	}
+}
+
+func TestRunStepsReportWithoutCapture(t *testing.T) {
+	configs := []*internal.CommandConfig{{Command: "echo", Args: []string{"hi"}}}
+	results, err := internal.RunStepsReport(configs, nil, internal.ReportOptions{Stdout: io.Discard, Stderr: io.Discard})
+	if err != nil {
+		t.Fatalf("RunStepsReport failed: %v", err)
+	}
+	if results[0].Stdout != nil || results[0].Stderr != nil {
+		t.Errorf("Expected no captured output without Capture, got %+v", results[0])
+	}
+}
+
+func TestDryRunStepsReport(t *testing.T) {
+	configs := []*internal.CommandConfig{
+		{Command: "echo", Args: []string{"{name}"}, Variables: map[string]string{"name": "alice"}},
+		{Command: "echo", Args: []string{"${missing}"}},
+		{Command: "echo", Args: []string{"unreached"}},
+	}
+
+	results, err := internal.DryRunStepsReport(configs, nil, true)
+	if err == nil {
+		t.Fatal("Expected an error for the undefined variable")
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected to stop after the failing step, got %d results", len(results))
+	}
+	if results[0].Status != internal.StepDryRun || len(results[0].Variables) != 1 || results[0].Variables[0].Source != internal.SourceYAML {
+		t.Errorf("Unexpected first step: %+v", results[0])
+	}
+	if results[1].Status != internal.StepFailed || results[1].Error == "" {
+		t.Errorf("Expected the second step to fail with an error, got %+v", results[1])
+	}
+}
+
+func TestWriteStructured(t *testing.T) {
+	report := &internal.RunReport{
+		Workflow: "deploy",
+		Status:   internal.StepFailed,
+		ExitCode: 2,
+		Steps:    []internal.StepResult{{Index: 1, Command: []string{"sh", "-c", "a && b > c"}, Status: internal.StepFailed, ExitCode: 2}},
+	}
+
+	var out bytes.Buffer
+	if err := internal.WriteStructured(&out, internal.OutputJSON, report); err != nil {
+		t.Fatalf("WriteStructured json failed: %v", err)
+	}
+	if !strings.Contains(out.String(), `"a && b > c"`) {
+		t.Errorf("Expected shell operators to be written unescaped, got:\n%s", out.String())
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("Output is not valid JSON: %v", err)
+	}
+	if decoded["exit_code"] != float64(2) || decoded["status"] != "failed" {
+		t.Errorf("Unexpected JSON fields: %v", decoded)
+	}
+
+	out.Reset()
+	if err := internal.WriteStructured(&out, internal.OutputYAML, report); err != nil {
+		t.Fatalf("WriteStructured yaml failed: %v", err)
+	}
+	var fromYAML internal.RunReport
+	if err := yaml.Unmarshal(out.Bytes(), &fromYAML); err != nil {
+		t.Fatalf("Output is not valid YAML: %v", err)
+	}
+	if fromYAML.Workflow != "deploy" || len(fromYAML.Steps) != 1 || fromYAML.Steps[0].ExitCode != 2 {
+		t.Errorf("Unexpected YAML round trip: %+v", fromYAML)
+	}
+}
+
+func TestParseOutputFormat(t *testing.T) {
+	for _, format := range []string{"text", "json", "yaml"} {
+		if _, err := internal.ParseOutputFormat(format); err != nil {
+			t.Errorf("Expected %s to be accepted: %v", format, err)
+		}
+	}
+	if _, err := internal.ParseOutputFormat("xml"); err == nil {
+		t.Error("Expected xml to be rejected")
+	}
+}
+
+func TestRunStepsReportKeepGoing(t *testing.T) {
+	configs := []*internal.CommandConfig{
+		{Command: "linea-definitely-missing-tool"},
+		{Command: "echo", Args: []string{"${missing}"}},
+		{Command: "echo", Args: []string{"still runs"}},
+	}
+
+	var out, errOut bytes.Buffer
+	results, err := internal.RunStepsReport(configs, nil, internal.ReportOptions{Stdout: &out, Stderr: &errOut, KeepGoing: true})
+	if err == nil || !strings.Contains(err.Error(), "command 1") {
+		t.Fatalf("Expected the first failure to be returned, got %v", err)
+	}
+	if results[0].Status != internal.StepFailed || results[1].Status != internal.StepFailed || results[2].Status != internal.StepSucceeded {
+		t.Errorf("Expected every step to run, got %+v", results)
+	}
+	if out.String() != "still runs\n" {
+		t.Errorf("Expected the last step's output, got %q", out.String())
+	}
+	if !strings.Contains(errOut.String(), "Error building command 2") {
+		t.Errorf("Expected each failure to be reported as it happens, got %q", errOut.String())
+	}
+
+	report := &internal.RunReport{Status: internal.StepFailed, Steps: results}
+	if got := report.FailedSteps(); got != 2 {
+		t.Errorf("Expected 2 failed steps, got %d", got)
+	}
+}
+
+func TestFailedStepsWithoutSteps(t *testing.T) {
+	report := &internal.RunReport{Status: internal.StepFailed, Steps: []internal.StepResult{}}
+	if got := report.FailedSteps(); got != 1 {
+		t.Errorf("Expected a workflow that failed to load to count as one failure, got %d", got)
+	}
+	if got := (&internal.RunReport{Status: internal.StepSucceeded}).FailedSteps(); got != 0 {
+		t.Errorf("Expected no failures, got %d", got)
+	}
+}
+
+func TestFailureExitCode(t *testing.T) {
+	for failures, want := range map[int]int{0: 0, 3: 3, 125: 125, 300: 125} {
+		if got := internal.FailureExitCode(failures); got != want {
+			t.Errorf("FailureExitCode(%d) = %d, want %d", failures, got, want)
+		}
+	}
+}
+
+func TestSummarizeRuns(t *testing.T) {
+	reports := []*internal.RunReport{
+		{Workflow: "build", Status: internal.StepFailed, DurationMs: 900, Steps: []internal.StepResult{
+			{Index: 1, Name: "compile", Status: internal.StepSucceeded, DurationMs: 700},
+			{Index: 2, Status: internal.StepFailed, ExitCode: 2, DurationMs: 150},
+			{Index: 3, Status: internal.StepNotRun},
+		}},
+		{Workflow: "broken", Status: internal.StepFailed, ExitCode: 1, DurationMs: 5, Steps: []internal.StepResult{}},
+		{Workflow: "deploy", Status: internal.StepNotRun, Steps: []internal.StepResult{}},
+	}
+
+	summary := internal.SummarizeRuns(reports)
+	if summary.Status != internal.StepFailed || summary.Failures != 2 || summary.DurationMs != 905 {
+		t.Errorf("Unexpected totals: %+v", summary)
+	}
+	if len(summary.Steps) != 5 {
+		t.Fatalf("Expected a row per step and per workflow without steps, got %+v", summary.Steps)
+	}
+	if row := summary.Steps[3]; row.Workflow != "broken" || row.Index != 0 || row.ExitCode != 1 {
+		t.Errorf("Expected a row for the workflow that failed to load, got %+v", row)
+	}
+	if slowest := summary.Slowest(); slowest == nil || slowest.Name != "compile" {
+		t.Errorf("Expected compile to be the slowest step, got %+v", slowest)
+	}
+	if (&internal.RunSummary{}).Slowest() != nil {
+		t.Error("Expected no slowest step without steps")
+	}
+}
+
+func TestParseSummaryFormat(t *testing.T) {
+	for _, format := range []string{"text", "json", "none"} {
+		if _, err := internal.ParseSummaryFormat(format); err != nil {
+			t.Errorf("Expected %s to be accepted: %v", format, err)
+		}
+	}
+	if _, err := internal.ParseSummaryFormat("yaml"); err == nil {
+		t.Error("Expected yaml to be rejected")
+	}
+}
+
+func TestRunStepsReportCaptureField(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	configs := []*internal.CommandConfig{
+		{Command: "echo", Args: []string{"streamed"}},
+		{Command: "sh", Args: []string{"-c", "echo building; echo 'disk full' >&2; exit 4"}, Capture: true},
+	}
+
+	var out bytes.Buffer
+	results, err := internal.RunStepsReport(configs, nil, internal.ReportOptions{Stdout: &out, Stderr: &out})
+	if err == nil {
+		t.Fatal("Expected the second step to fail")
+	}
+	if results[0].Stdout != nil {
+		t.Errorf("Expected no capture for a step without capture:, got %q", *results[0].Stdout)
+	}
+	second := results[1]
+	if second.Stdout == nil || *second.Stdout != "building\n" || second.Stderr == nil || *second.Stderr != "disk full\n" {
+		t.Errorf("Expected the step's output to be stored, got %+v", second)
+	}
+	if second.Error != "exit status 4: disk full" {
+		t.Errorf("Expected the error to end with the step's stderr, got %q", second.Error)
+	}
+	if !strings.Contains(out.String(), "building\n") || !strings.Contains(out.String(), "disk full\n") {
+		t.Errorf("Expected captured output to be streamed too, got %q", out.String())
+	}
+}
+
+func TestRunStepsReportAllowedExitCodes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	configs := []*internal.CommandConfig{
+		{Name: "search", Command: "sh", Args: []string{"-c", "exit 1"}, AllowedExitCodes: []int{0, 1}},
+		{Name: "found", Command: "echo", Args: []string{"found"}, When: "{exit_code} == 0"},
+		{Name: "none", Command: "echo", Args: []string{"none {exit_code}"}, When: "{exit_code} == 1"},
+		{Name: "strict", Command: "sh", Args: []string{"-c", "exit 2"}, AllowedExitCodes: []int{0, 1}},
+	}
+
+	var out bytes.Buffer
+	results, err := internal.RunStepsReport(configs, nil, internal.ReportOptions{Stdout: &out, Stderr: &out})
+	if err == nil {
+		t.Fatal("Expected exit code 2 to fail the last step")
+	}
+	want := []struct {
+		status string
+		code   int
+	}{
+		{internal.StepSucceeded, 1},
+		{internal.StepSkipped, 0},
+		{internal.StepSucceeded, 0},
+		{internal.StepFailed, 2},
+	}
+	for i, w := range want {
+		if results[i].Status != w.status || results[i].ExitCode != w.code {
+			t.Errorf("Step %d: expected %s with exit code %d, got %s with %d", i+1, w.status, w.code, results[i].Status, results[i].ExitCode)
+		}
+	}
+	if out.String() != "none 1\n" {
+		t.Errorf("Expected only the step for exit code 1 to run, got %q", out.String())
+	}
+}
@@ -0,0 +1,52 @@
+package tests
+
+import (
+	"testing"
+
+	"linea/internal"
+)
+
+func TestExplainVariables(t *testing.T) {
+	config := &internal.CommandConfig{
+		Command:   "echo",
+		Args:      []string{"{name}", "$name", "{who}", "{env|default:dev}", "{os}", "$region", "{name}"},
+		Variables: map[string]string{"name": "alice"},
+	}
+
+	resolutions, err := internal.ExplainVariables(config, map[string]string{"name": "bob"})
+	if err != nil {
+		t.Fatalf("ExplainVariables failed: %v", err)
+	}
+	if len(resolutions) != 6 {
+		t.Fatalf("Expected 6 distinct placeholders, got %d: %+v", len(resolutions), resolutions)
+	}
+
+	brace := resolutions[0]
+	if brace.Reference != "{name}" || brace.Value != "alice" || brace.Source != internal.SourceYAML {
+		t.Errorf("Expected {name} to come from variables:, got %+v", brace)
+	}
+	if len(brace.Ignored) != 1 || brace.Ignored[0].Source != internal.SourceSet {
+		t.Errorf("Expected {name} to ignore --set, got %+v", brace.Ignored)
+	}
+
+	dollar := resolutions[1]
+	if dollar.Value != "bob" || dollar.Source != internal.SourceSet {
+		t.Errorf("Expected $name to come from --set, got %+v", dollar)
+	}
+	if len(dollar.Overrides) != 1 || dollar.Overrides[0].Value != "alice" {
+		t.Errorf("Expected $name to override the YAML value, got %+v", dollar.Overrides)
+	}
+
+	if resolutions[2].Resolved() || resolutions[2].Hint == "" {
+		t.Errorf("Expected {who} to be unresolved with a hint, got %+v", resolutions[2])
+	}
+	if r := resolutions[3]; r.Value != "dev" || r.Source != internal.SourceDefault {
+		t.Errorf("Expected the pipeline default to be used, got %+v", r)
+	}
+	if r := resolutions[4]; r.Source != internal.SourceBuiltin {
+		t.Errorf("Expected {os} to be a built-in, got %+v", r)
+	}
+	if r := resolutions[5]; r.Resolved() {
+		t.Errorf("Expected $region to be unresolved, got %+v", r)
+	}
+}
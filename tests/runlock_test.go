@@ -0,0 +1,112 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"linea/internal"
+)
+
+// lockedWorkflow writes a workflow in a project and returns its path and steps
+func lockedWorkflow(t *testing.T, lockWait string) (string, []*internal.CommandConfig) {
+	t.Helper()
+	path := projectWorkflow(t, "deploy.yml", "command: echo\nargs: [deploying]\nlock: true\n")
+	return path, []*internal.CommandConfig{{Command: "echo", Lock: true, LockWait: lockWait}}
+}
+
+func TestLockWorkflow(t *testing.T) {
+	path, configs := lockedWorkflow(t, "")
+	lock, err := internal.LockWorkflow(path, configs, os.Stderr)
+	if err != nil || lock == nil {
+		t.Fatalf("LockWorkflow failed: %v", err)
+	}
+	lockFile := internal.RunLockPath(path)
+	if filepath.Base(filepath.Dir(lockFile)) != "locks" || !strings.HasPrefix(lockFile, filepath.Join(filepath.Dir(path), ".linea", "run")) {
+		t.Errorf("Expected the lock in .linea/run/locks, got %s", lockFile)
+	}
+	if _, err := os.Stat(lockFile); err != nil {
+		t.Errorf("Expected the lock file to exist: %v", err)
+	}
+
+	_, err = internal.LockWorkflow(path, configs, os.Stderr)
+	if err == nil || !strings.Contains(err.Error(), fmt.Sprintf("workflow 'deploy' is already running (pid %d, started at ", os.Getpid())) {
+		t.Errorf("Expected the second run to fail at once, got %v", err)
+	}
+
+	lock.Unlock()
+	if _, err := os.Stat(lockFile); !os.IsNotExist(err) {
+		t.Errorf("Expected Unlock to remove the lock file, got %v", err)
+	}
+	if lock, err := internal.LockWorkflow(path, []*internal.CommandConfig{{Command: "echo"}}, os.Stderr); lock != nil || err != nil {
+		t.Errorf("Expected no lock for a workflow without lock: true, got %+v %v", lock, err)
+	}
+}
+
+func TestLockWorkflowWait(t *testing.T) {
+	path, configs := lockedWorkflow(t, "5s")
+	first, err := internal.LockWorkflow(path, configs, os.Stderr)
+	if err != nil {
+		t.Fatalf("LockWorkflow failed: %v", err)
+	}
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		first.Unlock()
+	}()
+
+	var stderr bytes.Buffer
+	start := time.Now()
+	second, err := internal.LockWorkflow(path, configs, &stderr)
+	if err != nil {
+		t.Fatalf("Expected the second run to wait for the lock, got %v", err)
+	}
+	defer second.Unlock()
+	if time.Since(start) < 300*time.Millisecond || !strings.Contains(stderr.String(), "Waiting for the run of 'deploy' in progress") {
+		t.Errorf("Expected the run to wait and say so, got %q after %s", stderr.String(), time.Since(start))
+	}
+
+	_, short := lockedWorkflow(t, "300ms")
+	if _, err := internal.LockWorkflow(path, short, &stderr); err == nil || !strings.Contains(err.Error(), "already running") {
+		t.Errorf("Expected the run to fail after lock_wait, got %v", err)
+	}
+}
+
+func TestLockWorkflowStale(t *testing.T) {
+	path, configs := lockedWorkflow(t, "")
+	exited := exec.Command(os.Args[0], "-test.run=^$")
+	if err := exited.Run(); err != nil {
+		t.Fatalf("Failed to run a process: %v", err)
+	}
+	stale, _ := json.Marshal(internal.RunLock{Workflow: "deploy", Path: path, PID: exited.Process.Pid, Start: time.Now().Add(-time.Hour)})
+	os.MkdirAll(filepath.Dir(internal.RunLockPath(path)), 0755)
+	os.WriteFile(internal.RunLockPath(path), stale, 0644)
+
+	lock, err := internal.LockWorkflow(path, configs, os.Stderr)
+	if err != nil {
+		t.Fatalf("Expected the lock of an exited process to be broken, got %v", err)
+	}
+	lock.Unlock()
+}
+
+func TestCheckLock(t *testing.T) {
+	for _, tc := range []struct {
+		config internal.CommandConfig
+		want   string
+	}{
+		{internal.CommandConfig{LockWait: "10m"}, "lock_wait needs lock: true"},
+		{internal.CommandConfig{Lock: true, LockWait: "soon"}, "invalid age 'soon'"},
+	} {
+		if err := internal.CheckLock(&tc.config); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Expected an error containing %q, got %v", tc.want, err)
+		}
+	}
+	if err := internal.CheckLock(&internal.CommandConfig{Lock: true, LockWait: "1h"}); err != nil {
+		t.Errorf("Expected a valid lock_wait, got %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"linea/internal"
+)
+
+func TestCreateRunLog(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "logs")
+	start := time.Date(2026, 3, 2, 10, 15, 0, 0, time.UTC)
+
+	first, err := internal.CreateRunLog(dir, "deploy", start)
+	if err != nil {
+		t.Fatalf("CreateRunLog failed: %v", err)
+	}
+	second, err := internal.CreateRunLog(dir, "deploy", start)
+	if err != nil {
+		t.Fatalf("CreateRunLog failed: %v", err)
+	}
+	if filepath.Base(first.Path) != "deploy-20260302T101500.log" || filepath.Base(second.Path) != "deploy-20260302T101500-2.log" {
+		t.Errorf("Unexpected log names %s and %s", first.Path, second.Path)
+	}
+
+	internal.RegisterSecret("s3cr3t-run-log")
+	first.Start("deploy.yml", start)
+	fmt.Fprintf(first, "token is s3cr3t-run-log\n")
+	if err := first.Finish(start, nil); err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+	second.Finish(start, nil)
+
+	data, _ := os.ReadFile(first.Path)
+	if strings.Contains(string(data), "s3cr3t-run-log") || !strings.Contains(string(data), "token is "+internal.SecretMask) {
+		t.Errorf("Expected the secret to be masked in the log, got:\n%s", data)
+	}
+	if !strings.Contains(string(data), "# succeeded") {
+		t.Errorf("Expected the outcome at the end of the log, got:\n%s", data)
+	}
+}
+
+func TestPruneRunLogs(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Now().Add(-time.Hour)
+	for i := 0; i < 5; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("w-%d.log", i))
+		os.WriteFile(path, []byte("log"), 0644)
+		os.Chtimes(path, base.Add(time.Duration(i)*time.Minute), base.Add(time.Duration(i)*time.Minute))
+	}
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("keep"), 0644)
+
+	if err := internal.PruneRunLogs(dir, 2); err != nil {
+		t.Fatalf("PruneRunLogs failed: %v", err)
+	}
+	entries, _ := os.ReadDir(dir)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if strings.Join(names, ",") != "notes.txt,w-3.log,w-4.log" {
+		t.Errorf("Expected the two newest logs to be kept, got %v", names)
+	}
+}
+
+func TestReadRunLog(t *testing.T) {
+	if _, err := internal.ReadRunLog(&internal.RunRecord{ID: "old"}); err == nil {
+		t.Error("Expected an error for a run without a log")
+	}
+	missing := &internal.RunRecord{ID: "gone", LogFile: filepath.Join(t.TempDir(), "gone.log")}
+	if _, err := internal.ReadRunLog(missing); err == nil || !strings.Contains(err.Error(), "removed") {
+		t.Errorf("Expected an error for a removed log, got %v", err)
+	}
+}
+
+func TestRecentRuns(t *testing.T) {
+	records := []internal.RunRecord{
+		{ID: "1", Workflow: "build", Success: true},
+		{ID: "2", Workflow: "deploy", Success: false},
+		{ID: "3", Workflow: "build", Success: false},
+		{ID: "4", Workflow: "build", Success: true},
+	}
+
+	ids := func(runs []internal.RunRecord) string {
+		var got []string
+		for _, run := range runs {
+			got = append(got, run.ID)
+		}
+		return strings.Join(got, ",")
+	}
+	if got := ids(internal.RecentRuns(records, "", false, 0)); got != "4,3,2,1" {
+		t.Errorf("Expected newest first, got %s", got)
+	}
+	if got := ids(internal.RecentRuns(records, "build", false, 2)); got != "4,3" {
+		t.Errorf("Expected the last two build runs, got %s", got)
+	}
+	if got := ids(internal.RecentRuns(records, "", true, 0)); got != "3,2" {
+		t.Errorf("Expected only failures, got %s", got)
+	}
+}
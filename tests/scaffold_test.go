@@ -0,0 +1,168 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"linea/cmd"
+	"linea/internal"
+)
+
+func TestRenderWorkflowRoundTrip(t *testing.T) {
+	spec := &internal.WorkflowSpec{
+		Description: "Build: the image",
+		Command:     "docker",
+		Subcommand:  "build",
+		Args:        []string{"-t", "myapp:{tag}", "."},
+		Variables: []internal.WorkflowVariable{
+			{Name: "tag", Default: "latest", Description: "Image tag"},
+			{Name: "note", Default: "has: colon"},
+		},
+	}
+
+	content := internal.RenderWorkflow(spec)
+	if !strings.Contains(content, "# Image tag") {
+		t.Errorf("Expected variable description comment, got:\n%s", content)
+	}
+
+	path := filepath.Join(t.TempDir(), "build.yml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	config, err := internal.ParseYAML(path)
+	if err != nil {
+		t.Fatalf("Generated workflow does not parse: %v\n%s", err, content)
+	}
+	if config.Description != "Build: the image" {
+		t.Errorf("Unexpected description %q", config.Description)
+	}
+	if config.Command != "docker" || config.Subcommand != "build" {
+		t.Errorf("Unexpected command %q %q", config.Command, config.Subcommand)
+	}
+	if len(config.Args) != 3 || config.Args[1] != "myapp:{tag}" {
+		t.Errorf("Unexpected args %v", config.Args)
+	}
+	if config.Variables["tag"] != "latest" || config.Variables["note"] != "has: colon" {
+		t.Errorf("Unexpected variables %v", config.Variables)
+	}
+}
+
+func TestInitPresetsAreValid(t *testing.T) {
+	presets := internal.InitPresets()
+	if len(presets) == 0 {
+		t.Fatal("Expected built-in presets")
+	}
+	for _, preset := range presets {
+		if preset.Description == "" {
+			t.Errorf("%s: expected a description", preset.Name)
+		}
+		path := filepath.Join(t.TempDir(), preset.Name+".yml")
+		os.WriteFile(path, preset.Content, 0644)
+		problems, err := internal.ValidateWorkflowDefinition(path)
+		if err != nil || len(problems) > 0 {
+			t.Errorf("%s is not valid: %v %v", preset.Name, problems, err)
+		}
+	}
+
+	if preset, err := internal.FindInitPreset("docker-build"); err != nil || !strings.Contains(string(preset.Content), "docker") {
+		t.Errorf("Expected the docker-build preset, got %v", err)
+	}
+	if _, err := internal.FindInitPreset("nope"); err == nil || !strings.Contains(err.Error(), "docker-build") {
+		t.Errorf("Expected an unknown preset error listing the presets, got %v", err)
+	}
+}
+
+func TestSplitCommandLine(t *testing.T) {
+	cases := map[string][]string{
+		`docker build -t myapp:{tag} .`:      {"docker", "build", "-t", "myapp:{tag}", "."},
+		`git commit -m 'it''s "done"'`:       {"git", "commit", "-m", `its "done"`},
+		`echo "a \"b\" \$x" 'c\d'`:           {"echo", `a "b" \$x`, `c\d`},
+		`C:\tools\app.exe my\ file ""`:       {`C:\tools\app.exe`, "my file", ""},
+		"  kubectl\tget   pods  ":            {"kubectl", "get", "pods"},
+		`sh -c 'echo a | grep a && echo ok'`: {"sh", "-c", "echo a | grep a && echo ok"},
+	}
+	for line, want := range cases {
+		got, err := internal.SplitCommandLine(line)
+		if err != nil {
+			t.Errorf("SplitCommandLine(%s) failed: %v", line, err)
+			continue
+		}
+		if strings.Join(got, "|") != strings.Join(want, "|") || len(got) != len(want) {
+			t.Errorf("SplitCommandLine(%s): expected %q, got %q", line, want, got)
+		}
+	}
+
+	for _, line := range []string{`echo "open`, `echo 'open`, `echo a | grep a`, `make && make install`, `ls > out.txt`} {
+		if _, err := internal.SplitCommandLine(line); err == nil {
+			t.Errorf("SplitCommandLine(%s): expected an error", line)
+		}
+	}
+}
+
+func TestWorkflowSpecFromCommand(t *testing.T) {
+	spec, err := internal.WorkflowSpecFromCommand(`docker build -t myapp:{tag} --label "built={date}" $context`)
+	if err != nil {
+		t.Fatalf("WorkflowSpecFromCommand failed: %v", err)
+	}
+	if spec.Command != "docker" || spec.Subcommand != "build" || len(spec.Args) != 5 {
+		t.Fatalf("Expected docker build with 5 args, got %+v", spec)
+	}
+	var names []string
+	for _, v := range spec.Variables {
+		names = append(names, v.Name)
+	}
+	if strings.Join(names, ",") != "context,tag" {
+		t.Errorf("Expected tag and context variables without the built-in date, got %v", names)
+	}
+
+	// The rendered workflow is valid and runs the same command
+	path := filepath.Join(t.TempDir(), "build.yml")
+	os.WriteFile(path, []byte(internal.RenderWorkflow(spec)), 0644)
+	problems, err := internal.ValidateWorkflowDefinition(path)
+	if err != nil || len(problems) > 0 {
+		t.Errorf("Expected a valid workflow, got %v %v", problems, err)
+	}
+
+	// Only a plain word after a known tool is a subcommand
+	for line, subcommand := range map[string]string{
+		"go test ./...":             "test",
+		"git -C repo status":        "",
+		"echo hello":                "",
+		`/usr/bin/kubectl get pods`: "get",
+		"npm {script}":              "",
+	} {
+		spec, err := internal.WorkflowSpecFromCommand(line)
+		if err != nil || spec.Subcommand != subcommand {
+			t.Errorf("%s: expected subcommand %q, got %+v, %v", line, subcommand, spec, err)
+		}
+	}
+
+	if _, err := internal.WorkflowSpecFromCommand("   "); err == nil {
+		t.Errorf("Expected an empty command line to be refused")
+	}
+}
+
+func TestInitInteractiveInputEnds(t *testing.T) {
+	dir := t.TempDir()
+	for _, input := range []string{"", "\n\n"} {
+		path := filepath.Join(dir, "empty.yml")
+		err := cmd.InitInteractiveCommand(path, strings.NewReader(input))
+		if err == nil || err.Error() != "input ended before a command was given" {
+			t.Errorf("%q: expected the wizard to stop at the end of the input, got %v", input, err)
+		}
+		if _, err := os.Stat(path); err == nil {
+			t.Errorf("%q: expected no workflow to be written", input)
+		}
+	}
+
+	path := filepath.Join(dir, "echo.yml")
+	if err := cmd.InitInteractiveCommand(path, strings.NewReader("echo")); err != nil {
+		t.Fatalf("Expected a command without a final newline to be used, got %v", err)
+	}
+	if config, err := internal.ParseYAML(path); err != nil || config.Command != "echo" {
+		t.Errorf("Expected a workflow running echo, got %+v %v", config, err)
+	}
+}
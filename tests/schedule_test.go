@@ -0,0 +1,146 @@
+package tests
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"linea/internal"
+)
+
+func TestParseCronMatches(t *testing.T) {
+	tests := []struct {
+		expr  string
+		when  time.Time
+		match bool
+	}{
+		{"*/15 9-17 * * mon-fri", time.Date(2026, 3, 2, 9, 45, 0, 0, time.UTC), true},  // Monday
+		{"*/15 9-17 * * mon-fri", time.Date(2026, 3, 2, 9, 46, 0, 0, time.UTC), false}, // not a multiple of 15
+		{"*/15 9-17 * * mon-fri", time.Date(2026, 3, 7, 10, 0, 0, 0, time.UTC), false}, // Saturday
+		{"0 0 * * 7", time.Date(2026, 3, 8, 0, 0, 0, 0, time.UTC), true},               // 7 is Sunday
+		{"0 12 1 jan,jul *", time.Date(2026, 7, 1, 12, 0, 0, 0, time.UTC), true},
+		{"@daily", time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), true},
+		{"@daily", time.Date(2026, 3, 2, 0, 1, 0, 0, time.UTC), false},
+		// Both day fields restricted: either one matches
+		{"0 0 13 * fri", time.Date(2026, 3, 6, 0, 0, 0, 0, time.UTC), true},  // Friday the 6th
+		{"0 0 13 * fri", time.Date(2026, 3, 13, 0, 0, 0, 0, time.UTC), true}, // the 13th
+		{"0 0 13 * fri", time.Date(2026, 3, 12, 0, 0, 0, 0, time.UTC), false},
+	}
+	for _, tt := range tests {
+		schedule, err := internal.ParseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseCron(%q) failed: %v", tt.expr, err)
+		}
+		if got := schedule.Matches(tt.when); got != tt.match {
+			t.Errorf("%q Matches(%s) = %v, expected %v", tt.expr, tt.when.Format(time.RFC1123), got, tt.match)
+		}
+	}
+}
+
+func TestParseCronInvalid(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "0 0 0 * *", "*/0 * * * *", "5-1 * * * *", "0 0 * foo *"} {
+		if _, err := internal.ParseCron(expr); err == nil {
+			t.Errorf("Expected error for cron expression %q", expr)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	tests := []struct {
+		expr     string
+		from     time.Time
+		expected time.Time
+	}{
+		{"0 2 * * *", time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC), time.Date(2026, 3, 3, 2, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 3, 2, 10, 0, 30, 0, time.UTC), time.Date(2026, 3, 2, 10, 15, 0, 0, time.UTC)},
+		{"30 9 * * mon", time.Date(2026, 3, 6, 12, 0, 0, 0, time.UTC), time.Date(2026, 3, 9, 9, 30, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		schedule, err := internal.ParseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseCron(%q) failed: %v", tt.expr, err)
+		}
+		if got := schedule.Next(tt.from); !got.Equal(tt.expected) {
+			t.Errorf("%q Next(%s) = %s, expected %s", tt.expr, tt.from, got, tt.expected)
+		}
+	}
+
+	never, _ := internal.ParseCron("0 0 30 2 *")
+	if got := never.Next(time.Now()); !got.IsZero() {
+		t.Errorf("Expected zero time for a schedule that never fires, got %s", got)
+	}
+}
+
+func TestAddAndRemoveSchedule(t *testing.T) {
+	t.Setenv("LINEA_HOME", t.TempDir())
+	t.Setenv("LINEA_STATE_DIR", "")
+
+	dir := t.TempDir()
+	scheduled := writeWorkflow(t, dir, "backup.yml", "command: echo\nargs: [backup]\nschedule: \"0 2 * * *\"\ntimezone: UTC\n")
+	plain := writeWorkflow(t, dir, "plain.yml", "command: echo\n")
+
+	entry, err := internal.AddSchedule(scheduled, "", map[string]string{"env": "prod"})
+	if err != nil {
+		t.Fatalf("AddSchedule failed: %v", err)
+	}
+	if entry.ID != "backup" || entry.Cron != "" || entry.Workflow != scheduled {
+		t.Errorf("Unexpected entry %+v", entry)
+	}
+
+	if _, err := internal.AddSchedule(plain, "", nil); err == nil || !strings.Contains(err.Error(), "--cron") {
+		t.Errorf("Expected error suggesting --cron for a workflow without schedule:, got %v", err)
+	}
+	if _, err := internal.AddSchedule(plain, "not a cron", nil); err == nil {
+		t.Error("Expected error for an invalid cron expression")
+	}
+	second, err := internal.AddSchedule(scheduled, "@hourly", nil)
+	if err != nil || second.ID != "backup-2" {
+		t.Fatalf("Expected second schedule backup-2, got %+v (%v)", second, err)
+	}
+
+	entries, err := internal.LoadSchedules()
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("Expected 2 schedules, got %d (%v)", len(entries), err)
+	}
+	if entries[0].Variables["env"] != "prod" {
+		t.Errorf("Expected variables to be stored, got %v", entries[0].Variables)
+	}
+
+	schedule, location, err := entries[0].Resolve()
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if location.String() != "UTC" || !schedule.Matches(time.Date(2026, 3, 2, 2, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the workflow's schedule in UTC, got %s", location)
+	}
+
+	if _, err := internal.RemoveSchedule("backup"); err != nil {
+		t.Fatalf("RemoveSchedule failed: %v", err)
+	}
+	if _, err := internal.RemoveSchedule("backup"); err == nil {
+		t.Error("Expected error removing a missing schedule")
+	}
+	entries, _ = internal.LoadSchedules()
+	if len(entries) != 1 || entries[0].ID != "backup-2" {
+		t.Errorf("Expected only backup-2 to remain, got %+v", entries)
+	}
+}
+
+func TestRunLogsDir(t *testing.T) {
+	t.Setenv("LINEA_HOME", t.TempDir())
+	t.Setenv("LINEA_STATE_DIR", "")
+
+	project := t.TempDir()
+	workflowsDir := filepath.Join(project, ".linea", "workflows")
+	path := writeWorkflow(t, workflowsDir, "backup.yml", "command: echo\n")
+	if got := internal.RunLogsDir(path); got != filepath.Join(project, ".linea", "logs") {
+		t.Errorf("Expected project log directory, got %s", got)
+	}
+
+	outside := writeWorkflow(t, t.TempDir(), "backup.yml", "command: echo\n")
+	if got := internal.RunLogsDir(outside); got != filepath.Join(internal.UserPaths().State, "logs") {
+		t.Errorf("Expected state log directory, got %s", got)
+	}
+}
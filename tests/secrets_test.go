@@ -0,0 +1,76 @@
+package tests
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+func TestResolveSecretFromEnv(t *testing.T) {
+	t.Setenv("LINEA_TEST_TOKEN", "s3cr3t-env")
+
+	value, err := internal.ResolveSecret("token", internal.SecretRef{From: "env", Key: "LINEA_TEST_TOKEN"})
+	if err != nil {
+		t.Fatalf("ResolveSecret failed: %v", err)
+	}
+	if value != "s3cr3t-env" {
+		t.Errorf("Expected 's3cr3t-env', got '%s'", value)
+	}
+
+	if _, err := internal.ResolveSecret("missing", internal.SecretRef{From: "env", Key: "LINEA_TEST_MISSING"}); err == nil {
+		t.Error("Expected error for unset environment variable")
+	}
+}
+
+func TestSecretStoreRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".linea", "secrets.enc")
+	t.Setenv("LINEA_SECRETS_KEY", "passphrase")
+	t.Setenv("LINEA_SECRETS_FILE", path)
+
+	if err := internal.SaveSecretStore(path, map[string]string{"db_password": "hunter2"}); err != nil {
+		t.Fatalf("SaveSecretStore failed: %v", err)
+	}
+
+	value, err := internal.ResolveSecret("db_password", internal.SecretRef{})
+	if err != nil {
+		t.Fatalf("ResolveSecret failed: %v", err)
+	}
+	if value != "hunter2" {
+		t.Errorf("Expected 'hunter2', got '%s'", value)
+	}
+
+	t.Setenv("LINEA_SECRETS_KEY", "wrong")
+	if _, err := internal.LoadSecretStore(path); err == nil {
+		t.Error("Expected error when decrypting with the wrong passphrase")
+	}
+}
+
+func TestBuildCommandMasksSecrets(t *testing.T) {
+	t.Setenv("LINEA_TEST_API_KEY", "abc123xyz")
+
+	config := &internal.CommandConfig{
+		Command: "curl",
+		Args:    []string{"-H", "Authorization: {api_key}"},
+		Secrets: map[string]internal.SecretRef{
+			"api_key": {From: "env", Key: "LINEA_TEST_API_KEY"},
+		},
+	}
+
+	cmd, err := internal.BuildCommand(config, nil)
+	if err != nil {
+		t.Fatalf("BuildCommand failed: %v", err)
+	}
+	if cmd[2] != "Authorization: abc123xyz" {
+		t.Errorf("Expected secret to be substituted in argv, got '%s'", cmd[2])
+	}
+
+	formatted := internal.FormatCommand(cmd)
+	if strings.Contains(formatted, "abc123xyz") {
+		t.Errorf("Expected secret to be masked in formatted command, got '%s'", formatted)
+	}
+	if !strings.Contains(formatted, internal.SecretMask) {
+		t.Errorf("Expected mask in formatted command, got '%s'", formatted)
+	}
+}
@@ -0,0 +1,218 @@
+package tests
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"linea/internal"
+)
+
+// fakeLineaRun stands in for `linea run --output json --progress-fd 3`: it logs its
+// arguments, sends a step event, prints a report, and fails when env=bad is set
+const fakeLineaRun = `#!/bin/sh
+echo "running $*" >&2
+echo '{"event":"step_start","step":1,"steps":1,"name":"build"}' >&3
+status=success code=0
+case "$*" in *env=bad*) status=failed code=3 ;; esac
+echo '{"event":"step_end","step":1,"steps":1,"name":"build","status":"'$status'","exit_code":'$code'}' >&3
+echo "done" >&2
+echo '{"workflow":"build","status":"'$status'","exit_code":'$code',"run_id":"r1","steps":[{"index":1,"name":"build","status":"'$status'","exit_code":'$code'}]}'
+exit $code
+`
+
+// servedRun is the part of a run of the API the tests check
+type servedRun struct {
+	ID       string
+	Status   string
+	ExitCode *int   `json:"exit_code"`
+	RunID    string `json:"run_id"`
+	Steps    []internal.StepResult
+}
+
+// newTestServer serves a directory with a build workflow through fakeLineaRun
+func newTestServer(t *testing.T, token string) (*internal.Server, *httptest.Server) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "build.yml"), []byte("# Builds the app\nversion: 2\ncommand: make\n"), 0644)
+	lineaPath := filepath.Join(t.TempDir(), "linea")
+	os.WriteFile(lineaPath, []byte(fakeLineaRun), 0755)
+
+	server := &internal.Server{LineaPath: lineaPath, Dirs: []string{dir}, Token: token}
+	httpServer := httptest.NewServer(server.Handler())
+	t.Cleanup(func() {
+		httpServer.Close()
+		server.Wait()
+	})
+	return server, httpServer
+}
+
+// apiRequest sends a request to the API and decodes its JSON response into v
+func apiRequest(t *testing.T, method, url, token, body string, v interface{}) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest(method, url, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, url, err)
+	}
+	defer resp.Body.Close()
+	if v != nil {
+		json.NewDecoder(resp.Body).Decode(v)
+	}
+	return resp
+}
+
+func TestServeRunsWorkflows(t *testing.T) {
+	_, httpServer := newTestServer(t, "")
+
+	var workflows []internal.ServedWorkflow
+	apiRequest(t, "GET", httpServer.URL+"/api/workflows", "", "", &workflows)
+	if len(workflows) != 1 || workflows[0].Name != "build" || workflows[0].Description != "Builds the app" {
+		t.Fatalf("Unexpected workflows %+v", workflows)
+	}
+
+	var run map[string]interface{}
+	resp := apiRequest(t, "POST", httpServer.URL+"/api/runs", "", `{"workflow": "build", "variables": {"env": "prod"}}`, &run)
+	if resp.StatusCode != http.StatusAccepted || resp.Header.Get("Location") != "/api/runs/"+run["id"].(string) {
+		t.Fatalf("Expected the run to be accepted, got %d %v", resp.StatusCode, run)
+	}
+
+	// The events of the run end with the finished run, whenever they are followed
+	resp, err := http.Get(httpServer.URL + resp.Header.Get("Location") + "/events")
+	if err != nil {
+		t.Fatalf("Failed to follow the run: %v", err)
+	}
+	defer resp.Body.Close()
+	var events []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if line := scanner.Text(); strings.HasPrefix(line, "event: ") || strings.HasPrefix(line, "data: running") {
+			events = append(events, line)
+		}
+	}
+	if len(events) == 0 || events[len(events)-1] != "event: end" {
+		t.Errorf("Expected the events to end with the run, got %v", events)
+	}
+	if !strings.Contains(strings.Join(events, "\n"), "build.yml --output json -s env=prod") {
+		t.Errorf("Expected the log to show the variables passed to linea run, got %v", events)
+	}
+
+	var finished servedRun
+	apiRequest(t, "GET", httpServer.URL+"/api/runs/"+run["id"].(string), "", "", &finished)
+	if finished.Status != internal.StepSucceeded || finished.ExitCode == nil || *finished.ExitCode != 0 || finished.RunID != "r1" {
+		t.Errorf("Unexpected finished run %+v", finished)
+	}
+	if len(finished.Steps) != 1 || finished.Steps[0].Name != "build" {
+		t.Errorf("Expected the steps of the report, got %+v", finished.Steps)
+	}
+}
+
+func TestServeFailedRunAndErrors(t *testing.T) {
+	server, httpServer := newTestServer(t, "secret")
+
+	if resp := apiRequest(t, "GET", httpServer.URL+"/api/workflows", "", "", nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected a request without the token to be refused, got %d", resp.StatusCode)
+	}
+	if resp := apiRequest(t, "GET", httpServer.URL+"/healthz", "", "", nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected /healthz without a token, got %d", resp.StatusCode)
+	}
+	for body, want := range map[string]int{
+		`{"workflow": "../build"}`:   http.StatusBadRequest,
+		`{"workflow": "missing"}`:    http.StatusNotFound,
+		`{"workflow": "build", "x"}`: http.StatusBadRequest,
+	} {
+		if resp := apiRequest(t, "POST", httpServer.URL+"/api/runs", "secret", body, nil); resp.StatusCode != want {
+			t.Errorf("Expected %d for %s, got %d", want, body, resp.StatusCode)
+		}
+	}
+
+	run, err := server.StartRun("build", map[string]string{"env": "bad"})
+	if err != nil {
+		t.Fatalf("StartRun failed: %v", err)
+	}
+	server.Wait()
+	var finished servedRun
+	apiRequest(t, "GET", httpServer.URL+"/api/runs/"+run.ID, "secret", "", &finished)
+	if finished.Status != internal.StepFailed || *finished.ExitCode != 3 || finished.Steps[0].ExitCode != 3 {
+		t.Errorf("Expected a failed run with exit code 3, got %+v", finished)
+	}
+	if log := run.Log(); !strings.HasPrefix(log, "running run ") || !strings.HasSuffix(log, "done\n") {
+		t.Errorf("Unexpected log %q", log)
+	}
+
+	var runs []servedRun
+	apiRequest(t, "GET", httpServer.URL+"/api/runs", "secret", "", &runs)
+	if len(runs) != 1 || runs[0].ID != run.ID {
+		t.Errorf("Expected the run in the list, got %+v", runs)
+	}
+	if resp := apiRequest(t, "POST", httpServer.URL+"/api/runs/"+run.ID+"/cancel", "secret", "", nil); resp.StatusCode != http.StatusConflict {
+		t.Errorf("Expected a finished run not to be cancelled, got %d", resp.StatusCode)
+	}
+	if resp := apiRequest(t, "GET", httpServer.URL+"/api/runs/nope", "secret", "", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected an unknown run to be not found, got %d", resp.StatusCode)
+	}
+}
+
+func TestServeRefusesCrossSiteRequests(t *testing.T) {
+	server, httpServer := newTestServer(t, "")
+	send := func(contentType, origin string) int {
+		req, _ := http.NewRequest("POST", httpServer.URL+"/api/runs", strings.NewReader(`{"workflow": "build"}`))
+		req.Header.Set("Content-Type", contentType)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("POST /api/runs failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := send("text/plain", ""); status != http.StatusUnsupportedMediaType {
+		t.Errorf("Expected a body that is not JSON to be refused, got %d", status)
+	}
+	if status := send("application/json", "https://evil.example"); status != http.StatusForbidden {
+		t.Errorf("Expected a request from another site to be refused, got %d", status)
+	}
+	if len(server.Runs()) != 0 {
+		t.Fatalf("Expected no run to start, got %d", len(server.Runs()))
+	}
+	if status := send("application/json; charset=utf-8", httpServer.URL); status != http.StatusAccepted {
+		t.Errorf("Expected a request from the server's own UI to be accepted, got %d", status)
+	}
+}
+
+func TestServeCancel(t *testing.T) {
+	server, _ := newTestServer(t, "")
+	os.WriteFile(server.LineaPath, []byte("#!/bin/sh\ntrap 'kill $!; echo stopped >&2; exit 130' INT\nsleep 10 & wait\n"), 0755)
+
+	run, err := server.StartRun("build", nil)
+	if err != nil {
+		t.Fatalf("StartRun failed: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if err := run.Cancel(); err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+	server.Wait()
+	if *run.ExitCode != 130 || !strings.Contains(run.Log(), "stopped") {
+		t.Errorf("Expected the run to be interrupted, got exit code %d and log %q", *run.ExitCode, run.Log())
+	}
+}
@@ -0,0 +1,57 @@
+package tests
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+func TestSystemdUnit(t *testing.T) {
+	unit := internal.SystemdUnit("/opt/my tools/linea", false, map[string]string{
+		"LINEA_HOME": "/home/me/linea 100%",
+		"PATH":       "/usr/bin:/bin",
+	})
+	for _, want := range []string{
+		`ExecStart="/opt/my tools/linea" --theme ci schedule start`,
+		`Environment="LINEA_HOME=/home/me/linea 100%%"`,
+		"Environment=PATH=/usr/bin:/bin\n",
+		"Restart=on-failure\n",
+		"WantedBy=default.target\n",
+	} {
+		if !strings.Contains(unit, want) {
+			t.Errorf("Expected the unit to contain %q, got:\n%s", want, unit)
+		}
+	}
+	if system := internal.SystemdUnit("/usr/bin/linea", true, nil); !strings.Contains(system, "WantedBy=multi-user.target") {
+		t.Errorf("Expected a system unit to start in multi-user.target, got:\n%s", system)
+	}
+}
+
+func TestLaunchdPlist(t *testing.T) {
+	plist := internal.LaunchdPlist("/usr/local/bin/linea", "/tmp/a&b.log", map[string]string{"PATH": "/usr/bin"})
+	if err := xml.Unmarshal([]byte(plist), new(interface{})); err != nil {
+		t.Fatalf("Expected valid XML: %v\n%s", err, plist)
+	}
+	for _, want := range []string{"<string>/usr/local/bin/linea</string>", "<string>/tmp/a&amp;b.log</string>", "<key>PATH</key>", "<key>SuccessfulExit</key>"} {
+		if !strings.Contains(plist, want) {
+			t.Errorf("Expected the plist to contain %q, got:\n%s", want, plist)
+		}
+	}
+}
+
+func TestWindowsTaskXML(t *testing.T) {
+	task := internal.WindowsTaskXML(`C:\Program Files\linea\linea.exe`, `C:\Users\me\linea-scheduler.log`, `HOST\me`)
+	for _, want := range []string{
+		`<Command>C:\Program Files\linea\linea.exe</Command>`,
+		`<Arguments>--theme ci schedule start --log-file &#34;C:\Users\me\linea-scheduler.log&#34;</Arguments>`,
+		`<UserId>HOST\me</UserId>`,
+		"<RestartOnFailure>",
+		"<ExecutionTimeLimit>PT0S</ExecutionTimeLimit>",
+	} {
+		if !strings.Contains(task, want) {
+			t.Errorf("Expected the task to contain %q, got:\n%s", want, task)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"linea/cmd"
+	"linea/internal"
+)
+
+// cliMains are the subcommands runCLI can run, by name
+var cliMains = map[string]func([]string){
+	"run":        cmd.RunCommandMain,
+	"sh":         cmd.ShCommandMain,
+	"completion": cmd.CompletionCommandMain,
+	"__complete": cmd.CompleteCommandMain,
+}
+
+// TestCLIHelperProcess runs the subcommand given after -- in a process started by runCLI,
+// as linea would; in a normal test run it does nothing
+func TestCLIHelperProcess(t *testing.T) {
+	if os.Getenv("LINEA_TEST_CLI") != "1" {
+		return
+	}
+	args := flag.Args()
+	cliMains[args[0]](args[1:])
+	os.Exit(0)
+}
+
+// runCLI runs a linea subcommand with its arguments in dir, in a process of its own since
+// subcommands exit on errors, and returns its output and exit code
+func runCLI(t *testing.T, dir string, args ...string) (string, string, int) {
+	t.Helper()
+	c := exec.Command(os.Args[0], append([]string{"-test.run=^TestCLIHelperProcess$", "--"}, args...)...)
+	c.Dir = dir
+	c.Env = append(os.Environ(), "LINEA_TEST_CLI=1")
+	var stdout, stderr bytes.Buffer
+	c.Stdout, c.Stderr = &stdout, &stderr
+	var exitErr *exec.ExitError
+	if err := c.Run(); err != nil && !errors.As(err, &exitErr) {
+		t.Fatalf("Failed to run linea %s: %v", strings.Join(args, " "), err)
+	}
+	return stdout.String(), stderr.String(), c.ProcessState.ExitCode()
+}
+
+func TestShRunsScripts(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	t.Setenv("LINEA_HOME", t.TempDir())
+	dir := t.TempDir()
+	writeWorkflow(t, filepath.Join(dir, ".linea", "workflows"), "greet.yml", "command: echo\nargs: [\"hello $who\"]\n")
+	writeWorkflow(t, filepath.Join(dir, "scripts"), "hello.lnsh", `who=$1
+echo "start $who"
+for n in 1 2
+    echo "step $n"
+end
+greet -s who=$who
+`)
+	// Workflows called by the script run through this test binary too
+	linea := filepath.Join(dir, "linea")
+	if err := os.WriteFile(linea, []byte("#!/bin/sh\nLINEA_TEST_CLI=1 exec \""+os.Args[0]+"\" -test.run='^TestCLIHelperProcess$' -- \"$@\"\n"), 0755); err != nil {
+		t.Fatalf("Failed to write linea: %v", err)
+	}
+	t.Setenv(internal.LineaBinEnv, linea)
+
+	stdout, stderr, code := runCLI(t, dir, "sh", "scripts/hello.lnsh", "linea")
+	if code != 0 || stdout != "start linea\nstep 1\nstep 2\nhello linea\n" {
+		t.Errorf("Expected the script's output, got %d %q %q", code, stdout, stderr)
+	}
+}
+
+func TestShUsage(t *testing.T) {
+	dir := t.TempDir()
+	if _, stderr, code := runCLI(t, dir, "sh"); code != 1 || !strings.Contains(stderr, "no script file specified") || !strings.Contains(stderr, "linea sh <script.lnsh> [args...]") {
+		t.Errorf("Expected the usage of linea sh, got %d %q", code, stderr)
+	}
+	if _, stderr, code := runCLI(t, dir, "sh", "missing.lnsh"); code != 1 || !strings.Contains(stderr, "script file not found") {
+		t.Errorf("Expected a missing script to fail, got %d %q", code, stderr)
+	}
+}
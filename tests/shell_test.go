@@ -0,0 +1,66 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+func TestWindowsShellCommand(t *testing.T) {
+	cmd := []string{"echo", "a & b", `say "hi"`}
+
+	got := internal.WindowsShellCommand(cmd, internal.ShellCmd)
+	want := []string{"cmd.exe", "/c", "echo", "a & b", `say "hi"`}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	got = internal.WindowsShellCommand(cmd, internal.ShellPwsh)
+	if len(got) != 4 || got[0] != "pwsh" || got[2] != "-Command" {
+		t.Fatalf("Expected pwsh -NoProfile -Command <script>, got %v", got)
+	}
+	if !strings.HasPrefix(got[3], `echo 'a & b' 'say "hi"'; `) || !strings.Contains(got[3], "exit $LASTEXITCODE") {
+		t.Errorf("Expected the quoted command followed by its exit code, got %s", got[3])
+	}
+}
+
+func TestStepShell(t *testing.T) {
+	t.Setenv("LINEA_HOME", t.TempDir())
+	defer internal.SetShell("")
+
+	if shell := internal.StepShell(&internal.CommandConfig{Command: "dir"}); shell != internal.ShellCmd {
+		t.Errorf("Expected cmd by default, got %s", shell)
+	}
+
+	if err := internal.SetShell(internal.ShellPowerShell); err != nil {
+		t.Fatalf("SetShell failed: %v", err)
+	}
+	if shell := internal.StepShell(nil); shell != internal.ShellPowerShell {
+		t.Errorf("Expected --shell to apply, got %s", shell)
+	}
+	if shell := internal.StepShell(&internal.CommandConfig{Command: "dir", Shell: internal.ShellCmd}); shell != internal.ShellCmd {
+		t.Errorf("Expected the step's shell: to win over --shell, got %s", shell)
+	}
+
+	if err := internal.SetShell("bash"); err == nil {
+		t.Error("Expected an error for an unknown shell")
+	}
+}
+
+func TestShellFieldValidation(t *testing.T) {
+	dir := t.TempDir()
+	path := writeWorkflow(t, dir, "shell.yml", "command: dir\nshell: bash\n")
+	problems, err := internal.ValidateWorkflowFile(path, nil)
+	if err != nil {
+		t.Fatalf("ValidateWorkflowFile failed: %v", err)
+	}
+	if len(problems) == 0 {
+		t.Error("Expected a problem for shell: bash")
+	}
+
+	path = writeWorkflow(t, dir, "pwsh.yml", "command: Get-ChildItem\nshell: pwsh\n")
+	if problems, _ := internal.ValidateWorkflowFile(path, nil); len(problems) != 0 {
+		t.Errorf("Expected shell: pwsh to be valid, got %v", problems)
+	}
+}
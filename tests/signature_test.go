@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+func TestSignWorkflow(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deploy.yml")
+	os.WriteFile(path, []byte("command: ./deploy.sh\nargs: [prod]\n"), 0644)
+
+	if _, err := internal.VerifyWorkflow(path); !errors.Is(err, internal.ErrUnsigned) {
+		t.Errorf("Expected an unsigned file to be rejected, got %v", err)
+	}
+
+	t.Setenv(internal.SigningKeyEnv, "")
+	signature, err := internal.SignWorkflow(path)
+	if err != nil || len(signature.SHA256) != 64 || signature.HMAC != "" {
+		t.Fatalf("Expected a checksum without a key, got %+v, %v", signature, err)
+	}
+	if signed, err := internal.VerifyWorkflow(path); signed || err != nil {
+		t.Errorf("Expected the checksum to match, got %v, %v", signed, err)
+	}
+	// A checksum is not enough once a signing key is expected
+	t.Setenv(internal.SigningKeyEnv, "review-key")
+	if _, err := internal.VerifyWorkflow(path); !errors.Is(err, internal.ErrUnsigned) {
+		t.Errorf("Expected a checksum-only file to need a signature, got %v", err)
+	}
+
+	if signature, err = internal.SignWorkflow(path); err != nil || signature.HMAC == "" {
+		t.Fatalf("Expected an HMAC with a key, got %+v, %v", signature, err)
+	}
+	if signed, err := internal.VerifyWorkflow(path); !signed || err != nil {
+		t.Errorf("Expected the signature to be verified, got %v, %v", signed, err)
+	}
+	// Converted line endings still match
+	os.WriteFile(path, []byte("command: ./deploy.sh\r\nargs: [prod]\r\n"), 0644)
+	if signed, err := internal.VerifyWorkflow(path); !signed || err != nil {
+		t.Errorf("Expected CRLF line endings to match, got %v, %v", signed, err)
+	}
+
+	t.Setenv(internal.SigningKeyEnv, "other-key")
+	if _, err := internal.VerifyWorkflow(path); !errors.Is(err, internal.ErrSignatureMismatch) || !strings.Contains(err.Error(), "not signed with") {
+		t.Errorf("Expected another key to be rejected, got %v", err)
+	}
+	t.Setenv(internal.SigningKeyEnv, "review-key")
+	os.WriteFile(path, []byte("command: ./deploy.sh\nargs: [prod, --skip-checks]\n"), 0644)
+	if _, err := internal.VerifyWorkflow(path); !errors.Is(err, internal.ErrSignatureMismatch) || !strings.Contains(err.Error(), "changed since it was signed") {
+		t.Errorf("Expected a changed file to be rejected, got %v", err)
+	}
+}
+
+func TestRequireSignaturesCoversLoadedFiles(t *testing.T) {
+	t.Setenv(internal.SigningKeyEnv, "")
+	internal.RequireSignatures = true
+	defer func() { internal.RequireSignatures = false }()
+
+	dir := t.TempDir()
+	child := writeWorkflow(t, dir, "child.yml", "command: echo\nargs: [child]\n")
+	main := writeWorkflow(t, dir, "main.yml", "workflow: child.yml\n---\nuse: greet\n")
+	greet := writeWorkflow(t, filepath.Join(dir, ".linea", "templates"), "greet.yml", "steps:\n  - command: echo\n")
+	for _, path := range []string{child, main, greet} {
+		if _, err := internal.SignWorkflow(path); err != nil {
+			t.Fatalf("SignWorkflow failed: %v", err)
+		}
+	}
+	if _, err := internal.ParseMultiYAML(main); err != nil {
+		t.Fatalf("Expected the signed files to parse, got %v", err)
+	}
+
+	os.WriteFile(child, []byte("command: echo\nargs: [tampered]\n"), 0644)
+	if _, err := internal.ParseMultiYAML(main); !errors.Is(err, internal.ErrSignatureMismatch) || !strings.Contains(err.Error(), "workflow child.yml") {
+		t.Errorf("Expected the changed called workflow to be refused, got %v", err)
+	}
+	internal.SignWorkflow(child)
+
+	os.WriteFile(greet, []byte("steps:\n  - command: rm\n"), 0644)
+	if _, err := internal.ParseMultiYAML(main); !errors.Is(err, internal.ErrSignatureMismatch) || !strings.Contains(err.Error(), "use greet") {
+		t.Errorf("Expected the changed template to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"linea/internal"
+)
+
+func TestRunHistoryRoundTrip(t *testing.T) {
+	t.Setenv("LINEA_HOME", t.TempDir())
+	t.Setenv("LINEA_STATE_DIR", "")
+
+	start := time.Now().Add(-2 * time.Second)
+	if err := internal.AppendRunRecord(internal.NewRunRecord("deploy.yml", map[string]string{"env": "prod"}, start, nil)); err != nil {
+		t.Fatalf("AppendRunRecord failed: %v", err)
+	}
+	if err := internal.AppendRunRecord(internal.NewRunRecord("deploy.yml", nil, start, errors.New("boom"))); err != nil {
+		t.Fatalf("AppendRunRecord failed: %v", err)
+	}
+
+	records, err := internal.LoadRunHistory()
+	if err != nil {
+		t.Fatalf("LoadRunHistory failed: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(records))
+	}
+	if records[0].Workflow != "deploy" || !records[0].Success || records[0].Duration() < 2*time.Second {
+		t.Errorf("Unexpected first record: %+v", records[0])
+	}
+	if records[1].Success || records[1].ExitCode != 1 || records[1].Error != "boom" {
+		t.Errorf("Unexpected second record: %+v", records[1])
+	}
+}
+
+func TestComputeRunStats(t *testing.T) {
+	monday := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	records := []internal.RunRecord{
+		{Workflow: "build", Start: monday, DurationMs: 1000, Success: true},
+		{Workflow: "build", Start: monday.AddDate(0, 0, 1), DurationMs: 3000, Success: false},
+		{Workflow: "build", Start: monday.AddDate(0, 0, 7), DurationMs: 2000, Success: true},
+		{Workflow: "deploy", Start: monday.AddDate(0, 0, 8), DurationMs: 500, Success: false},
+	}
+
+	stats := internal.ComputeRunStats(records, time.Time{})
+	if stats.Runs != 4 || len(stats.Workflows) != 2 {
+		t.Fatalf("Unexpected stats: %+v", stats)
+	}
+
+	build := stats.Workflows[0]
+	if build.Workflow != "build" || build.Runs != 3 || build.Failures != 1 {
+		t.Errorf("Expected most-run workflow 'build' with 1 failure, got %+v", build)
+	}
+	if build.AvgDurationMs != 2000 || build.MaxDurationMs != 3000 {
+		t.Errorf("Unexpected durations: %+v", build)
+	}
+
+	if len(stats.Weekly) != 2 || stats.Weekly[0].Period != "2026-W10" || stats.Weekly[1].FailureRate != 0.5 {
+		t.Errorf("Unexpected weekly stats: %+v", stats.Weekly)
+	}
+
+	recent := internal.ComputeRunStats(records, monday.AddDate(0, 0, 7))
+	if recent.Runs != 2 || recent.Since == nil {
+		t.Errorf("Expected only 2 recent runs, got %+v", recent)
+	}
+}
+
+func TestFindRunRecord(t *testing.T) {
+	t.Setenv("LINEA_HOME", t.TempDir())
+	t.Setenv("LINEA_STATE_DIR", "")
+
+	if _, err := internal.FindRunRecord("last"); err == nil {
+		t.Error("Expected error with empty history")
+	}
+
+	first := internal.NewRunRecord("build.yml", map[string]string{"target": "linux"}, time.Now(), nil)
+	second := internal.NewRunRecord("deploy.yml", map[string]string{"env": "prod"}, time.Now(), nil)
+	for _, record := range []internal.RunRecord{first, second} {
+		if err := internal.AppendRunRecord(record); err != nil {
+			t.Fatalf("AppendRunRecord failed: %v", err)
+		}
+	}
+
+	last, err := internal.FindRunRecord("last")
+	if err != nil || last.ID != second.ID || last.Variables["env"] != "prod" {
+		t.Errorf("Expected last run %s, got %+v (%v)", second.ID, last, err)
+	}
+
+	found, err := internal.FindRunRecord(first.ID)
+	if err != nil || found.Workflow != "build" || found.Variables["target"] != "linux" {
+		t.Errorf("Expected run %s, got %+v (%v)", first.ID, found, err)
+	}
+
+	if _, err := internal.FindRunRecord("19990101"); err == nil {
+		t.Error("Expected error for unknown run ID")
+	}
+}
@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"bytes"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+func TestStdinFromTextFileAndStep(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses cat")
+	}
+	dir := t.TempDir()
+	writeWorkflow(t, dir, "input.txt", "from a file\n")
+	path := writeWorkflow(t, dir, "stdin.yml", `name: render
+command: echo
+args: ["rendered {who}"]
+variables:
+  who: world
+capture: true
+---
+command: cat
+stdin: "hello {who}\n"
+variables:
+  who: text
+---
+command: cat
+stdin:
+  file: input.txt
+---
+command: cat
+stdin:
+  step: render
+`)
+
+	configs, err := internal.ParseMultiYAML(path)
+	if err != nil {
+		t.Fatalf("ParseMultiYAML failed: %v", err)
+	}
+	var out bytes.Buffer
+	if err := internal.ExecuteMultipleCommandsWithOutput(configs, nil, false, false, &out, &out); err != nil {
+		t.Fatalf("ExecuteMultipleCommandsWithOutput failed: %v", err)
+	}
+	want := "rendered world\nhello text\nfrom a file\nrendered world\n"
+	if out.String() != want {
+		t.Errorf("Expected %q, got %q", want, out.String())
+	}
+}
+
+func TestOpenStepInputErrors(t *testing.T) {
+	config := &internal.CommandConfig{Command: "cat", Stdin: &internal.StdinSpec{Step: "never-ran"}}
+	if _, err := internal.OpenStepInput(config, nil); err == nil || !strings.Contains(err.Error(), "never-ran") {
+		t.Errorf("Expected an error for a step that has not run, got %v", err)
+	}
+
+	config.Stdin = &internal.StdinSpec{File: filepath.Join(t.TempDir(), "missing.sql")}
+	if _, err := internal.OpenStepInput(config, nil); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+
+	config.Stdin = &internal.StdinSpec{Text: "a", File: "b"}
+	if _, err := internal.OpenStepInput(config, nil); err == nil {
+		t.Error("Expected an error for both text and file")
+	}
+
+	if reader, err := internal.OpenStepInput(&internal.CommandConfig{Command: "cat"}, nil); reader != nil || err != nil {
+		t.Errorf("Expected no input without stdin:, got %v, %v", reader, err)
+	}
+}
+
+func TestValidateStdinStep(t *testing.T) {
+	path := writeWorkflow(t, t.TempDir(), "stdin.yml", `command: kubectl
+args: [apply, -f, "-"]
+stdin:
+  step: render
+---
+name: render
+command: helm
+capture: true
+`)
+
+	problems, err := internal.ValidateWorkflowDefinition(path)
+	if err != nil {
+		t.Fatalf("ValidateWorkflowDefinition failed: %v", err)
+	}
+	if len(problems) != 1 || !strings.Contains(problems[0].Message, "earlier step with capture: true") {
+		t.Errorf("Expected one stdin problem, got %v", problems)
+	}
+}
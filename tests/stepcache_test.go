@@ -0,0 +1,178 @@
+package tests
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"linea/cmd"
+	"linea/internal"
+)
+
+// cachedWorkflow writes a workflow with a lock file and sources in a temporary directory
+// and returns the file and its steps: install counts its runs and is cached on the lock
+// file and the sources, gen captures its output with a key of {tier}, and use reads it
+func cachedWorkflow(t *testing.T) (string, []*internal.CommandConfig) {
+	t.Helper()
+	t.Setenv(internal.CacheDirEnv, t.TempDir())
+	dir := t.TempDir()
+	path := writeWorkflow(t, dir, "build.yml", "command: echo\n")
+	writeWorkflow(t, dir, "deps.lock", "v1")
+	writeWorkflow(t, filepath.Join(dir, "src", "pkg"), "main.go", "package main")
+	return path, []*internal.CommandConfig{
+		{Name: "install", Command: "sh", Args: []string{"-c", "echo run >> " + filepath.Join(dir, "installed")}, Cache: &internal.StepCacheSpec{Paths: []string{"deps.lock", "src/**/*.go"}}, SourceFile: path},
+		{Name: "gen", Command: "sh", Args: []string{"-c", "echo gen >> " + filepath.Join(dir, "generated") + "; echo token-{env}"}, Variables: map[string]string{"env": "prod", "tier": "1"}, Capture: true, Cache: &internal.StepCacheSpec{Key: "tier-{tier}"}, SourceFile: path},
+		{Name: "use", Command: "sh", Args: []string{"-c", "cat > " + filepath.Join(dir, "used")}, Stdin: &internal.StdinSpec{Step: "gen"}, SourceFile: path},
+	}
+}
+
+// countLines returns the number of lines of a file
+func countLines(t *testing.T, file string) int {
+	t.Helper()
+	data, _ := os.ReadFile(file)
+	return strings.Count(string(data), "\n")
+}
+
+func TestStepCache(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	path, configs := cachedWorkflow(t)
+	dir := filepath.Dir(path)
+	var vars map[string]string
+	run := func() []string {
+		t.Helper()
+		var stdout bytes.Buffer
+		results, err := internal.RunStepsReport(configs, vars, internal.ReportOptions{Stdout: &stdout, Stderr: io.Discard})
+		if err != nil {
+			t.Fatalf("RunStepsReport failed: %v", err)
+		}
+		var statuses []string
+		for _, result := range results {
+			statuses = append(statuses, result.Status)
+		}
+		return statuses
+	}
+
+	if statuses := strings.Join(run(), ","); statuses != "success,success,success" {
+		t.Fatalf("Expected every step to run the first time, got %s", statuses)
+	}
+	if statuses := strings.Join(run(), ","); statuses != "skipped,skipped,success" {
+		t.Errorf("Expected the cached steps to be skipped, got %s", statuses)
+	}
+	if countLines(t, filepath.Join(dir, "installed")) != 1 || countLines(t, filepath.Join(dir, "generated")) != 1 {
+		t.Errorf("Expected the cached steps to run once")
+	}
+	if used, _ := os.ReadFile(filepath.Join(dir, "used")); string(used) != "token-prod\n" {
+		t.Errorf("Expected use to read the cached output of gen, got %q", used)
+	}
+
+	// A changed input file or key runs the step again
+	os.WriteFile(filepath.Join(dir, "src", "pkg", "main.go"), []byte("package main // changed"), 0644)
+	configs[1].Variables["tier"] = "2"
+	if statuses := strings.Join(run(), ","); statuses != "success,success,success" {
+		t.Errorf("Expected the steps with changed inputs to run, got %s", statuses)
+	}
+	configs[1].Variables["env"] = "dev"
+	if statuses := strings.Join(run(), ","); statuses != "skipped,success,success" {
+		t.Errorf("Expected a changed command line to run the step, got %s", statuses)
+	}
+	if used, _ := os.ReadFile(filepath.Join(dir, "used")); string(used) != "token-dev\n" {
+		t.Errorf("Expected use to read the new output of gen, got %q", used)
+	}
+
+	internal.NoCache = true
+	statuses := strings.Join(run(), ",")
+	internal.NoCache = false
+	if statuses != "success,success,success" {
+		t.Errorf("Expected --no-cache to run every step, got %s", statuses)
+	}
+
+	if cleared, err := internal.ClearStepCache([]string{"other"}); err != nil || cleared != 0 {
+		t.Errorf("Expected no step of another workflow to be cleared, got %d %v", cleared, err)
+	}
+	if n := internal.CountStepCache(); n != 2 {
+		t.Errorf("Expected 2 cached steps, got %d", n)
+	}
+	if cleared, err := internal.ClearStepCache([]string{"build"}); err != nil || cleared != 2 {
+		t.Errorf("Expected the 2 cached steps of build to be cleared, got %d %v", cleared, err)
+	}
+	if run()[0] != internal.StepSucceeded {
+		t.Errorf("Expected install to run after the cache was cleared")
+	}
+
+	// The steps run by ExecuteMultipleCommands are cached the same way
+	var stdout bytes.Buffer
+	if err := internal.ExecuteMultipleCommandsWithOutput(configs, vars, false, false, &stdout, io.Discard); err != nil {
+		t.Fatalf("ExecuteMultipleCommandsWithOutput failed: %v", err)
+	}
+	if !strings.Contains(stdout.String(), "Cached: install (inputs unchanged since") || countLines(t, filepath.Join(dir, "installed")) != 4 || countLines(t, filepath.Join(dir, "generated")) != 5 {
+		t.Errorf("Expected install to be cached, got %q", stdout.String())
+	}
+}
+
+func TestStepCacheSingleStep(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	t.Setenv("LINEA_HOME", t.TempDir())
+	dir := t.TempDir()
+	path := writeWorkflow(t, dir, "gen.yml", "command: sh\nargs: [\"-c\", \"echo run >> {out}\"]\ncache: v1\nvariables:\n  out: \""+filepath.ToSlash(filepath.Join(dir, "ran"))+"\"\n")
+
+	// The only step of a workflow is cached without the summary too
+	for _, summary := range []string{internal.SummaryNone, internal.SummaryNone, internal.SummaryText} {
+		if err := cmd.RunCommand(path, nil, cmd.RunOptions{Summary: summary}); err != nil {
+			t.Fatalf("RunCommand failed: %v", err)
+		}
+	}
+	if runs := countLines(t, filepath.Join(dir, "ran")); runs != 1 {
+		t.Errorf("Expected the step to run once, ran %d times", runs)
+	}
+}
+
+func TestStepCacheInputs(t *testing.T) {
+	path, configs := cachedWorkflow(t)
+	install := configs[0]
+	install.Cache.Paths = []string{"deps.lok"}
+	if _, _, err := internal.LookupStepCache(install, 1, []string{"sh"}, nil); err == nil || !strings.Contains(err.Error(), "cache: no file matches 'deps.lok'") {
+		t.Errorf("Expected a path matching no file to be an error, got %v", err)
+	}
+
+	// A directory stands for every file in it
+	install.Cache.Paths = []string{"src"}
+	cache, _, err := internal.LookupStepCache(install, 1, []string{"sh"}, nil)
+	if err != nil {
+		t.Fatalf("LookupStepCache failed: %v", err)
+	}
+	os.WriteFile(filepath.Join(filepath.Dir(path), "src", "pkg", "extra.go"), nil, 0644)
+	changed, _, _ := internal.LookupStepCache(install, 1, []string{"sh"}, nil)
+	if changed.Hash == cache.Hash {
+		t.Errorf("Expected a new file in the directory to change the hash")
+	}
+	if cache, _, _ := internal.LookupStepCache(&internal.CommandConfig{Command: "sh"}, 1, nil, nil); cache != nil {
+		t.Errorf("Expected no cache for a step without cache:, got %+v", cache)
+	}
+}
+
+func TestCheckStepCache(t *testing.T) {
+	for _, tc := range []struct {
+		config internal.CommandConfig
+		want   string
+	}{
+		{internal.CommandConfig{Cache: &internal.StepCacheSpec{}}, "cache needs a key or paths"},
+		{internal.CommandConfig{Background: true, Cache: &internal.StepCacheSpec{Key: "x"}}, "background steps cannot be cached"},
+		{internal.CommandConfig{After: true, Cache: &internal.StepCacheSpec{Key: "x"}}, "cleanup steps cannot be cached"},
+		{internal.CommandConfig{Cache: &internal.StepCacheSpec{Paths: []string{"src/[a"}}}, "invalid path 'src/[a'"},
+	} {
+		if err := internal.CheckStepCache(&tc.config); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Expected an error containing %q, got %v", tc.want, err)
+		}
+	}
+	if err := internal.CheckStepCache(&internal.CommandConfig{Cache: &internal.StepCacheSpec{Key: "deps-{env}", Paths: []string{"**/*.go"}}}); err != nil {
+		t.Errorf("Expected a valid cache, got %v", err)
+	}
+}
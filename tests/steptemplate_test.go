@@ -0,0 +1,104 @@
+package tests
+
+import (
+	"bytes"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+const templateWorkflow = `name: images
+templates:
+  image:
+    params:
+      - tag
+      - name: repo
+        default: app
+    steps:
+      - name: build
+        command: echo
+        args: ["{repo}:{tag}"]
+        capture: true
+    exports: [build]
+---
+use: image
+variables:
+  tag: "1.2"
+with:
+  tag: "{tag}"
+---
+name: api
+use: image
+with:
+  tag: "${tag}"
+  repo: api
+---
+command: cat
+stdin:
+  step: api/build
+`
+
+func TestStepTemplate(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses cat")
+	}
+	path := writeWorkflow(t, t.TempDir(), "images.yml", templateWorkflow)
+	configs, err := internal.ParseMultiYAML(path)
+	if err != nil {
+		t.Fatalf("ParseMultiYAML failed: %v", err)
+	}
+	if len(configs) != 3 {
+		t.Fatalf("Expected 3 steps, got %d", len(configs))
+	}
+	if configs[0].Group != "image" || configs[1].Group != "api" || configs[1].UsedBy == nil || !configs[1].Exported {
+		t.Errorf("Unexpected template steps: %+v, %+v", configs[0], configs[1])
+	}
+
+	var out bytes.Buffer
+	if err := internal.ExecuteMultipleCommandsWithOutput(configs, map[string]string{"tag": "9"}, false, false, &out, &out); err != nil {
+		t.Fatalf("ExecuteMultipleCommandsWithOutput failed: %v", err)
+	}
+	if want := "app:1.2\napi:9\napi:9\n"; out.String() != want {
+		t.Errorf("Expected %q, got %q", want, out.String())
+	}
+
+	if problems, err := internal.ValidateWorkflowFile(path, map[string]string{"tag": "9"}); err != nil || len(problems) != 0 {
+		t.Errorf("Expected no problems, got %v %v", problems, err)
+	}
+}
+
+func TestStepTemplateFile(t *testing.T) {
+	dir := t.TempDir()
+	writeWorkflow(t, filepath.Join(dir, ".linea", "templates"), "greet.yml", "params: [who]\nsteps:\n  - command: echo\n    args: [\"hello {who}\"]\n")
+	path := writeWorkflow(t, filepath.Join(dir, "ops"), "greet.yml", "use: greet\nwith:\n  who: world\n")
+	configs, err := internal.ParseMultiYAML(path)
+	if err != nil {
+		t.Fatalf("ParseMultiYAML failed: %v", err)
+	}
+	cmd, err := internal.BuildCommand(configs[0], map[string]string{"who": "ignored"})
+	if err != nil || strings.Join(cmd, " ") != "echo hello world" {
+		t.Errorf("Expected the parameter from with:, got %v %v", cmd, err)
+	}
+}
+
+func TestStepTemplateErrors(t *testing.T) {
+	dir := t.TempDir()
+	for _, tc := range []struct{ content, want string }{
+		{"use: missing\n", "use missing: unknown template"},
+		{"use: image\n", "use image: missing parameter 'tag' under with:"},
+		{"use: image\nwith:\n  tag: a\n  size: b\n", "use image: unknown parameter 'size'"},
+		{"use: image\nwith:\n  tag: \"{nope}\"\n", "undefined variable {nope}"},
+		{"use: image\ncommand: echo\nwith:\n  tag: a\n", "a use: step cannot have command"},
+		{"command: echo\nwith:\n  tag: a\n", "with requires use:"},
+	} {
+		content := "templates:\n  image:\n    params: [tag]\n    steps:\n      - command: echo\n        args: [\"{tag}\"]\n---\n" + tc.content
+		path := writeWorkflow(t, dir, "use.yml", content)
+		problems, err := internal.ValidateWorkflowFile(path, nil)
+		if err != nil || len(problems) != 1 || !strings.Contains(problems[0].Message, tc.want) {
+			t.Errorf("%q: expected a problem with %q, got %v %v", tc.content, tc.want, problems, err)
+		}
+	}
+}
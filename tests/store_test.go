@@ -0,0 +1,136 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"linea/internal"
+	"linea/internal/store"
+)
+
+func TestStoreUpdateConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counter")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := store.Update(path, 0644, func(data []byte) ([]byte, error) {
+				n, _ := strconv.Atoi(string(data))
+				return []byte(strconv.Itoa(n + 1)), nil
+			})
+			if err != nil {
+				t.Errorf("Update failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "20" {
+		t.Errorf("Expected 20 increments, got %q (%v)", data, err)
+	}
+	if _, err := os.Stat(path + store.LockSuffix); !os.IsNotExist(err) {
+		t.Error("Expected the lock file to be removed")
+	}
+}
+
+func TestStoreWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+	if err := store.WriteFile(path, []byte("one"), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := store.WriteFile(path, []byte("two"), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if string(data) != "two" {
+		t.Errorf("Expected the file to be replaced, got %q", data)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("Expected no temporary files to be left behind, got %d entries", len(entries))
+	}
+}
+
+func TestStoreLockTimeoutAndStaleLocks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	timeout := store.LockTimeout
+	store.LockTimeout = 50 * time.Millisecond
+	defer func() { store.LockTimeout = timeout }()
+
+	lock, err := store.Lock(path)
+	if err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+	if _, err := store.Lock(path); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Expected a held lock to time out, got %v", err)
+	}
+	lock.Unlock()
+
+	// A lock left behind by a crashed process is broken once it is old enough
+	if err := os.WriteFile(path+store.LockSuffix, []byte("12345\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * store.StaleLockAge)
+	os.Chtimes(path+store.LockSuffix, old, old)
+	lock, err = store.Lock(path)
+	if err != nil {
+		t.Fatalf("Expected a stale lock to be broken, got %v", err)
+	}
+	lock.Unlock()
+}
+
+func TestAppendRunRecordConcurrent(t *testing.T) {
+	t.Setenv("LINEA_HOME", t.TempDir())
+	t.Setenv("LINEA_STATE_DIR", "")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			record := internal.RunRecord{ID: strconv.Itoa(i), Workflow: strings.Repeat("x", 4096)}
+			if err := internal.AppendRunRecord(record); err != nil {
+				t.Errorf("AppendRunRecord failed: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	records, err := internal.LoadRunHistory()
+	if err != nil || len(records) != 20 {
+		t.Errorf("Expected 20 intact records, got %d (%v)", len(records), err)
+	}
+}
+
+func TestAddScheduleConcurrent(t *testing.T) {
+	t.Setenv("LINEA_HOME", t.TempDir())
+	t.Setenv("LINEA_STATE_DIR", "")
+	path := writeWorkflow(t, t.TempDir(), "backup.yml", "command: echo\nschedule: \"@daily\"\n")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := internal.AddSchedule(path, "", nil); err != nil {
+				t.Errorf("AddSchedule failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	entries, err := internal.LoadSchedules()
+	if err != nil || len(entries) != 10 {
+		t.Errorf("Expected 10 schedules, got %d (%v)", len(entries), err)
+	}
+}
@@ -0,0 +1,70 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+// strictParse parses content as a workflow file with StrictParse set
+func strictParse(t *testing.T, name, content string) ([]*internal.CommandConfig, error) {
+	t.Helper()
+	internal.StrictParse = true
+	defer func() { internal.StrictParse = false }()
+	path := filepath.Join(t.TempDir(), name)
+	os.WriteFile(path, []byte(content), 0644)
+	return internal.ParseMultiYAML(path)
+}
+
+func TestStrictParseUnknownKeys(t *testing.T) {
+	typo := `name: build
+command: echo
+---
+name: deploy
+commannd: ./deploy.sh
+variabels:
+  env: prod
+`
+	// Without strict mode the document with the typo is skipped
+	if configs := parseWorkflowFile(t, "deploy.yml", typo); len(configs) != 1 {
+		t.Fatalf("Expected the misspelled step to be skipped, got %d steps", len(configs))
+	}
+
+	_, err := strictParse(t, "deploy.yml", typo)
+	if err == nil {
+		t.Fatalf("Expected strict mode to refuse unknown keys")
+	}
+	for _, want := range []string{
+		"line 5: unknown key 'commannd' (did you mean 'command'?)",
+		"line 6: unknown key 'variabels' (did you mean 'variables'?)",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Error does not contain %q: %v", want, err)
+		}
+	}
+
+	// Nested keys are checked too, and a .json file has no line numbers
+	_, err = strictParse(t, "deploy.json", `{"command": "echo", "limits": {"memory": "1G", "cpu": "30s"}}`)
+	if err == nil || !strings.Contains(err.Error(), "strict mode: unknown key 'cpu'") {
+		t.Errorf("Expected an unknown key under limits:, got %v", err)
+	}
+}
+
+func TestStrictParseAcceptsValidWorkflows(t *testing.T) {
+	configs, err := strictParse(t, "release.yml", anchorsWorkflow)
+	if err != nil || len(configs) != 3 {
+		t.Fatalf("Expected the workflow to parse in strict mode, got %d steps and %v", len(configs), err)
+	}
+
+	// Older versions are checked after their upgrades
+	if _, err := strictParse(t, "old.yml", "command: echo\nargs: [hi]\n"); err != nil {
+		t.Errorf("Expected a version 1 workflow to parse in strict mode, got %v", err)
+	}
+
+	if _, err := strictParse(t, "empty.yml", "version: 2\ncommand: echo\n---\nname: nothing\n"); err == nil || !strings.Contains(err.Error(), "document has no command, type, group, workflow, or use") {
+		t.Errorf("Expected a document without a command to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+const structuredWorkflow = `command: echo
+args: ["{hosts[0]}", "{hosts|join:,}", "{db.port}", "{db.replicas[1]|upper}", "${targets[1]}", "$targets"]
+variables:
+  hosts: [web1, web2]
+  db:
+    port: 5432
+    replicas: [r1, r2]
+  targets: ["a", "b"]
+  version:
+    provider: env
+    default: "1.0"
+`
+
+func TestStructuredVariables(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deploy.yml")
+	os.WriteFile(path, []byte(structuredWorkflow), 0644)
+	configs, err := internal.ParseMultiYAML(path)
+	if err != nil {
+		t.Fatalf("ParseMultiYAML failed: %v", err)
+	}
+	config := configs[0]
+	if config.Variables["hosts"] != `["web1","web2"]` || config.Variables["db"] != `{"port":5432,"replicas":["r1","r2"]}` {
+		t.Errorf("Expected lists and maps as JSON, got %v", config.Variables)
+	}
+	if _, ok := config.Providers["version"]; !ok {
+		t.Errorf("Expected a mapping with provider: to stay a provider, got %v", config.Providers)
+	}
+
+	cmd, err := internal.BuildCommand(config, nil)
+	if err != nil || strings.Join(cmd, " ") != `echo web1 web1,web2 5432 R2 b ["a","b"]` {
+		t.Errorf("Unexpected command %v %v", cmd, err)
+	}
+	cmd, err = internal.BuildCommand(config, map[string]string{"targets": `["x","y"]`})
+	if err != nil || cmd[5] != "y" || cmd[6] != `["x","y"]` {
+		t.Errorf("Expected ${targets[1]} to select into the -s value, got %v %v", cmd, err)
+	}
+
+	if problems, err := internal.ValidateWorkflowFile(path, nil); err != nil || len(problems) != 0 {
+		t.Errorf("Expected no problems, got %v %v", problems, err)
+	}
+}
+
+func TestStructuredVariableErrors(t *testing.T) {
+	variables := map[string]string{"hosts": `["web1","web2"]`, "db": `{"port":5432}`, "name": "linea"}
+	for _, tc := range []struct {
+		arg  string
+		want string
+	}{
+		{"{hosts[2]}", "{hosts[2]}: index 2 is out of range (the list has 2 items)"},
+		{"{db.host}", "{db.host}: no key 'host' in the map"},
+		{"{name[0]}", "{name[0]}: cannot select '0' from a value that is not a list or a map"},
+		{"{other[0]}", "undefined variables: other"},
+	} {
+		config := &internal.CommandConfig{Command: "echo", Args: []string{tc.arg}, Variables: variables}
+		if _, err := internal.BuildCommand(config, nil); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected an error containing %q, got %v", tc.arg, tc.want, err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "deploy.yml")
+	os.WriteFile(path, []byte(strings.Replace(structuredWorkflow, "{hosts[0]}", "{hosts[3]}", 1)), 0644)
+	problems, _ := internal.ValidateWorkflowFile(path, nil)
+	if len(problems) != 1 || !strings.Contains(problems[0].Message, "index 3 is out of range") {
+		t.Errorf("Expected linea validate to report the index, got %v", problems)
+	}
+}
+
+func TestStructuredValue(t *testing.T) {
+	for _, tc := range []struct{ in, want string }{
+		{`[ "a", "b" ]`, `["a","b"]`},
+		{`{"url": "http://x?a=1&b=<2>"}`, `{"url":"http://x?a=1&b=<2>"}`},
+		{`"text"`, "text"},
+		{`12`, "12"},
+	} {
+		if got, err := internal.StructuredValue(tc.in); err != nil || got != tc.want {
+			t.Errorf("StructuredValue(%s) = %q %v, want %q", tc.in, got, err, tc.want)
+		}
+	}
+	if _, err := internal.StructuredValue(`["a"`); err == nil {
+		t.Errorf("Expected invalid JSON to be an error")
+	}
+
+	if items, ok := internal.ListValue(`["a", 2, {"k": "v"}]`); !ok || strings.Join(items, " ") != `a 2 {"k":"v"}` {
+		t.Errorf("Unexpected items %v %v", items, ok)
+	}
+	if _, ok := internal.ListValue("a,b"); ok {
+		t.Errorf("Expected a plain value not to be a list")
+	}
+}
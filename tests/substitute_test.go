@@ -0,0 +1,54 @@
+package tests
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+func TestSubstituteOverlappingNames(t *testing.T) {
+	vars := map[string]string{"a": "1", "ab": "2", "x": "$ab", "y": "{a}"}
+	got := internal.SubstituteVariables("$a $ab ${a}b {a}{ab} $abc {x} $y", vars)
+	if want := "1 2 1b 12 $abc $ab {a}"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestSubstituteSeparateMaps(t *testing.T) {
+	yamlVars := map[string]string{"v": "one", "hosts": `["a","b"]`}
+	dollarVars := map[string]string{"v": "two", "hosts": `["c"]`}
+	for _, tc := range []struct{ in, want string }{
+		{"{v} $v ${v} ${v|upper} {v|upper}", "one two two TWO ONE"},
+		{"{hosts[1]} ${hosts[0]} {hosts|join:,}", "b c a,b"},
+		{"{nope} $nope ${nope} {nope|upper} {hosts[5]}", "{nope} $nope ${nope} {nope|upper} {hosts[5]}"},
+		{`awk '{print $1}' {"k": "{v}"} {{v}} ${ v }`, `awk '{print $1}' {"k": "one"} {one} ${ v }`},
+		{"$$v {v", "$two {v"},
+	} {
+		if got := internal.SubstituteVariablesWithSeparateMaps(tc.in, yamlVars, dollarVars); got != tc.want {
+			t.Errorf("%s: expected %q, got %q", tc.in, tc.want, got)
+		}
+	}
+}
+
+func TestSubstituteManyVariables(t *testing.T) {
+	vars := make(map[string]string)
+	var arg, want strings.Builder
+	for i := 0; i < 2000; i++ {
+		vars[fmt.Sprintf("v%d", i)] = fmt.Sprintf("<%d>", i)
+		fmt.Fprintf(&arg, "{v%d}$v%d", i, i)
+		fmt.Fprintf(&want, "<%d><%d>", i, i)
+	}
+	if got := internal.SubstituteVariables(arg.String(), vars); got != want.String() {
+		t.Errorf("Unexpected substitution of many variables")
+	}
+}
+
+func TestLineashSubstitution(t *testing.T) {
+	ctx := &internal.LineashContext{Variables: map[string]string{"count": "2", "counter": "5"}, Args: []string{"x"}}
+	got := ctx.SubstituteVariables("$counter $count ${count}s $1 {count} $((count + counter)) $(($count * 3))")
+	if want := "5 2 2s x {count} 7 6"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
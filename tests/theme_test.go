@@ -0,0 +1,99 @@
+package tests
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+func TestRendererThemes(t *testing.T) {
+	tests := []struct {
+		theme    string
+		expected string
+	}{
+		{"classic", "\n  ❌ Error: no YAML file specified\n"},
+		{"minimal", "\n  ✗ Error: no YAML file specified\n"},
+		{"ci", "\n  [linea] ERROR no YAML file specified\n"},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		r := &internal.Renderer{Theme: internal.Themes[tt.theme], Out: &out, Err: &out}
+		r.Eprintf(internal.StatusError, "\n  Error: no YAML file specified\n")
+		if out.String() != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.theme, tt.expected, out.String())
+		}
+	}
+}
+
+func TestThemesDefineEverySymbol(t *testing.T) {
+	statuses := []internal.Status{
+		internal.StatusSuccess, internal.StatusError, internal.StatusWarning, internal.StatusHint,
+		internal.StatusInfo, internal.StatusStart, internal.StatusStop, internal.StatusSkip,
+		internal.StatusRetry, internal.StatusEdit, internal.StatusRemove, internal.StatusNone,
+	}
+	for _, name := range internal.ThemeNames() {
+		for _, status := range statuses {
+			if internal.Themes[name].Symbols[status] == "" {
+				t.Errorf("Theme %s has no symbol for %s", name, status)
+			}
+		}
+	}
+}
+
+func TestThemeConfigSetting(t *testing.T) {
+	config := &internal.UserConfig{}
+	if err := config.Set("theme", "ci"); err != nil || config.Theme != "ci" {
+		t.Errorf("Expected theme to be set, got %q (%v)", config.Theme, err)
+	}
+	if err := config.Set("theme", "fancy"); err == nil || !strings.Contains(err.Error(), "classic") {
+		t.Errorf("Expected an error listing the themes, got %v", err)
+	}
+	if _, err := internal.LookupTheme("fancy"); err == nil {
+		t.Error("Expected unknown theme error")
+	}
+}
+
+func TestRendererQuiet(t *testing.T) {
+	var out bytes.Buffer
+	r := &internal.Renderer{Theme: internal.Themes["ci"], Out: &out, Err: &out, Quiet: true}
+	r.Printf(internal.StatusSuccess, "Formatted deploy.yml\n")
+	r.Printf(internal.StatusInfo, "Next steps\n")
+	r.Eprintf(internal.StatusWarning, "Warning: deprecated field\n")
+	r.Eprintf(internal.StatusError, "Error: broken\n")
+	got := out.String()
+	if strings.Contains(got, "Formatted") || strings.Contains(got, "Next steps") {
+		t.Errorf("Expected quiet mode to drop success and info messages, got %q", got)
+	}
+	if !strings.Contains(got, "deprecated field") || !strings.Contains(got, "broken") {
+		t.Errorf("Expected quiet mode to keep warnings and errors, got %q", got)
+	}
+}
+
+func TestCurrentColorMode(t *testing.T) {
+	t.Setenv("LINEA_HOME", t.TempDir())
+	t.Setenv("LINEA_CONFIG_DIR", "")
+	t.Setenv(internal.NoColorEnv, "")
+	defer internal.SetColorMode("")
+
+	if got := internal.CurrentColorMode(); got != internal.ColorAuto {
+		t.Errorf("Expected auto by default, got %s", got)
+	}
+	config := &internal.UserConfig{}
+	config.Set("color", "always")
+	if err := internal.SaveUserConfig(config); err != nil {
+		t.Fatalf("SaveUserConfig failed: %v", err)
+	}
+	if got := internal.CurrentColorMode(); got != internal.ColorAlways {
+		t.Errorf("Expected the color setting to apply, got %s", got)
+	}
+	t.Setenv(internal.NoColorEnv, "1")
+	if got := internal.CurrentColorMode(); got != internal.ColorNever {
+		t.Errorf("Expected NO_COLOR to override the color setting, got %s", got)
+	}
+	internal.SetColorMode(internal.ColorAlways)
+	if got := internal.CurrentColorMode(); got != internal.ColorAlways {
+		t.Errorf("Expected the command line to override NO_COLOR, got %s", got)
+	}
+}
@@ -0,0 +1,232 @@
+package tests
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"linea/internal"
+)
+
+// otlpExport is the part of an OTLP/HTTP JSON export the tests look at
+type otlpExport struct {
+	ResourceSpans []struct {
+		Resource struct {
+			Attributes []otlpTestAttribute `json:"attributes"`
+		} `json:"resource"`
+		ScopeSpans []struct {
+			Spans []otlpTestSpan `json:"spans"`
+		} `json:"scopeSpans"`
+	} `json:"resourceSpans"`
+}
+
+type otlpTestSpan struct {
+	TraceID      string              `json:"traceId"`
+	SpanID       string              `json:"spanId"`
+	ParentSpanID string              `json:"parentSpanId"`
+	Name         string              `json:"name"`
+	Start        string              `json:"startTimeUnixNano"`
+	End          string              `json:"endTimeUnixNano"`
+	Attributes   []otlpTestAttribute `json:"attributes"`
+	Status       struct {
+		Code int `json:"code"`
+	} `json:"status"`
+}
+
+type otlpTestAttribute struct {
+	Key   string            `json:"key"`
+	Value map[string]string `json:"value"`
+}
+
+// attribute returns the value of an attribute, whatever its type
+func attribute(attributes []otlpTestAttribute, key string) string {
+	for _, a := range attributes {
+		if a.Key == key {
+			for _, v := range a.Value {
+				return v
+			}
+		}
+	}
+	return ""
+}
+
+// newCollector starts an OTLP collector that keeps the exports it receives
+func newCollector(t *testing.T, exports *[]otlpExport, headers *http.Header) *httptest.Server {
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		var export otlpExport
+		if err := json.NewDecoder(r.Body).Decode(&export); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		*exports = append(*exports, export)
+		*headers = r.Header
+		w.Write([]byte("{}"))
+	}))
+	t.Cleanup(collector.Close)
+	return collector
+}
+
+func TestTracerFromEnv(t *testing.T) {
+	for _, name := range []string{
+		internal.OtelSDKDisabledEnv, internal.OtelTracesExporterEnv, internal.OtelEndpointEnv, internal.OtelTracesEndpointEnv,
+		internal.OtelHeadersEnv, internal.OtelTracesHeadersEnv, internal.OtelTimeoutEnv, internal.OtelTracesTimeoutEnv,
+		internal.OtelProtocolEnv, internal.OtelTracesProtocolEnv, internal.OtelServiceNameEnv, internal.OtelResourceAttrsEnv,
+		internal.TraceParentEnv,
+	} {
+		t.Setenv(name, "")
+	}
+	if tracer, err := internal.TracerFromEnv(); tracer != nil || err != nil {
+		t.Errorf("Expected no tracer without an endpoint, got %+v %v", tracer, err)
+	}
+
+	t.Setenv(internal.OtelEndpointEnv, "http://collector:4318/")
+	t.Setenv(internal.OtelHeadersEnv, "x-api-key=abc%3D,tenant = acme")
+	t.Setenv(internal.OtelResourceAttrsEnv, "deployment.environment=prod,service.name=ignored")
+	t.Setenv(internal.OtelServiceNameEnv, "ci-runner")
+	t.Setenv(internal.OtelTimeoutEnv, "2500")
+	tracer, err := internal.TracerFromEnv()
+	if err != nil || tracer == nil {
+		t.Fatalf("TracerFromEnv failed: %v", err)
+	}
+	if tracer.Endpoint != "http://collector:4318/v1/traces" || tracer.Timeout != 2500*time.Millisecond {
+		t.Errorf("Unexpected endpoint or timeout %+v", tracer)
+	}
+	if tracer.Headers["x-api-key"] != "abc=" || tracer.Headers["tenant"] != "acme" {
+		t.Errorf("Unexpected headers %v", tracer.Headers)
+	}
+	if tracer.Resource["service.name"] != "ci-runner" || tracer.Resource["deployment.environment"] != "prod" {
+		t.Errorf("Unexpected resource %v", tracer.Resource)
+	}
+
+	t.Setenv(internal.OtelTracesEndpointEnv, "https://traces.example.com/otlp")
+	if tracer, _ := internal.TracerFromEnv(); tracer.Endpoint != "https://traces.example.com/otlp" {
+		t.Errorf("Expected the traces endpoint to be used as is, got %s", tracer.Endpoint)
+	}
+	t.Setenv(internal.OtelSDKDisabledEnv, "true")
+	if tracer, _ := internal.TracerFromEnv(); tracer != nil {
+		t.Errorf("Expected no tracer with the SDK disabled")
+	}
+	t.Setenv(internal.OtelSDKDisabledEnv, "")
+
+	for _, tc := range []struct{ name, value, want string }{
+		{internal.OtelProtocolEnv, "grpc", "protocol 'grpc' is not supported"},
+		{internal.OtelTracesExporterEnv, "zipkin", "only otlp"},
+		{internal.OtelTimeoutEnv, "10s", "not a number of milliseconds"},
+		{internal.OtelHeadersEnv, "novalue", "'novalue' is not key=value"},
+		{internal.OtelTracesEndpointEnv, "collector:4318", "not an http(s) URL"},
+	} {
+		old := os.Getenv(tc.name)
+		t.Setenv(tc.name, tc.value)
+		if _, err := internal.TracerFromEnv(); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Expected an error containing %q for %s=%s, got %v", tc.want, tc.name, tc.value, err)
+		}
+		t.Setenv(tc.name, old)
+	}
+}
+
+func TestRunTraceExport(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	var exports []otlpExport
+	var headers http.Header
+	collector := newCollector(t, &exports, &headers)
+	tracer := &internal.Tracer{
+		Endpoint: collector.URL + "/v1/traces",
+		Headers:  map[string]string{"X-Api-Key": "abc"},
+		Resource: map[string]string{"service.name": "linea"},
+		Timeout:  5 * time.Second,
+		Parent:   "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	}
+
+	configs := []*internal.CommandConfig{
+		{Name: "build", Command: "sh", Args: []string{"-c", "sleep 0.05"}},
+		{Name: "test", Command: "sh", Args: []string{"-c", "exit 3"}},
+		{Name: "deploy", Command: "echo", Args: []string{"never"}},
+	}
+	path := filepath.Join(t.TempDir(), "release.yml")
+	report := internal.NewRunReport(path, "run", nil, time.Now())
+	trace := tracer.StartRun()
+	steps, err := internal.RunStepsReport(configs, nil, internal.ReportOptions{
+		Stdout:     io.Discard,
+		Stderr:     io.Discard,
+		Workflow:   "release",
+		Middleware: []internal.Middleware{trace.Middleware()},
+	})
+	report.Steps = steps
+	report.Finish(err)
+	if err := trace.End(report); err != nil {
+		t.Fatalf("End failed: %v", err)
+	}
+
+	if len(exports) != 1 || headers.Get("X-Api-Key") != "abc" {
+		t.Fatalf("Expected one export with the headers, got %d %v", len(exports), headers)
+	}
+	resource := exports[0].ResourceSpans[0]
+	if attribute(resource.Resource.Attributes, "service.name") != "linea" {
+		t.Errorf("Expected the service name in the resource, got %+v", resource.Resource)
+	}
+	spans := resource.ScopeSpans[0].Spans
+	if len(spans) != 4 {
+		t.Fatalf("Expected the run and its 3 steps, got %+v", spans)
+	}
+	root := spans[0]
+	if root.Name != "release" || root.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || root.ParentSpanID != "00f067aa0ba902b7" {
+		t.Errorf("Expected the run to continue the traceparent, got %+v", root)
+	}
+	if root.Status.Code != 2 || attribute(root.Attributes, "linea.run.status") != internal.StepFailed || attribute(root.Attributes, "linea.run.exit_code") != "3" {
+		t.Errorf("Expected a failed run span, got %+v", root)
+	}
+
+	build, test, deploy := spans[1], spans[2], spans[3]
+	for _, span := range spans[1:] {
+		if span.TraceID != root.TraceID || span.ParentSpanID != root.SpanID || span.SpanID == root.SpanID {
+			t.Errorf("Expected %s to be a child of the run, got %+v", span.Name, span)
+		}
+	}
+	if build.Name != "build" || attribute(build.Attributes, "linea.step.command") != "sh -c sleep 0.05" || build.Status.Code != 0 {
+		t.Errorf("Unexpected build span %+v", build)
+	}
+	if ms := attribute(build.Attributes, "linea.step.duration_ms"); ms == "" || ms == "0" || build.End <= build.Start {
+		t.Errorf("Expected the build span to last, got %s ms from %s to %s", ms, build.Start, build.End)
+	}
+	if attribute(test.Attributes, "linea.step.exit_code") != "3" || test.Status.Code != 2 {
+		t.Errorf("Expected a failed test span, got %+v", test)
+	}
+	if attribute(deploy.Attributes, "linea.step.status") != internal.StepNotRun {
+		t.Errorf("Expected the deploy step not to run, got %+v", deploy)
+	}
+}
+
+func TestRunTraceNotSampled(t *testing.T) {
+	var exports []otlpExport
+	var headers http.Header
+	collector := newCollector(t, &exports, &headers)
+	tracer := &internal.Tracer{Endpoint: collector.URL + "/v1/traces", Parent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"}
+	report := internal.NewRunReport("build.yml", "run", nil, time.Now())
+	report.Finish(nil)
+	if err := tracer.StartRun().End(report); err != nil || len(exports) != 0 {
+		t.Errorf("Expected a run whose parent is not sampled not to be exported, got %v %d", err, len(exports))
+	}
+
+	// A nil tracer traces nothing, and a collector that refuses the trace is an error
+	var none *internal.Tracer
+	if err := none.StartRun().End(report); err != nil {
+		t.Errorf("Expected a nil tracer to do nothing, got %v", err)
+	}
+	tracer = &internal.Tracer{Endpoint: collector.URL + "/elsewhere", Timeout: time.Second}
+	if err := tracer.StartRun().End(report); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("Expected the refused export to be an error, got %v", err)
+	}
+}
@@ -0,0 +1,203 @@
+package tests
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"linea/internal"
+)
+
+func TestDownloadStepResumesAndVerifies(t *testing.T) {
+	content := bytes.Repeat([]byte("linea "), 1000)
+	sum := sha256.Sum256(content)
+	checksum := hex.EncodeToString(sum[:])
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "tool.tar.gz", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	dest := filepath.Join(t.TempDir(), "tools", "tool.tar.gz")
+	os.MkdirAll(filepath.Dir(dest), 0755)
+	os.WriteFile(dest+".part", content[:1000], 0644)
+	os.WriteFile(dest+".part.if-range", []byte(`"v1"`), 0644)
+	config := &internal.CommandConfig{
+		Type:      internal.StepTypeDownload,
+		Download:  &internal.DownloadSpec{URL: "{base}/tool.tar.gz", Dest: dest, SHA256: checksum, Headers: map[string]string{"Authorization": "Bearer token"}},
+		Variables: map[string]string{"base": server.URL},
+	}
+	output, err := runFileStep(t, config, nil)
+	if err != nil {
+		t.Fatalf("Download failed: %v\n%s", err, output)
+	}
+	if data, _ := os.ReadFile(dest); !bytes.Equal(data, content) {
+		t.Errorf("Expected the resumed download to have the whole content, got %d bytes", len(data))
+	}
+	if len(ranges) != 1 || ranges[0] != "bytes=1000-" || !strings.Contains(output, "resuming") {
+		t.Errorf("Expected the download to resume at 1000 bytes, got ranges %q and %q", ranges, output)
+	}
+	if _, err := os.Stat(dest + ".part"); !os.IsNotExist(err) {
+		t.Errorf("Expected the partial file to be gone")
+	}
+	if _, err := os.Stat(dest + ".part.if-range"); !os.IsNotExist(err) {
+		t.Errorf("Expected the saved ETag to be gone")
+	}
+
+	// A file with the checksum is not downloaded again
+	if output, err := runFileStep(t, config, nil); err != nil || !strings.Contains(output, "up to date") || len(ranges) != 1 {
+		t.Errorf("Expected the download to be skipped, got %v: %s", err, output)
+	}
+
+	config.Download.SHA256 = strings.Repeat("0", 64)
+	if _, err := runFileStep(t, config, nil); internal.ExitCode(err) != 1 || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("Expected a checksum mismatch, got %v", err)
+	}
+	if _, err := os.Stat(dest + ".part"); !os.IsNotExist(err) {
+		t.Errorf("Expected the mismatching download to be removed")
+	}
+
+	config.Download.Headers = nil
+	if _, err := runFileStep(t, config, nil); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Expected the status to fail the step, got %v", err)
+	}
+}
+
+func TestDownloadStepStartsOver(t *testing.T) {
+	content := bytes.Repeat([]byte("v2 "), 1000)
+	wrongRange := false
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		if wrongRange && r.Header.Get("Range") != "" {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes 0-%d/%d", len(content)-1, len(content)))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(content)
+			return
+		}
+		w.Header().Set("ETag", `"v2"`)
+		http.ServeContent(w, r, "tool.tar.gz", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	dest := filepath.Join(t.TempDir(), "tool.tar.gz")
+	config := &internal.CommandConfig{Type: internal.StepTypeDownload, Download: &internal.DownloadSpec{URL: server.URL, Dest: dest}}
+	for _, tc := range []struct {
+		name, ifRange string
+		wrongRange    bool
+		want          []string
+	}{
+		{"the file changed since the part was downloaded", `"v1"`, false, []string{"bytes=1000-"}},
+		{"the ETag of the part is unknown", "", false, []string{""}},
+		{"the server sends another range", `"v2"`, true, []string{"bytes=1000-", ""}},
+	} {
+		ranges, wrongRange = nil, tc.wrongRange
+		os.Remove(dest)
+		os.WriteFile(dest+".part", bytes.Repeat([]byte("v1 "), 1000)[:1000], 0644)
+		if tc.ifRange != "" {
+			os.WriteFile(dest+".part.if-range", []byte(tc.ifRange), 0644)
+		}
+		output, err := runFileStep(t, config, nil)
+		if err != nil {
+			t.Fatalf("%s: download failed: %v\n%s", tc.name, err, output)
+		}
+		if data, _ := os.ReadFile(dest); !bytes.Equal(data, content) || strings.Contains(output, "resuming") {
+			t.Errorf("%s: expected the download to start over, got %d bytes and %q", tc.name, len(data), output)
+		}
+		if strings.Join(ranges, ",") != strings.Join(tc.want, ",") {
+			t.Errorf("%s: expected ranges %q, got %q", tc.name, tc.want, ranges)
+		}
+	}
+}
+
+func TestUploadStepHTTPPut(t *testing.T) {
+	var received []byte
+	var contentType string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.URL.Path != "/releases/app.zip" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received, _ = io.ReadAll(r.Body)
+		contentType = r.Header.Get("Content-Type")
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	src := filepath.Join(t.TempDir(), "app.zip")
+	os.WriteFile(src, []byte("zip data"), 0644)
+	config := &internal.CommandConfig{
+		Type:   internal.StepTypeUpload,
+		Upload: &internal.UploadSpec{Src: src, URL: server.URL + "/releases/app.zip", Headers: map[string]string{"Content-Type": "application/zip"}},
+	}
+	if output, err := runFileStep(t, config, nil); err != nil {
+		t.Fatalf("Upload failed: %v\n%s", err, output)
+	}
+	if string(received) != "zip data" || contentType != "application/zip" {
+		t.Errorf("Expected the file with its content type, got %q (%s)", received, contentType)
+	}
+}
+
+func TestUploadStepS3(t *testing.T) {
+	var path, authorization, payloadHash atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path.Store(r.URL.Path)
+		authorization.Store(r.Header.Get("Authorization"))
+		payloadHash.Store(r.Header.Get("X-Amz-Content-Sha256"))
+		io.Copy(io.Discard, r.Body)
+	}))
+	defer server.Close()
+
+	src := filepath.Join(t.TempDir(), "app-1.0.tar.gz")
+	os.WriteFile(src, []byte("release"), 0644)
+	config := &internal.CommandConfig{
+		Type:   internal.StepTypeUpload,
+		Upload: &internal.UploadSpec{Src: src, S3: "s3://releases/myapp/", Endpoint: server.URL, AccessKey: "AKID", SecretKey: "secret"},
+	}
+	output, err := runFileStep(t, config, nil)
+	if err != nil {
+		t.Fatalf("Upload failed: %v\n%s", err, output)
+	}
+	sum := sha256.Sum256([]byte("release"))
+	if path.Load() != "/releases/myapp/app-1.0.tar.gz" || payloadHash.Load() != hex.EncodeToString(sum[:]) {
+		t.Errorf("Expected the object at the prefix signed with its checksum, got %v and %v", path.Load(), payloadHash.Load())
+	}
+	if !strings.HasPrefix(authorization.Load().(string), "AWS4-HMAC-SHA256 Credential=AKID/") {
+		t.Errorf("Expected a signed request, got %v", authorization.Load())
+	}
+	if !strings.Contains(output, "s3://releases/myapp/app-1.0.tar.gz") {
+		t.Errorf("Unexpected output: %s", output)
+	}
+}
+
+func TestCheckTransferStep(t *testing.T) {
+	for _, config := range []*internal.CommandConfig{
+		{Type: internal.StepTypeDownload, Download: &internal.DownloadSpec{URL: "https://example.com/a"}},
+		{Type: internal.StepTypeDownload, Download: &internal.DownloadSpec{URL: "https://example.com/a", Dest: "a", SHA256: "abc"}},
+		{Type: internal.StepTypeUpload, Upload: &internal.UploadSpec{Src: "a"}},
+		{Type: internal.StepTypeUpload, Upload: &internal.UploadSpec{Src: "a", URL: "https://example.com", S3: "s3://b/k"}},
+		{Type: internal.StepTypeUpload, Upload: &internal.UploadSpec{Src: "a", S3: "bucket/key"}},
+		{Type: internal.StepTypeUpload, Upload: &internal.UploadSpec{Src: "a", URL: "https://example.com", Region: "eu-west-1"}},
+		{Command: "curl", Download: &internal.DownloadSpec{URL: "https://example.com/a", Dest: "a"}},
+	} {
+		if err := internal.CheckTransferStep(config); err == nil {
+			t.Errorf("Expected %+v to be rejected", config)
+		}
+	}
+}
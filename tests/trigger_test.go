@@ -0,0 +1,155 @@
+package tests
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+const pushPayload = `{
+  "ref": "refs/heads/main",
+  "after": "abc123",
+  "repository": {"full_name": "acme/app", "private": false},
+  "commits": [{"id": "c1", "author": {"name": "Ann"}}, {"id": "c2", "author": {"name": "Bo"}}]
+}`
+
+// postHook sends a webhook and returns its response with the decoded body
+func postHook(t *testing.T, url, contentType, body string, headers map[string]string) (*http.Response, map[string]interface{}) {
+	t.Helper()
+	req, _ := http.NewRequest("POST", url, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST %s failed: %v", url, err)
+	}
+	defer resp.Body.Close()
+	var result map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&result)
+	return resp, result
+}
+
+// githubSignature signs a payload like GitHub's X-Hub-Signature-256
+func githubSignature(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestTriggerGitHubPush(t *testing.T) {
+	server, httpServer := newTestServer(t, "token")
+	os.Setenv("TEST_HOOK_SECRET", "s3cret")
+	defer os.Unsetenv("TEST_HOOK_SECRET")
+	server.Triggers = []internal.Trigger{{
+		Name:     "push",
+		Workflow: "build",
+		Type:     internal.TriggerGitHub,
+		Secret:   "${TEST_HOOK_SECRET}",
+		Match:    map[string]string{"$.ref": "refs/heads/main"},
+		Variables: map[string]string{
+			"commit": "$.after",
+			"repo":   "$['repository'].full_name",
+			"last":   "$.commits[-1].author.name",
+			"first":  "$.commits[0]",
+		},
+	}}
+	if err := internal.CheckTriggers(server.Triggers); err != nil {
+		t.Fatalf("CheckTriggers failed: %v", err)
+	}
+	hook := httpServer.URL + "/hooks/push"
+	signed := map[string]string{"X-GitHub-Event": "push", "X-Hub-Signature-256": githubSignature("s3cret", pushPayload)}
+
+	if resp, _ := postHook(t, hook, "application/json", pushPayload, map[string]string{"X-GitHub-Event": "push", "X-Hub-Signature-256": githubSignature("wrong", pushPayload)}); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected a bad signature to be refused, got %d", resp.StatusCode)
+	}
+	if resp, result := postHook(t, hook, "application/json", pushPayload, map[string]string{"X-GitHub-Event": "issues", "X-Hub-Signature-256": signed["X-Hub-Signature-256"]}); resp.StatusCode != http.StatusOK || result["status"] != "ignored" {
+		t.Errorf("Expected another event to be ignored, got %d %v", resp.StatusCode, result)
+	}
+	other := strings.Replace(pushPayload, "heads/main", "heads/dev", 1)
+	if _, result := postHook(t, hook, "application/json", other, map[string]string{"X-GitHub-Event": "push", "X-Hub-Signature-256": githubSignature("s3cret", other)}); result["status"] != "ignored" {
+		t.Errorf("Expected a push to another branch to be ignored, got %v", result)
+	}
+
+	resp, run := postHook(t, hook, "application/json", pushPayload, signed)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("Expected the push to start a run, got %d %v", resp.StatusCode, run)
+	}
+	server.Wait()
+	vars := run["variables"].(map[string]interface{})
+	if vars["commit"] != "abc123" || vars["repo"] != "acme/app" || vars["last"] != "Bo" || vars["first"] != `{"author":{"name":"Ann"},"id":"c1"}` {
+		t.Errorf("Unexpected variables %v", vars)
+	}
+
+	// GitHub webhooks with the form content type send the JSON as payload=
+	form := "payload=" + url.QueryEscape(pushPayload)
+	if resp, _ := postHook(t, hook, "application/x-www-form-urlencoded", form, map[string]string{"X-GitHub-Event": "push", "X-Hub-Signature-256": githubSignature("s3cret", form)}); resp.StatusCode != http.StatusAccepted {
+		t.Errorf("Expected a form-encoded push to start a run, got %d", resp.StatusCode)
+	}
+	if _, result := postHook(t, hook, "application/json", "{}", map[string]string{"X-GitHub-Event": "ping", "X-Hub-Signature-256": githubSignature("s3cret", "{}")}); result["status"] != "pong" {
+		t.Errorf("Expected a ping to be answered, got %v", result)
+	}
+}
+
+func TestTriggerJSON(t *testing.T) {
+	server, httpServer := newTestServer(t, "")
+	server.Triggers = []internal.Trigger{
+		{Name: "deploy", Workflow: "build", Secret: "tok", Variables: map[string]string{"env": "$.env"}},
+		{Name: "open", Workflow: "build"},
+	}
+
+	if resp, _ := postHook(t, httpServer.URL+"/hooks/deploy", "application/json", `{"env": "prod"}`, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected a webhook without the secret to be refused, got %d", resp.StatusCode)
+	}
+	bearer := map[string]string{"Authorization": "Bearer tok"}
+	if resp, result := postHook(t, httpServer.URL+"/hooks/deploy", "application/json", `{"stage": "prod"}`, bearer); resp.StatusCode != http.StatusUnprocessableEntity || !strings.Contains(result["error"].(string), "key 'env' not found") {
+		t.Errorf("Expected a missing variable to be refused, got %d %v", resp.StatusCode, result)
+	}
+	if resp, _ := postHook(t, httpServer.URL+"/hooks/deploy", "application/json", `not json`, bearer); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected a payload that is not JSON to be refused, got %d", resp.StatusCode)
+	}
+	if resp, run := postHook(t, httpServer.URL+"/hooks/deploy", "application/json", `{"env": 3}`, bearer); resp.StatusCode != http.StatusAccepted || run["variables"].(map[string]interface{})["env"] != "3" {
+		t.Errorf("Expected a run with env=3, got %d %v", resp.StatusCode, run)
+	}
+	if resp, _ := postHook(t, httpServer.URL+"/hooks/open", "application/json", `{}`, nil); resp.StatusCode != http.StatusAccepted {
+		t.Errorf("Expected a trigger without a secret to run, got %d", resp.StatusCode)
+	}
+	for _, origin := range []string{"https://evil.example", httpServer.URL} {
+		if resp, _ := postHook(t, httpServer.URL+"/hooks/open", "text/plain", `{"who": "evil"}`, map[string]string{"Origin": origin}); resp.StatusCode != http.StatusForbidden {
+			t.Errorf("Expected a webhook from a browser at %s to be refused, got %d", origin, resp.StatusCode)
+		}
+	}
+	if resp, _ := postHook(t, httpServer.URL+"/hooks/nope", "application/json", `{}`, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected an unknown trigger to be not found, got %d", resp.StatusCode)
+	}
+}
+
+func TestCheckTriggers(t *testing.T) {
+	for _, tc := range []struct {
+		trigger internal.Trigger
+		want    string
+	}{
+		{internal.Trigger{Name: "a/b", Workflow: "build"}, "name must be"},
+		{internal.Trigger{Name: "a"}, "no workflow"},
+		{internal.Trigger{Name: "a", Workflow: "build", Type: "gitlab"}, "unknown type 'gitlab'"},
+		{internal.Trigger{Name: "a", Workflow: "build", Secret: "$TEST_UNSET_SECRET"}, "secret $TEST_UNSET_SECRET is empty"},
+		{internal.Trigger{Name: "a", Workflow: "build", Variables: map[string]string{"x": "ref"}}, "must start with $"},
+		{internal.Trigger{Name: "a", Workflow: "build", Match: map[string]string{"$.commits[*].id": "x"}}, "[*] is not an index"},
+	} {
+		if err := internal.CheckTriggers([]internal.Trigger{tc.trigger}); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Expected an error containing %q for %+v, got %v", tc.want, tc.trigger, err)
+		}
+	}
+	if err := internal.CheckTriggers([]internal.Trigger{{Name: "a", Workflow: "x"}, {Name: "a", Workflow: "y"}}); err == nil {
+		t.Errorf("Expected duplicate trigger names to be refused")
+	}
+}
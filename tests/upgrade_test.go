@@ -0,0 +1,64 @@
+package tests
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+func TestUnversionedWorkflowUpgradedOnLoad(t *testing.T) {
+	path := writeWorkflow(t, t.TempDir(), "legacy.yml", "command: echo\nargs: hello\n")
+
+	configs, err := internal.ParseMultiYAML(path)
+	if err != nil {
+		t.Fatalf("ParseMultiYAML failed: %v", err)
+	}
+	if configs[0].Version != internal.CurrentWorkflowVersion {
+		t.Errorf("Expected version %d after loading, got %d", internal.CurrentWorkflowVersion, configs[0].Version)
+	}
+	if len(configs[0].Args) != 1 || configs[0].Args[0] != "hello" {
+		t.Errorf("Expected scalar args to be upgraded to a list, got %v", configs[0].Args)
+	}
+
+	problems, err := internal.ValidateWorkflowFile(path, nil)
+	if err != nil || len(problems) != 0 {
+		t.Errorf("Expected the legacy file to validate, got %v (%v)", problemMessages(problems), err)
+	}
+}
+
+func TestWorkflowVersionChecks(t *testing.T) {
+	current := writeWorkflow(t, t.TempDir(), "current.yml", "version: 2\ncommand: echo\nargs: hello\n")
+	if _, err := internal.ParseMultiYAML(current); err == nil {
+		t.Error("Expected scalar args to be rejected in the current version")
+	}
+
+	future := writeWorkflow(t, t.TempDir(), "future.yml", "version: 99\ncommand: echo\n")
+	if _, err := internal.ParseMultiYAML(future); err == nil || !strings.Contains(err.Error(), "newer linea") {
+		t.Errorf("Expected error asking for a newer linea, got %v", err)
+	}
+	problems, err := internal.ValidateWorkflowFile(future, nil)
+	if err != nil || !strings.Contains(problemMessages(problems), "newer linea") {
+		t.Errorf("Expected validate to report the unsupported version, got %v (%v)", problemMessages(problems), err)
+	}
+}
+
+func TestFormatWorkflowUpgrade(t *testing.T) {
+	input := "# Legacy workflow\ncommand: echo\nargs: hello\n"
+	formatted, err := internal.FormatWorkflow([]byte(input), true)
+	if err != nil {
+		t.Fatalf("FormatWorkflow failed: %v", err)
+	}
+	expected := "# Legacy workflow\nversion: " + strconv.Itoa(internal.CurrentWorkflowVersion) + "\ncommand: echo\nargs:\n  - hello\n"
+	if string(formatted) != expected {
+		t.Errorf("Unexpected upgrade:\n%s\nexpected:\n%s", formatted, expected)
+	}
+
+	if plain, _ := internal.FormatWorkflow([]byte(input), false); strings.Contains(string(plain), "version") {
+		t.Errorf("Expected fmt without --upgrade to keep the version, got:\n%s", plain)
+	}
+	if again, _ := internal.FormatWorkflow(formatted, true); string(again) != string(formatted) {
+		t.Errorf("Expected upgrading to be idempotent, got:\n%s", again)
+	}
+}
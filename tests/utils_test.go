@@ -175,3 +175,49 @@ func TestSubstituteVariablesDollarSyntax(t *testing.T) {
 	}
 }
 
+
+func TestSubstituteVariablesPipelines(t *testing.T) {
+	variables := map[string]string{
+		"name":   "Linea",
+		"branch": "feature/login",
+		"padded": "  spaced  ",
+		"empty":  "",
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"{name|upper}", "LINEA"},
+		{"{name|lower}", "linea"},
+		{"{padded|trim}", "spaced"},
+		{"{branch|replace:/:-}", "feature-login"},
+		{"{version|default:latest}", "latest"},
+		{"{empty|default:none}", "none"},
+		{"{name|default:other|upper}", "LINEA"},
+		{"app-{branch|replace:/:_|upper}.zip", "app-FEATURE_LOGIN.zip"},
+		{"{name|bogus}", "{name|bogus}"},
+	}
+
+	for _, tt := range tests {
+		result := internal.SubstituteVariables(tt.input, variables)
+		if result != tt.expected {
+			t.Errorf("SubstituteVariables(%q) = %q, want %q", tt.input, result, tt.expected)
+		}
+	}
+}
+
+func TestValidateVariablesPipelines(t *testing.T) {
+	if err := internal.ValidateVariables([]string{"{version|default:latest}"}, map[string]string{}); err != nil {
+		t.Errorf("Expected default to make variable optional, got %v", err)
+	}
+
+	if err := internal.ValidateVariables([]string{"{name|upper}"}, map[string]string{}); err == nil {
+		t.Error("Expected error for undefined variable in pipeline")
+	}
+
+	err := internal.ValidateVariables([]string{"{name|bogus}"}, map[string]string{"name": "x"})
+	if err == nil || !strings.Contains(err.Error(), "bogus") {
+		t.Errorf("Expected unknown function error, got %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package tests
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+func problemMessages(problems []internal.ValidationProblem) string {
+	messages := make([]string, len(problems))
+	for i, p := range problems {
+		messages[i] = p.String()
+	}
+	return strings.Join(messages, "\n")
+}
+
+func TestValidateWorkflowFileValid(t *testing.T) {
+	path := writeWorkflow(t, t.TempDir(), "ok.yml", `name: greet
+description: Say hello
+command: echo
+args:
+  - "Hello {name}"
+  - 42
+variables:
+  name: "World"
+`)
+
+	problems, err := internal.ValidateWorkflowFile(path, nil)
+	if err != nil {
+		t.Fatalf("ValidateWorkflowFile failed: %v", err)
+	}
+	if len(problems) != 0 {
+		t.Errorf("Expected no problems, got:\n%s", problemMessages(problems))
+	}
+}
+
+func TestValidateWorkflowFileProblems(t *testing.T) {
+	path := writeWorkflow(t, t.TempDir(), "bad.yml", `name: build
+commannd: echo
+---
+name: build
+command: echo
+args:
+  - "{missing}"
+  - "$runtime"
+variables:
+  nested:
+    provider: env
+    key: value
+`)
+
+	problems, err := internal.ValidateWorkflowFile(path, nil)
+	if err != nil {
+		t.Fatalf("ValidateWorkflowFile failed: %v", err)
+	}
+	output := problemMessages(problems)
+
+	for _, expected := range []string{
+		"unknown key 'commannd' (did you mean 'command'?)",
+		"missing required key 'command'",
+		"duplicate step name 'build'",
+		"unknown key 'key'",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected problem %q, got:\n%s", expected, output)
+		}
+	}
+}
+
+func TestValidateWorkflowFileUndefinedVariables(t *testing.T) {
+	path := writeWorkflow(t, t.TempDir(), "vars.yml", `command: echo
+args:
+  - "{missing}"
+  - "$runtime"
+`)
+
+	problems, err := internal.ValidateWorkflowFile(path, map[string]string{"runtime": "x"})
+	if err != nil {
+		t.Fatalf("ValidateWorkflowFile failed: %v", err)
+	}
+	output := problemMessages(problems)
+
+	if !strings.Contains(output, "undefined variable {missing}") {
+		t.Errorf("Expected undefined {missing}, got:\n%s", output)
+	}
+	if strings.Contains(output, "runtime") {
+		t.Errorf("Expected $runtime to be satisfied by -s, got:\n%s", output)
+	}
+	if filepath.Base(problems[0].File) != "vars.yml" {
+		t.Errorf("Expected problem to reference the file, got %s", problems[0].File)
+	}
+}
@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+func TestValidateVariablesSyntax(t *testing.T) {
+	vars := map[string]string{"file": "data.txt"}
+	for _, arg := range []string{"{ total += $1 } END { print total }", "$HOME/{file}", "s/(\\w+)/$_/", `{"key": "value"}`, "{a,b}"} {
+		if err := internal.ValidateVariables([]string{arg}, vars); err != nil {
+			t.Errorf("%s: expected no undefined variables, got %v", arg, err)
+		}
+	}
+	for _, arg := range []string{"{missing}", "${missing}", "{missing|upper}"} {
+		if err := internal.ValidateVariables([]string{arg}, vars); err == nil || !strings.Contains(err.Error(), "undefined variables: missing") {
+			t.Errorf("%s: expected missing to be undefined, got %v", arg, err)
+		}
+	}
+
+	if err := internal.ValidateVariablesWithSyntax([]string{"$HOME/{file}"}, vars, internal.VariableSyntaxBare); err == nil || !strings.Contains(err.Error(), "undefined variables: HOME") {
+		t.Errorf("Expected the bare syntax to check $HOME, got %v", err)
+	}
+	if err := internal.ValidateVariablesWithSyntax([]string{"$1"}, vars, internal.VariableSyntaxBare); err != nil {
+		t.Errorf("Expected $1 not to be a variable, got %v", err)
+	}
+}
+
+func TestDollarBraceSyntax(t *testing.T) {
+	config := &internal.CommandConfig{Command: "awk", Args: []string{"{print $1, \"${tag}\", \"{tag}\", \"$tag\", $other}"}, Variables: map[string]string{"tag": "1.0"}}
+	cmd, err := internal.BuildCommand(config, map[string]string{"tag": "2.0"})
+	if err != nil || cmd[1] != `{print $1, "2.0", "1.0", "2.0", $other}` {
+		t.Errorf("Unexpected command %v %v", cmd, err)
+	}
+
+	config.VariableSyntax = internal.VariableSyntaxBare
+	if _, err := internal.BuildCommand(config, nil); err == nil || !strings.Contains(err.Error(), "undefined variables: other") {
+		t.Errorf("Expected the bare syntax to check $other, got %v", err)
+	}
+}
+
+func TestValidateVariableSyntaxField(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sum.yml")
+	os.WriteFile(path, []byte(`name: sum
+command: awk
+args: ["{ total += $1 } END { print total }", "{file}"]
+variables:
+  file: data.txt
+---
+name: push
+command: docker
+args: ["push", "app:$tag"]
+variable_syntax: bare
+`), 0644)
+	problems, err := internal.ValidateWorkflowFile(path, nil)
+	if err != nil || len(problems) != 1 || !strings.Contains(problems[0].Message, "undefined variable $tag") {
+		t.Errorf("Expected only the bare $tag to be reported, got %v %v", problems, err)
+	}
+
+	if err := internal.CheckVariableSyntax(&internal.CommandConfig{VariableSyntax: "dollar"}); err == nil || !strings.Contains(err.Error(), "variable_syntax must be braces or bare") {
+		t.Errorf("Expected an unknown syntax to be an error, got %v", err)
+	}
+	// A typo fails the step instead of falling back to braces
+	if _, err := internal.BuildCommand(&internal.CommandConfig{Command: "echo", VariableSyntax: "bogus"}, nil); err == nil || !strings.Contains(err.Error(), "not 'bogus'") {
+		t.Errorf("Expected the step not to build, got %v", err)
+	}
+}
@@ -0,0 +1,43 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+func TestWriteVerifyApp(t *testing.T) {
+	dir := t.TempDir()
+	if err := internal.WriteVerifyApp(dir); err != nil {
+		t.Fatalf("WriteVerifyApp failed: %v", err)
+	}
+
+	workflow := filepath.Join(dir, ".linea", "workflows", internal.VerifyWorkflowName+".yml")
+	problems, err := internal.ValidateWorkflowFile(workflow, map[string]string{"token": "abc123"})
+	if err != nil || len(problems) > 0 {
+		t.Errorf("Expected the verification workflow to be valid, got %v %s", err, problemMessages(problems))
+	}
+
+	configs, err := internal.ParseMultiYAML(workflow)
+	if err != nil {
+		t.Fatalf("ParseMultiYAML failed: %v", err)
+	}
+	cmd, err := internal.BuildCommand(configs[0], map[string]string{"token": "abc123"})
+	if err != nil {
+		t.Fatalf("BuildCommand failed: %v", err)
+	}
+	if got := strings.Join(cmd, " "); got != "echo workflow abc123" {
+		t.Errorf("Expected the token to be passed with -s, got %q", got)
+	}
+
+	script, err := os.ReadFile(filepath.Join(dir, "scripts", "verify.lnsh"))
+	if err != nil {
+		t.Fatalf("Expected a lineash script: %v", err)
+	}
+	if !strings.Contains(string(script), internal.VerifyWorkflowName+" -s token=") {
+		t.Errorf("Expected the script to call the workflow as a command, got:\n%s", script)
+	}
+}
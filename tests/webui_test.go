@@ -0,0 +1,99 @@
+package tests
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"linea/internal"
+)
+
+func TestServeWebUI(t *testing.T) {
+	server := &internal.Server{Token: "secret"}
+	withoutUI := httptest.NewServer(server.Handler())
+	defer withoutUI.Close()
+	if resp := getPage(t, withoutUI.URL+"/"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected no web UI without UI set, got %d", resp.StatusCode)
+	}
+
+	server.UI = true
+	httpServer := httptest.NewServer(server.Handler())
+	defer httpServer.Close()
+	resp := getPage(t, httpServer.URL+"/")
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") || !strings.Contains(string(body), "/api/workflows") {
+		t.Errorf("Expected the page without the token, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	if resp := getPage(t, httpServer.URL+"/index.php"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected other paths to be not found, got %d", resp.StatusCode)
+	}
+}
+
+func TestServeWorkflowDefaults(t *testing.T) {
+	server, httpServer := newTestServer(t, "")
+	os.WriteFile(filepath.Join(server.Dirs[0], "deploy.yml"), []byte("version: 2\ncommand: echo\nargs: [\"$env\", \"{region}\", \"$tag\"]\nvariables:\n  env: staging\n  region: eu-west-1\n"), 0644)
+
+	var workflows []internal.ServedWorkflow
+	apiRequest(t, "GET", httpServer.URL+"/api/workflows", "", "", &workflows)
+	if len(workflows) != 2 || workflows[1].Name != "deploy" {
+		t.Fatalf("Unexpected workflows %+v", workflows)
+	}
+	deploy := workflows[1]
+	if deploy.Defaults["env"] != "staging" || deploy.Defaults["region"] != "eu-west-1" || strings.Join(deploy.Required, ",") != "tag" {
+		t.Errorf("Expected the defaults and required variables of the form, got %+v", deploy)
+	}
+}
+
+func TestServeHistory(t *testing.T) {
+	t.Setenv("LINEA_HOME", t.TempDir())
+	_, httpServer := newTestServer(t, "secret")
+
+	logFile := filepath.Join(t.TempDir(), "build.log")
+	os.WriteFile(logFile, []byte("building\n"), 0644)
+	start := time.Now().Add(-time.Minute)
+	internal.AppendRunRecord(internal.RunRecord{ID: "20260101T000000-aaaa", Workflow: "build", Start: start, Success: true, LogFile: logFile})
+	internal.AppendRunRecord(internal.RunRecord{ID: "20260101T000100-bbbb", Workflow: "test", Start: start, ExitCode: 2})
+
+	var records []internal.RunRecord
+	apiRequest(t, "GET", httpServer.URL+"/api/history", "secret", "", &records)
+	if len(records) != 2 || records[0].Workflow != "test" {
+		t.Errorf("Expected the runs newest first, got %+v", records)
+	}
+	apiRequest(t, "GET", httpServer.URL+"/api/history?failed=true&limit=5", "secret", "", &records)
+	if len(records) != 1 || records[0].ExitCode != 2 {
+		t.Errorf("Expected only the failed run, got %+v", records)
+	}
+	if resp := apiRequest(t, "GET", httpServer.URL+"/api/history?limit=x", "secret", "", nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected an invalid limit to be refused, got %d", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest("GET", httpServer.URL+"/api/history/20260101T000000/log", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET log failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if body, _ := io.ReadAll(resp.Body); string(body) != "building\n" {
+		t.Errorf("Expected the log of the run found by its ID prefix, got %d %q", resp.StatusCode, body)
+	}
+	if resp := apiRequest(t, "GET", httpServer.URL+"/api/history/20260101T000100-bbbb/log", "secret", "", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected a run without a log to be not found, got %d", resp.StatusCode)
+	}
+}
+
+// getPage fetches a URL without a token
+func getPage(t *testing.T, url string) *http.Response {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", url, err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
@@ -0,0 +1,96 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"linea/internal"
+)
+
+func TestTimeWindowAllows(t *testing.T) {
+	config := &internal.CommandConfig{
+		Command:      "deploy",
+		AllowedHours: "09:00-17:00",
+		AllowedDays:  internal.StringList{"mon-fri"},
+		Timezone:     "UTC",
+	}
+	window, err := internal.ParseTimeWindow(config)
+	if err != nil {
+		t.Fatalf("ParseTimeWindow failed: %v", err)
+	}
+
+	tests := []struct {
+		when  time.Time
+		allow bool
+	}{
+		{time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC), true},  // Monday
+		{time.Date(2026, 3, 2, 17, 0, 0, 0, time.UTC), false}, // end is exclusive
+		{time.Date(2026, 3, 2, 8, 59, 0, 0, time.UTC), false},
+		{time.Date(2026, 3, 7, 10, 0, 0, 0, time.UTC), false}, // Saturday
+	}
+	for _, tt := range tests {
+		if got := window.Allows(tt.when); got != tt.allow {
+			t.Errorf("Allows(%s) = %v, expected %v", tt.when.Format(time.RFC1123), got, tt.allow)
+		}
+	}
+}
+
+func TestTimeWindowOvernightAndTimezone(t *testing.T) {
+	config := &internal.CommandConfig{
+		Command:      "backup",
+		AllowedHours: "22:00-02:00",
+		AllowedDays:  internal.StringList{"fri-mon"},
+		Timezone:     "Asia/Tokyo",
+	}
+	window, err := internal.ParseTimeWindow(config)
+	if err != nil {
+		t.Skipf("timezone data not available: %v", err)
+	}
+
+	// 14:30 UTC on a Saturday is 23:30 in Tokyo
+	if !window.Allows(time.Date(2026, 3, 7, 14, 30, 0, 0, time.UTC)) {
+		t.Error("Expected 23:30 Tokyo time on Saturday to be allowed")
+	}
+	// 03:00 UTC on a Wednesday is 12:00 in Tokyo
+	if window.Allows(time.Date(2026, 3, 4, 3, 0, 0, 0, time.UTC)) {
+		t.Error("Expected noon on Wednesday to be blocked")
+	}
+}
+
+func TestCheckTimeWindows(t *testing.T) {
+	saturday := time.Date(2026, 3, 7, 10, 0, 0, 0, time.UTC)
+	configs := []*internal.CommandConfig{
+		{Command: "echo"},
+		{Name: "migrate", Command: "migrate", AllowedDays: internal.StringList{"mon", "tue,wed"}, Timezone: "UTC"},
+	}
+
+	outside, err := internal.CheckTimeWindows(configs, saturday)
+	if err != nil {
+		t.Fatalf("CheckTimeWindows failed: %v", err)
+	}
+	if len(outside) != 1 || !strings.Contains(outside[0].Error(), "step 'migrate'") {
+		t.Errorf("Expected the migrate step to be blocked, got %v", outside)
+	}
+
+	invalid := []*internal.CommandConfig{{Command: "echo", AllowedHours: "9am-5pm"}}
+	if _, err := internal.CheckTimeWindows(invalid, saturday); err == nil {
+		t.Error("Expected error for invalid allowed_hours")
+	}
+}
+
+func TestParseAllowedDaysScalar(t *testing.T) {
+	path := writeWorkflow(t, t.TempDir(), "window.yml", "command: echo\nallowed_days: sat,sun\nallowed_hours: \"0-24\"\n")
+	configs, err := internal.ParseMultiYAML(path)
+	if err != nil {
+		t.Fatalf("ParseMultiYAML failed: %v", err)
+	}
+	if len(configs[0].AllowedDays) != 1 || configs[0].AllowedDays[0] != "sat,sun" {
+		t.Errorf("Expected scalar allowed_days to parse as one entry, got %v", configs[0].AllowedDays)
+	}
+
+	problems, err := internal.ValidateWorkflowFile(path, nil)
+	if err != nil || len(problems) != 0 {
+		t.Errorf("Expected window fields to validate, got %v (%v)", problems, err)
+	}
+}
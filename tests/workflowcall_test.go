@@ -0,0 +1,116 @@
+package tests
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+const calledWorkflow = `name: build
+lock: true
+---
+name: version
+command: echo
+args: ["v$version-{arch}"]
+variables:
+  arch: amd64
+capture: true
+---
+command: cat
+stdin:
+  step: version
+`
+
+const callingWorkflow = `variables:
+  v: "1.2"
+name: build
+workflow: build.yml
+set:
+  version: "{v}.${patch}"
+---
+command: cat
+stdin:
+  step: build/version
+`
+
+func TestWorkflowCall(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses cat")
+	}
+	dir := t.TempDir()
+	writeWorkflow(t, dir, "build.yml", calledWorkflow)
+	path := writeWorkflow(t, dir, "deploy.yml", callingWorkflow)
+	configs, err := internal.ParseMultiYAML(path)
+	if err != nil {
+		t.Fatalf("ParseMultiYAML failed: %v", err)
+	}
+	if len(configs) != 3 {
+		t.Fatalf("Expected 3 steps, got %d", len(configs))
+	}
+	if configs[0].Call == nil || configs[0].Call.Name != "build" || configs[0].Lock || configs[2].Call != nil {
+		t.Errorf("Unexpected called steps: %+v, %+v", configs[0], configs[2])
+	}
+
+	var out bytes.Buffer
+	overrides := map[string]string{"patch": "3", "version": "ignored"}
+	if err := internal.ExecuteMultipleCommandsWithOutput(configs, overrides, false, false, &out, &out); err != nil {
+		t.Fatalf("ExecuteMultipleCommandsWithOutput failed: %v", err)
+	}
+	want := "v1.2.3-amd64\nv1.2.3-amd64\nv1.2.3-amd64\n"
+	if out.String() != want {
+		t.Errorf("Expected %q, got %q", want, out.String())
+	}
+
+	if problems, err := internal.ValidateWorkflowFile(path, overrides); err != nil || len(problems) != 0 {
+		t.Errorf("Expected no problems, got %v %v", problems, err)
+	}
+}
+
+func TestWorkflowCallScope(t *testing.T) {
+	dir := t.TempDir()
+	writeWorkflow(t, dir, "build.yml", calledWorkflow)
+	path := writeWorkflow(t, dir, "release.yml", "name: ship\nworkflow: deploy.yml\n")
+	writeWorkflow(t, dir, "deploy.yml", callingWorkflow)
+	configs, err := internal.ParseMultiYAML(path)
+	if err != nil {
+		t.Fatalf("ParseMultiYAML failed: %v", err)
+	}
+
+	// The steps of deploy.yml see no -s values but the set: of ship, which has none
+	if _, err := internal.BuildCommand(configs[0], map[string]string{"patch": "3"}); err == nil || !strings.Contains(err.Error(), "patch") {
+		t.Errorf("Expected $patch not to reach the called workflow, got %v", err)
+	}
+	if id := internal.JobID(configs[0]); id != "ship-build-version" {
+		t.Errorf("Expected the job ID to be scoped by both calls, got %s", id)
+	}
+	if configs[2].Stdin.Step != "build/version" || configs[2].Call.Name != "ship" {
+		t.Errorf("Unexpected step of deploy.yml: %+v", configs[2])
+	}
+}
+
+func TestWorkflowCallErrors(t *testing.T) {
+	dir := t.TempDir()
+	a := writeWorkflow(t, dir, "a.yml", "workflow: b\n")
+	writeWorkflow(t, dir, "b.yml", "command: echo\n---\nworkflow: a.yml\n")
+	if _, err := internal.ParseMultiYAML(a); err == nil || !strings.Contains(err.Error(), "workflow call cycle: a -> b -> a") {
+		t.Errorf("Expected a cycle error, got %v", err)
+	}
+
+	for _, tc := range []struct{ content, want string }{
+		{"workflow: missing\n", "workflow: "},
+		{"workflow: c.yml\ncommand: echo\n", "a workflow: step cannot have command"},
+		{"command: echo\nset:\n  a: b\n", "set requires workflow:"},
+		{"workflow: c.yml\nset:\n  a: \"{nope}\"\n", "undefined variable {nope}"},
+		{"workflow: c.yml\n---\ncommand: cat\nstdin:\n  step: c/hidden\n", "stdin: step 'c/hidden' must be an earlier step"},
+	} {
+		writeWorkflow(t, dir, "c.yml", "name: hidden\ncommand: echo\n")
+		path := writeWorkflow(t, dir, "call.yml", tc.content)
+		problems, err := internal.ValidateWorkflowFile(path, nil)
+		if err != nil || len(problems) != 1 || !strings.Contains(problems[0].Message, tc.want) {
+			t.Errorf("%q: expected a problem with %q, got %v %v", tc.content, tc.want, problems, err)
+		}
+	}
+}
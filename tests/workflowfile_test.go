@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+// parseWorkflowFile writes content to a file named name and parses it
+func parseWorkflowFile(t *testing.T, name, content string) []*internal.CommandConfig {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	os.WriteFile(path, []byte(content), 0644)
+	configs, err := internal.ParseMultiYAML(path)
+	if err != nil {
+		t.Fatalf("ParseMultiYAML(%s) failed: %v", name, err)
+	}
+	return configs
+}
+
+func TestParseJSONWorkflow(t *testing.T) {
+	configs := parseWorkflowFile(t, "deploy.json", `[
+	{"name": "build", "command": "go", "subcommand": "build", "args": ["./..."]},
+	{"name": "push", "command": "sh", "args": ["-c", "exit 3"], "allowed_exit_codes": [0, 3], "variables": {"tag": "v1"}}
+]`)
+	if len(configs) != 2 {
+		t.Fatalf("Expected 2 steps, got %d", len(configs))
+	}
+	if configs[0].Command != "go" || configs[0].Subcommand != "build" {
+		t.Errorf("Unexpected first step: %+v", configs[0])
+	}
+	if len(configs[1].AllowedExitCodes) != 2 || configs[1].AllowedExitCodes[1] != 3 || configs[1].Variables["tag"] != "v1" {
+		t.Errorf("Expected allowed_exit_codes [0 3] and tag v1, got %v and %v", configs[1].AllowedExitCodes, configs[1].Variables)
+	}
+
+	single := parseWorkflowFile(t, "hello.json", `{"command": "echo", "args": ["hello"]}`)
+	if len(single) != 1 || single[0].Command != "echo" {
+		t.Errorf("Expected a single echo step, got %+v", single)
+	}
+}
+
+func TestParseTOMLWorkflow(t *testing.T) {
+	configs := parseWorkflowFile(t, "deploy.toml", `# Deploys the app
+[[steps]]
+name = "build"
+command = "go"
+args = ["build", "./..."]
+
+[[steps]]
+name = "greet"
+command = "echo"
+args = ["hello", "$who"]
+variables = { who = "world" }
+`)
+	if len(configs) != 2 || configs[1].Name != "greet" || configs[1].Variables["who"] != "world" {
+		t.Fatalf("Expected the build and greet steps, got %+v", configs)
+	}
+
+	single := parseWorkflowFile(t, "hello.toml", "command = \"echo\"\nargs = [\"hello\"]\n")
+	if len(single) != 1 || single[0].Command != "echo" {
+		t.Errorf("Expected a single echo step, got %+v", single)
+	}
+}
+
+func TestWorkflowFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"broken.json": `{"command": "echo",}`,
+		"broken.toml": `command = echo`,
+		"scalar.json": `"echo"`,
+	} {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(content), 0644)
+		if _, err := internal.ParseMultiYAML(path); err == nil {
+			t.Errorf("Expected %s to be refused", name)
+		}
+	}
+
+	path := filepath.Join(dir, "typo.json")
+	os.WriteFile(path, []byte(`{"commannd": "echo"}`), 0644)
+	problems, err := internal.ValidateWorkflowDefinition(path)
+	if err != nil {
+		t.Fatalf("ValidateWorkflowDefinition failed: %v", err)
+	}
+	if len(problems) == 0 || !strings.Contains(problems[0].Message, "unknown key 'commannd'") || problems[0].Line != 0 {
+		t.Errorf("Expected the unknown key without a line number, got %+v", problems)
+	}
+}
+
+func TestFindJSONAndTOMLWorkflows(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "gen.json"), []byte(`{"command": "echo"}`), 0644)
+	os.WriteFile(filepath.Join(dir, "conf.toml"), []byte(`command = "echo"`), 0644)
+
+	if got := internal.FindWorkflowInDirs("gen", []string{dir}); filepath.Base(got) != "gen.json" {
+		t.Errorf("Expected gen.json, got %q", got)
+	}
+	if got := internal.FindWorkflowInDirs("conf", []string{dir}); filepath.Base(got) != "conf.toml" {
+		t.Errorf("Expected conf.toml, got %q", got)
+	}
+	if internal.WorkflowName("conf.toml") != "conf" || !internal.IsWorkflowFile("gen.json") {
+		t.Errorf("Expected .json and .toml files to be workflows named without their extension")
+	}
+}
@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"linea/internal"
+)
+
+const deployWithInputs = `name: deploy
+description: Deploys the app to one region
+inputs:
+  - name: env
+    description: Target environment
+  - region
+  - name: tier
+    default: standard
+---
+command: echo
+args: ["$env", "$region", "$tier"]
+`
+
+func TestWorkflowInputs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deploy.yml")
+	os.WriteFile(path, []byte(deployWithInputs), 0644)
+	inputs, err := internal.WorkflowInputs(path)
+	if err != nil {
+		t.Fatalf("WorkflowInputs failed: %v", err)
+	}
+	if len(inputs) != 3 || inputs[0].Description != "Target environment" || inputs[1].Name != "region" || inputs[1].Optional() || *inputs[2].Default != "standard" {
+		t.Fatalf("Unexpected inputs %+v", inputs)
+	}
+	if usage := internal.InputUsage(inputs); usage != "<env> <region> [tier]" {
+		t.Errorf("Unexpected usage %q", usage)
+	}
+
+	// The inputs count as -s values for the $name references of the steps
+	problems, err := internal.ValidateWorkflowFile(path, nil)
+	if err != nil || len(problems) != 0 {
+		t.Errorf("Expected no problems, got %v %v", problems, err)
+	}
+
+	vars, err := internal.BindInputs("deploy", inputs, []string{"prod", "eu-west-1"}, nil)
+	if err != nil {
+		t.Fatalf("BindInputs failed: %v", err)
+	}
+	configs, _ := internal.ParseMultiYAML(path)
+	cmd, err := internal.BuildCommand(configs[0], vars)
+	if err != nil || strings.Join(cmd, " ") != "echo prod eu-west-1 standard" {
+		t.Errorf("Expected the arguments and the default in the command, got %v %v", cmd, err)
+	}
+}
+
+func TestBindInputs(t *testing.T) {
+	standard := "standard"
+	inputs := []internal.WorkflowInput{{Name: "env"}, {Name: "region"}, {Name: "tier", Default: &standard}}
+
+	vars, err := internal.BindInputs("deploy", inputs, []string{"prod"}, map[string]string{"region": "eu", "tag": "v1"})
+	if err != nil || vars["env"] != "prod" || vars["region"] != "eu" || vars["tier"] != "standard" || vars["tag"] != "v1" {
+		t.Errorf("Expected -s to set an input left out, got %v %v", vars, err)
+	}
+	if vars, _ := internal.BindInputs("deploy", inputs, []string{"prod", "eu"}, map[string]string{"tier": "gold"}); vars["tier"] != "gold" {
+		t.Errorf("Expected -s to set an optional input, got %v", vars)
+	}
+
+	for _, tc := range []struct {
+		inputs []internal.WorkflowInput
+		args   []string
+		vars   map[string]string
+		want   string
+	}{
+		{inputs, []string{"prod"}, nil, "missing argument <region> (usage: linea run deploy <env> <region> [tier])"},
+		{inputs, []string{"a", "b", "c", "d"}, nil, "deploy takes at most 3 argument(s), got 4"},
+		{inputs, []string{"prod", "eu"}, map[string]string{"env": "dev"}, "env is given both as argument 1 and with -s"},
+		{nil, []string{"prod"}, nil, "deploy takes no arguments, got 1"},
+	} {
+		if _, err := internal.BindInputs("deploy", tc.inputs, tc.args, tc.vars); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Expected an error containing %q, got %v", tc.want, err)
+		}
+	}
+}
+
+func TestCheckWorkflowInputs(t *testing.T) {
+	value := "x"
+	for _, tc := range []struct {
+		inputs []internal.WorkflowInput
+		want   string
+	}{
+		{[]internal.WorkflowInput{{Name: "my-env"}}, "invalid name 'my-env'"},
+		{[]internal.WorkflowInput{{Name: "env"}, {Name: "env"}}, "'env' is declared twice"},
+		{[]internal.WorkflowInput{{Name: "tier", Default: &value}, {Name: "env"}}, "required input 'env' cannot follow the optional input 'tier'"},
+	} {
+		if err := internal.CheckWorkflowInputs(tc.inputs); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Expected an error containing %q, got %v", tc.want, err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "deploy.yml")
+	os.WriteFile(path, []byte(strings.Replace(deployWithInputs, "  - region\n", "  - region\n  - env\n", 1)), 0644)
+	problems, _ := internal.ValidateWorkflowDefinition(path)
+	if len(problems) != 1 || !strings.Contains(problems[0].Message, "'env' is declared twice") {
+		t.Errorf("Expected linea validate to report the inputs, got %v", problems)
+	}
+}
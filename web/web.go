@@ -0,0 +1,9 @@
+// Package web holds the web UI of linea serve --ui, embedded in the linea binary
+package web
+
+import _ "embed"
+
+// Index is the single page of the web UI; it talks to the API of linea serve
+//
+//go:embed index.html
+var Index []byte